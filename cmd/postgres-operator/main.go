@@ -32,6 +32,8 @@ import (
 	"github.com/crunchydata/postgres-operator/internal/controller/postgrescluster"
 	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
 	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pki"
 	"github.com/crunchydata/postgres-operator/internal/upgradecheck"
 	"github.com/crunchydata/postgres-operator/internal/util"
 )
@@ -59,6 +61,21 @@ func main() {
 	err := util.AddAndSetFeatureGates(os.Getenv("PGO_FEATURE_GATES"))
 	assertNoError(err)
 
+	// Panic when FIPS validation is enabled and generated certificates would not pass it
+	if util.DefaultMutableFeatureGate.Enabled(util.FIPSValidation) {
+		assertNoError(pki.CheckGeneratedFIPS())
+	}
+
+	// Panic on a label domain that Kubernetes would reject
+	if domain := os.Getenv(naming.LabelDomainVariable); domain != "" {
+		assertNoError(naming.ValidateLabelDomain(domain))
+	}
+
+	// Panic on a cluster domain that cannot be part of a DNS name
+	if domain := os.Getenv(naming.KubernetesClusterDomainVariable); domain != "" {
+		assertNoError(naming.ValidateKubernetesClusterDomain(domain))
+	}
+
 	otelFlush, err := initOpenTelemetry()
 	assertNoError(err)
 	defer otelFlush()
@@ -72,6 +89,9 @@ func main() {
 
 	log.Info("feature gates enabled",
 		"PGO_FEATURE_GATES", os.Getenv("PGO_FEATURE_GATES"))
+	log.Info("label domain", naming.LabelDomainVariable, naming.LabelDomain())
+	log.Info("cluster domain", naming.KubernetesClusterDomainVariable,
+		naming.KubernetesClusterDomain(ctx))
 
 	cruntime.SetLogger(log)
 
@@ -126,8 +146,16 @@ func main() {
 // addControllersToManager adds all PostgreSQL Operator controllers to the provided controller
 // runtime manager.
 func addControllersToManager(mgr manager.Manager, openshift bool, log logr.Logger) {
+	// Fault injection is for testing PGO. It is available only in builds with
+	// the "faults" tag and is disabled unless PGO_FAULTS is set.
+	faults, err := runtime.ParseFaults(os.Getenv("PGO_FAULTS"))
+	assertNoError(err)
+	if faults != nil {
+		log.Info("fault injection enabled", "PGO_FAULTS", os.Getenv("PGO_FAULTS"))
+	}
+
 	pgReconciler := &postgrescluster.Reconciler{
-		Client:      mgr.GetClient(),
+		Client:      faults.Client(mgr.GetClient()),
 		Owner:       postgrescluster.ControllerName,
 		Recorder:    mgr.GetEventRecorderFor(postgrescluster.ControllerName),
 		Tracer:      otel.Tracer(postgrescluster.ControllerName),
@@ -138,11 +166,13 @@ func addControllersToManager(mgr manager.Manager, openshift bool, log logr.Logge
 		log.Error(err, "unable to create PostgresCluster controller")
 		os.Exit(1)
 	}
+	pgReconciler.PodExec = faults.PodExec(pgReconciler.PodExec)
 
 	upgradeReconciler := &pgupgrade.PGUpgradeReconciler{
-		Client: mgr.GetClient(),
-		Owner:  "pgupgrade-controller",
-		Scheme: mgr.GetScheme(),
+		Client:  faults.Client(mgr.GetClient()),
+		Owner:   "pgupgrade-controller",
+		Scheme:  mgr.GetScheme(),
+		PodExec: pgReconciler.PodExec,
 	}
 
 	if err := upgradeReconciler.SetupWithManager(mgr); err != nil {
@@ -16,6 +16,7 @@
 directory=$( cd "$( dirname "${BASH_SOURCE[0]}" )" && pwd )
 clusters_dir="${directory}/../build/crd/postgresclusters"
 upgrades_dir="${directory}/../build/crd/pgupgrades"
+sharedrepohosts_dir="${directory}/../build/crd/sharedrepohosts"
 
 # Generate a Kustomize patch file for removing any TODOs we inherit from the Kubernetes API.
 # Right now there is one TODO in our CRD. This script focuses on removing the specific TODO
@@ -52,3 +53,10 @@ python3 -m yq -y --arg old "${name_desc_with_todo}" --arg new "${name_desc_witho
 	[{ op: "remove", path: "/work" }]
 ' \
 	"${upgrades_dir}/generated/postgres-operator.crunchydata.com_pgupgrades.yaml" > "${upgrades_dir}/todos.yaml"
+
+python3 -m yq -y --arg old "${name_desc_with_todo}" --arg new "${name_desc_without_todo}" '
+	[{ op: "add", path: "/work", value: $new }] +
+	[paths(select(. == $old)) | { op: "copy", from: "/work", path: "/\(map(tostring) | join("/"))" }] +
+	[{ op: "remove", path: "/work" }]
+' \
+	"${sharedrepohosts_dir}/generated/postgres-operator.crunchydata.com_sharedrepohosts.yaml" > "${sharedrepohosts_dir}/todos.yaml"
@@ -0,0 +1,107 @@
+#!/usr/bin/env bash
+
+# Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+# http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Run the KUTTL end-to-end tests once for every PostgreSQL version in
+# KUTTL_MATRIX_POSTGRES against a new kind cluster for every Kubernetes version
+# in KUTTL_MATRIX_KUBERNETES. PGO is built from this checkout and runs locally.
+#
+# Requires docker, go, kind, kubectl, kubectl-kuttl, and envsubst. kind node
+# images are published for amd64 and arm64, so the matrix runs on either.
+#
+#   KUTTL_MATRIX_KUBERNETES="v1.25.3 v1.21.14" KUTTL_MATRIX_POSTGRES="14 15" hack/kuttl-matrix.sh
+
+set -eu -o pipefail
+
+directory=$( cd "$( dirname "${BASH_SOURCE[0]}" )/.." && pwd )
+cd "${directory}"
+
+read -ra kubernetes <<< "${KUTTL_MATRIX_KUBERNETES:-v1.25.3}"
+read -ra postgres <<< "${KUTTL_MATRIX_POSTGRES:-14 15}"
+declare -r kuttl="${KUTTL_TEST:-kubectl-kuttl test}"
+declare -r results="${KUTTL_MATRIX_RESULTS:-$(mktemp -d)}"
+mkdir -p "${results}"
+
+# related prints the value of the RELATED_IMAGE_* environment variable with
+# name in the PGO Deployment.
+related() {
+	kubectl kustomize ./config/dev | sed -ne '/^kind: Deployment/,/^---/ {
+		/name: '"$1"'$/ { n; s|.*value:[[:space:]]*"\{0,1\}\([^"[:space:]]*\).*|\1|p; }
+	}'
+}
+
+make build-postgres-operator
+
+failed=()
+for k8s in "${kubernetes[@]}"; do
+	cluster="pgo-kuttl-${k8s//./-}"
+	export KUBECONFIG="${results}/${cluster}.kubeconfig"
+
+	kind create cluster --name "${cluster}" --image "kindest/node:${k8s}" --wait 5m
+
+	# Start PGO in its own process group so that it can be stopped along with
+	# the make process that started it.
+	set -m
+	make deploy-dev > "${results}/${cluster}-pgo.log" 2>&1 &
+	pgo=$!
+	set +m
+	until kubectl get crd/postgresclusters.postgres-operator.crunchydata.com > /dev/null 2>&1
+	do kill -0 "${pgo}" || exit 1; sleep 1; done
+	kubectl wait --for=condition=Established --timeout=2m \
+		crd/postgresclusters.postgres-operator.crunchydata.com
+
+	for pg in "${postgres[@]}"; do
+		name="${k8s}-pg${pg}"
+		gis=$(kubectl kustomize ./config/dev |
+			sed -ne "s,.*name: RELATED_IMAGE_POSTGRES_${pg}_GIS_\([0-9.]*\)$,\1,p" | tail -n1)
+
+		# Upgrade to this version from the one before it, when there is an image for
+		# that. Otherwise, the major-upgrade test uses its default versions.
+		upgrade=()
+		if [ -n "$(related "RELATED_IMAGE_POSTGRES_$((pg - 1))")" ]; then
+			upgrade=(
+				KUTTL_PG_UPGRADE_FROM_VERSION="$((pg - 1))"
+				KUTTL_PG_UPGRADE_TO_VERSION="${pg}"
+			)
+		fi
+
+		echo "::group::${name}"
+		if env ${upgrade[@]+"${upgrade[@]}"} \
+			KUTTL_PG_VERSION="${pg}" \
+			KUTTL_POSTGIS_VERSION="${gis}" \
+			KUTTL_PSQL_IMAGE="$(related "RELATED_IMAGE_POSTGRES_${pg}")" \
+			make generate-kuttl &&
+			KUTTL_TEST="${kuttl}" make check-kuttl 2>&1 | tee "${results}/${name}.log"
+		then
+			echo "PASS ${name}"
+		else
+			echo "FAIL ${name}"
+			failed+=("${name}")
+		fi
+		echo '::endgroup::'
+	done
+
+	kill -- "-${pgo}" 2> /dev/null || true
+	wait "${pgo}" 2> /dev/null || true
+
+	if [ -z "${KUTTL_MATRIX_KEEP:-}" ]; then
+		kind delete cluster --name "${cluster}"
+	fi
+done
+
+echo "Logs are in ${results}"
+if [ "${#failed[@]}" -gt 0 ]; then
+	echo "Failed: ${failed[*]}"
+	exit 1
+fi
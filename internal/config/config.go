@@ -80,6 +80,23 @@ func PGExporterContainerImage(cluster *v1beta1.PostgresCluster) string {
 	return defaultFromEnv(image, "RELATED_IMAGE_PGEXPORTER")
 }
 
+// SharedRepoHostContainerImage returns the container image to use for the
+// pgBackRest repository host of host.
+func SharedRepoHostContainerImage(host *v1beta1.SharedRepoHost) string {
+	var image string
+	if host.Spec.Image != nil {
+		image = *host.Spec.Image
+	}
+
+	return defaultFromEnv(image, "RELATED_IMAGE_PGBACKREST")
+}
+
+// RepoSyncContainerImage returns the container image to use for copying a
+// pgBackRest repository according to sync.
+func RepoSyncContainerImage(sync *v1beta1.PGBackRestRepoSync) string {
+	return defaultFromEnv(sync.Image, "RELATED_IMAGE_RCLONE")
+}
+
 // PostgresContainerImage returns the container image to use for PostgreSQL.
 func PostgresContainerImage(cluster *v1beta1.PostgresCluster) string {
 	image := cluster.Spec.Image
@@ -121,6 +121,32 @@ func TestPGExporterContainerImage(t *testing.T) {
 	assert.Equal(t, PGExporterContainerImage(cluster), "spec-image")
 }
 
+func TestSharedRepoHostContainerImage(t *testing.T) {
+	host := &v1beta1.SharedRepoHost{}
+
+	unsetEnv(t, "RELATED_IMAGE_PGBACKREST")
+	assert.Equal(t, SharedRepoHostContainerImage(host), "")
+
+	setEnv(t, "RELATED_IMAGE_PGBACKREST", "env-var-pgbackrest")
+	assert.Equal(t, SharedRepoHostContainerImage(host), "env-var-pgbackrest")
+
+	assert.NilError(t, yaml.Unmarshal([]byte(`{ image: spec-image }`), &host.Spec))
+	assert.Equal(t, SharedRepoHostContainerImage(host), "spec-image")
+}
+
+func TestRepoSyncContainerImage(t *testing.T) {
+	sync := &v1beta1.PGBackRestRepoSync{}
+
+	unsetEnv(t, "RELATED_IMAGE_RCLONE")
+	assert.Equal(t, RepoSyncContainerImage(sync), "")
+
+	setEnv(t, "RELATED_IMAGE_RCLONE", "env-var-rclone")
+	assert.Equal(t, RepoSyncContainerImage(sync), "env-var-rclone")
+
+	assert.NilError(t, yaml.Unmarshal([]byte(`{ image: spec-image }`), sync))
+	assert.Equal(t, RepoSyncContainerImage(sync), "spec-image")
+}
+
 func TestPostgresContainerImage(t *testing.T) {
 	cluster := &v1beta1.PostgresCluster{}
 	cluster.Spec.PostgresVersion = 12
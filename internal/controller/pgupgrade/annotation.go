@@ -0,0 +1,103 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// upgradeApproved reports whether or not upgrade may annotate cluster. Either
+// upgrade refers to cluster as an owner, or someone allowed to write the status
+// of upgrade approved it.
+func upgradeApproved(upgrade *v1beta1.PGUpgrade, cluster *v1beta1.PostgresCluster) bool {
+	for _, owner := range upgrade.GetOwnerReferences() {
+		if owner.Kind == "PostgresCluster" && owner.Name == cluster.Name &&
+			owner.UID == cluster.UID {
+			return true
+		}
+	}
+	return meta.IsStatusConditionTrue(upgrade.Status.Conditions, ConditionPGUpgradeApproved)
+}
+
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={patch}
+
+// reconcileAllowUpgrade reports whether or not cluster is annotated for
+// upgrade. When it is not, it adds the annotation when asked and approved or
+// sets the Progressing condition to explain what is missing.
+func (r *PGUpgradeReconciler) reconcileAllowUpgrade(
+	ctx context.Context, upgrade *v1beta1.PGUpgrade, cluster *v1beta1.PostgresCluster,
+) (bool, error) {
+	current, annotated := cluster.GetAnnotations()[AnnotationAllowUpgrade]
+	if current == upgrade.Name {
+		return true, nil
+	}
+
+	blocked := func(reason, message string) {
+		meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+			ObservedGeneration: upgrade.Generation,
+			Type:               ConditionPGUpgradeProgressing,
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+
+	// Never take a cluster from another upgrade.
+	if !upgrade.Spec.AutoAnnotateCluster || annotated {
+		message := fmt.Sprintf("PostgresCluster %s lacks annotation for upgrade %s",
+			cluster.Name, upgrade.Name)
+		if annotated {
+			message = fmt.Sprintf("PostgresCluster %s is annotated for upgrade %q, not %s",
+				cluster.Name, current, upgrade.Name)
+		}
+		blocked("PGClusterMissingRequiredAnnotation", message)
+		return false, nil
+	}
+
+	if !upgradeApproved(upgrade, cluster) {
+		blocked("PGUpgradeNotApproved", fmt.Sprintf(
+			"Upgrade %s needs an owner reference to PostgresCluster %s or an %s condition to annotate it",
+			upgrade.Name, cluster.Name, ConditionPGUpgradeApproved))
+		return false, nil
+	}
+
+	// Add the annotation and fail when the cluster changed since it was read.
+	patch := cluster.DeepCopy()
+	annotations := patch.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[AnnotationAllowUpgrade] = upgrade.Name
+	patch.SetAnnotations(annotations)
+
+	err := errors.WithStack(r.Patch(ctx, patch, client.MergeFromWithOptions(
+		cluster, client.MergeFromWithOptimisticLock{}), r.Owner))
+	if err == nil {
+		cluster.SetAnnotations(patch.GetAnnotations())
+		cluster.SetResourceVersion(patch.GetResourceVersion())
+		ctrl.LoggerFrom(ctx).Info("Annotated PostgresCluster for upgrade",
+			"cluster", cluster.Name)
+	}
+	return err == nil, err
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestUpgradeApproved(t *testing.T) {
+	cluster := v1beta1.NewPostgresCluster()
+	cluster.Name, cluster.UID = "hippo", "abc"
+
+	upgrade := &v1beta1.PGUpgrade{}
+	assert.Assert(t, !upgradeApproved(upgrade, cluster))
+
+	t.Run("OwnerReference", func(t *testing.T) {
+		upgrade := upgrade.DeepCopy()
+		upgrade.OwnerReferences = []metav1.OwnerReference{
+			{Kind: "PostgresCluster", Name: "hippo", UID: "other"},
+		}
+		assert.Assert(t, !upgradeApproved(upgrade, cluster), "expected a matching UID")
+
+		upgrade.OwnerReferences[0].UID = "abc"
+		assert.Assert(t, upgradeApproved(upgrade, cluster))
+	})
+
+	t.Run("Condition", func(t *testing.T) {
+		upgrade := upgrade.DeepCopy()
+		upgrade.Status.Conditions = []metav1.Condition{{
+			Type: ConditionPGUpgradeApproved, Status: metav1.ConditionFalse,
+		}}
+		assert.Assert(t, !upgradeApproved(upgrade, cluster))
+
+		upgrade.Status.Conditions[0].Status = metav1.ConditionTrue
+		assert.Assert(t, upgradeApproved(upgrade, cluster))
+	})
+}
+
+func TestReconcileAllowUpgrade(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	setup := func(t *testing.T, annotations map[string]string) (
+		*PGUpgradeReconciler, *v1beta1.PGUpgrade, *v1beta1.PostgresCluster,
+	) {
+		cluster := v1beta1.NewPostgresCluster()
+		cluster.Namespace, cluster.Name = "ns1", "hippo"
+		cluster.Annotations = annotations
+
+		cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster).Build()
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+
+		upgrade := &v1beta1.PGUpgrade{}
+		upgrade.Namespace, upgrade.Name = "ns1", "up"
+		upgrade.Spec.PostgresClusterName = "hippo"
+
+		return &PGUpgradeReconciler{Client: cc}, upgrade, cluster
+	}
+
+	reason := func(upgrade *v1beta1.PGUpgrade) string {
+		progressing := meta.FindStatusCondition(upgrade.Status.Conditions, ConditionPGUpgradeProgressing)
+		if progressing == nil {
+			return ""
+		}
+		return progressing.Reason
+	}
+
+	t.Run("Annotated", func(t *testing.T) {
+		r, upgrade, cluster := setup(t, map[string]string{AnnotationAllowUpgrade: "up"})
+
+		allowed, err := r.reconcileAllowUpgrade(ctx, upgrade, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, allowed)
+		assert.Equal(t, reason(upgrade), "")
+	})
+
+	t.Run("NotAsked", func(t *testing.T) {
+		r, upgrade, cluster := setup(t, nil)
+		upgrade.Status.Conditions = []metav1.Condition{{
+			Type: ConditionPGUpgradeApproved, Status: metav1.ConditionTrue,
+		}}
+
+		allowed, err := r.reconcileAllowUpgrade(ctx, upgrade, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, !allowed)
+		assert.Equal(t, reason(upgrade), "PGClusterMissingRequiredAnnotation")
+	})
+
+	t.Run("OtherUpgrade", func(t *testing.T) {
+		r, upgrade, cluster := setup(t, map[string]string{AnnotationAllowUpgrade: "other"})
+		upgrade.Spec.AutoAnnotateCluster = true
+		upgrade.Status.Conditions = []metav1.Condition{{
+			Type: ConditionPGUpgradeApproved, Status: metav1.ConditionTrue,
+		}}
+
+		allowed, err := r.reconcileAllowUpgrade(ctx, upgrade, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, !allowed)
+		assert.Equal(t, reason(upgrade), "PGClusterMissingRequiredAnnotation")
+
+		progressing := meta.FindStatusCondition(upgrade.Status.Conditions, ConditionPGUpgradeProgressing)
+		assert.Equal(t, progressing.Message, `PostgresCluster hippo is annotated for upgrade "other", not up`)
+
+		assert.NilError(t, r.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+		assert.Equal(t, cluster.Annotations[AnnotationAllowUpgrade], "other")
+	})
+
+	t.Run("NotApproved", func(t *testing.T) {
+		r, upgrade, cluster := setup(t, nil)
+		upgrade.Spec.AutoAnnotateCluster = true
+
+		allowed, err := r.reconcileAllowUpgrade(ctx, upgrade, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, !allowed)
+		assert.Equal(t, reason(upgrade), "PGUpgradeNotApproved")
+
+		assert.NilError(t, r.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+		assert.Assert(t, cluster.Annotations == nil)
+	})
+
+	t.Run("Approved", func(t *testing.T) {
+		r, upgrade, cluster := setup(t, map[string]string{"keep": "this"})
+		upgrade.Spec.AutoAnnotateCluster = true
+		upgrade.OwnerReferences = []metav1.OwnerReference{
+			{Kind: "PostgresCluster", Name: "hippo", UID: cluster.UID},
+		}
+
+		allowed, err := r.reconcileAllowUpgrade(ctx, upgrade, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, allowed)
+		assert.Equal(t, reason(upgrade), "")
+		assert.Equal(t, cluster.Annotations[AnnotationAllowUpgrade], "up",
+			"expected the observed cluster to change")
+
+		stored := v1beta1.NewPostgresCluster()
+		assert.NilError(t, r.Get(ctx, client.ObjectKeyFromObject(cluster), stored))
+		assert.DeepEqual(t, stored.Annotations, map[string]string{
+			"keep": "this", AnnotationAllowUpgrade: "up",
+		})
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		r, upgrade, cluster := setup(t, nil)
+		upgrade.Spec.AutoAnnotateCluster = true
+		upgrade.Status.Conditions = []metav1.Condition{{
+			Type: ConditionPGUpgradeApproved, Status: metav1.ConditionTrue,
+		}}
+
+		// Something else changed the cluster since it was read.
+		changed := cluster.DeepCopy()
+		changed.Spec.PostgresVersion = 14
+		assert.NilError(t, r.Update(ctx, changed))
+
+		allowed, err := r.reconcileAllowUpgrade(ctx, upgrade, cluster)
+		assert.Assert(t, apierrors.IsConflict(err), "got %v", err)
+		assert.Assert(t, !allowed)
+		assert.Assert(t, cluster.Annotations == nil)
+	})
+}
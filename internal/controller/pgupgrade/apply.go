@@ -20,8 +20,13 @@ import (
 	"reflect"
 	"strings"
 
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/kubeapi"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // JSON6902 represents a JSON Patch according to RFC 6902; the same as
@@ -162,3 +167,35 @@ func (r *PGUpgradeReconciler) apply(ctx context.Context, object client.Object) e
 
 	return err
 }
+
+// applyJob is like [PGUpgradeReconciler.apply] for a Job. When policy says to,
+// disrupted pods do not count toward the backoffLimit of the Job.
+func (r *PGUpgradeReconciler) applyJob(
+	ctx context.Context, job *batchv1.Job, policy *v1beta1.JobPodFailurePolicy,
+) error {
+	ignore := policy != nil && policy.IgnoreDisruptions
+
+	// The pod failure policy of a Job cannot change after the Job is created,
+	// so keep the policy of an existing Job. The field is missing from the
+	// Job type, so read it as unstructured content.
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+
+	err := r.Client.Get(ctx, client.ObjectKeyFromObject(job), existing)
+	if err == nil {
+		_, ignore, _ = unstructured.NestedMap(existing.Object, "spec", "podFailurePolicy")
+	}
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	if !ignore {
+		return r.apply(ctx, job)
+	}
+
+	intent, err := kubeapi.IgnoreJobDisruptions(job)
+	if err == nil {
+		err = r.patch(ctx, intent, client.Apply, client.ForceOwnership)
+	}
+	return err
+}
@@ -0,0 +1,246 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// preUpgradeBackupID returns the value of the manual backup annotation that
+// requests the backup taken before upgrade.
+func preUpgradeBackupID(upgrade *v1beta1.PGUpgrade) string {
+	return "pgupgrade-" + string(upgrade.UID)
+}
+
+// rollbackRestoreID returns the value of the restore annotation that requests
+// the in-place restore that rolls back upgrade.
+func rollbackRestoreID(upgrade *v1beta1.PGUpgrade) string {
+	return "pgupgrade-rollback-" + string(upgrade.UID)
+}
+
+//+kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={patch}
+
+// reconcilePreUpgradeBackup asks the PostgresCluster controller for a full
+// backup of the cluster and waits for it to appear in the repository. It
+// returns true once the label of the backup is in the upgrade status.
+func (r *PGUpgradeReconciler) reconcilePreUpgradeBackup(
+	ctx context.Context, upgrade *v1beta1.PGUpgrade, world *World,
+) (bool, error) {
+	spec := upgrade.Spec.PreUpgradeBackup
+	status := upgrade.Status.PreUpgradeBackup
+	cluster := world.Cluster
+
+	if status != nil && status.RepoName == spec.RepoName && status.Label != "" {
+		return true, nil
+	}
+
+	// Request the backup by setting the manual backup fields and annotation
+	// of the cluster. PGO takes manual backups only from a running primary.
+	if status == nil || status.RepoName != spec.RepoName {
+		if world.ClusterShutdown {
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradeProgressing,
+				Status:             metav1.ConditionFalse,
+				Reason:             "PGUpgradeBackupPending",
+				Message: fmt.Sprintf(
+					"PostgresCluster %s must be running to back it up before upgrade",
+					upgrade.Spec.PostgresClusterName),
+			})
+			return false, nil
+		}
+
+		patch := cluster.DeepCopy()
+		annotations := patch.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[naming.PGBackRestBackup] = preUpgradeBackupID(upgrade)
+		patch.SetAnnotations(annotations)
+		patch.Spec.Backups.PGBackRest.Manual = &v1beta1.PGBackRestManualBackup{
+			RepoName: spec.RepoName,
+			Type:     "full",
+		}
+
+		err := errors.WithStack(r.Patch(ctx, patch, client.MergeFromWithOptions(
+			cluster, client.MergeFromWithOptimisticLock{}), r.Owner))
+
+		if err == nil {
+			upgrade.Status.PreUpgradeBackup = &v1beta1.PGUpgradeBackupStatus{
+				ID:       preUpgradeBackupID(upgrade),
+				RepoName: spec.RepoName,
+			}
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradeBackup,
+				Status:             metav1.ConditionUnknown,
+				Reason:             "PGUpgradeBackupRunning",
+				Message: fmt.Sprintf("Taking a full backup of PostgresCluster %s in %s",
+					upgrade.Spec.PostgresClusterName, spec.RepoName),
+			})
+		}
+		return false, err
+	}
+
+	var manual *v1beta1.PGBackRestJobStatus
+	if cluster.Status.PGBackRest != nil {
+		manual = cluster.Status.PGBackRest.ManualBackup
+	}
+
+	// Wait for the backup job to finish. Changes to the cluster are watched.
+	if manual == nil || manual.ID != status.ID || !manual.Finished {
+		return false, nil
+	}
+
+	if manual.Succeeded == 0 {
+		message := fmt.Sprintf(
+			"The full backup of PostgresCluster %s failed; recreate this upgrade to try again",
+			upgrade.Spec.PostgresClusterName)
+
+		meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+			ObservedGeneration: upgrade.Generation,
+			Type:               ConditionPGUpgradeBackup,
+			Status:             metav1.ConditionFalse,
+			Reason:             "PGUpgradeBackupFailed",
+			Message:            message,
+		})
+		meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+			ObservedGeneration: upgrade.Generation,
+			Type:               ConditionPGUpgradeProgressing,
+			Status:             metav1.ConditionFalse,
+			Reason:             "PGUpgradeBackupFailed",
+			Message:            message,
+		})
+		return false, nil
+	}
+
+	// The backups in a repository are read periodically, so the new one may
+	// not be listed yet. Changes to the cluster status are watched.
+	status.Label = preUpgradeBackupLabel(cluster, status.RepoName, manual.StartTime)
+	if status.Label == "" {
+		return false, nil
+	}
+
+	meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+		ObservedGeneration: upgrade.Generation,
+		Type:               ConditionPGUpgradeBackup,
+		Status:             metav1.ConditionTrue,
+		Reason:             "PGUpgradeBackupComplete",
+		Message: fmt.Sprintf("Backup %s of PostgresCluster %s is in %s",
+			status.Label, upgrade.Spec.PostgresClusterName, status.RepoName),
+	})
+	return true, nil
+}
+
+// preUpgradeBackupLabel returns the label of the newest full backup in the
+// repository named repoName of cluster that started no earlier than since.
+func preUpgradeBackupLabel(
+	cluster *v1beta1.PostgresCluster, repoName string, since *metav1.Time,
+) string {
+	var label string
+	if cluster.Status.PGBackRest == nil || since == nil {
+		return label
+	}
+
+	for _, repo := range cluster.Status.PGBackRest.Repos {
+		if repo.Name != repoName {
+			continue
+		}
+		// Backups are listed oldest first.
+		for _, backup := range repo.Backups {
+			if backup.Type == "full" && backup.StartTime != nil && !backup.StartTime.Before(since) {
+				label = backup.Label
+			}
+		}
+	}
+	return label
+}
+
+// startRollback sets cluster back to the version it had before upgrade and
+// asks the PostgresCluster controller to restore the pre-upgrade backup in
+// place. WAL archived after the backup is replayed, so the cluster returns to
+// the moment it was shut down for the upgrade.
+func (r *PGUpgradeReconciler) startRollback(
+	ctx context.Context, upgrade *v1beta1.PGUpgrade, cluster *v1beta1.PostgresCluster,
+) error {
+	backup := upgrade.Status.PreUpgradeBackup
+
+	patch := cluster.DeepCopy()
+	annotations := patch.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[naming.PGBackRestRestore] = rollbackRestoreID(upgrade)
+	patch.SetAnnotations(annotations)
+	patch.Spec.PostgresVersion = upgrade.Spec.FromPostgresVersion
+	patch.Spec.Backups.PGBackRest.Restore = &v1beta1.PGBackRestRestore{
+		Enabled: initialize.Bool(true),
+		PostgresClusterDataSource: &v1beta1.PostgresClusterDataSource{
+			RepoName: backup.RepoName,
+			Options:  []string{"--set=" + backup.Label},
+		},
+	}
+
+	return errors.WithStack(r.Patch(ctx, patch, client.MergeFromWithOptions(
+		cluster, client.MergeFromWithOptimisticLock{}), r.Owner))
+}
+
+// rollbackCondition returns the condition that reports the in-place restore
+// of cluster that rolls back upgrade.
+func rollbackCondition(
+	upgrade *v1beta1.PGUpgrade, cluster *v1beta1.PostgresCluster,
+) metav1.Condition {
+	condition := metav1.Condition{
+		ObservedGeneration: upgrade.Generation,
+		Type:               ConditionPGUpgradeRolledBack,
+		Status:             metav1.ConditionUnknown,
+		Reason:             "PGUpgradeRollbackRunning",
+		Message: fmt.Sprintf("Restoring backup %s of PostgresCluster %s",
+			upgrade.Status.PreUpgradeBackup.Label, upgrade.Spec.PostgresClusterName),
+	}
+
+	var restore *v1beta1.PGBackRestJobStatus
+	if cluster.Status.PGBackRest != nil {
+		restore = cluster.Status.PGBackRest.Restore
+	}
+
+	switch {
+	case restore == nil || restore.ID != rollbackRestoreID(upgrade) || !restore.Finished:
+	case restore.Succeeded > 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "PGUpgradeRolledBack"
+		condition.Message = fmt.Sprintf(
+			"PostgresCluster %s was restored from backup %s and is at version %d",
+			upgrade.Spec.PostgresClusterName, upgrade.Status.PreUpgradeBackup.Label,
+			upgrade.Spec.FromPostgresVersion)
+	default:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "PGUpgradeRollbackFailed"
+		condition.Message = fmt.Sprintf(
+			"Restoring backup %s of PostgresCluster %s failed, please check its restore job",
+			upgrade.Status.PreUpgradeBackup.Label, upgrade.Spec.PostgresClusterName)
+	}
+	return condition
+}
@@ -0,0 +1,225 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestPreUpgradeBackupLabel(t *testing.T) {
+	start := metav1.NewTime(time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC))
+	before := metav1.NewTime(start.Add(-time.Hour))
+	after := metav1.NewTime(start.Add(time.Minute))
+
+	cluster := v1beta1.NewPostgresCluster()
+	assert.Equal(t, preUpgradeBackupLabel(cluster, "repo1", &start), "")
+
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{
+			{Name: "repo1", Backups: []v1beta1.RepoBackupStatus{
+				{Label: "old-full", Type: "full", StartTime: &before},
+				{Label: "new-incr", Type: "incr", StartTime: &after},
+			}},
+			{Name: "repo2", Backups: []v1beta1.RepoBackupStatus{
+				{Label: "other-full", Type: "full", StartTime: &after},
+			}},
+		},
+	}
+	assert.Equal(t, preUpgradeBackupLabel(cluster, "repo1", &start), "",
+		"expected no full backup since start")
+	assert.Equal(t, preUpgradeBackupLabel(cluster, "repo1", nil), "")
+	assert.Equal(t, preUpgradeBackupLabel(cluster, "repo2", &start), "other-full")
+
+	repo := &cluster.Status.PGBackRest.Repos[0]
+	repo.Backups = append(repo.Backups,
+		v1beta1.RepoBackupStatus{Label: "new-full", Type: "full", StartTime: &after})
+	assert.Equal(t, preUpgradeBackupLabel(cluster, "repo1", &start), "new-full")
+}
+
+func TestReconcilePreUpgradeBackup(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := v1beta1.NewPostgresCluster()
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Spec.Backups.PGBackRest.Manual = &v1beta1.PGBackRestManualBackup{
+		RepoName: "repo1", Options: []string{"--type=incr"},
+	}
+
+	cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster).Build()
+	reconciler := &PGUpgradeReconciler{Client: cc}
+
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.UID = "some-uid"
+	upgrade.Spec.PostgresClusterName = "hippo"
+	upgrade.Spec.PreUpgradeBackup = &v1beta1.PGUpgradePreUpgradeBackupSpec{RepoName: "repo2"}
+
+	t.Run("Shutdown", func(t *testing.T) {
+		world := &World{Cluster: cluster, ClusterShutdown: true}
+
+		ready, err := reconciler.reconcilePreUpgradeBackup(ctx, upgrade, world)
+		assert.NilError(t, err)
+		assert.Assert(t, !ready)
+		assert.Assert(t, upgrade.Status.PreUpgradeBackup == nil)
+
+		progressing := meta.FindStatusCondition(upgrade.Status.Conditions, ConditionPGUpgradeProgressing)
+		assert.Assert(t, progressing != nil)
+		assert.Equal(t, progressing.Reason, "PGUpgradeBackupPending")
+	})
+
+	t.Run("Request", func(t *testing.T) {
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+		world := &World{Cluster: cluster}
+
+		ready, err := reconciler.reconcilePreUpgradeBackup(ctx, upgrade, world)
+		assert.NilError(t, err)
+		assert.Assert(t, !ready)
+		assert.DeepEqual(t, upgrade.Status.PreUpgradeBackup, &v1beta1.PGUpgradeBackupStatus{
+			ID: "pgupgrade-some-uid", RepoName: "repo2",
+		})
+
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+		assert.Equal(t, cluster.Annotations[naming.PGBackRestBackup], "pgupgrade-some-uid")
+		assert.DeepEqual(t, cluster.Spec.Backups.PGBackRest.Manual,
+			&v1beta1.PGBackRestManualBackup{RepoName: "repo2", Type: "full"})
+
+		condition := meta.FindStatusCondition(upgrade.Status.Conditions, ConditionPGUpgradeBackup)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionUnknown)
+	})
+
+	t.Run("Wait", func(t *testing.T) {
+		world := &World{Cluster: cluster.DeepCopy()}
+		world.Cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			ManualBackup: &v1beta1.PGBackRestJobStatus{ID: "pgupgrade-some-uid", Active: 1},
+		}
+
+		ready, err := reconciler.reconcilePreUpgradeBackup(ctx, upgrade, world)
+		assert.NilError(t, err)
+		assert.Assert(t, !ready)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		failed := upgrade.DeepCopy()
+		world := &World{Cluster: cluster.DeepCopy()}
+		world.Cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			ManualBackup: &v1beta1.PGBackRestJobStatus{
+				ID: "pgupgrade-some-uid", Finished: true, Failed: 1,
+			},
+		}
+
+		ready, err := reconciler.reconcilePreUpgradeBackup(ctx, failed, world)
+		assert.NilError(t, err)
+		assert.Assert(t, !ready)
+
+		condition := meta.FindStatusCondition(failed.Status.Conditions, ConditionPGUpgradeBackup)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "PGUpgradeBackupFailed")
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		start := metav1.Now()
+		world := &World{Cluster: cluster.DeepCopy()}
+		world.Cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			ManualBackup: &v1beta1.PGBackRestJobStatus{
+				ID: "pgupgrade-some-uid", Finished: true, Succeeded: 1, StartTime: &start,
+			},
+		}
+
+		// The repository has not been read since the backup.
+		ready, err := reconciler.reconcilePreUpgradeBackup(ctx, upgrade, world)
+		assert.NilError(t, err)
+		assert.Assert(t, !ready)
+
+		world.Cluster.Status.PGBackRest.Repos = []v1beta1.RepoStatus{{
+			Name: "repo2", Backups: []v1beta1.RepoBackupStatus{
+				{Label: "20230301-120000F", Type: "full", StartTime: &start},
+			},
+		}}
+
+		ready, err = reconciler.reconcilePreUpgradeBackup(ctx, upgrade, world)
+		assert.NilError(t, err)
+		assert.Assert(t, ready)
+		assert.Equal(t, upgrade.Status.PreUpgradeBackup.Label, "20230301-120000F")
+		assert.Assert(t, meta.IsStatusConditionTrue(upgrade.Status.Conditions, ConditionPGUpgradeBackup))
+	})
+}
+
+func TestRollback(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := v1beta1.NewPostgresCluster()
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Spec.PostgresVersion = 15
+
+	cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster).Build()
+	reconciler := &PGUpgradeReconciler{Client: cc}
+
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.UID = "some-uid"
+	upgrade.Spec.PostgresClusterName = "hippo"
+	upgrade.Spec.FromPostgresVersion = 14
+	upgrade.Status.PreUpgradeBackup = &v1beta1.PGUpgradeBackupStatus{
+		ID: "pgupgrade-some-uid", RepoName: "repo2", Label: "20230301-120000F",
+	}
+
+	assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+	assert.NilError(t, reconciler.startRollback(ctx, upgrade, cluster))
+
+	assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+	assert.Equal(t, cluster.Annotations[naming.PGBackRestRestore], "pgupgrade-rollback-some-uid")
+	assert.Equal(t, cluster.Spec.PostgresVersion, 14)
+
+	restore := cluster.Spec.Backups.PGBackRest.Restore
+	assert.Assert(t, restore != nil && restore.Enabled != nil && *restore.Enabled)
+	assert.Equal(t, restore.RepoName, "repo2")
+	assert.DeepEqual(t, restore.Options, []string{"--set=20230301-120000F"})
+
+	condition := rollbackCondition(upgrade, cluster)
+	assert.Equal(t, condition.Type, ConditionPGUpgradeRolledBack)
+	assert.Equal(t, condition.Status, metav1.ConditionUnknown)
+
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Restore: &v1beta1.PGBackRestJobStatus{ID: "something-else", Finished: true, Succeeded: 1},
+	}
+	assert.Equal(t, rollbackCondition(upgrade, cluster).Status, metav1.ConditionUnknown)
+
+	cluster.Status.PGBackRest.Restore.ID = "pgupgrade-rollback-some-uid"
+	condition = rollbackCondition(upgrade, cluster)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Equal(t, condition.Reason, "PGUpgradeRolledBack")
+
+	cluster.Status.PGBackRest.Restore.Succeeded = 0
+	cluster.Status.PGBackRest.Restore.Failed = 1
+	condition = rollbackCondition(upgrade, cluster)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	assert.Equal(t, condition.Reason, "PGUpgradeRollbackFailed")
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+//+kubebuilder:rbac:groups="",resources="pods/exec",verbs={create}
+
+// updateExtensions runs `ALTER EXTENSION ... UPDATE` in every database that has
+// extensions to update using the database container of pod. It returns the
+// result for each of those databases; those that failed have an Error. Earlier
+// results of databases that no longer need updates are kept when they succeeded.
+func (r *PGUpgradeReconciler) updateExtensions(
+	ctx context.Context, upgrade *v1beta1.PGUpgrade, pod *corev1.Pod,
+) ([]v1beta1.PGUpgradeExtensionUpdateStatus, error) {
+	exec := func(
+		_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		return r.PodExec(pod.Namespace, pod.Name, ContainerDatabase,
+			stdin, stdout, stderr, command...)
+	}
+
+	names := upgrade.Spec.UpdateExtensions.Names
+	databases, err := postgres.DatabasesWithOutdatedExtensions(ctx, exec, names)
+	if err != nil {
+		return nil, err
+	}
+
+	outdated := sets.NewString(databases...)
+	results := make([]v1beta1.PGUpgradeExtensionUpdateStatus, 0, len(databases))
+	for _, previous := range upgrade.Status.ExtensionUpdates {
+		if previous.Error == "" && !outdated.Has(previous.Database) {
+			results = append(results, previous)
+		}
+	}
+	for _, database := range databases {
+		updated, err := postgres.UpdateExtensionsInPostgreSQL(ctx, exec, database, names)
+
+		result := v1beta1.PGUpgradeExtensionUpdateStatus{
+			Database: database,
+			Updated:  updated,
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Database < results[j].Database
+	})
+	return results, nil
+}
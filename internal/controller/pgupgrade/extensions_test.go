@@ -0,0 +1,96 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestUpdateExtensions(t *testing.T) {
+	ctx := context.Background()
+
+	pod := &corev1.Pod{}
+	pod.Namespace = "ns1"
+	pod.Name = "pod2"
+
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.Spec.UpdateExtensions = &v1beta1.PGUpgradeUpdateExtensionsSpec{}
+
+	t.Run("Error", func(t *testing.T) {
+		expected := errors.New("boom")
+		reconciler := &PGUpgradeReconciler{
+			PodExec: func(
+				namespace, pod, container string,
+				stdin io.Reader, stdout, stderr io.Writer, command ...string,
+			) error {
+				assert.Equal(t, namespace, "ns1")
+				assert.Equal(t, pod, "pod2")
+				assert.Equal(t, container, "database")
+				return expected
+			},
+		}
+
+		_, err := reconciler.updateExtensions(ctx, upgrade, pod)
+		assert.Equal(t, err, expected)
+	})
+
+	t.Run("Databases", func(t *testing.T) {
+		reconciler := &PGUpgradeReconciler{
+			PodExec: func(
+				_, _, _ string,
+				stdin io.Reader, stdout, stderr io.Writer, command ...string,
+			) error {
+				b, err := io.ReadAll(stdin)
+				assert.NilError(t, err)
+
+				switch {
+				case !strings.Contains(string(b), "ALTER EXTENSION"):
+					_, _ = stdout.Write([]byte("app\nzoo\n"))
+					return nil
+				case strings.Contains(strings.Join(command, " "), "--set=database=zoo"):
+					_, _ = stderr.Write([]byte("ERROR:  no update path"))
+					return errors.New("exit 3")
+				default:
+					_, _ = stdout.Write([]byte("postgis\n"))
+					return nil
+				}
+			},
+		}
+
+		upgrade := upgrade.DeepCopy()
+		upgrade.Status.ExtensionUpdates = []v1beta1.PGUpgradeExtensionUpdateStatus{
+			{Database: "before", Updated: []string{"pgaudit"}},
+			{Database: "fixed", Error: "earlier"},
+			{Database: "zoo", Error: "earlier"},
+		}
+
+		results, err := reconciler.updateExtensions(ctx, upgrade, pod)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, results, []v1beta1.PGUpgradeExtensionUpdateStatus{
+			{Database: "app", Updated: []string{"postgis"}},
+			{Database: "before", Updated: []string{"pgaudit"}},
+			{Database: "zoo", Updated: []string{}, Error: "exit 3: ERROR:  no update path"},
+		})
+	})
+}
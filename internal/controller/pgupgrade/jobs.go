@@ -26,9 +26,40 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
+// nssWrapperScript is a portion of bash that makes the current UID and GID
+// resolve to "postgres". It expects the home directory in ${data_volume}.
+//
+// Note: Rather than import the nss_wrapper init container, as we do in the
+// main postgres-operator, these jobs do the required nss_wrapper settings here.
+var nssWrapperScript = strings.Join([]string{
+	// Create a copy of the system group definitions, but remove the "postgres"
+	// group or any group with the current GID. Replace them with our own that
+	// has the current GID.
+	`gid=$(id -G); NSS_WRAPPER_GROUP=$(mktemp)`,
+	`(sed "/^postgres:x:/ d; /^[^:]*:x:${gid%% *}:/ d" /etc/group`,
+	`echo "postgres:x:${gid%% *}:") > "${NSS_WRAPPER_GROUP}"`,
+
+	// Create a copy of the system user definitions, but remove the "postgres"
+	// user or any user with the currrent UID. Replace them with our own that
+	// has the current UID and GID.
+	`uid=$(id -u); NSS_WRAPPER_PASSWD=$(mktemp)`,
+	`(sed "/^postgres:x:/ d; /^[^:]*:x:${uid}:/ d" /etc/passwd`,
+	`echo "postgres:x:${uid}:${gid%% *}::${data_volume}:") > "${NSS_WRAPPER_PASSWD}"`,
+
+	// Enable nss_wrapper so the current UID and GID resolve to "postgres".
+	// - https://cwrap.org/nss_wrapper.html
+	`export LD_PRELOAD='libnss_wrapper.so' NSS_WRAPPER_GROUP NSS_WRAPPER_PASSWD`,
+}, "\n")
+
+// tablespaceVolumePrefix is how the names of tablespace volumes begin. See
+// [postgres.TablespaceVolumeMount].
+var tablespaceVolumePrefix = postgres.TablespaceVolumeMount("").Name
+
 // Upgrade job
 
 // pgUpgradeJob returns the ObjectMeta for the pg_upgrade Job utilized to
@@ -46,32 +77,23 @@ func upgradeCommand(upgrade *v1beta1.PGUpgrade) []string {
 	oldVersion := fmt.Sprint(upgrade.Spec.FromPostgresVersion)
 	newVersion := fmt.Sprint(upgrade.Spec.ToPostgresVersion)
 
+	// pg_upgrade copies files when no transfer mode is given.
+	// - https://www.postgresql.org/docs/current/pgupgrade.html
+	var transfer string
+	switch upgrade.Spec.TransferMode {
+	case "Copy":
+	case "Clone":
+		transfer = " --clone"
+	default:
+		transfer = " --link"
+	}
+
 	args := []string{oldVersion, newVersion}
 	script := strings.Join([]string{
 		`declare -r data_volume='/pgdata' old_version="$1" new_version="$2"`,
 		`printf 'Performing PostgreSQL upgrade from version "%s" to "%s" ...\n\n' "$@"`,
 
-		// Note: Rather than import the nss_wrapper init container, as we do in
-		// the main postgres-operator, this job does the required nss_wrapper
-		// settings here.
-
-		// Create a copy of the system group definitions, but remove the "postgres"
-		// group or any group with the current GID. Replace them with our own that
-		// has the current GID.
-		`gid=$(id -G); NSS_WRAPPER_GROUP=$(mktemp)`,
-		`(sed "/^postgres:x:/ d; /^[^:]*:x:${gid%% *}:/ d" /etc/group`,
-		`echo "postgres:x:${gid%% *}:") > "${NSS_WRAPPER_GROUP}"`,
-
-		// Create a copy of the system user definitions, but remove the "postgres"
-		// user or any user with the currrent UID. Replace them with our own that
-		// has the current UID and GID.
-		`uid=$(id -u); NSS_WRAPPER_PASSWD=$(mktemp)`,
-		`(sed "/^postgres:x:/ d; /^[^:]*:x:${uid}:/ d" /etc/passwd`,
-		`echo "postgres:x:${uid}:${gid%% *}::${data_volume}:") > "${NSS_WRAPPER_PASSWD}"`,
-
-		// Enable nss_wrapper so the current UID and GID resolve to "postgres".
-		// - https://cwrap.org/nss_wrapper.html
-		`export LD_PRELOAD='libnss_wrapper.so' NSS_WRAPPER_GROUP NSS_WRAPPER_PASSWD`,
+		nssWrapperScript,
 
 		// Below is the pg_upgrade script used to upgrade a PostgresCluster from
 		// one major verson to another. Additional information concerning the
@@ -91,6 +113,19 @@ func upgradeCommand(upgrade *v1beta1.PGUpgrade) []string {
 		// preload library settings must be copied over.
 		`echo -e "\nStep 3: Setting the expected permissions on the old pgdata directory...\n"`,
 		`chmod 700 /pgdata/pg"${old_version}"`,
+
+		// Tablespaces are upgraded where they are; pg_upgrade creates a directory
+		// for the new version next to the old one at each location. The tablespace
+		// volumes are mounted from the startup instance, so fail clearly when a
+		// location is missing rather than part way through pg_upgrade.
+		// - https://www.postgresql.org/docs/current/pgupgrade.html
+		`for tablespace in /pgdata/pg"${old_version}"/pg_tblspc/*; do`,
+		`[ -L "${tablespace}" ] || continue`,
+		`location=$(readlink "${tablespace}")`,
+		`if [ ! -d "${location}" ]; then echo "Tablespace ${tablespace##*/} at ${location} is not mounted"; exit 1; fi`,
+		`echo "Tablespace ${tablespace##*/} is at ${location}"`,
+		`done`,
+
 		`echo -e "Step 4: Copying shared_preload_libraries setting to new postgresql.conf file...\n"`,
 		`echo "shared_preload_libraries = '$(/usr/pgsql-"""${old_version}"""/bin/postgres -D \`,
 		`/pgdata/pg"""${old_version}""" -C shared_preload_libraries)'" >> /pgdata/pg"${new_version}"/postgresql.conf`,
@@ -100,17 +135,17 @@ func upgradeCommand(upgrade *v1beta1.PGUpgrade) []string {
 		`echo -e "Step 5: Running pg_upgrade check...\n"`,
 		`time /usr/pgsql-"${new_version}"/bin/pg_upgrade --old-bindir /usr/pgsql-"${old_version}"/bin \`,
 		`--new-bindir /usr/pgsql-"${new_version}"/bin --old-datadir /pgdata/pg"${old_version}"\`,
-		` --new-datadir /pgdata/pg"${new_version}" --link --check`,
+		` --new-datadir /pgdata/pg"${new_version}"` + transfer + ` --check`,
 
 		// Assuming the check completes successfully, the pg_upgrade command will
 		// be run that actually prepares the upgraded pgdata directory.
 		`echo -e "\nStep 6: Running pg_upgrade...\n"`,
 		`time /usr/pgsql-"${new_version}"/bin/pg_upgrade --old-bindir /usr/pgsql-"${old_version}"/bin \`,
 		`--new-bindir /usr/pgsql-"${new_version}"/bin --old-datadir /pgdata/pg"${old_version}" \`,
-		`--new-datadir /pgdata/pg"${new_version}" --link`,
+		`--new-datadir /pgdata/pg"${new_version}"` + transfer,
 
-		// Since we have cleared the Patroni cluster step by removing the EndPoints, we copy patroni.dynamic.json
-		// from the old data dir to help retain PostgreSQL parameters you had set before.
+		// Since we have cleared the Patroni cluster state by removing its Endpoints or ConfigMaps, we copy
+		// patroni.dynamic.json from the old data dir to help retain PostgreSQL parameters you had set before.
 		// - https://patroni.readthedocs.io/en/latest/existing_data.html#major-upgrade-of-postgresql-version
 		`echo -e "\nStep 7: Copying patroni.dynamic.json...\n"`,
 		`cp /pgdata/pg"${old_version}"/patroni.dynamic.json /pgdata/pg"${new_version}"`,
@@ -161,8 +196,12 @@ func (r *PGUpgradeReconciler) generateUpgradeJob(
 	// Use the image pull secrets specified for the upgrade image.
 	job.Spec.Template.Spec.ImagePullSecrets = upgrade.Spec.ImagePullSecrets
 
-	// Attempt the upgrade exactly once.
+	// Attempt the upgrade exactly once unless the spec allows retries.
 	job.Spec.BackoffLimit = initialize.Int32(0)
+	if upgrade.Spec.BackoffLimit != nil {
+		job.Spec.BackoffLimit = upgrade.Spec.BackoffLimit
+	}
+	job.Spec.ActiveDeadlineSeconds = upgrade.Spec.ActiveDeadlineSeconds
 	job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
 
 	// Replace all containers with one that does the upgrade.
@@ -193,11 +232,233 @@ func (r *PGUpgradeReconciler) generateUpgradeJob(
 	return job
 }
 
+// Preflight job
+
+// pgUpgradePreflightJob returns the ObjectMeta for the Job that checks a copy
+// of the primary for incompatibilities before the cluster is shut down.
+func pgUpgradePreflightJob(upgrade *v1beta1.PGUpgrade) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: upgrade.Namespace,
+		Name:      upgrade.Name + "-preflight",
+	}
+}
+
+// preflightCommand returns an entrypoint that copies the data directory of
+// the primary at host and runs `pg_upgrade --check` against that copy. The
+// names of any checks that fail are written to the termination message of
+// the container, one per line. Tablespaces in the volumes mounted at each of
+// tablespaces are copied below /pgdata.
+func preflightCommand(upgrade *v1beta1.PGUpgrade, host string, tablespaces []string) []string {
+	oldVersion := fmt.Sprint(upgrade.Spec.FromPostgresVersion)
+	newVersion := fmt.Sprint(upgrade.Spec.ToPostgresVersion)
+
+	// pg_basebackup writes tablespaces to the same locations as the primary
+	// unless they are mapped elsewhere. Tablespaces are created in the "data"
+	// directory of their volume; see the tablespaces guide.
+	// - https://www.postgresql.org/docs/current/app-pgbasebackup.html
+	var mappings string
+	for _, mount := range tablespaces {
+		mappings += fmt.Sprintf(" --tablespace-mapping=%s/data=/pgdata%s/data", mount, mount)
+	}
+
+	args := []string{oldVersion, newVersion, host}
+	script := strings.Join([]string{
+		`declare -r data_volume='/pgdata' old_version="$1" new_version="$2" primary="$3"`,
+		`printf 'Checking PostgreSQL upgrade from version "%s" to "%s" ...\n\n' "$1" "$2"`,
+		nssWrapperScript,
+
+		// Copy the replication client certificate files so they have the
+		// permissions libpq expects. See the same in the database container.
+		fmt.Sprintf(`install -D --mode=0600 -t %q %q/{%s,%s,%s}`,
+			naming.ReplicationTmp, naming.CertMountPath+naming.ReplicationDirectory,
+			naming.ReplicationCert, naming.ReplicationPrivateKey,
+			naming.ReplicationCACert),
+
+		// Connect to the primary the same way replicas do. Patroni does this
+		// using the same user and certificate.
+		fmt.Sprintf(`conninfo="host=${primary} user=%s sslmode=verify-ca sslcert=%s sslkey=%s sslrootcert=%s"`,
+			postgres.ReplicationUser,
+			naming.ReplicationTmp+"/"+naming.ReplicationCert,
+			naming.ReplicationTmp+"/"+naming.ReplicationPrivateKey,
+			naming.ReplicationTmp+"/"+naming.ReplicationCACert),
+
+		// pg_upgrade requires a data directory that was shut down cleanly, so
+		// copy the primary and bring that copy to a consistent state. Disable
+		// archiving in the copy so that none of its WAL reaches a repository.
+		`cd /pgdata || exit`,
+		`echo -e "Step 1: Copying the primary data directory...\n"`,
+		`/usr/pgsql-"${old_version}"/bin/pg_basebackup --pgdata=/pgdata/pg"${old_version}" \`,
+		`--checkpoint=fast --wal-method=stream --no-sync --dbname="${conninfo}"` + mappings,
+		`echo "archive_mode = 'off'" >> /pgdata/pg"${old_version}"/postgresql.auto.conf`,
+		`echo -e "\nStep 2: Recovering and stopping the copy...\n"`,
+		`/usr/pgsql-"${old_version}"/bin/pg_ctl start --wait --pgdata=/pgdata/pg"${old_version}" \`,
+		`--options="-c listen_addresses='' -c unix_socket_directories='/tmp'"`,
+		`/usr/pgsql-"${old_version}"/bin/pg_ctl stop --wait --mode=fast --pgdata=/pgdata/pg"${old_version}"`,
+
+		// Prepare a new data directory the same way the upgrade job does.
+		`echo -e "\nStep 3: Initializing new pgdata directory...\n"`,
+		`/usr/pgsql-"${new_version}"/bin/initdb -k -D /pgdata/pg"${new_version}"`,
+		`echo "shared_preload_libraries = '$(/usr/pgsql-"""${old_version}"""/bin/postgres -D \`,
+		`/pgdata/pg"""${old_version}""" -C shared_preload_libraries)'" >> /pgdata/pg"${new_version}"/postgresql.conf`,
+
+		`echo -e "\nStep 4: Running pg_upgrade check...\n"`,
+		`set -o pipefail`,
+		`if /usr/pgsql-"${new_version}"/bin/pg_upgrade --old-bindir /usr/pgsql-"${old_version}"/bin \`,
+		`--new-bindir /usr/pgsql-"${new_version}"/bin --old-datadir /pgdata/pg"${old_version}" \`,
+		`--new-datadir /pgdata/pg"${new_version}" --link --check | tee /tmp/check.log`,
+		`then echo -e "\npg_upgrade Preflight Job Complete!"; exit 0; fi`,
+
+		// pg_upgrade prints "fatal" after the name of each check that fails and
+		// writes the details to text files. Print those files and report the
+		// names of the failed checks.
+		// - https://kubernetes.io/docs/tasks/debug/debug-application/determine-reason-pod-failure/
+		`find /pgdata -name '*.txt' -exec tail -n +1 -- {} + || true`,
+		`sed -n 's/[[:space:]]*fatal$//p' /tmp/check.log > /dev/termination-log`,
+		`exit 1`,
+	}, "\n")
+
+	return append([]string{"bash", "-ceu", "--", script, "preflight"}, args...)
+}
+
+// generatePreflightJob returns a Job that checks a copy of the primary at host
+// for incompatibilities. It runs with the pod template of instance, but keeps
+// only its certificates; the copy is written to an emptyDir volume.
+func (r *PGUpgradeReconciler) generatePreflightJob(
+	_ context.Context, upgrade *v1beta1.PGUpgrade, instance *appsv1.StatefulSet,
+	host string,
+) *batchv1.Job {
+	job := &batchv1.Job{}
+	job.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+
+	job.Namespace = upgrade.Namespace
+	job.Name = pgUpgradePreflightJob(upgrade).Name
+
+	job.Annotations = upgrade.Spec.Metadata.GetAnnotationsOrNil()
+	job.Labels = Merge(upgrade.Spec.Metadata.GetLabelsOrNil(),
+		commonLabels(preflight, upgrade),
+		map[string]string{
+			LabelVersion: fmt.Sprint(upgrade.Spec.ToPostgresVersion),
+		})
+
+	// Find the database container.
+	var database *corev1.Container
+	for i := range instance.Spec.Template.Spec.Containers {
+		container := instance.Spec.Template.Spec.Containers[i]
+		if container.Name == ContainerDatabase {
+			database = &container
+		}
+	}
+
+	// Copy the pod template from the instance StatefulSet. This includes the
+	// service account, DNS policies, and security context.
+	instance.Spec.Template.DeepCopyInto(&job.Spec.Template)
+
+	// Use the same labels and annotations as the job.
+	job.Spec.Template.ObjectMeta = metav1.ObjectMeta{
+		Annotations: job.Annotations,
+		Labels:      job.Labels,
+	}
+
+	// The volumes of the instance are in use, so mount only its certificates
+	// and put an emptyDir where its data would be. Tablespaces are copied into
+	// that emptyDir as well.
+	var data string
+	var mounts []corev1.VolumeMount
+	var tablespaces []string
+	for _, mount := range database.VolumeMounts {
+		if mount.MountPath == "/pgdata" {
+			data = mount.Name
+		}
+		if mount.Name == naming.CertVolume || mount.Name == data {
+			mounts = append(mounts, mount)
+		}
+		if strings.HasPrefix(mount.Name, tablespaceVolumePrefix) {
+			tablespaces = append(tablespaces, mount.MountPath)
+		}
+	}
+	var volumes []corev1.Volume
+	for _, volume := range job.Spec.Template.Spec.Volumes {
+		switch volume.Name {
+		case naming.CertVolume:
+			volumes = append(volumes, volume)
+		case data:
+			volumes = append(volumes, corev1.Volume{
+				Name: volume.Name,
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{},
+				},
+			})
+		}
+	}
+	job.Spec.Template.Spec.Volumes = volumes
+
+	// Use the image pull secrets specified for the upgrade image.
+	job.Spec.Template.Spec.ImagePullSecrets = upgrade.Spec.ImagePullSecrets
+
+	// Check exactly once unless the spec allows retries.
+	job.Spec.BackoffLimit = initialize.Int32(0)
+	if upgrade.Spec.BackoffLimit != nil {
+		job.Spec.BackoffLimit = upgrade.Spec.BackoffLimit
+	}
+	job.Spec.ActiveDeadlineSeconds = upgrade.Spec.ActiveDeadlineSeconds
+	job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+
+	// Replace all containers with one that does the check.
+	job.Spec.Template.Spec.EphemeralContainers = nil
+	job.Spec.Template.Spec.InitContainers = nil
+	job.Spec.Template.Spec.Containers = []corev1.Container{{
+		Name:            database.Name,
+		SecurityContext: database.SecurityContext,
+		VolumeMounts:    mounts,
+
+		// Use our preflight command and the specified image and resources.
+		Command:         preflightCommand(upgrade, host, tablespaces),
+		Image:           pgUpgradeContainerImage(upgrade),
+		ImagePullPolicy: upgrade.Spec.ImagePullPolicy,
+		Resources:       upgrade.Spec.Resources,
+	}}
+
+	// The following will set these fields to null if not set in the spec
+	job.Spec.Template.Spec.Affinity = upgrade.Spec.Affinity
+	job.Spec.Template.Spec.PriorityClassName = initialize.FromPointer(
+		upgrade.Spec.PriorityClassName)
+	job.Spec.Template.Spec.Tolerations = upgrade.Spec.Tolerations
+
+	r.setControllerReference(upgrade, job)
+	return job
+}
+
+// failedChecks returns the checks reported by the most recent preflight or
+// scan pod that failed. It returns nil when no pod reported any.
+func failedChecks(pods []*corev1.Pod) []string {
+	var latest *corev1.ContainerStateTerminated
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if terminated := status.State.Terminated; status.Name == ContainerDatabase &&
+				terminated != nil && terminated.ExitCode != 0 &&
+				(latest == nil || latest.FinishedAt.Before(&terminated.FinishedAt)) {
+				latest = terminated
+			}
+		}
+	}
+
+	var checks []string
+	if latest != nil {
+		for _, line := range strings.Split(latest.Message, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				checks = append(checks, line)
+			}
+		}
+	}
+	return checks
+}
+
 // Remove data job
 
 // removeDataCommand returns an entrypoint that removes certain directories.
 // We currently target the `pgdata/pg{old_version}` and `pgdata/pg{old_version}_wal`
-// directories for removal.
+// directories for removal, along with the directories of that version in every
+// tablespace.
 func removeDataCommand(upgrade *v1beta1.PGUpgrade) []string {
 	oldVersion := fmt.Sprint(upgrade.Spec.FromPostgresVersion)
 
@@ -221,6 +482,14 @@ func removeDataCommand(upgrade *v1beta1.PGUpgrade) []string {
 		// was shut down as a replica.
 		// - https://git.postgresql.org/gitweb/?p=postgresql.git;a=blob;f=src/bin/pg_upgrade/controldata.c;h=41b8f69b8cbe4f40e6098ad84c2e8e987e24edaf;hb=HEAD#l122
 		`if [ "$(/usr/pgsql-"${old_version}"/bin/pg_controldata /pgdata/pg"${old_version}" | grep -c "shut down in recovery")" -ne 1 ]; then echo -e "Directory in use, cannot remove..."; exit 1; fi`,
+		// Each tablespace has a directory for the old version at the location
+		// its link points to. A replica cannot be recreated while those remain;
+		// both pg_basebackup and pgBackRest require the tablespace to be empty.
+		`echo -e "Removing old tablespace directories...\n"`,
+		`for tablespace in /pgdata/pg"${old_version}"/pg_tblspc/*; do`,
+		`[ -L "${tablespace}" ] || continue`,
+		`rm -rf "$(realpath "${tablespace}")"/PG_"${old_version}"_*`,
+		`done`,
 		`echo -e "Removing old pgdata directory...\n"`,
 		// When deleting the wal directory, use `realpath` to resolve the symlink from
 		// the pgdata directory. This is necessary because the wal directory can be
@@ -270,8 +539,12 @@ func (r *PGUpgradeReconciler) generateRemoveDataJob(
 	// Use the image pull secrets specified for the upgrade image.
 	job.Spec.Template.Spec.ImagePullSecrets = upgrade.Spec.ImagePullSecrets
 
-	// Attempt the removal exactly once.
+	// Attempt the removal exactly once unless the spec allows retries.
 	job.Spec.BackoffLimit = initialize.Int32(0)
+	if upgrade.Spec.BackoffLimit != nil {
+		job.Spec.BackoffLimit = upgrade.Spec.BackoffLimit
+	}
+	job.Spec.ActiveDeadlineSeconds = upgrade.Spec.ActiveDeadlineSeconds
 	job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
 
 	// Replace all containers with one that removes the data.
@@ -18,12 +18,14 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/assert/cmp"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
@@ -128,6 +130,12 @@ spec:
           /usr/pgsql-"${new_version}"/bin/initdb -k -D /pgdata/pg"${new_version}"
           echo -e "\nStep 3: Setting the expected permissions on the old pgdata directory...\n"
           chmod 700 /pgdata/pg"${old_version}"
+          for tablespace in /pgdata/pg"${old_version}"/pg_tblspc/*; do
+          [ -L "${tablespace}" ] || continue
+          location=$(readlink "${tablespace}")
+          if [ ! -d "${location}" ]; then echo "Tablespace ${tablespace##*/} at ${location} is not mounted"; exit 1; fi
+          echo "Tablespace ${tablespace##*/} is at ${location}"
+          done
           echo -e "Step 4: Copying shared_preload_libraries setting to new postgresql.conf file...\n"
           echo "shared_preload_libraries = '$(/usr/pgsql-"""${old_version}"""/bin/postgres -D \
           /pgdata/pg"""${old_version}""" -C shared_preload_libraries)'" >> /pgdata/pg"${new_version}"/postgresql.conf
@@ -162,6 +170,68 @@ spec:
         name: vol2
 status: {}
 	`))
+
+	t.Run("Retries", func(t *testing.T) {
+		upgrade := upgrade.DeepCopy()
+		upgrade.Spec.BackoffLimit = initialize.Int32(2)
+		upgrade.Spec.ActiveDeadlineSeconds = initialize.Int64(3600)
+
+		job := reconciler.generateUpgradeJob(ctx, upgrade, startup.DeepCopy())
+		assert.Equal(t, *job.Spec.BackoffLimit, int32(2))
+		assert.Equal(t, *job.Spec.ActiveDeadlineSeconds, int64(3600))
+
+		job = reconciler.generateRemoveDataJob(ctx, upgrade, startup.DeepCopy())
+		assert.Equal(t, *job.Spec.BackoffLimit, int32(2))
+		assert.Equal(t, *job.Spec.ActiveDeadlineSeconds, int64(3600))
+	})
+
+	t.Run("TransferMode", func(t *testing.T) {
+		upgrade := upgrade.DeepCopy()
+
+		upgrade.Spec.TransferMode = "Link"
+		script := upgradeCommand(upgrade)[3]
+		assert.Assert(t, cmp.Contains(script, `--new-datadir /pgdata/pg"${new_version}" --link --check`))
+		assert.Assert(t, cmp.Contains(script, `--new-datadir /pgdata/pg"${new_version}" --link`+"\n"))
+
+		upgrade.Spec.TransferMode = "Clone"
+		script = upgradeCommand(upgrade)[3]
+		assert.Assert(t, cmp.Contains(script, `--new-datadir /pgdata/pg"${new_version}" --clone --check`))
+		assert.Assert(t, cmp.Contains(script, `--new-datadir /pgdata/pg"${new_version}" --clone`+"\n"))
+
+		upgrade.Spec.TransferMode = "Copy"
+		script = upgradeCommand(upgrade)[3]
+		assert.Assert(t, !strings.Contains(script, "--link"), "expected no --link in\n%s", script)
+		assert.Assert(t, !strings.Contains(script, "--clone"), "expected no --clone in\n%s", script)
+		assert.Assert(t, cmp.Contains(script, `--new-datadir /pgdata/pg"${new_version}" --check`))
+	})
+
+	t.Run("Tablespaces", func(t *testing.T) {
+		startup := startup.DeepCopy()
+		startup.Spec.Template.Spec.Volumes = append(startup.Spec.Template.Spec.Volumes,
+			corev1.Volume{
+				Name: "tablespace-books",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: "pg5-abcd-books-tablespace",
+					},
+				},
+			})
+		startup.Spec.Template.Spec.Containers[0].VolumeMounts = append(
+			startup.Spec.Template.Spec.Containers[0].VolumeMounts,
+			corev1.VolumeMount{Name: "tablespace-books", MountPath: "/tablespaces/books"})
+
+		// The tablespace volumes of the instance are upgraded in place.
+		pod := reconciler.generateUpgradeJob(ctx, upgrade, startup).Spec.Template.Spec
+		assert.DeepEqual(t, pod.Volumes, startup.Spec.Template.Spec.Volumes)
+		assert.DeepEqual(t, pod.Containers[0].VolumeMounts,
+			startup.Spec.Template.Spec.Containers[0].VolumeMounts)
+
+		// So are the tablespace volumes of replicas whose data is removed.
+		pod = reconciler.generateRemoveDataJob(ctx, upgrade, startup).Spec.Template.Spec
+		assert.DeepEqual(t, pod.Volumes, startup.Spec.Template.Spec.Volumes)
+		assert.DeepEqual(t, pod.Containers[0].VolumeMounts,
+			startup.Spec.Template.Spec.Containers[0].VolumeMounts)
+	})
 }
 
 func TestGenerateRemoveDataJob(t *testing.T) {
@@ -241,6 +311,11 @@ spec:
           echo -e "Checking the directory exists and isn't being used...\n"
           cd /pgdata || exit
           if [ "$(/usr/pgsql-"${old_version}"/bin/pg_controldata /pgdata/pg"${old_version}" | grep -c "shut down in recovery")" -ne 1 ]; then echo -e "Directory in use, cannot remove..."; exit 1; fi
+          echo -e "Removing old tablespace directories...\n"
+          for tablespace in /pgdata/pg"${old_version}"/pg_tblspc/*; do
+          [ -L "${tablespace}" ] || continue
+          rm -rf "$(realpath "${tablespace}")"/PG_"${old_version}"_*
+          done
           echo -e "Removing old pgdata directory...\n"
           rm -rf /pgdata/pg"${old_version}" "$(realpath /pgdata/pg${old_version}/pg_wal)"
           echo -e "Remove Data Job Complete!"
@@ -264,3 +339,209 @@ spec:
 status: {}
 	`))
 }
+
+func TestUpgradeJobScheduling(t *testing.T) {
+	ctx := context.Background()
+	reconciler := &PGUpgradeReconciler{}
+
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.Namespace = "ns1"
+	upgrade.Name = "pgu2"
+	upgrade.Spec.FromPostgresVersion = 19
+	upgrade.Spec.ToPostgresVersion = 25
+	upgrade.Spec.Resources.Limits = corev1.ResourceList{
+		corev1.ResourceMemory: resource.MustParse("8Gi"),
+	}
+	upgrade.Spec.Affinity = &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key: "node-size", Operator: corev1.NodeSelectorOpIn,
+						Values: []string{"large"},
+					}},
+				}},
+			},
+		},
+	}
+	upgrade.Spec.PriorityClassName = initialize.Pointer("maintenance")
+	upgrade.Spec.Tolerations = []corev1.Toleration{{
+		Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "upgrades",
+		Effect: corev1.TaintEffectNoSchedule,
+	}}
+
+	// The instance is scheduled some other way.
+	sts := &appsv1.StatefulSet{}
+	sts.Spec.Template.Spec = corev1.PodSpec{
+		Containers: []corev1.Container{{Name: ContainerDatabase}},
+		Affinity:   &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{}},
+		Tolerations: []corev1.Toleration{{
+			Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "postgres",
+		}},
+		PriorityClassName: "database",
+	}
+
+	for name, pod := range map[string]corev1.PodSpec{
+		"Upgrade":    reconciler.generateUpgradeJob(ctx, upgrade, sts).Spec.Template.Spec,
+		"RemoveData": reconciler.generateRemoveDataJob(ctx, upgrade, sts).Spec.Template.Spec,
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.DeepEqual(t, pod.Affinity, upgrade.Spec.Affinity)
+			assert.Equal(t, pod.PriorityClassName, "maintenance")
+			assert.DeepEqual(t, pod.Tolerations, upgrade.Spec.Tolerations)
+			assert.Equal(t, len(pod.Containers), 1)
+			assert.DeepEqual(t, pod.Containers[0].Resources, upgrade.Spec.Resources)
+		})
+	}
+}
+
+func TestGeneratePreflightJob(t *testing.T) {
+	ctx := context.Background()
+	reconciler := &PGUpgradeReconciler{}
+
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.Namespace = "ns1"
+	upgrade.Name = "pgu2"
+	upgrade.UID = "uid3"
+	upgrade.Spec.Image = initialize.Pointer("img4")
+	upgrade.Spec.PostgresClusterName = "pg5"
+	upgrade.Spec.FromPostgresVersion = 19
+	upgrade.Spec.ToPostgresVersion = 25
+
+	instance := &appsv1.StatefulSet{}
+	instance.Spec.Template.Spec = corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name: ContainerDatabase,
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: "cert-volume", MountPath: "/pgconf/tls"},
+				{Name: "postgres-data", MountPath: "/pgdata"},
+				{Name: "postgres-wal", MountPath: "/pgwal"},
+			},
+		}},
+		Volumes: []corev1.Volume{
+			{
+				Name: "cert-volume",
+				VolumeSource: corev1.VolumeSource{
+					Projected: new(corev1.ProjectedVolumeSource),
+				},
+			},
+			{
+				Name: "postgres-data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: "pg5-abcd-pgdata",
+					},
+				},
+			},
+			{
+				Name: "postgres-wal",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: "pg5-abcd-pgwal",
+					},
+				},
+			},
+		},
+	}
+
+	job := reconciler.generatePreflightJob(ctx, upgrade, instance, "pg5-primary")
+	assert.Equal(t, job.Name, "pgu2-preflight")
+	assert.Equal(t, job.Namespace, "ns1")
+	assert.Equal(t, job.Labels[LabelRole], "preflight")
+	assert.Equal(t, job.Labels[LabelPGUpgrade], "pgu2")
+	assert.Equal(t, *job.Spec.BackoffLimit, int32(0))
+
+	// The instance StatefulSet is not changed.
+	assert.Equal(t, len(instance.Spec.Template.Spec.Volumes), 3)
+
+	// Only certificates are mounted from the instance; its data is replaced.
+	assert.Assert(t, marshalMatches(job.Spec.Template.Spec.Volumes, `
+- name: cert-volume
+  projected:
+    sources: null
+- emptyDir: {}
+  name: postgres-data
+	`))
+
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, container.Image, "img4")
+	assert.Assert(t, marshalMatches(container.VolumeMounts, `
+- mountPath: /pgconf/tls
+  name: cert-volume
+- mountPath: /pgdata
+  name: postgres-data
+	`))
+	assert.DeepEqual(t, container.Command[4:], []string{"preflight", "19", "25", "pg5-primary"})
+
+	script := container.Command[3]
+	assert.Assert(t, cmp.Contains(script, `pg_basebackup`))
+	assert.Assert(t, cmp.Contains(script, `user=_crunchyrepl sslmode=verify-ca`))
+	assert.Assert(t, cmp.Contains(script, `--link --check`))
+	assert.Assert(t, cmp.Contains(script, `archive_mode = 'off'`))
+	assert.Assert(t, cmp.Contains(script, `/dev/termination-log`))
+	assert.Assert(t, !strings.Contains(script, `--tablespace-mapping`))
+
+	t.Run("Tablespaces", func(t *testing.T) {
+		instance := instance.DeepCopy()
+		instance.Spec.Template.Spec.Volumes = append(instance.Spec.Template.Spec.Volumes,
+			corev1.Volume{
+				Name: "tablespace-books",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: "pg5-abcd-books-tablespace",
+					},
+				},
+			})
+		instance.Spec.Template.Spec.Containers[0].VolumeMounts = append(
+			instance.Spec.Template.Spec.Containers[0].VolumeMounts,
+			corev1.VolumeMount{Name: "tablespace-books", MountPath: "/tablespaces/books"})
+
+		job := reconciler.generatePreflightJob(ctx, upgrade, instance, "pg5-primary")
+
+		// The tablespace volume is in use, so it is not mounted.
+		assert.Equal(t, len(job.Spec.Template.Spec.Volumes), 2)
+		assert.Equal(t, len(job.Spec.Template.Spec.Containers[0].VolumeMounts), 2)
+
+		// The tablespace is copied into the emptyDir instead.
+		script := job.Spec.Template.Spec.Containers[0].Command[3]
+		assert.Assert(t, cmp.Contains(script,
+			`--dbname="${conninfo}" --tablespace-mapping=/tablespaces/books/data=/pgdata/tablespaces/books/data`+"\n"))
+	})
+}
+
+func TestFailedChecks(t *testing.T) {
+	assert.Assert(t, failedChecks(nil) == nil)
+
+	terminated := func(code int32, finished time.Time, message string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name: ContainerDatabase,
+			State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{
+					ExitCode:   code,
+					FinishedAt: metav1.NewTime(finished),
+					Message:    message,
+				},
+			},
+		}}
+		return pod
+	}
+
+	now := time.Now()
+	older := terminated(1, now.Add(-time.Hour),
+		"Checking for reg* data types in user tables\n")
+	newer := terminated(1, now,
+		"Checking for presence of required libraries\n"+
+			"  Checking for incompatible polymorphic functions \n\n")
+	success := terminated(0, now.Add(time.Hour), "")
+
+	assert.DeepEqual(t,
+		failedChecks([]*corev1.Pod{newer, older, success}),
+		[]string{
+			"Checking for presence of required libraries",
+			"Checking for incompatible polymorphic functions",
+		})
+
+	// Pods that are still running report nothing.
+	assert.Assert(t, failedChecks([]*corev1.Pod{{}}) == nil)
+}
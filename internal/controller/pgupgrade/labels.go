@@ -15,9 +15,22 @@
 package pgupgrade
 
 import (
+	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
+// These labels follow the domain of the labels in package naming.
+var (
+	labelPrefix           = naming.LabelDomain() + "/"
+	LabelPGUpgrade        = labelPrefix + "pgupgrade"
+	LabelCluster          = labelPrefix + "cluster"
+	LabelRole             = labelPrefix + "role"
+	LabelVersion          = labelPrefix + "version"
+	LabelPatroni          = labelPrefix + "patroni"
+	LabelPGBackRestBackup = labelPrefix + "pgbackrest-backup"
+	LabelInstance         = labelPrefix + "instance"
+)
+
 const (
 	// ConditionPGUpgradeProgressing is the type used in a condition to indicate that
 	// an Postgres major upgrade is in progress.
@@ -27,20 +40,63 @@ const (
 	// status of a Postgres major upgrade.
 	ConditionPGUpgradeSucceeded = "Succeeded"
 
-	labelPrefix           = "postgres-operator.crunchydata.com/"
-	LabelPGUpgrade        = labelPrefix + "pgupgrade"
-	LabelCluster          = labelPrefix + "cluster"
-	LabelRole             = labelPrefix + "role"
-	LabelVersion          = labelPrefix + "version"
-	LabelPatroni          = labelPrefix + "patroni"
-	LabelPGBackRestBackup = labelPrefix + "pgbackrest-backup"
-	LabelInstance         = labelPrefix + "instance"
+	// ConditionPGUpgradePreflight is the type used in a condition to indicate
+	// the result of running `pg_upgrade --check` before the cluster is shut down.
+	ConditionPGUpgradePreflight = "PreflightChecked"
+
+	// ConditionPGUpgradeModules is the type used in a condition to indicate
+	// whether or not the extensions and libraries of the cluster are in the
+	// image of the new version.
+	ConditionPGUpgradeModules = "ModulesScanned"
+
+	// ConditionPGUpgradeExtensionsUpdated is the type used in a condition to
+	// indicate the result of updating extensions after the upgrade.
+	ConditionPGUpgradeExtensionsUpdated = "ExtensionsUpdated"
+
+	// ConditionPGUpgradeBackup is the type used in a condition to indicate the
+	// status of the full backup taken before the upgrade.
+	ConditionPGUpgradeBackup = "PreUpgradeBackup"
+
+	// ConditionPGUpgradeRolledBack is the type used in a condition to indicate
+	// the status of restoring the pre-upgrade backup after the upgrade failed.
+	ConditionPGUpgradeRolledBack = "RolledBack"
+
+	// ConditionPGUpgradeApproved is the type of a condition that someone
+	// allowed to write the status of a PGUpgrade sets to approve adding the
+	// allow-upgrade annotation to its cluster.
+	ConditionPGUpgradeApproved = "Approved"
+
+	// ConditionPGUpgradeInSync is the type used in a condition to indicate
+	// whether or not the cluster created by the LogicalReplication method has
+	// copied every table and is applying changes as they happen.
+	ConditionPGUpgradeInSync = "LogicalReplicationInSync"
+
+	// ConditionPostUpgradeBackup is the type of the PostgresCluster condition that
+	// indicates whether or not a full backup has been taken since a major upgrade.
+	// It matches the one in package postgrescluster.
+	ConditionPostUpgradeBackup = "PGBackRestPostUpgradeBackup"
 
 	ReplicaCreate     = "replica-create"
 	ContainerDatabase = "database"
 
+	// MethodLogicalReplication is the method of a PGUpgrade that copies data
+	// to a new cluster using logical replication.
+	MethodLogicalReplication = "LogicalReplication"
+
+	// PatroniDCSEndpoints and PatroniDCSConfigMaps are the kinds of Kubernetes
+	// objects in which Patroni can store the state of a cluster.
+	PatroniDCSEndpoints  = "endpoints"
+	PatroniDCSConfigMaps = "configmaps"
+
+	// patroniConfigKey is the key of the cluster-wide Patroni configuration in
+	// the cluster ConfigMap.
+	patroniConfigKey = "patroni.yaml"
+
 	pgUpgrade  = "pgupgrade"
+	preflight  = "preflight"
+	scan       = "scan"
 	removeData = "removedata"
+	debugWorld = "debug-world"
 )
 
 func commonLabels(role string, upgrade *v1beta1.PGUpgrade) map[string]string {
@@ -0,0 +1,778 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/internal/util"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// logicalReplicationName is the name of the PostgreSQL user, publications,
+// and subscriptions of the LogicalReplication method. Replication slots in the
+// old cluster start with this name.
+const logicalReplicationName = "_crunchyupgrade"
+
+// logicalDatabasesSQL returns the names of databases in the old cluster that
+// are copied to the new cluster.
+const logicalDatabasesSQL = `SELECT datname FROM pg_catalog.pg_database` +
+	` WHERE datallowconn AND NOT datistemplate`
+
+// logicalClusterName returns the name of the PostgresCluster that upgrade
+// creates at its target version. The name does not change once it is created.
+func logicalClusterName(upgrade *v1beta1.PGUpgrade) string {
+	if status := upgrade.Status.LogicalReplication; status != nil {
+		return status.ClusterName
+	}
+	if spec := upgrade.Spec.LogicalReplication; spec != nil && spec.ClusterName != "" {
+		return spec.ClusterName
+	}
+	return fmt.Sprintf("%s-%d", upgrade.Spec.PostgresClusterName, upgrade.Spec.ToPostgresVersion)
+}
+
+// logicalReplicationSecret returns the Secret with the password of the
+// PostgreSQL user that the new cluster uses to read from the old one.
+func logicalReplicationSecret(upgrade *v1beta1.PGUpgrade) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: upgrade.Namespace,
+			Name:      upgrade.Name + "-logical-replication",
+		},
+	}
+}
+
+// logicalConnectionString returns the libpq connection string of database in
+// source without its password. The new cluster verifies the certificate of
+// source using the authority that issued its own certificates.
+// - https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNSTRING
+func logicalConnectionString(source *v1beta1.PostgresCluster, database string) string {
+	port := int32(5432)
+	if source.Spec.Port != nil {
+		port = *source.Spec.Port
+	}
+	primary := naming.ClusterPrimaryService(source)
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace
+
+	return fmt.Sprintf(
+		"host=%s.%s.svc port=%d user=%s sslmode=verify-full sslrootcert=%s dbname='%s'",
+		primary.Name, primary.Namespace, port, logicalReplicationName,
+		naming.CertMountPath+"/ca.crt", quote(database))
+}
+
+// generateLogicalCluster returns the PostgresCluster that replaces source at
+// the target version of upgrade. It is a copy of source that starts empty,
+// accepts writes, and keeps its backups apart from those of source.
+func generateLogicalCluster(
+	upgrade *v1beta1.PGUpgrade, source *v1beta1.PostgresCluster,
+) *v1beta1.PostgresCluster {
+	cluster := v1beta1.NewPostgresCluster()
+	cluster.Namespace = source.Namespace
+	cluster.Name = logicalClusterName(upgrade)
+	cluster.Labels = map[string]string{LabelPGUpgrade: upgrade.Name}
+	source.Spec.DeepCopyInto(&cluster.Spec)
+
+	spec := &cluster.Spec
+	spec.PostgresVersion = upgrade.Spec.ToPostgresVersion
+	spec.Image = upgrade.Spec.ToPostgresImage
+
+	// The schema and data come from source through logical replication.
+	spec.DataSource = nil
+	spec.DatabaseInitSQL = nil
+	spec.Backups.PGBackRest.Restore = nil
+
+	spec.Paused = nil
+	spec.ReadOnly = nil
+	spec.RemoteInstanceSets = nil
+	spec.Shutdown = nil
+	spec.Standby = nil
+
+	// Each node port can be allocated to only one Service.
+	for _, service := range []*v1beta1.ServiceSpec{spec.Service, spec.ReplicaService} {
+		if service != nil {
+			service.NodePort = nil
+		}
+	}
+	if spec.Proxy != nil && spec.Proxy.PGBouncer != nil && spec.Proxy.PGBouncer.Service != nil {
+		spec.Proxy.PGBouncer.Service.NodePort = nil
+	}
+	if spec.UserInterface != nil && spec.UserInterface.PGAdmin != nil &&
+		spec.UserInterface.PGAdmin.Service != nil {
+		spec.UserInterface.PGAdmin.Service.NodePort = nil
+	}
+
+	// Repositories in cloud storage would be shared with source. Move them to
+	// a directory named after the new cluster. Those on volumes are separate.
+	global := spec.Backups.PGBackRest.Global
+	for i := range spec.Backups.PGBackRest.Repos {
+		repo := &spec.Backups.PGBackRest.Repos[i]
+		if repo.Volume != nil || repo.SharedHost != nil {
+			continue
+		}
+
+		key := repo.Name + "-path"
+		location, ok := global[key]
+		if !ok {
+			location = repo.Path
+		}
+		if location == "" {
+			location = "/pgbackrest/" + repo.Name
+		}
+		location = path.Join(path.Dir(location), cluster.Name, path.Base(location))
+
+		if ok {
+			global[key] = location
+		} else {
+			repo.Path = location
+		}
+	}
+
+	return cluster
+}
+
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={get,list}
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={create,patch}
+
+// reconcileLogicalReplicationSecret writes the Secret with the password of
+// the PostgreSQL user that the new cluster uses to read from source. The
+// password has only letters and digits so it needs no quoting.
+func (r *PGUpgradeReconciler) reconcileLogicalReplicationSecret(
+	ctx context.Context, upgrade *v1beta1.PGUpgrade, source *v1beta1.PostgresCluster,
+) (*corev1.Secret, error) {
+	existing := logicalReplicationSecret(upgrade)
+	err := errors.WithStack(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing))
+	if client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+
+	intent := logicalReplicationSecret(upgrade)
+	intent.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+	intent.Labels = map[string]string{LabelPGUpgrade: upgrade.Name}
+
+	intent.Data = make(map[string][]byte)
+	intent.Data["password"] = existing.Data["password"]
+	intent.Data["verifier"] = existing.Data["verifier"]
+
+	if len(intent.Data["password"]) == 0 {
+		password, err := util.GenerateAlphaNumericPassword(util.DefaultGeneratedPasswordLength)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		intent.Data["password"] = []byte(password)
+		intent.Data["verifier"] = nil
+	}
+
+	if len(intent.Data["verifier"]) == 0 ||
+		!postgres.PasswordVerifierMatches(source, string(intent.Data["verifier"])) {
+		verifier, err := postgres.NewPasswordVerifier(source,
+			logicalReplicationName, string(intent.Data["password"]))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		intent.Data["verifier"] = []byte(verifier)
+	}
+
+	r.setControllerReference(upgrade, intent)
+	err = errors.WithStack(r.apply(ctx, intent))
+	if err == nil {
+		return intent, nil
+	}
+	return nil, err
+}
+
+// copyUserSecrets creates Secrets for target with the passwords of the
+// PostgreSQL users of source. The PostgresCluster controller keeps the
+// passwords it finds in these Secrets. Secrets that exist are left alone.
+func (r *PGUpgradeReconciler) copyUserSecrets(
+	ctx context.Context, source, target *v1beta1.PostgresCluster,
+) error {
+	secrets := &corev1.SecretList{}
+	selector, err := naming.AsSelector(naming.ClusterPostgresUsers(source.Name))
+	if err == nil {
+		err = errors.WithStack(
+			r.Client.List(ctx, secrets,
+				client.InNamespace(source.Namespace),
+				client.MatchingLabelsSelector{Selector: selector},
+			))
+	}
+
+	// Prefer the current Secret of each user over the deprecated default one.
+	deprecated := naming.DeprecatedPostgresUserSecret(source).Name
+	sort.SliceStable(secrets.Items, func(i, j int) bool {
+		return secrets.Items[j].Name == deprecated && secrets.Items[i].Name != deprecated
+	})
+
+	for i := 0; err == nil && i < len(secrets.Items); i++ {
+		username := secrets.Items[i].Labels[naming.LabelPostgresUser]
+
+		secret := &corev1.Secret{ObjectMeta: naming.PostgresUserSecret(target, username)}
+		secret.Labels = map[string]string{
+			naming.LabelCluster:      target.Name,
+			naming.LabelPostgresUser: username,
+			naming.LabelRole:         naming.RolePostgresUser,
+		}
+		secret.Data = map[string][]byte{
+			"password": secrets.Items[i].Data["password"],
+			"verifier": secrets.Items[i].Data["verifier"],
+		}
+
+		err = errors.WithStack(r.Client.Create(ctx, secret))
+		if apierrors.IsAlreadyExists(err) {
+			err = nil
+		}
+	}
+	return err
+}
+
+// executor returns a [postgres.Executor] for the database container of pod.
+func (r *PGUpgradeReconciler) executor(pod *corev1.Pod) postgres.Executor {
+	return func(
+		_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		return r.PodExec(pod.Namespace, pod.Name, ContainerDatabase,
+			stdin, stdout, stderr, command...)
+	}
+}
+
+// logicalReplicationDatabases returns the sorted names of databases in the old
+// cluster that are copied to the new cluster.
+func logicalReplicationDatabases(ctx context.Context, exec postgres.Executor) ([]string, error) {
+	stdout, _, err := exec.Exec(ctx, strings.NewReader(`
+\pset format unaligned
+\pset tuples_only on
+`+logicalDatabasesSQL+`;`), map[string]string{
+		"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+		"QUIET":         "on", // Do not print successful statements to stdout.
+	})
+
+	databases := sets.NewString()
+	for _, line := range strings.Split(stdout, "\n") {
+		if line != "" {
+			databases.Insert(line)
+		}
+	}
+	return databases.List(), errors.WithStack(err)
+}
+
+// logicalReplicationIncompatibilities returns the tables in the old cluster
+// that cannot be replicated. PostgreSQL refuses to UPDATE or DELETE rows of a
+// published table that has no replica identity.
+// - https://www.postgresql.org/docs/current/logical-replication-publication.html
+func logicalReplicationIncompatibilities(
+	ctx context.Context, exec postgres.Executor,
+) ([]string, error) {
+	stdout, stderr, err := exec.ExecInDatabasesFromQuery(ctx, logicalDatabasesSQL, `
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+
+SELECT pg_catalog.format('database %I: table %I.%I has no primary key or replica identity',
+       pg_catalog.current_database(), n.nspname, c.relname)
+  FROM pg_catalog.pg_class c
+  JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+ WHERE c.relkind = 'r'
+   AND n.nspname NOT IN ('information_schema', 'pg_catalog', 'pg_toast')
+   AND (c.relreplident = 'n' OR (c.relreplident = 'd' AND NOT EXISTS (
+        SELECT 1 FROM pg_catalog.pg_index i WHERE i.indrelid = c.oid AND i.indisprimary)))
+ ORDER BY 1;
+`, map[string]string{
+		"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+		"QUIET":         "on", // Do not print successful statements to stdout.
+	})
+
+	logging.FromContext(ctx).V(1).Info("checked replica identities",
+		"stdout", stdout, "stderr", stderr)
+
+	var problems []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if line != "" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, errors.WithStack(err)
+}
+
+// preparePublisher creates the PostgreSQL user that the new cluster connects
+// as and publishes every table in every database of the old cluster.
+func preparePublisher(ctx context.Context, exec postgres.Executor, verifier string) error {
+	variables := map[string]string{
+		"name":     logicalReplicationName,
+		"verifier": verifier,
+
+		"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+		"QUIET":         "on", // Do not print successful statements to stdout.
+	}
+
+	// The user copies tables and reads changes from replication slots.
+	stdout, stderr, err := exec.Exec(ctx, strings.NewReader(`
+SET search_path TO '';
+SELECT pg_catalog.format('CREATE ROLE %I', :'name')
+ WHERE NOT EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = :'name')
+\gexec
+ALTER ROLE :"name" LOGIN REPLICATION SUPERUSER PASSWORD :'verifier';
+`), variables)
+
+	if err == nil {
+		var more string
+		more, stderr, err = exec.ExecInDatabasesFromQuery(ctx, logicalDatabasesSQL, `
+SET search_path TO '';
+SELECT pg_catalog.format('CREATE PUBLICATION %I FOR ALL TABLES', :'name')
+ WHERE NOT EXISTS (SELECT 1 FROM pg_catalog.pg_publication WHERE pubname = :'name')
+\gexec
+`, variables)
+		stdout += more
+	}
+
+	logging.FromContext(ctx).V(1).Info("prepared publisher",
+		"stdout", stdout, "stderr", stderr)
+
+	return errors.WithStack(err)
+}
+
+// logicalSubscribeScript copies roles and the schema of one database from the
+// old cluster then subscribes to changes in it. It reads the password of the
+// old cluster from stdin. Objects that already exist are reported and skipped.
+const logicalSubscribeScript = `
+IFS= read -r PGPASSWORD && export PGPASSWORD
+set -o pipefail
+
+pg_dumpall --roles-only --no-role-passwords --dbname="$1" | psql -Xqw --dbname=postgres
+pg_dump --schema-only --create --dbname="$1" | psql -Xqw --dbname=postgres
+
+PGDATABASE="$2" psql -Xqw --set=ON_ERROR_STOP=on --set=conninfo="$1" --set=name="$3" --file=- <<< "$4"
+`
+
+// logicalSubscribeSQL creates a subscription with a replication slot that is
+// unique to the database. psql reads the password from the environment so
+// that it does not appear in the arguments of any process.
+const logicalSubscribeSQL = `
+SET search_path TO '';
+\set password ` + "`printf '%s' \"${PGPASSWORD}\"`" + `
+SELECT pg_catalog.format(
+         'CREATE SUBSCRIPTION %I CONNECTION %L PUBLICATION %I WITH (slot_name = %L)',
+         :'name', :'conninfo' || ' password=' || :'password', :'name', :'name' || '_' || d.oid)
+  FROM pg_catalog.pg_database d
+ WHERE d.datname = pg_catalog.current_database()
+   AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_subscription s
+                    WHERE s.subdbid = d.oid AND s.subname = :'name')
+\gexec
+`
+
+// subscribe copies the schema of database from source into the new cluster
+// and subscribes to its changes. The new cluster copies the existing rows of
+// each table before it applies changes.
+func subscribe(
+	ctx context.Context, exec postgres.Executor,
+	source *v1beta1.PostgresCluster, database, password string,
+) error {
+	var stdout, stderr strings.Builder
+	err := exec(ctx, strings.NewReader(password+"\n"), &stdout, &stderr,
+		"bash", "-ceu", "--", logicalSubscribeScript, "-",
+		logicalConnectionString(source, database), database,
+		logicalReplicationName, logicalSubscribeSQL)
+
+	logging.FromContext(ctx).V(1).Info("subscribed",
+		"database", database, "stdout", stdout.String(), "stderr", stderr.String())
+
+	return errors.WithStack(err)
+}
+
+// tablesSyncing returns the number of tables in the new cluster that are still
+// copying their existing rows.
+func tablesSyncing(ctx context.Context, exec postgres.Executor) (int32, error) {
+	stdout, _, err := exec.ExecInDatabasesFromQuery(ctx, `
+SELECT d.datname FROM pg_catalog.pg_database d
+  JOIN pg_catalog.pg_subscription s ON s.subdbid = d.oid
+ WHERE s.subname = :'name'`, `
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+
+SELECT pg_catalog.count(*)
+  FROM pg_catalog.pg_subscription_rel r
+  JOIN pg_catalog.pg_subscription s ON s.oid = r.srsubid
+ WHERE s.subname = :'name' AND r.srsubstate <> 'r';
+`, map[string]string{
+		"name": logicalReplicationName,
+
+		"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+		"QUIET":         "on", // Do not print successful statements to stdout.
+	})
+
+	var total int32
+	for _, line := range strings.Split(stdout, "\n") {
+		if n, _ := strconv.ParseInt(strings.TrimSpace(line), 10, 32); n > 0 {
+			total += int32(n)
+		}
+	}
+	return total, errors.WithStack(err)
+}
+
+// replicationLag returns the number of bytes of WAL in the old cluster that
+// the new cluster has yet to confirm and whether the old cluster refuses
+// writes. The lag is nil when there are no replication slots.
+func replicationLag(ctx context.Context, exec postgres.Executor) (*int64, bool, error) {
+	stdout, _, err := exec.Exec(ctx, strings.NewReader(`
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+
+SELECT pg_catalog.max(pg_catalog.pg_wal_lsn_diff(
+         pg_catalog.pg_current_wal_lsn(), confirmed_flush_lsn))::bigint,
+       pg_catalog.current_setting('default_transaction_read_only')
+  FROM pg_catalog.pg_replication_slots
+ WHERE pg_catalog.left(slot_name, pg_catalog.length(:'name') + 1) = :'name' || '_';
+`), map[string]string{
+		"name": logicalReplicationName,
+
+		"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+		"QUIET":         "on", // Do not print successful statements to stdout.
+	})
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+
+	var lag *int64
+	fields := strings.Split(strings.TrimSpace(stdout), "|")
+	if n, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+		lag = &n
+	}
+	return lag, len(fields) > 1 && fields[1] == "on", nil
+}
+
+// logicalCutoverScript copies the values of sequences in one database of the
+// old cluster then stops replicating it. It reads the password of the old
+// cluster from stdin.
+const logicalCutoverScript = `
+IFS= read -r PGPASSWORD && export PGPASSWORD
+set -o pipefail
+
+psql -XAtqw --dbname="$1" --file=- <<< "$4" |
+  PGDATABASE="$2" psql -Xqw --set=ON_ERROR_STOP=on --file=-
+PGDATABASE="$2" psql -Xqw --set=ON_ERROR_STOP=on --set=name="$3" --file=- <<< "$5"
+`
+
+// cutover stops replicating from the old cluster once the new cluster has
+// every change. Logical replication does not copy the values of sequences,
+// so those are copied first. Dropping each subscription drops its replication
+// slot in the old cluster. Then the user and publications are dropped there.
+func cutover(
+	ctx context.Context, target, publisher postgres.Executor,
+	source *v1beta1.PostgresCluster, databases []string, password string,
+) error {
+	var err error
+	for _, database := range databases {
+		if err != nil {
+			break
+		}
+
+		var stdout, stderr strings.Builder
+		err = target(ctx, strings.NewReader(password+"\n"), &stdout, &stderr,
+			"bash", "-ceu", "--", logicalCutoverScript, "-",
+			logicalConnectionString(source, database), database,
+			logicalReplicationName, `
+SET search_path TO '';
+SELECT pg_catalog.format('SELECT pg_catalog.setval(%L, %s);',
+       pg_catalog.format('%I.%I', schemaname, sequencename), last_value)
+  FROM pg_catalog.pg_sequences WHERE last_value IS NOT NULL;
+`, `DROP SUBSCRIPTION IF EXISTS :"name";`)
+
+		logging.FromContext(ctx).V(1).Info("cut over",
+			"database", database, "stdout", stdout.String(), "stderr", stderr.String())
+		err = errors.WithStack(err)
+	}
+
+	variables := map[string]string{
+		"name": logicalReplicationName,
+
+		"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+		"QUIET":         "on", // Do not print successful statements to stdout.
+	}
+
+	// The user was copied along with the other roles.
+	if err == nil {
+		_, _, err = target.Exec(ctx, strings.NewReader(
+			`DROP ROLE IF EXISTS :"name";`), variables)
+		err = errors.WithStack(err)
+	}
+
+	// The old cluster refuses writes by default, so allow them here.
+	if err == nil {
+		_, _, err = publisher.ExecInDatabasesFromQuery(ctx, logicalDatabasesSQL, `
+SET default_transaction_read_only TO off;
+DROP PUBLICATION IF EXISTS :"name";
+`, variables)
+		err = errors.WithStack(err)
+	}
+	if err == nil {
+		_, _, err = publisher.Exec(ctx, strings.NewReader(`
+SET default_transaction_read_only TO off;
+DROP ROLE IF EXISTS :"name";
+`), variables)
+		err = errors.WithStack(err)
+	}
+	return err
+}
+
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={create,patch}
+
+// reconcileLogicalReplication upgrades the cluster of upgrade without shutting
+// it down. It creates a new cluster at the target version that subscribes to
+// every database of the old cluster. Once the new cluster is in sync and the
+// upgrade asks for it, the old cluster refuses writes until the new cluster
+// has every change. Then replication stops and clients move to the new cluster.
+func (r *PGUpgradeReconciler) reconcileLogicalReplication(
+	ctx context.Context, upgrade *v1beta1.PGUpgrade, world *World,
+) (ctrl.Result, error) {
+	source := world.Cluster
+	progressing := func(status metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+			ObservedGeneration: upgrade.Generation,
+			Type:               ConditionPGUpgradeProgressing,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+
+	// The old cluster must allow this upgrade, like the PGUpgrade method.
+	if allowed, err := r.reconcileAllowUpgrade(ctx, upgrade, source); !allowed {
+		return ctrl.Result{}, err
+	}
+	setStatusToProgressingIfReasonWas("PGClusterMissingRequiredAnnotation", upgrade)
+	setStatusToProgressingIfReasonWas("PGUpgradeNotApproved", upgrade)
+
+	// The new cluster trusts only the authority that issues certificates for
+	// clusters in this namespace.
+	if source.Spec.PostgresVersion != upgrade.Spec.FromPostgresVersion ||
+		source.Spec.CustomTLSSecret != nil {
+		message := fmt.Sprintf("Current postgres version is %d, but upgrade expected %d",
+			source.Spec.PostgresVersion, upgrade.Spec.FromPostgresVersion)
+		if source.Spec.CustomTLSSecret != nil {
+			message = fmt.Sprintf(
+				"PostgresCluster %s has a custom TLS certificate that cannot be replicated from",
+				source.Name)
+		}
+		progressing(metav1.ConditionFalse, "PGUpgradeInvalidForCluster", message)
+		return ctrl.Result{}, nil
+	}
+	setStatusToProgressingIfReasonWas("PGUpgradeInvalidForCluster", upgrade)
+
+	if world.ClusterShutdown || world.ClusterLeaderPod == nil {
+		progressing(metav1.ConditionFalse, "PGClusterNotRunning",
+			fmt.Sprintf("PostgresCluster %s must be running to replicate it",
+				source.Name))
+		return ctrl.Result{}, nil
+	}
+	setStatusToProgressingIfReasonWas("PGClusterNotRunning", upgrade)
+
+	target := world.LogicalCluster
+	if target != nil && target.GetLabels()[LabelPGUpgrade] != upgrade.Name {
+		progressing(metav1.ConditionFalse, "PGClusterAlreadyExists",
+			fmt.Sprintf("PostgresCluster %s already exists; choose another clusterName",
+				target.Name))
+		return ctrl.Result{}, nil
+	}
+	setStatusToProgressingIfReasonWas("PGClusterAlreadyExists", upgrade)
+
+	if upgrade.Status.LogicalReplication == nil {
+		upgrade.Status.LogicalReplication = &v1beta1.PGUpgradeLogicalReplicationStatus{
+			ClusterName: logicalClusterName(upgrade),
+		}
+	}
+	status := upgrade.Status.LogicalReplication
+	publisher := r.executor(world.ClusterLeaderPod)
+
+	secret, err := r.reconcileLogicalReplicationSecret(ctx, upgrade, source)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	password := string(secret.Data["password"])
+
+	// Check and publish the old cluster then create the new cluster.
+	if target == nil {
+		problems, err := logicalReplicationIncompatibilities(ctx, publisher)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		upgrade.Status.Incompatibilities = problems
+
+		if len(problems) > 0 {
+			progressing(metav1.ConditionFalse, "PGUpgradeIncompatible",
+				fmt.Sprintf("Found %d tables that cannot be replicated; "+
+					"add a primary key or replica identity to each", len(problems)))
+			return ctrl.Result{RequeueAfter: time.Minute}, nil
+		}
+		setStatusToProgressingIfReasonWas("PGUpgradeIncompatible", upgrade)
+
+		err = preparePublisher(ctx, publisher, string(secret.Data["verifier"]))
+
+		target = generateLogicalCluster(upgrade, source)
+		if err == nil {
+			err = r.copyUserSecrets(ctx, source, target)
+		}
+		if err == nil {
+			err = errors.WithStack(r.Client.Create(ctx, target))
+		}
+		if apierrors.IsAlreadyExists(err) {
+			err = nil
+		}
+		if err == nil {
+			progressing(metav1.ConditionTrue, "PGUpgradeLogicalClusterPending",
+				fmt.Sprintf("Waiting for PostgresCluster %s to start", target.Name))
+		}
+		return ctrl.Result{}, err
+	}
+
+	// Changes to the new cluster are watched.
+	if world.LogicalLeaderPod == nil {
+		progressing(metav1.ConditionTrue, "PGUpgradeLogicalClusterPending",
+			fmt.Sprintf("Waiting for PostgresCluster %s to start", target.Name))
+		return ctrl.Result{}, nil
+	}
+	subscriber := r.executor(world.LogicalLeaderPod)
+
+	// Once the cutover starts, the old cluster may have no replication slots.
+	// The condition remembers that it started.
+	synced := meta.FindStatusCondition(upgrade.Status.Conditions, ConditionPGUpgradeInSync)
+	cuttingOver := synced != nil && synced.Reason == "PGUpgradeCutover"
+
+	if !cuttingOver {
+		// Subscribe to databases as they appear in the old cluster.
+		databases, err := logicalReplicationDatabases(ctx, publisher)
+		subscribed := sets.NewString(status.Databases...)
+		for _, database := range databases {
+			if err == nil && !subscribed.Has(database) {
+				err = subscribe(ctx, subscriber, source, database, password)
+				if err == nil {
+					subscribed.Insert(database)
+				}
+			}
+		}
+		status.Databases = subscribed.List()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		syncing, err := tablesSyncing(ctx, subscriber)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		lag, readOnly, err := replicationLag(ctx, publisher)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		status.TablesSyncing, status.LagBytes = &syncing, lag
+
+		condition := metav1.Condition{
+			ObservedGeneration: upgrade.Generation,
+			Type:               ConditionPGUpgradeInSync,
+			Status:             metav1.ConditionTrue,
+			Reason:             "PGUpgradeInSync",
+			Message: fmt.Sprintf("PostgresCluster %s is applying changes from %s",
+				target.Name, source.Name),
+		}
+		if syncing > 0 || lag == nil {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "PGUpgradeCopyingTables"
+			condition.Message = fmt.Sprintf("PostgresCluster %s is copying %d tables",
+				target.Name, syncing)
+		}
+		meta.SetStatusCondition(&upgrade.Status.Conditions, condition)
+
+		// Replication progress is not visible in Kubernetes, so check again later.
+		if upgrade.Spec.LogicalReplication == nil || !upgrade.Spec.LogicalReplication.Cutover ||
+			condition.Status != metav1.ConditionTrue {
+			progressing(metav1.ConditionTrue, "PGUpgradeReplicating",
+				fmt.Sprintf("Replicating PostgresCluster %s to %s",
+					source.Name, target.Name))
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+		}
+
+		// Stop writes to the old cluster and wait for the new cluster to
+		// confirm all of its changes.
+		if !postgres.ReadOnly(source) {
+			patch := source.DeepCopy()
+			annotations := patch.GetAnnotations()
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations[naming.ReadOnly] = "true"
+			patch.SetAnnotations(annotations)
+
+			err = errors.WithStack(r.Patch(ctx, patch, client.MergeFromWithOptions(
+				source, client.MergeFromWithOptimisticLock{}), r.Owner))
+		}
+		if err != nil || !readOnly || *lag != 0 {
+			progressing(metav1.ConditionTrue, "PGUpgradeCatchingUp",
+				fmt.Sprintf("PostgresCluster %s refuses writes until %s applies %d bytes of changes",
+					source.Name, target.Name, *lag))
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+		}
+
+		meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+			ObservedGeneration: upgrade.Generation,
+			Type:               ConditionPGUpgradeInSync,
+			Status:             metav1.ConditionTrue,
+			Reason:             "PGUpgradeCutover",
+			Message: fmt.Sprintf("PostgresCluster %s has every change from %s",
+				target.Name, source.Name),
+		})
+	}
+
+	progressing(metav1.ConditionTrue, "PGUpgradeCuttingOver",
+		fmt.Sprintf("Copying sequences and stopping replication from PostgresCluster %s",
+			source.Name))
+
+	err = cutover(ctx, subscriber, publisher, source, status.Databases, password)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status.TablesSyncing, status.LagBytes = nil, nil
+	progressing(metav1.ConditionFalse, "PGUpgradeCompleted",
+		fmt.Sprintf("PostgresCluster %s is running version %d",
+			target.Name, upgrade.Spec.ToPostgresVersion))
+	meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+		ObservedGeneration: upgrade.Generation,
+		Type:               ConditionPGUpgradeSucceeded,
+		Status:             metav1.ConditionTrue,
+		Reason:             "PGUpgradeSucceeded",
+		Message: fmt.Sprintf(
+			"PostgresCluster %s replaces %s; connect clients to %s then delete %s",
+			target.Name, source.Name, target.Name, source.Name),
+	})
+	return ctrl.Result{}, nil
+}
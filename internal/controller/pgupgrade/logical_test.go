@@ -0,0 +1,363 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestLogicalClusterName(t *testing.T) {
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.Spec.PostgresClusterName = "hippo"
+	upgrade.Spec.ToPostgresVersion = 15
+	assert.Equal(t, logicalClusterName(upgrade), "hippo-15")
+
+	upgrade.Spec.LogicalReplication = &v1beta1.PGUpgradeLogicalReplicationSpec{ClusterName: "rhino"}
+	assert.Equal(t, logicalClusterName(upgrade), "rhino")
+
+	upgrade.Status.LogicalReplication = &v1beta1.PGUpgradeLogicalReplicationStatus{ClusterName: "first"}
+	assert.Equal(t, logicalClusterName(upgrade), "first", "expected the created name")
+}
+
+func TestLogicalConnectionString(t *testing.T) {
+	source := v1beta1.NewPostgresCluster()
+	source.Namespace, source.Name = "ns1", "hippo"
+	assert.Equal(t, logicalConnectionString(source, `it's\here`), ""+
+		"host=hippo-primary.ns1.svc port=5432 user=_crunchyupgrade sslmode=verify-full"+
+		` sslrootcert=/pgconf/tls/ca.crt dbname='it\'s\\here'`)
+
+	source.Spec.Port = initialize.Int32(6543)
+	assert.Assert(t, strings.Contains(logicalConnectionString(source, "app"), " port=6543 "))
+}
+
+func TestGenerateLogicalCluster(t *testing.T) {
+	source := v1beta1.NewPostgresCluster()
+	source.Namespace, source.Name = "ns1", "hippo"
+	source.Spec.PostgresVersion = 13
+	source.Spec.Image = "postgres:13"
+	source.Spec.Shutdown = initialize.Bool(false)
+	source.Spec.ReadOnly = initialize.Bool(true)
+	source.Spec.DataSource = &v1beta1.DataSource{}
+	source.Spec.Service = &v1beta1.ServiceSpec{Type: "NodePort", NodePort: initialize.Int32(32000)}
+	source.Spec.Users = []v1beta1.PostgresUserSpec{{Name: "app"}}
+	source.Spec.Backups.PGBackRest.Global = map[string]string{"repo3-path": "/custom/repo3"}
+	source.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+		{Name: "repo1", Volume: &v1beta1.RepoPVC{}},
+		{Name: "repo2", S3: &v1beta1.RepoS3{Bucket: "b"}},
+		{Name: "repo3", GCS: &v1beta1.RepoGCS{Bucket: "b"}},
+		{Name: "repo4", Azure: &v1beta1.RepoAzure{Container: "c"}, Path: "/az/hippo"},
+	}
+
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.Name = "up"
+	upgrade.Spec.PostgresClusterName = "hippo"
+	upgrade.Spec.ToPostgresVersion = 15
+	upgrade.Spec.ToPostgresImage = "postgres:15"
+
+	cluster := generateLogicalCluster(upgrade, source)
+	assert.Equal(t, cluster.Namespace, "ns1")
+	assert.Equal(t, cluster.Name, "hippo-15")
+	assert.DeepEqual(t, cluster.Labels, map[string]string{LabelPGUpgrade: "up"})
+	assert.Assert(t, cluster.OwnerReferences == nil, "expected the cluster to outlive the upgrade")
+
+	assert.Equal(t, cluster.Spec.PostgresVersion, 15)
+	assert.Equal(t, cluster.Spec.Image, "postgres:15")
+	assert.Assert(t, cluster.Spec.DataSource == nil)
+	assert.Assert(t, cluster.Spec.ReadOnly == nil)
+	assert.Assert(t, cluster.Spec.Shutdown == nil)
+	assert.Assert(t, cluster.Spec.Service.NodePort == nil)
+	assert.Equal(t, cluster.Spec.Service.Type, "NodePort")
+	assert.DeepEqual(t, cluster.Spec.Users, source.Spec.Users)
+
+	repos := cluster.Spec.Backups.PGBackRest.Repos
+	assert.Equal(t, repos[0].Path, "", "volumes are separate")
+	assert.Equal(t, repos[1].Path, "/pgbackrest/hippo-15/repo2")
+	assert.Equal(t, repos[2].Path, "")
+	assert.Equal(t, cluster.Spec.Backups.PGBackRest.Global["repo3-path"], "/custom/hippo-15/repo3")
+	assert.Equal(t, repos[3].Path, "/az/hippo-15/hippo")
+
+	// The source is not changed.
+	assert.Equal(t, source.Spec.PostgresVersion, 13)
+	assert.Equal(t, *source.Spec.Service.NodePort, int32(32000))
+	assert.Equal(t, source.Spec.Backups.PGBackRest.Global["repo3-path"], "/custom/repo3")
+	assert.Equal(t, source.Spec.Backups.PGBackRest.Repos[1].Path, "")
+}
+
+func TestLogicalReplicationIncompatibilities(t *testing.T) {
+	ctx := context.Background()
+
+	var script string
+	exec := func(
+		_ context.Context, stdin io.Reader, stdout, _ io.Writer, command ...string,
+	) error {
+		b, err := io.ReadAll(stdin)
+		assert.NilError(t, err)
+		script = string(b)
+
+		assert.Equal(t, command[0], "bash")
+		assert.Assert(t, strings.Contains(strings.Join(command, " "), "NOT datistemplate"))
+		_, _ = stdout.Write([]byte("" +
+			"database app: table public.logs has no primary key or replica identity\n" +
+			"\n" +
+			"database zoo: table public.events has no primary key or replica identity\n"))
+		return nil
+	}
+
+	problems, err := logicalReplicationIncompatibilities(ctx, exec)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, problems, []string{
+		"database app: table public.logs has no primary key or replica identity",
+		"database zoo: table public.events has no primary key or replica identity",
+	})
+	assert.Assert(t, strings.Contains(script, "relreplident"))
+}
+
+func TestPreparePublisher(t *testing.T) {
+	ctx := context.Background()
+
+	var calls []string
+	exec := func(
+		_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+	) error {
+		b, err := io.ReadAll(stdin)
+		assert.NilError(t, err)
+		calls = append(calls, string(b))
+
+		assert.Assert(t, strings.Contains(strings.Join(command, " "), "--set=verifier=SCRAM"))
+		return nil
+	}
+
+	assert.NilError(t, preparePublisher(ctx, exec, "SCRAM"))
+	assert.Equal(t, len(calls), 2)
+	assert.Assert(t, strings.Contains(calls[0], `ALTER ROLE :"name" LOGIN REPLICATION SUPERUSER`))
+	assert.Assert(t, strings.Contains(calls[1], `CREATE PUBLICATION %I FOR ALL TABLES`))
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
+	source := v1beta1.NewPostgresCluster()
+	source.Namespace, source.Name = "ns1", "hippo"
+
+	exec := func(
+		_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+	) error {
+		b, err := io.ReadAll(stdin)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), "secret\n", "expected the password on stdin")
+
+		assert.Equal(t, len(command), 9)
+		assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+		assert.Equal(t, command[5], logicalConnectionString(source, "app"))
+		assert.Equal(t, command[6], "app")
+		assert.Equal(t, command[7], "_crunchyupgrade")
+		assert.Assert(t, !strings.Contains(strings.Join(command, " "), "secret"),
+			"expected no password in arguments")
+		return errors.New("boom")
+	}
+
+	assert.ErrorContains(t, subscribe(ctx, exec, source, "app", "secret"), "boom")
+	assert.Assert(t, strings.Contains(logicalSubscribeSQL, "slot_name = %L"))
+	assert.Assert(t, strings.Contains(logicalSubscribeScript, "--schema-only --create"))
+}
+
+func TestReplicationProgress(t *testing.T) {
+	ctx := context.Background()
+	output := func(text string) postgres.Executor {
+		return func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte(text))
+			return nil
+		}
+	}
+
+	syncing, err := tablesSyncing(ctx, output("2\n0\n\n1\n"))
+	assert.NilError(t, err)
+	assert.Equal(t, syncing, int32(3))
+
+	lag, readOnly, err := replicationLag(ctx, output("|off\n"))
+	assert.NilError(t, err)
+	assert.Assert(t, lag == nil, "expected no lag without slots")
+	assert.Assert(t, !readOnly)
+
+	lag, readOnly, err = replicationLag(ctx, output("1024|on\n"))
+	assert.NilError(t, err)
+	assert.Equal(t, *lag, int64(1024))
+	assert.Assert(t, readOnly)
+}
+
+func TestCutover(t *testing.T) {
+	ctx := context.Background()
+	source := v1beta1.NewPostgresCluster()
+	source.Namespace, source.Name = "ns1", "hippo"
+
+	var target, publisher []string
+	record := func(calls *[]string) postgres.Executor {
+		return func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			*calls = append(*calls, strings.Join(command, " ")+"\n"+string(b))
+			return nil
+		}
+	}
+
+	assert.NilError(t, cutover(ctx, record(&target), record(&publisher),
+		source, []string{"app", "zoo"}, "secret"))
+
+	assert.Equal(t, len(target), 3)
+	assert.Assert(t, strings.Contains(target[0], "pg_sequences"))
+	assert.Assert(t, strings.Contains(target[0], `DROP SUBSCRIPTION IF EXISTS :"name";`))
+	assert.Assert(t, strings.HasSuffix(target[1], "\nsecret\n"))
+	assert.Assert(t, strings.Contains(target[2], `DROP ROLE IF EXISTS :"name";`))
+
+	assert.Equal(t, len(publisher), 2)
+	assert.Assert(t, strings.Contains(publisher[0], `DROP PUBLICATION IF EXISTS :"name";`))
+	assert.Assert(t, strings.Contains(publisher[1], `SET default_transaction_read_only TO off;`))
+	assert.Assert(t, strings.Contains(publisher[1], `DROP ROLE IF EXISTS :"name";`))
+}
+
+func TestReconcileLogicalReplication(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	source := v1beta1.NewPostgresCluster()
+	source.Namespace, source.Name = "ns1", "hippo"
+	source.Spec.PostgresVersion = 13
+
+	other := v1beta1.NewPostgresCluster()
+	other.Namespace, other.Name = "ns1", "hippo-15"
+
+	cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(source).Build()
+	reconciler := &PGUpgradeReconciler{Client: cc}
+
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.Namespace, upgrade.Name = "ns1", "up"
+	upgrade.Spec.Method = MethodLogicalReplication
+	upgrade.Spec.PostgresClusterName = "hippo"
+	upgrade.Spec.FromPostgresVersion = 13
+	upgrade.Spec.ToPostgresVersion = 15
+
+	reason := func(t testing.TB, world *World) string {
+		result, err := reconciler.reconcileLogicalReplication(ctx, upgrade, world)
+		assert.NilError(t, err)
+		assert.Assert(t, result.IsZero())
+
+		progressing := meta.FindStatusCondition(upgrade.Status.Conditions, ConditionPGUpgradeProgressing)
+		assert.Assert(t, progressing != nil)
+		assert.Equal(t, progressing.Status, metav1.ConditionFalse)
+		return progressing.Reason
+	}
+
+	t.Run("MissingAnnotation", func(t *testing.T) {
+		assert.Equal(t, reason(t, &World{Cluster: source}), "PGClusterMissingRequiredAnnotation")
+	})
+
+	source.Annotations = map[string]string{AnnotationAllowUpgrade: "up"}
+
+	t.Run("Version", func(t *testing.T) {
+		cluster := source.DeepCopy()
+		cluster.Spec.PostgresVersion = 12
+		assert.Equal(t, reason(t, &World{Cluster: cluster}), "PGUpgradeInvalidForCluster")
+	})
+
+	t.Run("CustomTLS", func(t *testing.T) {
+		cluster := source.DeepCopy()
+		cluster.Spec.CustomTLSSecret = &corev1.SecretProjection{}
+		assert.Equal(t, reason(t, &World{Cluster: cluster}), "PGUpgradeInvalidForCluster")
+	})
+
+	t.Run("NotRunning", func(t *testing.T) {
+		assert.Equal(t, reason(t, &World{Cluster: source}), "PGClusterNotRunning")
+	})
+
+	t.Run("AlreadyExists", func(t *testing.T) {
+		world := &World{Cluster: source, ClusterLeaderPod: &corev1.Pod{}, LogicalCluster: other}
+		assert.Equal(t, reason(t, world), "PGClusterAlreadyExists")
+		assert.Assert(t, upgrade.Status.LogicalReplication == nil)
+
+		// Nothing was created.
+		assert.ErrorContains(t,
+			cc.Get(ctx, client.ObjectKeyFromObject(logicalReplicationSecret(upgrade)), &corev1.Secret{}),
+			"not found")
+	})
+}
+
+func TestCopyUserSecrets(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	source := v1beta1.NewPostgresCluster()
+	source.Namespace, source.Name = "ns1", "hippo"
+	target := v1beta1.NewPostgresCluster()
+	target.Namespace, target.Name = "ns1", "hippo-15"
+
+	userSecret := func(name, user, password string) *corev1.Secret {
+		secret := &corev1.Secret{}
+		secret.Namespace, secret.Name = "ns1", name
+		secret.Labels = map[string]string{
+			naming.LabelCluster:      "hippo",
+			naming.LabelPostgresUser: user,
+		}
+		secret.Data = map[string][]byte{
+			"password": []byte(password), "verifier": []byte("v-" + password),
+			"host": []byte("hippo-primary"),
+		}
+		return secret
+	}
+
+	cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		userSecret("hippo-pguser", "hippo", "old"),
+		userSecret("hippo-pguser-hippo", "hippo", "current"),
+		userSecret("hippo-pguser-app", "app", "apples"),
+	).Build()
+	reconciler := &PGUpgradeReconciler{Client: cc}
+
+	assert.NilError(t, reconciler.copyUserSecrets(ctx, source, target))
+	assert.NilError(t, reconciler.copyUserSecrets(ctx, source, target), "expected no conflicts")
+
+	for user, password := range map[string]string{"hippo": "current", "app": "apples"} {
+		secret := &corev1.Secret{ObjectMeta: naming.PostgresUserSecret(target, user)}
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(secret), secret))
+		assert.DeepEqual(t, secret.Data, map[string][]byte{
+			"password": []byte(password), "verifier": []byte("v-" + password),
+		})
+		assert.DeepEqual(t, secret.Labels, map[string]string{
+			naming.LabelCluster:      "hippo-15",
+			naming.LabelPostgresUser: user,
+			naming.LabelRole:         naming.RolePostgresUser,
+		})
+	}
+}
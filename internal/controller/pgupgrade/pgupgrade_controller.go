@@ -17,6 +17,8 @@ package pgupgrade
 import (
 	"context"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/pkg/errors"
 	batchv1 "k8s.io/api/batch/v1"
@@ -31,11 +33,17 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
-const (
-	AnnotationAllowUpgrade = "postgres-operator.crunchydata.com/allow-upgrade"
+var (
+	AnnotationAllowUpgrade = labelPrefix + "allow-upgrade"
+
+	// AnnotationDebugWorld is the annotation added to a PGUpgrade to have the
+	// controller write what it observed, and the conditions it set as a result,
+	// to a ConfigMap on every reconcile. The value identifies the request.
+	AnnotationDebugWorld = labelPrefix + "debug-world"
 )
 
 // PGUpgradeReconciler reconciles a PGUpgrade object
@@ -44,6 +52,13 @@ type PGUpgradeReconciler struct {
 	Owner  client.FieldOwner
 	Scheme *runtime.Scheme
 
+	// PodExec runs commands in containers of the cluster to update extensions
+	// after the upgrade.
+	PodExec func(
+		namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error
+
 	// For this iteration, we will only be setting conditions rather than
 	// setting conditions and emitting events. That may change in the future,
 	// so we're leaving this EventRecorder here for now.
@@ -102,6 +117,13 @@ func (r *PGUpgradeReconciler) watchPostgresClusters() handler.Funcs {
 				NamespacedName: client.ObjectKeyFromObject(upgrade),
 			})
 		}
+
+		// A cluster created by an upgrade is labeled with its name.
+		if name := cluster.GetLabels()[LabelPGUpgrade]; name != "" {
+			q.Add(ctrl.Request{
+				NamespacedName: client.ObjectKey{Namespace: key.Namespace, Name: name},
+			})
+		}
 	}
 
 	return handler.Funcs{
@@ -126,6 +148,8 @@ func (r *PGUpgradeReconciler) watchPostgresClusters() handler.Funcs {
 //+kubebuilder:rbac:groups="batch",resources="jobs",verbs={list}
 //+kubebuilder:rbac:groups="",resources="endpoints",verbs={get}
 //+kubebuilder:rbac:groups="",resources="endpoints",verbs={delete}
+//+kubebuilder:rbac:groups="",resources="configmaps",verbs={get}
+//+kubebuilder:rbac:groups="",resources="configmaps",verbs={delete}
 
 // Reconcile does the work to move the current state of the world toward the
 // desired state described in a [v1beta1.PGUpgrade] identified by req.
@@ -162,6 +186,17 @@ func (r *PGUpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Report the step of the upgrade once the conditions below are set. This
+	// runs before the status patch above. Progress is kept when nothing was
+	// observed.
+	var world *World
+	defer func() {
+		if world != nil {
+			upgrade.Status.Progress = upgradeProgress(upgrade, world)
+		}
+		upgrade.Status.Phase = upgradePhase(upgrade, upgrade.Status.Progress)
+	}()
+
 	// Validate the remainder of the upgrade specification. These can likely
 	// move to CEL rules or a webhook when supported.
 
@@ -197,13 +232,69 @@ func (r *PGUpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
+	// File cloning is possible only in PostgreSQL 12 and later.
+	if upgrade.Spec.TransferMode == "Clone" && upgrade.Spec.ToPostgresVersion < 12 {
+
+		meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+			ObservedGeneration: upgrade.GetGeneration(),
+			Type:               ConditionPGUpgradeProgressing,
+			Status:             metav1.ConditionFalse,
+			Reason:             "PGUpgradeInvalid",
+			Message: fmt.Sprintf(
+				"Cannot clone files when upgrading to postgres version %d",
+				upgrade.Spec.ToPostgresVersion),
+		})
+
+		return ctrl.Result{}, nil
+	}
+
+	// A rollback restores the backup taken before the upgrade.
+	if upgrade.Spec.Rollback && upgrade.Spec.PreUpgradeBackup == nil {
+
+		meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+			ObservedGeneration: upgrade.GetGeneration(),
+			Type:               ConditionPGUpgradeProgressing,
+			Status:             metav1.ConditionFalse,
+			Reason:             "PGUpgradeInvalid",
+			Message:            "Cannot roll back without a pre-upgrade backup",
+		})
+
+		return ctrl.Result{}, nil
+	}
+
+	// The LogicalReplication method leaves the cluster running and untouched
+	// when it fails, so there is nothing to check, back up, or roll back.
+	if upgrade.Spec.Method == MethodLogicalReplication &&
+		(upgrade.Spec.Preflight || upgrade.Spec.ScanModules ||
+			upgrade.Spec.PreUpgradeBackup != nil || upgrade.Spec.Rollback) {
+
+		meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+			ObservedGeneration: upgrade.GetGeneration(),
+			Type:               ConditionPGUpgradeProgressing,
+			Status:             metav1.ConditionFalse,
+			Reason:             "PGUpgradeInvalid",
+			Message:            "Cannot use preflight, scanModules, preUpgradeBackup, or rollback with the LogicalReplication method",
+		})
+
+		return ctrl.Result{}, nil
+	}
+
 	setStatusToProgressingIfReasonWas("PGUpgradeInvalid", upgrade)
 
 	// Observations and cluster validation
 	//
 	// First, read everything we need from the API. Compare the state of the
 	// world to the upgrade specification, perform any remaining validation.
-	world, err := r.observeWorld(ctx, upgrade)
+	world, err = r.observeWorld(ctx, upgrade)
+
+	// Write what was observed once the conditions below are set. This runs
+	// before the status patch above.
+	defer func() {
+		if snapshot := r.reconcileWorldSnapshot(ctx, upgrade, world); snapshot != nil {
+			log.Error(snapshot, "Writing PGUpgrade world snapshot")
+		}
+	}()
+
 	// If `observeWorld` returns an error, then exit early.
 	// If we do no exit here, err is assume nil
 	if err != nil {
@@ -238,6 +329,20 @@ func (r *PGUpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 	setStatusToProgressingIfReasonWas("PGClusterNotFound", upgrade)
 
+	// Once a rollback has started, only report its progress. Changes to the
+	// cluster are watched.
+	if meta.FindStatusCondition(upgrade.Status.Conditions, ConditionPGUpgradeRolledBack) != nil {
+		meta.SetStatusCondition(&upgrade.Status.Conditions,
+			rollbackCondition(upgrade, world.Cluster))
+
+		return ctrl.Result{}, nil
+	}
+
+	// The LogicalReplication method copies the cluster rather than changing it.
+	if upgrade.Spec.Method == MethodLogicalReplication {
+		return r.reconcileLogicalReplication(ctx, upgrade, world)
+	}
+
 	// Get the spec version to check if this cluster is at the requested version
 	version := int64(world.Cluster.Spec.PostgresVersion)
 
@@ -296,7 +401,70 @@ func (r *PGUpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 				upgrade.Spec.PostgresClusterName, upgrade.Spec.ToPostgresVersion),
 		})
 
-		if upgradeJobComplete && removeDataJobsComplete {
+		// pg_upgrade does not change the versions of extensions. When asked,
+		// update them once the cluster is running the new version. The upgrade
+		// does not succeed until they are updated.
+		if upgradeJobComplete && removeDataJobsComplete &&
+			upgrade.Spec.UpdateExtensions != nil &&
+			!meta.IsStatusConditionTrue(upgrade.Status.Conditions, ConditionPGUpgradeExtensionsUpdated) {
+
+			if version != int64(upgrade.Spec.ToPostgresVersion) ||
+				world.ClusterShutdown || world.ClusterLeaderPod == nil {
+				meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+					ObservedGeneration: upgrade.Generation,
+					Type:               ConditionPGUpgradeExtensionsUpdated,
+					Status:             metav1.ConditionFalse,
+					Reason:             "PGUpgradeExtensionsPending",
+					Message: fmt.Sprintf(
+						"PostgresCluster %s must be running version %d to update extensions",
+						upgrade.Spec.PostgresClusterName, upgrade.Spec.ToPostgresVersion),
+				})
+
+				return ctrl.Result{}, nil
+			}
+
+			results, err := r.updateExtensions(ctx, upgrade, world.ClusterLeaderPod)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			upgrade.Status.ExtensionUpdates = results
+
+			var failed int
+			for _, result := range results {
+				if result.Error != "" {
+					failed++
+				}
+			}
+
+			// Try again later when any database failed. The reason may be
+			// something the user has to fix, so do not return an error.
+			if failed > 0 {
+				meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+					ObservedGeneration: upgrade.Generation,
+					Type:               ConditionPGUpgradeExtensionsUpdated,
+					Status:             metav1.ConditionFalse,
+					Reason:             "PGUpgradeExtensionsFailed",
+					Message: fmt.Sprintf(
+						"Could not update extensions in %d of %d databases",
+						failed, len(results)),
+				})
+
+				return ctrl.Result{RequeueAfter: time.Minute}, nil
+			}
+
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradeExtensionsUpdated,
+				Status:             metav1.ConditionTrue,
+				Reason:             "PGUpgradeExtensionsUpdated",
+				Message: fmt.Sprintf(
+					"Updated extensions in %d databases", len(results)),
+			})
+		}
+
+		if upgradeJobComplete && removeDataJobsComplete &&
+			(upgrade.Spec.UpdateExtensions == nil ||
+				meta.IsStatusConditionTrue(upgrade.Status.Conditions, ConditionPGUpgradeExtensionsUpdated)) {
 			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
 				ObservedGeneration: upgrade.Generation,
 				Type:               ConditionPGUpgradeSucceeded,
@@ -311,6 +479,201 @@ func (r *PGUpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
+	// When asked, look for the extensions and libraries of the cluster in the
+	// image of the new version before anything else. Those that are missing
+	// would make pg_upgrade fail after the cluster is shut down.
+	if upgrade.Spec.ScanModules && upgradeJob == nil {
+		scanJob := world.Jobs[pgUpgradeScanJob(upgrade).Name]
+
+		switch {
+		case scanJob != nil && jobCompleted(scanJob):
+			upgrade.Status.MissingModules = nil
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradeModules,
+				Status:             metav1.ConditionTrue,
+				Reason:             "PGUpgradeModulesFound",
+				Message: fmt.Sprintf(
+					"The image of PostgreSQL %d has every module of the cluster",
+					upgrade.Spec.ToPostgresVersion),
+			})
+
+		case scanJob != nil && jobFailed(scanJob):
+			upgrade.Status.MissingModules = failedChecks(world.ScanPods)
+
+			message := fmt.Sprintf("Module scan job %s failed, please check its pod logs",
+				scanJob.Name)
+			if len(upgrade.Status.MissingModules) > 0 {
+				message = missingModulesMessage(upgrade,
+					upgrade.Status.MissingModules, scanJob.Name)
+			}
+
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradeModules,
+				Status:             metav1.ConditionFalse,
+				Reason:             "PGUpgradeModulesMissing",
+				Message:            message,
+			})
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradeProgressing,
+				Status:             metav1.ConditionFalse,
+				Reason:             "PGUpgradeModuleScanFailed",
+				Message:            message,
+			})
+
+			return ctrl.Result{}, nil
+
+		case scanJob != nil:
+			// Wait for the job to finish. Its changes are watched.
+			return ctrl.Result{}, nil
+
+		case world.ClusterShutdown || world.ClusterLeaderPod == nil || world.ClusterInstance == nil:
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradeProgressing,
+				Status:             metav1.ConditionFalse,
+				Reason:             "PGUpgradeModuleScanPending",
+				Message: fmt.Sprintf(
+					"PostgresCluster %s must be running to scan its modules before upgrade",
+					upgrade.Spec.PostgresClusterName),
+			})
+
+			return ctrl.Result{}, nil
+
+		default:
+			modules, err := scanModules(ctx, r.executor(world.ClusterLeaderPod))
+			if err != nil {
+				return ctrl.Result{}, errors.WithStack(err)
+			}
+
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradeModules,
+				Status:             metav1.ConditionUnknown,
+				Reason:             "PGUpgradeModuleScanRunning",
+				Message: fmt.Sprintf(
+					"Looking for the modules of PostgresCluster %s in the image of PostgreSQL %d",
+					upgrade.Spec.PostgresClusterName, upgrade.Spec.ToPostgresVersion),
+			})
+
+			err = errors.WithStack(r.applyJob(ctx,
+				r.generateScanJob(ctx, upgrade, world.ClusterInstance, moduleChecks(modules)),
+				upgrade.Spec.PodFailurePolicy))
+
+			return ctrl.Result{}, err
+		}
+	}
+
+	setStatusToProgressingIfReasonWas("PGUpgradeModuleScanFailed", upgrade)
+	setStatusToProgressingIfReasonWas("PGUpgradeModuleScanPending", upgrade)
+
+	// When asked, check a copy of the primary for incompatibilities before the
+	// cluster is shut down so they can be resolved without downtime. The check
+	// is done once the upgrade job exists.
+	if upgrade.Spec.Preflight && upgradeJob == nil {
+		preflightJob := world.Jobs[pgUpgradePreflightJob(upgrade).Name]
+
+		switch {
+		case preflightJob != nil && jobCompleted(preflightJob):
+			upgrade.Status.Incompatibilities = nil
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradePreflight,
+				Status:             metav1.ConditionTrue,
+				Reason:             "PGUpgradeCompatible",
+				Message:            "pg_upgrade --check found no incompatibilities",
+			})
+
+		case preflightJob != nil && jobFailed(preflightJob):
+			upgrade.Status.Incompatibilities = failedChecks(world.PreflightPods)
+
+			message := fmt.Sprintf("Preflight job %s failed, please check its pod logs",
+				preflightJob.Name)
+			if n := len(upgrade.Status.Incompatibilities); n > 0 {
+				message = fmt.Sprintf(
+					"pg_upgrade --check found %d incompatibilities; delete job %s to check again",
+					n, preflightJob.Name)
+			}
+
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradePreflight,
+				Status:             metav1.ConditionFalse,
+				Reason:             "PGUpgradeIncompatible",
+				Message:            message,
+			})
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradeProgressing,
+				Status:             metav1.ConditionFalse,
+				Reason:             "PGUpgradePreflightFailed",
+				Message:            message,
+			})
+
+			return ctrl.Result{}, nil
+
+		case preflightJob != nil:
+			// Wait for the job to finish. Its changes are watched.
+			return ctrl.Result{}, nil
+
+		case world.ClusterShutdown || world.ClusterInstance == nil:
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradeProgressing,
+				Status:             metav1.ConditionFalse,
+				Reason:             "PGUpgradePreflightPending",
+				Message: fmt.Sprintf(
+					"PostgresCluster %s must be running to check it before upgrade",
+					upgrade.Spec.PostgresClusterName),
+			})
+
+			return ctrl.Result{}, nil
+
+		default:
+			meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
+				ObservedGeneration: upgrade.Generation,
+				Type:               ConditionPGUpgradePreflight,
+				Status:             metav1.ConditionUnknown,
+				Reason:             "PGUpgradePreflightRunning",
+				Message: fmt.Sprintf(
+					"Checking a copy of PostgresCluster %s",
+					upgrade.Spec.PostgresClusterName),
+			})
+
+			err = errors.WithStack(r.applyJob(ctx,
+				r.generatePreflightJob(ctx, upgrade, world.ClusterInstance,
+					naming.ClusterPrimaryService(world.Cluster).Name),
+				upgrade.Spec.PodFailurePolicy))
+
+			return ctrl.Result{}, err
+		}
+	}
+
+	setStatusToProgressingIfReasonWas("PGUpgradePreflightFailed", upgrade)
+	setStatusToProgressingIfReasonWas("PGUpgradePreflightPending", upgrade)
+
+	// When asked, take a full backup while the cluster is still running. It
+	// is restored when the upgrade fails and a rollback is requested. Taking
+	// the backup changes the cluster, so the cluster must allow this upgrade.
+	// Add that annotation now, while the cluster is running, when asked.
+	if upgrade.Spec.PreUpgradeBackup != nil && upgradeJob == nil &&
+		upgrade.Spec.AutoAnnotateCluster {
+		if allowed, err := r.reconcileAllowUpgrade(ctx, upgrade, world.Cluster); !allowed {
+			return ctrl.Result{}, err
+		}
+	}
+	if upgrade.Spec.PreUpgradeBackup != nil && upgradeJob == nil &&
+		world.Cluster.GetAnnotations()[AnnotationAllowUpgrade] == upgrade.Name {
+		ready, err := r.reconcilePreUpgradeBackup(ctx, upgrade, world)
+		if !ready || err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	setStatusToProgressingIfReasonWas("PGUpgradeBackupPending", upgrade)
+
 	// The upgrade needs to manipulate the data directory of the primary while
 	// Postgres is stopped. Wait until all instances are gone and the primary
 	// is identified.
@@ -355,22 +718,34 @@ func (r *PGUpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	// Having an annotation on the cluster also provides some assurance that
 	// the user that created the upgrade also has authority to create or edit
 	// the cluster.
+	//
+	// When asked, the controller adds the annotation itself. It does so only
+	// when the upgrade is approved; see [upgradeApproved].
+	if allowed, err := r.reconcileAllowUpgrade(ctx, upgrade, world.Cluster); !allowed {
+		return ctrl.Result{}, err
+	}
 
-	if allowed := world.Cluster.GetAnnotations()[AnnotationAllowUpgrade] == upgrade.Name; !allowed {
+	setStatusToProgressingIfReasonWas("PGClusterMissingRequiredAnnotation", upgrade)
+	setStatusToProgressingIfReasonWas("PGUpgradeNotApproved", upgrade)
+
+	// The upgrade clears the state Patroni keeps in its DCS, which is possible
+	// only when that is stored in Kubernetes Endpoints or ConfigMaps.
+	if world.PatroniDCS != PatroniDCSEndpoints && world.PatroniDCS != PatroniDCSConfigMaps {
 		meta.SetStatusCondition(&upgrade.Status.Conditions, metav1.Condition{
 			ObservedGeneration: upgrade.Generation,
 			Type:               ConditionPGUpgradeProgressing,
 			Status:             metav1.ConditionFalse,
-			Reason:             "PGClusterMissingRequiredAnnotation",
+			Reason:             "PGClusterUnsupportedDCS",
 			Message: fmt.Sprintf(
-				"PostgresCluster %s lacks annotation for upgrade %s",
-				upgrade.Spec.PostgresClusterName, upgrade.GetName()),
+				"PostgresCluster %s stores Patroni state in %q, but upgrade supports only Kubernetes %s and %s",
+				upgrade.Spec.PostgresClusterName, world.PatroniDCS,
+				PatroniDCSEndpoints, PatroniDCSConfigMaps),
 		})
 
 		return ctrl.Result{}, nil
 	}
 
-	setStatusToProgressingIfReasonWas("PGClusterMissingRequiredAnnotation", upgrade)
+	setStatusToProgressingIfReasonWas("PGClusterUnsupportedDCS", upgrade)
 
 	// Currently our jobs are set to only run once, so if any job has failed, the
 	// upgrade has failed.
@@ -383,7 +758,18 @@ func (r *PGUpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			Message:            "Upgrade jobs failed, please check individual pod logs",
 		})
 
-		return ctrl.Result{}, nil
+		// When asked, restore the backup taken before the upgrade. The
+		// upgrade job does not start until that backup is in the repository.
+		if upgrade.Spec.Rollback && upgrade.Status.PreUpgradeBackup != nil &&
+			upgrade.Status.PreUpgradeBackup.Label != "" {
+			err = r.startRollback(ctx, upgrade, world.Cluster)
+			if err == nil {
+				meta.SetStatusCondition(&upgrade.Status.Conditions,
+					rollbackCondition(upgrade, world.Cluster))
+			}
+		}
+
+		return ctrl.Result{}, err
 	}
 
 	// If we have reached this point, all preconditions for upgrade are satisfied.
@@ -422,7 +808,21 @@ func (r *PGUpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			// Set the pgBackRest status for bootstrapping
 			patch.Status.PGBackRest.Repos = []v1beta1.RepoStatus{}
 
-			err = r.Status().Patch(ctx, patch, client.MergeFrom(world.Cluster), r.Owner)
+			// Ask for a full backup of the new version. The PostgresCluster
+			// controller marks this condition true when that backup completes.
+			meta.SetStatusCondition(&patch.Status.Conditions, metav1.Condition{
+				ObservedGeneration: patch.GetGeneration(),
+				Type:               ConditionPostUpgradeBackup,
+				Status:             metav1.ConditionFalse,
+				Reason:             "Upgraded",
+				Message: fmt.Sprintf("PostgreSQL was upgraded to %d; "+
+					"a full backup is required", upgrade.Spec.ToPostgresVersion),
+			})
+
+			// Other controllers write conditions of the cluster, so fail rather
+			// than replace what they wrote since it was read.
+			err = r.Status().Patch(ctx, patch, client.MergeFromWithOptions(
+				world.Cluster, client.MergeFromWithOptimisticLock{}), r.Owner)
 		}
 
 		return ctrl.Result{}, err
@@ -430,8 +830,9 @@ func (r *PGUpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 	// TODO: error from apply could mean that the job exists with a different spec.
 	if err == nil && !upgradeJobComplete {
-		err = errors.WithStack(r.apply(ctx,
-			r.generateUpgradeJob(ctx, upgrade, world.ClusterPrimary)))
+		err = errors.WithStack(r.applyJob(ctx,
+			r.generateUpgradeJob(ctx, upgrade, world.ClusterPrimary),
+			upgrade.Spec.PodFailurePolicy))
 	}
 
 	// Create the jobs to remove the data from the replicas, as long as
@@ -441,28 +842,37 @@ func (r *PGUpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	if err == nil && upgradeJobComplete && !removeDataJobsComplete {
 		for _, sts := range world.ClusterReplicas {
 			if err == nil {
-				err = r.apply(ctx, r.generateRemoveDataJob(ctx, upgrade, sts))
+				err = r.applyJob(ctx, r.generateRemoveDataJob(ctx, upgrade, sts),
+					upgrade.Spec.PodFailurePolicy)
 			}
 		}
 	}
 
 	// The upgrade job generates a new system identifier for this cluster.
-	// Clear the old identifier from Patroni by deleting its DCS Endpoints.
-	// This is safe to do this when all Patroni processes are stopped
-	// (ClusterShutdown) and PGO has identified a leader to start first
-	// (ClusterPrimary).
+	// Clear the old identifier from Patroni by deleting the Endpoints or
+	// ConfigMaps of its DCS. This is safe to do this when all Patroni processes
+	// are stopped (ClusterShutdown) and PGO has identified a leader to start
+	// first (ClusterPrimary).
 	// - https://github.com/zalando/patroni/blob/v2.1.2/docs/existing_data.rst
-	//
-	// TODO(cbandy): This works only when using Kubernetes Endpoints for DCS.
-	if len(world.PatroniEndpoints) > 0 {
+	if len(world.PatroniEndpoints) > 0 || len(world.PatroniConfigMaps) > 0 {
 		for _, object := range world.PatroniEndpoints {
 			uid := object.GetUID()
 			version := object.GetResourceVersion()
 			exactly := client.Preconditions{UID: &uid, ResourceVersion: &version}
-			err = client.IgnoreNotFound(r.Client.Delete(ctx, object, exactly))
+			if err == nil {
+				err = client.IgnoreNotFound(r.Client.Delete(ctx, object, exactly))
+			}
+		}
+		for _, object := range world.PatroniConfigMaps {
+			uid := object.GetUID()
+			version := object.GetResourceVersion()
+			exactly := client.Preconditions{UID: &uid, ResourceVersion: &version}
+			if err == nil {
+				err = client.IgnoreNotFound(r.Client.Delete(ctx, object, exactly))
+			}
 		}
 
-		// Requeue to verify that Patroni endpoints are deleted
+		// Requeue to verify that Patroni endpoints and configmaps are deleted
 		return ctrl.Result{Requeue: true}, err // FIXME
 	}
 
@@ -0,0 +1,143 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// These are the values of status.phase of a PGUpgrade.
+const (
+	PhasePending             = "Pending"
+	PhaseChecking            = "Checking"
+	PhaseBackingUp           = "BackingUp"
+	PhaseUpgrading           = "Upgrading"
+	PhaseRemovingReplicaData = "RemovingReplicaData"
+	PhaseFinalizing          = "Finalizing"
+	PhaseReplicating         = "Replicating"
+	PhaseCuttingOver         = "CuttingOver"
+	PhaseBlocked             = "Blocked"
+	PhaseFailed              = "Failed"
+	PhaseRollingBack         = "RollingBack"
+	PhaseRolledBack          = "RolledBack"
+	PhaseSucceeded           = "Succeeded"
+)
+
+// upgradeProgress returns the progress of each step of upgrade in world. The
+// LogicalReplication method reports its progress elsewhere, so it returns nil
+// for that method.
+func upgradeProgress(upgrade *v1beta1.PGUpgrade, world *World) *v1beta1.PGUpgradeProgress {
+	if upgrade.Spec.Method == MethodLogicalReplication {
+		return nil
+	}
+	progress := new(v1beta1.PGUpgradeProgress)
+
+	// The upgrade job is created only after every check passes.
+	if job := world.Jobs[pgUpgradeJob(upgrade).Name]; job != nil {
+		progress.ChecksPassed = true
+
+		switch {
+		case jobCompleted(job):
+			progress.UpgradeJob = "Succeeded"
+		case jobFailed(job):
+			progress.UpgradeJob = "Failed"
+		case job.Status.Active > 0:
+			progress.UpgradeJob = "Running"
+		default:
+			progress.UpgradeJob = "Pending"
+		}
+	}
+
+	if world.ReplicasExpected > 0 {
+		progress.Replicas = int32(world.ReplicasExpected)
+	}
+	for _, job := range world.Jobs {
+		if job.GetLabels()[LabelRole] == removeData && jobCompleted(job) {
+			progress.ReplicasRemoved++
+		}
+	}
+
+	// The PostgresCluster controller reports the backup of the new version
+	// in this condition of the cluster.
+	if world.Cluster != nil {
+		if backup := meta.FindStatusCondition(world.Cluster.Status.Conditions,
+			ConditionPostUpgradeBackup); backup != nil {
+			progress.BackupRequested = true
+			progress.BackupCompleted = backup.Status == metav1.ConditionTrue
+		}
+	}
+
+	return progress
+}
+
+// upgradePhase returns the step of upgrade that is happening according to its
+// conditions and progress. A nil progress is no progress.
+func upgradePhase(upgrade *v1beta1.PGUpgrade, progress *v1beta1.PGUpgradeProgress) string {
+	if progress == nil {
+		progress = new(v1beta1.PGUpgradeProgress)
+	}
+	conditions := upgrade.Status.Conditions
+	succeeded := meta.FindStatusCondition(conditions, ConditionPGUpgradeSucceeded)
+	rolledBack := meta.FindStatusCondition(conditions, ConditionPGUpgradeRolledBack)
+	progressing := meta.FindStatusCondition(conditions, ConditionPGUpgradeProgressing)
+	inSync := meta.FindStatusCondition(conditions, ConditionPGUpgradeInSync)
+	preflight := meta.FindStatusCondition(conditions, ConditionPGUpgradePreflight)
+	modules := meta.FindStatusCondition(conditions, ConditionPGUpgradeModules)
+	backup := meta.FindStatusCondition(conditions, ConditionPGUpgradeBackup)
+
+	switch {
+	case succeeded != nil && succeeded.Status == metav1.ConditionTrue:
+		return PhaseSucceeded
+
+	case rolledBack != nil && rolledBack.Status == metav1.ConditionTrue:
+		return PhaseRolledBack
+	case rolledBack != nil && rolledBack.Status == metav1.ConditionUnknown:
+		return PhaseRollingBack
+	case succeeded != nil && succeeded.Status == metav1.ConditionFalse:
+		return PhaseFailed
+
+	// Cutover is not stopped by changes to the spec or cluster.
+	case inSync != nil && inSync.Reason == "PGUpgradeCutover":
+		return PhaseCuttingOver
+
+	// Progressing is false when the upgrade is waiting on something that
+	// cannot change on its own, except once PostgreSQL is running the new version.
+	case progressing != nil && progressing.Status == metav1.ConditionFalse &&
+		progressing.Reason != "PGUpgradeCompleted":
+		return PhaseBlocked
+
+	case upgrade.Spec.Method == MethodLogicalReplication:
+		return PhaseReplicating
+
+	case progress.BackupRequested ||
+		(progressing != nil && progressing.Reason == "PGUpgradeCompleted"):
+		return PhaseFinalizing
+	case progress.UpgradeJob == "Succeeded":
+		return PhaseRemovingReplicaData
+	case progress.UpgradeJob != "":
+		return PhaseUpgrading
+
+	case backup != nil && backup.Status != metav1.ConditionTrue:
+		return PhaseBackingUp
+	case preflight != nil && preflight.Status == metav1.ConditionUnknown,
+		modules != nil && modules.Status == metav1.ConditionUnknown:
+		return PhaseChecking
+	}
+
+	return PhasePending
+}
@@ -0,0 +1,256 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestUpgradeProgress(t *testing.T) {
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.Name = "up"
+
+	finished := func(name, role string, condition batchv1.JobConditionType) *batchv1.Job {
+		job := &batchv1.Job{}
+		job.Name = name
+		job.Labels = map[string]string{LabelRole: role}
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type: condition, Status: corev1.ConditionTrue,
+		}}
+		return job
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		world := NewWorld()
+		world.ReplicasExpected = -1
+
+		assert.DeepEqual(t, upgradeProgress(upgrade, world), &v1beta1.PGUpgradeProgress{})
+	})
+
+	t.Run("Running", func(t *testing.T) {
+		job := &batchv1.Job{}
+		job.Status.Active = 1
+
+		world := NewWorld()
+		world.Jobs[pgUpgradeJob(upgrade).Name] = job
+		world.ReplicasExpected = 2
+
+		assert.DeepEqual(t, upgradeProgress(upgrade, world), &v1beta1.PGUpgradeProgress{
+			ChecksPassed: true, UpgradeJob: "Running", Replicas: 2,
+		})
+
+		job.Status.Active = 0
+		assert.Equal(t, upgradeProgress(upgrade, world).UpgradeJob, "Pending")
+
+		world.Jobs[pgUpgradeJob(upgrade).Name] = finished("", pgUpgrade, batchv1.JobFailed)
+		assert.Equal(t, upgradeProgress(upgrade, world).UpgradeJob, "Failed")
+	})
+
+	t.Run("Replicas", func(t *testing.T) {
+		world := NewWorld()
+		world.Jobs[pgUpgradeJob(upgrade).Name] = finished("", pgUpgrade, batchv1.JobComplete)
+		world.Jobs["a"] = finished("a", removeData, batchv1.JobComplete)
+		world.Jobs["b"] = finished("b", removeData, batchv1.JobFailed)
+		world.Jobs["c"] = finished("c", removeData, batchv1.JobComplete)
+		world.ReplicasExpected = 3
+
+		world.Cluster = v1beta1.NewPostgresCluster()
+		world.Cluster.Status.Conditions = []metav1.Condition{{
+			Type: ConditionPostUpgradeBackup, Status: metav1.ConditionFalse,
+		}}
+
+		assert.DeepEqual(t, upgradeProgress(upgrade, world), &v1beta1.PGUpgradeProgress{
+			ChecksPassed: true, UpgradeJob: "Succeeded",
+			Replicas: 3, ReplicasRemoved: 2, BackupRequested: true,
+		})
+
+		world.Cluster.Status.Conditions[0].Status = metav1.ConditionTrue
+		assert.Assert(t, upgradeProgress(upgrade, world).BackupCompleted)
+	})
+
+	t.Run("LogicalReplication", func(t *testing.T) {
+		logical := upgrade.DeepCopy()
+		logical.Spec.Method = MethodLogicalReplication
+
+		assert.Assert(t, upgradeProgress(logical, NewWorld()) == nil)
+	})
+}
+
+func TestUpgradePhase(t *testing.T) {
+	condition := func(kind string, status metav1.ConditionStatus, reason string) metav1.Condition {
+		return metav1.Condition{Type: kind, Status: status, Reason: reason}
+	}
+
+	for _, tt := range []struct {
+		name       string
+		method     string
+		conditions []metav1.Condition
+		progress   *v1beta1.PGUpgradeProgress
+		expected   string
+	}{
+		{
+			name:     "New",
+			expected: PhasePending,
+		},
+		{
+			name: "WaitingForShutdown",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionFalse, "PGClusterNotShutdown"),
+			},
+			expected: PhaseBlocked,
+		},
+		{
+			name: "Preflight",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionTrue, "PGUpgradeProgressing"),
+				condition(ConditionPGUpgradePreflight, metav1.ConditionUnknown, "PGUpgradePreflightRunning"),
+			},
+			expected: PhaseChecking,
+		},
+		{
+			name: "ModuleScan",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionTrue, "PGUpgradeProgressing"),
+				condition(ConditionPGUpgradeModules, metav1.ConditionUnknown, "PGUpgradeModuleScanRunning"),
+			},
+			expected: PhaseChecking,
+		},
+		{
+			name: "PreUpgradeBackup",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionTrue, "PGUpgradeProgressing"),
+				condition(ConditionPGUpgradePreflight, metav1.ConditionTrue, "PGUpgradeCompatible"),
+				condition(ConditionPGUpgradeBackup, metav1.ConditionUnknown, "PGUpgradeBackupRunning"),
+			},
+			expected: PhaseBackingUp,
+		},
+		{
+			name: "UpgradeJob",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionTrue, "PGUpgradeProgressing"),
+				condition(ConditionPGUpgradeBackup, metav1.ConditionTrue, "PGUpgradeBackupComplete"),
+			},
+			progress: &v1beta1.PGUpgradeProgress{ChecksPassed: true, UpgradeJob: "Running"},
+			expected: PhaseUpgrading,
+		},
+		{
+			name: "RemoveData",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionTrue, "PGUpgradeProgressing"),
+			},
+			progress: &v1beta1.PGUpgradeProgress{ChecksPassed: true, UpgradeJob: "Succeeded", Replicas: 2},
+			expected: PhaseRemovingReplicaData,
+		},
+		{
+			name: "Backup",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionTrue, "PGUpgradeProgressing"),
+			},
+			progress: &v1beta1.PGUpgradeProgress{UpgradeJob: "Succeeded", BackupRequested: true},
+			expected: PhaseFinalizing,
+		},
+		{
+			name: "Extensions",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionFalse, "PGUpgradeCompleted"),
+				condition(ConditionPGUpgradeExtensionsUpdated, metav1.ConditionFalse, "PGUpgradeExtensionsFailed"),
+			},
+			progress: &v1beta1.PGUpgradeProgress{UpgradeJob: "Succeeded"},
+			expected: PhaseFinalizing,
+		},
+		{
+			name: "Succeeded",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionFalse, "PGUpgradeCompleted"),
+				condition(ConditionPGUpgradeSucceeded, metav1.ConditionTrue, "PGUpgradeSucceeded"),
+			},
+			expected: PhaseSucceeded,
+		},
+		{
+			name: "Failed",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionTrue, "PGUpgradeProgressing"),
+				condition(ConditionPGUpgradeSucceeded, metav1.ConditionFalse, "PGUpgradeFailed"),
+			},
+			progress: &v1beta1.PGUpgradeProgress{UpgradeJob: "Failed"},
+			expected: PhaseFailed,
+		},
+		{
+			name: "RollingBack",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeSucceeded, metav1.ConditionFalse, "PGUpgradeFailed"),
+				condition(ConditionPGUpgradeRolledBack, metav1.ConditionUnknown, "PGUpgradeRollbackRunning"),
+			},
+			expected: PhaseRollingBack,
+		},
+		{
+			name: "RolledBack",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeSucceeded, metav1.ConditionFalse, "PGUpgradeFailed"),
+				condition(ConditionPGUpgradeRolledBack, metav1.ConditionTrue, "PGUpgradeRolledBack"),
+			},
+			expected: PhaseRolledBack,
+		},
+		{
+			name: "RollbackFailed",
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeSucceeded, metav1.ConditionFalse, "PGUpgradeFailed"),
+				condition(ConditionPGUpgradeRolledBack, metav1.ConditionFalse, "PGUpgradeRollbackFailed"),
+			},
+			expected: PhaseFailed,
+		},
+		{
+			name:   "Replicating",
+			method: MethodLogicalReplication,
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionTrue, "PGUpgradeReplicating"),
+				condition(ConditionPGUpgradeInSync, metav1.ConditionFalse, "PGUpgradeCopyingTables"),
+			},
+			expected: PhaseReplicating,
+		},
+		{
+			name:   "Incompatible",
+			method: MethodLogicalReplication,
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionFalse, "PGUpgradeIncompatible"),
+			},
+			expected: PhaseBlocked,
+		},
+		{
+			name:   "CuttingOver",
+			method: MethodLogicalReplication,
+			conditions: []metav1.Condition{
+				condition(ConditionPGUpgradeProgressing, metav1.ConditionTrue, "PGUpgradeCuttingOver"),
+				condition(ConditionPGUpgradeInSync, metav1.ConditionTrue, "PGUpgradeCutover"),
+			},
+			expected: PhaseCuttingOver,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			upgrade := &v1beta1.PGUpgrade{}
+			upgrade.Spec.Method = tt.method
+			upgrade.Status.Conditions = tt.conditions
+
+			assert.Equal(t, upgradePhase(upgrade, tt.progress), tt.expected)
+		})
+	}
+}
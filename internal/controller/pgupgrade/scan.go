@@ -0,0 +1,277 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// moduleScanSQL prints what the current database loads from outside of the
+// PostgreSQL server, one "kind|name|library" per line: installed extensions,
+// the libraries of C functions, base types with input functions in those
+// libraries, and shared_preload_libraries. Names are schema-qualified because
+// the search_path is empty.
+const moduleScanSQL = `
+SELECT 'extension', extname, '' FROM pg_catalog.pg_extension
+ UNION
+SELECT 'library', p.probin, p.probin
+  FROM pg_catalog.pg_proc p JOIN pg_catalog.pg_language l ON l.oid = p.prolang
+ WHERE l.lanname = 'c' AND p.probin IS NOT NULL
+ UNION
+SELECT 'type', pg_catalog.format('%I.%s', pg_catalog.current_database(), t.oid::pg_catalog.regtype), p.probin
+  FROM pg_catalog.pg_type t
+  JOIN pg_catalog.pg_proc p ON p.oid = t.typinput
+  JOIN pg_catalog.pg_language l ON l.oid = p.prolang
+ WHERE t.typtype = 'b' AND l.lanname = 'c' AND p.probin IS NOT NULL
+ UNION
+SELECT 'preload', pg_catalog.btrim(library, ' "'), ''
+  FROM pg_catalog.unnest(pg_catalog.string_to_array(
+       pg_catalog.current_setting('shared_preload_libraries'), ',')) AS library
+ WHERE pg_catalog.btrim(library, ' "') <> ''`
+
+// clusterModules are the extensions and libraries that the databases of a
+// cluster use. Their names are sorted.
+type clusterModules struct {
+	Extensions []string
+	Libraries  []string
+	Preload    []string
+
+	// Types maps each library to the custom data types that need it.
+	Types map[string][]string
+}
+
+// scanModules calls exec to list the extensions and libraries used by every
+// database that allows connections.
+func scanModules(ctx context.Context, exec postgres.Executor) (*clusterModules, error) {
+	stdout, stderr, err := exec.ExecInAllDatabases(ctx, `
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+`+moduleScanSQL+`;`, map[string]string{
+		"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+		"QUIET":         "on", // Do not print successful statements to stdout.
+	})
+
+	logging.FromContext(ctx).V(1).Info("scanned modules", "stdout", stdout, "stderr", stderr)
+
+	if err != nil {
+		return nil, err
+	}
+	return parseModules(stdout), nil
+}
+
+// parseModules returns the modules in output of [moduleScanSQL].
+func parseModules(output string) *clusterModules {
+	extensions, libraries, preload := sets.NewString(), sets.NewString(), sets.NewString()
+	types := map[string]sets.String{}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), "|", 3)
+		if len(fields) != 3 || fields[1] == "" {
+			continue
+		}
+		switch fields[0] {
+		case "extension":
+			extensions.Insert(fields[1])
+		case "library":
+			libraries.Insert(fields[1])
+		case "preload":
+			preload.Insert(fields[1])
+		case "type":
+			if types[fields[2]] == nil {
+				types[fields[2]] = sets.NewString()
+			}
+			types[fields[2]].Insert(fields[1])
+		}
+	}
+
+	modules := &clusterModules{
+		Extensions: extensions.List(),
+		Libraries:  libraries.List(),
+		Preload:    preload.List(),
+		Types:      make(map[string][]string, len(types)),
+	}
+	for library, names := range types {
+		modules.Types[library] = names.List()
+	}
+	return modules
+}
+
+// libraryPath returns where PostgreSQL looks for library. Names without a
+// directory are found in "$libdir", the default dynamic_library_path.
+// - https://www.postgresql.org/docs/current/xfunc-c.html#XFUNC-C-DYNLOAD
+func libraryPath(library string) string {
+	if strings.Contains(library, "/") {
+		return library
+	}
+	return path.Join("$libdir", library)
+}
+
+// moduleChecks returns pairs of arguments for [scanCommand]: the path of each
+// module in modules followed by how to describe it when it is missing.
+func moduleChecks(modules *clusterModules) []string {
+	var checks []string
+	for _, name := range modules.Extensions {
+		checks = append(checks,
+			"$sharedir/extension/"+name+".control", fmt.Sprintf("extension %q", name))
+	}
+	for _, name := range modules.Preload {
+		checks = append(checks,
+			libraryPath(name), fmt.Sprintf("shared_preload_libraries %q", name))
+	}
+	for _, name := range modules.Libraries {
+		description := fmt.Sprintf("library %q", name)
+		if types := modules.Types[name]; len(types) > 0 {
+			description += " of type " + strings.Join(types, ", ")
+		}
+		checks = append(checks, libraryPath(name), description)
+	}
+	return checks
+}
+
+// pgUpgradeScanJob returns the ObjectMeta for the Job that looks for the
+// modules of the cluster in the image of the new version.
+func pgUpgradeScanJob(upgrade *v1beta1.PGUpgrade) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: upgrade.Namespace,
+		Name:      upgrade.Name + "-scan",
+	}
+}
+
+// scanCommand returns an entrypoint that looks for each path in checks in the
+// installation of the new version. The descriptions of those it does not find
+// are written to the termination message of the container, one per line.
+func scanCommand(upgrade *v1beta1.PGUpgrade, checks []string) []string {
+	newVersion := fmt.Sprint(upgrade.Spec.ToPostgresVersion)
+
+	script := strings.Join([]string{
+		`declare -r new_version="$1"; shift`,
+		`declare -r sharedir="/usr/pgsql-${new_version}/share" libdir="/usr/pgsql-${new_version}/lib"`,
+		`printf 'Looking for modules in PostgreSQL %s ...\n\n' "${new_version}"`,
+		`missing=()`,
+		`while [[ "$#" -gt 1 ]]; do`,
+		`  path="${1/#\$sharedir/${sharedir}}"; path="${path/#\$libdir/${libdir}}"`,
+
+		// PostgreSQL adds the shared library suffix to names without one.
+		`  if [[ -f "${path}" || -f "${path}.so" ]]`,
+		`  then printf 'Found %s\n' "$2"`,
+		`  else printf 'Missing %s\n' "$2"; missing+=("$2")`,
+		`  fi`,
+		`  shift 2`,
+		`done`,
+		`[[ "${#missing[@]}" -eq 0 ]] || { printf '%s\n' "${missing[@]}" > /dev/termination-log; exit 1; }`,
+		`echo -e "\nModule scan Job Complete!"`,
+	}, "\n")
+
+	return append([]string{"bash", "-ceu", "--", script, "scan", newVersion}, checks...)
+}
+
+// generateScanJob returns a Job that looks for the modules in checks in the
+// image of the new version. It runs with the pod template of instance, but
+// without any of its volumes.
+func (r *PGUpgradeReconciler) generateScanJob(
+	_ context.Context, upgrade *v1beta1.PGUpgrade, instance *appsv1.StatefulSet,
+	checks []string,
+) *batchv1.Job {
+	job := &batchv1.Job{}
+	job.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+
+	job.Namespace = upgrade.Namespace
+	job.Name = pgUpgradeScanJob(upgrade).Name
+
+	job.Annotations = upgrade.Spec.Metadata.GetAnnotationsOrNil()
+	job.Labels = Merge(upgrade.Spec.Metadata.GetLabelsOrNil(),
+		commonLabels(scan, upgrade),
+		map[string]string{
+			LabelVersion: fmt.Sprint(upgrade.Spec.ToPostgresVersion),
+		})
+
+	// Find the database container.
+	var database *corev1.Container
+	for i := range instance.Spec.Template.Spec.Containers {
+		container := instance.Spec.Template.Spec.Containers[i]
+		if container.Name == ContainerDatabase {
+			database = &container
+		}
+	}
+
+	// Copy the pod template from the instance StatefulSet. This includes the
+	// service account, DNS policies, and security context.
+	instance.Spec.Template.DeepCopyInto(&job.Spec.Template)
+
+	// Use the same labels and annotations as the job.
+	job.Spec.Template.ObjectMeta = metav1.ObjectMeta{
+		Annotations: job.Annotations,
+		Labels:      job.Labels,
+	}
+
+	// The scan reads only the image.
+	job.Spec.Template.Spec.Volumes = nil
+
+	// Use the image pull secrets specified for the upgrade image.
+	job.Spec.Template.Spec.ImagePullSecrets = upgrade.Spec.ImagePullSecrets
+
+	// Scan exactly once; the result does not change until the image does.
+	job.Spec.BackoffLimit = initialize.Int32(0)
+	job.Spec.ActiveDeadlineSeconds = upgrade.Spec.ActiveDeadlineSeconds
+	job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+
+	// Replace all containers with one that does the scan.
+	job.Spec.Template.Spec.EphemeralContainers = nil
+	job.Spec.Template.Spec.InitContainers = nil
+	job.Spec.Template.Spec.Containers = []corev1.Container{{
+		Name:            database.Name,
+		SecurityContext: database.SecurityContext,
+
+		// Use our scan command and the specified image and resources.
+		Command:         scanCommand(upgrade, checks),
+		Image:           pgUpgradeContainerImage(upgrade),
+		ImagePullPolicy: upgrade.Spec.ImagePullPolicy,
+		Resources:       upgrade.Spec.Resources,
+	}}
+
+	// The following will set these fields to null if not set in the spec
+	job.Spec.Template.Spec.Affinity = upgrade.Spec.Affinity
+	job.Spec.Template.Spec.PriorityClassName = initialize.FromPointer(
+		upgrade.Spec.PriorityClassName)
+	job.Spec.Template.Spec.Tolerations = upgrade.Spec.Tolerations
+
+	r.setControllerReference(upgrade, job)
+	return job
+}
+
+// missingModulesMessage returns a readable summary of missing for conditions.
+func missingModulesMessage(upgrade *v1beta1.PGUpgrade, missing []string, job string) string {
+	sorted := append([]string(nil), missing...)
+	sort.Strings(sorted)
+	return fmt.Sprintf(
+		"The image of PostgreSQL %d is missing %d modules: %s; delete job %s to scan again",
+		upgrade.Spec.ToPostgresVersion, len(sorted), strings.Join(sorted, "; "), job)
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestScanModules(t *testing.T) {
+	ctx := context.Background()
+
+	pod := &corev1.Pod{}
+	pod.Namespace = "ns1"
+	pod.Name = "pod2"
+
+	t.Run("Error", func(t *testing.T) {
+		expected := errors.New("boom")
+		reconciler := &PGUpgradeReconciler{
+			PodExec: func(
+				_, _, _ string,
+				stdin io.Reader, stdout, stderr io.Writer, command ...string,
+			) error {
+				return expected
+			},
+		}
+
+		_, err := scanModules(ctx, reconciler.executor(pod))
+		assert.Equal(t, err, expected)
+	})
+
+	t.Run("Output", func(t *testing.T) {
+		reconciler := &PGUpgradeReconciler{
+			PodExec: func(
+				namespace, pod, container string,
+				stdin io.Reader, stdout, stderr io.Writer, command ...string,
+			) error {
+				assert.Equal(t, namespace, "ns1")
+				assert.Equal(t, pod, "pod2")
+				assert.Equal(t, container, "database")
+
+				b, err := io.ReadAll(stdin)
+				assert.NilError(t, err)
+				assert.Assert(t, cmp.Contains(string(b), `SET search_path TO '';`))
+				assert.Assert(t, cmp.Contains(string(b), `shared_preload_libraries`))
+
+				_, _ = stdout.Write([]byte("extension|postgis|\nlibrary|$libdir/postgis-3|$libdir/postgis-3\n"))
+				return nil
+			},
+		}
+
+		modules, err := scanModules(ctx, reconciler.executor(pod))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, modules.Extensions, []string{"postgis"})
+		assert.DeepEqual(t, modules.Libraries, []string{"$libdir/postgis-3"})
+	})
+}
+
+func TestParseModules(t *testing.T) {
+	modules := parseModules(strings.Join([]string{
+		"extension|postgis|",
+		"extension|pgaudit|",
+		"extension|postgis|",
+		"library|$libdir/postgis-3|$libdir/postgis-3",
+		"type|app.public.geometry|$libdir/postgis-3",
+		"type|db2.public.geometry|$libdir/postgis-3",
+		"preload|pgaudit|",
+		"preload|pg_stat_statements|",
+		"",
+		"unexpected",
+		"library||",
+	}, "\n"))
+
+	assert.DeepEqual(t, modules, &clusterModules{
+		Extensions: []string{"pgaudit", "postgis"},
+		Libraries:  []string{"$libdir/postgis-3"},
+		Preload:    []string{"pg_stat_statements", "pgaudit"},
+		Types: map[string][]string{
+			"$libdir/postgis-3": {"app.public.geometry", "db2.public.geometry"},
+		},
+	})
+
+	assert.DeepEqual(t, moduleChecks(modules), []string{
+		"$sharedir/extension/pgaudit.control", `extension "pgaudit"`,
+		"$sharedir/extension/postgis.control", `extension "postgis"`,
+		"$libdir/pg_stat_statements", `shared_preload_libraries "pg_stat_statements"`,
+		"$libdir/pgaudit", `shared_preload_libraries "pgaudit"`,
+		"$libdir/postgis-3", `library "$libdir/postgis-3" of type app.public.geometry, db2.public.geometry`,
+	})
+}
+
+func TestGenerateScanJob(t *testing.T) {
+	ctx := context.Background()
+	reconciler := &PGUpgradeReconciler{}
+
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.Namespace = "ns1"
+	upgrade.Name = "pgu2"
+	upgrade.UID = "uid3"
+	upgrade.Spec.Image = initialize.Pointer("img4")
+	upgrade.Spec.PostgresClusterName = "pg5"
+	upgrade.Spec.FromPostgresVersion = 19
+	upgrade.Spec.ToPostgresVersion = 25
+
+	instance := &appsv1.StatefulSet{}
+	instance.Spec.Template.Spec = corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name: ContainerDatabase,
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: "postgres-data", MountPath: "/pgdata"},
+			},
+		}},
+		InitContainers: []corev1.Container{{Name: "init"}},
+		Volumes: []corev1.Volume{{
+			Name: "postgres-data",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: "pg5-abcd-pgdata",
+				},
+			},
+		}},
+	}
+
+	job := reconciler.generateScanJob(ctx, upgrade, instance, []string{
+		"$sharedir/extension/postgis.control", `extension "postgis"`,
+	})
+	assert.Equal(t, job.Name, "pgu2-scan")
+	assert.Equal(t, job.Namespace, "ns1")
+	assert.Equal(t, job.Labels[LabelRole], "scan")
+	assert.Equal(t, job.Labels[LabelPGUpgrade], "pgu2")
+	assert.Equal(t, job.Labels[LabelVersion], "25")
+	assert.Equal(t, *job.Spec.BackoffLimit, int32(0))
+
+	// The instance StatefulSet is not changed.
+	assert.Equal(t, len(instance.Spec.Template.Spec.Volumes), 1)
+
+	// Nothing is mounted; the scan reads only the image.
+	assert.Assert(t, job.Spec.Template.Spec.Volumes == nil)
+	assert.Assert(t, job.Spec.Template.Spec.InitContainers == nil)
+	assert.Equal(t, len(job.Spec.Template.Spec.Containers), 1)
+
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, container.Image, "img4")
+	assert.Assert(t, container.VolumeMounts == nil)
+	assert.DeepEqual(t, container.Command[4:], []string{
+		"scan", "25", "$sharedir/extension/postgis.control", `extension "postgis"`,
+	})
+
+	script := container.Command[3]
+	assert.Assert(t, cmp.Contains(script, `/usr/pgsql-${new_version}/share`))
+	assert.Assert(t, cmp.Contains(script, `/usr/pgsql-${new_version}/lib`))
+	assert.Assert(t, cmp.Contains(script, `/dev/termination-log`))
+}
+
+func TestMissingModulesMessage(t *testing.T) {
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.Spec.ToPostgresVersion = 16
+
+	assert.Equal(t,
+		missingModulesMessage(upgrade, []string{`library "x"`, `extension "y"`}, "job"),
+		`The image of PostgreSQL 16 is missing 2 modules: extension "y"; library "x"; delete job job to scan again`)
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// worldSnapshotKey is the key of the observed world in the snapshot ConfigMap.
+const worldSnapshotKey = "world.yaml"
+
+// WorldSnapshot is a summary of a [World] that can be read by people. It
+// identifies objects by name rather than repeating their entire content.
+type WorldSnapshot struct {
+	// Request is the value of the annotation that asked for this snapshot.
+	Request string `json:"request"`
+
+	Cluster          string   `json:"cluster"`
+	ClusterNotFound  string   `json:"clusterNotFound,omitempty"`
+	ClusterInstance  string   `json:"clusterInstance,omitempty"`
+	ClusterLeaderPod string   `json:"clusterLeaderPod,omitempty"`
+	ClusterPrimary   string   `json:"clusterPrimary,omitempty"`
+	ClusterReplicas  []string `json:"clusterReplicas,omitempty"`
+	ClusterShutdown  bool     `json:"clusterShutdown"`
+	ReplicasExpected int      `json:"replicasExpected"`
+
+	PatroniDCS        string   `json:"patroniDCS,omitempty"`
+	PatroniConfigMaps []string `json:"patroniConfigMaps,omitempty"`
+	PatroniEndpoints  []string `json:"patroniEndpoints,omitempty"`
+	PreflightPods     []string `json:"preflightPods,omitempty"`
+	ScanPods          []string `json:"scanPods,omitempty"`
+
+	LogicalCluster   string `json:"logicalCluster,omitempty"`
+	LogicalLeaderPod string `json:"logicalLeaderPod,omitempty"`
+
+	// Jobs maps the name of each Job to its role and progress.
+	Jobs map[string]WorldSnapshotJob `json:"jobs,omitempty"`
+
+	// Conditions are those of the PGUpgrade after they were evaluated
+	// against the world above.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// WorldSnapshotJob summarizes one Job in a [WorldSnapshot].
+type WorldSnapshotJob struct {
+	Role      string `json:"role,omitempty"`
+	Active    int32  `json:"active"`
+	Succeeded int32  `json:"succeeded"`
+	Failed    int32  `json:"failed"`
+	Completed bool   `json:"completed"`
+}
+
+// Snapshot returns a summary of w along with the conditions of its upgrade.
+func (w *World) Snapshot() WorldSnapshot {
+	names := func(count int, name func(int) string) []string {
+		var result []string
+		for i := 0; i < count; i++ {
+			result = append(result, name(i))
+		}
+		sort.Strings(result)
+		return result
+	}
+	statefulSet := func(sts *appsv1.StatefulSet) string {
+		if sts == nil {
+			return ""
+		}
+		return sts.Name
+	}
+
+	snapshot := WorldSnapshot{
+		ClusterInstance:  statefulSet(w.ClusterInstance),
+		ClusterPrimary:   statefulSet(w.ClusterPrimary),
+		ClusterShutdown:  w.ClusterShutdown,
+		ReplicasExpected: w.ReplicasExpected,
+		PatroniDCS:       w.PatroniDCS,
+	}
+
+	if w.Upgrade != nil {
+		snapshot.Request = w.Upgrade.Annotations[AnnotationDebugWorld]
+		snapshot.Cluster = w.Upgrade.Spec.PostgresClusterName
+		snapshot.Conditions = append(snapshot.Conditions, w.Upgrade.Status.Conditions...)
+	}
+	if w.ClusterNotFound != nil {
+		snapshot.ClusterNotFound = w.ClusterNotFound.Error()
+	}
+	if w.ClusterLeaderPod != nil {
+		snapshot.ClusterLeaderPod = w.ClusterLeaderPod.Name
+	}
+	if w.LogicalCluster != nil {
+		snapshot.LogicalCluster = w.LogicalCluster.Name
+	}
+	if w.LogicalLeaderPod != nil {
+		snapshot.LogicalLeaderPod = w.LogicalLeaderPod.Name
+	}
+
+	snapshot.ClusterReplicas = names(len(w.ClusterReplicas),
+		func(i int) string { return w.ClusterReplicas[i].Name })
+	snapshot.PatroniConfigMaps = names(len(w.PatroniConfigMaps),
+		func(i int) string { return w.PatroniConfigMaps[i].Name })
+	snapshot.PatroniEndpoints = names(len(w.PatroniEndpoints),
+		func(i int) string { return w.PatroniEndpoints[i].Name })
+	snapshot.PreflightPods = names(len(w.PreflightPods),
+		func(i int) string { return w.PreflightPods[i].Name })
+	snapshot.ScanPods = names(len(w.ScanPods),
+		func(i int) string { return w.ScanPods[i].Name })
+
+	for name, job := range w.Jobs {
+		if snapshot.Jobs == nil {
+			snapshot.Jobs = make(map[string]WorldSnapshotJob, len(w.Jobs))
+		}
+		snapshot.Jobs[name] = WorldSnapshotJob{
+			Role:      job.Labels[LabelRole],
+			Active:    job.Status.Active,
+			Succeeded: job.Status.Succeeded,
+			Failed:    job.Status.Failed,
+			Completed: jobCompleted(job),
+		}
+	}
+
+	return snapshot
+}
+
+// worldSnapshotConfigMap returns the ConfigMap that holds snapshots of the
+// world observed for upgrade.
+func worldSnapshotConfigMap(upgrade *v1beta1.PGUpgrade) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: upgrade.Namespace,
+			Name:      upgrade.Name + "-world",
+		},
+	}
+}
+
+//+kubebuilder:rbac:groups="",resources="configmaps",verbs={create,patch}
+
+// reconcileWorldSnapshot writes a snapshot of world to a ConfigMap when the
+// upgrade has the debug annotation. Nothing changes in the cluster; the
+// ConfigMap is deleted along with the upgrade.
+func (r *PGUpgradeReconciler) reconcileWorldSnapshot(
+	ctx context.Context, upgrade *v1beta1.PGUpgrade, world *World,
+) error {
+	if upgrade.Annotations[AnnotationDebugWorld] == "" || world == nil {
+		return nil
+	}
+
+	snapshot, err := yaml.Marshal(world.Snapshot())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	configmap := worldSnapshotConfigMap(upgrade)
+	configmap.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+	configmap.Labels = map[string]string{
+		LabelPGUpgrade: upgrade.Name,
+		LabelRole:      debugWorld,
+	}
+	configmap.Data = map[string]string{
+		worldSnapshotKey: string(snapshot),
+	}
+	r.setControllerReference(upgrade, configmap)
+
+	return errors.WithStack(r.apply(ctx, configmap))
+}
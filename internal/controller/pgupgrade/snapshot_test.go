@@ -0,0 +1,123 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgupgrade
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestWorldSnapshot(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		b, err := yaml.Marshal(NewWorld().Snapshot())
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `
+cluster: ""
+clusterShutdown: false
+replicasExpected: 0
+request: ""
+`[1:])
+	})
+
+	t.Run("Observed", func(t *testing.T) {
+		upgrade := &v1beta1.PGUpgrade{}
+		upgrade.Name = "up"
+		upgrade.Annotations = map[string]string{AnnotationDebugWorld: "ticket-42"}
+		upgrade.Spec.PostgresClusterName = "hippo"
+		upgrade.Status.Conditions = []metav1.Condition{{
+			Type: ConditionPGUpgradeProgressing, Status: metav1.ConditionFalse,
+			Reason: "PGClusterNotShutdown", Message: "stop it",
+		}}
+
+		world := NewWorld()
+		world.Upgrade = upgrade
+		world.ClusterNotFound = apierrors.NewNotFound(schema.GroupResource{}, "hippo")
+		world.ClusterInstance = &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "hippo-abc"}}
+		world.ClusterLeaderPod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "hippo-abc-0"}}
+		world.ClusterReplicas = []*appsv1.StatefulSet{
+			{ObjectMeta: metav1.ObjectMeta{Name: "hippo-xyz"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "hippo-def"}},
+		}
+		world.ReplicasExpected = 2
+		world.PatroniDCS = PatroniDCSConfigMaps
+		world.PatroniConfigMaps = []*corev1.ConfigMap{
+			{ObjectMeta: metav1.ObjectMeta{Name: "hippo-config"}},
+		}
+		world.Jobs["up-pgdata"] = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{LabelRole: pgUpgrade}},
+			Status: batchv1.JobStatus{
+				Failed: 1,
+				Conditions: []batchv1.JobCondition{{
+					Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+				}},
+			},
+		}
+
+		b, err := yaml.Marshal(world.Snapshot())
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `
+cluster: hippo
+clusterInstance: hippo-abc
+clusterLeaderPod: hippo-abc-0
+clusterNotFound: ' "hippo" not found'
+clusterReplicas:
+- hippo-def
+- hippo-xyz
+clusterShutdown: false
+conditions:
+- lastTransitionTime: null
+  message: stop it
+  reason: PGClusterNotShutdown
+  status: "False"
+  type: Progressing
+jobs:
+  up-pgdata:
+    active: 0
+    completed: true
+    failed: 1
+    role: pgupgrade
+    succeeded: 0
+patroniConfigMaps:
+- hippo-config
+patroniDCS: configmaps
+replicasExpected: 2
+request: ticket-42
+`[1:])
+	})
+}
+
+func TestReconcileWorldSnapshot(t *testing.T) {
+	// Without the annotation, nothing is sent to the API.
+	r := &PGUpgradeReconciler{}
+	upgrade := &v1beta1.PGUpgrade{}
+	assert.NilError(t, r.reconcileWorldSnapshot(context.Background(), upgrade, NewWorld()))
+
+	configmap := worldSnapshotConfigMap(&v1beta1.PGUpgrade{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "up"},
+	})
+	assert.Equal(t, configmap.Namespace, "ns")
+	assert.Equal(t, configmap.Name, "up-world")
+}
@@ -24,7 +24,9 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 
+	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
@@ -34,8 +36,10 @@ import (
 // - https://github.com/kubernetes-sigs/controller-runtime/issues/1454
 //+kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={get,watch}
 //+kubebuilder:rbac:groups="",resources="endpoints",verbs={list,watch}
+//+kubebuilder:rbac:groups="",resources="configmaps",verbs={get,list,watch}
 //+kubebuilder:rbac:groups="batch",resources="jobs",verbs={list,watch}
 //+kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={list,watch}
+//+kubebuilder:rbac:groups="",resources="pods",verbs={list,watch}
 
 func (r *PGUpgradeReconciler) observeWorld(
 	ctx context.Context, upgrade *v1beta1.PGUpgrade,
@@ -65,6 +69,28 @@ func (r *PGUpgradeReconciler) observeWorld(
 		world.populatePatroniEndpoints(endpoints.Items)
 	}
 
+	if err == nil {
+		var configmaps corev1.ConfigMapList
+		err = errors.WithStack(
+			r.List(ctx, &configmaps,
+				client.InNamespace(upgrade.Namespace),
+				client.MatchingLabelsSelector{Selector: selectCluster},
+			))
+		world.populatePatroniConfigMaps(configmaps.Items)
+	}
+
+	if err == nil && world.Cluster != nil {
+		config := &corev1.ConfigMap{}
+		err = errors.WithStack(
+			r.Get(ctx, client.ObjectKey{
+				Namespace: upgrade.Namespace,
+				Name:      upgrade.Spec.PostgresClusterName + "-config",
+			}, config))
+		if err == nil || apierrors.IsNotFound(err) {
+			err = world.populatePatroniDCS(config)
+		}
+	}
+
 	if err == nil {
 		var jobs batchv1.JobList
 		err = errors.WithStack(
@@ -87,6 +113,72 @@ func (r *PGUpgradeReconciler) observeWorld(
 		world.populateStatefulSets(statefulsets.Items)
 	}
 
+	if err == nil {
+		var pods corev1.PodList
+		err = errors.WithStack(
+			r.List(ctx, &pods,
+				client.InNamespace(upgrade.Namespace),
+				client.MatchingLabels{
+					LabelPGUpgrade: upgrade.Name,
+					LabelRole:      preflight,
+				},
+			))
+		world.populatePreflightPods(pods.Items)
+	}
+
+	if err == nil {
+		var pods corev1.PodList
+		err = errors.WithStack(
+			r.List(ctx, &pods,
+				client.InNamespace(upgrade.Namespace),
+				client.MatchingLabels{
+					LabelPGUpgrade: upgrade.Name,
+					LabelRole:      scan,
+				},
+			))
+		world.populateScanPods(pods.Items)
+	}
+
+	if err == nil {
+		var pods corev1.PodList
+		err = errors.WithStack(
+			r.List(ctx, &pods,
+				client.InNamespace(upgrade.Namespace),
+				client.MatchingLabels{
+					LabelCluster: upgrade.Spec.PostgresClusterName,
+					LabelRole:    naming.RolePatroniLeader,
+				},
+			))
+		world.populateLeaderPod(pods.Items)
+	}
+
+	if err == nil && upgrade.Spec.Method == MethodLogicalReplication {
+		target := v1beta1.NewPostgresCluster()
+		err = errors.WithStack(
+			r.Get(ctx, client.ObjectKey{
+				Namespace: upgrade.Namespace,
+				Name:      logicalClusterName(upgrade),
+			}, target))
+		if err == nil {
+			world.LogicalCluster = target
+		} else if apierrors.IsNotFound(err) {
+			err = nil
+		}
+	}
+
+	if err == nil && world.LogicalCluster != nil {
+		var pods corev1.PodList
+		err = errors.WithStack(
+			r.List(ctx, &pods,
+				client.InNamespace(upgrade.Namespace),
+				client.MatchingLabels{
+					LabelCluster: world.LogicalCluster.Name,
+					LabelRole:    naming.RolePatroniLeader,
+				},
+			))
+		world.LogicalLeaderPod = leaderPod(pods.Items)
+	}
+
 	if err == nil {
 		world.populateShutdown()
 	}
@@ -115,11 +207,77 @@ func (w *World) populatePatroniEndpoints(endpoints []corev1.Endpoints) {
 	}
 }
 
+func (w *World) populatePatroniConfigMaps(configmaps []corev1.ConfigMap) {
+	for index, configmap := range configmaps {
+		if configmap.Labels[LabelPatroni] != "" {
+			w.PatroniConfigMaps = append(w.PatroniConfigMaps, &configmaps[index])
+		}
+	}
+}
+
+// populatePatroniDCS identifies the distributed configuration store (DCS) that
+// Patroni uses according to the cluster-wide Patroni configuration in config.
+// A missing configuration is assumed to be Kubernetes Endpoints, the default.
+// - https://patroni.readthedocs.io/en/latest/yaml_configuration.html
+func (w *World) populatePatroniDCS(config *corev1.ConfigMap) error {
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal([]byte(config.Data[patroniConfigKey]), &settings); err != nil {
+		return errors.WithStack(err)
+	}
+
+	w.PatroniDCS = PatroniDCSEndpoints
+	if kubernetes, ok := settings["kubernetes"].(map[string]interface{}); ok {
+		if endpoints, _ := kubernetes["use_endpoints"].(bool); !endpoints {
+			w.PatroniDCS = PatroniDCSConfigMaps
+		}
+		return nil
+	}
+	for _, name := range []string{"consul", "etcd", "etcd3", "exhibitor", "raft", "zookeeper"} {
+		if _, ok := settings[name]; ok {
+			w.PatroniDCS = name
+		}
+	}
+	return nil
+}
+
+// populateLeaderPod assigns the pod of the Patroni leader when its database
+// container is ready.
+func (w *World) populateLeaderPod(pods []corev1.Pod) {
+	w.ClusterLeaderPod = leaderPod(pods)
+}
+
+// leaderPod returns the pod in pods whose database container is ready, if any.
+func leaderPod(pods []corev1.Pod) *corev1.Pod {
+	var leader *corev1.Pod
+	for index, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == ContainerDatabase && status.Ready &&
+				pod.DeletionTimestamp == nil && pod.Labels[LabelInstance] != "" {
+				leader = &pods[index]
+			}
+		}
+	}
+	return leader
+}
+
+func (w *World) populatePreflightPods(pods []corev1.Pod) {
+	for index := range pods {
+		w.PreflightPods = append(w.PreflightPods, &pods[index])
+	}
+}
+
+func (w *World) populateScanPods(pods []corev1.Pod) {
+	for index := range pods {
+		w.ScanPods = append(w.ScanPods, &pods[index])
+	}
+}
+
 // populateStatefulSets assigns
 // a) the expected number of replicas -- the number of StatefulSets that have the expected
 // LabelInstance label, minus 1 (for the primary)
 // b) the primary StatefulSet and replica StatefulSets if the cluster is shutdown.
 // When the cluster is not shutdown, we cannot verify which StatefulSet is the primary.
+// c) any one instance StatefulSet, whether or not the cluster is shutdown.
 func (w *World) populateStatefulSets(statefulSets []appsv1.StatefulSet) {
 	w.ReplicasExpected = -1
 	if w.Cluster != nil {
@@ -127,6 +285,9 @@ func (w *World) populateStatefulSets(statefulSets []appsv1.StatefulSet) {
 		for index, sts := range statefulSets {
 			if sts.Labels[LabelInstance] != "" {
 				w.ReplicasExpected++
+				if w.ClusterInstance == nil {
+					w.ClusterInstance = &statefulSets[index]
+				}
 				if startup != "" {
 					switch sts.Name {
 					case startup:
@@ -169,13 +330,22 @@ type World struct {
 	Upgrade *v1beta1.PGUpgrade
 
 	ClusterNotFound  error
+	ClusterInstance  *appsv1.StatefulSet
+	ClusterLeaderPod *corev1.Pod
 	ClusterPrimary   *appsv1.StatefulSet
 	ClusterReplicas  []*appsv1.StatefulSet
 	ClusterShutdown  bool
 	ReplicasExpected int
 
-	PatroniEndpoints []*corev1.Endpoints
-	Jobs             map[string]*batchv1.Job
+	PatroniDCS        string
+	PatroniConfigMaps []*corev1.ConfigMap
+	PatroniEndpoints  []*corev1.Endpoints
+	Jobs              map[string]*batchv1.Job
+	PreflightPods     []*corev1.Pod
+	ScanPods          []*corev1.Pod
+
+	LogicalCluster   *v1beta1.PostgresCluster
+	LogicalLeaderPod *corev1.Pod
 }
 
 func NewWorld() *World {
@@ -102,6 +102,108 @@ func TestPopulatePatroniEndpoint(t *testing.T) {
 	})
 }
 
+func TestPopulatePatroniConfigMaps(t *testing.T) {
+	configmaps := []corev1.ConfigMap{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					LabelPatroni: "west",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					LabelCluster: "west",
+				},
+			},
+		},
+	}
+
+	world := NewWorld()
+	world.populatePatroniConfigMaps(configmaps)
+
+	// Only the first has the Patroni label.
+	assert.DeepEqual(t, world.PatroniConfigMaps, []*corev1.ConfigMap{
+		&configmaps[0],
+	})
+}
+
+func TestPopulateLeaderPod(t *testing.T) {
+	pod := func(instance string, ready bool) corev1.Pod {
+		var pod corev1.Pod
+		pod.Labels = map[string]string{LabelInstance: instance}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{Name: ContainerDatabase, Ready: ready},
+		}
+		return pod
+	}
+
+	t.Run("NotReady", func(t *testing.T) {
+		world := NewWorld()
+		world.populateLeaderPod([]corev1.Pod{pod("one", false)})
+		assert.Assert(t, world.ClusterLeaderPod == nil)
+	})
+
+	t.Run("Terminating", func(t *testing.T) {
+		pods := []corev1.Pod{pod("one", true)}
+		pods[0].DeletionTimestamp = &metav1.Time{}
+
+		world := NewWorld()
+		world.populateLeaderPod(pods)
+		assert.Assert(t, world.ClusterLeaderPod == nil)
+	})
+
+	t.Run("Ready", func(t *testing.T) {
+		pods := []corev1.Pod{pod("", true), pod("one", true)}
+
+		world := NewWorld()
+		world.populateLeaderPod(pods)
+		assert.Equal(t, world.ClusterLeaderPod, &pods[1])
+	})
+}
+
+func TestPopulatePatroniDCS(t *testing.T) {
+	for _, tt := range []struct {
+		name, config, expected string
+	}{
+		{name: "Missing", config: "", expected: PatroniDCSEndpoints},
+		{
+			name:     "Endpoints",
+			config:   "kubernetes:\n  use_endpoints: true\n",
+			expected: PatroniDCSEndpoints,
+		},
+		{
+			name:     "ConfigMaps",
+			config:   "kubernetes:\n  namespace: ns1\n",
+			expected: PatroniDCSConfigMaps,
+		},
+		{
+			name:     "Etcd",
+			config:   "etcd3:\n  hosts: etcd:2379\n",
+			expected: "etcd3",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &corev1.ConfigMap{}
+			if tt.config != "" {
+				config.Data = map[string]string{"patroni.yaml": tt.config}
+			}
+
+			world := NewWorld()
+			assert.NilError(t, world.populatePatroniDCS(config))
+			assert.Equal(t, world.PatroniDCS, tt.expected)
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		config := &corev1.ConfigMap{Data: map[string]string{"patroni.yaml": "}"}}
+
+		world := NewWorld()
+		assert.ErrorContains(t, world.populatePatroniDCS(config), "yaml")
+	})
+}
+
 func TestPopulateShutdown(t *testing.T) {
 	t.Run("NoCluster", func(t *testing.T) {
 		world := NewWorld()
@@ -198,6 +300,10 @@ func TestPopulateStatefulSets(t *testing.T) {
 		assert.Assert(t, world.ClusterPrimary == nil)
 		assert.Assert(t, world.ClusterReplicas == nil)
 		assert.Assert(t, world.ReplicasExpected == 1)
+
+		// Some instance is known even when the primary is not.
+		assert.Assert(t, world.ClusterInstance != nil)
+		assert.Equal(t, world.ClusterInstance.Name, "the-one")
 	})
 
 	t.Run("PopulatesWithStartupGiven", func(t *testing.T) {
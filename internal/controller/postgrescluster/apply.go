@@ -23,14 +23,17 @@ import (
 
 	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/crunchydata/postgres-operator/internal/kubeapi"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // apply sends an apply patch to object's endpoint in the Kubernetes API and
@@ -72,6 +75,40 @@ func (r *Reconciler) apply(ctx context.Context, object client.Object) error {
 	return err
 }
 
+// applyJob is like [Reconciler.apply] for a Job or CronJob. When policy says
+// to, disrupted pods do not count toward the backoffLimit of its Jobs.
+func (r *Reconciler) applyJob(
+	ctx context.Context, object client.Object, policy *v1beta1.JobPodFailurePolicy,
+) error {
+	ignore := policy != nil && policy.IgnoreDisruptions
+
+	// The pod failure policy of a Job cannot change after the Job is created,
+	// so keep the policy of an existing Job. The field is missing from the
+	// Job type, so read it as unstructured content.
+	if _, ok := object.(*batchv1.Job); ok {
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+
+		err := r.Client.Get(ctx, client.ObjectKeyFromObject(object), existing)
+		if err == nil {
+			_, ignore, _ = unstructured.NestedMap(existing.Object, "spec", "podFailurePolicy")
+		}
+		if client.IgnoreNotFound(err) != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if !ignore {
+		return r.apply(ctx, object)
+	}
+
+	intent, err := kubeapi.IgnoreJobDisruptions(object)
+	if err == nil {
+		err = r.patch(ctx, intent, client.Apply, client.ForceOwnership)
+	}
+	return errors.WithStack(err)
+}
+
 // handleServiceError inspects err for expected Kubernetes API responses to
 // writing a Service. It returns err when it cannot resolve the issue, otherwise
 // it returns nil.
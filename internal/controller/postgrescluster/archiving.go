@@ -0,0 +1,264 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionArchivingHealthy is the type used in a condition to indicate
+	// whether or not the primary is archiving WAL to its pgBackRest
+	// repositories. Backups cannot restore past the point where archiving
+	// stopped working.
+	ConditionArchivingHealthy = "ArchivingHealthy"
+
+	// archiveCheckInterval is how often WAL archiving of the primary instance
+	// is checked.
+	archiveCheckInterval = 5 * time.Minute
+
+	// archiveQueueThreshold is how long a WAL file can wait to be archived
+	// before the archive queue is considered to be growing.
+	archiveQueueThreshold = 15 * time.Minute
+)
+
+// archiveReconciler periodically checks that the primary instance is archiving
+// WAL. It shares its client, field owner, and tracer with the PostgresCluster
+// Reconciler.
+type archiveReconciler struct {
+	*Reconciler
+}
+
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={get,list,watch}
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters/status",verbs={patch}
+
+// setupWithManager adds the WAL archiving controller to the provided runtime
+// manager.
+func (r *archiveReconciler) setupWithManager(mgr manager.Manager) error {
+	return builder.ControllerManagedBy(mgr).
+		Named("postgrescluster-archiving").
+		For(&v1beta1.PostgresCluster{},
+			// Ignore changes to status, including the ones made here.
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups="",resources="pods",verbs={list}
+// +kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={list}
+// +kubebuilder:rbac:groups="",resources="pods/exec",verbs={create}
+
+// Reconcile checks WAL archiving of the primary instance of a PostgresCluster
+// and reports it in the [ConditionArchivingHealthy] condition.
+func (r *archiveReconciler) Reconcile(
+	ctx context.Context, request reconcile.Request) (reconcile.Result, error,
+) {
+	ctx, span := r.Tracer.Start(ctx, "ReconcileArchiving")
+	log := logging.FromContext(ctx)
+	defer span.End()
+
+	cluster := &v1beta1.PostgresCluster{}
+	if err := r.Client.Get(ctx, request.NamespacedName, cluster); err != nil {
+		// NotFound cannot be fixed by requeuing so ignore it.
+		if err = client.IgnoreNotFound(err); err != nil {
+			log.Error(err, "unable to fetch PostgresCluster")
+			span.RecordError(err)
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Leave clusters that are being deleted or whose reconciliation is paused.
+	if cluster.DeletionTimestamp != nil ||
+		(cluster.Spec.Paused != nil && *cluster.Spec.Paused) {
+		return reconcile.Result{}, nil
+	}
+
+	before := cluster.DeepCopy()
+	err := r.reconcileArchiveHealth(ctx, cluster, time.Now())
+	if err != nil {
+		log.Error(err, "checking WAL archiving")
+		span.RecordError(err)
+	}
+
+	// Write only what this controller changed; see [Reconciler.patchStatus].
+	if patchErr := r.patchStatus(ctx, before, cluster); patchErr != nil {
+		log.Error(patchErr, "patching cluster status")
+		if err == nil {
+			err = patchErr
+		}
+	}
+
+	// Errors are retried with the backoff of this controller's workqueue.
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: archiveCheckInterval}, nil
+}
+
+// reconcileArchiveHealth reads the statistics of the WAL archiver on the
+// writable instance of cluster. When the last attempt to archive failed or a
+// WAL file has waited too long, it runs "pgbackrest check" to confirm that
+// WAL is not reaching the repositories. The check switches to a new WAL file,
+// so it runs only when something looks wrong.
+func (r *archiveReconciler) reconcileArchiveHealth(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, now time.Time,
+) error {
+	const container = naming.ContainerDatabase
+
+	// Nothing is archived while archiving is paused; that is reported in
+	// the [ConditionArchiving] condition. Nothing can be archived until
+	// a stanza exists.
+	if pause := cluster.Spec.Backups.PGBackRest.PauseArchiving; (pause != nil && *pause) ||
+		!stanzaCreated(cluster) {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionArchivingHealthy)
+		return nil
+	}
+
+	pods := &corev1.PodList{}
+	runners := &appsv1.StatefulSetList{}
+
+	selector, err := naming.AsSelector(naming.ClusterInstances(cluster.Name))
+	if err == nil {
+		err = errors.WithStack(
+			r.Client.List(ctx, pods,
+				client.InNamespace(cluster.Namespace),
+				client.MatchingLabelsSelector{Selector: selector},
+			))
+	}
+	if err == nil {
+		err = errors.WithStack(
+			r.Client.List(ctx, runners,
+				client.InNamespace(cluster.Namespace),
+				client.MatchingLabelsSelector{Selector: selector},
+			))
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only the primary archives WAL. When there is none, such as in a standby
+	// cluster, check again later.
+	pod, _ := newObservedInstances(cluster, runners.Items, pods.Items).writablePod(container)
+	if pod == nil {
+		return nil
+	}
+
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).WithValues("pod", pod.Name))
+	exec := func(
+		_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		return r.PodExec(pod.Namespace, pod.Name, container, stdin, stdout, stderr, command...)
+	}
+
+	status, err := postgres.ReadArchiverStatus(ctx, exec)
+	if err != nil {
+		return err
+	}
+
+	condition := metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionArchivingHealthy,
+		LastTransitionTime: metav1.NewTime(now),
+		Status:             metav1.ConditionTrue,
+		Reason:             "Archiving",
+		Message:            "WAL is being archived",
+	}
+
+	switch {
+	case status.Failing():
+		condition.Reason = "ArchiveCommandFailing"
+		condition.Message = fmt.Sprintf("The last attempt to archive WAL file %s failed",
+			status.LastFailedWAL)
+
+	case status.OldestReadyTime != nil && now.Sub(*status.OldestReadyTime) > archiveQueueThreshold:
+		condition.Reason = "ArchiveQueueGrowing"
+		condition.Message = fmt.Sprintf("%d WAL files are waiting to be archived, "+
+			"the oldest since %v", status.ReadyFiles,
+			status.OldestReadyTime.UTC().Format(time.RFC3339))
+	}
+
+	if condition.Reason != "Archiving" {
+		if err := pgbackrest.Executor(exec).Check(ctx, pgbackrest.StanzaName(cluster)); err == nil {
+			// The WAL file that failed earlier may have been archived by now.
+			condition.Reason = "Archiving"
+			condition.Message = "WAL is being archived"
+		} else {
+			condition.Status = metav1.ConditionFalse
+			condition.Message += fmt.Sprintf("; pgbackrest check: %v", err)
+		}
+	}
+
+	previous := meta.FindStatusCondition(cluster.Status.Conditions, ConditionArchivingHealthy)
+	push := cluster.Spec.Backups.PGBackRest.ArchivePush
+	switch {
+	case condition.Status == metav1.ConditionFalse &&
+		(previous == nil || previous.Status != metav1.ConditionFalse):
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "WALArchivingFailed",
+			condition.Message)
+
+	// Alert on every check once archiving has been unhealthy for longer than
+	// the spec allows. WAL accumulates on the instance all the while.
+	case condition.Status == metav1.ConditionFalse &&
+		push != nil && push.AlertAfterMinutes != nil &&
+		now.Sub(previous.LastTransitionTime.Time) >=
+			time.Duration(*push.AlertAfterMinutes)*time.Minute:
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "WALArchivingStalled",
+			"WAL archiving has been failing since %v: %s",
+			previous.LastTransitionTime.UTC().Format(time.RFC3339), condition.Message)
+
+	case condition.Status == metav1.ConditionTrue &&
+		previous != nil && previous.Status == metav1.ConditionFalse:
+		r.Recorder.Event(cluster, corev1.EventTypeNormal, "WALArchivingRecovered",
+			condition.Message)
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+
+	return nil
+}
+
+// stanzaCreated returns true when a pgBackRest stanza has been created in any
+// repository of cluster.
+func stanzaCreated(cluster *v1beta1.PostgresCluster) bool {
+	if cluster.Status.PGBackRest != nil {
+		for _, repo := range cluster.Status.PGBackRest.Repos {
+			if repo.StanzaCreated {
+				return true
+			}
+		}
+	}
+	return false
+}
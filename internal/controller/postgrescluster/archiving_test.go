@@ -0,0 +1,173 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestReconcileArchiveHealth(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := testCluster()
+	cluster.Namespace = "ns1"
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace, Name: "hippo-instance1-abcd-0",
+			Labels: map[string]string{
+				naming.LabelCluster:  cluster.Name,
+				naming.LabelInstance: "hippo-instance1-abcd",
+			},
+			Annotations: map[string]string{"status": `{"role":"master"}`},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: naming.ContainerDatabase,
+				State: corev1.ContainerState{
+					Running: new(corev1.ContainerStateRunning),
+				},
+			}},
+		},
+	}
+
+	var archiver string
+	var checkErr error
+	var checks int
+	recorder := events.NewRecorder(t, scheme)
+	reconciler := &archiveReconciler{&Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build(),
+		Recorder: recorder,
+		PodExec: func(namespace, pod, container string, stdin io.Reader, stdout,
+			stderr io.Writer, command ...string) error {
+			if command[0] == "pgbackrest" {
+				assert.DeepEqual(t, command, []string{"pgbackrest", "check", "--stanza=db"})
+				checks++
+				return checkErr
+			}
+			_, _ = stdout.Write([]byte(archiver))
+			return nil
+		},
+	}}
+
+	now := time.Date(2023, time.March, 7, 2, 30, 0, 0, time.UTC)
+
+	// Archiving that looks fine is not checked further.
+	archiver = `{"lastArchivedTime":"2023-03-07T02:29:00+00:00","lastFailedWAL":null,` +
+		`"lastFailedTime":null,"readyFiles":0,"oldestReadyTime":null}`
+	assert.NilError(t, reconciler.reconcileArchiveHealth(ctx, cluster, now))
+	assert.Equal(t, checks, 0)
+
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionArchivingHealthy)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Equal(t, len(recorder.Events), 0)
+
+	// A failure that pgBackRest confirms is reported once.
+	archiver = `{"lastArchivedTime":"2023-03-07T02:00:00+00:00",` +
+		`"lastFailedWAL":"000000010000000000000009",` +
+		`"lastFailedTime":"2023-03-07T02:29:00+00:00","readyFiles":1,` +
+		`"oldestReadyTime":"2023-03-07T02:28:00+00:00"}`
+	checkErr = errors.New("command terminated with exit code 82")
+	assert.NilError(t, reconciler.reconcileArchiveHealth(ctx, cluster, now))
+	assert.NilError(t, reconciler.reconcileArchiveHealth(ctx, cluster, now))
+	assert.Equal(t, checks, 2)
+
+	condition = meta.FindStatusCondition(cluster.Status.Conditions, ConditionArchivingHealthy)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	assert.Equal(t, condition.Reason, "ArchiveCommandFailing")
+	assert.Assert(t, strings.Contains(condition.Message, "000000010000000000000009"), "got %q", condition.Message)
+	assert.Assert(t, strings.Contains(condition.Message, "exit code 82"), "got %q", condition.Message)
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, recorder.Events[0].Reason, "WALArchivingFailed")
+	assert.Assert(t, clusterDegraded(cluster))
+
+	// A WAL file that waits too long is a problem, too.
+	archiver = `{"lastArchivedTime":"2023-03-07T02:00:00+00:00","lastFailedWAL":null,` +
+		`"lastFailedTime":null,"readyFiles":4,"oldestReadyTime":"2023-03-07T02:01:00+00:00"}`
+	assert.NilError(t, reconciler.reconcileArchiveHealth(ctx, cluster, now))
+	condition = meta.FindStatusCondition(cluster.Status.Conditions, ConditionArchivingHealthy)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Reason, "ArchiveQueueGrowing")
+	assert.Assert(t, strings.HasPrefix(condition.Message, "4 WAL files"), "got %q", condition.Message)
+	assert.Equal(t, len(recorder.Events), 1)
+
+	// Failures that last longer than the spec allows are alerted on every check.
+	cluster.Spec.Backups.PGBackRest.ArchivePush = &v1beta1.PGBackRestArchivePush{
+		AlertAfterMinutes: initialize.Int32(30),
+	}
+	assert.NilError(t, reconciler.reconcileArchiveHealth(ctx, cluster, now.Add(29*time.Minute)))
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.NilError(t, reconciler.reconcileArchiveHealth(ctx, cluster, now.Add(30*time.Minute)))
+	assert.NilError(t, reconciler.reconcileArchiveHealth(ctx, cluster, now.Add(35*time.Minute)))
+	assert.Equal(t, len(recorder.Events), 3)
+	assert.Equal(t, recorder.Events[2].Reason, "WALArchivingStalled")
+	assert.Assert(t, strings.Contains(recorder.Events[2].Note, "since 2023-03-07T02:30:00Z"),
+		"got %q", recorder.Events[2].Note)
+
+	// Recovery is reported when pgBackRest can archive again.
+	checkErr = nil
+	assert.NilError(t, reconciler.reconcileArchiveHealth(ctx, cluster, now))
+	condition = meta.FindStatusCondition(cluster.Status.Conditions, ConditionArchivingHealthy)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Equal(t, len(recorder.Events), 4)
+	assert.Equal(t, recorder.Events[3].Reason, "WALArchivingRecovered")
+
+	t.Run("Paused", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.PauseArchiving = initialize.Bool(true)
+
+		assert.NilError(t, reconciler.reconcileArchiveHealth(ctx, cluster, now))
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionArchivingHealthy) == nil)
+	})
+
+	t.Run("NoPrimary", func(t *testing.T) {
+		cluster := testCluster()
+		cluster.Namespace = "ns2"
+		cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}},
+		}
+
+		assert.NilError(t, reconciler.reconcileArchiveHealth(ctx, cluster, now))
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionArchivingHealthy) == nil)
+	})
+}
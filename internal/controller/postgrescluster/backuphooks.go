@@ -0,0 +1,221 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// backupHookTimeout is how long a backup hook may take when its spec
+	// does not say.
+	backupHookTimeout = 30 * time.Second
+
+	// backupHookRetryInterval is how long a scheduled full backup waits
+	// before its failed before hook is tried again.
+	backupHookRetryInterval = time.Minute
+)
+
+// backupHookClient sends the HTTP requests of backup hooks.
+var backupHookClient = &http.Client{}
+
+// backupHookRequest is the body of the HTTP request sent by a backup hook.
+type backupHookRequest struct {
+	Namespace string `json:"namespace"`
+	Cluster   string `json:"cluster"`
+	Repo      string `json:"repo"`
+	Job       string `json:"job"`
+	Phase     string `json:"phase"`
+
+	// Succeeded is whether or not the backup succeeded; it is sent only after
+	// the backup finishes.
+	Succeeded *bool `json:"succeeded,omitempty"`
+}
+
+// fullBackupHooks returns the hooks around the scheduled full backups of
+// cluster, or nil when there are none.
+func fullBackupHooks(cluster *v1beta1.PostgresCluster) *v1beta1.PGBackRestBackupHooks {
+	hooks := cluster.Spec.Backups.PGBackRest.FullBackupHooks
+	if hooks == nil || (hooks.Before == nil && hooks.After == nil) {
+		return nil
+	}
+	return hooks
+}
+
+// +kubebuilder:rbac:groups="batch",resources="jobs",verbs={patch}
+
+// reconcileBackupHooks runs the hooks around scheduled full backups. Their
+// Jobs are created suspended when there are hooks; see
+// [Reconciler.reconcilePGBackRestCronJob]. A suspended Job runs once its
+// before hook succeeds, and the after hook runs once that Job finishes.
+// Progress is recorded in the [naming.PGBackRestBackupHooks] annotation of
+// each Job. It returns how soon to try a failed before hook again.
+func (r *Reconciler) reconcileBackupHooks(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	instances *observedInstances, backupJobs []*batchv1.Job,
+) (time.Duration, error) {
+	hooks := fullBackupHooks(cluster)
+	var retry time.Duration
+
+	for _, job := range backupJobs {
+		if job.GetLabels()[naming.LabelPGBackRestCronJob] != full ||
+			job.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		state := job.GetAnnotations()[naming.PGBackRestBackupHooks]
+		suspended := job.Spec.Suspend != nil && *job.Spec.Suspend
+
+		switch {
+		case suspended && state == "":
+			// Jobs that were suspended before the hooks were removed run now.
+			if hooks != nil && hooks.Before != nil {
+				if err := r.runBackupHook(ctx, cluster, instances, job, hooks.Before,
+					"before", nil); err != nil {
+					r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventBackupHookFailed,
+						"Before full backup Job %q: %v", job.GetName(), err)
+					retry = backupHookRetryInterval
+					continue
+				}
+			}
+
+			before := job.DeepCopy()
+			job.Annotations = naming.Merge(job.Annotations,
+				map[string]string{naming.PGBackRestBackupHooks: "Started"})
+			job.Spec.Suspend = initialize.Bool(false)
+			if err := errors.WithStack(r.patch(ctx, job, client.MergeFrom(before))); err != nil {
+				return retry, err
+			}
+
+		case state == "Started" && jobFinishTime(job) != nil:
+			if hooks != nil && hooks.After != nil {
+				succeeded := jobCompleted(job)
+				if err := r.runBackupHook(ctx, cluster, instances, job, hooks.After,
+					"after", &succeeded); err != nil {
+					r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventBackupHookFailed,
+						"After full backup Job %q: %v", job.GetName(), err)
+				}
+			}
+
+			// The after hook runs once, whether or not it succeeds.
+			before := job.DeepCopy()
+			job.Annotations = naming.Merge(job.Annotations,
+				map[string]string{naming.PGBackRestBackupHooks: "Finished"})
+			if err := errors.WithStack(r.patch(ctx, job, client.MergeFrom(before))); err != nil {
+				return retry, err
+			}
+		}
+	}
+
+	return retry, nil
+}
+
+// runBackupHook sends the HTTP request or executes the SQL of hook for the
+// backup in job. The phase is "before" or "after", and succeeded is whether
+// or not a finished backup succeeded.
+func (r *Reconciler) runBackupHook(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	instances *observedInstances, job *batchv1.Job,
+	hook *v1beta1.PGBackRestBackupHook, phase string, succeeded *bool,
+) error {
+	log := logging.FromContext(ctx)
+
+	timeout := backupHookTimeout
+	if hook.TimeoutSeconds != nil {
+		timeout = time.Duration(*hook.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if hook.URL != nil {
+		body, err := json.Marshal(backupHookRequest{
+			Namespace: cluster.Namespace,
+			Cluster:   cluster.Name,
+			Repo:      job.GetLabels()[naming.LabelPGBackRestRepo],
+			Job:       job.GetName(),
+			Phase:     phase,
+			Succeeded: succeeded,
+		})
+
+		var request *http.Request
+		if err == nil {
+			request, err = http.NewRequestWithContext(ctx, http.MethodPost, *hook.URL,
+				bytes.NewReader(body))
+		}
+		var response *http.Response
+		if err == nil {
+			request.Header.Set("Content-Type", "application/json")
+			response, err = backupHookClient.Do(request)
+		}
+		if err == nil {
+			_ = response.Body.Close()
+			if response.StatusCode < 200 || response.StatusCode > 299 {
+				err = fmt.Errorf("%s responded %s", *hook.URL, response.Status)
+			}
+		}
+		if err == nil {
+			log.V(1).Info("sent backup hook", "job", job.GetName(), "phase", phase)
+		}
+		return err
+	}
+
+	if hook.SQL != nil {
+		pod, _ := instances.writablePod(naming.ContainerDatabase)
+		if pod == nil {
+			return errors.New("there is no running primary")
+		}
+
+		exec := func(_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+			return r.PodExec(pod.Namespace, pod.Name, naming.ContainerDatabase, stdin, stdout, stderr, command...)
+		}
+
+		// Stop at the first error, and limit each statement to the timeout.
+		// The pod exec itself cannot be interrupted.
+		_, stderr, err := postgres.Executor(exec).Exec(ctx, strings.NewReader(
+			"SET statement_timeout = :'hook_timeout';\n"+*hook.SQL),
+			map[string]string{
+				"ON_ERROR_STOP": "on",
+				"hook_timeout":  fmt.Sprint(timeout.Milliseconds()),
+			})
+		if err != nil && stderr != "" {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr))
+		}
+		if err == nil {
+			log.V(1).Info("executed backup hook", "job", job.GetName(), "phase", phase)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestReconcileBackupHooks(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace = "ns1"
+	cluster.Name = "hippo"
+	cluster.Spec.Backups.PGBackRest.FullBackupHooks = &v1beta1.PGBackRestBackupHooks{}
+
+	instances := &observedInstances{forCluster: []*Instance{{
+		Name: "instance",
+		Pods: []*corev1.Pod{{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "ns1",
+				Name:        "pod",
+				Annotations: map[string]string{"status": `{"role":"master"}`},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: naming.ContainerDatabase,
+					State: corev1.ContainerState{
+						Running: new(corev1.ContainerStateRunning),
+					},
+				}},
+			},
+		}},
+	}}}
+
+	suspended := &batchv1.Job{}
+	suspended.Namespace = "ns1"
+	suspended.Name = "hippo-repo1-full-123"
+	suspended.Labels = map[string]string{
+		naming.LabelPGBackRestCronJob: full,
+		naming.LabelPGBackRestRepo:    "repo1",
+	}
+	suspended.Spec.Suspend = initialize.Bool(true)
+
+	finished := suspended.DeepCopy()
+	finished.Annotations = map[string]string{naming.PGBackRestBackupHooks: "Started"}
+	finished.Spec.Suspend = initialize.Bool(false)
+	finished.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	finished.Status.Conditions = []batchv1.JobCondition{{
+		Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+	}}
+
+	reconcile := func(
+		t *testing.T, cluster *v1beta1.PostgresCluster, job *batchv1.Job,
+		exec func(stdin string) error,
+	) (*batchv1.Job, time.Duration, *events.Recorder) {
+		t.Helper()
+		cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(job.DeepCopy()).Build()
+		recorder := events.NewRecorder(t, scheme)
+		r := &Reconciler{Client: cc, Recorder: recorder, PodExec: func(
+			_, _, _ string, stdin io.Reader, _, _ io.Writer, _ ...string,
+		) error {
+			b, _ := io.ReadAll(stdin)
+			return exec(string(b))
+		}}
+
+		// Pass a copy that has the resource version of the stored Job.
+		stored := &batchv1.Job{}
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(job), stored))
+
+		retry, err := r.reconcileBackupHooks(ctx, cluster, instances, []*batchv1.Job{stored})
+		assert.NilError(t, err)
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(job), stored))
+		return stored, retry, recorder
+	}
+
+	t.Run("BeforeURL", func(t *testing.T) {
+		var received backupHookRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, r.Method, http.MethodPost)
+			assert.NilError(t, json.NewDecoder(r.Body).Decode(&received))
+		}))
+		t.Cleanup(server.Close)
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.FullBackupHooks.Before =
+			&v1beta1.PGBackRestBackupHook{URL: initialize.String(server.URL)}
+
+		job, retry, _ := reconcile(t, cluster, suspended, nil)
+		assert.Equal(t, retry, time.Duration(0))
+		assert.Equal(t, *job.Spec.Suspend, false)
+		assert.Equal(t, job.Annotations[naming.PGBackRestBackupHooks], "Started")
+		assert.DeepEqual(t, received, backupHookRequest{
+			Namespace: "ns1", Cluster: "hippo", Repo: "repo1",
+			Job: "hippo-repo1-full-123", Phase: "before",
+		})
+	})
+
+	t.Run("BeforeFails", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(server.Close)
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.FullBackupHooks.Before =
+			&v1beta1.PGBackRestBackupHook{URL: initialize.String(server.URL)}
+
+		job, retry, recorder := reconcile(t, cluster, suspended, nil)
+		assert.Equal(t, retry, backupHookRetryInterval)
+		assert.Equal(t, *job.Spec.Suspend, true)
+		assert.Equal(t, job.Annotations[naming.PGBackRestBackupHooks], "")
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Reason, EventBackupHookFailed)
+		assert.Assert(t, strings.Contains(recorder.Events[0].Note, "503"))
+	})
+
+	t.Run("BeforeSQL", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.FullBackupHooks.Before =
+			&v1beta1.PGBackRestBackupHook{SQL: initialize.String("CHECKPOINT;")}
+
+		var executed string
+		job, _, _ := reconcile(t, cluster, suspended, func(stdin string) error {
+			executed = stdin
+			return nil
+		})
+		assert.Equal(t, *job.Spec.Suspend, false)
+		assert.Assert(t, strings.HasPrefix(executed, "SET statement_timeout"))
+		assert.Assert(t, strings.HasSuffix(executed, "CHECKPOINT;"))
+
+		t.Run("Fails", func(t *testing.T) {
+			job, retry, recorder := reconcile(t, cluster, suspended, func(string) error {
+				return errors.New("boom")
+			})
+			assert.Equal(t, retry, backupHookRetryInterval)
+			assert.Equal(t, *job.Spec.Suspend, true)
+			assert.Equal(t, len(recorder.Events), 1)
+		})
+	})
+
+	t.Run("HooksRemoved", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.FullBackupHooks = nil
+
+		job, _, _ := reconcile(t, cluster, suspended, nil)
+		assert.Equal(t, *job.Spec.Suspend, false)
+	})
+
+	t.Run("After", func(t *testing.T) {
+		var received backupHookRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.NilError(t, json.NewDecoder(r.Body).Decode(&received))
+		}))
+		t.Cleanup(server.Close)
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.FullBackupHooks.After =
+			&v1beta1.PGBackRestBackupHook{URL: initialize.String(server.URL)}
+
+		job, _, _ := reconcile(t, cluster, finished, nil)
+		assert.Equal(t, job.Annotations[naming.PGBackRestBackupHooks], "Finished")
+		assert.Equal(t, received.Phase, "after")
+		assert.Assert(t, received.Succeeded != nil && *received.Succeeded)
+
+		// The hook runs only once.
+		received = backupHookRequest{}
+		reconcile(t, cluster, job, nil)
+		assert.Equal(t, received.Phase, "")
+	})
+
+	t.Run("AfterFails", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.FullBackupHooks.After =
+			&v1beta1.PGBackRestBackupHook{SQL: initialize.String("SELECT 1;")}
+
+		job, _, recorder := reconcile(t, cluster, finished, func(string) error {
+			return errors.New("boom")
+		})
+		assert.Equal(t, job.Annotations[naming.PGBackRestBackupHooks], "Finished")
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Reason, EventBackupHookFailed)
+	})
+
+	t.Run("OtherJobs", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.FullBackupHooks.Before =
+			&v1beta1.PGBackRestBackupHook{SQL: initialize.String("SELECT 1;")}
+
+		incr := suspended.DeepCopy()
+		incr.Labels[naming.LabelPGBackRestCronJob] = "incr"
+
+		job, _, _ := reconcile(t, cluster, incr, func(string) error {
+			t.Fatal("expected no hook")
+			return nil
+		})
+		assert.Equal(t, *job.Spec.Suspend, true)
+	})
+}
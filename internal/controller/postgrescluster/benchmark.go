@@ -0,0 +1,361 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// ConditionBenchmark is the type used in a condition to indicate the progress
+// of a pgbench benchmark of the running cluster.
+const ConditionBenchmark = "Benchmark"
+
+// +kubebuilder:rbac:groups="batch",resources="jobs",verbs={get,create,patch,delete}
+// +kubebuilder:rbac:groups="",resources="pods",verbs={list}
+
+// reconcileBenchmark runs the pgbench benchmark in the spec of cluster each
+// time its "benchmark" annotation changes. The results are reported in
+// cluster.Status, the [ConditionBenchmark] condition, and an event.
+func (r *Reconciler) reconcileBenchmark(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	primaryCertificate *corev1.SecretProjection,
+) error {
+	id := cluster.GetAnnotations()[naming.Benchmark]
+
+	existing := &batchv1.Job{ObjectMeta: naming.BenchmarkJob(cluster)}
+	err := errors.WithStack(client.IgnoreNotFound(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing)))
+	if err != nil {
+		return err
+	}
+
+	// Remove a Job that ran some other benchmark.
+	if existing.GetUID() != "" &&
+		(existing.GetAnnotations()[naming.Benchmark] != id || cluster.Spec.Benchmark == nil) {
+		return errors.WithStack(client.IgnoreNotFound(r.deleteControlled(ctx, cluster, existing)))
+	}
+
+	if cluster.Spec.Benchmark == nil {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionBenchmark)
+		return nil
+	}
+	if id == "" {
+		return nil
+	}
+
+	// Each benchmark runs once. Its results remain after its Job is gone.
+	if status := cluster.Status.Benchmark; status != nil &&
+		status.ID == id && status.CompletionTime != nil {
+		return nil
+	}
+
+	database, err := benchmarkDatabase(cluster)
+	if err == nil && postgres.ReadOnly(cluster) {
+		err = errors.New("the cluster is read-only")
+	}
+	if err == nil && cluster.Spec.Standby != nil && cluster.Spec.Standby.Enabled {
+		err = errors.New("the cluster is a standby")
+	}
+	if err != nil {
+		r.setBenchmarkCondition(cluster, metav1.ConditionFalse, "InvalidBenchmark",
+			"Unable to run benchmark: "+err.Error())
+		return nil
+	}
+
+	switch {
+	case existing.GetUID() != "" && jobCompleted(existing):
+		output, err := r.benchmarkOutput(ctx, existing)
+		if err != nil {
+			return err
+		}
+
+		results := parseBenchmarkOutput(output)
+		results.ID = id
+		results.StartTime = existing.Status.StartTime
+		results.CompletionTime = existing.Status.CompletionTime
+		cluster.Status.Benchmark = results
+
+		r.setBenchmarkCondition(cluster, metav1.ConditionTrue, "BenchmarkComplete",
+			fmt.Sprintf("%s transactions per second with an average latency of %s",
+				results.TransactionsPerSecond, results.AverageLatency))
+		return nil
+
+	case existing.GetUID() != "" && jobFailed(existing):
+		message, err := r.restoreDryRunMessage(ctx, existing)
+		if err == nil {
+			r.setBenchmarkCondition(cluster, metav1.ConditionFalse, "BenchmarkFailed",
+				"Unable to run benchmark: "+message)
+		}
+		return err
+	}
+
+	// Wait for PostgreSQL to accept connections.
+	if !patroni.ClusterBootstrapped(cluster) {
+		return nil
+	}
+
+	job := generateBenchmarkJob(cluster, primaryCertificate, id, database)
+	err = errors.WithStack(r.setControllerReference(cluster, job))
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, job))
+	}
+	if err == nil && existing.GetUID() == "" {
+		cluster.Status.Benchmark = &v1beta1.PostgresBenchmarkStatus{
+			ID: id, StartTime: &metav1.Time{Time: time.Now()},
+		}
+		r.setBenchmarkCondition(cluster, metav1.ConditionUnknown, "BenchmarkRunning",
+			fmt.Sprintf("Running pgbench in database %q", database))
+	}
+	return err
+}
+
+// setBenchmarkCondition sets the [ConditionBenchmark] condition of cluster
+// and records an event when a benchmark finishes.
+func (r *Reconciler) setBenchmarkCondition(cluster *v1beta1.PostgresCluster,
+	status metav1.ConditionStatus, reason, message string) {
+
+	previous := meta.FindStatusCondition(cluster.Status.Conditions, ConditionBenchmark)
+	if previous == nil || previous.Status != status || previous.Reason != reason {
+		switch status {
+		case metav1.ConditionTrue:
+			r.Recorder.Event(cluster, corev1.EventTypeNormal, reason, message)
+		case metav1.ConditionFalse:
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, reason, message)
+		}
+	}
+
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionBenchmark,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// benchmarkOutput returns the output of the Pod of job that succeeded.
+func (r *Reconciler) benchmarkOutput(ctx context.Context, job *batchv1.Job) (string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(job.Namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	for i := range pods.Items {
+		for _, status := range pods.Items[i].Status.ContainerStatuses {
+			if terminated := status.State.Terminated; terminated != nil &&
+				terminated.ExitCode == 0 && terminated.Message != "" {
+				return terminated.Message, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// parseBenchmarkOutput returns the results in the summary that pgbench prints
+// after running transactions. Results that are missing are left empty.
+// - https://www.postgresql.org/docs/current/pgbench.html#id-1.9.4.11.9.4
+func parseBenchmarkOutput(output string) *v1beta1.PostgresBenchmarkStatus {
+	results := new(v1beta1.PostgresBenchmarkStatus)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		name, value, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " = ")
+		if found := strings.TrimPrefix(name,
+			"number of transactions actually processed: "); found != name {
+			// With a fixed number of transactions, this is "processed/expected".
+			value, _, _ = strings.Cut(found, "/")
+			results.Transactions, _ = strconv.ParseInt(value, 10, 64)
+		}
+
+		switch name {
+		case "latency average":
+			results.AverageLatency = value
+
+		// PostgreSQL 13 and older print two lines: one including the time to
+		// connect followed by one excluding it. Keep the last.
+		case "tps":
+			results.TransactionsPerSecond, _, _ = strings.Cut(value, " ")
+		}
+	}
+	return results
+}
+
+// benchmarkDatabase returns the database in which the benchmark of cluster
+// runs, or an error when the spec cannot be used.
+func benchmarkDatabase(cluster *v1beta1.PostgresCluster) (v1beta1.PostgresIdentifier, error) {
+	spec := cluster.Spec.Benchmark
+
+	for _, user := range cluster.Spec.Users {
+		if user.Name != spec.User {
+			continue
+		}
+		switch {
+		case spec.Database != "":
+			return spec.Database, nil
+		case len(user.Databases) > 0:
+			return user.Databases[0], nil
+		}
+		return "", errors.Errorf(
+			"user %q has no databases; set spec.benchmark.database", spec.User)
+	}
+	return "", errors.Errorf("user %q is not defined in spec.users", spec.User)
+}
+
+// benchmarkCommand returns an entrypoint that initializes the pgbench tables
+// at the scale in spec, runs transactions, and then drops the tables. The
+// summary of the run is written to the termination message of the container.
+func benchmarkCommand(spec *v1beta1.PostgresBenchmarkSpec) []string {
+	scale, clients, jobs, duration := int32(10), int32(1), int32(1), int32(60)
+	if spec.Scale != nil {
+		scale = *spec.Scale
+	}
+	if spec.Clients != nil {
+		clients = *spec.Clients
+	}
+	if spec.Jobs != nil {
+		jobs = *spec.Jobs
+	}
+	if spec.DurationSeconds != nil {
+		duration = *spec.DurationSeconds
+	}
+
+	script := strings.Join([]string{
+		`declare -r scale="$1" clients="$2" jobs="$3" duration="$4"`,
+		`trap 'pgbench --initialize --init-steps=d' EXIT`,
+		`pgbench --initialize --quiet --scale="${scale}"`,
+		`pgbench --client="${clients}" --jobs="${jobs}" --time="${duration}" | tee /dev/termination-log`,
+	}, "\n")
+
+	return []string{"bash", "-ceu", "-o", "pipefail", "--", script, "benchmark",
+		fmt.Sprint(scale), fmt.Sprint(clients), fmt.Sprint(jobs), fmt.Sprint(duration)}
+}
+
+// generateBenchmarkJob returns the Job that runs the benchmark in the spec of
+// cluster against database on the primary. The Job is annotated with id, the
+// value of the "benchmark" annotation that asked for it.
+func generateBenchmarkJob(
+	cluster *v1beta1.PostgresCluster, primaryCertificate *corev1.SecretProjection,
+	id string, database v1beta1.PostgresIdentifier,
+) *batchv1.Job {
+	spec := cluster.Spec.Benchmark
+	primary := naming.ClusterPrimaryService(cluster)
+	secret := naming.PostgresUserSecret(cluster, string(spec.User)).Name
+
+	container := corev1.Container{
+		Name:            naming.ContainerDatabase,
+		Command:         benchmarkCommand(spec),
+		Image:           config.PostgresContainerImage(cluster),
+		ImagePullPolicy: cluster.Spec.ImagePullPolicy,
+		Resources:       spec.Resources,
+		SecurityContext: initialize.RestrictedSecurityContext(),
+
+		// Kubernetes reports the last lines of output when the benchmark fails.
+		// - https://kubernetes.io/docs/tasks/debug/debug-application/determine-reason-pod-failure/
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+
+		Env: []corev1.EnvVar{
+			{Name: "PGHOST", Value: primary.Name + "." + primary.Namespace + ".svc"},
+			{Name: "PGPORT", Value: fmt.Sprint(*cluster.Spec.Port)},
+			{Name: "PGUSER", Value: string(spec.User)},
+			{Name: "PGPASSWORD", ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+					Key:                  "password",
+				},
+			}},
+			{Name: "PGDATABASE", Value: string(database)},
+			{Name: "PGSSLMODE", Value: "verify-full"},
+			{Name: "PGSSLROOTCERT", Value: naming.CertMountPath + "/ca.crt"},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: naming.CertVolume, MountPath: naming.CertMountPath, ReadOnly: true},
+		},
+	}
+
+	labels := naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelRole:    naming.RoleBenchmark,
+		})
+
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil()),
+			Labels:      labels,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{container},
+
+			// Disable environment variables for services other than the Kubernetes API.
+			// - https://docs.k8s.io/concepts/services-networking/connect-applications-service/#accessing-the-service
+			EnableServiceLinks: initialize.Bool(false),
+
+			// Set the image pull secrets, if any exist. This is set here rather
+			// than using the service account due to the lack of propagation to
+			// existing pods when the CRD is updated.
+			// - https://issue.k8s.io/88456
+			ImagePullSecrets: cluster.Spec.ImagePullSecrets,
+
+			RestartPolicy:   corev1.RestartPolicyNever,
+			SecurityContext: initialize.PodSecurityContext(),
+
+			Volumes: []corev1.Volume{
+				{Name: naming.CertVolume, VolumeSource: corev1.VolumeSource{
+					Projected: &corev1.ProjectedVolumeSource{
+						Sources: []corev1.VolumeProjection{
+							{Secret: logicalBackupAuthority(primaryCertificate)},
+						},
+					},
+				}},
+			},
+		},
+	}
+
+	job := &batchv1.Job{ObjectMeta: naming.BenchmarkJob(cluster)}
+	job.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+	job.Annotations = naming.Merge(template.Annotations,
+		map[string]string{naming.Benchmark: id})
+	job.Labels = template.Labels
+
+	// A benchmark that fails is not run again; its results would not be
+	// comparable to others.
+	job.Spec = batchv1.JobSpec{BackoffLimit: initialize.Int32(0), Template: template}
+	return job
+}
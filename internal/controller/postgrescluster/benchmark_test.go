@@ -0,0 +1,356 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestParseBenchmarkOutput(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		assert.DeepEqual(t, parseBenchmarkOutput(""), &v1beta1.PostgresBenchmarkStatus{})
+	})
+
+	t.Run("PostgreSQL15", func(t *testing.T) {
+		results := parseBenchmarkOutput(strings.TrimSpace(`
+pgbench (15.2)
+transaction type: <builtin: TPC-B (sort of)>
+scaling factor: 10
+query mode: simple
+number of clients: 4
+number of threads: 2
+maximum number of tries: 1
+duration: 60 s
+number of transactions actually processed: 61524
+number of failed transactions: 0 (0.000%)
+latency average = 3.901 ms
+initial connection time = 21.034 ms
+tps = 1025.358121 (without initial connection time)
+		`))
+
+		assert.DeepEqual(t, results, &v1beta1.PostgresBenchmarkStatus{
+			Transactions:          61524,
+			TransactionsPerSecond: "1025.358121",
+			AverageLatency:        "3.901 ms",
+		})
+	})
+
+	t.Run("PostgreSQL13", func(t *testing.T) {
+		results := parseBenchmarkOutput(strings.TrimSpace(`
+transaction type: <builtin: TPC-B (sort of)>
+scaling factor: 10
+query mode: simple
+number of clients: 1
+number of threads: 1
+duration: 60 s
+number of transactions actually processed: 12345
+latency average = 4.861 ms
+tps = 205.701234 (including connections establishing)
+tps = 205.734567 (excluding connections establishing)
+		`))
+
+		assert.DeepEqual(t, results, &v1beta1.PostgresBenchmarkStatus{
+			Transactions:          12345,
+			TransactionsPerSecond: "205.734567",
+			AverageLatency:        "4.861 ms",
+		})
+	})
+}
+
+func TestBenchmarkDatabase(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.Users = []v1beta1.PostgresUserSpec{
+		{Name: "app", Databases: []v1beta1.PostgresIdentifier{"one", "two"}},
+		{Name: "nodb"},
+	}
+
+	for _, tt := range []struct {
+		name, user, database string
+		expected, err        string
+	}{
+		{name: "FirstDatabase", user: "app", expected: "one"},
+		{name: "Specified", user: "app", database: "other", expected: "other"},
+		{name: "SpecifiedNoDatabases", user: "nodb", database: "other", expected: "other"},
+		{name: "NoDatabases", user: "nodb", err: `user "nodb" has no databases`},
+		{name: "MissingUser", user: "missing", err: `user "missing" is not defined`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Benchmark = &v1beta1.PostgresBenchmarkSpec{
+				User:     v1beta1.PostgresIdentifier(tt.user),
+				Database: v1beta1.PostgresIdentifier(tt.database),
+			}
+
+			database, err := benchmarkDatabase(cluster)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+			} else {
+				assert.NilError(t, err)
+				assert.Equal(t, string(database), tt.expected)
+			}
+		})
+	}
+}
+
+func TestBenchmarkCommand(t *testing.T) {
+	command := benchmarkCommand(&v1beta1.PostgresBenchmarkSpec{})
+	assert.DeepEqual(t, command[:5], []string{"bash", "-ceu", "-o", "pipefail", "--"})
+	assert.DeepEqual(t, command[6:], []string{"benchmark", "10", "1", "1", "60"})
+
+	script := command[5]
+	assert.Assert(t, strings.Contains(script, "pgbench --initialize --quiet"))
+	assert.Assert(t, strings.Contains(script, "tee /dev/termination-log"))
+	assert.Assert(t, strings.Contains(script, "--init-steps=d"),
+		"expected the tables to be dropped")
+
+	command = benchmarkCommand(&v1beta1.PostgresBenchmarkSpec{
+		Scale: initialize.Int32(100), Clients: initialize.Int32(8),
+		Jobs: initialize.Int32(4), DurationSeconds: initialize.Int32(300),
+	})
+	assert.DeepEqual(t, command[6:], []string{"benchmark", "100", "8", "4", "300"})
+}
+
+func TestGenerateBenchmarkJob(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Spec.Port = initialize.Int32(5432)
+	cluster.Spec.Benchmark = &v1beta1.PostgresBenchmarkSpec{User: "app"}
+
+	job := generateBenchmarkJob(cluster, nil, "one", "appdb")
+	assert.Equal(t, job.Name, "hippo-benchmark")
+	assert.Equal(t, job.Annotations[naming.Benchmark], "one")
+	assert.Equal(t, job.Labels[naming.LabelRole], naming.RoleBenchmark)
+	assert.Equal(t, *job.Spec.BackoffLimit, int32(0))
+
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, container.TerminationMessagePolicy,
+		corev1.TerminationMessageFallbackToLogsOnError)
+
+	env := map[string]corev1.EnvVar{}
+	for _, variable := range container.Env {
+		env[variable.Name] = variable
+	}
+	assert.Equal(t, env["PGHOST"].Value, "hippo-primary.ns1.svc")
+	assert.Equal(t, env["PGUSER"].Value, "app")
+	assert.Equal(t, env["PGDATABASE"].Value, "appdb")
+	assert.Equal(t, env["PGSSLMODE"].Value, "verify-full")
+	assert.Equal(t, env["PGPASSWORD"].ValueFrom.SecretKeyRef.Name, "hippo-pguser-app")
+}
+
+func TestReconcileBenchmark(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name, cluster.UID = "ns1", "hippo", "cluster-uid"
+	cluster.Annotations = map[string]string{naming.Benchmark: "one"}
+	cluster.Spec.Users = []v1beta1.PostgresUserSpec{
+		{Name: "app", Databases: []v1beta1.PostgresIdentifier{"app"}},
+	}
+	cluster.Spec.Benchmark = &v1beta1.PostgresBenchmarkSpec{User: "app"}
+
+	job := &batchv1.Job{ObjectMeta: naming.BenchmarkJob(cluster)}
+	job.UID = "job-uid" // The fake client does not assign one.
+	job.Annotations = map[string]string{naming.Benchmark: "one"}
+	job.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"job-name": job.Name},
+	}
+	job.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: v1beta1.GroupVersion.String(), Kind: "PostgresCluster",
+		Name: cluster.Name, UID: cluster.UID, Controller: initialize.Bool(true),
+	}}
+
+	condition := func(cluster *v1beta1.PostgresCluster) *metav1.Condition {
+		return meta.FindStatusCondition(cluster.Status.Conditions, ConditionBenchmark)
+	}
+
+	t.Run("NotRequested", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Annotations = nil
+
+		r := &Reconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+		assert.NilError(t, r.reconcileBenchmark(ctx, cluster, nil))
+		assert.Assert(t, condition(cluster) == nil)
+		assert.Assert(t, cluster.Status.Benchmark == nil)
+	})
+
+	t.Run("NotBootstrapped", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+
+		cc := fake.NewClientBuilder().WithScheme(scheme).Build()
+		r := &Reconciler{Client: cc}
+		assert.NilError(t, r.reconcileBenchmark(ctx, cluster, nil))
+		assert.Assert(t, cluster.Status.Benchmark == nil)
+
+		err := cc.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		assert.Assert(t, client.IgnoreNotFound(err) == nil && err != nil,
+			"expected no Job, got %v", err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, tt := range []struct {
+			name    string
+			mutate  func(*v1beta1.PostgresCluster)
+			message string
+		}{
+			{
+				name:    "MissingUser",
+				mutate:  func(c *v1beta1.PostgresCluster) { c.Spec.Users = nil },
+				message: `user "app" is not defined`,
+			},
+			{
+				name:    "ReadOnly",
+				mutate:  func(c *v1beta1.PostgresCluster) { c.Spec.ReadOnly = initialize.Bool(true) },
+				message: "read-only",
+			},
+			{
+				name: "Standby",
+				mutate: func(c *v1beta1.PostgresCluster) {
+					c.Spec.Standby = &v1beta1.PostgresStandbySpec{Enabled: true}
+				},
+				message: "standby",
+			},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				cluster := cluster.DeepCopy()
+				tt.mutate(cluster)
+
+				recorder := events.NewRecorder(t, scheme)
+				r := &Reconciler{
+					Client:   fake.NewClientBuilder().WithScheme(scheme).Build(),
+					Recorder: recorder,
+				}
+				assert.NilError(t, r.reconcileBenchmark(ctx, cluster, nil))
+				assert.Equal(t, condition(cluster).Status, metav1.ConditionFalse)
+				assert.Equal(t, condition(cluster).Reason, "InvalidBenchmark")
+				assert.Assert(t, strings.Contains(condition(cluster).Message, tt.message))
+				assert.Equal(t, len(recorder.Events), 1)
+			})
+		}
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		complete := job.DeepCopy()
+		started := metav1.NewTime(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+		finished := metav1.NewTime(started.Add(time.Minute))
+		complete.Status.StartTime = &started
+		complete.Status.CompletionTime = &finished
+		complete.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+		}}
+
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = job.Namespace, job.Name+"-xyz"
+		pod.Labels = map[string]string{"job-name": job.Name}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				ExitCode: 0,
+				Message: "number of transactions actually processed: 600\n" +
+					"latency average = 100.000 ms\n" +
+					"tps = 10.000000 (without initial connection time)\n",
+			}},
+		}}
+
+		recorder := events.NewRecorder(t, scheme)
+		r := &Reconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(complete, pod).Build(),
+			Recorder: recorder,
+		}
+		assert.NilError(t, r.reconcileBenchmark(ctx, cluster, nil))
+		assert.Equal(t, condition(cluster).Status, metav1.ConditionTrue)
+		assert.Equal(t, condition(cluster).Reason, "BenchmarkComplete")
+		assert.Assert(t, strings.Contains(condition(cluster).Message, "10.000000 transactions per second"))
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeNormal)
+
+		assert.Assert(t, cluster.Status.Benchmark != nil)
+		assert.Equal(t, cluster.Status.Benchmark.ID, "one")
+		assert.Equal(t, cluster.Status.Benchmark.Transactions, int64(600))
+		assert.Equal(t, cluster.Status.Benchmark.AverageLatency, "100.000 ms")
+		assert.Assert(t, cluster.Status.Benchmark.StartTime.Equal(&started))
+		assert.Assert(t, cluster.Status.Benchmark.CompletionTime.Equal(&finished))
+
+		// The results remain after the Job is gone.
+		assert.NilError(t, r.Client.Delete(ctx, complete))
+		assert.NilError(t, r.reconcileBenchmark(ctx, cluster, nil))
+		assert.Equal(t, cluster.Status.Benchmark.Transactions, int64(600))
+		assert.Equal(t, len(recorder.Events), 1)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		failed := job.DeepCopy()
+		failed.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobFailed, Status: corev1.ConditionTrue,
+		}}
+
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = job.Namespace, job.Name+"-xyz"
+		pod.Labels = map[string]string{"job-name": job.Name}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				ExitCode: 1,
+				Message:  `pgbench: error: permission denied for schema public`,
+			}},
+		}}
+
+		recorder := events.NewRecorder(t, scheme)
+		r := &Reconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(failed, pod).Build(),
+			Recorder: recorder,
+		}
+		assert.NilError(t, r.reconcileBenchmark(ctx, cluster, nil))
+		assert.Equal(t, condition(cluster).Status, metav1.ConditionFalse)
+		assert.Equal(t, condition(cluster).Reason, "BenchmarkFailed")
+		assert.Assert(t, strings.Contains(condition(cluster).Message, "permission denied"))
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+	})
+
+	t.Run("ReplacedByAnotherBenchmark", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Annotations[naming.Benchmark] = "two"
+
+		cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(job.DeepCopy()).Build()
+		r := &Reconciler{Client: cc}
+		assert.NilError(t, r.reconcileBenchmark(ctx, cluster, nil))
+
+		err := cc.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		assert.Assert(t, client.IgnoreNotFound(err) == nil && err != nil,
+			"expected the Job to be deleted, got %v", err)
+	})
+}
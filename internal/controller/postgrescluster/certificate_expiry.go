@@ -0,0 +1,222 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/internal/pki"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// ConditionCertificatesExpiringSoon is the type used in a condition to
+// indicate whether or not any certificate of a PostgresCluster is past the
+// time it should have been renewed. Certificates generated by PGO are renewed
+// well before then, so this usually means that rotation is stuck, that the
+// clock of PGO is wrong, or that a custom certificate needs attention.
+const ConditionCertificatesExpiringSoon = "CertificatesExpiringSoon"
+
+var metricCertificateExpiry = prometheus.NewDesc(
+	"pgo_certificate_expiry_days",
+	"Days until each certificate of a PostgresCluster expires. It is negative after the certificate expires.",
+	[]string{"namespace", "cluster", "certificate"}, nil)
+
+// certificateExpirations remembers when the certificates of each cluster
+// expire. They are reported as Prometheus metrics relative to the time of
+// each scrape.
+type certificateExpirations struct {
+	mu       sync.Mutex
+	clusters map[types.NamespacedName]map[string]time.Time
+}
+
+var _ prometheus.Collector = (*certificateExpirations)(nil)
+
+// forget removes the certificates of the cluster identified by key.
+func (e *certificateExpirations) forget(key types.NamespacedName) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.clusters, key)
+}
+
+// remember replaces the certificates of the cluster identified by key.
+func (e *certificateExpirations) remember(
+	key types.NamespacedName, certificates map[string]pki.Certificate,
+) {
+	if e == nil {
+		return
+	}
+	expirations := make(map[string]time.Time, len(certificates))
+	for name, certificate := range certificates {
+		expirations[name] = certificate.NotAfter()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.clusters == nil {
+		e.clusters = make(map[types.NamespacedName]map[string]time.Time)
+	}
+	e.clusters[key] = expirations
+}
+
+// Describe implements [prometheus.Collector].
+func (*certificateExpirations) Describe(descriptions chan<- *prometheus.Desc) {
+	descriptions <- metricCertificateExpiry
+}
+
+// Collect implements [prometheus.Collector].
+func (e *certificateExpirations) Collect(metrics chan<- prometheus.Metric) {
+	now := time.Now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for key, expirations := range e.clusters {
+		for name, expiration := range expirations {
+			metrics <- prometheus.MustNewConstMetric(metricCertificateExpiry,
+				prometheus.GaugeValue, expiration.Sub(now).Hours()/24,
+				key.Namespace, key.Name, name)
+		}
+	}
+}
+
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={get}
+
+// clusterCertificates returns the certificates that cluster uses by the name
+// reported in metrics and conditions. These are the root certificate
+// authority of its namespace, the cluster and replication certificates, which
+// may be custom, and the pgBackRest certificates. Secrets that do not exist
+// are skipped, as are keys that do not hold a certificate.
+func (r *Reconciler) clusterCertificates(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) (map[string]pki.Certificate, error) {
+	certificates := make(map[string]pki.Certificate)
+
+	// read returns the Secret with meta or nil when it does not exist.
+	read := func(meta metav1.ObjectMeta) (*corev1.Secret, error) {
+		secret := &corev1.Secret{ObjectMeta: meta}
+		err := errors.WithStack(r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret))
+		if err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		return secret, nil
+	}
+
+	// add parses the certificate in key of secret and adds it as name.
+	add := func(name string, secret *corev1.Secret, key string) {
+		var certificate pki.Certificate
+		if secret != nil && certificate.UnmarshalText(secret.Data[key]) == nil {
+			certificates[name] = certificate
+		}
+	}
+
+	// keyOf returns the key of a custom Secret that is projected as path.
+	keyOf := func(projection *corev1.SecretProjection, path string) string {
+		for _, item := range projection.Items {
+			if item.Path == path {
+				return item.Key
+			}
+		}
+		return path
+	}
+
+	root, err := read(metav1.ObjectMeta{
+		Namespace: cluster.Namespace, Name: naming.RootCertSecret,
+	})
+	add("root", root, "root.crt")
+
+	var secret *corev1.Secret
+	if err == nil {
+		meta, key := naming.PostgresTLSSecret(cluster), clusterCertFile
+		if custom := cluster.Spec.CustomTLSSecret; custom != nil {
+			meta.Name, key = custom.Name, keyOf(custom, clusterCertFile)
+		}
+		secret, err = read(meta)
+		add("cluster", secret, key)
+	}
+	if err == nil {
+		meta, key := naming.ReplicationClientCertSecret(cluster), naming.ReplicationCert
+		if custom := cluster.Spec.CustomReplicationClientTLSSecret; custom != nil {
+			meta.Name, key = custom.Name, keyOf(custom, naming.ReplicationCert)
+		}
+		secret, err = read(meta)
+		add("replication", secret, key)
+	}
+	if err == nil {
+		secret, err = read(naming.PGBackRestSecret(cluster))
+		if secret != nil {
+			for name, certificate := range pgbackrest.Certificates(secret) {
+				certificates[name] = certificate
+			}
+		}
+	}
+
+	return certificates, err
+}
+
+// certificateExpiryCondition returns the [ConditionCertificatesExpiringSoon]
+// condition of cluster for its certificates at now.
+func certificateExpiryCondition(
+	cluster *v1beta1.PostgresCluster, certificates map[string]pki.Certificate, now time.Time,
+) metav1.Condition {
+	condition := metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionCertificatesExpiringSoon,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Current",
+		Message:            "No certificates are past their renewal time",
+	}
+
+	var expired, overdue []string
+	for name, certificate := range certificates {
+		switch {
+		case !now.Before(certificate.NotAfter()):
+			expired = append(expired, fmt.Sprintf("%q expired at %s",
+				name, certificate.NotAfter().UTC().Format(time.RFC3339)))
+		case !now.Before(certificate.RenewalTime()):
+			overdue = append(overdue, fmt.Sprintf("%q expires at %s",
+				name, certificate.NotAfter().UTC().Format(time.RFC3339)))
+		}
+	}
+	sort.Strings(expired)
+	sort.Strings(overdue)
+
+	if len(expired)+len(overdue) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "RenewalOverdue"
+		if len(expired) > 0 {
+			condition.Reason = "Expired"
+		}
+		condition.Message = "Certificates are past their renewal time: " +
+			strings.Join(append(expired, overdue...), "; ")
+	}
+	return condition
+}
@@ -0,0 +1,214 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pgoruntime "github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pki"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestCertificateExpirations(t *testing.T) {
+	root, err := pki.NewRootCertificateAuthority()
+	assert.NilError(t, err)
+
+	// A nil value does nothing.
+	var none *certificateExpirations
+	none.remember(types.NamespacedName{}, nil)
+	none.forget(types.NamespacedName{})
+
+	expirations := new(certificateExpirations)
+	assert.Equal(t, testutil.CollectAndCount(expirations), 0)
+
+	hippo := types.NamespacedName{Namespace: "ns1", Name: "hippo"}
+	rhino := types.NamespacedName{Namespace: "ns2", Name: "rhino"}
+	expirations.remember(hippo, map[string]pki.Certificate{"root": root.Certificate})
+	expirations.remember(rhino, map[string]pki.Certificate{
+		"root": root.Certificate, "cluster": root.Certificate,
+	})
+	assert.Equal(t, testutil.CollectAndCount(expirations, "pgo_certificate_expiry_days"), 3)
+
+	// Values are days from the time of the scrape.
+	metrics := make(chan prometheus.Metric, 10)
+	most := time.Until(root.Certificate.NotAfter()).Hours() / 24
+	expirations.Collect(metrics)
+	least := time.Until(root.Certificate.NotAfter()).Hours() / 24
+	close(metrics)
+	for metric := range metrics {
+		days := testutil.ToFloat64(constCollector{metric})
+		assert.Assert(t, days >= least && days <= most, "got %v", days)
+	}
+
+	// Certificates of a cluster are replaced and forgotten together.
+	expirations.remember(rhino, map[string]pki.Certificate{"root": root.Certificate})
+	assert.Equal(t, testutil.CollectAndCount(expirations), 2)
+	expirations.forget(hippo)
+	assert.Equal(t, testutil.CollectAndCount(expirations), 1)
+}
+
+// constCollector reports one metric.
+type constCollector struct{ prometheus.Metric }
+
+func (c constCollector) Describe(descriptions chan<- *prometheus.Desc) {
+	descriptions <- c.Desc()
+}
+
+func (c constCollector) Collect(metrics chan<- prometheus.Metric) {
+	metrics <- c.Metric
+}
+
+func TestClusterCertificates(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := pgoruntime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	root, err := pki.NewRootCertificateAuthority()
+	assert.NilError(t, err)
+	leaf, err := root.GenerateLeafCertificate("leaf", nil)
+	assert.NilError(t, err)
+
+	rootPEM, err := root.Certificate.MarshalText()
+	assert.NilError(t, err)
+	leafPEM, err := leaf.Certificate.MarshalText()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+
+	t.Run("Empty", func(t *testing.T) {
+		r := &Reconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+
+		certificates, err := r.clusterCertificates(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(certificates), 0)
+	})
+
+	t.Run("Generated", func(t *testing.T) {
+		rootSecret := &corev1.Secret{Data: map[string][]byte{"root.crt": rootPEM}}
+		rootSecret.Namespace, rootSecret.Name = cluster.Namespace, naming.RootCertSecret
+
+		clusterSecret := &corev1.Secret{ObjectMeta: naming.PostgresTLSSecret(cluster)}
+		clusterSecret.Data = map[string][]byte{"tls.crt": leafPEM}
+
+		replicationSecret := &corev1.Secret{ObjectMeta: naming.ReplicationClientCertSecret(cluster)}
+		replicationSecret.Data = map[string][]byte{"tls.crt": []byte("garbage")}
+
+		backrestSecret := &corev1.Secret{ObjectMeta: naming.PGBackRestSecret(cluster)}
+		backrestSecret.Data = map[string][]byte{"pgbackrest-repo-host.crt": leafPEM}
+
+		r := &Reconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			rootSecret, clusterSecret, replicationSecret, backrestSecret).Build()}
+
+		certificates, err := r.clusterCertificates(ctx, cluster)
+		assert.NilError(t, err)
+
+		// Malformed certificates are skipped.
+		assert.Equal(t, len(certificates), 3)
+		assert.Assert(t, certificates["root"].Equal(root.Certificate))
+		assert.Assert(t, certificates["cluster"].Equal(leaf.Certificate))
+		assert.Assert(t, certificates["pgbackrest-repo-host"].Equal(leaf.Certificate))
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.CustomTLSSecret = &corev1.SecretProjection{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "custom-tls"},
+			Items:                []corev1.KeyToPath{{Key: "server.pem", Path: "tls.crt"}},
+		}
+		cluster.Spec.CustomReplicationClientTLSSecret = &corev1.SecretProjection{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "custom-replication"},
+		}
+
+		custom := &corev1.Secret{Data: map[string][]byte{"server.pem": leafPEM}}
+		custom.Namespace, custom.Name = cluster.Namespace, "custom-tls"
+
+		replication := &corev1.Secret{Data: map[string][]byte{"tls.crt": rootPEM}}
+		replication.Namespace, replication.Name = cluster.Namespace, "custom-replication"
+
+		r := &Reconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			custom, replication).Build()}
+
+		certificates, err := r.clusterCertificates(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(certificates), 2)
+		assert.Assert(t, certificates["cluster"].Equal(leaf.Certificate))
+		assert.Assert(t, certificates["replication"].Equal(root.Certificate))
+	})
+}
+
+func TestCertificateExpiryCondition(t *testing.T) {
+	root, err := pki.NewRootCertificateAuthority()
+	assert.NilError(t, err)
+	leaf, err := root.GenerateLeafCertificate("leaf", nil)
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Generation = 3
+
+	certificates := map[string]pki.Certificate{
+		"root":    root.Certificate,
+		"cluster": leaf.Certificate,
+	}
+
+	t.Run("Current", func(t *testing.T) {
+		condition := certificateExpiryCondition(cluster, certificates, time.Now())
+		assert.Equal(t, condition.Type, ConditionCertificatesExpiringSoon)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "Current")
+		assert.Equal(t, condition.ObservedGeneration, int64(3))
+
+		// There is nothing to expire without certificates.
+		condition = certificateExpiryCondition(cluster, nil, time.Now())
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	})
+
+	t.Run("RenewalOverdue", func(t *testing.T) {
+		now := leaf.Certificate.RenewalTime().Add(time.Hour)
+
+		condition := certificateExpiryCondition(cluster, certificates, now)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.Reason, "RenewalOverdue")
+		assert.Equal(t, condition.Message,
+			`Certificates are past their renewal time: "cluster" expires at `+
+				leaf.Certificate.NotAfter().UTC().Format(time.RFC3339))
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		now := root.Certificate.RenewalTime().Add(time.Hour)
+
+		condition := certificateExpiryCondition(cluster, certificates, now)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.Reason, "Expired")
+		assert.Equal(t, condition.Message,
+			`Certificates are past their renewal time: "cluster" expired at `+
+				leaf.Certificate.NotAfter().UTC().Format(time.RFC3339)+
+				`; "root" expires at `+
+				root.Certificate.NotAfter().UTC().Format(time.RFC3339))
+	})
+}
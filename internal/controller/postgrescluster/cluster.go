@@ -17,7 +17,9 @@ package postgrescluster
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
@@ -202,16 +204,25 @@ func (r *Reconciler) generateClusterReplicaService(
 	service.Annotations = naming.Merge(
 		cluster.Spec.Metadata.GetAnnotationsOrNil())
 	service.Labels = naming.Merge(
-		cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.Metadata.GetLabelsOrNil())
+
+	if spec := cluster.Spec.ReplicaService; spec != nil {
+		service.Annotations = naming.Merge(service.Annotations,
+			spec.Metadata.GetAnnotationsOrNil())
+		service.Labels = naming.Merge(service.Labels,
+			spec.Metadata.GetLabelsOrNil())
+	}
+
+	// add our labels last so they aren't overwritten
+	service.Labels = naming.Merge(service.Labels,
 		map[string]string{
 			naming.LabelCluster: cluster.Name,
 			naming.LabelRole:    naming.RoleReplica,
 		})
 
-	// Allocate an IP address and let Kubernetes manage the Endpoints by
-	// selecting Pods with the Patroni replica role.
+	// Allocate an IP address and/or node port and let Kubernetes manage the
+	// Endpoints by selecting Pods with the Patroni replica role.
 	// - https://docs.k8s.io/concepts/services-networking/service/#defining-a-service
-	service.Spec.Type = corev1.ServiceTypeClusterIP
 	service.Spec.Selector = map[string]string{
 		naming.LabelCluster: cluster.Name,
 		naming.LabelRole:    naming.RolePatroniReplica,
@@ -220,12 +231,31 @@ func (r *Reconciler) generateClusterReplicaService(
 	// The TargetPort must be the name (not the number) of the PostgreSQL
 	// ContainerPort. This name allows the port number to differ between Pods,
 	// which can happen during a rolling update.
-	service.Spec.Ports = []corev1.ServicePort{{
+	servicePort := corev1.ServicePort{
 		Name:       naming.PortPostgreSQL,
 		Port:       *cluster.Spec.Port,
 		Protocol:   corev1.ProtocolTCP,
 		TargetPort: intstr.FromString(naming.PortPostgreSQL),
-	}}
+	}
+
+	if spec := cluster.Spec.ReplicaService; spec == nil {
+		service.Spec.Type = corev1.ServiceTypeClusterIP
+	} else {
+		service.Spec.Type = corev1.ServiceType(spec.Type)
+		if spec.NodePort != nil {
+			if service.Spec.Type == corev1.ServiceTypeClusterIP {
+				// The NodePort can only be set when the Service type is NodePort or
+				// LoadBalancer. Log an Event and return an error as is done for the
+				// primary Service.
+				r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "MisconfiguredClusterIP",
+					"NodePort cannot be set with type ClusterIP on Service %q", service.Name)
+				return nil, fmt.Errorf("NodePort cannot be set with type ClusterIP on Service %q", service.Name)
+			}
+			servicePort.NodePort = *spec.NodePort
+		}
+		setServiceTrafficPolicies(service, spec)
+	}
+	service.Spec.Ports = []corev1.ServicePort{servicePort}
 
 	err := errors.WithStack(r.setControllerReference(cluster, service))
 
@@ -345,6 +375,9 @@ func (r *Reconciler) reconcileDataSource(ctx context.Context,
 	case cloudDataSource != nil:
 		configs = []string{cloudDataSource.Stanza, cloudDataSource.Repo.Name}
 		configs = append(configs, cloudDataSource.Options...)
+		if cloudDataSource.RepoPath != "" {
+			configs = append(configs, cloudDataSource.RepoPath)
+		}
 	}
 	configHash, err := hashFunc(configs)
 	if err != nil {
@@ -356,6 +389,16 @@ func (r *Reconciler) reconcileDataSource(ctx context.Context,
 			(configHash != restoreJob.GetAnnotations()[naming.PGBackRestConfigHash])
 	}
 
+	// A dry run of a new in-place restore only checks the restore, so the cluster is not
+	// prepared for it and continues to run. Each restore ID is checked separately.
+	if restoreInPlaceRequested && restoreIDChanged && restoreDryRun(dataSource) {
+		dryRunHash, err := hashFunc([]string{configHash, restoreID})
+		if err == nil {
+			err = r.reconcileInPlaceRestoreDryRun(ctx, cluster, dataSource, dryRunHash)
+		}
+		return false, err
+	}
+
 	// Proceed with preparing the cluster for restore (e.g. tearing down runners, the DCS,
 	// etc.) if:
 	// - A restore is already in progress, but the cluster has not yet been prepared
@@ -363,6 +406,23 @@ func (r *Reconciler) reconcileDataSource(ctx context.Context,
 	// - The restore ID has changed (i.e. the user provide a new value for the restore
 	//   annotation, indicating they want a new in-place restore)
 	if (restoringInPlace && (!readyForRestore || configChanged)) || restoreIDChanged {
+		// Check a new restore before preparing the cluster for it. An invalid
+		// in-place restore leaves the cluster running as it was. A restore found
+		// invalid is checked again when the restore annotation or the spec
+		// generation changes, or after restoreRecheckInterval, in case it failed
+		// because pgBackRest was unavailable.
+		if restoreIDChanged {
+			now := time.Now()
+			if r.invalidRestores.remembered(cluster, restoreID, now) {
+				return !restoreInPlaceRequested, nil
+			}
+			valid := r.validateRestore(ctx, cluster, observed,
+				dataSource, cloudDataSource, restoreInPlaceRequested)
+			r.invalidRestores.remember(cluster, restoreID, now, valid)
+			if !valid {
+				return !restoreInPlaceRequested, nil
+			}
+		}
 		if err := r.prepareForRestore(ctx, cluster, observed, endpoints,
 			restoreJob, restoreID); err != nil {
 			return true, err
@@ -791,4 +791,60 @@ postgres-operator.crunchydata.com/cluster: pg2
 postgres-operator.crunchydata.com/role: replica
 		`))
 	})
+
+	t.Run("ServiceSpec", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		affinity := corev1.ServiceAffinityClientIP
+		policy := corev1.ServiceExternalTrafficPolicyTypeLocal
+		cluster.Spec.ReplicaService = &v1beta1.ServiceSpec{
+			Metadata: &v1beta1.Metadata{
+				Annotations: map[string]string{"c": "v3"},
+				Labels: map[string]string{"d": "v4",
+					"postgres-operator.crunchydata.com/role": "wrong"},
+			},
+			Type:                  "LoadBalancer",
+			NodePort:              initialize.Int32(32001),
+			SessionAffinity:       &affinity,
+			ExternalTrafficPolicy: &policy,
+		}
+
+		service, err := reconciler.generateClusterReplicaService(cluster)
+		assert.NilError(t, err)
+
+		assert.Assert(t, marshalMatches(service.ObjectMeta.Annotations, `
+c: v3
+		`))
+		assert.Assert(t, marshalMatches(service.ObjectMeta.Labels, `
+d: v4
+postgres-operator.crunchydata.com/cluster: pg2
+postgres-operator.crunchydata.com/role: replica
+		`))
+		assert.Assert(t, marshalMatches(service.Spec, `
+externalTrafficPolicy: Local
+ports:
+- name: postgres
+  nodePort: 32001
+  port: 9876
+  protocol: TCP
+  targetPort: postgres
+selector:
+  postgres-operator.crunchydata.com/cluster: pg2
+  postgres-operator.crunchydata.com/role: replica
+sessionAffinity: ClientIP
+type: LoadBalancer
+		`))
+	})
+
+	t.Run("ClusterIPWithNodePort", func(t *testing.T) {
+		reconciler := &Reconciler{Client: cc, Recorder: new(record.FakeRecorder)}
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.ReplicaService = &v1beta1.ServiceSpec{
+			Type:     "ClusterIP",
+			NodePort: initialize.Int32(32001),
+		}
+
+		_, err := reconciler.generateClusterReplicaService(cluster)
+		assert.ErrorContains(t, err, "NodePort cannot be set with type ClusterIP")
+	})
 }
@@ -0,0 +1,300 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionReindexRequired is the type used in a condition to indicate
+	// whether or not any database of a PostgresCluster uses a collation whose
+	// version has changed. Indexes that depend on such a collation may be
+	// corrupt until they are rebuilt.
+	ConditionReindexRequired = "ReindexRequired"
+
+	// collationCheckInterval is how often the image of the primary instance is
+	// checked for changes, and how often a cluster that needs indexes rebuilt
+	// is checked for an open maintenance window.
+	collationCheckInterval = 5 * time.Minute
+)
+
+// collationReconciler compares the versions of collations in PostgreSQL with
+// the ones provided by the image of the primary instance whenever that image
+// changes. It rebuilds indexes during maintenance windows when asked to do so.
+// Rebuilding indexes can take a long time, so it has its own workqueue. It
+// shares its client, field owner, and tracer with the PostgresCluster
+// Reconciler.
+type collationReconciler struct {
+	*Reconciler
+}
+
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={get,list,watch}
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters/status",verbs={patch}
+
+// setupWithManager adds the collation controller to the provided runtime
+// manager.
+func (r *collationReconciler) setupWithManager(mgr manager.Manager) error {
+	return builder.ControllerManagedBy(mgr).
+		Named("postgrescluster-collation").
+		For(&v1beta1.PostgresCluster{},
+			// Ignore changes to status, including the ones made here.
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups="",resources="pods",verbs={list}
+// +kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={list}
+// +kubebuilder:rbac:groups="",resources="pods/exec",verbs={create}
+
+// Reconcile looks for collation version mismatches in the databases of a
+// PostgresCluster after the image of its primary instance changes and reports
+// them in the [ConditionReindexRequired] condition. During one of the
+// maintenance windows in spec.reindex, it rebuilds the indexes of one of
+// those databases at a time.
+func (r *collationReconciler) Reconcile(
+	ctx context.Context, request reconcile.Request) (reconcile.Result, error,
+) {
+	ctx, span := r.Tracer.Start(ctx, "ReconcileCollation")
+	log := logging.FromContext(ctx)
+	defer span.End()
+
+	cluster := &v1beta1.PostgresCluster{}
+	if err := r.Client.Get(ctx, request.NamespacedName, cluster); err != nil {
+		// NotFound cannot be fixed by requeuing so ignore it.
+		if err = client.IgnoreNotFound(err); err != nil {
+			log.Error(err, "unable to fetch PostgresCluster")
+			span.RecordError(err)
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Leave clusters that are being deleted or whose reconciliation is paused.
+	if cluster.DeletionTimestamp != nil ||
+		(cluster.Spec.Paused != nil && *cluster.Spec.Paused) {
+		return reconcile.Result{}, nil
+	}
+
+	before := cluster.DeepCopy()
+	result := reconcile.Result{RequeueAfter: collationCheckInterval}
+
+	err := r.reconcileCollation(ctx, cluster, time.Now(), &result)
+	if err != nil {
+		log.Error(err, "checking collation versions")
+		span.RecordError(err)
+	}
+
+	// Write only what this controller changed; see [Reconciler.patchStatus].
+	if patchErr := r.patchStatus(ctx, before, cluster); patchErr != nil {
+		log.Error(patchErr, "patching cluster status")
+		if err == nil {
+			err = patchErr
+		}
+	}
+
+	// Errors are retried with the backoff of this controller's workqueue.
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	return result, nil
+}
+
+// reconcileCollation compares collation versions in the writable instance of
+// cluster when its image differs from the one last compared. When a database
+// needs its indexes rebuilt and now is within a maintenance window, the
+// indexes of that database are rebuilt and result is set to check again
+// immediately.
+func (r *collationReconciler) reconcileCollation(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, now time.Time,
+	result *reconcile.Result,
+) error {
+	const container = naming.ContainerDatabase
+
+	pods := &corev1.PodList{}
+	runners := &appsv1.StatefulSetList{}
+
+	selector, err := naming.AsSelector(naming.ClusterInstances(cluster.Name))
+	if err == nil {
+		err = errors.WithStack(
+			r.Client.List(ctx, pods,
+				client.InNamespace(cluster.Namespace),
+				client.MatchingLabelsSelector{Selector: selector},
+			))
+	}
+	if err == nil {
+		err = errors.WithStack(
+			r.Client.List(ctx, runners,
+				client.InNamespace(cluster.Namespace),
+				client.MatchingLabelsSelector{Selector: selector},
+			))
+	}
+	if err != nil {
+		return err
+	}
+
+	// Find the PostgreSQL instance that can execute SQL that writes system
+	// catalogs. When there is none, such as in a standby cluster, check again
+	// later.
+	pod, _ := newObservedInstances(cluster, runners.Items, pods.Items).writablePod(container)
+	if pod == nil {
+		return nil
+	}
+
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).WithValues("pod", pod.Name))
+	exec := func(
+		_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		return r.PodExec(pod.Namespace, pod.Name, container, stdin, stdout, stderr, command...)
+	}
+
+	// Compare collation versions when the image of the writable instance
+	// changes. Prefer the digest of the image that is running.
+	image := ""
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == container {
+			image = status.ImageID
+		}
+	}
+	if image == "" {
+		for _, c := range pod.Spec.Containers {
+			if c.Name == container {
+				image = c.Image
+			}
+		}
+	}
+
+	if cluster.Status.Collation == nil || cluster.Status.Collation.Image != image {
+		var databases []string
+		databases, err = postgres.DatabasesWithCollationMismatch(ctx, exec)
+		if err != nil {
+			return err
+		}
+
+		cluster.Status.Collation = &v1beta1.PostgresCollationStatus{
+			Image: image, Databases: databases,
+		}
+		if len(databases) > 0 {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "CollationVersionMismatch",
+				"Collation versions changed in databases %s; rebuild their indexes",
+				strings.Join(databases, ", "))
+		}
+	}
+
+	// Rebuild the indexes of one database during a maintenance window.
+	if databases := cluster.Status.Collation.Databases; len(databases) > 0 &&
+		cluster.Spec.Reindex != nil &&
+		inMaintenanceWindow(cluster.Spec.Reindex.MaintenanceWindows, now) {
+
+		database := databases[0]
+		started := time.Now()
+		err = errors.WithStack(postgres.ReindexForCollationsInPostgreSQL(ctx, exec, database))
+
+		if err == nil {
+			r.Recorder.Eventf(cluster, corev1.EventTypeNormal, "Reindexed",
+				"Rebuilt indexes of database %q in %s", database,
+				time.Since(started).Round(time.Second))
+
+			cluster.Status.Collation.Databases = databases[1:]
+			*result = reconcile.Result{Requeue: true}
+		} else {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "ReindexFailed",
+				"Unable to rebuild indexes of database %q: %v", database, err)
+		}
+	}
+
+	condition := metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionReindexRequired,
+		Status:             metav1.ConditionFalse,
+		Reason:             "CollationVersionsCurrent",
+		Message:            "Collation versions match the image",
+	}
+	if databases := cluster.Status.Collation.Databases; len(databases) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "CollationVersionMismatch"
+		condition.Message = fmt.Sprintf(
+			"Indexes should be rebuilt in databases: %s", strings.Join(databases, ", "))
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+
+	return err
+}
+
+// inMaintenanceWindow returns whether or not now is within any of windows.
+func inMaintenanceWindow(windows []v1beta1.MaintenanceWindow, now time.Time) bool {
+	now = now.UTC()
+
+	for _, window := range windows {
+		var hour, minute int
+		if _, err := fmt.Sscanf(window.Start, "%d:%d", &hour, &minute); err != nil {
+			continue
+		}
+
+		duration := time.Duration(window.DurationMinutes) * time.Minute
+		if duration <= 0 {
+			duration = time.Hour
+		}
+
+		// A window that started yesterday may still be open.
+		for _, days := range []int{0, -1} {
+			day := now.AddDate(0, 0, days)
+			start := time.Date(day.Year(), day.Month(), day.Day(),
+				hour, minute, 0, 0, time.UTC)
+
+			if !now.Before(start) && now.Before(start.Add(duration)) &&
+				startsOn(window.Days, start.Weekday()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// startsOn returns whether or not weekday is one of days. Empty days means
+// every day.
+func startsOn(days []v1beta1.Weekday, weekday time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, day := range days {
+		if string(day) == weekday.String() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestInMaintenanceWindow(t *testing.T) {
+	// Tuesday
+	now := time.Date(2023, time.March, 7, 2, 30, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name    string
+		windows []v1beta1.MaintenanceWindow
+		expect  bool
+	}{
+		{name: "None", expect: false},
+		{
+			name:    "EveryDay",
+			windows: []v1beta1.MaintenanceWindow{{Start: "02:00", DurationMinutes: 60}},
+			expect:  true,
+		},
+		{
+			name:    "DefaultDuration",
+			windows: []v1beta1.MaintenanceWindow{{Start: "01:45"}},
+			expect:  true,
+		},
+		{
+			name:    "NotYet",
+			windows: []v1beta1.MaintenanceWindow{{Start: "03:00", DurationMinutes: 60}},
+			expect:  false,
+		},
+		{
+			name:    "Over",
+			windows: []v1beta1.MaintenanceWindow{{Start: "01:00", DurationMinutes: 90}},
+			expect:  false,
+		},
+		{
+			name: "OtherDay",
+			windows: []v1beta1.MaintenanceWindow{{
+				Days: []v1beta1.Weekday{"Monday"}, Start: "02:00", DurationMinutes: 60,
+			}},
+			expect: false,
+		},
+		{
+			name: "SinceYesterday",
+			windows: []v1beta1.MaintenanceWindow{{
+				Days: []v1beta1.Weekday{"Monday"}, Start: "23:00", DurationMinutes: 240,
+			}},
+			expect: true,
+		},
+		{
+			name: "Any",
+			windows: []v1beta1.MaintenanceWindow{
+				{Days: []v1beta1.Weekday{"Sunday"}, Start: "02:00"},
+				{Days: []v1beta1.Weekday{"Tuesday"}, Start: "02:00"},
+			},
+			expect: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, inMaintenanceWindow(tt.windows, now), tt.expect)
+		})
+	}
+}
+
+func TestReconcileCollation(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := testCluster()
+	cluster.Namespace = "ns1"
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace, Name: "hippo-instance1-abcd-0",
+			Labels: map[string]string{
+				naming.LabelCluster:  cluster.Name,
+				naming.LabelInstance: "hippo-instance1-abcd",
+			},
+			Annotations: map[string]string{"status": `{"role":"master"}`},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:    naming.ContainerDatabase,
+				ImageID: "postgres@sha256:one",
+				State: corev1.ContainerState{
+					Running: new(corev1.ContainerStateRunning),
+				},
+			}},
+		},
+	}
+
+	var checks, reindexes []string
+	recorder := events.NewRecorder(t, scheme)
+	reconciler := &collationReconciler{&Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build(),
+		Recorder: recorder,
+		PodExec: func(namespace, pod, container string, stdin io.Reader, stdout,
+			stderr io.Writer, command ...string) error {
+			b, _ := io.ReadAll(stdin)
+			if strings.Contains(string(b), "REINDEX") {
+				reindexes = append(reindexes, command[len(command)-1])
+			} else {
+				checks = append(checks, pod)
+				_, _ = stdout.Write([]byte("zoo\naquarium\n"))
+			}
+			return nil
+		},
+	}}
+
+	// Tuesday
+	outside := time.Date(2023, time.March, 7, 12, 0, 0, 0, time.UTC)
+	inside := time.Date(2023, time.March, 7, 2, 30, 0, 0, time.UTC)
+
+	var result reconcile.Result
+	assert.NilError(t, reconciler.reconcileCollation(ctx, cluster, outside, &result))
+	assert.DeepEqual(t, checks, []string{pod.Name})
+	assert.Equal(t, len(reindexes), 0)
+	assert.Equal(t, cluster.Status.Collation.Image, "postgres@sha256:one")
+	assert.DeepEqual(t, cluster.Status.Collation.Databases, []string{"aquarium", "zoo"})
+
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionReindexRequired)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Assert(t, strings.Contains(condition.Message, "aquarium, zoo"))
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, recorder.Events[0].Reason, "CollationVersionMismatch")
+
+	// The same image is not checked again.
+	assert.NilError(t, reconciler.reconcileCollation(ctx, cluster, outside, &result))
+	assert.Equal(t, len(checks), 1)
+
+	// No maintenance windows; nothing is rebuilt.
+	assert.NilError(t, reconciler.reconcileCollation(ctx, cluster, inside, &result))
+	assert.Equal(t, len(reindexes), 0)
+
+	// During a maintenance window, one database is rebuilt at a time.
+	cluster.Spec.Reindex = &v1beta1.PostgresReindexSpec{
+		MaintenanceWindows: []v1beta1.MaintenanceWindow{{Start: "02:00", DurationMinutes: 60}},
+	}
+	assert.NilError(t, reconciler.reconcileCollation(ctx, cluster, outside, &result))
+	assert.Equal(t, len(reindexes), 0)
+
+	result = reconcile.Result{}
+	assert.NilError(t, reconciler.reconcileCollation(ctx, cluster, inside, &result))
+	assert.DeepEqual(t, reindexes, []string{"--set=database=aquarium"})
+	assert.DeepEqual(t, cluster.Status.Collation.Databases, []string{"zoo"})
+	assert.Assert(t, result.Requeue)
+
+	assert.NilError(t, reconciler.reconcileCollation(ctx, cluster, inside, &result))
+	assert.DeepEqual(t, reindexes, []string{"--set=database=aquarium", "--set=database=zoo"})
+	assert.Equal(t, len(cluster.Status.Collation.Databases), 0)
+
+	condition = meta.FindStatusCondition(cluster.Status.Conditions, ConditionReindexRequired)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	assert.Equal(t, condition.Reason, "CollationVersionsCurrent")
+
+	// A new image is checked again.
+	pod.Status.ContainerStatuses[0].ImageID = "postgres@sha256:two"
+	assert.NilError(t, reconciler.Client.Status().Update(ctx, pod))
+	assert.NilError(t, reconciler.reconcileCollation(ctx, cluster, outside, &result))
+	assert.Equal(t, len(checks), 2)
+	assert.Equal(t, cluster.Status.Collation.Image, "postgres@sha256:two")
+}
@@ -16,13 +16,21 @@ limitations under the License.
 */
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
@@ -30,15 +38,20 @@ import (
 	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
@@ -69,6 +82,20 @@ type Reconciler struct {
 		namespace, pod, container string,
 		stdin io.Reader, stdout, stderr io.Writer, command ...string,
 	) error
+
+	// invalidRestores remembers restores that were found invalid so that they
+	// are not checked and reported on every reconcile.
+	invalidRestores *restoreValidations
+
+	// statusWrites coalesces minor changes to the status of each cluster.
+	statusWrites *statusWrites
+
+	// allowedExtensions are the extensions that clusters may request. When
+	// nil, every extension is allowed.
+	allowedExtensions sets.String
+
+	// certificates remembers when the certificates of each cluster expire.
+	certificates *certificateExpirations
 }
 
 // +kubebuilder:rbac:groups="",resources="events",verbs={create,patch}
@@ -105,6 +132,12 @@ func (r *Reconciler) Reconcile(
 		return result, err
 	}
 
+	// Identify the cluster in the trace of its reconciliation.
+	span.SetAttributes(
+		attribute.String("namespace", cluster.Namespace),
+		attribute.String("name", cluster.Name),
+	)
+
 	// Set any defaults that may not have been stored in the API. No DeepCopy
 	// is necessary because controller-runtime makes a copy before returning
 	// from its cache.
@@ -123,6 +156,14 @@ func (r *Reconciler) Reconcile(
 	// as Forbidden: "unable to create new content in namespace … because it is
 	// being terminated".
 
+	// Move cluster to the current label domain before anything reads or
+	// writes its labels, annotations, or finalizer.
+	if err := r.reconcileLabelDomain(ctx, cluster); err != nil {
+		span.RecordError(err)
+		log.Error(err, "moving label domain")
+		return reconcile.Result{}, err
+	}
+
 	// Check for and handle deletion of cluster. Return early if it is being
 	// deleted or there was an error.
 	if result, err := r.handleDelete(ctx, cluster); err != nil {
@@ -145,19 +186,21 @@ func (r *Reconciler) Reconcile(
 	// Perform initial validation on a cluster
 	// TODO: Move this to a defaulting (mutating admission) webhook
 	// to leverage regular validation.
-	if cluster.Spec.Standby != nil &&
-		cluster.Spec.Standby.Enabled &&
-		cluster.Spec.Standby.Host == "" &&
-		cluster.Spec.Standby.RepoName == "" {
-		// When a standby cluster is requested but a repoName or host is not provided
-		// the cluster will be created as a non-standby. Reject any clusters with
-		// this configuration and provide an event
-		path := field.NewPath("spec", "standby")
-		err := field.Invalid(path, cluster.Name, "Standby requires a host or repoName to be enabled")
+	if err := validateStandby(cluster); err != nil {
 		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidStandbyConfiguration",
 			err.Error())
 		return result, err
 	}
+	if err := validateAuthentication(cluster); err != nil {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidAuthentication",
+			err.Error())
+		return result, err
+	}
+	if err := validateRemoteInstanceSets(cluster); err != nil {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidRemoteInstanceSet",
+			err.Error())
+		return result, err
+	}
 
 	var (
 		clusterConfigMap         *corev1.ConfigMap
@@ -179,14 +222,31 @@ func (r *Reconciler) Reconcile(
 	// occurs while attempting to patch the status, while otherwise simply returning the
 	// Result and error variables that are populated while reconciling the PostgresCluster.
 	patchClusterStatus := func() (reconcile.Result, error) {
+		// Only the status is written here. Reconcilers must never change the spec
+		// so that tools comparing it to a source of truth, e.g. GitOps controllers,
+		// never see a difference. Report any reconciler that does; a later step
+		// of this or the next reconcile could act on the changed value.
+		if changed := specChanges(before.Spec, cluster.Spec); len(changed) > 0 {
+			log.Error(errors.New("spec changed during reconcile"),
+				"reconcilers must not change the cluster spec", "fields", changed)
+		}
 		if !equality.Semantic.DeepEqual(before.Status, cluster.Status) {
+			// Minor changes wait a moment when the status was written recently.
+			// Reconciling again then writes them along with any that follow.
+			now := time.Now()
+			if wait := r.statusWrites.delay(cluster, &before.Status, &cluster.Status, now); wait > 0 {
+				log.V(1).Info("delaying cluster status", "delay", wait)
+				result = updateReconcileResult(result, reconcile.Result{RequeueAfter: wait})
+				return result, err
+			}
+
 			// NOTE(cbandy): Kubernetes prior to v1.16.10 and v1.17.6 does not track
 			// managed fields on the status subresource: https://issue.k8s.io/88901
-			if err := errors.WithStack(r.Client.Status().Patch(
-				ctx, cluster, client.MergeFrom(before), r.Owner)); err != nil {
+			if err := r.patchStatus(ctx, before, cluster); err != nil {
 				log.Error(err, "patching cluster status")
 				return result, err
 			}
+			r.statusWrites.wrote(cluster, now)
 			log.V(1).Info("patched cluster status")
 		}
 		return result, err
@@ -209,150 +269,358 @@ func (r *Reconciler) Reconcile(
 
 	pgHBAs := postgres.NewHBAs()
 	pgmonitor.PostgreSQLHBAs(cluster, &pgHBAs)
+	logicalBackupHBAs(cluster, &pgHBAs)
 	pgbouncer.PostgreSQL(cluster, &pgHBAs)
 
 	pgParameters := postgres.NewParameters()
 	pgaudit.PostgreSQLParameters(&pgParameters)
 	pgbackrest.PostgreSQL(cluster, &pgParameters)
 	pgmonitor.PostgreSQLParameters(cluster, &pgParameters)
+	r.preloadExtensionLibraries(cluster, &pgParameters)
+
+	// Store passwords and authenticate password connections as specified
+	postgres.SetAuthentication(cluster, &pgHBAs, &pgParameters)
 
 	// Set huge_pages = try if a hugepages resource limit > 0, otherwise set "off"
 	postgres.SetHugePages(cluster, &pgParameters)
 
-	if err == nil {
-		rootCA, err = r.reconcileRootCertificate(ctx, cluster)
-	}
+	// Limit the WAL that replication slots can keep
+	postgres.SetReplicationSlotLimit(cluster, &pgParameters)
 
-	if err == nil {
-		// Since any existing data directories must be moved prior to bootstrapping the
-		// cluster, further reconciliation will not occur until the directory move Jobs
-		// (if configured) have completed. Func reconcileDirMoveJobs() will therefore
-		// return a bool indicating that the controller should return early while any
-		// required Jobs are running, after which it will indicate that an early
-		// return is no longer needed, and reconciliation can proceed normally.
-		var returnEarly bool
+	// Refuse writes during a planned read-only window. PGO leaves the objects it
+	// manages inside PostgreSQL alone until the window ends.
+	postgres.SetReadOnly(cluster, &pgParameters)
+	readOnly := r.reconcileReadOnly(cluster)
+
+	// Each phase of reconciliation runs in its own span so that traces show
+	// where reconciling a large cluster spends its time.
+	phase := reconcilePhases(ctx, r.Tracer, &err)
+
+	phase("reconcile-root-certificate", func(ctx context.Context) (err error) {
+		rootCA, err = r.reconcileRootCertificate(ctx, cluster)
+		return err
+	})
+
+	// Since any existing data directories must be moved prior to bootstrapping the
+	// cluster, further reconciliation will not occur until the directory move Jobs
+	// (if configured) have completed. Func reconcileDirMoveJobs() will therefore
+	// return a bool indicating that the controller should return early while any
+	// required Jobs are running, after which it will indicate that an early
+	// return is no longer needed, and reconciliation can proceed normally.
+	var returnEarly bool
+	phase("reconcile-directory-moves", func(ctx context.Context) (err error) {
 		returnEarly, err = r.reconcileDirMoveJobs(ctx, cluster)
-		if err != nil || returnEarly {
-			return patchClusterStatus()
-		}
+		return err
+	})
+	if err != nil || returnEarly {
+		return patchClusterStatus()
 	}
-	if err == nil {
+
+	phase("observe", func(ctx context.Context) (err error) {
 		clusterVolumes, err = r.observePersistentVolumeClaims(ctx, cluster)
-	}
-	if err == nil {
-		clusterVolumes, err = r.configureExistingPVCs(ctx, cluster, clusterVolumes)
-	}
-	if err == nil {
-		instances, err = r.observeInstances(ctx, cluster)
-	}
-	if err == nil {
-		err = updateResult(r.reconcilePatroniStatus(ctx, cluster, instances))
-	}
-	if err == nil {
-		err = r.reconcilePatroniSwitchover(ctx, cluster, instances)
-	}
+		if err == nil {
+			clusterVolumes, err = r.configureExistingPVCs(ctx, cluster, clusterVolumes)
+		}
+		if err == nil {
+			instances, err = r.observeInstances(ctx, cluster)
+		}
+		if err == nil {
+			err = updateResult(r.reconcilePatroniStatus(ctx, cluster, instances))
+		}
+		if err == nil {
+			err = r.reconcilePatroniSwitchover(ctx, cluster, instances)
+		}
+		return err
+	})
+
 	// reconcile the Pod service before reconciling any data source in case it is necessary
 	// to start Pods during data source reconciliation that require network connections (e.g.
 	// if it is necessary to start a dedicated repo host to bootstrap a new cluster using its
 	// own existing backups).
-	if err == nil {
-		clusterPodService, err = r.reconcileClusterPodService(ctx, cluster)
-	}
 	// reconcile the RBAC resources before reconciling any data source in case
 	// restore/move Job pods require the ServiceAccount to access any data source.
 	// e.g., we are restoring from an S3 source using an IAM for access
 	// - https://docs.aws.amazon.com/eks/latest/userguide/iam-roles-for-service-accounts-technical-overview.html
-	if err == nil {
-		instanceServiceAccount, err = r.reconcileRBACResources(ctx, cluster)
-	}
+	phase("reconcile-access", func(ctx context.Context) (err error) {
+		clusterPodService, err = r.reconcileClusterPodService(ctx, cluster)
+		if err == nil {
+			instanceServiceAccount, err = r.reconcileRBACResources(ctx, cluster)
+		}
+		return err
+	})
+
 	// First handle reconciling any data source configured for the PostgresCluster.  This includes
 	// reconciling the data source defined to bootstrap a new cluster, as well as a reconciling
 	// a data source to perform restore in-place and re-bootstrap the cluster.
-	if err == nil {
-		// Since the PostgreSQL data source needs to be populated prior to bootstrapping the
-		// cluster, further reconciliation will not occur until the data source (if configured) is
-		// initialized.  Func reconcileDataSource() will therefore return a bool indicating that
-		// the controller should return early while data initialization is in progress, after
-		// which it will indicate that an early return is no longer needed, and reconciliation
-		// can proceed normally.
-		var returnEarly bool
+	//
+	// Since the PostgreSQL data source needs to be populated prior to bootstrapping the
+	// cluster, further reconciliation will not occur until the data source (if configured) is
+	// initialized.  Func reconcileDataSource() will therefore return a bool indicating that
+	// the controller should return early while data initialization is in progress, after
+	// which it will indicate that an early return is no longer needed, and reconciliation
+	// can proceed normally.
+	phase("reconcile-data-source", func(ctx context.Context) (err error) {
 		returnEarly, err = r.reconcileDataSource(ctx, cluster, instances, clusterVolumes, rootCA)
-		if err != nil || returnEarly {
-			return patchClusterStatus()
-		}
+		return err
+	})
+	if err == nil && returnEarly && cluster.Status.PGBackRest != nil &&
+		cluster.Status.PGBackRest.Restore != nil &&
+		cluster.Status.PGBackRest.Restore.Active > 0 {
+		// Observe the progress of the running restore again later.
+		result = updateReconcileResult(result,
+			reconcile.Result{RequeueAfter: restoreProgressInterval})
+	}
+	if err != nil || returnEarly {
+		return patchClusterStatus()
 	}
-	if err == nil {
+
+	phase("reconcile-cluster-configmap", func(ctx context.Context) (err error) {
 		clusterConfigMap, err = r.reconcileClusterConfigMap(ctx, cluster, pgHBAs, pgParameters)
-	}
-	if err == nil {
+		return err
+	})
+	phase("reconcile-replication-certificate", func(ctx context.Context) (err error) {
 		clusterReplicationSecret, err = r.reconcileReplicationSecret(ctx, cluster, rootCA)
-	}
-	if err == nil {
+		return err
+	})
+	phase("reconcile-services", func(ctx context.Context) (err error) {
 		patroniLeaderService, err = r.reconcilePatroniLeaderLease(ctx, cluster)
-	}
-	if err == nil {
-		primaryService, err = r.reconcileClusterPrimaryService(ctx, cluster, patroniLeaderService)
-	}
-	if err == nil {
-		err = r.reconcileClusterReplicaService(ctx, cluster)
-	}
-	if err == nil {
+		if err == nil {
+			primaryService, err = r.reconcileClusterPrimaryService(ctx, cluster, patroniLeaderService)
+		}
+		if err == nil {
+			err = r.reconcileClusterReplicaService(ctx, cluster)
+		}
+		return err
+	})
+	phase("reconcile-cluster-certificates", func(ctx context.Context) (err error) {
 		primaryCertificate, err = r.reconcileClusterCertificate(ctx, rootCA, cluster, primaryService)
-	}
-	if err == nil {
+		if err == nil {
+			err = r.reconcileClusterCABundle(ctx, rootCA, cluster, primaryCertificate)
+		}
+		return err
+	})
+	phase("reconcile-patroni-configuration", func(ctx context.Context) (err error) {
 		err = r.reconcilePatroniDistributedConfiguration(ctx, cluster)
-	}
-	if err == nil {
-		err = r.reconcilePatroniDynamicConfiguration(ctx, cluster, instances, pgHBAs, pgParameters)
-	}
-	if err == nil {
+		if err == nil {
+			err = r.reconcilePatroniDynamicConfiguration(ctx, cluster, instances, pgHBAs, pgParameters)
+		}
+		return err
+	})
+	phase("reconcile-monitoring-configuration", func(ctx context.Context) (err error) {
 		monitoringSecret, err = r.reconcileMonitoringSecret(ctx, cluster)
-	}
-	if err == nil {
-		exporterWebConfig, err = r.reconcileExporterWebConfig(ctx, cluster)
-	}
-	if err == nil {
-		err = r.reconcileInstanceSets(
+		if err == nil {
+			exporterWebConfig, err = r.reconcileExporterWebConfig(ctx, cluster)
+		}
+		return err
+	})
+	phase("reconcile-instances", func(ctx context.Context) error {
+		return r.reconcileInstanceSets(
 			ctx, cluster, clusterConfigMap, clusterReplicationSecret,
 			rootCA, clusterPodService, instanceServiceAccount, instances,
 			patroniLeaderService, primaryCertificate, clusterVolumes, exporterWebConfig)
+	})
+	phase("reconcile-remote-instances", func(ctx context.Context) error {
+		return r.reconcileRemoteInstanceSets(
+			ctx, cluster, rootCA, primaryCertificate, clusterReplicationSecret)
+	})
+
+	phase("reconcile-postgres", func(ctx context.Context) (err error) {
+		err = r.reconcileExtensionLibraries(ctx, cluster, instances)
+		if err == nil && !readOnly {
+			err = r.reconcilePostgresDatabases(ctx, cluster, instances)
+		}
+		if err == nil {
+			err = r.reconcilePostgresUsers(ctx, cluster, instances)
+		}
+		if err == nil && !readOnly {
+			err = r.reconcileForeignServers(ctx, cluster, instances)
+		}
+		return err
+	})
+
+	phase("reconcile-backups", func(ctx context.Context) error {
+		err := updateResult(r.reconcilePGBackRest(ctx, cluster, instances, rootCA))
+		if err == nil {
+			err = updateResult(r.reconcileVolumeSnapshots(ctx, cluster, instances))
+		}
+		if err == nil {
+			err = r.reconcileLogicalBackups(ctx, cluster, instances, primaryCertificate)
+		}
+		return err
+	})
+	phase("reconcile-pgbouncer", func(ctx context.Context) error {
+		return r.reconcilePGBouncer(ctx, cluster, instances, primaryCertificate, rootCA)
+	})
+	if !readOnly {
+		phase("reconcile-pgmonitor", func(ctx context.Context) error {
+			return r.reconcilePGMonitor(ctx, cluster, instances, monitoringSecret)
+		})
+		phase("reconcile-database-init-sql", func(ctx context.Context) error {
+			return r.reconcileDatabaseInitSQL(ctx, cluster, instances)
+		})
+	}
+	phase("reconcile-pgadmin", func(ctx context.Context) error {
+		return r.reconcilePGAdmin(ctx, cluster)
+	})
+	phase("reconcile-benchmark", func(ctx context.Context) error {
+		return r.reconcileBenchmark(ctx, cluster, primaryCertificate)
+	})
+
+	// This is after [Reconciler.rolloutInstances] to ensure that recreating
+	// Pods takes precedence.
+	phase("handle-patroni-restarts", func(ctx context.Context) error {
+		return r.handlePatroniRestarts(ctx, cluster, instances)
+	})
+
+	// at this point everything reconciled successfully, and we can update the
+	// observedGeneration
+	cluster.Status.ObservedGeneration = cluster.GetGeneration()
+
+	log.V(1).Info("reconciled cluster")
+
+	return patchClusterStatus()
+}
+
+// reconcilePhases returns a function that runs steps in a span named for its
+// phase of reconciliation. The span records any error, which is also stored in
+// err. A phase is skipped when err is already set, e.g. by an earlier phase.
+func reconcilePhases(
+	ctx context.Context, tracer trace.Tracer, err *error,
+) func(name string, steps func(context.Context) error) {
+	return func(name string, steps func(context.Context) error) {
+		if *err == nil {
+			ctx, span := tracer.Start(ctx, name)
+			if *err = steps(ctx); *err != nil {
+				span.RecordError(*err)
+			}
+			span.End()
+		}
+	}
+}
+
+// patchStatus writes the changes from before to after in the status of a
+// PostgresCluster. Other controllers write to the same status, and a merge
+// patch replaces status.conditions as a whole, so the patch fails when the
+// cluster changed after before was read. On conflict, the changes are redone
+// on the latest status and the patch is retried.
+func (r *Reconciler) patchStatus(
+	ctx context.Context, before, after *v1beta1.PostgresCluster,
+) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if equality.Semantic.DeepEqual(before.Status, after.Status) {
+			return nil
+		}
+
+		err := errors.WithStack(r.Client.Status().Patch(ctx, after,
+			client.MergeFromWithOptions(before, client.MergeFromWithOptimisticLock{}),
+			r.Owner))
+
+		if apierrors.IsConflict(err) {
+			latest := &v1beta1.PostgresCluster{}
+			if err := errors.WithStack(
+				r.Client.Get(ctx, client.ObjectKeyFromObject(after), latest),
+			); err != nil {
+				return err
+			}
+
+			redone := latest.DeepCopy()
+			if err := redoStatusChanges(&redone.Status, before.Status, after.Status); err != nil {
+				return err
+			}
+			before, after = latest, redone
+		}
+		return err
+	})
+}
+
+// specChanges returns the JSON names of the top-level fields that differ
+// between before and after, in the order they appear in the spec.
+func specChanges(before, after v1beta1.PostgresClusterSpec) []string {
+	if equality.Semantic.DeepEqual(before, after) {
+		return nil
 	}
 
+	var beforeFields, afterFields map[string]json.RawMessage
+	beforeJSON, err := json.Marshal(before)
 	if err == nil {
-		err = r.reconcilePostgresDatabases(ctx, cluster, instances)
+		err = json.Unmarshal(beforeJSON, &beforeFields)
 	}
 	if err == nil {
-		err = r.reconcilePostgresUsers(ctx, cluster, instances)
+		var afterJSON []byte
+		afterJSON, err = json.Marshal(after)
+		if err == nil {
+			err = json.Unmarshal(afterJSON, &afterFields)
+		}
+	}
+	if err != nil {
+		return []string{"spec"}
+	}
+
+	var changed []string
+	spec := reflect.TypeOf(before)
+	for i := 0; i < spec.NumField(); i++ {
+		name, _, _ := strings.Cut(spec.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" &&
+			!bytes.Equal(beforeFields[name], afterFields[name]) {
+			changed = append(changed, name)
+		}
+	}
+
+	// Some differences, such as nil and empty slices, do not appear in JSON.
+	if len(changed) == 0 {
+		changed = []string{"spec"}
 	}
+	return changed
+}
 
+// redoStatusChanges applies the changes from before to after onto status.
+// Fields other than conditions are changed as a JSON merge patch would change
+// them. Conditions are changed one type at a time, so conditions that other
+// controllers wrote to status are kept.
+func redoStatusChanges(status *v1beta1.PostgresClusterStatus, before, after v1beta1.PostgresClusterStatus) error {
+	beforeJSON, err := json.Marshal(before)
+	var afterJSON, statusJSON, patch []byte
 	if err == nil {
-		err = updateResult(r.reconcilePGBackRest(ctx, cluster, instances, rootCA))
+		afterJSON, err = json.Marshal(after)
 	}
 	if err == nil {
-		err = r.reconcilePGBouncer(ctx, cluster, instances, primaryCertificate, rootCA)
+		statusJSON, err = json.Marshal(status)
 	}
 	if err == nil {
-		err = r.reconcilePGMonitor(ctx, cluster, instances, monitoringSecret)
+		patch, err = jsonpatch.CreateMergePatch(beforeJSON, afterJSON)
 	}
 	if err == nil {
-		err = r.reconcileDatabaseInitSQL(ctx, cluster, instances)
+		statusJSON, err = jsonpatch.MergePatch(statusJSON, patch)
 	}
+
+	conditions := status.Conditions
 	if err == nil {
-		err = r.reconcilePGAdmin(ctx, cluster)
+		*status = v1beta1.PostgresClusterStatus{}
+		err = json.Unmarshal(statusJSON, status)
 	}
 	if err == nil {
-		// This is after [Reconciler.rolloutInstances] to ensure that recreating
-		// Pods takes precedence.
-		err = r.handlePatroniRestarts(ctx, cluster, instances)
+		status.Conditions = conditions
+		for _, changes := range [][]metav1.Condition{before.Conditions, after.Conditions} {
+			for _, condition := range changes {
+				if !equality.Semantic.DeepEqual(
+					meta.FindStatusCondition(before.Conditions, condition.Type),
+					meta.FindStatusCondition(after.Conditions, condition.Type),
+				) {
+					copyCondition(&status.Conditions, after.Conditions, condition.Type)
+				}
+			}
+		}
 	}
+	return errors.WithStack(err)
+}
 
-	// at this point everything reconciled successfully, and we can update the
-	// observedGeneration
-	cluster.Status.ObservedGeneration = cluster.GetGeneration()
-
-	log.V(1).Info("reconciled cluster")
-
-	return patchClusterStatus()
+// copyCondition makes the condition of kind in conditions the same as the one
+// in source, removing it when source has none.
+func copyCondition(conditions *[]metav1.Condition, source []metav1.Condition, kind string) {
+	meta.RemoveStatusCondition(conditions, kind)
+	if condition := meta.FindStatusCondition(source, kind); condition != nil {
+		*conditions = append(*conditions, *condition)
+	}
 }
 
 // deleteControlled safely deletes object when it is controlled by cluster.
@@ -406,6 +674,52 @@ func (r *Reconciler) setOwnerReference(
 	return controllerutil.SetOwnerReference(owner, controlled, r.Client.Scheme())
 }
 
+// validateAuthentication returns an error when the authentication settings
+// of cluster conflict with one another.
+func validateAuthentication(cluster *v1beta1.PostgresCluster) error {
+	spec := cluster.Spec.Authentication
+	if spec == nil || !postgres.RequireChannelBinding(cluster) {
+		return nil
+	}
+
+	// Channel binding is part of SCRAM-SHA-256 authentication, which cannot
+	// verify passwords stored as MD5.
+	if postgres.PasswordEncryption(cluster) != "scram-sha-256" {
+		return field.Invalid(field.NewPath("spec", "authentication", "channelBinding"),
+			spec.ChannelBinding, `requires passwordEncryption "scram-sha-256"`)
+	}
+	return nil
+}
+
+// validateStandby returns an error when the standby configuration of cluster
+// cannot work.
+func validateStandby(cluster *v1beta1.PostgresCluster) error {
+	standby := cluster.Spec.Standby
+	if standby == nil || !standby.Enabled {
+		return nil
+	}
+
+	// When a standby cluster is requested but a repoName or host is not provided
+	// the cluster will be created as a non-standby. Reject any clusters with
+	// this configuration.
+	path := field.NewPath("spec", "standby")
+	if standby.Host == "" && standby.RepoName == "" {
+		return field.Invalid(path, cluster.Name, "Standby requires a host or repoName to be enabled")
+	}
+
+	// The standby fetches WAL files using the configuration of its own repos.
+	if standby.RepoName != "" {
+		for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+			if repo.Name == standby.RepoName {
+				return nil
+			}
+		}
+		return field.Invalid(path.Child("repoName"), standby.RepoName,
+			"Standby repoName must be defined in spec.backups.pgbackrest.repos")
+	}
+	return nil
+}
+
 // +kubebuilder:rbac:groups="",resources="configmaps",verbs={get,list,watch}
 // +kubebuilder:rbac:groups="",resources="endpoints",verbs={get,list,watch}
 // +kubebuilder:rbac:groups="",resources="persistentvolumeclaims",verbs={get,list,watch}
@@ -424,11 +738,53 @@ func (r *Reconciler) setOwnerReference(
 func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 	if r.PodExec == nil {
 		var err error
-		r.PodExec, err = newPodExecutor(mgr.GetConfig())
+		var exec podExecutor
+		exec, err = newPodExecutor(mgr.GetConfig())
 		if err != nil {
 			return err
 		}
+
+		// Commands in Pods are limited per cluster and overall so that many
+		// reconciles at once do not exhaust exec streams and kubelet connections.
+		perCluster, total, wait := podExecPerCluster, podExecTotal, podExecWait
+		if s := os.Getenv("PGO_EXEC_PER_CLUSTER"); s != "" {
+			if i, err := strconv.Atoi(s); err == nil && i >= 0 {
+				perCluster = i
+			} else {
+				mgr.GetLogger().Error(err, "PGO_EXEC_PER_CLUSTER must be a number")
+			}
+		}
+		if s := os.Getenv("PGO_EXEC_TOTAL"); s != "" {
+			if i, err := strconv.Atoi(s); err == nil && i >= 0 {
+				total = i
+			} else {
+				mgr.GetLogger().Error(err, "PGO_EXEC_TOTAL must be a number")
+			}
+		}
+		if s := os.Getenv("PGO_EXEC_WAIT"); s != "" {
+			if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+				wait = d
+			} else {
+				mgr.GetLogger().Error(err, "PGO_EXEC_WAIT must be a duration")
+			}
+		}
+		r.PodExec = newPodExecPool(mgr.GetClient(), perCluster, total, wait).Wrap(exec)
 	}
+	r.invalidRestores = new(restoreValidations)
+	r.statusWrites = &statusWrites{interval: statusWriteInterval}
+
+	// The interval can be changed or, when zero, minor changes are not delayed.
+	if s := os.Getenv("PGO_STATUS_INTERVAL"); s != "" {
+		if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+			r.statusWrites.interval = d
+		} else {
+			mgr.GetLogger().Error(err, "PGO_STATUS_INTERVAL must be a duration")
+		}
+	}
+
+	// Clusters may request only the extensions in this comma-separated list.
+	// When it is empty, every extension is allowed.
+	r.allowedExtensions = extensionAllowlist(os.Getenv("PGO_EXTENSION_ALLOWLIST"))
 
 	var opts controller.Options
 
@@ -445,24 +801,99 @@ func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 		opts.MaxConcurrentReconciles = 2
 	}
 
-	return builder.ControllerManagedBy(mgr).
+	// Report the backups of every cluster on the metrics endpoint of the manager.
+	// Only the first Reconciler of a process registers this.
+	if err := metrics.Registry.Register(backupCollector{mgr.GetClient()}); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if !errors.As(err, &registered) {
+			return err
+		}
+	}
+
+	// Report when the certificates of every cluster expire. Reconcilers in the
+	// same process share the first collector that was registered.
+	r.certificates = new(certificateExpirations)
+	if err := metrics.Registry.Register(r.certificates); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if !errors.As(err, &registered) {
+			return err
+		}
+		if existing, ok := registered.ExistingCollector.(*certificateExpirations); ok {
+			r.certificates = existing
+		}
+	}
+
+	// Repository hosts shared by clusters are reconciled by their own controller.
+	if err := (&sharedRepoHostReconciler{r}).setupWithManager(mgr); err != nil {
+		return err
+	}
+
+	// Certificates are renewed by their own controller on their own interval.
+	if err := (&certificateRotationReconciler{r}).setupWithManager(mgr); err != nil {
+		return err
+	}
+
+	// Collation versions are compared, and indexes rebuilt, by their own
+	// controller so that long rebuilds do not delay other reconciles.
+	if err := (&collationReconciler{r}).setupWithManager(mgr); err != nil {
+		return err
+	}
+
+	// Replication slots are checked by their own controller on their own
+	// interval.
+	if err := (&replicationSlotReconciler{r}).setupWithManager(mgr); err != nil {
+		return err
+	}
+
+	// WAL archiving is checked by its own controller on its own interval.
+	if err := (&archiveReconciler{r}).setupWithManager(mgr); err != nil {
+		return err
+	}
+
+	// Users are read from identity providers by their own controller on their
+	// own interval.
+	if err := (&userSyncReconciler{Reconciler: r}).setupWithManager(mgr); err != nil {
+		return err
+	}
+
+	// Events of objects other than the PostgresCluster itself are frequent and
+	// mostly confirm a steady state. Let degraded clusters go first when there
+	// are more of these than workers.
+	workers := opts.MaxConcurrentReconciles
+	owner := &handler.EnqueueRequestForOwner{
+		OwnerType: &v1beta1.PostgresCluster{}, IsController: true,
+	}
+
+	managedBy := builder.ControllerManagedBy(mgr).
 		For(&v1beta1.PostgresCluster{}).
-		WithOptions(opts).
-		Owns(&corev1.ConfigMap{}).
-		Owns(&corev1.Endpoints{}).
-		Owns(&corev1.PersistentVolumeClaim{}).
-		Owns(&corev1.Secret{}).
-		Owns(&corev1.Service{}).
-		Owns(&corev1.ServiceAccount{}).
-		Owns(&appsv1.Deployment{}).
-		Owns(&appsv1.StatefulSet{}).
-		Owns(&batchv1.Job{}).
-		Owns(&rbacv1.Role{}).
-		Owns(&rbacv1.RoleBinding{}).
-		Owns(&batchv1.CronJob{}).
-		Owns(&policyv1.PodDisruptionBudget{}).
-		Watches(&source.Kind{Type: &corev1.Pod{}}, r.watchPods()).
+		WithOptions(opts)
+
+	for _, object := range []client.Object{
+		&corev1.ConfigMap{},
+		&corev1.Endpoints{},
+		&corev1.PersistentVolumeClaim{},
+		&corev1.Secret{},
+		&corev1.Service{},
+		&corev1.ServiceAccount{},
+		&appsv1.Deployment{},
+		&appsv1.StatefulSet{},
+		&batchv1.Job{},
+		&rbacv1.Role{},
+		&rbacv1.RoleBinding{},
+		&batchv1.CronJob{},
+		&policyv1.PodDisruptionBudget{},
+	} {
+		managedBy = managedBy.Watches(&source.Kind{Type: object}, r.prioritize(owner, workers))
+	}
+
+	return managedBy.
+		Watches(&source.Kind{Type: &corev1.Pod{}},
+			r.prioritize(r.watchPods(), workers)).
 		Watches(&source.Kind{Type: &appsv1.StatefulSet{}},
-			r.controllerRefHandlerFuncs()). // watch all StatefulSets
+			r.prioritize(r.controllerRefHandlerFuncs(), workers)). // watch all StatefulSets
+		Watches(&source.Kind{Type: &v1beta1.PostgresCluster{}},
+			r.watchDataSourceClusters()). // watch clusters being restored from
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			r.watchConfigIncludes()). // watch pgBackRest configuration to include
 		Complete(r)
 }
@@ -55,6 +55,49 @@ func (r *Reconciler) adoptObject(ctx context.Context, postgresCluster *v1beta1.P
 	})
 }
 
+// adoptStaleObject adopts the provided Object by replacing the controller owner ref of a
+// previous PostgresCluster with one for the provided PostgresCluster.
+func (r *Reconciler) adoptStaleObject(ctx context.Context,
+	postgresCluster *v1beta1.PostgresCluster, obj client.Object) error {
+
+	stale := metav1.GetControllerOfNoCopy(obj).UID
+	refs := make([]metav1.OwnerReference, 0, len(obj.GetOwnerReferences()))
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID != stale {
+			refs = append(refs, ref)
+		}
+	}
+	obj.SetOwnerReferences(refs)
+
+	if err := controllerutil.SetControllerReference(postgresCluster, obj,
+		r.Client.Scheme()); err != nil {
+		return err
+	}
+
+	// A merge patch replaces the entire list, removing the stale owner ref. The resource
+	// version guards against overwriting owner refs that changed since obj was read.
+	patchBytes, err := kubeapi.NewMergePatch().
+		Add("metadata", "ownerReferences")(obj.GetOwnerReferences()).
+		Add("metadata", "resourceVersion")(obj.GetResourceVersion()).Bytes()
+	if err != nil {
+		return err
+	}
+
+	return r.Client.Patch(ctx, obj, client.RawPatch(types.MergePatchType,
+		patchBytes), &client.PatchOptions{
+		FieldManager: ControllerName,
+	})
+}
+
+// isStaleControllerRef returns whether ref refers to a PostgresCluster that has the same
+// name as postgresCluster but is not postgresCluster. Names are unique within a namespace,
+// so that PostgresCluster has been deleted.
+func isStaleControllerRef(postgresCluster *v1beta1.PostgresCluster, ref metav1.OwnerReference) bool {
+	return ref.Kind == "PostgresCluster" &&
+		ref.Name == postgresCluster.GetName() &&
+		ref.UID != postgresCluster.GetUID()
+}
+
 // claimObject is responsible for adopting or releasing Objects based on their current
 // controller ownership and whether or not they meet the provided labeling requirements.
 // This solution is modeled after the ControllerRefManager logic as found within the controller
@@ -69,6 +112,16 @@ func (r *Reconciler) claimObject(ctx context.Context, postgresCluster *v1beta1.P
 
 	controllerRef := metav1.GetControllerOfNoCopy(obj)
 	if controllerRef != nil {
+		// if owned by a previous PostgresCluster of the same name, then adopt when the
+		// PostgresCluster asks for it
+		if isStaleControllerRef(postgresCluster, *controllerRef) {
+			if _, adopt := postgresCluster.GetAnnotations()[naming.AdoptResources]; adopt &&
+				postgresCluster.GetDeletionTimestamp() == nil && obj.GetDeletionTimestamp() == nil {
+				return client.IgnoreNotFound(r.adoptStaleObject(ctx, postgresCluster, obj))
+			}
+			return nil
+		}
+
 		// if not owned by this postgrescluster then ignore
 		if controllerRef.UID != postgresCluster.GetUID() {
 			return nil
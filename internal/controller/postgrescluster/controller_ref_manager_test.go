@@ -128,6 +128,50 @@ func TestManageControllerRefs(t *testing.T) {
 		}
 	})
 
+	t.Run("adopt Object: previous PostgresCluster", func(t *testing.T) {
+
+		isTrue := true
+		obj := objBase.DeepCopy()
+		obj.Name = "adopt-stale"
+		obj.Labels = map[string]string{naming.LabelCluster: clusterName}
+		obj.OwnerReferences = append(obj.OwnerReferences, metav1.OwnerReference{
+			APIVersion:         "group/version",
+			Kind:               "PostgresCluster",
+			Name:               clusterName,
+			UID:                "previous-uid",
+			Controller:         &isTrue,
+			BlockOwnerDeletion: &isTrue,
+		})
+
+		if err := r.Client.Create(ctx, obj); err != nil {
+			t.Error(err)
+		}
+
+		// ignored until the PostgresCluster asks for adoption
+		if err := r.manageControllerRefs(ctx, obj); err != nil {
+			t.Error(err)
+		}
+		if err := tClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			t.Error(err)
+		}
+		if refs := obj.GetOwnerReferences(); len(refs) != 1 || refs[0].UID != "previous-uid" {
+			t.Errorf("expected previous controller ref, got %v", refs)
+		}
+
+		cluster := cluster.DeepCopy()
+		cluster.Annotations = map[string]string{naming.AdoptResources: ""}
+
+		if err := r.claimObject(ctx, cluster, obj); err != nil {
+			t.Error(err)
+		}
+		if err := tClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			t.Error(err)
+		}
+		if refs := obj.GetOwnerReferences(); len(refs) != 1 || refs[0].UID != cluster.GetUID() {
+			t.Errorf("expected current controller ref, got %v", refs)
+		}
+	})
+
 	t.Run("ignore Object: no matching labels or owner refs", func(t *testing.T) {
 
 		obj := objBase.DeepCopy()
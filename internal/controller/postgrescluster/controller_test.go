@@ -29,19 +29,27 @@ import (
 	. "github.com/onsi/gomega/gstruct"
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/yaml"
 
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/internal/util"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
@@ -101,6 +109,209 @@ func TestDeleteControlled(t *testing.T) {
 	})
 }
 
+func TestPatchStatus(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 1)
+
+	ns := setupNamespace(t, cc)
+	reconciler := Reconciler{Client: cc, Owner: client.FieldOwner(t.Name())}
+
+	cluster := testCluster()
+	cluster.Namespace = ns.Name
+	cluster.Name = strings.ToLower(t.Name())
+	assert.NilError(t, cc.Create(ctx, cluster))
+
+	// Two controllers read the same cluster.
+	one, two := cluster.DeepCopy(), cluster.DeepCopy()
+	beforeOne, beforeTwo := one.DeepCopy(), two.DeepCopy()
+
+	setCondition := func(status *v1beta1.PostgresClusterStatus, kind string) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type: kind, Status: metav1.ConditionTrue, Reason: "Testing",
+		})
+	}
+
+	setCondition(&one.Status, "One")
+	assert.NilError(t, reconciler.patchStatus(ctx, beforeOne, one))
+
+	// The second patch conflicts and is redone on the latest status.
+	setCondition(&two.Status, "Two")
+	assert.NilError(t, reconciler.patchStatus(ctx, beforeTwo, two))
+
+	assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+	assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions, "One") != nil)
+	assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions, "Two") != nil)
+
+	// Nothing is written when nothing changed.
+	version := cluster.ResourceVersion
+	assert.NilError(t, reconciler.patchStatus(ctx, cluster, cluster.DeepCopy()))
+	assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+	assert.Equal(t, cluster.ResourceVersion, version)
+}
+
+func TestRedoStatusChanges(t *testing.T) {
+	condition := func(kind, reason string) metav1.Condition {
+		return metav1.Condition{Type: kind, Status: metav1.ConditionTrue, Reason: reason}
+	}
+
+	before := v1beta1.PostgresClusterStatus{
+		ObservedGeneration: 1,
+		Conditions:         []metav1.Condition{condition("Mine", "Old"), condition("Gone", "Old")},
+	}
+	after := *before.DeepCopy()
+	after.ObservedGeneration = 2
+	after.Conditions = []metav1.Condition{condition("Mine", "New"), condition("Added", "New")}
+
+	// Another controller changed the status in the meantime.
+	latest := *before.DeepCopy()
+	latest.PostgresVersion = 14
+	latest.Conditions = append(latest.Conditions, condition("Theirs", "Other"))
+
+	assert.NilError(t, redoStatusChanges(&latest, before, after))
+	assert.Equal(t, latest.ObservedGeneration, int64(2))
+	assert.Equal(t, latest.PostgresVersion, 14)
+
+	assert.Equal(t, len(latest.Conditions), 3)
+	assert.Equal(t, meta.FindStatusCondition(latest.Conditions, "Mine").Reason, "New")
+	assert.Equal(t, meta.FindStatusCondition(latest.Conditions, "Added").Reason, "New")
+	assert.Equal(t, meta.FindStatusCondition(latest.Conditions, "Theirs").Reason, "Other")
+	assert.Assert(t, meta.FindStatusCondition(latest.Conditions, "Gone") == nil)
+}
+
+func TestReconcilePhases(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := trace.NewTracerProvider(trace.WithSpanProcessor(recorder))
+	tracer := provider.Tracer(t.Name())
+
+	ctx, parent := tracer.Start(context.Background(), "Reconcile")
+
+	var err error
+	var ran []string
+	phase := reconcilePhases(ctx, tracer, &err)
+
+	phase("first", func(ctx context.Context) error {
+		ran = append(ran, "first")
+		return nil
+	})
+	phase("second", func(ctx context.Context) error {
+		ran = append(ran, "second")
+		return errors.New("boom")
+	})
+	phase("third", func(ctx context.Context) error {
+		ran = append(ran, "third")
+		return nil
+	})
+	parent.End()
+
+	// Phases after a failure are skipped, and the failure is returned.
+	assert.DeepEqual(t, ran, []string{"first", "second"})
+	assert.ErrorContains(t, err, "boom")
+
+	spans := recorder.Ended()
+	assert.Equal(t, len(spans), 3)
+	assert.Equal(t, spans[0].Name(), "first")
+	assert.Equal(t, spans[1].Name(), "second")
+	assert.Equal(t, spans[2].Name(), "Reconcile")
+
+	// Each phase is a child of the span that was in ctx.
+	for _, span := range spans[:2] {
+		assert.Equal(t, span.Parent().SpanID(), parent.SpanContext().SpanID())
+	}
+
+	// Only the phase that failed records an error.
+	assert.Equal(t, len(spans[0].Events()), 0)
+	assert.Equal(t, len(spans[1].Events()), 1)
+
+	event := spans[1].Events()[0]
+	assert.Equal(t, event.Name, "exception")
+	assert.Assert(t, cmp.Contains(event.Attributes,
+		attribute.String("exception.message", "boom")))
+}
+
+func TestValidateStandby(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Name = "hippo"
+	assert.NilError(t, validateStandby(cluster), "no standby")
+
+	cluster.Spec.Standby = &v1beta1.PostgresStandbySpec{Enabled: false}
+	assert.NilError(t, validateStandby(cluster), "disabled")
+
+	cluster.Spec.Standby.Enabled = true
+	assert.ErrorContains(t, validateStandby(cluster), "requires a host or repoName")
+
+	cluster.Spec.Standby.Host = "primary.example.com"
+	assert.NilError(t, validateStandby(cluster), "host")
+
+	cluster.Spec.Standby.Host = ""
+	cluster.Spec.Standby.RepoName = "repo2"
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{Name: "repo1"}}
+	err := validateStandby(cluster)
+	assert.ErrorContains(t, err, "spec.standby.repoName")
+	assert.ErrorContains(t, err, "must be defined in spec.backups.pgbackrest.repos")
+
+	cluster.Spec.Backups.PGBackRest.Repos = append(
+		cluster.Spec.Backups.PGBackRest.Repos, v1beta1.PGBackRestRepo{Name: "repo2"})
+	assert.NilError(t, validateStandby(cluster), "defined repo")
+}
+
+func TestReconcileInvalidStandby(t *testing.T) {
+	ctx := context.Background()
+
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := testCluster()
+	cluster.Namespace = "standby"
+	cluster.Finalizers = []string{naming.Finalizer}
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{Name: "repo1"}}
+	cluster.Spec.Standby = &v1beta1.PostgresStandbySpec{Enabled: true, RepoName: "repo2"}
+
+	cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster).Build()
+	recorder := events.NewRecorder(t, scheme)
+	r := &Reconciler{Client: cc, Recorder: recorder, Tracer: otel.Tracer(t.Name())}
+
+	_, err = r.Reconcile(ctx, reconcile.Request{
+		NamespacedName: client.ObjectKeyFromObject(cluster),
+	})
+	assert.ErrorContains(t, err, "spec.standby.repoName")
+
+	// The problem is reported in an event on the cluster.
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+	assert.Equal(t, recorder.Events[0].Reason, "InvalidStandbyConfiguration")
+	assert.Assert(t, strings.Contains(recorder.Events[0].Note,
+		"must be defined in spec.backups.pgbackrest.repos"), "%q", recorder.Events[0].Note)
+	assert.Equal(t, recorder.Events[0].Regarding.Name, cluster.Name)
+
+	// Nothing else is reconciled, so the status and its conditions do not change.
+	latest := &v1beta1.PostgresCluster{}
+	assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cluster), latest))
+	assert.DeepEqual(t, latest.Status, cluster.Status)
+	assert.Equal(t, len(latest.Status.Conditions), 0)
+}
+
+func TestValidateAuthentication(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	assert.NilError(t, validateAuthentication(cluster), "no authentication")
+
+	cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+		PasswordEncryption: "md5",
+	}
+	assert.NilError(t, validateAuthentication(cluster), "md5")
+
+	cluster.Spec.Authentication.ChannelBinding = "require"
+	err := validateAuthentication(cluster)
+	assert.ErrorContains(t, err, "spec.authentication.channelBinding")
+	assert.ErrorContains(t, err, `requires passwordEncryption "scram-sha-256"`)
+
+	cluster.Spec.Authentication.PasswordEncryption = ""
+	assert.NilError(t, validateAuthentication(cluster), "default encryption")
+
+	cluster.Spec.Authentication.PasswordEncryption = "scram-sha-256"
+	assert.NilError(t, validateAuthentication(cluster), "scram-sha-256")
+}
+
 var _ = Describe("PostgresCluster Reconciler", func() {
 	var test struct {
 		Namespace  *corev1.Namespace
@@ -271,6 +482,8 @@ spec:
 			)).To(Succeed())
 
 			Expect(existing.Status.ObservedGeneration).To(Equal(cluster.Generation))
+			Expect(existing.Generation).To(Equal(cluster.Generation),
+				"controller should never change the spec")
 
 			// The interaction between server-side apply and subresources can have
 			// unexpected results. However we manipulate Status, the controller must
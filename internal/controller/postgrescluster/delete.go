@@ -96,6 +96,12 @@ func (r *Reconciler) handleDelete(
 		return nil, err
 	}
 
+	// Remote instance sets stream from this cluster and are in other
+	// namespaces where garbage collection cannot find them.
+	if err := r.deleteRemoteInstanceSets(ctx, cluster, sets.NewString()); err != nil {
+		return nil, err
+	}
+
 	// Our finalizer logic is finished; remove our finalizer.
 	// The Finalizers field is shared by multiple controllers, but the
 	// server-side merge strategy does not work on our custom resource due to a
@@ -0,0 +1,151 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// disruptionSurge is the state of an instance set that has one more instance
+// than its replicas while its pods are disrupted. See the DisruptionSurge field
+// of [v1beta1.PostgresInstanceSetSpec].
+type disruptionSurge struct {
+	// wanted is true while the set should have its temporary instance.
+	wanted bool
+
+	// minAvailable is the number of pods that the PodDisruptionBudget of the
+	// set keeps available.
+	minAvailable int32
+}
+
+// replicas returns the number of instances that set should have.
+func (s *disruptionSurge) replicas(set *v1beta1.PostgresInstanceSetSpec) int {
+	if s != nil && s.wanted {
+		return int(*set.Replicas) + 1
+	}
+	return int(*set.Replicas)
+}
+
+// disruptionSurgeApplies returns whether or not set keeps its only replica
+// during voluntary disruptions.
+func disruptionSurgeApplies(set *v1beta1.PostgresInstanceSetSpec) bool {
+	return set.DisruptionSurge != nil && *set.DisruptionSurge &&
+		set.Replicas != nil && *set.Replicas == 2 && set.MinAvailable == nil
+}
+
+// +kubebuilder:rbac:groups="",resources="nodes",verbs={get}
+
+// observeDisruptionSurges returns the disruption surge of each instance set
+// of cluster that has one enabled, keyed by set name. A set has none when PGO
+// is not allowed to get the nodes of its pods.
+func (r *Reconciler) observeDisruptionSurges(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, observed *observedInstances,
+) (map[string]*disruptionSurge, error) {
+	log := logging.FromContext(ctx)
+	surges := make(map[string]*disruptionSurge)
+
+	// Nodes are cordoned before they are drained, so a pod on a node that is
+	// unschedulable is about to be evicted.
+	// - https://kubernetes.io/docs/tasks/administer-cluster/safely-drain-node/
+	cordoned := make(map[string]bool)
+	isCordoned := func(name string) (bool, error) {
+		if value, ok := cordoned[name]; ok {
+			return value, nil
+		}
+		node := &corev1.Node{}
+		err := errors.WithStack(r.Client.Get(ctx, client.ObjectKey{Name: name}, node))
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err == nil {
+			cordoned[name] = node.Spec.Unschedulable
+		}
+		return cordoned[name], err
+	}
+
+	var primaryPosition int64
+	var primaryKnown bool
+	for _, instance := range observed.forCluster {
+		if primary, known := instance.IsPrimary(); primary && known {
+			primaryPosition, primaryKnown = instance.WALPosition()
+		}
+	}
+
+	for i := range cluster.Spec.InstanceSets {
+		set := &cluster.Spec.InstanceSets[i]
+		if !disruptionSurgeApplies(set) {
+			continue
+		}
+
+		var disrupted bool
+		var err error
+		instances := observed.bySet[set.Name]
+
+		// The temporary instance is ready to take over when every instance of
+		// the set is ready and has caught up to the primary.
+		caughtUp := len(instances) > int(*set.Replicas)
+
+		for _, instance := range instances {
+			if len(instance.Pods) > 0 && instance.Pods[0].Spec.NodeName != "" && err == nil {
+				var node bool
+				node, err = isCordoned(instance.Pods[0].Spec.NodeName)
+				disrupted = disrupted || node
+			}
+			if ready, known := instance.IsReady(); !ready || !known {
+				caughtUp = false
+			}
+			if terminating, known := instance.IsTerminating(); terminating || !known {
+				caughtUp = false
+			}
+			if !replicationCaughtUp(instance, primaryPosition, primaryKnown) {
+				caughtUp = false
+			}
+		}
+
+		if apierrors.IsForbidden(err) {
+			log.V(1).Info("unable to get nodes; skipping disruption surge",
+				"instance-set", set.Name)
+			continue
+		}
+		if err != nil {
+			return surges, err
+		}
+
+		// Keep the temporary instance until the others are running on
+		// schedulable nodes and have caught up again.
+		surge := &disruptionSurge{minAvailable: *set.Replicas + 1}
+		surge.wanted = disrupted || (len(instances) > int(*set.Replicas) && !caughtUp)
+		if caughtUp {
+			surge.minAvailable = *set.Replicas
+		}
+
+		if surge.wanted && len(instances) <= int(*set.Replicas) {
+			r.Recorder.Eventf(cluster, corev1.EventTypeNormal, "DisruptionSurge",
+				"Adding a temporary instance to set %q while its pods are disrupted", set.Name)
+		}
+		surges[set.Name] = surge
+	}
+
+	return surges, nil
+}
@@ -0,0 +1,209 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// forbiddenNodes refuses to get nodes.
+type forbiddenNodes struct{ client.Client }
+
+func (c forbiddenNodes) Get(
+	ctx context.Context, key client.ObjectKey, obj client.Object,
+) error {
+	if _, ok := obj.(*corev1.Node); ok {
+		return apierrors.NewForbidden(corev1.Resource("nodes"), key.Name, nil)
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestDisruptionSurgeReplicas(t *testing.T) {
+	set := &v1beta1.PostgresInstanceSetSpec{Replicas: initialize.Int32(2)}
+
+	var none *disruptionSurge
+	assert.Equal(t, none.replicas(set), 2)
+	assert.Equal(t, (&disruptionSurge{}).replicas(set), 2)
+	assert.Equal(t, (&disruptionSurge{wanted: true}).replicas(set), 3)
+}
+
+func TestObserveDisruptionSurges(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{
+		{Name: "one", Replicas: initialize.Int32(2), DisruptionSurge: initialize.Bool(true)},
+		{Name: "two", Replicas: initialize.Int32(2)},
+	}
+
+	nodes := []client.Object{
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node3"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cordoned"},
+			Spec: corev1.NodeSpec{Unschedulable: true}},
+	}
+
+	// instance returns a ready instance of set "one" on node that has
+	// replicated WAL up to position.
+	instance := func(name, node, role string, position int) *Instance {
+		return &Instance{
+			Name: name,
+			Spec: &cluster.Spec.InstanceSets[0],
+			Pods: []*corev1.Pod{{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   name + "-0",
+					Labels: map[string]string{naming.LabelRole: role},
+					Annotations: map[string]string{"status": fmt.Sprintf(
+						`{"role":%q,"state":"running","xlog_location":%d}`, role, position)},
+				},
+				Spec: corev1.PodSpec{NodeName: node},
+				Status: corev1.PodStatus{
+					Conditions: []corev1.PodCondition{{
+						Type: corev1.PodReady, Status: corev1.ConditionTrue,
+					}},
+				},
+			}},
+		}
+	}
+	observe := func(instances ...*Instance) *observedInstances {
+		return &observedInstances{
+			forCluster: instances,
+			bySet:      map[string][]*Instance{"one": instances},
+		}
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(nodes...).Build(),
+		Recorder: recorder,
+	}
+
+	t.Run("Undisrupted", func(t *testing.T) {
+		surges, err := reconciler.observeDisruptionSurges(ctx, cluster, observe(
+			instance("a", "node1", "master", 5000),
+			instance("b", "node2", "replica", 5000),
+		))
+		assert.NilError(t, err)
+		assert.Equal(t, len(surges), 1)
+		assert.Equal(t, surges["one"].replicas(&cluster.Spec.InstanceSets[0]), 2)
+		assert.Equal(t, surges["one"].minAvailable, int32(3), "expected no evictions")
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Cordoned", func(t *testing.T) {
+		surges, err := reconciler.observeDisruptionSurges(ctx, cluster, observe(
+			instance("a", "node1", "master", 5000),
+			instance("b", "cordoned", "replica", 5000),
+		))
+		assert.NilError(t, err)
+		assert.Equal(t, surges["one"].replicas(&cluster.Spec.InstanceSets[0]), 3)
+		assert.Equal(t, surges["one"].minAvailable, int32(3), "expected no evictions")
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, <-recorder.Events,
+			`Normal DisruptionSurge Adding a temporary instance to set "one" while its pods are disrupted`)
+	})
+
+	t.Run("CaughtUp", func(t *testing.T) {
+		surges, err := reconciler.observeDisruptionSurges(ctx, cluster, observe(
+			instance("a", "node1", "master", 5000),
+			instance("b", "cordoned", "replica", 5000),
+			instance("c", "node3", "replica", 5000),
+		))
+		assert.NilError(t, err)
+		assert.Equal(t, surges["one"].replicas(&cluster.Spec.InstanceSets[0]), 3)
+		assert.Equal(t, surges["one"].minAvailable, int32(2), "expected one eviction")
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("LaggingBehind", func(t *testing.T) {
+		surges, err := reconciler.observeDisruptionSurges(ctx, cluster, observe(
+			instance("a", "node1", "master", 50<<20),
+			instance("b", "cordoned", "replica", 50<<20),
+			instance("c", "node3", "replica", 5000),
+		))
+		assert.NilError(t, err)
+		assert.Equal(t, surges["one"].minAvailable, int32(3), "expected no evictions")
+	})
+
+	t.Run("Recovering", func(t *testing.T) {
+		// The evicted pod is not yet ready on another node.
+		recovering := instance("b", "node2", "replica", 5000)
+		recovering.Pods[0].Status.Conditions[0].Status = corev1.ConditionFalse
+
+		surges, err := reconciler.observeDisruptionSurges(ctx, cluster, observe(
+			instance("a", "node1", "master", 5000),
+			recovering,
+			instance("c", "node3", "replica", 5000),
+		))
+		assert.NilError(t, err)
+		assert.Equal(t, surges["one"].replicas(&cluster.Spec.InstanceSets[0]), 3)
+		assert.Equal(t, surges["one"].minAvailable, int32(3), "expected no evictions")
+	})
+
+	t.Run("Recovered", func(t *testing.T) {
+		surges, err := reconciler.observeDisruptionSurges(ctx, cluster, observe(
+			instance("a", "node1", "master", 5000),
+			instance("b", "node2", "replica", 5000),
+			instance("c", "node3", "replica", 5000),
+		))
+		assert.NilError(t, err)
+		assert.Equal(t, surges["one"].replicas(&cluster.Spec.InstanceSets[0]), 2)
+	})
+
+	t.Run("Forbidden", func(t *testing.T) {
+		reconciler := &Reconciler{
+			Client:   forbiddenNodes{reconciler.Client},
+			Recorder: recorder,
+		}
+
+		surges, err := reconciler.observeDisruptionSurges(ctx, cluster, observe(
+			instance("a", "node1", "master", 5000),
+			instance("b", "cordoned", "replica", 5000),
+		))
+		assert.NilError(t, err)
+		assert.Equal(t, len(surges), 0)
+	})
+
+	t.Run("MinAvailable", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.InstanceSets[0].MinAvailable = initialize.IntOrStringInt32(1)
+
+		surges, err := reconciler.observeDisruptionSurges(ctx, cluster, observe(
+			instance("a", "node1", "master", 5000),
+			instance("b", "cordoned", "replica", 5000),
+		))
+		assert.NilError(t, err)
+		assert.Equal(t, len(surges), 0)
+	})
+}
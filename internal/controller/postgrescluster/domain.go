@@ -0,0 +1,173 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// +kubebuilder:rbac:groups="",resources="configmaps",verbs={list,patch}
+// +kubebuilder:rbac:groups="",resources="endpoints",verbs={list,patch}
+// +kubebuilder:rbac:groups="",resources="persistentvolumeclaims",verbs={list,patch}
+// +kubebuilder:rbac:groups="",resources="pods",verbs={list,patch}
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={list,patch}
+// +kubebuilder:rbac:groups="",resources="serviceaccounts",verbs={list,patch}
+// +kubebuilder:rbac:groups="",resources="services",verbs={list,patch}
+// +kubebuilder:rbac:groups="apps",resources="deployments",verbs={delete,list,patch}
+// +kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={delete,list,patch}
+// +kubebuilder:rbac:groups="batch",resources="cronjobs",verbs={list,patch}
+// +kubebuilder:rbac:groups="batch",resources="jobs",verbs={list,patch}
+// +kubebuilder:rbac:groups="policy",resources="poddisruptionbudgets",verbs={list,patch}
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={patch}
+
+// reconcileLabelDomain moves cluster and the objects it owns from the default
+// label domain to the one in PGO_LABEL_DOMAIN. See [Reconciler.moveLabelDomain].
+func (r *Reconciler) reconcileLabelDomain(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	return r.moveLabelDomain(ctx, cluster, naming.DefaultLabelDomain)
+}
+
+// moveLabelDomain copies the labels and annotations in domain on the objects
+// of cluster to the current label domain. StatefulSets and Deployments cannot
+// change their selectors, so those that select on domain are deleted without
+// deleting their Pods; later steps create them again and they adopt the Pods.
+// The finalizer of cluster moves last, so objects are only listed until every
+// one of them has been moved.
+func (r *Reconciler) moveLabelDomain(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, domain string,
+) error {
+	finalizers := sets.NewString(cluster.Finalizers...)
+	legacyFinalizer := naming.LabelInDomain(domain, naming.Finalizer)
+	if domain == naming.LabelDomain() || !finalizers.Has(legacyFinalizer) {
+		return nil
+	}
+
+	prefix := domain + "/"
+	moved := func(keys map[string]string) (map[string]string, bool) {
+		result := make(map[string]string, len(keys))
+		changed := false
+		for key, value := range keys {
+			result[key] = value
+		}
+		for key, value := range keys {
+			if name := strings.TrimPrefix(key, prefix); name != key {
+				if _, ok := keys[naming.LabelDomain()+"/"+name]; !ok {
+					result[naming.LabelDomain()+"/"+name] = value
+					changed = true
+				}
+			}
+		}
+		return result, changed
+	}
+
+	gvks := []schema.GroupVersionKind{
+		corev1.SchemeGroupVersion.WithKind("ConfigMapList"),
+		corev1.SchemeGroupVersion.WithKind("EndpointsList"),
+		corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaimList"),
+		corev1.SchemeGroupVersion.WithKind("PodList"),
+		corev1.SchemeGroupVersion.WithKind("SecretList"),
+		corev1.SchemeGroupVersion.WithKind("ServiceAccountList"),
+		corev1.SchemeGroupVersion.WithKind("ServiceList"),
+		appsv1.SchemeGroupVersion.WithKind("DeploymentList"),
+		appsv1.SchemeGroupVersion.WithKind("StatefulSetList"),
+		batchv1.SchemeGroupVersion.WithKind("CronJobList"),
+		batchv1.SchemeGroupVersion.WithKind("JobList"),
+		policyv1.SchemeGroupVersion.WithKind("PodDisruptionBudgetList"),
+	}
+
+	selector := client.MatchingLabels{
+		naming.LabelInDomain(domain, naming.LabelCluster): cluster.Name,
+	}
+	for _, gvk := range gvks {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk)
+
+		if err := errors.WithStack(r.Client.List(ctx, list,
+			client.InNamespace(cluster.Namespace), selector)); err != nil {
+			return err
+		}
+
+		for i := range list.Items {
+			object := &list.Items[i]
+
+			if gvk.Group == appsv1.GroupName {
+				matchLabels, _, _ := unstructured.NestedStringMap(
+					object.Object, "spec", "selector", "matchLabels")
+
+				if _, changed := moved(matchLabels); changed {
+					uid := object.GetUID()
+					if err := errors.WithStack(client.IgnoreNotFound(
+						r.Client.Delete(ctx, object,
+							client.Preconditions{UID: &uid},
+							client.PropagationPolicy(metav1.DeletePropagationOrphan),
+						))); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+
+			labels, labelsChanged := moved(object.GetLabels())
+			annotations, annotationsChanged := moved(object.GetAnnotations())
+
+			if labelsChanged || annotationsChanged {
+				before := object.DeepCopy()
+				if labelsChanged {
+					object.SetLabels(labels)
+				}
+				if annotationsChanged {
+					object.SetAnnotations(annotations)
+				}
+
+				if err := errors.WithStack(client.IgnoreNotFound(
+					r.patch(ctx, object, client.MergeFrom(before)))); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Replace the finalizer with one in the current domain. Build a merge-patch
+	// that includes the full list of Finalizers plus ResourceVersion to detect
+	// conflicts with other writers. See [Reconciler.handleDelete].
+	before := cluster.DeepCopy()
+	intent := before.DeepCopy()
+	intent.Finalizers = finalizers.Delete(legacyFinalizer).Insert(naming.Finalizer).List()
+
+	err := errors.WithStack(r.patch(ctx, intent,
+		client.MergeFromWithOptions(before, client.MergeFromWithOptimisticLock{})))
+	if err == nil {
+		cluster.Finalizers = intent.Finalizers
+		cluster.ResourceVersion = intent.ResourceVersion
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+//go:build envtest
+// +build envtest
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pgoruntime "github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestMoveLabelDomain(t *testing.T) {
+	ctx := context.Background()
+
+	scheme, err := pgoruntime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	const legacy = "pgo.example.com"
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Finalizers = []string{"other/finalizer", legacy + "/finalizer"}
+
+	configmap := &corev1.ConfigMap{}
+	configmap.Namespace, configmap.Name = "ns1", "hippo-config"
+	configmap.Labels = map[string]string{
+		legacy + "/cluster":      "hippo",
+		legacy + "/role":         "pgdata",
+		"app.kubernetes.io/name": "postgres",
+	}
+	configmap.Annotations = map[string]string{legacy + "/pgbackrest-hash": "abc"}
+
+	statefulset := &appsv1.StatefulSet{}
+	statefulset.Namespace, statefulset.Name = "ns1", "hippo-instance"
+	statefulset.Labels = map[string]string{legacy + "/cluster": "hippo"}
+	statefulset.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{legacy + "/instance": "hippo-instance"},
+	}
+
+	other := &corev1.ConfigMap{}
+	other.Namespace, other.Name = "ns1", "other-config"
+	other.Labels = map[string]string{legacy + "/cluster": "other"}
+
+	cc := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(cluster, configmap, statefulset, other).Build()
+	r := &Reconciler{Client: cc, Owner: client.FieldOwner(t.Name())}
+
+	t.Run("CurrentDomain", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		assert.NilError(t, r.moveLabelDomain(ctx, cluster, naming.LabelDomain()))
+		assert.DeepEqual(t, cluster.Finalizers, []string{"other/finalizer", legacy + "/finalizer"})
+	})
+
+	assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+	assert.NilError(t, r.moveLabelDomain(ctx, cluster, legacy))
+
+	assert.DeepEqual(t, cluster.Finalizers, []string{"other/finalizer", naming.Finalizer})
+	assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+	assert.DeepEqual(t, cluster.Finalizers, []string{"other/finalizer", naming.Finalizer})
+
+	// Labels and annotations are copied to the current domain.
+	assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(configmap), configmap))
+	assert.DeepEqual(t, configmap.Labels, map[string]string{
+		legacy + "/cluster":      "hippo",
+		legacy + "/role":         "pgdata",
+		naming.LabelCluster:      "hippo",
+		naming.LabelRole:         "pgdata",
+		"app.kubernetes.io/name": "postgres",
+	})
+	assert.DeepEqual(t, configmap.Annotations, map[string]string{
+		legacy + "/pgbackrest-hash": "abc",
+		naming.PGBackRestConfigHash: "abc",
+	})
+
+	// StatefulSets that select on the legacy domain are deleted.
+	err = cc.Get(ctx, client.ObjectKeyFromObject(statefulset), statefulset)
+	assert.Assert(t, apierrors.IsNotFound(err), "got %#v", err)
+
+	// Objects of other clusters are untouched.
+	assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(other), other))
+	assert.DeepEqual(t, other.Labels, map[string]string{legacy + "/cluster": "other"})
+
+	// Nothing happens once the finalizer has moved.
+	configmap.Labels = map[string]string{legacy + "/cluster": "hippo"}
+	assert.NilError(t, cc.Update(ctx, configmap))
+	assert.NilError(t, r.moveLabelDomain(ctx, cluster, legacy))
+	assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(configmap), configmap))
+	assert.DeepEqual(t, configmap.Labels, map[string]string{legacy + "/cluster": "hippo"})
+}
@@ -0,0 +1,151 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgmonitor"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// extensionAllowlist returns the extension names in value, a comma-separated
+// list. It returns nil, which allows every extension, when value has none.
+func extensionAllowlist(value string) sets.String {
+	var allowed sets.String
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			if allowed == nil {
+				allowed = sets.NewString()
+			}
+			allowed.Insert(name)
+		}
+	}
+	return allowed
+}
+
+// extensionAllowed reports whether or not clusters may request the extension
+// named name.
+func (r *Reconciler) extensionAllowed(name string) bool {
+	return r.allowedExtensions == nil || r.allowedExtensions.Has(name)
+}
+
+// requestedLibraries returns the shared libraries of the allowed extensions
+// in the databases of cluster.
+func (r *Reconciler) requestedLibraries(cluster *v1beta1.PostgresCluster) sets.String {
+	libraries := sets.NewString()
+	for _, database := range cluster.Spec.Databases {
+		for _, extension := range database.Extensions {
+			if extension.Preload != "" && r.extensionAllowed(string(extension.Name)) {
+				libraries.Insert(extension.Preload)
+			}
+		}
+	}
+	return libraries
+}
+
+// requestedExtensions returns the extensions to create in each database of
+// cluster, keyed by database name. Extensions that need a shared library are
+// left out until that library is recorded in cluster.Status. It also returns
+// the names of extensions that are not allowed.
+func (r *Reconciler) requestedExtensions(
+	cluster *v1beta1.PostgresCluster,
+) (map[string][]string, []string) {
+	loaded := sets.NewString(cluster.Status.PreloadLibraries...)
+	disallowed := sets.NewString()
+	extensions := make(map[string][]string)
+
+	for _, database := range cluster.Spec.Databases {
+		for _, extension := range database.Extensions {
+			name := string(extension.Name)
+			switch {
+			case !r.extensionAllowed(name):
+				disallowed.Insert(name)
+			case extension.Preload == "" || loaded.Has(extension.Preload):
+				extensions[string(database.Name)] = append(extensions[string(database.Name)], name)
+			}
+		}
+	}
+	return extensions, disallowed.List()
+}
+
+// preloadExtensionLibraries adds to outParameters the shared libraries of
+// requested extensions that were found in the PostgreSQL image.
+func (r *Reconciler) preloadExtensionLibraries(
+	cluster *v1beta1.PostgresCluster, outParameters *postgres.Parameters,
+) {
+	found := sets.NewString(cluster.Status.PreloadLibraries...).
+		Intersection(r.requestedLibraries(cluster))
+
+	if found.Len() > 0 {
+		libraries := found.List()
+
+		defined, ok := outParameters.Mandatory.Get("shared_preload_libraries")
+		if ok {
+			libraries = append(libraries, defined)
+		}
+
+		outParameters.Mandatory.Add("shared_preload_libraries", strings.Join(libraries, ","))
+	}
+}
+
+// reconcileExtensionLibraries records in cluster.Status the shared libraries
+// of requested extensions that are in the PostgreSQL image. PostgreSQL does not
+// start when a preloaded library is missing, so each library is loaded only
+// after it is found. Libraries that are no longer requested are forgotten.
+func (r *Reconciler) reconcileExtensionLibraries(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) error {
+	requested := r.requestedLibraries(cluster)
+	found := sets.NewString(cluster.Status.PreloadLibraries...).Intersection(requested)
+	missing := requested.Difference(found)
+
+	// Look for missing libraries in the PostgreSQL instance that is running
+	// and writable. When there is none, look again later.
+	pod, _ := instances.writablePod(naming.ContainerDatabase)
+
+	if missing.Len() > 0 && pod != nil {
+		exec := func(_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+			return r.PodExec(pod.Namespace, pod.Name, naming.ContainerDatabase, stdin, stdout, stderr, command...)
+		}
+
+		for _, library := range missing.List() {
+			ok, err := pgmonitor.Executor(exec).HasPostgreSQLLibrary(ctx, library)
+			if err != nil {
+				return err
+			}
+			if ok {
+				found.Insert(library)
+			} else {
+				r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "MissingLibrary",
+					"The %s library is not in the PostgreSQL image; it will not be loaded", library)
+			}
+		}
+	}
+
+	cluster.Status.PreloadLibraries = nil
+	if found.Len() > 0 {
+		cluster.Status.PreloadLibraries = found.List()
+	}
+	return nil
+}
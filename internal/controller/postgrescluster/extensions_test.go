@@ -0,0 +1,191 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestExtensionAllowlist(t *testing.T) {
+	assert.Assert(t, extensionAllowlist("") == nil)
+	assert.Assert(t, extensionAllowlist(" , ") == nil)
+	assert.DeepEqual(t, extensionAllowlist(" vector, pg_cron,,").List(),
+		[]string{"pg_cron", "vector"})
+}
+
+func TestRequestedExtensions(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.Databases = []v1beta1.PostgresDatabaseSpec{
+		{Name: "one", Extensions: []v1beta1.PostgresExtensionSpec{
+			{Name: "vector"},
+			{Name: "pg_cron", Preload: "pg_cron"},
+		}},
+		{Name: "two", Extensions: []v1beta1.PostgresExtensionSpec{
+			{Name: "plpython3u"},
+		}},
+	}
+
+	t.Run("AllAllowed", func(t *testing.T) {
+		r := &Reconciler{}
+
+		extensions, disallowed := r.requestedExtensions(cluster)
+		assert.DeepEqual(t, extensions, map[string][]string{
+			"one": {"vector"},
+			"two": {"plpython3u"},
+		})
+		assert.Equal(t, len(disallowed), 0)
+		assert.DeepEqual(t, r.requestedLibraries(cluster).List(), []string{"pg_cron"})
+
+		// Extensions that need a library are created after it is found.
+		loaded := cluster.DeepCopy()
+		loaded.Status.PreloadLibraries = []string{"pg_cron"}
+
+		extensions, _ = r.requestedExtensions(loaded)
+		assert.DeepEqual(t, extensions, map[string][]string{
+			"one": {"vector", "pg_cron"},
+			"two": {"plpython3u"},
+		})
+	})
+
+	t.Run("Allowlist", func(t *testing.T) {
+		r := &Reconciler{allowedExtensions: extensionAllowlist("vector")}
+
+		extensions, disallowed := r.requestedExtensions(cluster)
+		assert.DeepEqual(t, extensions, map[string][]string{"one": {"vector"}})
+		assert.DeepEqual(t, disallowed, []string{"pg_cron", "plpython3u"})
+		assert.Equal(t, r.requestedLibraries(cluster).Len(), 0)
+	})
+}
+
+func TestPreloadExtensionLibraries(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.Databases = []v1beta1.PostgresDatabaseSpec{
+		{Name: "one", Extensions: []v1beta1.PostgresExtensionSpec{
+			{Name: "pg_cron", Preload: "pg_cron"},
+			{Name: "timescaledb", Preload: "timescaledb"},
+		}},
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		parameters := postgres.NewParameters()
+		(&Reconciler{}).preloadExtensionLibraries(cluster, &parameters)
+
+		_, found := parameters.Mandatory.Get("shared_preload_libraries")
+		assert.Assert(t, !found)
+	})
+
+	t.Run("Found", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Status.PreloadLibraries = []string{"pg_cron", "removed"}
+
+		parameters := postgres.NewParameters()
+		parameters.Mandatory.Add("shared_preload_libraries", "pgaudit")
+		(&Reconciler{}).preloadExtensionLibraries(cluster, &parameters)
+
+		libraries, _ := parameters.Mandatory.Get("shared_preload_libraries")
+		assert.Equal(t, libraries, "pg_cron,pgaudit")
+	})
+}
+
+func TestReconcileExtensionLibraries(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	var present []string
+	var calls int
+	recorder := events.NewRecorder(t, scheme)
+	reconciler := &Reconciler{
+		Recorder: recorder,
+		PodExec: func(namespace, pod, container string, stdin io.Reader, stdout,
+			stderr io.Writer, command ...string) error {
+			calls++
+			assert.Equal(t, container, naming.ContainerDatabase)
+			for _, library := range present {
+				if command[len(command)-1] == library {
+					_, _ = stdout.Write([]byte("found\n"))
+				}
+			}
+			return nil
+		},
+	}
+
+	instances := &observedInstances{forCluster: []*Instance{{
+		Name: "instance",
+		Pods: []*corev1.Pod{{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "ns1",
+				Name:        "pod",
+				Annotations: map[string]string{"status": `{"role":"master"}`},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: naming.ContainerDatabase,
+					State: corev1.ContainerState{
+						Running: new(corev1.ContainerStateRunning),
+					},
+				}},
+			},
+		}},
+	}}}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.Databases = []v1beta1.PostgresDatabaseSpec{
+		{Name: "one", Extensions: []v1beta1.PostgresExtensionSpec{
+			{Name: "vector"},
+			{Name: "pg_cron", Preload: "pg_cron"},
+			{Name: "timescaledb", Preload: "timescaledb"},
+		}},
+	}
+
+	// One library is missing.
+	present = []string{"pg_cron"}
+	assert.NilError(t, reconciler.reconcileExtensionLibraries(ctx, cluster, instances))
+	assert.Equal(t, calls, 2)
+	assert.DeepEqual(t, cluster.Status.PreloadLibraries, []string{"pg_cron"})
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, recorder.Events[0].Reason, "MissingLibrary")
+	assert.Assert(t, strings.Contains(recorder.Events[0].Note, "timescaledb"))
+
+	// Only the missing library is searched again.
+	present = []string{"pg_cron", "timescaledb"}
+	assert.NilError(t, reconciler.reconcileExtensionLibraries(ctx, cluster, instances))
+	assert.Equal(t, calls, 3)
+	assert.DeepEqual(t, cluster.Status.PreloadLibraries, []string{"pg_cron", "timescaledb"})
+
+	// All found; no need to look again.
+	assert.NilError(t, reconciler.reconcileExtensionLibraries(ctx, cluster, instances))
+	assert.Equal(t, calls, 3)
+
+	// No longer requested.
+	cluster.Spec.Databases = nil
+	assert.NilError(t, reconciler.reconcileExtensionLibraries(ctx, cluster, instances))
+	assert.Equal(t, calls, 3)
+	assert.Assert(t, cluster.Status.PreloadLibraries == nil)
+}
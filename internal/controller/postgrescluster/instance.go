@@ -17,6 +17,7 @@ package postgrescluster
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"sort"
@@ -145,6 +146,29 @@ func (i Instance) IsWritable() (writable, known bool) {
 	return strings.HasPrefix(member[role:], `"role":"master"`), true
 }
 
+// WALPosition returns the write-ahead log location that Patroni last reported
+// for this instance. On a replica, it is the latest location either received
+// or replayed. It is not known when PostgreSQL is not running.
+func (i Instance) WALPosition() (position int64, known bool) {
+	if len(i.Pods) != 1 {
+		return 0, false
+	}
+
+	var member struct {
+		State    string `json:"state"`
+		Location *int64 `json:"xlog_location"`
+	}
+
+	// Patroni stores its member data as JSON in the "status" annotation.
+	// - https://github.com/zalando/patroni/blob/v2.1.4/patroni/ha.py
+	err := json.Unmarshal([]byte(i.Pods[0].Annotations["status"]), &member)
+	if err != nil || member.Location == nil || member.State != "running" {
+		return 0, false
+	}
+
+	return *member.Location, true
+}
+
 // PodMatchesPodTemplate returns whether or not the Pod for this instance
 // matches its specified PodTemplate. When it does not match, the Pod needs to
 // be redeployed.
@@ -292,7 +316,7 @@ func (observed *observedInstances) writablePod(container string) (*corev1.Pod, *
 }
 
 // +kubebuilder:rbac:groups="",resources="pods",verbs={list}
-// +kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={list}
+// +kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={list,patch}
 
 // observeInstances populates cluster.Status.InstanceSets with observations and
 // builds an observedInstances by reading from the Kubernetes API.
@@ -318,6 +342,16 @@ func (r *Reconciler) observeInstances(
 			))
 	}
 
+	// take ownership of instances left behind by a previous PostgresCluster of
+	// the same name when asked to do so
+	if _, adopt := cluster.GetAnnotations()[naming.AdoptResources]; adopt {
+		for i := range runners.Items {
+			if err == nil {
+				err = errors.WithStack(r.claimObject(ctx, cluster, &runners.Items[i]))
+			}
+		}
+	}
+
 	observed := newObservedInstances(cluster, runners.Items, pods.Items)
 
 	// Fill out status sorted by set name.
@@ -528,13 +562,20 @@ func (r *Reconciler) reconcileInstanceSets(
 		numInstancePods += len(instances.forCluster[i].Pods)
 	}
 
+	// Some instance sets have a temporary instance while their pods are
+	// disrupted.
+	surges, err := r.observeDisruptionSurges(ctx, cluster, instances)
+	if err != nil {
+		return err
+	}
+
 	// Range over instance sets to scale up and ensure that each set has
 	// at least the number of replicas defined in the spec. The set can
 	// have more replicas than defined
 	for i := range cluster.Spec.InstanceSets {
 		set := &cluster.Spec.InstanceSets[i]
 		_, err := r.scaleUpInstances(
-			ctx, cluster, instances, set,
+			ctx, cluster, instances, set, surges[set.Name].replicas(set),
 			clusterConfigMap, clusterReplicationSecret,
 			rootCA, clusterPodService, instanceServiceAccount,
 			patroniLeaderService, primaryCertificate,
@@ -542,7 +583,7 @@ func (r *Reconciler) reconcileInstanceSets(
 			numInstancePods, clusterVolumes, exporterWebConfig)
 
 		if err == nil {
-			err = r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, set)
+			err = r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, set, surges[set.Name])
 		}
 		if err != nil {
 			return err
@@ -552,7 +593,7 @@ func (r *Reconciler) reconcileInstanceSets(
 	// Scaledown is called on the whole cluster in order to consider all
 	// instances. This is necessary because we have no way to determine
 	// which instance or instance set contains the primary pod.
-	err := r.scaleDownInstances(ctx, cluster, instances)
+	err = r.scaleDownInstances(ctx, cluster, instances, surges)
 	if err != nil {
 		return err
 	}
@@ -709,12 +750,19 @@ func (r *Reconciler) rolloutInstance(
 	//
 	// NOTE(cbandy): The StatefulSet controlling this Pod reflects this change
 	// in its Status and triggers another reconcile.
+	//
+	// Prefer a replica that is already running its current PodTemplate so the
+	// former primary is the last instance to be redeployed. When there is no
+	// such replica, let Patroni choose.
 	if primary && len(instances.forCluster) > 1 {
 		var span trace.Span
 		ctx, span = r.Tracer.Start(ctx, "patroni-change-primary")
 		defer span.End()
 
-		success, err := patroni.Executor(exec).ChangePrimaryAndWait(ctx, pod.Name, "")
+		candidate := switchoverCandidate(instances, instance)
+		span.SetAttributes(attribute.String("candidate", candidate))
+
+		success, err := patroni.Executor(exec).ChangePrimaryAndWait(ctx, pod.Name, candidate)
 		if err = errors.WithStack(err); err == nil && !success {
 			err = errors.New("unable to switchover")
 		}
@@ -790,9 +838,60 @@ func (r *Reconciler) rolloutInstance(
 		}))
 }
 
+// rolloutReplicationLag is the number of bytes of WAL that a replica can be
+// behind its primary and still count toward availability during a rollout. It
+// matches the default of the Patroni "maximum_lag_on_failover" setting.
+const rolloutReplicationLag = 1 << 20
+
+// replicationCaughtUp returns whether or not instance is within
+// rolloutReplicationLag of primaryPosition. It is true for the primary itself
+// and when the position of the primary is unknown.
+func replicationCaughtUp(instance *Instance, primaryPosition int64, primaryKnown bool) bool {
+	if primary, known := instance.IsPrimary(); !primaryKnown || (known && primary) {
+		return true
+	}
+
+	position, known := instance.WALPosition()
+	return known && primaryPosition-position <= rolloutReplicationLag
+}
+
+// switchoverCandidate returns the name of the Pod that should become primary
+// when primary is redeployed. It is a replica that is available, matches its
+// PodTemplate, and has replicated the most WAL. It returns blank when there is
+// no such replica.
+func switchoverCandidate(instances *observedInstances, primary *Instance) string {
+	var candidate string
+	var candidatePosition int64
+
+	primaryPosition, primaryKnown := primary.WALPosition()
+
+	for _, instance := range instances.forCluster {
+		if instance == primary || instance.Spec == nil {
+			continue
+		}
+		if available, known := instance.IsAvailable(); !known || !available {
+			continue
+		}
+		if matches, known := instance.PodMatchesPodTemplate(); !known || !matches {
+			continue
+		}
+		if !replicationCaughtUp(instance, primaryPosition, primaryKnown) {
+			continue
+		}
+
+		position, _ := instance.WALPosition()
+		if candidate == "" || position > candidatePosition {
+			candidate, candidatePosition = instance.Pods[0].Name, position
+		}
+	}
+
+	return candidate
+}
+
 // rolloutInstances compares instances to cluster and calls redeploy on those
 // that need their Pod recreated. It considers the overall availability of
-// cluster and minimizes Patroni failovers.
+// cluster and minimizes Patroni failovers. Replicas are redeployed before the
+// primary, and each one must catch up on replication before the next begins.
 func (r *Reconciler) rolloutInstances(
 	ctx context.Context,
 	cluster *v1beta1.PostgresCluster,
@@ -802,7 +901,10 @@ func (r *Reconciler) rolloutInstances(
 	var err error
 	var consider []*Instance
 	var numAvailable int
+	var numLagging int
 	var numSpecified int
+	var primaryKnown bool
+	var primaryPosition int64
 
 	ctx, span := r.Tracer.Start(ctx, "rollout-instances")
 	defer span.End()
@@ -811,6 +913,15 @@ func (r *Reconciler) rolloutInstances(
 		numSpecified += int(*set.Replicas)
 	}
 
+	// Find the WAL position of the primary so that replicas can be compared
+	// to it. A replica that was just redeployed may be ready before it has
+	// caught up on the changes it missed.
+	for _, instance := range instances.forCluster {
+		if primary, known := instance.IsPrimary(); known && primary {
+			primaryPosition, primaryKnown = instance.WALPosition()
+		}
+	}
+
 	for _, instance := range instances.forCluster {
 		// Skip instances that have no set in cluster spec. They should not be
 		// redeployed and should not count toward availability.
@@ -825,7 +936,11 @@ func (r *Reconciler) rolloutInstances(
 		}
 
 		if available, known := instance.IsAvailable(); known && available {
-			numAvailable++
+			if replicationCaughtUp(instance, primaryPosition, primaryKnown) {
+				numAvailable++
+			} else {
+				numLagging++
+			}
 		}
 
 		if matches, known := instance.PodMatchesPodTemplate(); known && !matches {
@@ -847,6 +962,7 @@ func (r *Reconciler) rolloutInstances(
 		attribute.Int("instances", len(instances.forCluster)),
 		attribute.Int("specified", numSpecified),
 		attribute.Int("available", numAvailable),
+		attribute.Int("lagging", numLagging),
 		attribute.Int("considering", len(consider)),
 	)
 
@@ -882,12 +998,14 @@ func (r *Reconciler) scaleDownInstances(
 	ctx context.Context,
 	cluster *v1beta1.PostgresCluster,
 	observedInstances *observedInstances,
+	surges map[string]*disruptionSurge,
 ) error {
 
 	// want defines the number of replicas we want for each instance set
 	want := map[string]int{}
-	for _, set := range cluster.Spec.InstanceSets {
-		want[set.Name] = int(*set.Replicas)
+	for i := range cluster.Spec.InstanceSets {
+		set := &cluster.Spec.InstanceSets[i]
+		want[set.Name] = surges[set.Name].replicas(set)
 	}
 
 	// grab all pods for the cluster using the observed instances
@@ -960,13 +1078,14 @@ func podsToKeep(instances []corev1.Pod, want map[string]int) []corev1.Pod {
 
 // +kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={list}
 
-// scaleUpInstances updates the cluster until the number of instances matches
-// the cluster spec
+// scaleUpInstances updates the cluster until the number of instances in set
+// matches replicas
 func (r *Reconciler) scaleUpInstances(
 	ctx context.Context,
 	cluster *v1beta1.PostgresCluster,
 	observed *observedInstances,
 	set *v1beta1.PostgresInstanceSetSpec,
+	replicas int,
 	clusterConfigMap *corev1.ConfigMap,
 	clusterReplicationSecret *corev1.Secret,
 	rootCA *pki.RootCertificateAuthority,
@@ -993,7 +1112,7 @@ func (r *Reconciler) scaleUpInstances(
 	}
 	// While there are fewer instances than specified, generate another empty one
 	// and append it.
-	for len(instances) < int(*set.Replicas) {
+	for len(instances) < replicas {
 		var span trace.Span
 		ctx, span = r.Tracer.Start(ctx, "generateInstanceName")
 		next := naming.GenerateInstance(cluster, set)
@@ -1072,6 +1191,7 @@ func (r *Reconciler) reconcileInstance(
 		postgresDataVolume   *corev1.PersistentVolumeClaim
 		postgresWALVolume    *corev1.PersistentVolumeClaim
 		tablespaceVolumes    []*corev1.PersistentVolumeClaim
+		spoolVolume          *corev1.PersistentVolumeClaim
 	)
 
 	if err == nil {
@@ -1090,6 +1210,9 @@ func (r *Reconciler) reconcileInstance(
 	if err == nil {
 		tablespaceVolumes, err = r.reconcileTablespaceVolumes(ctx, cluster, spec, instance, clusterVolumes)
 	}
+	if err == nil {
+		spoolVolume, err = r.reconcilePGBackRestSpoolVolume(ctx, cluster, spec, instance, clusterVolumes)
+	}
 	if err == nil {
 		postgres.InstancePod(
 			ctx, cluster, spec,
@@ -1098,7 +1221,7 @@ func (r *Reconciler) reconcileInstance(
 			&instance.Spec.Template.Spec)
 
 		addPGBackRestToInstancePodSpec(
-			cluster, instanceCertificates, &instance.Spec.Template.Spec)
+			cluster, instanceCertificates, spoolVolume, &instance.Spec.Template.Spec)
 
 		err = patroni.InstancePod(
 			ctx, cluster, clusterConfigMap, clusterPodService, patroniLeaderService,
@@ -1122,7 +1245,9 @@ func (r *Reconciler) reconcileInstance(
 	// add an emptyDir volume to the PodTemplateSpec and an associated '/tmp' volume mount to
 	// all containers included within that spec
 	if err == nil {
-		addTMPEmptyDir(&instance.Spec.Template)
+		addTMPEmptyDir(&instance.Spec.Template, spec.TmpVolumeSizeLimit)
+		requestTMPEphemeralStorage(&instance.Spec.Template,
+			naming.ContainerDatabase, spec.TmpVolumeSizeLimit)
 	}
 
 	// mount shared memory to the Postgres instance
@@ -1269,17 +1394,23 @@ func generateInstanceStatefulSetIntent(_ context.Context,
 	sts.Spec.Template.Spec.ImagePullSecrets = cluster.Spec.ImagePullSecrets
 }
 
-// addPGBackRestToInstancePodSpec adds pgBackRest configurations and sidecars
-// to the PodSpec.
+// addPGBackRestToInstancePodSpec adds pgBackRest configurations, sidecars, and
+// any spool volume to the PodSpec.
 func addPGBackRestToInstancePodSpec(cluster *v1beta1.PostgresCluster,
-	instanceCertificates *corev1.Secret, instancePod *corev1.PodSpec,
+	instanceCertificates *corev1.Secret, spoolVolume *corev1.PersistentVolumeClaim,
+	instancePod *corev1.PodSpec,
 ) {
-	if pgbackrest.DedicatedRepoHostEnabled(cluster) {
+	if pgbackrest.DedicatedRepoHostEnabled(cluster) ||
+		pgbackrest.SharedRepoHostEnabled(cluster) {
 		pgbackrest.AddServerToInstancePod(cluster, instancePod,
 			instanceCertificates.Name)
 	}
 
 	pgbackrest.AddConfigToInstancePod(cluster, instancePod)
+
+	if spoolVolume != nil {
+		pgbackrest.AddSpoolVolumeToInstancePod(instancePod, spoolVolume)
+	}
 }
 
 // +kubebuilder:rbac:groups="",resources="configmaps",verbs={create,patch}
@@ -1386,17 +1517,22 @@ func (r *Reconciler) reconcileInstanceCertificates(
 // reconcileInstanceSetPodDisruptionBudget creates a PDB for an instance set. A
 // PDB will be created when the minAvailable is determined to be greater than 0.
 // MinAvailable can be defined in the spec or a default value will be set based
-// on the number of replicas in the instance set.
+// on the number of replicas in the instance set. A set with a disruption surge
+// uses the minAvailable of its surge.
 func (r *Reconciler) reconcileInstanceSetPodDisruptionBudget(
 	ctx context.Context,
 	cluster *v1beta1.PostgresCluster,
 	spec *v1beta1.PostgresInstanceSetSpec,
+	surge *disruptionSurge,
 ) error {
 	if spec.Replicas == nil {
 		// Replicas should always have a value because of defaults in the spec
 		return errors.New("Replicas should be defined")
 	}
 	minAvailable := getMinAvailable(spec.MinAvailable, *spec.Replicas)
+	if surge != nil {
+		minAvailable = initialize.IntOrStringInt32(surge.minAvailable)
+	}
 
 	meta := naming.InstanceSet(cluster, spec)
 	meta.Labels = naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(),
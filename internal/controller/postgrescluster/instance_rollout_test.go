@@ -158,6 +158,104 @@ func TestReconcilerRolloutInstance(t *testing.T) {
 			assert.Equal(t, execCalls, 1, "expected PodExec to be called")
 		})
 
+		t.Run("Candidate", func(t *testing.T) {
+			cluster := new(v1beta1.PostgresCluster)
+			cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{Name: "00"}}
+
+			ready := corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{
+					Type:   corev1.PodReady,
+					Status: corev1.ConditionTrue,
+				}},
+			}
+			runner := &appsv1.StatefulSet{
+				Status: appsv1.StatefulSetStatus{UpdateRevision: "gamma"},
+			}
+
+			instances := []*Instance{
+				{
+					Name: "primary",
+					Spec: &cluster.Spec.InstanceSets[0],
+					Pods: []*corev1.Pod{{
+						ObjectMeta: metav1.ObjectMeta{
+							Namespace: "ns1",
+							Name:      "the-pod",
+							Annotations: map[string]string{
+								"status": `{"role":"master","state":"running","xlog_location":5000}`,
+							},
+							Labels: map[string]string{
+								"controller-revision-hash":               "beta",
+								"postgres-operator.crunchydata.com/role": "master",
+							},
+						},
+						Status: ready,
+					}},
+					Runner: runner,
+				},
+				{
+					Name: "outdated",
+					Spec: &cluster.Spec.InstanceSets[0],
+					Pods: []*corev1.Pod{{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "outdated-pod",
+							Annotations: map[string]string{
+								"status": `{"role":"replica","state":"running","xlog_location":5000}`,
+							},
+							Labels: map[string]string{"controller-revision-hash": "beta"},
+						},
+						Status: ready,
+					}},
+					Runner: runner,
+				},
+				{
+					Name: "behind",
+					Spec: &cluster.Spec.InstanceSets[0],
+					Pods: []*corev1.Pod{{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "behind-pod",
+							Annotations: map[string]string{
+								"status": `{"role":"replica","state":"running","xlog_location":3000}`,
+							},
+							Labels: map[string]string{"controller-revision-hash": "gamma"},
+						},
+						Status: ready,
+					}},
+					Runner: runner,
+				},
+				{
+					Name: "updated",
+					Spec: &cluster.Spec.InstanceSets[0],
+					Pods: []*corev1.Pod{{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "updated-pod",
+							Annotations: map[string]string{
+								"status": `{"role":"replica","state":"running","xlog_location":4000}`,
+							},
+							Labels: map[string]string{"controller-revision-hash": "gamma"},
+						},
+						Status: ready,
+					}},
+					Runner: runner,
+				},
+			}
+			observed := &observedInstances{forCluster: instances}
+
+			reconciler := &Reconciler{}
+			reconciler.Tracer = otel.Tracer(t.Name())
+			reconciler.PodExec = func(
+				_, _, _ string, _ io.Reader, stdout, _ io.Writer, command ...string,
+			) error {
+				// A switchover to the updated replica that is furthest along.
+				assert.Assert(t, sets.NewString(command...).Has("--master=the-pod"))
+				assert.Assert(t, sets.NewString(command...).Has("--candidate=updated-pod"))
+
+				_, _ = stdout.Write([]byte("switched over"))
+				return nil
+			}
+
+			assert.NilError(t, reconciler.rolloutInstance(ctx, cluster, observed, instances[0]))
+		})
+
 		t.Run("Failure", func(t *testing.T) {
 			reconciler := &Reconciler{}
 			reconciler.Tracer = otel.Tracer(t.Name())
@@ -651,4 +749,90 @@ func TestReconcilerRolloutInstances(t *testing.T) {
 				return nil
 			}))
 	})
+
+	// One updated replica has not caught up to the outdated primary. Wait.
+	t.Run("OutdatedPrimaryWithLaggingReplica", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{
+			{Name: "00", Replicas: initialize.Int32(2)},
+		}
+		instances := []*Instance{
+			{
+				Name: "primary",
+				Spec: &cluster.Spec.InstanceSets[0],
+				Pods: []*corev1.Pod{{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"status": `{"role":"master","state":"running","xlog_location":83886080}`,
+						},
+						Labels: map[string]string{
+							"controller-revision-hash":               "beta",
+							"postgres-operator.crunchydata.com/role": "master",
+						},
+					},
+					Status: corev1.PodStatus{
+						Conditions: []corev1.PodCondition{{
+							Type:   corev1.PodReady,
+							Status: corev1.ConditionTrue,
+						}},
+					},
+				}},
+				Runner: &appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Generation: 1,
+					},
+					Status: appsv1.StatefulSetStatus{
+						ObservedGeneration: 1,
+						UpdateRevision:     "gamma",
+					},
+				},
+			},
+			{
+				Name: "replica",
+				Spec: &cluster.Spec.InstanceSets[0],
+				Pods: []*corev1.Pod{{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"status": `{"role":"replica","state":"running","xlog_location":16777216}`,
+						},
+						Labels: map[string]string{
+							"controller-revision-hash": "gamma",
+						},
+					},
+					Status: corev1.PodStatus{
+						Conditions: []corev1.PodCondition{{
+							Type:   corev1.PodReady,
+							Status: corev1.ConditionTrue,
+						}},
+					},
+				}},
+				Runner: &appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Generation: 1,
+					},
+					Status: appsv1.StatefulSetStatus{
+						ObservedGeneration: 1,
+						UpdateRevision:     "gamma",
+					},
+				},
+			},
+		}
+		observed := &observedInstances{forCluster: instances}
+
+		logSpanAttributes(t)
+		assert.NilError(t, reconciler.rolloutInstances(ctx, cluster, observed,
+			func(context.Context, *Instance) error {
+				t.Fatal("expected no redeploys")
+				return nil
+			}))
+
+		// The primary is redeployed once the replica catches up.
+		instances[1].Pods[0].Annotations["status"] =
+			`{"role":"replica","state":"running","xlog_location":83886000}`
+
+		var redeploys []*Instance
+		assert.NilError(t, reconciler.rolloutInstances(ctx, cluster, observed, accumulate(&redeploys)))
+		assert.Equal(t, len(redeploys), 1)
+		assert.Equal(t, redeploys[0].Name, "primary")
+	})
 }
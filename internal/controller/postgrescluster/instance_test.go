@@ -146,6 +146,37 @@ func TestInstanceIsWritable(t *testing.T) {
 	assert.Assert(t, !writable)
 }
 
+func TestInstanceWALPosition(t *testing.T) {
+	var instance Instance
+	var known bool
+	var position int64
+
+	// No pods
+	_, known = instance.WALPosition()
+	assert.Assert(t, !known)
+
+	// No annotations
+	instance.Pods = []*corev1.Pod{{}}
+	_, known = instance.WALPosition()
+	assert.Assert(t, !known)
+
+	// No location
+	instance.Pods[0].Annotations = map[string]string{"status": `{"state":"running"}`}
+	_, known = instance.WALPosition()
+	assert.Assert(t, !known)
+
+	// PostgreSQL is not running
+	instance.Pods[0].Annotations["status"] = `{"state":"starting","xlog_location":1234}`
+	_, known = instance.WALPosition()
+	assert.Assert(t, !known)
+
+	// PostgreSQL is running
+	instance.Pods[0].Annotations["status"] = `{"role":"replica","state":"running","xlog_location":1234}`
+	position, known = instance.WALPosition()
+	assert.Assert(t, known)
+	assert.Equal(t, position, int64(1234))
+}
+
 func TestNewObservedInstances(t *testing.T) {
 	t.Run("Empty", func(t *testing.T) {
 		cluster := new(v1beta1.PostgresCluster)
@@ -444,7 +475,7 @@ func TestAddPGBackRestToInstancePodSpec(t *testing.T) {
 		cluster.Spec.Backups.PGBackRest.Repos = nil
 
 		out := pod.DeepCopy()
-		addPGBackRestToInstancePodSpec(cluster, &certificates, out)
+		addPGBackRestToInstancePodSpec(cluster, &certificates, nil, out)
 
 		// Only Containers and Volumes fields have changed.
 		assert.DeepEqual(t, pod, *out, cmpopts.IgnoreFields(pod, "Containers", "Volumes"))
@@ -539,7 +570,7 @@ func TestAddPGBackRestToInstancePodSpec(t *testing.T) {
 		}
 
 		out := pod.DeepCopy()
-		addPGBackRestToInstancePodSpec(cluster, &certificates, out)
+		addPGBackRestToInstancePodSpec(cluster, &certificates, nil, out)
 		alwaysExpect(t, out)
 
 		// The TLS server is added and configuration mounted.
@@ -647,7 +678,7 @@ func TestAddPGBackRestToInstancePodSpec(t *testing.T) {
 
 			before := out.DeepCopy()
 			out := pod.DeepCopy()
-			addPGBackRestToInstancePodSpec(cluster, &certificates, out)
+			addPGBackRestToInstancePodSpec(cluster, &certificates, nil, out)
 			alwaysExpect(t, out)
 
 			// Only the TLS server container changed.
@@ -1749,7 +1780,7 @@ func TestReconcileInstanceSetPodDisruptionBudget(t *testing.T) {
 		cluster := &v1beta1.PostgresCluster{}
 		spec := &v1beta1.PostgresInstanceSetSpec{}
 
-		assert.Error(t, r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec),
+		assert.Error(t, r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec, nil),
 			"Replicas should be defined")
 	})
 
@@ -1758,7 +1789,7 @@ func TestReconcileInstanceSetPodDisruptionBudget(t *testing.T) {
 		cluster.Namespace = ns.Name
 		spec := &cluster.Spec.InstanceSets[0]
 		spec.MinAvailable = initialize.IntOrStringInt32(0)
-		assert.NilError(t, r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec))
+		assert.NilError(t, r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec, nil))
 		assert.Assert(t, !foundPDB(cluster, spec))
 	})
 
@@ -1771,18 +1802,18 @@ func TestReconcileInstanceSetPodDisruptionBudget(t *testing.T) {
 		assert.NilError(t, r.Client.Create(ctx, cluster))
 		t.Cleanup(func() { assert.Check(t, r.Client.Delete(ctx, cluster)) })
 
-		assert.NilError(t, r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec))
+		assert.NilError(t, r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec, nil))
 		assert.Assert(t, foundPDB(cluster, spec))
 
 		t.Run("deleted", func(t *testing.T) {
 			spec.MinAvailable = initialize.IntOrStringInt32(0)
-			err := r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec)
+			err := r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec, nil)
 			if apierrors.IsConflict(err) {
 				// When running in an existing environment another controller will sometimes update
 				// the object. This leads to an error where the ResourceVersion of the object does
 				// not match what we expect. When we run into this conflict, try to reconcile the
 				// object again.
-				err = r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec)
+				err = r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec, nil)
 			}
 			assert.NilError(t, err, errors.Unwrap(err))
 			assert.Assert(t, !foundPDB(cluster, spec))
@@ -1798,18 +1829,18 @@ func TestReconcileInstanceSetPodDisruptionBudget(t *testing.T) {
 		assert.NilError(t, r.Client.Create(ctx, cluster))
 		t.Cleanup(func() { assert.Check(t, r.Client.Delete(ctx, cluster)) })
 
-		assert.NilError(t, r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec))
+		assert.NilError(t, r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec, nil))
 		assert.Assert(t, foundPDB(cluster, spec))
 
 		t.Run("deleted", func(t *testing.T) {
 			spec.MinAvailable = initialize.IntOrStringString("0%")
-			err := r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec)
+			err := r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec, nil)
 			if apierrors.IsConflict(err) {
 				// When running in an existing environment another controller will sometimes update
 				// the object. This leads to an error where the ResourceVersion of the object does
 				// not match what we expect. When we run into this conflict, try to reconcile the
 				// object again.
-				err = r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec)
+				err = r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec, nil)
 			}
 			assert.NilError(t, err, errors.Unwrap(err))
 			assert.Assert(t, !foundPDB(cluster, spec))
@@ -1818,19 +1849,19 @@ func TestReconcileInstanceSetPodDisruptionBudget(t *testing.T) {
 		t.Run("delete with 00%", func(t *testing.T) {
 			spec.MinAvailable = initialize.IntOrStringString("50%")
 
-			assert.NilError(t, r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec))
+			assert.NilError(t, r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec, nil))
 			assert.Assert(t, foundPDB(cluster, spec))
 
 			t.Run("deleted", func(t *testing.T) {
 				spec.MinAvailable = initialize.IntOrStringString("00%")
-				err := r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec)
+				err := r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec, nil)
 				if apierrors.IsConflict(err) {
 					// When running in an existing environment another controller will sometimes update
 					// the object. This leads to an error where the ResourceVersion of the object does
 					// not match what we expect. When we run into this conflict, try to reconcile the
 					// object again.
 					t.Log("conflict:", err)
-					err = r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec)
+					err = r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec, nil)
 				}
 				assert.NilError(t, err, "\n%#v", errors.Unwrap(err))
 				assert.Assert(t, !foundPDB(cluster, spec))
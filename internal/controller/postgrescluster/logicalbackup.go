@@ -0,0 +1,755 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/internal/util"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionLogicalRestore is the type used in a condition to indicate the
+	// progress of a restore of a logical backup into the running cluster.
+	ConditionLogicalRestore = "LogicalRestore"
+
+	// logicalBackupUser is the PostgreSQL role that logical backups and
+	// restores connect as.
+	logicalBackupUser = "_crunchydump"
+
+	// logicalBackupRetention is the number of dumps of each database that
+	// are kept when the spec does not say.
+	logicalBackupRetention = 7
+
+	// logicalBackupMountPath is where the volume of logical backups is mounted.
+	logicalBackupMountPath = "/pgdump"
+)
+
+// logicalBackupUserSQL creates the logical backup user, when necessary, and
+// sets its password. It expects the psql variables "username" and "verifier".
+// The user is a superuser so that dumps include every object and restores
+// can recreate them with their owners.
+const logicalBackupUserSQL = `
+SET client_min_messages = WARNING;
+SELECT pg_catalog.format('CREATE ROLE %I', :'username')
+ WHERE NOT EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = :'username')
+\gexec
+ALTER ROLE :"username" LOGIN SUPERUSER PASSWORD :'verifier';`
+
+// disableLogicalBackupUserSQL removes login permissions from the logical
+// backup user, when it exists. It expects the psql variable "username".
+const disableLogicalBackupUserSQL = `
+SELECT pg_catalog.format('ALTER ROLE %I NOLOGIN NOSUPERUSER', :'username')
+ WHERE EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = :'username')
+\gexec`
+
+// logicalBackupHBAs adds the HBA rules of the logical backup user of cluster
+// to outHBAs. It can only connect over TLS using its password.
+func logicalBackupHBAs(cluster *v1beta1.PostgresCluster, outHBAs *postgres.HBAs) {
+	if cluster.Spec.Backups.Logical != nil {
+		outHBAs.Mandatory = append(outHBAs.Mandatory,
+			*postgres.NewHBA().TLS().User(logicalBackupUser).Method(postgres.PasswordMethod(cluster)),
+			*postgres.NewHBA().TCP().User(logicalBackupUser).Method("reject"))
+	}
+}
+
+// reconcileLogicalBackups writes the dumps of cluster on a schedule and
+// restores them when asked. Dumps and restores run in Jobs that connect to
+// the primary as the logical backup user.
+func (r *Reconciler) reconcileLogicalBackups(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	instances *observedInstances, primaryCertificate *corev1.SecretProjection,
+) error {
+	secret, err := r.reconcileLogicalBackupSecret(ctx, cluster)
+	if err == nil {
+		err = r.reconcileLogicalBackupUser(ctx, cluster, instances, secret)
+	}
+	if err == nil {
+		err = r.reconcileLogicalBackupVolume(ctx, cluster)
+	}
+	if err == nil {
+		err = r.reconcileLogicalBackupCronJob(ctx, cluster, primaryCertificate)
+	}
+	if err == nil {
+		err = r.reconcileLogicalRestore(ctx, cluster, primaryCertificate)
+	}
+	return err
+}
+
+// reconcileLogicalBackupSecret writes the Secret with the password of the
+// logical backup user. It returns nil and deletes the Secret when cluster has
+// no logical backups.
+func (r *Reconciler) reconcileLogicalBackupSecret(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) (*corev1.Secret, error) {
+	existing := &corev1.Secret{ObjectMeta: naming.LogicalBackup(cluster)}
+	err := errors.WithStack(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing))
+	if client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+
+	if cluster.Spec.Backups.Logical == nil {
+		if err == nil {
+			err = errors.WithStack(r.deleteControlled(ctx, cluster, existing))
+		}
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	intent := &corev1.Secret{ObjectMeta: naming.LogicalBackup(cluster)}
+	intent.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+
+	intent.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil())
+	intent.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		logicalBackupLabels(cluster))
+
+	intent.Data = make(map[string][]byte)
+	intent.Data["password"] = existing.Data["password"]
+	intent.Data["verifier"] = existing.Data["verifier"]
+
+	if len(intent.Data["password"]) == 0 {
+		password, err := util.GenerateASCIIPassword(util.DefaultGeneratedPasswordLength)
+		if err != nil {
+			return nil, err
+		}
+		intent.Data["password"] = []byte(password)
+		intent.Data["verifier"] = nil
+	}
+
+	// Store the verifier alongside the password so that later reconciles do
+	// not generate it again. Keep the password when the format changes.
+	if len(intent.Data["verifier"]) == 0 ||
+		!postgres.PasswordVerifierMatches(cluster, string(intent.Data["verifier"])) {
+		verifier, err := postgres.NewPasswordVerifier(cluster,
+			logicalBackupUser, string(intent.Data["password"]))
+		if err != nil {
+			return nil, err
+		}
+		intent.Data["verifier"] = []byte(verifier)
+	}
+
+	err = errors.WithStack(r.setControllerReference(cluster, intent))
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, intent))
+	}
+	if err == nil {
+		return intent, nil
+	}
+	return nil, err
+}
+
+// reconcileLogicalBackupUser creates the logical backup user in PostgreSQL
+// with the password in secret. When secret is nil, the user can no longer log
+// in. The SQL runs in the primary when there is one and is remembered in
+// cluster.Status so that it runs once.
+func (r *Reconciler) reconcileLogicalBackupUser(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	instances *observedInstances, secret *corev1.Secret,
+) error {
+	sql, revision := disableLogicalBackupUserSQL, ""
+	variables := map[string]string{
+		"username": logicalBackupUser,
+
+		"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+		"QUIET":         "on", // Do not print successful commands to stdout.
+	}
+
+	if secret != nil {
+		sql = logicalBackupUserSQL
+		variables["verifier"] = string(secret.Data["verifier"])
+
+		var err error
+		revision, err = safeHash32(func(hasher io.Writer) error {
+			_, err := io.WriteString(hasher, sql+variables["verifier"])
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if revision == cluster.Status.LogicalBackupRevision {
+		return nil
+	}
+
+	// Leave the user as it is while PostgreSQL refuses writes.
+	pod, _ := instances.writablePod(naming.ContainerDatabase)
+	if pod == nil || postgres.ReadOnly(cluster) {
+		return nil
+	}
+
+	exec := func(_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+		return r.PodExec(pod.Namespace, pod.Name, naming.ContainerDatabase, stdin, stdout, stderr, command...)
+	}
+
+	stdout, stderr, err := postgres.Executor(exec).Exec(ctx, strings.NewReader(sql), variables)
+	logging.FromContext(ctx).V(1).Info("wrote logical backup user",
+		"revision", revision, "stdout", stdout, "stderr", stderr)
+
+	if err == nil {
+		cluster.Status.LogicalBackupRevision = revision
+	}
+	return err
+}
+
+// +kubebuilder:rbac:groups="",resources="persistentvolumeclaims",verbs={create,patch}
+
+// reconcileLogicalBackupVolume writes the PersistentVolumeClaim of logical
+// backups. The volume is kept when logical backups are removed from the spec
+// so that its dumps are not lost; it is deleted along with cluster.
+func (r *Reconciler) reconcileLogicalBackupVolume(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	if cluster.Spec.Backups.Logical == nil {
+		return nil
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: naming.LogicalBackup(cluster)}
+	pvc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
+
+	pvc.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil())
+	pvc.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		logicalBackupLabels(cluster))
+	pvc.Spec = cluster.Spec.Backups.Logical.VolumeClaimSpec
+
+	err := errors.WithStack(r.setControllerReference(cluster, pvc))
+	if err == nil {
+		err = r.handlePersistentVolumeClaimError(cluster,
+			errors.WithStack(r.apply(ctx, pvc)))
+	}
+	return err
+}
+
+// +kubebuilder:rbac:groups="batch",resources="cronjobs",verbs={get,create,patch,delete}
+
+// reconcileLogicalBackupCronJob writes the CronJob that dumps the databases of
+// cluster. It is deleted when cluster has no logical backups.
+func (r *Reconciler) reconcileLogicalBackupCronJob(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	primaryCertificate *corev1.SecretProjection,
+) error {
+	if cluster.Spec.Backups.Logical == nil {
+		existing := &batchv1.CronJob{ObjectMeta: naming.LogicalBackup(cluster)}
+		err := errors.WithStack(
+			r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing))
+		if err == nil {
+			err = errors.WithStack(r.deleteControlled(ctx, cluster, existing))
+		}
+		return client.IgnoreNotFound(err)
+	}
+
+	// Wait for PostgreSQL and the user that dumps connect as.
+	if !patroni.ClusterBootstrapped(cluster) || cluster.Status.LogicalBackupRevision == "" {
+		return nil
+	}
+
+	cronjob := generateLogicalBackupCronJob(cluster, primaryCertificate)
+
+	err := errors.WithStack(r.setControllerReference(cluster, cronjob))
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, cronjob))
+	}
+	return err
+}
+
+// +kubebuilder:rbac:groups="batch",resources="jobs",verbs={get,create,patch,delete}
+
+// reconcileLogicalRestore restores the dump in the spec of cluster each time
+// its "logical-restore" annotation changes. The result is reported in the
+// [ConditionLogicalRestore] condition and an event.
+func (r *Reconciler) reconcileLogicalRestore(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	primaryCertificate *corev1.SecretProjection,
+) error {
+	id := cluster.GetAnnotations()[naming.LogicalRestore]
+
+	existing := &batchv1.Job{ObjectMeta: naming.LogicalRestoreJob(cluster)}
+	err := errors.WithStack(client.IgnoreNotFound(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing)))
+	if err != nil {
+		return err
+	}
+
+	// Remove a Job that ran some other restore.
+	if existing.GetUID() != "" &&
+		(existing.GetAnnotations()[naming.LogicalRestore] != id ||
+			cluster.Spec.Backups.Logical == nil) {
+		return errors.WithStack(client.IgnoreNotFound(r.deleteControlled(ctx, cluster, existing)))
+	}
+
+	if cluster.Spec.Backups.Logical == nil {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionLogicalRestore)
+		return nil
+	}
+	if id == "" {
+		return nil
+	}
+
+	spec := cluster.Spec.Backups.Logical.Restore
+	if spec == nil {
+		r.setLogicalRestoreCondition(cluster, metav1.ConditionFalse, "InvalidLogicalRestore",
+			"Unable to restore: spec.backups.logical.restore is not set")
+		return nil
+	}
+	if err := validateLogicalRestoreFile(spec.File); err != nil {
+		r.setLogicalRestoreCondition(cluster, metav1.ConditionFalse, "InvalidLogicalRestore",
+			"Unable to restore: "+err.Error())
+		return nil
+	}
+	if err := validateLogicalRestoreOptions(spec.Options); err != nil {
+		r.setLogicalRestoreCondition(cluster, metav1.ConditionFalse, "InvalidLogicalRestore",
+			"Unable to restore: "+err.Error())
+		return nil
+	}
+
+	switch {
+	case existing.GetUID() != "" && jobCompleted(existing):
+		r.setLogicalRestoreCondition(cluster, metav1.ConditionTrue, "LogicalRestoreComplete",
+			fmt.Sprintf("Restored %q into database %q", spec.File, spec.Database))
+		return nil
+
+	case existing.GetUID() != "" && jobFailed(existing):
+		message, err := r.restoreDryRunMessage(ctx, existing)
+		if err == nil {
+			r.setLogicalRestoreCondition(cluster, metav1.ConditionFalse, "LogicalRestoreFailed",
+				"Unable to restore: "+message)
+		}
+		return err
+	}
+
+	// Wait for PostgreSQL and the user that restores connect as.
+	if !patroni.ClusterBootstrapped(cluster) || cluster.Status.LogicalBackupRevision == "" {
+		return nil
+	}
+
+	job := generateLogicalRestoreJob(cluster, primaryCertificate, id)
+	err = errors.WithStack(r.setControllerReference(cluster, job))
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, job))
+	}
+	if err == nil && existing.GetUID() == "" {
+		r.setLogicalRestoreCondition(cluster, metav1.ConditionUnknown,
+			"LogicalRestoreRunning", fmt.Sprintf("Restoring %q", spec.File))
+	}
+	return err
+}
+
+// setLogicalRestoreCondition sets the [ConditionLogicalRestore] condition of
+// cluster and records an event when a restore finishes.
+func (r *Reconciler) setLogicalRestoreCondition(cluster *v1beta1.PostgresCluster,
+	status metav1.ConditionStatus, reason, message string) {
+
+	previous := meta.FindStatusCondition(cluster.Status.Conditions, ConditionLogicalRestore)
+	if previous == nil || previous.Status != status || previous.Reason != reason {
+		switch status {
+		case metav1.ConditionTrue:
+			r.Recorder.Event(cluster, corev1.EventTypeNormal, reason, message)
+		case metav1.ConditionFalse:
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, reason, message)
+		}
+	}
+
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionLogicalRestore,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// validateLogicalRestoreFile returns an error when file is outside the volume
+// of logical backups.
+func validateLogicalRestoreFile(file string) error {
+	for _, part := range strings.Split(file, "/") {
+		if part == ".." {
+			return errors.Errorf("file %q is not allowed", file)
+		}
+	}
+	return nil
+}
+
+// pgRestoreLongOptions are the long options of pg_restore, whether each takes
+// a value, and whether it is allowed in spec.backups.logical.restore.options.
+// Those that change what pg_restore reads, where it writes, or how it connects
+// are not allowed.
+// - https://git.postgresql.org/gitweb/?p=postgresql.git;a=blob;f=src/bin/pg_dump/pg_restore.c;hb=REL_15_STABLE
+var pgRestoreLongOptions = map[string]struct{ value, allowed bool }{
+	"clean":                         {false, true},
+	"create":                        {false, true},
+	"data-only":                     {false, true},
+	"dbname":                        {true, false},
+	"disable-triggers":              {false, true},
+	"enable-row-security":           {false, true},
+	"exclude-schema":                {true, true},
+	"exit-on-error":                 {false, true},
+	"file":                          {true, false},
+	"format":                        {true, true},
+	"function":                      {true, true},
+	"host":                          {true, false},
+	"if-exists":                     {false, true},
+	"index":                         {true, true},
+	"jobs":                          {true, true},
+	"list":                          {false, false},
+	"no-acl":                        {false, true},
+	"no-comments":                   {false, true},
+	"no-data-for-failed-tables":     {false, true},
+	"no-owner":                      {false, true},
+	"no-password":                   {false, false},
+	"no-privileges":                 {false, true},
+	"no-publications":               {false, true},
+	"no-reconnect":                  {false, true},
+	"no-security-labels":            {false, true},
+	"no-subscriptions":              {false, true},
+	"no-table-access-method":        {false, true},
+	"no-tablespaces":                {false, true},
+	"password":                      {false, false},
+	"port":                          {true, false},
+	"role":                          {true, true},
+	"schema":                        {true, true},
+	"schema-only":                   {false, true},
+	"section":                       {true, true},
+	"single-transaction":            {false, true},
+	"strict-names":                  {false, true},
+	"superuser":                     {true, true},
+	"table":                         {true, true},
+	"trigger":                       {true, true},
+	"use-list":                      {true, false},
+	"use-set-session-authorization": {false, true},
+	"username":                      {true, false},
+	"verbose":                       {false, true},
+}
+
+// pgRestoreShortOptions are the long names of the short options of pg_restore.
+var pgRestoreShortOptions = map[byte]string{
+	'1': "single-transaction", 'a': "data-only", 'C': "create", 'c': "clean",
+	'd': "dbname", 'e': "exit-on-error", 'F': "format", 'f': "file",
+	'h': "host", 'I': "index", 'j': "jobs", 'L': "use-list", 'l': "list",
+	'N': "exclude-schema", 'n': "schema", 'O': "no-owner", 'P': "function",
+	'p': "port", 'R': "no-reconnect", 'S': "superuser", 's': "schema-only",
+	'T': "trigger", 't': "table", 'U': "username", 'v': "verbose",
+	'W': "password", 'w': "no-password", 'x': "no-privileges",
+}
+
+// validateLogicalRestoreOptions returns an error when options would change
+// what pg_restore reads, where it writes, or how it connects. It parses them
+// the way pg_restore does: long options can be abbreviated, short options can
+// be grouped, and values can be attached or in the next argument.
+func validateLogicalRestoreOptions(options []string) error {
+	for i := 0; i < len(options); i++ {
+		option := options[i]
+		var names []string
+		var needsValue bool
+
+		switch {
+		case strings.HasPrefix(option, "--") && option != "--":
+			name, _, attached := strings.Cut(option[2:], "=")
+
+			// An unambiguous prefix of a long option is that option.
+			if _, ok := pgRestoreLongOptions[name]; !ok {
+				var matches []string
+				for long := range pgRestoreLongOptions {
+					if strings.HasPrefix(long, name) {
+						matches = append(matches, long)
+					}
+				}
+				if len(matches) != 1 {
+					return errors.Errorf("option %q is not allowed", option)
+				}
+				name = matches[0]
+			}
+
+			names = append(names, name)
+			needsValue = pgRestoreLongOptions[name].value && !attached
+
+		case strings.HasPrefix(option, "-") && option != "-":
+			// Short options are grouped until one that takes a value.
+			for j := 1; j < len(option); j++ {
+				name, ok := pgRestoreShortOptions[option[j]]
+				if !ok {
+					return errors.Errorf("option %q is not allowed", option)
+				}
+
+				names = append(names, name)
+				if pgRestoreLongOptions[name].value {
+					needsValue = j == len(option)-1
+					break
+				}
+			}
+
+		default:
+			// Other arguments are files to read.
+			return errors.Errorf("argument %q is not allowed", option)
+		}
+
+		for _, name := range names {
+			if !pgRestoreLongOptions[name].allowed {
+				return errors.Errorf("option %q is not allowed", option)
+			}
+		}
+
+		// The next argument is the value of this option.
+		if needsValue {
+			i++
+		}
+	}
+	return nil
+}
+
+// logicalBackupLabels returns the labels of the objects of logical backups.
+func logicalBackupLabels(cluster *v1beta1.PostgresCluster) map[string]string {
+	return map[string]string{
+		naming.LabelCluster: cluster.Name,
+		naming.LabelRole:    naming.RoleLogicalBackup,
+	}
+}
+
+// logicalBackupAuthority returns a projection of the certificate authority in
+// primaryCertificate, the certificate of the PostgreSQL server.
+func logicalBackupAuthority(primaryCertificate *corev1.SecretProjection) *corev1.SecretProjection {
+	const authority = "ca.crt"
+	result := primaryCertificate.DeepCopy()
+	if result == nil {
+		result = new(corev1.SecretProjection)
+	}
+
+	var items []corev1.KeyToPath
+	for _, item := range result.Items {
+		// The PostgreSQL server projection expects Path to match typical Keys.
+		if item.Path == authority {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		items = []corev1.KeyToPath{{Key: authority, Path: authority}}
+	}
+
+	result.Items = items
+	return result
+}
+
+// logicalBackupPodTemplate returns a pod template that runs command in the
+// PostgreSQL image of cluster with the volume of logical backups mounted. The
+// environment connects to the primary over TLS as the logical backup user.
+func logicalBackupPodTemplate(
+	cluster *v1beta1.PostgresCluster, primaryCertificate *corev1.SecretProjection,
+	command []string,
+) corev1.PodTemplateSpec {
+	spec := cluster.Spec.Backups.Logical
+	primary := naming.ClusterPrimaryService(cluster)
+	secret := naming.LogicalBackup(cluster).Name
+
+	container := corev1.Container{
+		Name:            naming.ContainerDatabase,
+		Command:         command,
+		Image:           config.PostgresContainerImage(cluster),
+		ImagePullPolicy: cluster.Spec.ImagePullPolicy,
+		Resources:       spec.Resources,
+		SecurityContext: initialize.RestrictedSecurityContext(),
+
+		Env: []corev1.EnvVar{
+			{Name: "PGHOST", Value: primary.Name + "." + primary.Namespace + ".svc"},
+			{Name: "PGPORT", Value: fmt.Sprint(*cluster.Spec.Port)},
+			{Name: "PGUSER", Value: logicalBackupUser},
+			{Name: "PGPASSWORD", ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+					Key:                  "password",
+				},
+			}},
+			{Name: "PGSSLMODE", Value: "verify-full"},
+			{Name: "PGSSLROOTCERT", Value: naming.CertMountPath + "/ca.crt"},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "pgdump", MountPath: logicalBackupMountPath},
+			{Name: naming.CertVolume, MountPath: naming.CertMountPath, ReadOnly: true},
+		},
+	}
+
+	labels := naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(), logicalBackupLabels(cluster))
+
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil()),
+			Labels:      labels,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{container},
+
+			// Disable environment variables for services other than the Kubernetes API.
+			// - https://docs.k8s.io/concepts/services-networking/connect-applications-service/#accessing-the-service
+			EnableServiceLinks: initialize.Bool(false),
+
+			// Set the image pull secrets, if any exist. This is set here rather
+			// than using the service account due to the lack of propagation to
+			// existing pods when the CRD is updated.
+			// - https://issue.k8s.io/88456
+			ImagePullSecrets: cluster.Spec.ImagePullSecrets,
+
+			RestartPolicy:   corev1.RestartPolicyNever,
+			SecurityContext: initialize.PodSecurityContext(),
+
+			Volumes: []corev1.Volume{
+				{Name: "pgdump", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: naming.LogicalBackup(cluster).Name,
+					},
+				}},
+				{Name: naming.CertVolume, VolumeSource: corev1.VolumeSource{
+					Projected: &corev1.ProjectedVolumeSource{
+						Sources: []corev1.VolumeProjection{
+							{Secret: logicalBackupAuthority(primaryCertificate)},
+						},
+					},
+				}},
+			},
+		},
+	}
+}
+
+// logicalBackupCommand returns an entrypoint that dumps each database in
+// the custom format of pg_dump to a directory of the same name. Only the
+// newest retention dumps of each database are kept. When databases is empty,
+// every database that allows connections, except templates, is dumped.
+func logicalBackupCommand(retention int32, databases []v1beta1.PostgresIdentifier) []string {
+	script := strings.Join([]string{
+		`declare -r retention="$1"; shift`,
+		`if [[ "$#" -eq 0 ]]; then`,
+		`  listed="$(psql -X --no-align --tuples-only --dbname=postgres --command='` +
+			`SELECT datname FROM pg_catalog.pg_database WHERE datallowconn AND NOT datistemplate ORDER BY 1')"`,
+		`  mapfile -t databases <<< "${listed}"`,
+		`  set -- "${databases[@]}"`,
+		`fi`,
+		`stamp="$(date -u +%Y%m%dT%H%M%SZ)"`,
+		`failed=0`,
+		`cd ` + logicalBackupMountPath,
+		`for database in "$@"; do`,
+		`  mkdir -p "./${database}"`,
+		`  if PGDATABASE="${database}" pg_dump --format=custom --file="./${database}/${stamp}.dump.partial"; then`,
+		`    mv "./${database}/${stamp}.dump.partial" "./${database}/${stamp}.dump"`,
+		`    printf 'Dumped database "%s" to "%s"\n' "${database}" "${database}/${stamp}.dump"`,
+		`    find "./${database}" -maxdepth 1 -name '*.dump' -print0 | sort -rz |`,
+		`    tail -zn "+$((retention + 1))" | xargs -0r rm -f --`,
+		`  else`,
+		`    rm -f "./${database}/${stamp}.dump.partial"`,
+		`    printf 'Unable to dump database "%s"\n' "${database}" >&2`,
+		`    failed=1`,
+		`  fi`,
+		`done`,
+		`exit "${failed}"`,
+	}, "\n")
+
+	command := []string{"bash", "-ceu", "--", script, "logical-backup", fmt.Sprint(retention)}
+	for _, database := range databases {
+		command = append(command, string(database))
+	}
+	return command
+}
+
+// logicalRestoreCommand returns a pg_restore command that restores the dump
+// in spec into its database.
+func logicalRestoreCommand(spec *v1beta1.LogicalRestore) []string {
+	// The value of "--dbname" is a connection string, so quote the database
+	// name in case it has spaces or other special characters.
+	// - https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNSTRING
+	quoted := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(string(spec.Database))
+
+	command := []string{"pg_restore", "--dbname=dbname='" + quoted + "'", "--verbose"}
+	for _, table := range spec.Tables {
+		command = append(command, "--table="+table)
+	}
+	command = append(command, spec.Options...)
+	return append(command, "--", logicalBackupMountPath+"/"+spec.File)
+}
+
+// generateLogicalBackupCronJob returns the CronJob that dumps the databases of
+// cluster on its schedule. It is suspended while cluster is shut down or is a
+// standby.
+func generateLogicalBackupCronJob(
+	cluster *v1beta1.PostgresCluster, primaryCertificate *corev1.SecretProjection,
+) *batchv1.CronJob {
+	spec := cluster.Spec.Backups.Logical
+	retention := int32(logicalBackupRetention)
+	if spec.Retention != nil {
+		retention = *spec.Retention
+	}
+
+	template := logicalBackupPodTemplate(cluster, primaryCertificate,
+		logicalBackupCommand(retention, spec.Databases))
+
+	suspend := (cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown) ||
+		(cluster.Spec.Standby != nil && cluster.Spec.Standby.Enabled)
+
+	cronjob := &batchv1.CronJob{ObjectMeta: naming.LogicalBackup(cluster)}
+	cronjob.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("CronJob"))
+	cronjob.Annotations = template.Annotations
+	cronjob.Labels = template.Labels
+	cronjob.Spec = batchv1.CronJobSpec{
+		Schedule:          spec.Schedule,
+		Suspend:           &suspend,
+		ConcurrencyPolicy: batchv1.ForbidConcurrent,
+		JobTemplate: batchv1.JobTemplateSpec{
+			ObjectMeta: template.ObjectMeta,
+			Spec:       batchv1.JobSpec{Template: template},
+		},
+	}
+	return cronjob
+}
+
+// generateLogicalRestoreJob returns the Job that restores the dump in the
+// spec of cluster. The Job is annotated with id, the value of the
+// "logical-restore" annotation that asked for it.
+func generateLogicalRestoreJob(
+	cluster *v1beta1.PostgresCluster, primaryCertificate *corev1.SecretProjection, id string,
+) *batchv1.Job {
+	template := logicalBackupPodTemplate(cluster, primaryCertificate,
+		logicalRestoreCommand(cluster.Spec.Backups.Logical.Restore))
+
+	// Kubernetes reports the last lines of output when the restore fails.
+	// - https://kubernetes.io/docs/tasks/debug/debug-application/determine-reason-pod-failure/
+	template.Spec.Containers[0].TerminationMessagePolicy =
+		corev1.TerminationMessageFallbackToLogsOnError
+
+	job := &batchv1.Job{ObjectMeta: naming.LogicalRestoreJob(cluster)}
+	job.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+	job.Annotations = naming.Merge(template.Annotations,
+		map[string]string{naming.LogicalRestore: id})
+	job.Labels = template.Labels
+
+	// A restore that fails part way is not run again; the database may need
+	// attention before another attempt.
+	job.Spec = batchv1.JobSpec{BackoffLimit: initialize.Int32(0), Template: template}
+	return job
+}
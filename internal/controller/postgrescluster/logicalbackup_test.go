@@ -0,0 +1,425 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
+	"github.com/crunchydata/postgres-operator/internal/testing/require"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestLogicalBackupHBAs(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+
+	outHBAs := postgres.HBAs{}
+	logicalBackupHBAs(cluster, &outHBAs)
+	assert.Equal(t, len(outHBAs.Mandatory), 0)
+
+	cluster.Spec.Backups.Logical = &v1beta1.LogicalBackups{}
+	logicalBackupHBAs(cluster, &outHBAs)
+	assert.Equal(t, len(outHBAs.Mandatory), 2)
+	assert.Equal(t, outHBAs.Mandatory[0].String(), `hostssl all "_crunchydump" all scram-sha-256`)
+	assert.Equal(t, outHBAs.Mandatory[1].String(), `host all "_crunchydump" all reject`)
+}
+
+func TestLogicalBackupCommand(t *testing.T) {
+	shellcheck := require.ShellCheck(t)
+
+	command := logicalBackupCommand(3, []v1beta1.PostgresIdentifier{"app", "two words"})
+	assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+	assert.DeepEqual(t, command[4:], []string{"logical-backup", "3", "app", "two words"})
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "script.bash")
+	assert.NilError(t, os.WriteFile(file, []byte(command[3]), 0o600))
+
+	// Expect shellcheck to be happy.
+	cmd := exec.Command(shellcheck, "--enable=all", "--shell=bash", file)
+	output, err := cmd.CombinedOutput()
+	assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+}
+
+func TestLogicalRestoreCommand(t *testing.T) {
+	assert.DeepEqual(t, logicalRestoreCommand(&v1beta1.LogicalRestore{
+		Database: "app", File: "app/20230102T030405Z.dump",
+	}), []string{
+		"pg_restore", "--dbname=dbname='app'", "--verbose",
+		"--", "/pgdump/app/20230102T030405Z.dump",
+	})
+
+	assert.DeepEqual(t, logicalRestoreCommand(&v1beta1.LogicalRestore{
+		Database: `it's \here`, File: "x.dump",
+		Tables:  []string{"orders", "items"},
+		Options: []string{"--clean", "--if-exists"},
+	}), []string{
+		"pg_restore", `--dbname=dbname='it\'s \\here'`, "--verbose",
+		"--table=orders", "--table=items", "--clean", "--if-exists",
+		"--", "/pgdump/x.dump",
+	})
+}
+
+func TestValidateLogicalRestoreOptions(t *testing.T) {
+	assert.NilError(t, validateLogicalRestoreOptions(nil))
+	assert.NilError(t, validateLogicalRestoreOptions([]string{
+		"--clean", "--if-exists", "--data-only", "--disable-triggers", "-j4",
+	}))
+
+	// Values can be attached or in the next argument, even when they look
+	// like options.
+	assert.NilError(t, validateLogicalRestoreOptions([]string{
+		"--schema", "app", "-t", "--file", "--section=data", "-cOj", "4", "--if", "--clea",
+	}))
+
+	for _, option := range []string{
+		"--dbname=other", "-dother", "-d", "--file=out.sql", "-f", "--list", "-l",
+		"--use-list=x", "-L", "--host=elsewhere", "-U", "--password",
+
+		// Abbreviations of long options.
+		"--dbn=other", "--fi=out.sql", "--li", "--use", "--ho",
+
+		// Ambiguous and unknown options.
+		"--no-", "--nope", "-Z",
+
+		// Short options grouped with others.
+		"-cdother", "-vf", "-1l",
+
+		// Arguments that are not options.
+		"other.dump", "-", "--",
+	} {
+		assert.ErrorContains(t, validateLogicalRestoreOptions([]string{option}),
+			"not allowed", "option %q", option)
+	}
+
+	// The value of an option is not checked as another option, but the
+	// argument after it is.
+	assert.ErrorContains(t, validateLogicalRestoreOptions([]string{
+		"-t", "orders", "--file=out.sql",
+	}), "--file=out.sql")
+}
+
+func TestValidateLogicalRestoreFile(t *testing.T) {
+	for _, file := range []string{"x.dump", "app/x.dump", "..x.dump", "app/...dump", "a..b/x.dump"} {
+		assert.NilError(t, validateLogicalRestoreFile(file), "file %q", file)
+	}
+
+	for _, file := range []string{"../x.dump", "app/../../x.dump", "app/.."} {
+		assert.ErrorContains(t, validateLogicalRestoreFile(file), "not allowed", "file %q", file)
+	}
+}
+
+func TestLogicalBackupAuthority(t *testing.T) {
+	assert.DeepEqual(t, logicalBackupAuthority(&corev1.SecretProjection{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "hippo-cluster-cert"},
+	}), &corev1.SecretProjection{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "hippo-cluster-cert"},
+		Items:                []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+	})
+
+	// Custom certificates map their keys to the usual paths.
+	assert.DeepEqual(t, logicalBackupAuthority(&corev1.SecretProjection{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "custom"},
+		Items: []corev1.KeyToPath{
+			{Key: "my-ca", Path: "ca.crt"},
+			{Key: "my-cert", Path: "tls.crt"},
+			{Key: "my-key", Path: "tls.key"},
+		},
+	}), &corev1.SecretProjection{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "custom"},
+		Items:                []corev1.KeyToPath{{Key: "my-ca", Path: "ca.crt"}},
+	})
+}
+
+func TestGenerateLogicalBackupCronJob(t *testing.T) {
+	cluster := testCluster()
+	cluster.Namespace = "ns1"
+	cluster.Spec.Port = initialize.Int32(5432)
+	cluster.Spec.Backups.Logical = &v1beta1.LogicalBackups{
+		Schedule:        "0 1 * * *",
+		VolumeClaimSpec: testVolumeClaimSpec(),
+	}
+	certificate := &corev1.SecretProjection{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "hippo-cluster-cert"},
+	}
+
+	cronjob := generateLogicalBackupCronJob(cluster, certificate)
+	assert.Equal(t, cronjob.Name, "hippo-logical-backup")
+	assert.Equal(t, cronjob.Spec.Schedule, "0 1 * * *")
+	assert.Equal(t, *cronjob.Spec.Suspend, false)
+	assert.Equal(t, cronjob.Spec.ConcurrencyPolicy, batchv1.ForbidConcurrent)
+	assert.Equal(t, cronjob.Labels[naming.LabelRole], naming.RoleLogicalBackup)
+
+	pod := cronjob.Spec.JobTemplate.Spec.Template.Spec
+	assert.Equal(t, pod.RestartPolicy, corev1.RestartPolicyNever)
+	assert.Equal(t, len(pod.Containers), 1)
+	assert.DeepEqual(t, pod.Containers[0].Command[4:], []string{"logical-backup", "7"})
+
+	assert.Assert(t, marshalMatches(pod.Containers[0].Env, `
+- name: PGHOST
+  value: hippo-primary.ns1.svc
+- name: PGPORT
+  value: "5432"
+- name: PGUSER
+  value: _crunchydump
+- name: PGPASSWORD
+  valueFrom:
+    secretKeyRef:
+      key: password
+      name: hippo-logical-backup
+- name: PGSSLMODE
+  value: verify-full
+- name: PGSSLROOTCERT
+  value: /pgconf/tls/ca.crt
+	`))
+	assert.Assert(t, marshalMatches(pod.Volumes, `
+- name: pgdump
+  persistentVolumeClaim:
+    claimName: hippo-logical-backup
+- name: cert-volume
+  projected:
+    sources:
+    - secret:
+        items:
+        - key: ca.crt
+          path: ca.crt
+        name: hippo-cluster-cert
+	`))
+
+	t.Run("Retention", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.Logical.Retention = initialize.Int32(2)
+		cluster.Spec.Backups.Logical.Databases = []v1beta1.PostgresIdentifier{"app"}
+
+		cronjob := generateLogicalBackupCronJob(cluster, certificate)
+		assert.DeepEqual(t,
+			cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Command[4:],
+			[]string{"logical-backup", "2", "app"})
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Shutdown = initialize.Bool(true)
+
+		cronjob := generateLogicalBackupCronJob(cluster, certificate)
+		assert.Equal(t, *cronjob.Spec.Suspend, true)
+	})
+}
+
+func TestReconcileLogicalBackupUser(t *testing.T) {
+	ctx := context.Background()
+
+	instances := &observedInstances{forCluster: []*Instance{{
+		Name: "instance",
+		Pods: []*corev1.Pod{{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "ns1",
+				Name:        "pod",
+				Annotations: map[string]string{"status": `{"role":"master"}`},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: naming.ContainerDatabase,
+					State: corev1.ContainerState{
+						Running: new(corev1.ContainerStateRunning),
+					},
+				}},
+			},
+		}},
+	}}}
+
+	var calls []string
+	r := &Reconciler{PodExec: func(
+		namespace, pod, container string,
+		stdin io.Reader, _, _ io.Writer, command ...string,
+	) error {
+		b, _ := io.ReadAll(stdin)
+		calls = append(calls, string(b))
+		return nil
+	}}
+
+	cluster := &v1beta1.PostgresCluster{}
+	secret := &corev1.Secret{Data: map[string][]byte{"verifier": []byte("SCRAM-SHA-256$abc")}}
+
+	t.Run("Create", func(t *testing.T) {
+		calls = nil
+		assert.NilError(t, r.reconcileLogicalBackupUser(ctx, cluster, instances, secret))
+		assert.Equal(t, len(calls), 1)
+		assert.Assert(t, strings.Contains(calls[0], "CREATE ROLE"))
+		assert.Assert(t, cluster.Status.LogicalBackupRevision != "")
+
+		// Nothing happens when the password is the same.
+		assert.NilError(t, r.reconcileLogicalBackupUser(ctx, cluster, instances, secret))
+		assert.Equal(t, len(calls), 1)
+	})
+
+	t.Run("NoPrimary", func(t *testing.T) {
+		calls = nil
+		cluster := &v1beta1.PostgresCluster{}
+		assert.NilError(t, r.reconcileLogicalBackupUser(ctx, cluster, nil, secret))
+		assert.Equal(t, len(calls), 0)
+		assert.Equal(t, cluster.Status.LogicalBackupRevision, "")
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		calls = nil
+		assert.NilError(t, r.reconcileLogicalBackupUser(ctx, cluster, instances, nil))
+		assert.Equal(t, len(calls), 1)
+		assert.Assert(t, strings.Contains(calls[0], "NOLOGIN"))
+		assert.Equal(t, cluster.Status.LogicalBackupRevision, "")
+
+		// Nothing happens once the user is disabled.
+		assert.NilError(t, r.reconcileLogicalBackupUser(ctx, cluster, instances, nil))
+		assert.Equal(t, len(calls), 1)
+	})
+}
+
+func TestReconcileLogicalRestore(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name, cluster.UID = "ns1", "hippo", "cluster-uid"
+	cluster.Annotations = map[string]string{naming.LogicalRestore: "one"}
+	cluster.Spec.Backups.Logical = &v1beta1.LogicalBackups{
+		Restore: &v1beta1.LogicalRestore{Database: "app", File: "app/x.dump"},
+	}
+
+	job := &batchv1.Job{ObjectMeta: naming.LogicalRestoreJob(cluster)}
+	job.UID = "job-uid" // The fake client does not assign one.
+	job.Annotations = map[string]string{naming.LogicalRestore: "one"}
+	job.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"job-name": job.Name},
+	}
+	job.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: v1beta1.GroupVersion.String(), Kind: "PostgresCluster",
+		Name: cluster.Name, UID: cluster.UID, Controller: initialize.Bool(true),
+	}}
+
+	condition := func(cluster *v1beta1.PostgresCluster) *metav1.Condition {
+		return meta.FindStatusCondition(cluster.Status.Conditions, ConditionLogicalRestore)
+	}
+
+	t.Run("NotRequested", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Annotations = nil
+
+		r := &Reconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+		assert.NilError(t, r.reconcileLogicalRestore(ctx, cluster, nil))
+		assert.Assert(t, condition(cluster) == nil)
+	})
+
+	t.Run("InvalidOptions", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.Logical.Restore.Options = []string{"--dbname=postgres"}
+
+		recorder := events.NewRecorder(t, scheme)
+		r := &Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).Build(),
+			Recorder: recorder,
+		}
+		assert.NilError(t, r.reconcileLogicalRestore(ctx, cluster, nil))
+		assert.Equal(t, condition(cluster).Status, metav1.ConditionFalse)
+		assert.Equal(t, condition(cluster).Reason, "InvalidLogicalRestore")
+		assert.Equal(t, len(recorder.Events), 1)
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		complete := job.DeepCopy()
+		complete.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+		}}
+
+		recorder := events.NewRecorder(t, scheme)
+		r := &Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(complete).Build(),
+			Recorder: recorder,
+		}
+		assert.NilError(t, r.reconcileLogicalRestore(ctx, cluster, nil))
+		assert.Equal(t, condition(cluster).Status, metav1.ConditionTrue)
+		assert.Equal(t, condition(cluster).Reason, "LogicalRestoreComplete")
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeNormal)
+
+		// No additional event while the result is the same.
+		assert.NilError(t, r.reconcileLogicalRestore(ctx, cluster, nil))
+		assert.Equal(t, len(recorder.Events), 1)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		failed := job.DeepCopy()
+		failed.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobFailed, Status: corev1.ConditionTrue,
+		}}
+
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = job.Namespace, job.Name+"-xyz"
+		pod.Labels = map[string]string{"job-name": job.Name}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				ExitCode: 1,
+				Message:  `pg_restore: error: could not open input file "/pgdump/app/x.dump"`,
+			}},
+		}}
+
+		recorder := events.NewRecorder(t, scheme)
+		r := &Reconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(failed, pod).Build(),
+			Recorder: recorder,
+		}
+		assert.NilError(t, r.reconcileLogicalRestore(ctx, cluster, nil))
+		assert.Equal(t, condition(cluster).Status, metav1.ConditionFalse)
+		assert.Equal(t, condition(cluster).Reason, "LogicalRestoreFailed")
+		assert.Assert(t, strings.Contains(condition(cluster).Message, "could not open input file"))
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+	})
+
+	t.Run("ReplacedByAnotherRestore", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Annotations[naming.LogicalRestore] = "two"
+
+		cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(job.DeepCopy()).Build()
+		r := &Reconciler{Client: cc}
+		assert.NilError(t, r.reconcileLogicalRestore(ctx, cluster, nil))
+
+		err := cc.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		assert.Assert(t, apierrors.IsNotFound(err), "expected the Job to be deleted, got %v", err)
+	})
+}
@@ -0,0 +1,86 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+var (
+	metricBackupTime = prometheus.NewDesc(
+		"pgo_pgbackrest_last_backup_timestamp_seconds",
+		"When the newest pgBackRest backup of each type in a repository finished, as seconds since the Unix epoch.",
+		[]string{"namespace", "cluster", "repo", "type"}, nil)
+
+	metricBackupSize = prometheus.NewDesc(
+		"pgo_pgbackrest_last_backup_size_bytes",
+		"The size in bytes of the newest pgBackRest backup in a repository, after compression.",
+		[]string{"namespace", "cluster", "repo"}, nil)
+)
+
+// backupCollector reports the backup information in the status of every
+// PostgresCluster as Prometheus metrics. It reads clusters when it is scraped
+// so that deleted clusters and repos disappear from the metrics.
+type backupCollector struct {
+	client.Reader
+}
+
+var _ prometheus.Collector = backupCollector{}
+
+// Describe implements [prometheus.Collector].
+func (backupCollector) Describe(descriptions chan<- *prometheus.Desc) {
+	descriptions <- metricBackupTime
+	descriptions <- metricBackupSize
+}
+
+// Collect implements [prometheus.Collector].
+func (c backupCollector) Collect(metrics chan<- prometheus.Metric) {
+	clusters := &v1beta1.PostgresClusterList{}
+	if err := c.List(context.Background(), clusters); err != nil {
+		metrics <- prometheus.NewInvalidMetric(metricBackupTime, err)
+		return
+	}
+
+	for _, cluster := range clusters.Items {
+		if cluster.Status.PGBackRest == nil {
+			continue
+		}
+		for _, repo := range cluster.Status.PGBackRest.Repos {
+			for backupType, finished := range map[string]*metav1.Time{
+				full:         repo.LastFullBackupTime,
+				differential: repo.LastDifferentialBackupTime,
+				incremental:  repo.LastIncrementalBackupTime,
+			} {
+				if finished != nil {
+					metrics <- prometheus.MustNewConstMetric(metricBackupTime,
+						prometheus.GaugeValue, float64(finished.Unix()),
+						cluster.Namespace, cluster.Name, repo.Name, backupType)
+				}
+			}
+			if repo.LastBackupSize != nil {
+				metrics <- prometheus.MustNewConstMetric(metricBackupSize,
+					prometheus.GaugeValue, float64(*repo.LastBackupSize),
+					cluster.Namespace, cluster.Name, repo.Name)
+			}
+		}
+	}
+}
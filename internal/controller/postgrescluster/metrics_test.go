@@ -0,0 +1,72 @@
+//go:build envtest
+// +build envtest
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/v3/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pgoruntime "github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestBackupCollector(t *testing.T) {
+	scheme, err := pgoruntime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	finished := metav1.NewTime(time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	hippo := &v1beta1.PostgresCluster{}
+	hippo.Namespace, hippo.Name = "ns1", "hippo"
+	hippo.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{
+			{
+				Name:                      "repo1",
+				LastFullBackupTime:        &finished,
+				LastIncrementalBackupTime: &metav1.Time{Time: finished.Add(time.Hour)},
+				LastBackupSize:            initialize.Int64(100),
+			},
+			{Name: "repo2"},
+		},
+	}
+
+	rhino := &v1beta1.PostgresCluster{}
+	rhino.Namespace, rhino.Name = "ns2", "rhino"
+
+	collector := backupCollector{
+		fake.NewClientBuilder().WithScheme(scheme).WithObjects(hippo, rhino).Build(),
+	}
+
+	assert.NilError(t, testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP pgo_pgbackrest_last_backup_size_bytes The size in bytes of the newest pgBackRest backup in a repository, after compression.
+# TYPE pgo_pgbackrest_last_backup_size_bytes gauge
+pgo_pgbackrest_last_backup_size_bytes{cluster="hippo",namespace="ns1",repo="repo1"} 100
+# HELP pgo_pgbackrest_last_backup_timestamp_seconds When the newest pgBackRest backup of each type in a repository finished, as seconds since the Unix epoch.
+# TYPE pgo_pgbackrest_last_backup_timestamp_seconds gauge
+pgo_pgbackrest_last_backup_timestamp_seconds{cluster="hippo",namespace="ns1",repo="repo1",type="full"} 1.6725312e+09
+pgo_pgbackrest_last_backup_timestamp_seconds{cluster="hippo",namespace="ns1",repo="repo1",type="incr"} 1.6725348e+09
+`)))
+}
@@ -286,6 +286,7 @@ func (r *Reconciler) generatePatroniLeaderLeaseService(
 			}
 			servicePort.NodePort = *spec.NodePort
 		}
+		setServiceTrafficPolicies(service, spec)
 	}
 	service.Spec.Ports = []corev1.ServicePort{servicePort}
 
@@ -191,6 +191,7 @@ func (r *Reconciler) generatePGAdminService(
 			}
 			servicePort.NodePort = *spec.NodePort
 		}
+		setServiceTrafficPolicies(service, spec)
 	}
 	service.Spec.Ports = []corev1.ServicePort{servicePort}
 
@@ -343,7 +344,7 @@ func (r *Reconciler) reconcilePGAdminStatefulSet(
 
 	// add an emptyDir volume to the PodTemplateSpec and an associated '/tmp'
 	// volume mount to all containers included within that spec
-	addTMPEmptyDir(&sts.Spec.Template)
+	addTMPEmptyDir(&sts.Spec.Template, nil)
 
 	return errors.WithStack(r.apply(ctx, sts))
 }
@@ -16,12 +16,14 @@ package postgrescluster
 */
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -38,6 +40,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -70,6 +74,14 @@ const (
 	// the pgBackRest repository for creating replicas is ready
 	ConditionReplicaRepoReady = "PGBackRestReplicaRepoReady"
 
+	// ConditionArchiving is the type used in a condition to indicate whether or not WAL
+	// archiving is paused, and whether a full backup has been taken since it resumed
+	ConditionArchiving = "PGBackRestArchiving"
+
+	// ConditionPostUpgradeBackup is the type used in a condition to indicate whether or not
+	// a full backup has been taken since a major upgrade of PostgreSQL
+	ConditionPostUpgradeBackup = "PGBackRestPostUpgradeBackup"
+
 	// ConditionRepoHostReady is the type used in a condition to indicate whether or not a
 	// pgBackRest repository host PostgresCluster is ready
 	ConditionRepoHostReady = "PGBackRestRepoHostReady"
@@ -78,6 +90,27 @@ const (
 	// and in-place pgBackRest restore is in progress
 	ConditionPGBackRestRestoreProgressing = "PGBackRestoreProgressing"
 
+	// ConditionLastBackupSucceeded is the type used in a condition to indicate whether or not
+	// the most recent pgBackRest backup Job to finish completed successfully
+	ConditionLastBackupSucceeded = "LastBackupSucceeded"
+
+	// ConditionPGBackRestGlobalValid is the type used in a condition to indicate that
+	// some pgBackRest global options are rejected because PGO manages them
+	ConditionPGBackRestGlobalValid = "PGBackRestGlobalValid"
+
+	// ConditionPGBackRestWebIdentityValid is the type used in a condition to indicate
+	// that some pgBackRest repositories cannot use the web identity they specify
+	ConditionPGBackRestWebIdentityValid = "PGBackRestWebIdentityValid"
+
+	// ConditionPGBackRestRepoPathsValid is the type used in a condition to indicate
+	// that the paths of some pgBackRest repositories are ignored
+	ConditionPGBackRestRepoPathsValid = "PGBackRestRepoPathsValid"
+
+	// ConditionPGBackRestConfigIncludeValid is the type used in a condition to indicate
+	// that the pgBackRest configuration files of spec.backups.pgbackrest.configInclude
+	// are left out
+	ConditionPGBackRestConfigIncludeValid = "PGBackRestConfigIncludeValid"
+
 	// EventRepoHostNotFound is used to indicate that a pgBackRest repository was not
 	// found when reconciling
 	EventRepoHostNotFound = "RepoDeploymentNotFound"
@@ -86,6 +119,14 @@ const (
 	// created
 	EventRepoHostCreated = "RepoHostCreated"
 
+	// EventRepoHostFailover is the event reason utilized when another pgBackRest repository
+	// host pod becomes active because the active one is not ready
+	EventRepoHostFailover = "RepoHostFailover"
+
+	// EventInvalidRepoHostReplicas is the event reason utilized when a pgBackRest repository
+	// host cannot have the number of pods it specifies
+	EventInvalidRepoHostReplicas = "InvalidRepoHostReplicas"
+
 	// EventUnableToCreateStanzas is the event reason utilized when pgBackRest is unable to create
 	// stanzas for the repositories in a PostgreSQL cluster
 	EventUnableToCreateStanzas = "UnableToCreateStanzas"
@@ -94,14 +135,57 @@ const (
 	// completes successfully
 	EventStanzasCreated = "StanzasCreated"
 
+	// EventStanzasUpgraded is the event reason utilized when pgBackRest stanzas are upgraded
+	// after a major upgrade of PostgreSQL
+	EventStanzasUpgraded = "StanzasUpgraded"
+
+	// EventStanzaAdopted is the event reason utilized when a stanza that already exists in a
+	// repository is used rather than created
+	EventStanzaAdopted = "StanzaAdopted"
+
+	// EventStanzaNotAdopted is the event reason utilized when a stanza that already exists in a
+	// repository cannot be used because it belongs to another database
+	EventStanzaNotAdopted = "StanzaNotAdopted"
+
 	// EventUnableToCreatePGBackRestCronJob is the event reason utilized when a pgBackRest backup
 	// CronJob fails to create successfully
 	EventUnableToCreatePGBackRestCronJob = "UnableToCreatePGBackRestCronJob"
 
+	// EventRepoVerifyFailed is the event reason utilized when a scheduled pgBackRest verify
+	// finds errors in a repository
+	EventRepoVerifyFailed = "RepoVerifyFailed"
+
+	// EventRepoSyncFailed is the event reason utilized when a scheduled copy of a pgBackRest
+	// repository to its sync bucket fails
+	EventRepoSyncFailed = "RepoSyncFailed"
+
+	// EventInvalidRepoSync is the event reason utilized when a pgBackRest repository cannot be
+	// copied to its sync bucket as specified
+	EventInvalidRepoSync = "InvalidRepoSync"
+
+	// EventBackupStarted is the event reason utilized when a pgBackRest backup Job starts
+	EventBackupStarted = "BackupStarted"
+
+	// EventBackupCompleted is the event reason utilized when a pgBackRest backup Job
+	// completes successfully
+	EventBackupCompleted = "BackupCompleted"
+
+	// EventBackupFailed is the event reason utilized when a pgBackRest backup Job fails
+	EventBackupFailed = "BackupFailed"
+
+	// EventBackupHookFailed is the event reason utilized when a hook before or after a
+	// scheduled full backup fails
+	EventBackupHookFailed = "BackupHookFailed"
+
 	// ReasonReadyForRestore is the reason utilized within ConditionPGBackRestRestoreProgressing
 	// to indicate that the restore Job can proceed because the cluster is now ready to be
 	// restored (i.e. it has been properly prepared for a restore).
 	ReasonReadyForRestore = "ReadyForRestore"
+
+	// ReasonInvalidRestoreOptions is the reason utilized within ConditionPGBackRestRestoreProgressing
+	// and ConditionPostgresDataInitialized to indicate that a restore cannot proceed because its
+	// options, repository, or backup set are not valid.
+	ReasonInvalidRestoreOptions = "InvalidRestoreOptions"
 )
 
 // backup types
@@ -111,15 +195,35 @@ const (
 	incremental  = "incr"
 )
 
+// verify is the scheduled Job type for pgBackRest verify, which is scheduled like a backup
+const verify = "verify"
+
+// expire is the scheduled Job type for pgBackRest expire, which is scheduled like a backup
+const expire = "expire"
+
+// defaultExpireSchedule is when pgBackRest expire runs for a repo that has time-based
+// retention but no expireSchedule: every day at midnight
+const defaultExpireSchedule = "0 0 * * *"
+
+// repoSync is the scheduled Job type for copying a repository to its sync bucket, which is
+// scheduled like a backup
+const repoSync = "sync"
+
+// restoreProgressInterval is how often PGO observes the progress of a running restore
+const restoreProgressInterval = 30 * time.Second
+
 // regexRepoIndex is the regex used to obtain the repo index from a pgBackRest repo name
 var regexRepoIndex = regexp.MustCompile(`\d+`)
 
 // RepoResources is used to store various resources for pgBackRest repositories and
 // repository hosts
 type RepoResources struct {
+	backupJobs              []*batchv1.Job
 	cronjobs                []*batchv1.CronJob
 	manualBackupJobs        []*batchv1.Job
 	replicaCreateBackupJobs []*batchv1.Job
+	verifyJobs              []*batchv1.Job
+	syncJobs                []*batchv1.Job
 	hosts                   []*appsv1.StatefulSet
 	pvcs                    []*corev1.PersistentVolumeClaim
 }
@@ -339,6 +443,17 @@ func (r *Reconciler) cleanupRepoResources(ctx context.Context,
 	return ownedNoDelete, nil
 }
 
+// repoExpireSchedule returns the Cron schedule of pgBackRest expire for repo, if any.
+// Time-based retention removes backups and WAL as they age, so a repo that has it
+// is expired on a default schedule when it has no expireSchedule.
+func repoExpireSchedule(repo v1beta1.PGBackRestRepo) *string {
+	if repo.ExpireSchedule == nil && repo.RetentionDays != nil {
+		schedule := defaultExpireSchedule
+		return &schedule
+	}
+	return repo.ExpireSchedule
+}
+
 // backupScheduleFound returns true if the CronJob in question should be created as
 // defined by the postgrescluster CRD, otherwise it returns false.
 func backupScheduleFound(repo v1beta1.PGBackRestRepo, backupType string) bool {
@@ -350,10 +465,17 @@ func backupScheduleFound(repo v1beta1.PGBackRestRepo, backupType string) bool {
 			return repo.BackupSchedules.Differential != nil
 		case incremental:
 			return repo.BackupSchedules.Incremental != nil
-		default:
-			return false
 		}
 	}
+	if backupType == verify {
+		return repo.VerifySchedule != nil
+	}
+	if backupType == expire {
+		return repoExpireSchedule(repo) != nil
+	}
+	if backupType == repoSync {
+		return repo.Sync != nil
+	}
 	return false
 }
 
@@ -369,7 +491,8 @@ func unstructuredToRepoResources(kind string, repoResources *RepoResources,
 			FromUnstructured(uList.UnstructuredContent(), &jobList); err != nil {
 			return errors.WithStack(err)
 		}
-		// we care about replica create backup jobs and manual backup jobs
+		// we care about replica create backup jobs, manual backup jobs, verify jobs and
+		// sync jobs
 		for i, job := range jobList.Items {
 			switch job.GetLabels()[naming.LabelPGBackRestBackup] {
 			case string(naming.BackupReplicaCreate):
@@ -379,6 +502,18 @@ func unstructuredToRepoResources(kind string, repoResources *RepoResources,
 				repoResources.manualBackupJobs =
 					append(repoResources.manualBackupJobs, &jobList.Items[i])
 			}
+			switch job.GetLabels()[naming.LabelPGBackRestCronJob] {
+			case verify:
+				repoResources.verifyJobs = append(repoResources.verifyJobs, &jobList.Items[i])
+			case repoSync:
+				repoResources.syncJobs = append(repoResources.syncJobs, &jobList.Items[i])
+			}
+			// and all backup jobs, including those of the CronJobs, for their history limit
+			if _, ok := job.GetLabels()[naming.LabelPGBackRestBackup]; ok {
+				repoResources.backupJobs = append(repoResources.backupJobs, &jobList.Items[i])
+			} else if _, ok := job.GetLabels()[naming.LabelPGBackRestCronJob]; ok {
+				repoResources.backupJobs = append(repoResources.backupJobs, &jobList.Items[i])
+			}
 		}
 	case "PersistentVolumeClaimList":
 		var pvcList corev1.PersistentVolumeClaimList
@@ -448,9 +583,11 @@ func (r *Reconciler) setScheduledJobStatus(ctx context.Context,
 	scheduledStatus := []v1beta1.PGBackRestScheduledBackupStatus{}
 	for _, job := range jobList.Items {
 		// we only care about the scheduled backup Jobs created by the
-		// associated CronJobs
+		// associated CronJobs; verify and sync Jobs are reported in the repo status,
+		// and expire Jobs do not take backups
 		sbs := v1beta1.PGBackRestScheduledBackupStatus{}
-		if job.GetLabels()[naming.LabelPGBackRestCronJob] != "" {
+		if cronJobType := job.GetLabels()[naming.LabelPGBackRestCronJob]; cronJobType != "" &&
+			cronJobType != verify && cronJobType != expire && cronJobType != repoSync {
 			if len(job.OwnerReferences) > 0 {
 				sbs.CronJobName = job.OwnerReferences[0].Name
 			}
@@ -518,8 +655,11 @@ func (r *Reconciler) generateRepoHostIntent(postgresCluster *v1beta1.PostgresClu
 
 	if repoHost := postgresCluster.Spec.Backups.PGBackRest.RepoHost; repoHost != nil {
 		repo.Spec.Template.Spec.Affinity = repoHost.Affinity
+		repo.Spec.Template.Spec.NodeSelector = repoHost.NodeSelector
 		repo.Spec.Template.Spec.Tolerations = repoHost.Tolerations
-		repo.Spec.Template.Spec.TopologySpreadConstraints = repoHost.TopologySpreadConstraints
+		// Copy the constraints so that appending the defaults below cannot change the spec.
+		repo.Spec.Template.Spec.TopologySpreadConstraints = append(
+			[]corev1.TopologySpreadConstraint(nil), repoHost.TopologySpreadConstraints...)
 		if repoHost.PriorityClassName != nil {
 			repo.Spec.Template.Spec.PriorityClassName = *repoHost.PriorityClassName
 		}
@@ -557,8 +697,8 @@ func (r *Reconciler) generateRepoHostIntent(postgresCluster *v1beta1.PostgresClu
 		!instancePodExists {
 		repo.Spec.Replicas = initialize.Int32(0)
 	} else {
-		// the cluster should not be shutdown, set this value to 1
-		repo.Spec.Replicas = initialize.Int32(1)
+		// the cluster should not be shutdown, run the pods of the repository host
+		repo.Spec.Replicas = initialize.Int32(pgbackrest.RepoHostReplicas(postgresCluster))
 	}
 
 	// Restart containers any time they stop, die, are killed, etc.
@@ -604,7 +744,7 @@ func (r *Reconciler) generateRepoHostIntent(postgresCluster *v1beta1.PostgresClu
 		postgresCluster.Spec.ImagePullPolicy,
 		&repo.Spec.Template)
 
-	addTMPEmptyDir(&repo.Spec.Template)
+	addTMPEmptyDir(&repo.Spec.Template, nil)
 
 	// set ownership references
 	if err := controllerutil.SetControllerReference(postgresCluster, repo,
@@ -666,6 +806,31 @@ func generateBackupJobSpecIntent(postgresCluster *v1beta1.PostgresCluster,
 	repo v1beta1.PGBackRestRepo, serviceAccountName string,
 	labels, annotations map[string]string, opts ...string) (*batchv1.JobSpec, error) {
 
+	// Add the backup options of the repo for the type of backup, unless they are
+	// already in opts (e.g. in the options of a manual backup).
+	backupType, _ := pgbackrest.OptionValue(opts, "--type")
+	names := pgbackrest.OptionNames(opts)
+
+	repoOpts, err := pgbackrest.BackupCommandOptions(repo, backupType)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range repoOpts {
+		if !names.HasAny(pgbackrest.OptionNames([]string{opt}).UnsortedList()...) {
+			opts = append(opts, opt)
+		}
+	}
+
+	return generatePGBackRestJobSpecIntent(postgresCluster, repo, "backup",
+		serviceAccountName, labels, annotations, opts...)
+}
+
+// generatePGBackRestJobSpecIntent generates a JobSpec for a job that runs the pgBackRest
+// command against repo
+func generatePGBackRestJobSpecIntent(postgresCluster *v1beta1.PostgresCluster,
+	repo v1beta1.PGBackRestRepo, command, serviceAccountName string,
+	labels, annotations map[string]string, opts ...string) (*batchv1.JobSpec, error) {
+
 	selector, containerName, err := getPGBackRestExecSelector(postgresCluster, repo)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -673,17 +838,24 @@ func generateBackupJobSpecIntent(postgresCluster *v1beta1.PostgresCluster,
 
 	repoIndex := regexRepoIndex.FindString(repo.Name)
 	cmdOpts := []string{
-		"--stanza=" + pgbackrest.DefaultStanzaName,
+		"--stanza=" + pgbackrest.StanzaName(postgresCluster),
 		"--repo=" + repoIndex,
 	}
 	cmdOpts = append(cmdOpts, opts...)
 
+	// The configuration of a shared repo host is not that of this cluster, so
+	// there is no hash of it to compare.
+	compareHash := "true"
+	if repo.SharedHost != nil {
+		compareHash = "false"
+	}
+
 	container := corev1.Container{
 		Command: []string{"/opt/crunchy/bin/pgbackrest"},
 		Env: []corev1.EnvVar{
-			{Name: "COMMAND", Value: "backup"},
+			{Name: "COMMAND", Value: command},
 			{Name: "COMMAND_OPTS", Value: strings.Join(cmdOpts, " ")},
-			{Name: "COMPARE_HASH", Value: "true"},
+			{Name: "COMPARE_HASH", Value: compareHash},
 			{Name: "CONTAINER", Value: containerName},
 			{Name: "NAMESPACE", Value: postgresCluster.GetNamespace()},
 			{Name: "SELECTOR", Value: selector.String()},
@@ -722,9 +894,11 @@ func generateBackupJobSpecIntent(postgresCluster *v1beta1.PostgresCluster,
 
 	if jobs := postgresCluster.Spec.Backups.PGBackRest.Jobs; jobs != nil {
 		jobSpec.TTLSecondsAfterFinished = jobs.TTLSecondsAfterFinished
+		jobSpec.BackoffLimit = jobs.BackoffLimit
+		jobSpec.ActiveDeadlineSeconds = jobs.ActiveDeadlineSeconds
 	}
 
-	// set the priority class name, tolerations, and affinity, if they exist
+	// set the priority class name, tolerations, affinity, and node selector, if they exist
 	if postgresCluster.Spec.Backups.PGBackRest.Jobs != nil {
 		if postgresCluster.Spec.Backups.PGBackRest.Jobs.PriorityClassName != nil {
 			jobSpec.Template.Spec.PriorityClassName =
@@ -732,6 +906,7 @@ func generateBackupJobSpecIntent(postgresCluster *v1beta1.PostgresCluster,
 		}
 		jobSpec.Template.Spec.Tolerations = postgresCluster.Spec.Backups.PGBackRest.Jobs.Tolerations
 		jobSpec.Template.Spec.Affinity = postgresCluster.Spec.Backups.PGBackRest.Jobs.Affinity
+		jobSpec.Template.Spec.NodeSelector = postgresCluster.Spec.Backups.PGBackRest.Jobs.NodeSelector
 	}
 
 	// Set the image pull secrets, if any exist.
@@ -750,6 +925,140 @@ func generateBackupJobSpecIntent(postgresCluster *v1beta1.PostgresCluster,
 	return jobSpec, nil
 }
 
+// backupJobPodFailurePolicy returns the pod failure policy of the backup Jobs
+// of postgresCluster, if any.
+func backupJobPodFailurePolicy(
+	postgresCluster *v1beta1.PostgresCluster,
+) *v1beta1.JobPodFailurePolicy {
+	if jobs := postgresCluster.Spec.Backups.PGBackRest.Jobs; jobs != nil {
+		return jobs.PodFailurePolicy
+	}
+	return nil
+}
+
+// generateRepoSyncJobSpecIntent generates a JobSpec for a job that copies repo to the bucket
+// in its sync field with rclone. A repo on a volume is read from the PVC named claimName. It
+// returns an error when repo cannot be copied.
+func generateRepoSyncJobSpecIntent(postgresCluster *v1beta1.PostgresCluster,
+	repo v1beta1.PGBackRestRepo, claimName, serviceAccountName string,
+	labels, annotations map[string]string) (*batchv1.JobSpec, error) {
+
+	source, destination, err := pgbackrest.SyncRemotes(postgresCluster, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	image := config.RepoSyncContainerImage(repo.Sync)
+	if image == "" {
+		return nil, errors.New("no image; set the image of sync or RELATED_IMAGE_RCLONE")
+	}
+
+	container := corev1.Container{
+		Command: pgbackrest.SyncCommand(),
+		Env: []corev1.EnvVar{
+			{Name: "SYNC_SOURCE", Value: source},
+			{Name: "SYNC_DESTINATION", Value: destination},
+			// rclone looks for its configuration file in the home directory.
+			{Name: "HOME", Value: "/tmp"},
+		},
+		Image:           image,
+		ImagePullPolicy: postgresCluster.Spec.ImagePullPolicy,
+		Name:            naming.ContainerRepoSync,
+		Resources:       repo.Sync.Resources,
+		SecurityContext: initialize.RestrictedSecurityContext(),
+	}
+
+	if secret := repo.Sync.CredentialsSecret; secret != nil {
+		container.EnvFrom = []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: *secret},
+		}}
+	}
+
+	// OpenShift assigns a user. Otherwise, run as the user that writes the
+	// repository, because the rclone image runs as root by default.
+	if postgresCluster.Spec.OpenShift == nil || !*postgresCluster.Spec.OpenShift {
+		container.SecurityContext.RunAsUser = initialize.Int64(26)
+	}
+
+	jobSpec := &batchv1.JobSpec{
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: labels, Annotations: annotations},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{container},
+
+				// rclone does not make any Kubernetes API calls.
+				AutomountServiceAccountToken: initialize.Bool(false),
+
+				// Disable environment variables for services other than the Kubernetes API.
+				// - https://docs.k8s.io/concepts/services-networking/connect-applications-service/#accessing-the-service
+				// - https://releases.k8s.io/v1.23.0/pkg/kubelet/kubelet_pods.go#L553-L563
+				EnableServiceLinks: initialize.Bool(false),
+
+				RestartPolicy:      corev1.RestartPolicyNever,
+				SecurityContext:    postgres.PodSecurityContext(postgresCluster),
+				ServiceAccountName: serviceAccountName,
+			},
+		},
+	}
+
+	if jobs := postgresCluster.Spec.Backups.PGBackRest.Jobs; jobs != nil {
+		jobSpec.TTLSecondsAfterFinished = jobs.TTLSecondsAfterFinished
+		jobSpec.BackoffLimit = jobs.BackoffLimit
+		jobSpec.ActiveDeadlineSeconds = jobs.ActiveDeadlineSeconds
+
+		if jobs.PriorityClassName != nil {
+			jobSpec.Template.Spec.PriorityClassName = *jobs.PriorityClassName
+		}
+		jobSpec.Template.Spec.Tolerations = jobs.Tolerations
+		jobSpec.Template.Spec.Affinity = jobs.Affinity.DeepCopy()
+		jobSpec.Template.Spec.NodeSelector = jobs.NodeSelector
+	}
+
+	// Read a repository on a volume where it is mounted. The volume may be
+	// ReadWriteOnce, so run on the node of a repository host pod.
+	if repo.Volume != nil {
+		jobSpec.Template.Spec.Volumes = []corev1.Volume{{
+			Name: repo.Name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: claimName,
+					ReadOnly:  true,
+				},
+			},
+		}}
+		jobSpec.Template.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{{
+			Name:      repo.Name,
+			MountPath: source,
+			ReadOnly:  true,
+		}}
+
+		if jobSpec.Template.Spec.Affinity == nil {
+			jobSpec.Template.Spec.Affinity = &corev1.Affinity{}
+		}
+		if jobSpec.Template.Spec.Affinity.PodAffinity == nil {
+			jobSpec.Template.Spec.Affinity.PodAffinity = &corev1.PodAffinity{}
+		}
+		affinity := jobSpec.Template.Spec.Affinity.PodAffinity
+		affinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+			affinity.RequiredDuringSchedulingIgnoredDuringExecution, corev1.PodAffinityTerm{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: naming.PGBackRestDedicatedLabels(postgresCluster.GetName()),
+				},
+				TopologyKey: corev1.LabelHostname,
+			})
+	}
+
+	// Set the image pull secrets, if any exist.
+	// This is set here rather than using the service account due to the lack
+	// of propagation to existing pods when the CRD is updated:
+	// https://github.com/kubernetes/kubernetes/issues/88456
+	jobSpec.Template.Spec.ImagePullSecrets = postgresCluster.Spec.ImagePullSecrets
+
+	addTMPEmptyDir(&jobSpec.Template, nil)
+
+	return jobSpec, nil
+}
+
 // +kubebuilder:rbac:groups="",resources="configmaps",verbs={delete,list}
 // +kubebuilder:rbac:groups="",resources="secrets",verbs={list,delete}
 // +kubebuilder:rbac:groups="",resources="endpoints",verbs={get}
@@ -820,6 +1129,10 @@ func (r *Reconciler) observeRestoreEnv(ctx context.Context,
 			cluster.Status.PGBackRest.Restore.Active = restoreJob.Status.Active
 			if completed || failed {
 				cluster.Status.PGBackRest.Restore.Finished = true
+				cluster.Status.PGBackRest.Restore.Progress = nil
+			} else if err := r.observeRestoreProgress(ctx,
+				cluster.Status.PGBackRest.Restore, restoreJob); err != nil {
+				return nil, nil, err
 			}
 		}
 
@@ -877,6 +1190,234 @@ func (r *Reconciler) observeRestoreEnv(ctx context.Context,
 	return currentEndpoints, restoreJob, nil
 }
 
+// +kubebuilder:rbac:groups="",resources="pods",verbs={list}
+// +kubebuilder:rbac:groups="",resources="pods/exec",verbs={create}
+
+// observeRestoreProgress updates status with the progress of the restore being
+// performed by job. It looks at most once every restoreProgressInterval and
+// only while a Pod of job is running.
+func (r *Reconciler) observeRestoreProgress(ctx context.Context,
+	status *v1beta1.PGBackRestJobStatus, job *batchv1.Job) error {
+	log := logging.FromContext(ctx)
+
+	now := metav1.Now()
+	if status.Progress != nil && status.Progress.LastObservedTime != nil &&
+		now.Sub(status.Progress.LastObservedTime.Time) < restoreProgressInterval {
+		return nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(job.Namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return errors.WithStack(err)
+	}
+
+	var pod *corev1.Pod
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == corev1.PodRunning {
+			pod = &pods.Items[i]
+		}
+	}
+	if pod == nil {
+		return nil
+	}
+
+	// The restore continues regardless of whether its progress can be seen.
+	var stdout, stderr bytes.Buffer
+	if err := r.PodExec(pod.Namespace, pod.Name, naming.PGBackRestRestoreContainerName,
+		nil, &stdout, &stderr,
+		pgbackrest.RestoreProgressCommand(naming.PGBackRestPGDataLogPath)...); err != nil {
+		log.Error(err, "unable to observe restore progress", "stderr", stderr.String())
+		return nil
+	}
+
+	var phase string
+	var percent float64
+	var restored int64
+	if _, err := fmt.Sscan(stdout.String(), &phase, &percent, &restored); err != nil {
+		// pgBackRest has not logged anything yet.
+		return nil
+	}
+
+	progress := &v1beta1.PGBackRestRestoreProgress{
+		Phase:            phase,
+		PercentComplete:  int32(percent),
+		BytesRestored:    restored,
+		LastObservedTime: &now,
+	}
+	if phase == "Restoring" && percent > 0 && percent < 100 && status.StartTime != nil {
+		elapsed := now.Sub(status.StartTime.Time)
+		progress.EstimatedCompletionTime = &metav1.Time{Time: status.StartTime.Add(
+			time.Duration(float64(elapsed) * 100 / percent)).Truncate(time.Second)}
+	}
+	status.Progress = progress
+
+	return nil
+}
+
+// restoreRecheckInterval is how long an invalid restore is remembered before it
+// is checked again, in case it failed because pgBackRest was unavailable.
+const restoreRecheckInterval = 10 * time.Minute
+
+// restoreValidations remembers the restores that were found invalid, by cluster.
+// A restore is identified by its restore ID and the generation of the cluster
+// spec that has its options. The zero value is ready to use; a nil pointer
+// remembers nothing.
+type restoreValidations struct {
+	mu      sync.Mutex
+	invalid map[types.UID]invalidRestore
+}
+
+type invalidRestore struct {
+	key     string
+	checked time.Time
+}
+
+// remembered returns true when the restore of cluster identified by restoreID
+// was found invalid recently.
+func (v *restoreValidations) remembered(
+	cluster *v1beta1.PostgresCluster, restoreID string, now time.Time,
+) bool {
+	if v == nil {
+		return false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	previous, ok := v.invalid[cluster.UID]
+	return ok && previous.key == restoreKey(cluster, restoreID) &&
+		now.Sub(previous.checked) < restoreRecheckInterval
+}
+
+// remember records whether or not the restore of cluster identified by
+// restoreID is valid.
+func (v *restoreValidations) remember(
+	cluster *v1beta1.PostgresCluster, restoreID string, now time.Time, valid bool,
+) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if valid {
+		delete(v.invalid, cluster.UID)
+		return
+	}
+	if v.invalid == nil {
+		v.invalid = make(map[types.UID]invalidRestore)
+	}
+	v.invalid[cluster.UID] = invalidRestore{
+		key: restoreKey(cluster, restoreID), checked: now,
+	}
+}
+
+func restoreKey(cluster *v1beta1.PostgresCluster, restoreID string) string {
+	return fmt.Sprintf("%d/%s", cluster.GetGeneration(), restoreID)
+}
+
+// validateRestore checks a restore before the cluster is prepared for it so that invalid
+// options do not tear down a running cluster only to fail in the restore Job. When restoring
+// in-place from a repository of the cluster while it is writable, pgBackRest is asked whether
+// the backup set exists. It records an event, sets a condition, and returns false when the
+// restore is invalid.
+func (r *Reconciler) validateRestore(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, observed *observedInstances,
+	dataSource *v1beta1.PostgresClusterDataSource,
+	cloudDataSource *v1beta1.PGBackRestDataSource, inPlace bool) bool {
+
+	var options, databases []string
+	var repoName string
+	var sameCluster bool
+	switch {
+	case dataSource != nil:
+		options, repoName = dataSource.Options, dataSource.RepoName
+		databases = dataSource.Databases
+		sameCluster = (dataSource.ClusterName == "" ||
+			dataSource.ClusterName == cluster.Name) &&
+			(dataSource.ClusterNamespace == "" ||
+				dataSource.ClusterNamespace == cluster.Namespace)
+	case cloudDataSource != nil:
+		options, repoName = cloudDataSource.Options, cloudDataSource.Repo.Name
+	}
+
+	err := pgbackrest.ValidateRestoreOptions(options)
+
+	// An in-place restore replaces every database, so it cannot leave any out.
+	if _, hasInclude := pgbackrest.OptionValue(options, "--db-include"); err == nil &&
+		inPlace && (len(databases) > 0 || hasInclude) {
+		err = errors.New("databases can only be selected when restoring into a new cluster")
+	}
+
+	if err == nil && sameCluster {
+		var found bool
+		for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+			found = found || repo.Name == repoName
+		}
+		if !found {
+			err = errors.Errorf("repo %q is not defined in spec.backups.pgbackrest.repos", repoName)
+		}
+	}
+
+	var writableInstanceName string
+	if observed != nil {
+		for _, instance := range observed.forCluster {
+			if writable, known := instance.IsWritable(); writable && known {
+				writableInstanceName = instance.Name + "-0"
+				break
+			}
+		}
+	}
+	if err == nil && sameCluster && inPlace && writableInstanceName != "" {
+		exec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+			command ...string) error {
+			return r.PodExec(cluster.GetNamespace(), writableInstanceName,
+				naming.ContainerDatabase, stdin, stdout, stderr, command...)
+		}
+
+		labels, infoErr := pgbackrest.Executor(exec).BackupLabels(ctx,
+			pgbackrest.StanzaName(cluster), repoName)
+
+		set, hasSet := pgbackrest.OptionValue(options, "--set")
+		var found bool
+		for _, label := range labels {
+			found = found || label == set
+		}
+		switch {
+		case infoErr != nil:
+			err = errors.Errorf("unable to list the backups in repo %q: %v", repoName, infoErr)
+		case len(labels) == 0:
+			err = errors.Errorf("repo %q has no backups to restore", repoName)
+		case hasSet && !found:
+			err = errors.Errorf("backup set %q does not exist in repo %q", set, repoName)
+		}
+	}
+
+	if err == nil {
+		return true
+	}
+
+	condition := metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionPostgresDataInitialized,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonInvalidRestoreOptions,
+		Message:            "Unable to restore: " + err.Error(),
+	}
+	if inPlace {
+		condition.Type = ConditionPGBackRestRestoreProgressing
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+	r.Recorder.Event(cluster, corev1.EventTypeWarning, ReasonInvalidRestoreOptions,
+		condition.Message)
+
+	return false
+}
+
 // +kubebuilder:rbac:groups="",resources="endpoints",verbs={delete}
 // +kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={delete}
 // +kubebuilder:rbac:groups="batch",resources="jobs",verbs={delete}
@@ -1045,7 +1586,8 @@ func (r *Reconciler) reconcileRestoreJob(ctx context.Context,
 	pgdata := postgres.DataDirectory(cluster)
 	// combine options provided by user in the spec with those populated by the operator for a
 	// successful restore
-	opts := append(options, []string{
+	opts := append(options, pgbackrest.RestoreDatabaseOptions(dataSource.Databases)...)
+	opts = append(opts, []string{
 		"--stanza=" + stanzaName,
 		"--pg1-path=" + pgdata,
 		"--repo=" + regexRepoIndex.FindString(repoName)}...)
@@ -1059,8 +1601,12 @@ func (r *Reconciler) reconcileRestoreJob(ctx context.Context,
 			deltaOptFound = true
 		}
 	}
-	if !deltaOptFound {
+	// Reuse existing files unless in-place restores are configured otherwise.
+	// An option provided by the user takes precedence.
+	if restore := cluster.Spec.Backups.PGBackRest.Restore; !deltaOptFound &&
+		(restore == nil || restore.Delta == nil || *restore.Delta) {
 		opts = append(opts, "--delta")
+		deltaOptFound = true
 	}
 
 	// Note on the pgBackRest option `--target-action` in the restore job:
@@ -1088,7 +1634,7 @@ func (r *Reconciler) reconcileRestoreJob(ctx context.Context,
 
 	// NOTE (andrewlecuyer): Forcing users to put each argument separately might prevent the need
 	// to do any escaping or use eval.
-	cmd := pgbackrest.RestoreCommand(pgdata, pgtablespaceVolumes, strings.Join(opts, " "))
+	cmd := pgbackrest.RestoreCommand(pgdata, deltaOptFound, pgtablespaceVolumes, strings.Join(opts, " "))
 
 	// create the volume resources required for the postgres data directory
 	dataVolumeMount := postgres.DataVolumeMount()
@@ -1148,9 +1694,9 @@ func (r *Reconciler) reconcileRestoreJob(ctx context.Context,
 		cluster.Spec.ImagePullPolicy,
 		&restoreJob.Spec.Template)
 
-	addTMPEmptyDir(&restoreJob.Spec.Template)
+	addTMPEmptyDir(&restoreJob.Spec.Template, nil)
 
-	return errors.WithStack(r.apply(ctx, restoreJob))
+	return errors.WithStack(r.applyJob(ctx, restoreJob, dataSource.PodFailurePolicy))
 }
 
 func (r *Reconciler) generateRestoreJobIntent(cluster *v1beta1.PostgresCluster,
@@ -1197,6 +1743,9 @@ func (r *Reconciler) generateRestoreJobIntent(cluster *v1beta1.PostgresCluster,
 				Tolerations:   dataSource.Tolerations,
 			},
 		},
+		BackoffLimit:            dataSource.BackoffLimit,
+		ActiveDeadlineSeconds:   dataSource.ActiveDeadlineSeconds,
+		TTLSecondsAfterFinished: dataSource.TTLSecondsAfterFinished,
 	}
 
 	// Set the image pull secrets, if any exist.
@@ -1223,6 +1772,25 @@ func (r *Reconciler) generateRestoreJobIntent(cluster *v1beta1.PostgresCluster,
 		job.Spec.Template.Spec.PriorityClassName = *dataSource.PriorityClassName
 	}
 
+	// Restore Jobs are placed like backup Jobs unless the data source says otherwise.
+	if jobs := cluster.Spec.Backups.PGBackRest.Jobs; jobs != nil {
+		spec := &job.Spec.Template.Spec
+		if spec.Affinity == nil {
+			spec.Affinity = jobs.Affinity
+		}
+		if spec.Tolerations == nil {
+			spec.Tolerations = jobs.Tolerations
+		}
+		if spec.PriorityClassName == "" && jobs.PriorityClassName != nil {
+			spec.PriorityClassName = *jobs.PriorityClassName
+		}
+		if resources := &spec.Containers[0].Resources; len(resources.Limits) == 0 &&
+			len(resources.Requests) == 0 {
+			*resources = jobs.Resources
+		}
+		spec.NodeSelector = jobs.NodeSelector
+	}
+
 	job.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
 	if err := errors.WithStack(r.setControllerReference(cluster, job)); err != nil {
 		return err
@@ -1279,6 +1847,11 @@ func (r *Reconciler) reconcilePGBackRest(ctx context.Context,
 		meta.RemoveStatusCondition(&postgresCluster.Status.Conditions, ConditionRepoHostReady)
 	}
 
+	if err := r.reconcileRepoHostService(ctx, postgresCluster, repoHostName); err != nil {
+		log.Error(err, "unable to reconcile pgBackRest repo host service")
+		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
+	}
+
 	if err := r.reconcilePGBackRestSecret(ctx, postgresCluster, repoHost, rootCA); err != nil {
 		log.Error(err, "unable to reconcile pgBackRest secret")
 		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
@@ -1302,6 +1875,31 @@ func (r *Reconciler) reconcilePGBackRest(ctx context.Context,
 		return result, nil
 	}
 
+	// record the results of any scheduled pgBackRest verify Jobs in the repo status
+	r.reconcileRepoVerification(postgresCluster, repoResources.verifyJobs)
+
+	// record the replication lag of any repos copied to a sync bucket in the repo status
+	r.reconcileRepoSync(postgresCluster, repoResources.syncJobs)
+
+	// run the hooks around scheduled full backups
+	if retry, err := r.reconcileBackupHooks(ctx, postgresCluster, instances,
+		repoResources.backupJobs); err != nil {
+		log.Error(err, "unable to run backup hooks")
+		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
+	} else if retry > 0 {
+		result = updateReconcileResult(result, reconcile.Result{RequeueAfter: retry})
+	}
+
+	// report when backup Jobs start and finish
+	r.reconcileBackupLifecycle(postgresCluster, repoResources.backupJobs)
+
+	// delete finished backup Jobs beyond the history limit
+	if err := r.reconcileBackupJobHistory(ctx, postgresCluster,
+		repoResources.backupJobs); err != nil {
+		log.Error(err, "unable to delete old backup Jobs")
+		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
+	}
+
 	// gather instance names and reconcile all pgbackrest configuration and secrets
 	instanceNames := []string{}
 	for _, instance := range instances.forCluster {
@@ -1360,10 +1958,18 @@ func (r *Reconciler) reconcilePGBackRest(ctx context.Context,
 		result = updateReconcileResult(result, reconcile.Result{RequeueAfter: 10 * time.Second})
 	}
 
+	// Report when WAL archiving is paused, and arrange for a full backup when it resumes
+	replicaCreateBackupJobs, err := r.reconcileArchiving(ctx, postgresCluster,
+		repoResources.replicaCreateBackupJobs)
+	if err != nil {
+		log.Error(err, "unable to reconcile WAL archiving")
+		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
+	}
+
 	// Reconcile the initial backup that is needed to enable replica creation using pgBackRest.
 	// This is done once stanza creation is successful
 	if err := r.reconcileReplicaCreateBackup(ctx, postgresCluster, instances,
-		repoResources.replicaCreateBackupJobs, sa, configHash, replicaCreateRepo); err != nil {
+		replicaCreateBackupJobs, sa, configHash, replicaCreateRepo); err != nil {
 		log.Error(err, "unable to reconcile replica creation backup")
 		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
 	}
@@ -1376,9 +1982,74 @@ func (r *Reconciler) reconcilePGBackRest(ctx context.Context,
 		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
 	}
 
+	// Record information about the backups in each repo, e.g. when the latest finished
+	requeueAfter, err := r.reconcileRepoInfo(ctx, postgresCluster, instances)
+	if err != nil {
+		log.Error(err, "unable to read pgBackRest repo information")
+		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
+	} else if requeueAfter > 0 {
+		result = updateReconcileResult(result, reconcile.Result{RequeueAfter: requeueAfter})
+	}
+
+	// Report what the expire command would remove when the end-user asks via annotation
+	if err := r.reconcileExpireDryRun(ctx, postgresCluster); err != nil {
+		log.Error(err, "unable to run pgBackRest expire dry run")
+		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
+	}
+
 	return result, nil
 }
 
+// clusterStopped returns true when cluster is shut down and none of its
+// instance Pods remain.
+func clusterStopped(cluster *v1beta1.PostgresCluster) bool {
+	if cluster.Spec.Shutdown == nil || !*cluster.Spec.Shutdown {
+		return false
+	}
+	for _, set := range cluster.Status.InstanceSets {
+		if set.Replicas > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// dataSourceCluster returns the key of the PostgresCluster that cluster is
+// cloned from. It returns false when cluster has no such data source or is
+// its own data source, i.e. an in-place restore.
+func dataSourceCluster(cluster *v1beta1.PostgresCluster) (client.ObjectKey, bool) {
+	if cluster.Spec.DataSource == nil || cluster.Spec.DataSource.PostgresCluster == nil {
+		return client.ObjectKey{}, false
+	}
+
+	key := client.ObjectKey{
+		Namespace: cluster.Spec.DataSource.PostgresCluster.ClusterNamespace,
+		Name:      cluster.Spec.DataSource.PostgresCluster.ClusterName,
+	}
+	if key.Namespace == "" {
+		key.Namespace = cluster.Namespace
+	}
+	if key.Name == "" {
+		key.Name = cluster.Name
+	}
+	return key, key != client.ObjectKeyFromObject(cluster)
+}
+
+// dataSourceAllowed returns true when source allows PostgresClusters in namespace
+// to restore from its backups. Clusters in the same namespace are always allowed.
+func dataSourceAllowed(source *v1beta1.PostgresCluster, namespace string) bool {
+	if source.Namespace == namespace {
+		return true
+	}
+	value := source.GetAnnotations()[naming.AllowDataSourceNamespaces]
+	for _, allowed := range strings.Split(value, ",") {
+		if allowed = strings.TrimSpace(allowed); allowed == "*" || allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
 // +kubebuilder:rbac:groups="",resources="persistentvolumeclaims",verbs={create,patch}
 // +kubebuilder:rbac:groups="batch",resources="jobs",verbs={create,patch,delete}
 
@@ -1487,6 +2158,26 @@ func (r *Reconciler) reconcilePostgresClusterDataSource(ctx context.Context,
 			return errors.WithStack(err)
 		}
 
+		// Backups in another namespace may only be restored when the source
+		// cluster allows it.
+		if !dataSourceAllowed(sourceCluster, cluster.Namespace) {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "DataSourceNotAllowed",
+				"PostgresCluster %q in namespace %q does not allow restores to namespace %q; "+
+					"add it to the %q annotation of that cluster", sourceClusterName,
+				sourceClusterNamespace, cluster.Namespace, naming.AllowDataSourceNamespaces)
+			return nil
+		}
+
+		// Two running clusters must never write to the same stanza. When this
+		// cluster continues the stanza of the source cluster, wait for the source
+		// cluster to stop so that its last WAL is archived before the restore.
+		if pgbackrest.SharesStanza(cluster, sourceCluster) && !clusterStopped(sourceCluster) {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "DataSourceRunning",
+				"PostgresCluster %q stores backups in the same pgBackRest stanza; "+
+					"waiting for it to shut down", sourceClusterName)
+			return nil
+		}
+
 		// Copy repository definitions and credentials from the source cluster.
 		// A copy is the only way to get this information across namespaces.
 		if err := r.copyRestoreConfiguration(ctx, cluster, sourceCluster); err != nil {
@@ -1509,6 +2200,12 @@ func (r *Reconciler) reconcilePostgresClusterDataSource(ctx context.Context,
 		return nil
 	}
 
+	// A dry run checks the restore without reconciling any data volumes.
+	if restoreDryRun(dataSource) {
+		return r.reconcileRestoreDryRun(ctx, cluster, sourceCluster, dataSource,
+			configHash, pgbackrest.StanzaName(sourceCluster))
+	}
+
 	// Define a fake STS to use when calling the reconcile functions below since when
 	// bootstrapping the cluster it will not exist until after the restore is complete.
 	fakeSTS := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{
@@ -1532,7 +2229,7 @@ func (r *Reconciler) reconcilePostgresClusterDataSource(ctx context.Context,
 
 	// reconcile the pgBackRest restore Job to populate the cluster's data directory
 	if err := r.reconcileRestoreJob(ctx, cluster, sourceCluster, pgdata, pgwal, pgtablespaces,
-		dataSource, instanceName, instanceSetName, configHash, pgbackrest.DefaultStanzaName); err != nil {
+		dataSource, instanceName, instanceSetName, configHash, pgbackrest.StanzaName(sourceCluster)); err != nil {
 		return errors.WithStack(err)
 	}
 
@@ -1597,13 +2294,40 @@ func (r *Reconciler) reconcileCloudBasedDataSource(ctx context.Context,
 		return nil
 	}
 
+	// Do not restore with a configuration that overrides what PGO manages. The
+	// condition is reported on cluster rather than the copy in createRestoreConfig.
+	if !r.validatePGBackRestGlobal(cluster, dataSource.Global, "spec.dataSource.pgbackrest.global") {
+		return nil
+	}
 	if err := r.createRestoreConfig(ctx, cluster, configHash); err != nil {
 		return err
 	}
 
-	// TODO(benjaminjb): Is there a way to check that a repo exists outside of spinning
-	// up a pod with pgBackRest and checking?
-
+	// The `reconcileRestoreJob` was originally designed to take a PostgresClusterDataSource
+	// and rather than reconfigure that func's signature, we translate the PGBackRestDataSource
+	tmpDataSource := &v1beta1.PostgresClusterDataSource{
+		RepoName:          dataSource.Repo.Name,
+		Options:           dataSource.Options,
+		DryRun:            dataSource.DryRun,
+		Resources:         dataSource.Resources,
+		Affinity:          dataSource.Affinity,
+		Tolerations:       dataSource.Tolerations,
+		PriorityClassName: dataSource.PriorityClassName,
+
+		BackoffLimit:            dataSource.BackoffLimit,
+		ActiveDeadlineSeconds:   dataSource.ActiveDeadlineSeconds,
+		PodFailurePolicy:        dataSource.PodFailurePolicy,
+		TTLSecondsAfterFinished: dataSource.TTLSecondsAfterFinished,
+	}
+
+	// A dry run checks that the repo has what the restore needs without reconciling any
+	// data volumes. Note that the 'source cluster' is nil as this is not used by this
+	// restore type.
+	if restoreDryRun(tmpDataSource) {
+		return r.reconcileRestoreDryRun(ctx, cluster, nil, tmpDataSource,
+			configHash, dataSource.Stanza)
+	}
+
 	// Define a fake STS to use when calling the reconcile functions below since when
 	// bootstrapping the cluster it will not exist until after the restore is complete.
 	fakeSTS := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{
@@ -1626,17 +2350,6 @@ func (r *Reconciler) reconcileCloudBasedDataSource(ctx context.Context,
 		return errors.WithStack(err)
 	}
 
-	// The `reconcileRestoreJob` was originally designed to take a PostgresClusterDataSource
-	// and rather than reconfigure that func's signature, we translate the PGBackRestDataSource
-	tmpDataSource := &v1beta1.PostgresClusterDataSource{
-		RepoName:          dataSource.Repo.Name,
-		Options:           dataSource.Options,
-		Resources:         dataSource.Resources,
-		Affinity:          dataSource.Affinity,
-		Tolerations:       dataSource.Tolerations,
-		PriorityClassName: dataSource.PriorityClassName,
-	}
-
 	// reconcile the pgBackRest restore Job to populate the cluster's data directory
 	// Note that the 'source cluster' is nil as this is not used by this restore type.
 	if err := r.reconcileRestoreJob(ctx, cluster, nil, pgdata, pgwal, pgtablespaces, tmpDataSource,
@@ -1652,11 +2365,22 @@ func (r *Reconciler) reconcileCloudBasedDataSource(ctx context.Context,
 func (r *Reconciler) createRestoreConfig(ctx context.Context, postgresCluster *v1beta1.PostgresCluster,
 	configHash string) error {
 
+	dataSource := postgresCluster.Spec.DataSource.PGBackRest
+
 	postgresClusterWithMockedBackups := postgresCluster.DeepCopy()
-	postgresClusterWithMockedBackups.Spec.Backups.PGBackRest.Global = postgresCluster.Spec.
-		DataSource.PGBackRest.Global
+	postgresClusterWithMockedBackups.Spec.Backups.PGBackRest.Global = dataSource.Global
 	postgresClusterWithMockedBackups.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
-		postgresCluster.Spec.DataSource.PGBackRest.Repo,
+		dataSource.Repo,
+	}
+
+	// A repo path in the data source takes the place of the default path of the repo.
+	if dataSource.RepoPath != "" {
+		global := make(map[string]string, len(dataSource.Global)+1)
+		for option, value := range dataSource.Global {
+			global[option] = value
+		}
+		global[dataSource.Repo.Name+"-path"] = dataSource.RepoPath
+		postgresClusterWithMockedBackups.Spec.Backups.PGBackRest.Global = global
 	}
 
 	return r.reconcilePGBackRestConfig(ctx, postgresClusterWithMockedBackups,
@@ -1870,6 +2594,30 @@ func (r *Reconciler) copyConfigurationResources(ctx context.Context, cluster,
 	return nil
 }
 
+// validatePGBackRestGlobal reports in an event and the PGBackRestGlobalValid
+// condition of cluster when global, the options of the "global" section at
+// path, set options that PGO manages. It returns false when they do.
+func (r *Reconciler) validatePGBackRestGlobal(
+	cluster *v1beta1.PostgresCluster, global map[string]string, path string,
+) bool {
+	err := pgbackrest.ValidateGlobalOptions(global)
+	if err == nil {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionPGBackRestGlobalValid)
+		return true
+	}
+
+	r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "InvalidPGBackRestGlobal",
+		"Invalid pgBackRest global configuration in %s: %v", path, err)
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionPGBackRestGlobalValid,
+		Status:             metav1.ConditionFalse,
+		Reason:             "ManagedOptions",
+		Message:            fmt.Sprintf("%s: %v", path, err),
+	})
+	return false
+}
+
 // reconcilePGBackRestConfig is responsible for reconciling the pgBackRest ConfigMaps and Secrets.
 func (r *Reconciler) reconcilePGBackRestConfig(ctx context.Context,
 	postgresCluster *v1beta1.PostgresCluster,
@@ -1878,12 +2626,70 @@ func (r *Reconciler) reconcilePGBackRestConfig(ctx context.Context,
 
 	log := logging.FromContext(ctx).WithValues("reconcileResource", "repoConfig")
 
-	backrestConfig := pgbackrest.CreatePGBackRestConfigMapIntent(postgresCluster, repoHostName,
+	// Leave the current configuration in place rather than generate one that
+	// overrides what PGO manages.
+	if !r.validatePGBackRestGlobal(postgresCluster,
+		postgresCluster.Spec.Backups.PGBackRest.Global, "spec.backups.pgbackrest.global") {
+		return nil
+	}
+	generateFrom := postgresCluster
+
+	// Report repositories that authenticate using a web identity other than the
+	// one projected into pods. They will not be able to reach their storage.
+	if err := pgbackrest.ValidateWebIdentity(
+		postgresCluster.Spec.Backups.PGBackRest.Repos); err != nil {
+		r.Recorder.Eventf(postgresCluster, corev1.EventTypeWarning, "InvalidWebIdentity",
+			"Invalid pgBackRest repository credentials: %v", err)
+		meta.SetStatusCondition(&postgresCluster.Status.Conditions, metav1.Condition{
+			ObservedGeneration: postgresCluster.GetGeneration(),
+			Type:               ConditionPGBackRestWebIdentityValid,
+			Status:             metav1.ConditionFalse,
+			Reason:             "InvalidWebIdentity",
+			Message:            err.Error(),
+		})
+	} else {
+		meta.RemoveStatusCondition(&postgresCluster.Status.Conditions, ConditionPGBackRestWebIdentityValid)
+	}
+
+	// Keep repositories that would be at the same path as an earlier repository at
+	// their default path. Otherwise, they would expire each other's backups.
+	if conflicts, err := pgbackrest.ValidateRepoPaths(
+		postgresCluster.Spec.Backups.PGBackRest.Repos,
+		postgresCluster.Spec.Backups.PGBackRest.Global); err != nil {
+		r.Recorder.Eventf(postgresCluster, corev1.EventTypeWarning, "InvalidRepoPath",
+			"Invalid pgBackRest repository path: %v", err)
+		meta.SetStatusCondition(&postgresCluster.Status.Conditions, metav1.Condition{
+			ObservedGeneration: postgresCluster.GetGeneration(),
+			Type:               ConditionPGBackRestRepoPathsValid,
+			Status:             metav1.ConditionFalse,
+			Reason:             "InvalidRepoPath",
+			Message:            err.Error(),
+		})
+
+		if generateFrom == postgresCluster {
+			generateFrom = postgresCluster.DeepCopy()
+		}
+		for i, repo := range generateFrom.Spec.Backups.PGBackRest.Repos {
+			for _, name := range conflicts {
+				if repo.Name == name {
+					generateFrom.Spec.Backups.PGBackRest.Repos[i].Path = ""
+				}
+			}
+		}
+	} else {
+		meta.RemoveStatusCondition(&postgresCluster.Status.Conditions, ConditionPGBackRestRepoPathsValid)
+	}
+
+	backrestConfig := pgbackrest.CreatePGBackRestConfigMapIntent(ctx, generateFrom, repoHostName,
 		configHash, serviceName, serviceNamespace, instanceNames)
 	if err := controllerutil.SetControllerReference(postgresCluster, backrestConfig,
 		r.Client.Scheme()); err != nil {
 		return err
 	}
+	if err := r.reconcilePGBackRestConfigInclude(ctx, postgresCluster,
+		backrestConfig); err != nil {
+		return err
+	}
 	if err := r.apply(ctx, backrestConfig); err != nil {
 		return errors.WithStack(err)
 	}
@@ -1897,6 +2703,57 @@ func (r *Reconciler) reconcilePGBackRestConfig(ctx context.Context,
 	return nil
 }
 
+// +kubebuilder:rbac:groups="",resources="configmaps",verbs={get}
+
+// reconcilePGBackRestConfigInclude copies the files of spec.backups.pgbackrest.configInclude
+// into generated, the pgBackRest ConfigMap of cluster, when they are valid. Otherwise, it
+// leaves them out and reports why in an event and the
+// [ConditionPGBackRestConfigIncludeValid] condition.
+func (r *Reconciler) reconcilePGBackRestConfigInclude(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, generated *corev1.ConfigMap) error {
+
+	reference := cluster.Spec.Backups.PGBackRest.ConfigInclude
+	if reference == nil {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions,
+			ConditionPGBackRestConfigIncludeValid)
+		return nil
+	}
+
+	// Pods mount the file whenever the spec has a ConfigMap to include, so it is
+	// always populated, even when there is nothing valid to put in it.
+	generated.Data[pgbackrest.CMIncludeKey] = ""
+
+	include := &corev1.ConfigMap{}
+	err := r.Client.Get(ctx,
+		client.ObjectKey{Namespace: cluster.Namespace, Name: reference.Name}, include)
+	if client.IgnoreNotFound(err) != nil {
+		return errors.WithStack(err)
+	}
+
+	var text string
+	if err != nil {
+		err = errors.Errorf("ConfigMap %q does not exist", reference.Name)
+	} else {
+		text, err = pgbackrest.ConfigInclude(generated.Data, include.Data)
+	}
+	if err != nil {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "InvalidPGBackRestConfigInclude",
+			"Invalid pgBackRest configuration include: %v", err)
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			ObservedGeneration: cluster.GetGeneration(),
+			Type:               ConditionPGBackRestConfigIncludeValid,
+			Status:             metav1.ConditionFalse,
+			Reason:             "InvalidConfigInclude",
+			Message:            err.Error(),
+		})
+		return nil
+	}
+
+	generated.Data[pgbackrest.CMIncludeKey] = text
+	meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionPGBackRestConfigIncludeValid)
+	return nil
+}
+
 // +kubebuilder:rbac:groups="",resources="secrets",verbs={get}
 // +kubebuilder:rbac:groups="",resources="secrets",verbs={create,delete,patch}
 
@@ -2062,8 +2919,46 @@ func (r *Reconciler) reconcileDedicatedRepoHost(ctx context.Context,
 		return nil, err
 	}
 
+	previous := postgresCluster.Status.PGBackRest.RepoHost
 	postgresCluster.Status.PGBackRest.RepoHost = getRepoHostStatus(repoHost)
 
+	if spec := postgresCluster.Spec.Backups.PGBackRest.RepoHost; spec != nil &&
+		spec.Replicas != nil && *spec.Replicas > pgbackrest.RepoHostReplicas(postgresCluster) {
+		r.Recorder.Eventf(postgresCluster, corev1.EventTypeWarning, EventInvalidRepoHostReplicas,
+			"pgBackRest repository host runs one pod rather than %d: "+
+				"every repository volume must be ReadWriteMany", *spec.Replicas)
+	}
+
+	// When there is more than one pod, choose the one that is active and keep
+	// choosing it while it is ready.
+	if pgbackrest.RepoHostReplicas(postgresCluster) > 1 {
+		pods := &corev1.PodList{}
+		if err := errors.WithStack(r.Client.List(ctx, pods,
+			client.InNamespace(postgresCluster.GetNamespace()),
+			client.MatchingLabelsSelector{
+				Selector: naming.PGBackRestDedicatedSelector(postgresCluster.GetName()),
+			},
+		)); err != nil {
+			log.Error(err, "listing repository host pods")
+			return nil, err
+		}
+
+		var current string
+		if previous != nil {
+			current = previous.ActivePod
+		}
+		active := activeRepoHostPod(current, pods.Items)
+		if active == "" {
+			active = repoHostName + "-0"
+		}
+		if current != "" && active != current {
+			r.Recorder.Eventf(postgresCluster, corev1.EventTypeWarning, EventRepoHostFailover,
+				"pgBackRest repository host pod %s is not ready; %s is now active",
+				current, active)
+		}
+		postgresCluster.Status.PGBackRest.RepoHost.ActivePod = active
+	}
+
 	if isCreate {
 		r.Recorder.Eventf(postgresCluster, corev1.EventTypeNormal, EventRepoHostCreated,
 			"created pgBackRest repository host %s/%s", repoHost.TypeMeta.Kind, repoHostName)
@@ -2072,6 +2967,111 @@ func (r *Reconciler) reconcileDedicatedRepoHost(ctx context.Context,
 	return repoHost, nil
 }
 
+// activeRepoHostPod returns the name of the repository host pod in pods that
+// should be active. The current pod stays active while it is ready. Otherwise,
+// the first ready pod by name becomes active. When no pod is ready, the current
+// pod stays active.
+func activeRepoHostPod(current string, pods []corev1.Pod) string {
+	ready := func(pod *corev1.Pod) bool {
+		if pod.DeletionTimestamp != nil {
+			return false
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady {
+				return condition.Status == corev1.ConditionTrue
+			}
+		}
+		return false
+	}
+
+	var first string
+	for i := range pods {
+		if !ready(&pods[i]) {
+			continue
+		}
+		if pods[i].Name == current {
+			return current
+		}
+		if first == "" || pods[i].Name < first {
+			first = pods[i].Name
+		}
+	}
+	if first == "" {
+		return current
+	}
+	return first
+}
+
+// generateRepoHostService returns the Service that resolves to the active pod
+// of the pgBackRest repository host. It is only specified when the repository
+// host has more than one pod.
+func (r *Reconciler) generateRepoHostService(
+	cluster *v1beta1.PostgresCluster, repoHostName string,
+) (*corev1.Service, bool, error) {
+	service := &corev1.Service{ObjectMeta: naming.PGBackRestRepoHostService(cluster)}
+	service.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
+
+	if !pgbackrest.DedicatedRepoHostEnabled(cluster) ||
+		pgbackrest.RepoHostReplicas(cluster) < 2 {
+		return service, false, nil
+	}
+
+	service.Annotations = naming.Merge(
+		cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetAnnotationsOrNil())
+	service.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetLabelsOrNil(),
+		naming.PGBackRestDedicatedLabels(cluster.Name))
+
+	active := repoHostName + "-0"
+	if status := cluster.Status.PGBackRest; status != nil &&
+		status.RepoHost != nil && status.RepoHost.ActivePod != "" {
+		active = status.RepoHost.ActivePod
+	}
+
+	// Allocate an IP address and let Kubernetes manage the Endpoints by
+	// selecting the active pod.
+	service.Spec.Type = corev1.ServiceTypeClusterIP
+	service.Spec.Selector = naming.PGBackRestActiveRepoHostLabels(cluster.Name, active)
+	service.Spec.Ports = []corev1.ServicePort{{
+		Port:       pgbackrest.IANAPortNumber,
+		Protocol:   corev1.ProtocolTCP,
+		TargetPort: intstr.FromInt(pgbackrest.IANAPortNumber),
+	}}
+
+	err := errors.WithStack(r.setControllerReference(cluster, service))
+
+	return service, true, err
+}
+
+// +kubebuilder:rbac:groups="",resources="services",verbs={get}
+// +kubebuilder:rbac:groups="",resources="services",verbs={create,delete,patch}
+
+// reconcileRepoHostService writes the Service that resolves to the active pod
+// of the pgBackRest repository host.
+func (r *Reconciler) reconcileRepoHostService(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, repoHostName string,
+) error {
+	service, specified, err := r.generateRepoHostService(cluster, repoHostName)
+
+	if err == nil && !specified {
+		// The repository host has one pod or none; delete the Service if it
+		// exists. Check the client cache first using Get.
+		key := client.ObjectKeyFromObject(service)
+		err := errors.WithStack(r.Client.Get(ctx, key, service))
+		if err == nil {
+			err = errors.WithStack(r.deleteControlled(ctx, cluster, service))
+		}
+		return client.IgnoreNotFound(err)
+	}
+
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, service))
+	}
+	return err
+}
+
 // +kubebuilder:rbac:groups="batch",resources="jobs",verbs={create,patch,delete}
 
 // reconcileManualBackup is responsible for reconciling pgBackRest backups that are initiated
@@ -2118,6 +3118,7 @@ func (r *Reconciler) reconcileManualBackup(ctx context.Context,
 			manualStatus.Succeeded = currentBackupJob.Status.Succeeded
 			manualStatus.Failed = currentBackupJob.Status.Failed
 			manualStatus.Active = currentBackupJob.Status.Active
+			manualStatus.Type = currentBackupJob.GetLabels()[naming.LabelPGBackRestBackupType]
 			if completed || failed {
 				manualStatus.Finished = true
 			}
@@ -2248,6 +3249,29 @@ func (r *Reconciler) reconcileManualBackup(ctx context.Context,
 		}
 	}
 
+	// The type of backup can be set using either the "manual.type" field or the "--type"
+	// option, but not both.  Record a warning event and return when both are found, the same
+	// as for "--repo" above.
+	backupType := postgresCluster.Spec.Backups.PGBackRest.Manual.Type
+	if optionType, found := pgbackrest.OptionValue(backupOpts, "--type"); found {
+		if backupType != "" {
+			r.Recorder.Event(postgresCluster, corev1.EventTypeWarning, "InvalidManualBackup",
+				"Option '--type' is not allowed with the 'type' field: please use one or the other.")
+			return nil
+		}
+		backupType = optionType
+	} else if backupType != "" {
+		backupOpts = append([]string{"--type=" + backupType}, backupOpts...)
+	}
+
+	// Only a type that pgBackRest accepts is recorded in the label and status of the backup.
+	// pgBackRest reports any other type when the Job runs.
+	switch backupType {
+	case full, differential, incremental:
+	default:
+		backupType = ""
+	}
+
 	// create the backup Job
 	backupJob := &batchv1.Job{}
 	backupJob.ObjectMeta = naming.PGBackRestBackupJob(postgresCluster)
@@ -2260,6 +3284,9 @@ func (r *Reconciler) reconcileManualBackup(ctx context.Context,
 		postgresCluster.Spec.Backups.PGBackRest.Metadata.GetLabelsOrNil(),
 		naming.PGBackRestBackupJobLabels(postgresCluster.GetName(), repoName,
 			naming.BackupManual))
+	if backupType != "" {
+		labels[naming.LabelPGBackRestBackupType] = backupType
+	}
 	annotations = naming.Merge(postgresCluster.Spec.Metadata.GetAnnotationsOrNil(),
 		postgresCluster.Spec.Backups.PGBackRest.Metadata.GetAnnotationsOrNil(),
 		map[string]string{
@@ -2274,6 +3301,7 @@ func (r *Reconciler) reconcileManualBackup(ctx context.Context,
 		return errors.WithStack(err)
 	}
 	backupJob.Spec = *spec
+	manualStatus.Type = backupType
 
 	// set gvk and ownership refs
 	backupJob.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
@@ -2283,7 +3311,7 @@ func (r *Reconciler) reconcileManualBackup(ctx context.Context,
 	}
 
 	// server-side apply the backup Job intent
-	if err := r.apply(ctx, backupJob); err != nil {
+	if err := r.applyJob(ctx, backupJob, backupJobPodFailurePolicy(postgresCluster)); err != nil {
 		return errors.WithStack(err)
 	}
 
@@ -2292,6 +3320,93 @@ func (r *Reconciler) reconcileManualBackup(ctx context.Context,
 
 // +kubebuilder:rbac:groups="batch",resources="jobs",verbs={create,patch,delete}
 
+// reconcileArchiving reports in a condition when WAL archiving is paused. When archiving
+// resumes, it deletes the replica create backup Jobs so that a new full backup is taken;
+// backups taken earlier cannot restore to any point while archiving was paused. It
+// returns the replica create backup Jobs that belong to the current state of archiving.
+func (r *Reconciler) reconcileArchiving(ctx context.Context,
+	postgresCluster *v1beta1.PostgresCluster, replicaCreateBackupJobs []*batchv1.Job,
+) ([]*batchv1.Job, error) {
+	pause := postgresCluster.Spec.Backups.PGBackRest.PauseArchiving
+	previous := meta.FindStatusCondition(postgresCluster.Status.Conditions, ConditionArchiving)
+
+	condition := metav1.Condition{
+		ObservedGeneration: postgresCluster.GetGeneration(),
+		Type:               ConditionArchiving,
+		Status:             metav1.ConditionFalse,
+	}
+
+	switch {
+	case pause != nil && *pause:
+		condition.Reason = "ArchivingPaused"
+		condition.Message = "WAL archiving is paused; backups cannot restore to any point " +
+			"after it was paused until it resumes and a full backup completes"
+		if previous == nil || previous.Reason != condition.Reason {
+			r.Recorder.Event(postgresCluster, corev1.EventTypeWarning, condition.Reason,
+				condition.Message)
+		}
+
+	case previous == nil:
+		// archiving has never been paused
+		return replicaCreateBackupJobs, nil
+
+	case previous.Reason == "ArchivingPaused":
+		for _, job := range replicaCreateBackupJobs {
+			if err := client.IgnoreNotFound(r.Client.Delete(ctx, job,
+				client.PropagationPolicy(metav1.DeletePropagationBackground))); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		replicaCreateBackupJobs = nil
+		if postgresCluster.Status.PGBackRest != nil {
+			for i := range postgresCluster.Status.PGBackRest.Repos {
+				postgresCluster.Status.PGBackRest.Repos[i].ReplicaCreateBackupComplete = false
+			}
+		}
+
+		condition.Reason = "FullBackupRequired"
+		condition.Message = "WAL archiving resumed; waiting for a full backup to complete"
+		r.Recorder.Event(postgresCluster, corev1.EventTypeNormal, "ArchivingResumed",
+			condition.Message)
+
+		// Start the transition time over so that Jobs from before are recognized below.
+		meta.RemoveStatusCondition(&postgresCluster.Status.Conditions, ConditionArchiving)
+
+	case previous.Reason == "FullBackupRequired":
+		// Jobs that were created before archiving resumed may still be in the cache.
+		var current []*batchv1.Job
+		for _, job := range replicaCreateBackupJobs {
+			if !job.CreationTimestamp.Before(&previous.LastTransitionTime) {
+				current = append(current, job)
+			}
+		}
+		replicaCreateBackupJobs = current
+
+		var complete bool
+		if postgresCluster.Status.PGBackRest != nil &&
+			len(postgresCluster.Spec.Backups.PGBackRest.Repos) > 0 {
+			for _, repo := range postgresCluster.Status.PGBackRest.Repos {
+				if repo.Name == postgresCluster.Spec.Backups.PGBackRest.Repos[0].Name {
+					complete = repo.ReplicaCreateBackupComplete
+				}
+			}
+		}
+		if !complete {
+			return replicaCreateBackupJobs, nil
+		}
+
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "FullBackupComplete"
+		condition.Message = "WAL archiving is enabled and a full backup completed since it resumed"
+
+	default:
+		return replicaCreateBackupJobs, nil
+	}
+
+	meta.SetStatusCondition(&postgresCluster.Status.Conditions, condition)
+	return replicaCreateBackupJobs, nil
+}
+
 // reconcileReplicaCreateBackup is responsible for reconciling a full pgBackRest backup for the
 // cluster as required to create replicas
 func (r *Reconciler) reconcileReplicaCreateBackup(ctx context.Context,
@@ -2308,6 +3423,26 @@ func (r *Reconciler) reconcileReplicaCreateBackup(ctx context.Context,
 		}
 	}
 
+	// Backups taken before a major upgrade cannot restore the upgraded cluster. The
+	// PGUpgrade controller records the new version in status, resets the status
+	// of the repos so that the stanza is upgraded, and marks this condition false
+	// so that a new backup is taken. The cluster is no longer "upgraded" once
+	// that backup completes and the condition is true.
+	var upgraded bool
+	if backup := meta.FindStatusCondition(postgresCluster.Status.Conditions,
+		ConditionPostUpgradeBackup); backup != nil {
+		upgraded = backup.Status != metav1.ConditionTrue
+	}
+
+	// Likewise, backups taken before WAL archiving resumed cannot restore to any point
+	// while it was paused. See [Reconciler.reconcileArchiving].
+	var paused, resumed bool
+	if archiving := meta.FindStatusCondition(postgresCluster.Status.Conditions,
+		ConditionArchiving); archiving != nil {
+		paused = archiving.Reason == "ArchivingPaused"
+		resumed = archiving.Reason == "FullBackupRequired"
+	}
+
 	// ensure condition is set before returning as needed by subsequent reconcile functions
 	defer func() {
 		replicaCreate := metav1.Condition{
@@ -2326,18 +3461,39 @@ func (r *Reconciler) reconcileReplicaCreateBackup(ctx context.Context,
 			replicaCreate.Status = metav1.ConditionFalse
 			replicaCreate.Reason = "RepoBackupNotComplete"
 			replicaCreate.Message = "pgBackRest replica creation is not currently " +
-				"possible"
+				"possible; replicas are created using pg_basebackup"
 		}
 		meta.SetStatusCondition(&postgresCluster.Status.Conditions, replicaCreate)
+
+		if upgraded {
+			backup := metav1.Condition{
+				ObservedGeneration: postgresCluster.GetGeneration(),
+				Type:               ConditionPostUpgradeBackup,
+				Status:             metav1.ConditionFalse,
+				Reason:             "RepoBackupNotComplete",
+				Message: fmt.Sprintf("No backup of PostgreSQL %d exists yet; "+
+					"backups taken before the upgrade cannot restore it",
+					postgresCluster.Status.PostgresVersion),
+			}
+			if replicaCreateRepoStatus != nil && replicaCreateRepoStatus.ReplicaCreateBackupComplete {
+				backup.Status = metav1.ConditionTrue
+				backup.Reason = "RepoBackupComplete"
+				backup.Message = fmt.Sprintf("A backup of PostgreSQL %d exists in %s",
+					postgresCluster.Status.PostgresVersion, replicaCreateRepo.Name)
+			}
+			meta.SetStatusCondition(&postgresCluster.Status.Conditions, backup)
+		}
 	}()
 
 	// pgBackRest connects to a PostgreSQL instance that is not in recovery to
 	// initiate a backup. Similar to "writable" but not exactly.
 	clusterWritable := false
+	var writableInstanceName string
 	for _, instance := range instances.forCluster {
 		writable, known := instance.IsWritable()
 		if writable && known {
 			clusterWritable = true
+			writableInstanceName = instance.Name + "-0"
 			break
 		}
 	}
@@ -2353,6 +3509,13 @@ func (r *Reconciler) reconcileReplicaCreateBackup(ctx context.Context,
 		return nil
 	}
 
+	// return early when replicas are always created using pg_basebackup, unless there
+	// has been a major upgrade or a pause in archiving that still needs a backup
+	strategy := postgresCluster.Spec.Backups.PGBackRest.ReplicaCreate
+	if strategy == v1beta1.PGBackRestReplicaCreateBasebackup && !upgraded && !resumed {
+		return nil
+	}
+
 	// determine if the replica create repo is ready using the "PGBackRestReplicaRepoReady" condition
 	var replicaRepoReady bool
 	condition := meta.FindStatusCondition(postgresCluster.Status.Conditions, ConditionReplicaRepoReady)
@@ -2418,11 +3581,34 @@ func (r *Reconciler) reconcileReplicaCreateBackup(ctx context.Context,
 
 	dedicatedEnabled := pgbackrest.DedicatedRepoHostEnabled(postgresCluster)
 	// return if no job has been created and the replica repo or the dedicated repo host  is not
-	// ready
-	if job == nil && ((dedicatedEnabled && !dedicatedRepoReady) || !replicaRepoReady) {
+	// ready, or when WAL archiving is paused and a backup cannot succeed
+	if job == nil && ((dedicatedEnabled && !dedicatedRepoReady) || !replicaRepoReady || paused) {
 		return nil
 	}
 
+	// when so configured, create replicas from a backup that is already in the repository
+	// rather than taking a new one, which can take a very long time for large clusters.
+	// Replicas are not created from backups at all with pg_basebackup, so any backup taken
+	// since the upgrade is enough.
+	if job == nil && !resumed && (strategy == v1beta1.PGBackRestReplicaCreateLatestBackup ||
+		strategy == v1beta1.PGBackRestReplicaCreateBasebackup) {
+		exec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+			command ...string) error {
+			return r.PodExec(postgresCluster.GetNamespace(), writableInstanceName,
+				naming.ContainerDatabase, stdin, stdout, stderr, command...)
+		}
+
+		exists, err := pgbackrest.Executor(exec).CurrentBackupExists(ctx,
+			pgbackrest.StanzaName(postgresCluster), replicaCreateRepo.Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			replicaCreateRepoStatus.ReplicaCreateBackupComplete = true
+			return nil
+		}
+	}
+
 	// create the backup Job, and populate ObjectMeta based on whether or not a Job already exists
 	backupJob := &batchv1.Job{}
 	backupJob.ObjectMeta = naming.PGBackRestBackupJob(postgresCluster)
@@ -2444,8 +3630,15 @@ func (r *Reconciler) reconcileReplicaCreateBackup(ctx context.Context,
 	backupJob.ObjectMeta.Labels = labels
 	backupJob.ObjectMeta.Annotations = annotations
 
+	// pgBackRest takes a full backup when there is no prior backup of the current
+	// database, but ask for one explicitly after an upgrade or a pause in archiving.
+	var opts []string
+	if upgraded || resumed {
+		opts = append(opts, "--type=full")
+	}
+
 	spec, err := generateBackupJobSpecIntent(postgresCluster, replicaCreateRepo,
-		serviceAccount.GetName(), labels, annotations)
+		serviceAccount.GetName(), labels, annotations, opts...)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -2458,7 +3651,7 @@ func (r *Reconciler) reconcileReplicaCreateBackup(ctx context.Context,
 		return errors.WithStack(err)
 	}
 
-	if err := r.apply(ctx, backupJob); err != nil {
+	if err := r.applyJob(ctx, backupJob, backupJobPodFailurePolicy(postgresCluster)); err != nil {
 		return errors.WithStack(err)
 	}
 
@@ -2600,9 +3793,22 @@ func (r *Reconciler) reconcileStanzaCreate(ctx context.Context,
 			naming.ContainerDatabase, stdin, stdout, stderr, command...)
 	}
 
+	// Use the stanzas that already exist in repos that allow it. When that covers
+	// every repo, there is nothing to create.
+	adopted, err := r.adoptExistingStanzas(ctx, postgresCluster, exec)
+	if err != nil {
+		r.Recorder.Event(postgresCluster, corev1.EventTypeWarning, EventUnableToCreateStanzas,
+			err.Error())
+
+		return false, err
+	}
+	if adopted {
+		return false, nil
+	}
+
 	// Always attempt to create pgBackRest stanza first
-	configHashMismatch, err := pgbackrest.Executor(exec).StanzaCreateOrUpgrade(ctx, configHash,
-		false)
+	configHashMismatch, err := pgbackrest.Executor(exec).StanzaCreateOrUpgrade(ctx,
+		pgbackrest.StanzaName(postgresCluster), configHash, false)
 	if err != nil {
 		// record and log any errors resulting from running the stanza-create command
 		r.Recorder.Event(postgresCluster, corev1.EventTypeWarning, EventUnableToCreateStanzas,
@@ -2618,9 +3824,17 @@ func (r *Reconciler) reconcileStanzaCreate(ctx context.Context,
 		return true, nil
 	}
 
-	// record an event indicating successful stanza creation
-	r.Recorder.Event(postgresCluster, corev1.EventTypeNormal, EventStanzasCreated,
-		"pgBackRest stanza creation completed successfully")
+	// record an event indicating successful stanza creation. After a major upgrade, the
+	// stanzas of the previous version were upgraded instead; see [ConditionPostUpgradeBackup].
+	if backup := meta.FindStatusCondition(postgresCluster.Status.Conditions,
+		ConditionPostUpgradeBackup); backup != nil && backup.Status != metav1.ConditionTrue {
+		r.Recorder.Eventf(postgresCluster, corev1.EventTypeNormal, EventStanzasUpgraded,
+			"pgBackRest stanza upgrade to PostgreSQL %d completed successfully",
+			postgresCluster.Status.PostgresVersion)
+	} else {
+		r.Recorder.Event(postgresCluster, corev1.EventTypeNormal, EventStanzasCreated,
+			"pgBackRest stanza creation completed successfully")
+	}
 
 	// if no errors then stanza(s) created successfully
 	for i := range postgresCluster.Status.PGBackRest.Repos {
@@ -2630,6 +3844,57 @@ func (r *Reconciler) reconcileStanzaCreate(ctx context.Context,
 	return false, nil
 }
 
+// adoptExistingStanzas marks the stanza of each repo with adoptExisting as created when it
+// already exists in the repo and belongs to the database of cluster. It returns true when
+// the stanzas of all repos are then created. pgBackRest "stanza-create" still runs on every
+// repo when any of them needs a stanza.
+func (r *Reconciler) adoptExistingStanzas(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, exec pgbackrest.Executor) (bool, error) {
+
+	stanza := pgbackrest.StanzaName(cluster)
+
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		if repo.AdoptExisting == nil || !*repo.AdoptExisting {
+			continue
+		}
+
+		var repoStatus *v1beta1.RepoStatus
+		for i := range cluster.Status.PGBackRest.Repos {
+			if cluster.Status.PGBackRest.Repos[i].Name == repo.Name {
+				repoStatus = &cluster.Status.PGBackRest.Repos[i]
+			}
+		}
+		if repoStatus == nil || repoStatus.StanzaCreated {
+			continue
+		}
+
+		systemID, err := exec.StanzaSystemIdentifier(ctx, stanza, repo.Name)
+		if err != nil {
+			return false, err
+		}
+
+		// pgBackRest cannot archive to or back up into a stanza of another database.
+		switch {
+		case systemID == "", cluster.Status.Patroni.SystemIdentifier == "":
+		case systemID == cluster.Status.Patroni.SystemIdentifier:
+			repoStatus.StanzaCreated = true
+			r.Recorder.Eventf(cluster, corev1.EventTypeNormal, EventStanzaAdopted,
+				"pgBackRest stanza %q already exists in %q", stanza, repo.Name)
+		default:
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventStanzaNotAdopted,
+				"pgBackRest stanza %q in %q belongs to another database (system identifier %s)",
+				stanza, repo.Name, systemID)
+		}
+	}
+
+	for _, repoStatus := range cluster.Status.PGBackRest.Repos {
+		if !repoStatus.StanzaCreated {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // getPGBackRestExecSelector returns a selector and container name that allows the proper
 // Pod (along with a specific container within it) to be found within the Kubernetes
 // cluster as needed to exec into the container and run a pgBackRest command.
@@ -2643,6 +3908,18 @@ func getPGBackRestExecSelector(postgresCluster *v1beta1.PostgresCluster,
 	if repo.Volume != nil {
 		podSelector = naming.PGBackRestDedicatedSelector(postgresCluster.GetName())
 		containerName = naming.PGBackRestRepoContainerName
+
+		// Run commands in the active pod when the repository host has more than one.
+		if status := postgresCluster.Status.PGBackRest; status != nil &&
+			status.RepoHost != nil && status.RepoHost.ActivePod != "" &&
+			pgbackrest.RepoHostReplicas(postgresCluster) > 1 {
+			podSelector = naming.PGBackRestActiveRepoHostLabels(
+				postgresCluster.GetName(), status.RepoHost.ActivePod).AsSelector()
+		}
+	} else if repo.SharedHost != nil {
+		// pgBackRest takes backups on the host of the repository.
+		podSelector = naming.SharedRepoHostSelector(repo.SharedHost.Name)
+		containerName = naming.PGBackRestRepoContainerName
 	} else {
 		podSelector, err = naming.AsSelector(naming.ClusterPrimary(postgresCluster.GetName()))
 		containerName = naming.ContainerDatabase
@@ -2809,6 +4086,27 @@ func (r *Reconciler) reconcileScheduledBackups(
 				}
 			}
 		}
+		if repo.VerifySchedule != nil {
+			if err := r.reconcilePGBackRestCronJob(ctx, cluster, repo,
+				verify, repo.VerifySchedule, sa, cronjobs); err != nil {
+				log.Error(err, "unable to reconcile verify for "+repo.Name)
+				requeue = true
+			}
+		}
+		if schedule := repoExpireSchedule(repo); schedule != nil {
+			if err := r.reconcilePGBackRestCronJob(ctx, cluster, repo,
+				expire, schedule, sa, cronjobs); err != nil {
+				log.Error(err, "unable to reconcile expire for "+repo.Name)
+				requeue = true
+			}
+		}
+		if repo.Sync != nil {
+			if err := r.reconcilePGBackRestCronJob(ctx, cluster, repo,
+				repoSync, &repo.Sync.Schedule, sa, cronjobs); err != nil {
+				log.Error(err, "unable to reconcile sync for "+repo.Name)
+				requeue = true
+			}
+		}
 	}
 	return requeue
 }
@@ -2816,7 +4114,7 @@ func (r *Reconciler) reconcileScheduledBackups(
 // +kubebuilder:rbac:groups="batch",resources="cronjobs",verbs={create,patch}
 
 // reconcilePGBackRestCronJob creates the CronJob for the given repo, pgBackRest
-// backup type and schedule
+// backup type (or verify, expire or sync) and schedule
 func (r *Reconciler) reconcilePGBackRestCronJob(
 	ctx context.Context, cluster *v1beta1.PostgresCluster, repo v1beta1.PGBackRestRepo,
 	backupType string, schedule *string, serviceAccount *corev1.ServiceAccount,
@@ -2893,11 +4191,51 @@ func (r *Reconciler) reconcilePGBackRestCronJob(
 		return nil
 	}
 
-	// set backup type (i.e. "full", "diff", "incr")
-	backupOpts := []string{"--type=" + backupType}
+	var jobSpec *batchv1.JobSpec
+	var err error
+	if backupType == repoSync {
+		// A repo on a volume is copied from its PVC, which may have been created
+		// by someone other than PGO.
+		claimName := naming.PGBackRestRepoVolume(cluster, repo.Name).Name
+		if repo.Volume != nil {
+			pvcs := &corev1.PersistentVolumeClaimList{}
+			if err := errors.WithStack(r.Client.List(ctx, pvcs,
+				client.InNamespace(cluster.GetNamespace()),
+				client.MatchingLabels(naming.PGBackRestRepoVolumeLabels(cluster.Name, repo.Name)),
+			)); err != nil {
+				return err
+			}
+			if len(pvcs.Items) > 0 {
+				claimName = pvcs.Items[0].Name
+			}
+		}
 
-	jobSpec, err := generateBackupJobSpecIntent(cluster, repo,
-		serviceAccount.GetName(), labels, annotations, backupOpts...)
+		jobSpec, err = generateRepoSyncJobSpecIntent(cluster, repo, claimName,
+			serviceAccount.GetName(), labels, annotations)
+
+		// Requeuing cannot fix a repo that cannot be copied.
+		if err != nil {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventInvalidRepoSync,
+				"Unable to copy %q to its sync bucket: %v", repo.Name, err)
+			return nil
+		}
+	} else if backupType == verify || backupType == expire {
+		jobSpec, err = generatePGBackRestJobSpecIntent(cluster, repo, backupType,
+			serviceAccount.GetName(), labels, annotations)
+	} else {
+		// set backup type (i.e. "full", "diff", "incr")
+		backupOpts := []string{"--type=" + backupType}
+
+		jobSpec, err = generateBackupJobSpecIntent(cluster, repo,
+			serviceAccount.GetName(), labels, annotations, backupOpts...)
+
+		// Requeuing cannot fix backup options that are not valid.
+		if err != nil {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "InvalidBackupOptions",
+				"Unable to schedule %s backups to %q: %v", backupType, repo.Name, err)
+			return nil
+		}
+	}
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -2908,6 +4246,17 @@ func (r *Reconciler) reconcilePGBackRestCronJob(
 	suspend := (cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown) ||
 		(cluster.Spec.Standby != nil && cluster.Spec.Standby.Enabled)
 
+	// Backups can also be suspended for maintenance, in every repository or in
+	// just this one, while keeping their schedules.
+	if backupType == full || backupType == differential || backupType == incremental {
+		if s := cluster.Spec.Backups.PGBackRest.SuspendSchedules; s != nil && *s {
+			suspend = true
+		}
+		if s := repo.BackupSchedules; s != nil && s.Suspend != nil && *s.Suspend {
+			suspend = true
+		}
+	}
+
 	pgBackRestCronJob := &batchv1.CronJob{
 		ObjectMeta: objectmeta,
 		Spec: batchv1.CronJobSpec{
@@ -2924,6 +4273,13 @@ func (r *Reconciler) reconcilePGBackRestCronJob(
 		},
 	}
 
+	// Keep no more Jobs than the cluster does. The CronJob controller applies these to each
+	// schedule while reconcileBackupJobHistory applies them to all the Jobs of the cluster.
+	if limit := cluster.Spec.Backups.PGBackRest.JobHistoryLimit; limit != nil {
+		pgBackRestCronJob.Spec.SuccessfulJobsHistoryLimit = limit.Successful
+		pgBackRestCronJob.Spec.FailedJobsHistoryLimit = limit.Failed
+	}
+
 	// Set the image pull secrets, if any exist.
 	// This is set here rather than using the service account due to the lack
 	// of propagation to existing pods when the CRD is updated:
@@ -2931,12 +4287,18 @@ func (r *Reconciler) reconcilePGBackRestCronJob(
 	pgBackRestCronJob.Spec.JobTemplate.Spec.Template.Spec.ImagePullSecrets =
 		cluster.Spec.ImagePullSecrets
 
+	// Full backups wait for their hooks, if any. These Jobs are created suspended
+	// and resumed by reconcileBackupHooks.
+	if backupType == full && fullBackupHooks(cluster) != nil {
+		pgBackRestCronJob.Spec.JobTemplate.Spec.Suspend = initialize.Bool(true)
+	}
+
 	// set metadata
 	pgBackRestCronJob.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("CronJob"))
 	err = errors.WithStack(r.setControllerReference(cluster, pgBackRestCronJob))
 
 	if err == nil {
-		err = r.apply(ctx, pgBackRestCronJob)
+		err = r.applyJob(ctx, pgBackRestCronJob, backupJobPodFailurePolicy(cluster))
 	}
 	if err != nil {
 		// record and log any errors resulting from trying to create the pgBackRest backup CronJob
@@ -2946,3 +4308,489 @@ func (r *Reconciler) reconcilePGBackRestCronJob(
 	}
 	return err
 }
+
+// reconcileRepoVerification records the result of the most recently finished pgBackRest
+// verify Job for each repo in the repo status. An event is emitted when a verify Job fails,
+// as that indicates the repository is missing files or contains files that are corrupt.
+func (r *Reconciler) reconcileRepoVerification(cluster *v1beta1.PostgresCluster,
+	verifyJobs []*batchv1.Job) {
+
+	scheduled := map[string]bool{}
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		scheduled[repo.Name] = repo.VerifySchedule != nil
+	}
+
+	for i := range cluster.Status.PGBackRest.Repos {
+		repoStatus := &cluster.Status.PGBackRest.Repos[i]
+
+		// clear any previous result when the repo is no longer verified
+		if !scheduled[repoStatus.Name] {
+			repoStatus.Verified = nil
+			continue
+		}
+
+		var latest *batchv1.Job
+		for _, job := range verifyJobs {
+			if job.GetLabels()[naming.LabelPGBackRestRepo] != repoStatus.Name {
+				continue
+			}
+			if finished := jobFinishTime(job); finished != nil &&
+				(latest == nil || jobFinishTime(latest).Before(finished)) {
+				latest = job
+			}
+		}
+		if latest == nil {
+			continue
+		}
+
+		verified := jobCompleted(latest)
+		if !verified && (repoStatus.Verified == nil || *repoStatus.Verified) {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventRepoVerifyFailed,
+				"pgBackRest verify Job %q found errors in %q", latest.GetName(), repoStatus.Name)
+		}
+		repoStatus.Verified = &verified
+	}
+}
+
+// reconcileRepoSync records the most recently finished copy of each repo to its sync bucket in
+// the repo status. The replication lag is how long the most recent successful copy took; the
+// copy has everything that was in the repo when it started. An event is emitted when a copy
+// fails.
+func (r *Reconciler) reconcileRepoSync(cluster *v1beta1.PostgresCluster,
+	syncJobs []*batchv1.Job) {
+
+	scheduled := map[string]bool{}
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		scheduled[repo.Name] = repo.Sync != nil
+	}
+
+	for i := range cluster.Status.PGBackRest.Repos {
+		repoStatus := &cluster.Status.PGBackRest.Repos[i]
+
+		// clear any previous result when the repo is no longer copied
+		if !scheduled[repoStatus.Name] {
+			repoStatus.Sync = nil
+			continue
+		}
+
+		var latest, succeeded *batchv1.Job
+		for _, job := range syncJobs {
+			if job.GetLabels()[naming.LabelPGBackRestRepo] != repoStatus.Name {
+				continue
+			}
+			finished := jobFinishTime(job)
+			if finished == nil {
+				continue
+			}
+			if latest == nil || jobFinishTime(latest).Before(finished) {
+				latest = job
+			}
+			if jobCompleted(job) && job.Status.StartTime != nil &&
+				(succeeded == nil || jobFinishTime(succeeded).Before(finished)) {
+				succeeded = job
+			}
+		}
+		if latest == nil {
+			continue
+		}
+
+		previous := repoStatus.Sync
+		if previous == nil {
+			previous = &v1beta1.RepoSyncStatus{}
+		}
+		status := previous.DeepCopy()
+		status.Failed = !jobCompleted(latest)
+
+		// Keep the last successful copy when its Job has been deleted.
+		if succeeded != nil {
+			started := succeeded.Status.StartTime.DeepCopy()
+			lag := int64(jobFinishTime(succeeded).Sub(started.Time).Seconds())
+			if status.LastSyncTime == nil || status.LastSyncTime.Before(started) {
+				status.LastSyncTime = started
+				status.LagSeconds = &lag
+			}
+		}
+
+		if status.Failed && !previous.Failed {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventRepoSyncFailed,
+				"Job %q was unable to copy %q to its sync bucket", latest.GetName(), repoStatus.Name)
+		}
+		repoStatus.Sync = status
+	}
+}
+
+// reconcileBackupLifecycle records an Event when one of the backup Jobs of cluster, whether
+// manual, scheduled, or for replica creation, starts, completes, or fails. The most recent
+// backup Job to finish is reported in the pgBackRest status and in the
+// ConditionLastBackupSucceeded condition.
+func (r *Reconciler) reconcileBackupLifecycle(cluster *v1beta1.PostgresCluster,
+	backupJobs []*batchv1.Job) {
+
+	status := cluster.Status.PGBackRest
+	previouslyRunning := sets.NewString(status.RunningBackups...)
+	running := sets.NewString()
+
+	var latest *batchv1.Job
+	for _, job := range backupJobs {
+		backupType := backupJobType(job)
+		if backupType == "" {
+			continue
+		}
+
+		finished := jobFinishTime(job)
+		switch {
+		case finished != nil:
+			if latest == nil || jobFinishTime(latest).Before(finished) {
+				latest = job
+			}
+		case job.Status.StartTime != nil && job.GetDeletionTimestamp() == nil:
+			running.Insert(job.GetName())
+			if !previouslyRunning.Has(job.GetName()) {
+				r.Recorder.Eventf(cluster, corev1.EventTypeNormal, EventBackupStarted,
+					"Started %s backup Job %q for %q", backupType, job.GetName(),
+					job.GetLabels()[naming.LabelPGBackRestRepo])
+			}
+		}
+	}
+	status.RunningBackups = running.List()
+
+	// Only a backup that finished after the one already reported is new. Older Jobs may
+	// remain after the newest is deleted, e.g. by the history limit.
+	if latest != nil && (status.LastBackup == nil || status.LastBackup.FinishTime == nil ||
+		status.LastBackup.FinishTime.Before(jobFinishTime(latest))) {
+
+		status.LastBackup = &v1beta1.PGBackRestLastBackupStatus{
+			JobName:    latest.GetName(),
+			RepoName:   latest.GetLabels()[naming.LabelPGBackRestRepo],
+			Type:       backupJobType(latest),
+			Succeeded:  jobCompleted(latest),
+			StartTime:  latest.Status.StartTime,
+			FinishTime: jobFinishTime(latest),
+		}
+
+		if status.LastBackup.Succeeded {
+			r.Recorder.Eventf(cluster, corev1.EventTypeNormal, EventBackupCompleted,
+				"Completed %s backup Job %q for %q", status.LastBackup.Type,
+				status.LastBackup.JobName, status.LastBackup.RepoName)
+		} else {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventBackupFailed,
+				"Failed %s backup Job %q for %q", status.LastBackup.Type,
+				status.LastBackup.JobName, status.LastBackup.RepoName)
+		}
+	}
+
+	if last := status.LastBackup; last != nil {
+		condition := metav1.Condition{
+			ObservedGeneration: cluster.GetGeneration(),
+			Type:               ConditionLastBackupSucceeded,
+			Status:             metav1.ConditionTrue,
+			Reason:             EventBackupCompleted,
+		}
+		if !last.Succeeded {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = EventBackupFailed
+		}
+		condition.Message = fmt.Sprintf("The %s backup Job %q for %q finished at %s",
+			last.Type, last.JobName, last.RepoName, last.FinishTime.UTC().Format(time.RFC3339))
+		meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+	}
+}
+
+// backupJobType returns why job took a backup: "manual", "replica-create", or the type of a
+// scheduled backup. It returns an empty string when job does not take backups.
+func backupJobType(job *batchv1.Job) string {
+	if backupType := job.GetLabels()[naming.LabelPGBackRestBackup]; backupType != "" {
+		return backupType
+	}
+	if cronJobType := job.GetLabels()[naming.LabelPGBackRestCronJob]; cronJobType != verify &&
+		cronJobType != expire && cronJobType != repoSync {
+		return cronJobType
+	}
+	return ""
+}
+
+// repoInfoInterval is how often the backup information in the status of each
+// repo is read from pgBackRest.
+const repoInfoInterval = 5 * time.Minute
+
+// repoInfoBackups is how many of the newest backups and recovery windows in each
+// repo are listed in the repo status.
+const repoInfoBackups = 20
+
+// +kubebuilder:rbac:groups="",resources="pods/exec",verbs={create}
+
+// reconcileRepoInfo records information about the backups in each repo in the repo status
+// when it is older than repoInfoInterval. It returns how long until that information should
+// be read again, or zero when there is no writable instance to read it from.
+func (r *Reconciler) reconcileRepoInfo(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, instances *observedInstances) (time.Duration, error) {
+
+	var writableInstanceName string
+	if instances != nil {
+		for _, instance := range instances.forCluster {
+			if writable, known := instance.IsWritable(); writable && known {
+				writableInstanceName = instance.Name + "-0"
+				break
+			}
+		}
+	}
+	if writableInstanceName == "" || cluster.Status.PGBackRest == nil {
+		return 0, nil
+	}
+
+	exec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+		command ...string) error {
+		return r.PodExec(cluster.GetNamespace(), writableInstanceName,
+			naming.ContainerDatabase, stdin, stdout, stderr, command...)
+	}
+
+	now := time.Now()
+	next := repoInfoInterval
+	for i := range cluster.Status.PGBackRest.Repos {
+		repoStatus := &cluster.Status.PGBackRest.Repos[i]
+		if !repoStatus.StanzaCreated {
+			continue
+		}
+		if repoStatus.BackupInfoTime != nil {
+			if age := now.Sub(repoStatus.BackupInfoTime.Time); age < repoInfoInterval {
+				if remaining := repoInfoInterval - age; remaining < next {
+					next = remaining
+				}
+				continue
+			}
+		}
+
+		info, err := pgbackrest.Executor(exec).RepoInfo(ctx,
+			pgbackrest.StanzaName(cluster), repoStatus.Name)
+		if err != nil {
+			return next, err
+		}
+
+		finished := func(backupType string) *metav1.Time {
+			if t, ok := info.LastBackupTime[backupType]; ok {
+				finished := metav1.NewTime(t)
+				return &finished
+			}
+			return nil
+		}
+		repoStatus.LastFullBackupTime = finished(full)
+		repoStatus.LastDifferentialBackupTime = finished(differential)
+		repoStatus.LastIncrementalBackupTime = finished(incremental)
+		repoStatus.LastBackupSize = info.LastBackupSize
+		repoStatus.ArchiveMin = info.ArchiveMin
+		repoStatus.ArchiveMax = info.ArchiveMax
+		repoStatus.BackupsSize = &info.BackupsSize
+
+		// List only the newest backups to keep the status small.
+		backups := info.Backups
+		if len(backups) > repoInfoBackups {
+			backups = backups[len(backups)-repoInfoBackups:]
+		}
+		repoStatus.Backups = nil
+		for _, backup := range backups {
+			started, stopped := metav1.NewTime(backup.StartTime), metav1.NewTime(backup.StopTime)
+			repoStatus.Backups = append(repoStatus.Backups, v1beta1.RepoBackupStatus{
+				Label:        backup.Label,
+				Type:         backup.Type,
+				StartTime:    &started,
+				StopTime:     &stopped,
+				Size:         backup.Size,
+				ArchiveStart: backup.ArchiveStart,
+				ArchiveStop:  backup.ArchiveStop,
+			})
+		}
+
+		// WAL reaches every repo while archiving is healthy, so restores can
+		// reach about now. Otherwise, only the backups themselves are certain.
+		var archivedUntil time.Time
+		if meta.IsStatusConditionTrue(cluster.Status.Conditions, ConditionArchivingHealthy) {
+			archivedUntil = now
+		}
+		windows := info.RecoveryWindows(archivedUntil)
+		if len(windows) > repoInfoBackups {
+			windows = windows[len(windows)-repoInfoBackups:]
+		}
+		repoStatus.RecoveryWindows = nil
+		for _, window := range windows {
+			repoStatus.RecoveryWindows = append(repoStatus.RecoveryWindows,
+				v1beta1.RepoRecoveryWindow{
+					Start: metav1.NewTime(window.Start),
+					End:   metav1.NewTime(window.End),
+				})
+		}
+		repoStatus.BackupInfoTime = &metav1.Time{Time: now}
+	}
+	return next, nil
+}
+
+// expireDryRunOption matches the options allowed in an expire dry run. Only retention can be
+// changed; anything else might cause the command to remove something.
+var expireDryRunOption = regexp.MustCompile(
+	`^--repo[1-4]-retention-(archive|archive-type|diff|full|full-type|history)=[^\s=]+$`)
+
+// +kubebuilder:rbac:groups="",resources="pods",verbs={list}
+// +kubebuilder:rbac:groups="",resources="pods/exec",verbs={create}
+
+// reconcileExpireDryRun runs the pgBackRest "expire" command with "--dry-run" in each repo
+// that has a stanza when the "pgbackrest-expire-dry-run" annotation has an ID other than the
+// one in status. What would be removed is recorded in status. A dry run that fails is not
+// tried again until the annotation changes.
+func (r *Reconciler) reconcileExpireDryRun(ctx context.Context,
+	cluster *v1beta1.PostgresCluster) error {
+
+	id := cluster.GetAnnotations()[naming.PGBackRestExpireDryRun]
+	if id == "" || cluster.Status.PGBackRest == nil {
+		return nil
+	}
+	if current := cluster.Status.PGBackRest.ExpireDryRun; current != nil && current.ID == id {
+		return nil
+	}
+
+	var options []string
+	if spec := cluster.Spec.Backups.PGBackRest.ExpireDryRun; spec != nil {
+		options = spec.Options
+	}
+
+	result := &v1beta1.PGBackRestExpireDryRunStatus{ID: id}
+	for _, option := range options {
+		if !expireDryRunOption.MatchString(option) {
+			result.Error = fmt.Sprintf("option %q is not allowed; only retention options are", option)
+			break
+		}
+	}
+
+	stanzas := make(map[string]bool)
+	for _, repoStatus := range cluster.Status.PGBackRest.Repos {
+		stanzas[repoStatus.Name] = repoStatus.StanzaCreated
+	}
+
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		if result.Error != "" {
+			break
+		}
+		if !stanzas[repo.Name] {
+			continue
+		}
+
+		selector, containerName, err := getPGBackRestExecSelector(cluster, repo)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		pods := &corev1.PodList{}
+		if err := r.Client.List(ctx, pods, client.InNamespace(cluster.Namespace),
+			client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return errors.WithStack(err)
+		}
+
+		var pod *corev1.Pod
+		for i := range pods.Items {
+			if pods.Items[i].Status.Phase == corev1.PodRunning &&
+				pods.Items[i].GetDeletionTimestamp() == nil {
+				pod = &pods.Items[i]
+			}
+		}
+		if pod == nil {
+			// Try again once the Pod is running.
+			return errors.Errorf("no running Pod for an expire dry run of %s", repo.Name)
+		}
+
+		exec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+			command ...string) error {
+			return r.PodExec(pod.Namespace, pod.Name, containerName,
+				stdin, stdout, stderr, command...)
+		}
+
+		removed, err := pgbackrest.Executor(exec).ExpireDryRun(ctx,
+			pgbackrest.StanzaName(cluster), repo.Name, options...)
+		if err != nil {
+			result.Error = fmt.Sprintf("%s: %v", repo.Name, err)
+			break
+		}
+
+		result.Repos = append(result.Repos, v1beta1.PGBackRestExpireDryRunRepoStatus{
+			Name:    repo.Name,
+			Backups: removed.Backups,
+			Archive: removed.Archive,
+		})
+	}
+
+	now := metav1.Now()
+	result.CompletionTime = &now
+	if result.Error != "" {
+		result.Repos = nil
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "ExpireDryRunFailed",
+			"pgBackRest expire dry run %q failed: %s", id, result.Error)
+	} else {
+		var backups int
+		for _, repo := range result.Repos {
+			backups += len(repo.Backups)
+		}
+		r.Recorder.Eventf(cluster, corev1.EventTypeNormal, "ExpireDryRunComplete",
+			"pgBackRest expire dry run %q found %d backups to remove", id, backups)
+	}
+
+	cluster.Status.PGBackRest.ExpireDryRun = result
+	return nil
+}
+
+// +kubebuilder:rbac:groups="batch",resources="jobs",verbs={delete}
+
+// reconcileBackupJobHistory deletes the oldest finished backup Jobs of cluster until no more
+// than its history limit of successful and failed Jobs remain. The replica create backup Job
+// and the Job of the current manual backup are kept regardless.
+func (r *Reconciler) reconcileBackupJobHistory(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, backupJobs []*batchv1.Job) error {
+
+	limit := cluster.Spec.Backups.PGBackRest.JobHistoryLimit
+	if limit == nil {
+		return nil
+	}
+
+	var manualID string
+	if cluster.Status.PGBackRest != nil && cluster.Status.PGBackRest.ManualBackup != nil {
+		manualID = cluster.Status.PGBackRest.ManualBackup.ID
+	}
+
+	var succeeded, failed []*batchv1.Job
+	for _, job := range backupJobs {
+		switch {
+		case backupJobType(job) == "":
+			// Jobs that verify, expire or copy backups have no history limit.
+		case job.GetDeletionTimestamp() != nil, jobFinishTime(job) == nil:
+		case job.GetLabels()[naming.LabelPGBackRestBackup] == string(naming.BackupReplicaCreate):
+		case job.GetLabels()[naming.LabelPGBackRestBackup] == string(naming.BackupManual) &&
+			job.GetAnnotations()[naming.PGBackRestBackup] == manualID:
+		case jobCompleted(job):
+			succeeded = append(succeeded, job)
+		default:
+			failed = append(failed, job)
+		}
+	}
+
+	var jobs []*batchv1.Job
+	for _, history := range []struct {
+		jobs  []*batchv1.Job
+		limit *int32
+	}{
+		{jobs: succeeded, limit: limit.Successful},
+		{jobs: failed, limit: limit.Failed},
+	} {
+		if history.limit == nil || len(history.jobs) <= int(*history.limit) {
+			continue
+		}
+
+		// sort the newest Jobs first and keep as many as the limit allows
+		sort.SliceStable(history.jobs, func(i, j int) bool {
+			return jobFinishTime(history.jobs[j]).Before(jobFinishTime(history.jobs[i]))
+		})
+		jobs = append(jobs, history.jobs[*history.limit:]...)
+	}
+
+	for _, job := range jobs {
+		if err := client.IgnoreNotFound(r.Client.Delete(ctx, job,
+			client.PropagationPolicy(metav1.DeletePropagationBackground))); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+//go:build envtest && faults
+// +build envtest,faults
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgoruntime "github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/testing/require"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestReconcileManualBackupFaults(t *testing.T) {
+	ctx := context.Background()
+	_, tClient := setupKubernetes(t)
+	require.ParallelCapacity(t, 1)
+
+	ns := setupNamespace(t, tClient)
+	owner := client.FieldOwner(t.Name())
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "hippo-sa"}}
+
+	cluster := fakePostgresCluster("manual-backup-faults", ns.GetName(), "", false)
+	cluster.Annotations = map[string]string{naming.PGBackRestBackup: "faulty"}
+	cluster.Spec.Backups.PGBackRest.Manual = &v1beta1.PGBackRestManualBackup{RepoName: "repo1"}
+	assert.NilError(t, tClient.Create(ctx, cluster))
+
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}},
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		Type: ConditionReplicaCreate, Reason: "testing", Status: metav1.ConditionTrue,
+	})
+
+	instances := &observedInstances{
+		forCluster: []*Instance{{
+			Name: "instance1",
+			Pods: []*corev1.Pod{{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{"status": `"role":"master"`},
+					Labels:      map[string]string{naming.LabelRole: naming.RolePatroniLeader},
+				},
+			}},
+		}},
+	}
+
+	listJobs := func(t *testing.T, cc client.Client) []*batchv1.Job {
+		jobs := &batchv1.JobList{}
+		assert.NilError(t, cc.List(ctx, jobs, client.InNamespace(ns.GetName()),
+			client.MatchingLabelsSelector{Selector: naming.PGBackRestBackupJobSelector(
+				cluster.GetName(), "repo1", naming.BackupManual)}))
+
+		result := make([]*batchv1.Job, len(jobs.Items))
+		for i := range jobs.Items {
+			result[i] = &jobs.Items[i]
+		}
+		return result
+	}
+
+	t.Run("Conflict", func(t *testing.T) {
+		faults, err := pgoruntime.ParseFaults("conflict=1")
+		assert.NilError(t, err)
+
+		r := &Reconciler{
+			Client:   faults.Client(tClient),
+			Owner:    owner,
+			Recorder: record.NewFakeRecorder(10),
+		}
+
+		err = r.reconcileManualBackup(ctx, cluster, nil, sa, instances)
+		assert.Assert(t, apierrors.IsConflict(err), "got %#v", err)
+		assert.Equal(t, len(listJobs(t, tClient)), 0)
+
+		// The next attempt creates the Job.
+		assert.NilError(t, r.reconcileManualBackup(ctx, cluster, nil, sa, instances))
+		assert.Equal(t, len(listJobs(t, tClient)), 1)
+	})
+
+	t.Run("SlowBackup", func(t *testing.T) {
+		faults, err := pgoruntime.ParseFaults("slow-backup=1h")
+		assert.NilError(t, err)
+
+		jobs := listJobs(t, tClient)
+		assert.Equal(t, len(jobs), 1)
+
+		job := jobs[0]
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+			LastTransitionTime: metav1.Now(),
+		}}
+		assert.NilError(t, tClient.Status().Update(ctx, job))
+
+		r := &Reconciler{
+			Client:   faults.Client(tClient),
+			Owner:    owner,
+			Recorder: record.NewFakeRecorder(10),
+		}
+
+		assert.NilError(t, r.reconcileManualBackup(ctx, cluster, listJobs(t, r.Client), sa, instances))
+		assert.Assert(t, cluster.Status.PGBackRest.ManualBackup != nil)
+		assert.Assert(t, !cluster.Status.PGBackRest.ManualBackup.Finished,
+			"expected the backup to still be running")
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionManualBackupSuccessful) == nil)
+
+		// Without faults, the same Job is finished.
+		r.Client = tClient
+		assert.NilError(t, r.reconcileManualBackup(ctx, cluster, listJobs(t, r.Client), sa, instances))
+		assert.Assert(t, cluster.Status.PGBackRest.ManualBackup.Finished)
+		assert.Assert(t, meta.IsStatusConditionTrue(cluster.Status.Conditions,
+			ConditionManualBackupSuccessful))
+	})
+}
@@ -31,6 +31,7 @@ import (
 
 	"go.opentelemetry.io/otel"
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -46,15 +47,19 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	pgoruntime "github.com/crunchydata/postgres-operator/internal/controller/runtime"
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
 	"github.com/crunchydata/postgres-operator/internal/pki"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -144,6 +149,7 @@ func fakePostgresCluster(clusterName, namespace, clusterUID string,
 			PriorityClassName: initialize.String("some-priority-class"),
 			Resources:         corev1.ResourceRequirements{},
 			Affinity:          &corev1.Affinity{},
+			NodeSelector:      map[string]string{"disktype": "ssd"},
 			Tolerations: []corev1.Toleration{
 				{Key: "woot"},
 			},
@@ -338,6 +344,8 @@ dnsPolicy: ClusterFirst
 enableServiceLinks: false
 imagePullSecrets:
 - name: myImagePullSecret
+nodeSelector:
+  disktype: ssd
 priorityClassName: some-priority-class
 restartPolicy: Always
 schedulerName: default-scheduler
@@ -545,11 +553,14 @@ topologySpreadConstraints:
 				Full:         &testCronSchedule,
 				Differential: &testCronSchedule,
 				Incremental:  &testCronSchedule,
-			}}
+			},
+			ExpireSchedule: &testCronSchedule,
+		}
 
 		assert.Assert(t, backupScheduleFound(testrepo, "full"))
 		assert.Assert(t, backupScheduleFound(testrepo, "diff"))
 		assert.Assert(t, backupScheduleFound(testrepo, "incr"))
+		assert.Assert(t, backupScheduleFound(testrepo, "expire"))
 
 	})
 
@@ -559,6 +570,18 @@ topologySpreadConstraints:
 
 		noscheduletestrepo := v1beta1.PGBackRestRepo{Name: "repo1"}
 		assert.Assert(t, !backupScheduleFound(noscheduletestrepo, "full"))
+		assert.Assert(t, !backupScheduleFound(noscheduletestrepo, "expire"))
+
+	})
+
+	t.Run("verify pgbackrest expire schedule with time-based retention", func(t *testing.T) {
+
+		testrepo := v1beta1.PGBackRestRepo{Name: "repo1", RetentionDays: initialize.Int32(14)}
+		assert.Assert(t, backupScheduleFound(testrepo, "expire"))
+		assert.Equal(t, *repoExpireSchedule(testrepo), "0 0 * * *")
+
+		testrepo.ExpireSchedule = &testCronSchedule
+		assert.Equal(t, *repoExpireSchedule(testrepo), testCronSchedule)
 
 	})
 
@@ -609,6 +632,41 @@ topologySpreadConstraints:
 
 			assert.Assert(t, *returnedCronJob.Spec.Suspend)
 		})
+
+		t.Run("repo schedules", func(t *testing.T) {
+			postgresCluster.Spec.Standby = nil
+			schedules := postgresCluster.Spec.Backups.PGBackRest.Repos[0].BackupSchedules
+			schedules.Suspend = initialize.Bool(true)
+			t.Cleanup(func() { schedules.Suspend = nil })
+
+			requeue := r.reconcileScheduledBackups(ctx,
+				postgresCluster, serviceAccount, fakeObservedCronJobs())
+			assert.Assert(t, !requeue)
+
+			assert.NilError(t, tClient.Get(ctx, types.NamespacedName{
+				Name:      postgresCluster.Name + "-repo1-full",
+				Namespace: postgresCluster.GetNamespace(),
+			}, returnedCronJob))
+
+			assert.Assert(t, *returnedCronJob.Spec.Suspend)
+		})
+
+		t.Run("all schedules", func(t *testing.T) {
+			postgresCluster.Spec.Standby = nil
+			postgresCluster.Spec.Backups.PGBackRest.SuspendSchedules = initialize.Bool(true)
+			t.Cleanup(func() { postgresCluster.Spec.Backups.PGBackRest.SuspendSchedules = nil })
+
+			requeue := r.reconcileScheduledBackups(ctx,
+				postgresCluster, serviceAccount, fakeObservedCronJobs())
+			assert.Assert(t, !requeue)
+
+			assert.NilError(t, tClient.Get(ctx, types.NamespacedName{
+				Name:      postgresCluster.Name + "-repo1-full",
+				Namespace: postgresCluster.GetNamespace(),
+			}, returnedCronJob))
+
+			assert.Assert(t, *returnedCronJob.Spec.Suspend)
+		})
 	})
 }
 
@@ -801,6 +859,145 @@ func TestReconcileStanzaCreate(t *testing.T) {
 	}
 }
 
+func TestReconcileStanzaUpgrade(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := fakePostgresCluster("hippocluster", "upgraded", "hippouid", false)
+	cluster.Status.PostgresVersion = 14
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: false}},
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		Type: ConditionPostUpgradeBackup, Status: metav1.ConditionFalse, Reason: "Upgraded",
+	})
+
+	instances := newObservedInstances(cluster, nil, []corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"status": `"role":"master"`},
+			Labels: map[string]string{
+				naming.LabelCluster:  cluster.Name,
+				naming.LabelInstance: "",
+				naming.LabelRole:     naming.RolePatroniLeader,
+			},
+		},
+	}})
+
+	// The stanza of the previous version does not match the upgraded database,
+	// so it is upgraded rather than created.
+	var commands []string
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{
+		Recorder: recorder,
+		PodExec: func(namespace, pod, container string, stdin io.Reader, stdout,
+			stderr io.Writer, command ...string) error {
+			commands = append(commands, command[len(command)-1])
+			if command[len(command)-1] == "stanza-create" {
+				_, _ = io.WriteString(stderr, "ERROR: [028]: backup and archive info files "+
+					"exist but do not match the database")
+				return errors.New("command terminated with exit code 28")
+			}
+			return nil
+		},
+	}
+
+	configHashMismatch, err := r.reconcileStanzaCreate(ctx, cluster, instances, "abcde12345")
+	assert.NilError(t, err)
+	assert.Assert(t, !configHashMismatch)
+	assert.DeepEqual(t, commands, []string{"stanza-create", "stanza-upgrade"})
+	assert.Assert(t, cluster.Status.PGBackRest.Repos[0].StanzaCreated)
+
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, <-recorder.Events,
+		"Normal StanzasUpgraded pgBackRest stanza upgrade to PostgreSQL 14 completed successfully")
+}
+
+func TestReconcileStanzaAdopt(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := fakePostgresCluster("hippocluster", "adopted", "hippouid", false)
+	cluster.Spec.Backups.PGBackRest.Repos[0].AdoptExisting = initialize.Bool(true)
+	cluster.Status.Patroni.SystemIdentifier = "7208476291367340075"
+
+	instances := newObservedInstances(cluster, nil, []corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"status": `"role":"master"`},
+			Labels: map[string]string{
+				naming.LabelCluster:  cluster.Name,
+				naming.LabelInstance: "",
+				naming.LabelRole:     naming.RolePatroniLeader,
+			},
+		},
+	}})
+
+	var commands []string
+	var info string
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{
+		Recorder: recorder,
+		PodExec: func(namespace, pod, container string, stdin io.Reader, stdout,
+			stderr io.Writer, command ...string) error {
+			if command[0] == "pgbackrest" {
+				commands = append(commands, command[1])
+				_, _ = io.WriteString(stdout, info)
+				return nil
+			}
+			commands = append(commands, command[len(command)-1])
+			return nil
+		},
+	}
+
+	t.Run("Matching", func(t *testing.T) {
+		commands = nil
+		info = `[{"db":[{"id":1,"system-id":7208476291367340075}],"name":"db"}]`
+		cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			Repos: []v1beta1.RepoStatus{{Name: "repo1"}},
+		}
+
+		configHashMismatch, err := r.reconcileStanzaCreate(ctx, cluster, instances, "abcde12345")
+		assert.NilError(t, err)
+		assert.Assert(t, !configHashMismatch)
+		assert.DeepEqual(t, commands, []string{"info"})
+		assert.Assert(t, cluster.Status.PGBackRest.Repos[0].StanzaCreated)
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, <-recorder.Events,
+			`Normal StanzaAdopted pgBackRest stanza "db" already exists in "repo1"`)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		commands = nil
+		info = `[{"db":[],"name":"db","status":{"code":1,"message":"missing stanza path"}}]`
+		cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			Repos: []v1beta1.RepoStatus{{Name: "repo1"}},
+		}
+
+		_, err := r.reconcileStanzaCreate(ctx, cluster, instances, "abcde12345")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, commands, []string{"info", "stanza-create"})
+		assert.Assert(t, cluster.Status.PGBackRest.Repos[0].StanzaCreated)
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, <-recorder.Events,
+			"Normal StanzasCreated pgBackRest stanza creation completed successfully")
+	})
+
+	t.Run("AnotherDatabase", func(t *testing.T) {
+		commands = nil
+		info = `[{"db":[{"id":1,"system-id":7106528346893520946}],"name":"db"}]`
+		cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			Repos: []v1beta1.RepoStatus{{Name: "repo1"}},
+		}
+
+		_, err := r.reconcileStanzaCreate(ctx, cluster, instances, "abcde12345")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, commands, []string{"info", "stanza-create"})
+
+		assert.Equal(t, len(recorder.Events), 2)
+		assert.Equal(t, <-recorder.Events, `Warning StanzaNotAdopted pgBackRest stanza "db" `+
+			`in "repo1" belongs to another database (system identifier 7106528346893520946)`)
+	})
+}
+
 func TestGetPGBackRestExecSelector(t *testing.T) {
 
 	testCases := []struct {
@@ -835,6 +1032,47 @@ func TestGetPGBackRestExecSelector(t *testing.T) {
 			"postgres-operator.crunchydata.com/instance," +
 			"postgres-operator.crunchydata.com/role=master",
 		expectedContainer: "database",
+	}, {
+		desc: "shared repo host",
+		cluster: &v1beta1.PostgresCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "hippo"},
+		},
+		repo: v1beta1.PGBackRestRepo{
+			Name:       "repo1",
+			SharedHost: &v1beta1.RepoSharedHost{Name: "zoo"},
+		},
+		expectedSelector:  "postgres-operator.crunchydata.com/shared-repo-host=zoo",
+		expectedContainer: "pgbackrest",
+	}, {
+		desc: "volume repo on a repo host with more than one pod",
+		cluster: &v1beta1.PostgresCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "hippo"},
+			Spec: v1beta1.PostgresClusterSpec{
+				Backups: v1beta1.Backups{PGBackRest: v1beta1.PGBackRestArchive{
+					RepoHost: &v1beta1.PGBackRestRepoHost{Replicas: initialize.Int32(2)},
+					Repos: []v1beta1.PGBackRestRepo{{
+						Name: "repo1",
+						Volume: &v1beta1.RepoPVC{VolumeClaimSpec: corev1.PersistentVolumeClaimSpec{
+							AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+						}},
+					}},
+				}},
+			},
+			Status: v1beta1.PostgresClusterStatus{
+				PGBackRest: &v1beta1.PGBackRestStatus{
+					RepoHost: &v1beta1.RepoHostStatus{ActivePod: "hippo-repo-host-1"},
+				},
+			},
+		},
+		repo: v1beta1.PGBackRestRepo{
+			Name:   "repo1",
+			Volume: &v1beta1.RepoPVC{},
+		},
+		expectedSelector: "postgres-operator.crunchydata.com/cluster=hippo," +
+			"postgres-operator.crunchydata.com/pgbackrest=," +
+			"postgres-operator.crunchydata.com/pgbackrest-dedicated=," +
+			"statefulset.kubernetes.io/pod-name=hippo-repo-host-1",
+		expectedContainer: "pgbackrest",
 	}}
 
 	for _, tc := range testCases {
@@ -847,6 +1085,58 @@ func TestGetPGBackRestExecSelector(t *testing.T) {
 	}
 }
 
+func TestActiveRepoHostPod(t *testing.T) {
+	pod := func(name string, ready corev1.ConditionStatus) corev1.Pod {
+		var pod corev1.Pod
+		pod.Name = name
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type: corev1.PodReady, Status: ready,
+		}}
+		return pod
+	}
+
+	t.Run("NoPods", func(t *testing.T) {
+		assert.Equal(t, activeRepoHostPod("", nil), "")
+		assert.Equal(t, activeRepoHostPod("some-0", nil), "some-0")
+	})
+
+	t.Run("CurrentReady", func(t *testing.T) {
+		pods := []corev1.Pod{
+			pod("some-0", corev1.ConditionTrue),
+			pod("some-1", corev1.ConditionTrue),
+		}
+		assert.Equal(t, activeRepoHostPod("some-1", pods), "some-1")
+	})
+
+	t.Run("CurrentNotReady", func(t *testing.T) {
+		pods := []corev1.Pod{
+			pod("some-2", corev1.ConditionTrue),
+			pod("some-0", corev1.ConditionFalse),
+			pod("some-1", corev1.ConditionTrue),
+		}
+		assert.Equal(t, activeRepoHostPod("some-0", pods), "some-1")
+		assert.Equal(t, activeRepoHostPod("", pods), "some-1")
+	})
+
+	t.Run("CurrentTerminating", func(t *testing.T) {
+		pods := []corev1.Pod{
+			pod("some-0", corev1.ConditionTrue),
+			pod("some-1", corev1.ConditionTrue),
+		}
+		now := metav1.Now()
+		pods[0].DeletionTimestamp = &now
+		assert.Equal(t, activeRepoHostPod("some-0", pods), "some-1")
+	})
+
+	t.Run("NoneReady", func(t *testing.T) {
+		pods := []corev1.Pod{
+			pod("some-0", corev1.ConditionFalse),
+			pod("some-1", corev1.ConditionUnknown),
+		}
+		assert.Equal(t, activeRepoHostPod("some-1", pods), "some-1")
+	})
+}
+
 func TestReconcileReplicaCreateBackup(t *testing.T) {
 	// Garbage collector cleans up test resources before the test completes
 	if strings.EqualFold(os.Getenv("USE_EXISTING_CLUSTER"), "true") {
@@ -1009,6 +1299,40 @@ func TestReconcileReplicaCreateBackup(t *testing.T) {
 	if assert.Check(t, replicaCreateRepoStatus != nil) {
 		assert.Assert(t, replicaCreateRepoStatus.ReplicaCreateBackupComplete)
 	}
+
+	t.Run("Basebackup", func(t *testing.T) {
+		cluster := postgresCluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.ReplicaCreate = "Basebackup"
+		cluster.Status.PGBackRest.Repos[0].ReplicaCreateBackupComplete = false
+		r := *r
+		r.PodExec = func(string, string, string, io.Reader, io.Writer, io.Writer, ...string) error {
+			t.Fatal("expected no exec")
+			return nil
+		}
+
+		assert.NilError(t, r.reconcileReplicaCreateBackup(ctx, cluster, instances,
+			[]*batchv1.Job{}, sa, configHash, replicaCreateRepo))
+		assert.Assert(t, !cluster.Status.PGBackRest.Repos[0].ReplicaCreateBackupComplete)
+	})
+
+	t.Run("LatestBackup", func(t *testing.T) {
+		cluster := postgresCluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.ReplicaCreate = "LatestBackup"
+		cluster.Status.PGBackRest.Repos[0].ReplicaCreateBackupComplete = false
+		r := *r
+		r.PodExec = func(_, _, _ string, _ io.Reader, stdout, _ io.Writer, command ...string) error {
+			assert.Equal(t, command[1], "info")
+			_, err := io.WriteString(stdout, `[{
+				"backup":[{"database":{"id":1,"repo-key":1},"label":"20230101-000000F"}],
+				"db":[{"id":1}],"name":"db"
+			}]`)
+			return err
+		}
+
+		assert.NilError(t, r.reconcileReplicaCreateBackup(ctx, cluster, instances,
+			[]*batchv1.Job{}, sa, configHash, replicaCreateRepo))
+		assert.Assert(t, cluster.Status.PGBackRest.Repos[0].ReplicaCreateBackupComplete)
+	})
 }
 
 func TestReconcileManualBackup(t *testing.T) {
@@ -1084,6 +1408,9 @@ func TestReconcileManualBackup(t *testing.T) {
 		// the reason associated with the expected event for the test (can be empty if
 		// no event is expected)
 		expectedEventReason string
+		// the type of backup expected in the label and status of the Job when it differs
+		// from the "type" field of manual
+		expectedBackupType *string
 	}{{
 		testDesc:         "read-only cluster should not reconcile",
 		createCurrentJob: false,
@@ -1250,6 +1577,73 @@ func TestReconcileManualBackup(t *testing.T) {
 		manual:                   &v1beta1.PGBackRestManualBackup{RepoName: "repo1"},
 		expectCurrentJobDeletion: false,
 		expectReconcile:          true,
+	}, {
+		testDesc:         "reconcile job with a backup type",
+		createCurrentJob: false,
+		clusterConditions: map[string]metav1.ConditionStatus{
+			ConditionRepoHostReady: metav1.ConditionTrue,
+			ConditionReplicaCreate: metav1.ConditionTrue,
+		},
+		status: &v1beta1.PostgresClusterStatus{
+			PGBackRest: &v1beta1.PGBackRestStatus{
+				Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}}},
+		},
+		backupId: backupId,
+		manual: &v1beta1.PGBackRestManualBackup{RepoName: "repo1", Type: "diff",
+			Options: []string{"--start-fast"}},
+		expectCurrentJobDeletion: false,
+		expectReconcile:          true,
+	}, {
+		testDesc:         "reconcile job with a backup type among other options",
+		createCurrentJob: false,
+		clusterConditions: map[string]metav1.ConditionStatus{
+			ConditionRepoHostReady: metav1.ConditionTrue,
+			ConditionReplicaCreate: metav1.ConditionTrue,
+		},
+		status: &v1beta1.PostgresClusterStatus{
+			PGBackRest: &v1beta1.PGBackRestStatus{
+				Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}}},
+		},
+		backupId: backupId,
+		manual: &v1beta1.PGBackRestManualBackup{RepoName: "repo1",
+			Options: []string{"--type=full --start-fast"}},
+		expectCurrentJobDeletion: false,
+		expectReconcile:          true,
+		expectedBackupType:       initialize.String("full"),
+	}, {
+		testDesc:         "reconcile job with an unknown backup type in options",
+		createCurrentJob: false,
+		clusterConditions: map[string]metav1.ConditionStatus{
+			ConditionRepoHostReady: metav1.ConditionTrue,
+			ConditionReplicaCreate: metav1.ConditionTrue,
+		},
+		status: &v1beta1.PostgresClusterStatus{
+			PGBackRest: &v1beta1.PGBackRestStatus{
+				Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}}},
+		},
+		backupId: backupId,
+		manual: &v1beta1.PGBackRestManualBackup{RepoName: "repo1",
+			Options: []string{"--type=weekly"}},
+		expectCurrentJobDeletion: false,
+		expectReconcile:          true,
+		expectedBackupType:       initialize.String(""),
+	}, {
+		testDesc:         "backup type in both field and options should not reconcile",
+		createCurrentJob: false,
+		clusterConditions: map[string]metav1.ConditionStatus{
+			ConditionRepoHostReady: metav1.ConditionTrue,
+			ConditionReplicaCreate: metav1.ConditionTrue,
+		},
+		status: &v1beta1.PostgresClusterStatus{
+			PGBackRest: &v1beta1.PGBackRestStatus{
+				Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}}},
+		},
+		backupId: backupId,
+		manual: &v1beta1.PGBackRestManualBackup{RepoName: "repo1", Type: "diff",
+			Options: []string{"--type=full"}},
+		expectCurrentJobDeletion: false,
+		expectReconcile:          false,
+		expectedEventReason:      "InvalidManualBackup",
 	}, {
 		testDesc:         "reconcile job when current job exists for id and is in progress",
 		createCurrentJob: true,
@@ -1400,6 +1794,21 @@ func TestReconcileManualBackup(t *testing.T) {
 					assert.Assert(t, postgresCluster.Status.PGBackRest.ManualBackup != nil)
 					assert.Assert(t, postgresCluster.Status.PGBackRest.ManualBackup.ID != "")
 
+					// verify the requested type of backup
+					backupType := tc.manual.Type
+					if tc.expectedBackupType != nil {
+						backupType = *tc.expectedBackupType
+					}
+					assert.Equal(t, jobs.Items[0].GetLabels()[naming.LabelPGBackRestBackupType],
+						backupType)
+					assert.Equal(t, postgresCluster.Status.PGBackRest.ManualBackup.Type,
+						backupType)
+					for _, env := range jobs.Items[0].Spec.Template.Spec.Containers[0].Env {
+						if env.Name == "COMMAND_OPTS" && tc.manual.Type != "" {
+							assert.Assert(t, strings.Contains(env.Value, "--type="+tc.manual.Type))
+						}
+					}
+
 					return
 				} else {
 
@@ -2666,6 +3075,21 @@ volumes:
 		assert.DeepEqual(t, job.Template.Spec.Tolerations, tolerations)
 	})
 
+	t.Run("NodeSelector", func(t *testing.T) {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Spec.Backups.PGBackRest.Jobs = &v1beta1.BackupJobs{
+			NodeSelector: map[string]string{"workload": "backups"},
+		}
+		job, err := generateBackupJobSpecIntent(
+			cluster, v1beta1.PGBackRestRepo{},
+			"",
+			nil, nil,
+		)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, job.Template.Spec.NodeSelector,
+			map[string]string{"workload": "backups"})
+	})
+
 	t.Run("TTLSecondsAfterFinished", func(t *testing.T) {
 		cluster := &v1beta1.PostgresCluster{}
 
@@ -2715,6 +3139,104 @@ volumes:
 			}
 		})
 	})
+
+	t.Run("Limits", func(t *testing.T) {
+		cluster := &v1beta1.PostgresCluster{}
+
+		spec, err := generateBackupJobSpecIntent(
+			cluster, v1beta1.PGBackRestRepo{}, "", nil, nil,
+		)
+		assert.NilError(t, err)
+		assert.Assert(t, spec.BackoffLimit == nil)
+		assert.Assert(t, spec.ActiveDeadlineSeconds == nil)
+
+		cluster.Spec.Backups.PGBackRest.Jobs = &v1beta1.BackupJobs{
+			BackoffLimit:          initialize.Int32(1),
+			ActiveDeadlineSeconds: initialize.Int64(7200),
+		}
+
+		spec, err = generateBackupJobSpecIntent(
+			cluster, v1beta1.PGBackRestRepo{}, "", nil, nil,
+		)
+		assert.NilError(t, err)
+		assert.Equal(t, *spec.BackoffLimit, int32(1))
+		assert.Equal(t, *spec.ActiveDeadlineSeconds, int64(7200))
+	})
+
+	t.Run("BackupOptions", func(t *testing.T) {
+		cluster := &v1beta1.PostgresCluster{}
+		repo := v1beta1.PGBackRestRepo{
+			Name: "repo1",
+			BackupOptions: &v1beta1.PGBackRestRepoBackupOptions{
+				PGBackRestBackupOptions: v1beta1.PGBackRestBackupOptions{
+					CompressType: "lz4",
+				},
+				Differential: &v1beta1.PGBackRestBackupOptions{
+					ProcessMax: initialize.Int32(2),
+				},
+			},
+		}
+
+		commandOpts := func(spec *batchv1.JobSpec) string {
+			for _, env := range spec.Template.Spec.Containers[0].Env {
+				if env.Name == "COMMAND_OPTS" {
+					return env.Value
+				}
+			}
+			return ""
+		}
+
+		spec, err := generateBackupJobSpecIntent(cluster, repo, "", nil, nil, "--type=diff")
+		assert.NilError(t, err)
+		assert.Equal(t, commandOpts(spec),
+			"--stanza=db --repo=1 --type=diff --compress-type=lz4 --process-max=2")
+
+		// Options that are already set are not repeated.
+		spec, err = generateBackupJobSpecIntent(cluster, repo, "", nil, nil, "--compress-type=gz")
+		assert.NilError(t, err)
+		assert.Equal(t, commandOpts(spec), "--stanza=db --repo=1 --compress-type=gz")
+
+		// Options of the backup type replace those of the repository.
+		repo.BackupOptions.Options = []string{"--start-fast", "--archive-check=n"}
+		repo.BackupOptions.Differential.Options = []string{"--start-fast=n"}
+		spec, err = generateBackupJobSpecIntent(cluster, repo, "", nil, nil, "--type=diff")
+		assert.NilError(t, err)
+		assert.Equal(t, commandOpts(spec), "--stanza=db --repo=1 --type=diff"+
+			" --compress-type=lz4 --process-max=2 --archive-check=n --start-fast=n")
+
+		spec, err = generateBackupJobSpecIntent(cluster, repo, "", nil, nil, "--type=full")
+		assert.NilError(t, err)
+		assert.Equal(t, commandOpts(spec), "--stanza=db --repo=1 --type=full"+
+			" --compress-type=lz4 --start-fast --archive-check=n")
+
+		// The type and names of options are found wherever they are.
+		for _, tt := range []struct {
+			opts     []string
+			expected string
+		}{
+			{[]string{"--type diff"}, "--type diff" +
+				" --compress-type=lz4 --process-max=2 --archive-check=n --start-fast=n"},
+			{[]string{"--type", "diff"}, "--type diff" +
+				" --compress-type=lz4 --process-max=2 --archive-check=n --start-fast=n"},
+			{[]string{"--start-fast=y --type=diff"}, "--start-fast=y --type=diff" +
+				" --compress-type=lz4 --process-max=2 --archive-check=n"},
+		} {
+			spec, err = generateBackupJobSpecIntent(cluster, repo, "", nil, nil, tt.opts...)
+			assert.NilError(t, err)
+			assert.Equal(t, commandOpts(spec), "--stanza=db --repo=1 "+tt.expected,
+				"opts: %q", tt.opts)
+		}
+
+		spec, err = generateBackupJobSpecIntent(cluster, repo, "", nil, nil,
+			"--type=full --archive-check=y --start-fast")
+		assert.NilError(t, err)
+		assert.Equal(t, commandOpts(spec), "--stanza=db --repo=1"+
+			" --type=full --archive-check=y --start-fast --compress-type=lz4")
+
+		repo.BackupOptions.CompressLevel = initialize.Int32(20)
+		_, err = generateBackupJobSpecIntent(cluster, repo, "", nil, nil)
+		assert.ErrorContains(t, err, "compressLevel 20")
+	})
 }
 
 func TestGenerateRepoHostIntent(t *testing.T) {
@@ -2767,13 +3289,52 @@ func TestGenerateRepoHostIntent(t *testing.T) {
 		assert.NilError(t, err)
 		assert.Equal(t, *sts.Spec.Replicas, int32(0))
 	})
-}
 
-func TestGenerateRestoreJobIntent(t *testing.T) {
-	_, cc := setupKubernetes(t)
-	require.ParallelCapacity(t, 0)
+	t.Run("Scheduling", func(t *testing.T) {
+		constraints := make([]corev1.TopologySpreadConstraint, 1, 10)
+		constraints[0] = corev1.TopologySpreadConstraint{
+			MaxSkew: 1, TopologyKey: "zone", WhenUnsatisfiable: corev1.DoNotSchedule,
+		}
 
-	r := Reconciler{
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Spec.Backups.PGBackRest.RepoHost = &v1beta1.PGBackRestRepoHost{
+			Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+						MatchExpressions: []corev1.NodeSelectorRequirement{{
+							Key: "disktype", Operator: corev1.NodeSelectorOpExists,
+						}},
+					}},
+				},
+			}},
+			NodeSelector:              map[string]string{"disktype": "ssd"},
+			PriorityClassName:         initialize.String("some-priority-class"),
+			Tolerations:               []corev1.Toleration{{Key: "backups"}},
+			TopologySpreadConstraints: constraints,
+		}
+		repoHost := cluster.Spec.Backups.PGBackRest.RepoHost
+
+		sts, err := r.generateRepoHostIntent(cluster, "", &RepoResources{}, &observedInstances{})
+		assert.NilError(t, err)
+
+		spec := sts.Spec.Template.Spec
+		assert.DeepEqual(t, spec.Affinity, repoHost.Affinity)
+		assert.DeepEqual(t, spec.NodeSelector, repoHost.NodeSelector)
+		assert.Equal(t, spec.PriorityClassName, "some-priority-class")
+		assert.DeepEqual(t, spec.Tolerations, repoHost.Tolerations)
+
+		// The defaults follow the constraints in the spec without changing them.
+		assert.Assert(t, len(spec.TopologySpreadConstraints) > 1)
+		assert.DeepEqual(t, spec.TopologySpreadConstraints[0], constraints[0])
+		assert.DeepEqual(t, constraints[:cap(constraints)][1], corev1.TopologySpreadConstraint{})
+	})
+}
+
+func TestGenerateRestoreJobIntent(t *testing.T) {
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	r := Reconciler{
 		Client: cc,
 	}
 
@@ -2818,6 +3379,10 @@ func TestGenerateRestoreJobIntent(t *testing.T) {
 			Operator: "Exist",
 		}},
 		PriorityClassName: initialize.String("some-priority-class"),
+
+		BackoffLimit:            initialize.Int32(2),
+		ActiveDeadlineSeconds:   initialize.Int64(3600),
+		TTLSecondsAfterFinished: initialize.Int32(300),
 	}
 	cluster := &v1beta1.PostgresCluster{
 		ObjectMeta: metav1.ObjectMeta{
@@ -2874,6 +3439,11 @@ func TestGenerateRestoreJobIntent(t *testing.T) {
 				})
 			})
 			t.Run("Spec", func(t *testing.T) {
+				t.Run("Limits", func(t *testing.T) {
+					assert.Equal(t, *job.Spec.BackoffLimit, int32(2))
+					assert.Equal(t, *job.Spec.ActiveDeadlineSeconds, int64(3600))
+					assert.Equal(t, *job.Spec.TTLSecondsAfterFinished, int32(300))
+				})
 				t.Run("Template", func(t *testing.T) {
 					t.Run("ObjectMeta", func(t *testing.T) {
 						t.Run("Annotations", func(t *testing.T) {
@@ -2972,6 +3542,43 @@ func TestGenerateRestoreJobIntent(t *testing.T) {
 			})
 		})
 	}
+
+	t.Run("BackupJobs", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Jobs = &v1beta1.BackupJobs{
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			},
+			PriorityClassName: initialize.String("backups"),
+			Affinity:          &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}},
+			Tolerations:       []corev1.Toleration{{Key: "backups"}},
+			NodeSelector:      map[string]string{"workload": "backups"},
+		}
+
+		// The data source has nothing to say about placement.
+		job := &batchv1.Job{}
+		assert.NilError(t, r.generateRestoreJobIntent(cluster, configHash, instanceName,
+			cmd, volumeMounts, volumes, &v1beta1.PostgresClusterDataSource{}, job))
+
+		spec := job.Spec.Template.Spec
+		assert.DeepEqual(t, spec.Containers[0].Resources, cluster.Spec.Backups.PGBackRest.Jobs.Resources)
+		assert.Equal(t, spec.PriorityClassName, "backups")
+		assert.DeepEqual(t, spec.Affinity, cluster.Spec.Backups.PGBackRest.Jobs.Affinity)
+		assert.DeepEqual(t, spec.Tolerations, cluster.Spec.Backups.PGBackRest.Jobs.Tolerations)
+		assert.DeepEqual(t, spec.NodeSelector, map[string]string{"workload": "backups"})
+
+		// The data source takes precedence.
+		job = &batchv1.Job{}
+		assert.NilError(t, r.generateRestoreJobIntent(cluster, configHash, instanceName,
+			cmd, volumeMounts, volumes, dataSource, job))
+
+		spec = job.Spec.Template.Spec
+		assert.DeepEqual(t, spec.Containers[0].Resources, dataSource.Resources)
+		assert.Equal(t, spec.PriorityClassName, "some-priority-class")
+		assert.DeepEqual(t, spec.Affinity, dataSource.Affinity)
+		assert.DeepEqual(t, spec.Tolerations, dataSource.Tolerations)
+		assert.DeepEqual(t, spec.NodeSelector, map[string]string{"workload": "backups"})
+	})
 }
 
 func TestObserveRestoreEnv(t *testing.T) {
@@ -3787,3 +4394,1457 @@ func TestSetScheduledJobStatus(t *testing.T) {
 		assert.Assert(t, len(postgresCluster.Status.PGBackRest.ScheduledBackups) == 0)
 	})
 }
+
+func TestReconcileRepoVerification(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{Recorder: recorder}
+
+	cluster := fakePostgresCluster("hippocluster", "verify", "hippouid", false)
+	cluster.Spec.Backups.PGBackRest.Repos[0].VerifySchedule = initialize.String("@daily")
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1"}, {Name: "repo2"}},
+	}
+
+	verifyJob := func(name, repo string, condition batchv1.JobConditionType,
+		finished time.Time) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: naming.PGBackRestCronJobLabels(cluster.Name, repo, verify),
+			},
+			Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+				Type:               condition,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(finished),
+			}}},
+		}
+	}
+	now := time.Now()
+
+	t.Run("NoJobs", func(t *testing.T) {
+		r.reconcileRepoVerification(cluster, nil)
+		assert.Assert(t, cluster.Status.PGBackRest.Repos[0].Verified == nil)
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		r.reconcileRepoVerification(cluster, []*batchv1.Job{
+			verifyJob("old", "repo1", batchv1.JobComplete, now.Add(-time.Hour)),
+			verifyJob("new", "repo1", batchv1.JobFailed, now),
+		})
+
+		assert.DeepEqual(t, cluster.Status.PGBackRest.Repos[0].Verified, initialize.Bool(false))
+		assert.Assert(t, cluster.Status.PGBackRest.Repos[1].Verified == nil)
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.HasPrefix(<-recorder.Events, "Warning RepoVerifyFailed "))
+
+		// No additional event when the repo is already known to have errors.
+		r.reconcileRepoVerification(cluster, []*batchv1.Job{
+			verifyJob("new", "repo1", batchv1.JobFailed, now),
+		})
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Succeeded", func(t *testing.T) {
+		r.reconcileRepoVerification(cluster, []*batchv1.Job{
+			verifyJob("new", "repo1", batchv1.JobFailed, now),
+			verifyJob("newer", "repo1", batchv1.JobComplete, now.Add(time.Hour)),
+			{ObjectMeta: metav1.ObjectMeta{
+				Name:   "running",
+				Labels: naming.PGBackRestCronJobLabels(cluster.Name, "repo1", verify),
+			}},
+		})
+
+		assert.DeepEqual(t, cluster.Status.PGBackRest.Repos[0].Verified, initialize.Bool(true))
+	})
+
+	t.Run("Unscheduled", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Repos[0].VerifySchedule = nil
+
+		r.reconcileRepoVerification(cluster, nil)
+		assert.Assert(t, cluster.Status.PGBackRest.Repos[0].Verified == nil)
+	})
+}
+
+func TestReconcileRepoSync(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{Recorder: recorder}
+
+	cluster := fakePostgresCluster("hippocluster", "sync", "hippouid", false)
+	cluster.Spec.Backups.PGBackRest.Repos[0].Sync = &v1beta1.PGBackRestRepoSync{
+		Schedule: "@hourly",
+	}
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1"}, {Name: "repo2"}},
+	}
+
+	now := time.Now().Truncate(time.Second)
+	syncJob := func(name string, condition batchv1.JobConditionType,
+		started, finished time.Time) *batchv1.Job {
+		start := metav1.NewTime(started)
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: naming.PGBackRestCronJobLabels(cluster.Name, "repo1", repoSync),
+			},
+			Status: batchv1.JobStatus{
+				StartTime: &start,
+				Conditions: []batchv1.JobCondition{{
+					Type:               condition,
+					Status:             corev1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(finished),
+				}},
+			},
+		}
+	}
+
+	t.Run("NoJobs", func(t *testing.T) {
+		r.reconcileRepoSync(cluster, nil)
+		assert.Assert(t, cluster.Status.PGBackRest.Repos[0].Sync == nil)
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Succeeded", func(t *testing.T) {
+		r.reconcileRepoSync(cluster, []*batchv1.Job{
+			syncJob("old", batchv1.JobComplete, now.Add(-3*time.Hour), now.Add(-2*time.Hour)),
+			syncJob("new", batchv1.JobComplete, now.Add(-time.Hour), now.Add(-50*time.Minute)),
+		})
+
+		status := cluster.Status.PGBackRest.Repos[0].Sync
+		assert.Assert(t, status != nil)
+		assert.Assert(t, !status.Failed)
+		assert.Equal(t, status.LastSyncTime.Time, now.Add(-time.Hour))
+		assert.DeepEqual(t, status.LagSeconds, initialize.Int64(600))
+		assert.Assert(t, cluster.Status.PGBackRest.Repos[1].Sync == nil)
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		// The Job of the last successful copy is gone.
+		r.reconcileRepoSync(cluster, []*batchv1.Job{
+			syncJob("newer", batchv1.JobFailed, now.Add(-10*time.Minute), now),
+		})
+
+		status := cluster.Status.PGBackRest.Repos[0].Sync
+		assert.Assert(t, status.Failed)
+		assert.Equal(t, status.LastSyncTime.Time, now.Add(-time.Hour))
+		assert.DeepEqual(t, status.LagSeconds, initialize.Int64(600))
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.HasPrefix(<-recorder.Events, "Warning RepoSyncFailed "))
+
+		// No additional event while copies keep failing.
+		r.reconcileRepoSync(cluster, []*batchv1.Job{
+			syncJob("newer", batchv1.JobFailed, now.Add(-10*time.Minute), now),
+		})
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Unscheduled", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Repos[0].Sync = nil
+
+		r.reconcileRepoSync(cluster, nil)
+		assert.Assert(t, cluster.Status.PGBackRest.Repos[0].Sync == nil)
+	})
+}
+
+func TestGenerateRepoSyncJobSpecIntent(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+
+	repo := v1beta1.PGBackRestRepo{
+		Name:   "repo1",
+		Volume: &v1beta1.RepoPVC{},
+		Sync: &v1beta1.PGBackRestRepoSync{
+			Schedule: "@hourly",
+			Image:    "some-rclone",
+			S3: v1beta1.RepoSyncS3{
+				Bucket: "west", Endpoint: "s3.us-west-2.amazonaws.com", Region: "us-west-2",
+			},
+			CredentialsSecret: &corev1.LocalObjectReference{Name: "aws"},
+		},
+	}
+
+	t.Run("Volume", func(t *testing.T) {
+		spec, err := generateRepoSyncJobSpecIntent(cluster, repo, "some-pvc", "some-sa", nil, nil)
+		assert.NilError(t, err)
+
+		pod := spec.Template.Spec
+		assert.Equal(t, pod.ServiceAccountName, "some-sa")
+		assert.Equal(t, pod.RestartPolicy, corev1.RestartPolicyNever)
+		assert.DeepEqual(t, pod.AutomountServiceAccountToken, initialize.Bool(false))
+		assert.Equal(t, len(pod.Containers), 1)
+
+		container := pod.Containers[0]
+		assert.Equal(t, container.Name, "repo-sync")
+		assert.Equal(t, container.Image, "some-rclone")
+		assert.DeepEqual(t, container.Command, pgbackrest.SyncCommand())
+		assert.DeepEqual(t, container.EnvFrom, []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "aws"},
+			},
+		}})
+		assert.DeepEqual(t, container.SecurityContext.RunAsUser, initialize.Int64(26))
+
+		// The repository is read from its volume on the node of a repo host pod.
+		assert.DeepEqual(t, pod.Volumes[0].PersistentVolumeClaim,
+			&corev1.PersistentVolumeClaimVolumeSource{ClaimName: "some-pvc", ReadOnly: true})
+		assert.DeepEqual(t, container.VolumeMounts[0], corev1.VolumeMount{
+			Name: "repo1", MountPath: "/pgbackrest/repo1", ReadOnly: true,
+		})
+		assert.DeepEqual(t, pod.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+			[]corev1.PodAffinityTerm{{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: naming.PGBackRestDedicatedLabels("hippo"),
+				},
+				TopologyKey: "kubernetes.io/hostname",
+			}})
+	})
+
+	t.Run("OpenShift", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.OpenShift = initialize.Bool(true)
+
+		spec, err := generateRepoSyncJobSpecIntent(cluster, repo, "some-pvc", "some-sa", nil, nil)
+		assert.NilError(t, err)
+		assert.Assert(t, spec.Template.Spec.Containers[0].SecurityContext.RunAsUser == nil)
+	})
+
+	t.Run("S3", func(t *testing.T) {
+		repo := *repo.DeepCopy()
+		repo.Volume = nil
+		repo.S3 = &v1beta1.RepoS3{Bucket: "east", Endpoint: "e", Region: "us-east-1"}
+
+		spec, err := generateRepoSyncJobSpecIntent(cluster, repo, "", "some-sa", nil, nil)
+		assert.NilError(t, err)
+		assert.Assert(t, spec.Template.Spec.Affinity == nil)
+		for _, volume := range spec.Template.Spec.Volumes {
+			assert.Assert(t, volume.PersistentVolumeClaim == nil)
+		}
+	})
+
+	t.Run("NoImage", func(t *testing.T) {
+		t.Setenv("RELATED_IMAGE_RCLONE", "")
+		repo := *repo.DeepCopy()
+		repo.Sync.Image = ""
+
+		_, err := generateRepoSyncJobSpecIntent(cluster, repo, "some-pvc", "some-sa", nil, nil)
+		assert.ErrorContains(t, err, "no image")
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		repo := *repo.DeepCopy()
+		repo.Volume = nil
+		repo.GCS = &v1beta1.RepoGCS{Bucket: "g"}
+
+		_, err := generateRepoSyncJobSpecIntent(cluster, repo, "", "some-sa", nil, nil)
+		assert.ErrorContains(t, err, "not on a volume or in S3")
+	})
+}
+
+func TestReconcileBackupLifecycle(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{Recorder: recorder}
+
+	cluster := fakePostgresCluster("hippocluster", "lifecycle", "hippouid", false)
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{}
+
+	now := time.Now().Truncate(time.Second)
+	backupJob := func(name string, labels map[string]string) *batchv1.Job {
+		started := metav1.NewTime(now.Add(-time.Hour))
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Status:     batchv1.JobStatus{StartTime: &started},
+		}
+	}
+	finish := func(job *batchv1.Job, condition batchv1.JobConditionType,
+		finished time.Time) *batchv1.Job {
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type:               condition,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(finished),
+		}}
+		return job
+	}
+
+	scheduled := backupJob("scheduled", naming.PGBackRestCronJobLabels(cluster.Name, "repo1", full))
+	manual := backupJob("manual", naming.PGBackRestBackupJobLabels(cluster.Name, "repo1",
+		naming.BackupManual))
+	verifyJob := backupJob("verify", naming.PGBackRestCronJobLabels(cluster.Name, "repo1", verify))
+
+	t.Run("Started", func(t *testing.T) {
+		r.reconcileBackupLifecycle(cluster, []*batchv1.Job{scheduled, verifyJob})
+
+		assert.DeepEqual(t, cluster.Status.PGBackRest.RunningBackups, []string{"scheduled"})
+		assert.Assert(t, cluster.Status.PGBackRest.LastBackup == nil)
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionLastBackupSucceeded) == nil)
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, <-recorder.Events,
+			`Normal BackupStarted Started full backup Job "scheduled" for "repo1"`)
+
+		// No additional event while the Job is running.
+		r.reconcileBackupLifecycle(cluster, []*batchv1.Job{scheduled, verifyJob})
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Completed", func(t *testing.T) {
+		finish(scheduled, batchv1.JobComplete, now)
+		r.reconcileBackupLifecycle(cluster, []*batchv1.Job{scheduled})
+
+		assert.Equal(t, len(cluster.Status.PGBackRest.RunningBackups), 0)
+		assert.DeepEqual(t, cluster.Status.PGBackRest.LastBackup,
+			&v1beta1.PGBackRestLastBackupStatus{
+				JobName: "scheduled", RepoName: "repo1", Type: full, Succeeded: true,
+				StartTime:  scheduled.Status.StartTime,
+				FinishTime: &scheduled.Status.Conditions[0].LastTransitionTime,
+			})
+		assert.Equal(t, <-recorder.Events,
+			`Normal BackupCompleted Completed full backup Job "scheduled" for "repo1"`)
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionLastBackupSucceeded)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.Reason, "BackupCompleted")
+		assert.Assert(t, strings.Contains(condition.Message, now.UTC().Format(time.RFC3339)))
+
+		// No additional event for the same Job.
+		r.reconcileBackupLifecycle(cluster, []*batchv1.Job{scheduled})
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		r.reconcileBackupLifecycle(cluster, []*batchv1.Job{
+			scheduled, finish(manual, batchv1.JobFailed, now.Add(time.Minute)),
+		})
+
+		assert.Equal(t, cluster.Status.PGBackRest.LastBackup.JobName, "manual")
+		assert.Assert(t, !cluster.Status.PGBackRest.LastBackup.Succeeded)
+		assert.Equal(t, <-recorder.Events,
+			`Warning BackupFailed Failed manual backup Job "manual" for "repo1"`)
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionLastBackupSucceeded)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "BackupFailed")
+	})
+
+	t.Run("OlderJob", func(t *testing.T) {
+		// The failed Job is gone, but the older Job is not reported again.
+		r.reconcileBackupLifecycle(cluster, []*batchv1.Job{scheduled})
+
+		assert.Equal(t, cluster.Status.PGBackRest.LastBackup.JobName, "manual")
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+}
+
+func TestReconcileBackupJobHistory(t *testing.T) {
+	ctx := context.Background()
+	_, tClient := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	r := &Reconciler{Client: tClient, Owner: client.FieldOwner(t.Name())}
+	ns := setupNamespace(t, tClient)
+
+	cluster := fakePostgresCluster("hippocluster", ns.GetName(), "hippouid", false)
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		ManualBackup: &v1beta1.PGBackRestJobStatus{ID: "current"},
+	}
+
+	now := time.Now()
+	backupJob := func(name string, labels map[string]string, manualID string,
+		condition batchv1.JobConditionType, finished time.Time) *batchv1.Job {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: ns.GetName(), Labels: labels,
+				Annotations: map[string]string{naming.PGBackRestBackup: manualID},
+			},
+			Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers:    []corev1.Container{{Name: "pgbackrest", Image: "pgbackrest"}},
+				RestartPolicy: corev1.RestartPolicyNever,
+			}}},
+		}
+		assert.NilError(t, tClient.Create(ctx, job))
+
+		// The status is only needed in memory.
+		if condition != "" {
+			job.Status.Conditions = []batchv1.JobCondition{{
+				Type: condition, Status: corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(finished),
+			}}
+		}
+		return job
+	}
+
+	scheduled := naming.PGBackRestCronJobLabels(cluster.Name, "repo1", "full")
+	manual := naming.PGBackRestBackupJobLabels(cluster.Name, "repo1", naming.BackupManual)
+	replicas := naming.PGBackRestBackupJobLabels(cluster.Name, "repo1", naming.BackupReplicaCreate)
+	verification := naming.PGBackRestCronJobLabels(cluster.Name, "repo1", verify)
+
+	jobs := []*batchv1.Job{
+		backupJob("succeeded-old", scheduled, "", batchv1.JobComplete, now.Add(-3*time.Hour)),
+		backupJob("succeeded-new", scheduled, "", batchv1.JobComplete, now.Add(-1*time.Hour)),
+		backupJob("failed", scheduled, "", batchv1.JobFailed, now.Add(-2*time.Hour)),
+		backupJob("running", scheduled, "", "", now),
+		backupJob("replica-create", replicas, "", batchv1.JobComplete, now.Add(-5*time.Hour)),
+		backupJob("manual-previous", manual, "previous", batchv1.JobComplete, now.Add(-4*time.Hour)),
+		backupJob("manual-current", manual, "current", batchv1.JobFailed, now.Add(-6*time.Hour)),
+		backupJob("verify", verification, "", batchv1.JobFailed, now.Add(-7*time.Hour)),
+	}
+
+	remaining := func(t *testing.T) []string {
+		var names []string
+		for _, job := range jobs {
+			err := tClient.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+			if err == nil {
+				names = append(names, job.Name)
+			} else {
+				assert.Assert(t, apierrors.IsNotFound(err))
+			}
+		}
+		return names
+	}
+
+	t.Run("NoLimit", func(t *testing.T) {
+		assert.NilError(t, r.reconcileBackupJobHistory(ctx, cluster, jobs))
+		assert.Equal(t, len(remaining(t)), len(jobs))
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.JobHistoryLimit = &v1beta1.PGBackRestJobHistoryLimit{
+			Successful: initialize.Int32(1),
+			Failed:     initialize.Int32(0),
+		}
+
+		assert.NilError(t, r.reconcileBackupJobHistory(ctx, cluster, jobs))
+
+		// Deleted Jobs linger while their Pods are deleted in the background.
+		assert.NilError(t, wait.Poll(time.Second/2, Scale(time.Second*10), func() (bool, error) {
+			return len(remaining(t)) == 5, nil
+		}))
+		assert.DeepEqual(t, remaining(t), []string{
+			"succeeded-new", "running", "replica-create", "manual-current", "verify",
+		})
+
+		// Jobs that are already gone are not an error.
+		assert.NilError(t, r.reconcileBackupJobHistory(ctx, cluster, jobs))
+	})
+}
+
+func TestRestoreValidations(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.UID, cluster.Generation = "some-uid", 2
+	now := time.Now()
+
+	var none *restoreValidations
+	none.remember(cluster, "one", now, false)
+	assert.Assert(t, !none.remembered(cluster, "one", now))
+
+	v := new(restoreValidations)
+	assert.Assert(t, !v.remembered(cluster, "one", now))
+
+	v.remember(cluster, "one", now, false)
+	assert.Assert(t, v.remembered(cluster, "one", now))
+	assert.Assert(t, !v.remembered(cluster, "two", now), "expected a new restore ID to be checked")
+	assert.Assert(t, !v.remembered(cluster, "one", now.Add(restoreRecheckInterval)),
+		"expected to check again after an interval")
+
+	changed := cluster.DeepCopy()
+	changed.Generation = 3
+	assert.Assert(t, !v.remembered(changed, "one", now), "expected new options to be checked")
+
+	v.remember(cluster, "one", now, true)
+	assert.Assert(t, !v.remembered(cluster, "one", now))
+}
+
+func TestReconcilePGBackRestConfigInvalidGlobal(t *testing.T) {
+	ctx := context.Background()
+	_, tClient := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{Client: tClient, Owner: client.FieldOwner(t.Name()), Recorder: recorder}
+	ns := setupNamespace(t, tClient)
+
+	cluster := fakePostgresCluster("hippocluster", ns.Name, "hippouid", false)
+	cluster.Spec.Backups.PGBackRest.Global = map[string]string{
+		"log-path":             "/tmp",
+		"repo1-retention-full": "2",
+	}
+
+	assert.NilError(t, r.reconcilePGBackRestConfig(ctx, cluster, "", "hash", "", "", nil))
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, <-recorder.Events, "Warning InvalidPGBackRestGlobal "+
+		"Invalid pgBackRest global configuration in spec.backups.pgbackrest.global:"+
+		" options managed by PGO cannot be set: log-path")
+
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionPGBackRestGlobalValid)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	assert.Equal(t, condition.Reason, "ManagedOptions")
+	assert.Assert(t, strings.Contains(condition.Message, "spec.backups.pgbackrest.global"))
+
+	// The configuration is not generated.
+	config := &corev1.ConfigMap{ObjectMeta: naming.PGBackRestConfig(cluster)}
+	err := tClient.Get(ctx, client.ObjectKeyFromObject(config), config)
+	assert.Assert(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+
+	// The condition goes away when the option is removed.
+	delete(cluster.Spec.Backups.PGBackRest.Global, "log-path")
+	assert.NilError(t, r.reconcilePGBackRestConfig(ctx, cluster, "", "hash", "", "", nil))
+	assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions, ConditionPGBackRestGlobalValid) == nil)
+
+	assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(config), config))
+	assert.Assert(t, strings.Contains(config.Data[pgbackrest.CMInstanceKey], "repo1-retention-full = 2"))
+}
+
+func TestReconcileCloudBasedDataSourceInvalidGlobal(t *testing.T) {
+	ctx := context.Background()
+
+	scheme, err := pgoruntime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cc := &applyRecorder{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+	recorder := events.NewRecorder(t, scheme)
+	r := &Reconciler{Client: cc, Recorder: recorder}
+
+	cluster := fakePostgresCluster("hippocluster", "restore", "hippouid", false)
+	cluster.Status.StartupInstance = "hippocluster-instance1-abcd"
+	cluster.Status.StartupInstanceSet = cluster.Spec.InstanceSets[0].Name
+	cluster.Spec.DataSource = &v1beta1.DataSource{
+		PGBackRest: &v1beta1.PGBackRestDataSource{
+			Global: map[string]string{"pg1-path": "/elsewhere"},
+			Repo: v1beta1.PGBackRestRepo{
+				Name: "repo1", S3: &v1beta1.RepoS3{Bucket: "bucket"},
+			},
+			Stanza: "elephant",
+		},
+	}
+
+	assert.NilError(t, r.reconcileCloudBasedDataSource(ctx, cluster,
+		cluster.Spec.DataSource.PGBackRest, "hash", nil))
+
+	// Nothing is written for the restore.
+	assert.Equal(t, len(cc.patched), 0)
+
+	// The real cluster reports the data source.
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionPGBackRestGlobalValid)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	assert.Assert(t, strings.Contains(condition.Message, "spec.dataSource.pgbackrest.global"))
+	assert.Assert(t, strings.Contains(condition.Message, "pg1-path"))
+
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, recorder.Events[0].Reason, "InvalidPGBackRestGlobal")
+}
+
+func TestReconcilePGBackRestConfigInvalidWebIdentity(t *testing.T) {
+	ctx := context.Background()
+	_, tClient := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{Client: tClient, Owner: client.FieldOwner(t.Name()), Recorder: recorder}
+	ns := setupNamespace(t, tClient)
+
+	cluster := fakePostgresCluster("hippocluster", ns.Name, "hippouid", false)
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{
+		Name: "repo1",
+		S3: &v1beta1.RepoS3{
+			Bucket: "b", Endpoint: "e", Region: "r", KeyType: "web-id",
+		},
+	}}
+
+	assert.NilError(t, r.reconcilePGBackRestConfig(ctx, cluster, "", "hash", "", "", nil))
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, <-recorder.Events, "Warning InvalidWebIdentity "+
+		"Invalid pgBackRest repository credentials: "+
+		"repositories cannot share a web identity: repo1 uses web-id without a roleARN")
+
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionPGBackRestWebIdentityValid)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+
+	// The condition goes away when the role is specified.
+	cluster.Spec.Backups.PGBackRest.Repos[0].S3.RoleARN = "arn:aws:iam::123:role/hippo"
+	assert.NilError(t, r.reconcilePGBackRestConfig(ctx, cluster, "", "hash", "", "", nil))
+	assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions, ConditionPGBackRestWebIdentityValid) == nil)
+}
+
+func TestReconcilePGBackRestConfigInvalidRepoPath(t *testing.T) {
+	ctx := context.Background()
+	_, tClient := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{Client: tClient, Owner: client.FieldOwner(t.Name()), Recorder: recorder}
+	ns := setupNamespace(t, tClient)
+
+	cluster := fakePostgresCluster("hippocluster", ns.Name, "hippouid", false)
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{
+		Name: "repo1", S3: &v1beta1.RepoS3{Bucket: "b", Endpoint: "e", Region: "r"},
+	}, {
+		Name: "repo2", Path: "/pgbackrest/repo1",
+		S3: &v1beta1.RepoS3{Bucket: "b", Endpoint: "e", Region: "r"},
+	}}
+
+	assert.NilError(t, r.reconcilePGBackRestConfig(ctx, cluster, "", "hash", "", "", nil))
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, <-recorder.Events, "Warning InvalidRepoPath "+
+		"Invalid pgBackRest repository path: "+
+		"invalid repository paths: repo2 is at the same path as repo1")
+
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionPGBackRestRepoPathsValid)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+
+	// The conflicting repository stays at its default path.
+	config := &corev1.ConfigMap{ObjectMeta: naming.PGBackRestConfig(cluster)}
+	assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(config), config))
+	assert.Assert(t, cmp.Contains(config.Data["pgbackrest_instance.conf"],
+		"\nrepo2-path = /pgbackrest/repo2\n"))
+
+	// The condition goes away when the path is unique.
+	cluster.Spec.Backups.PGBackRest.Repos[1].Path = "/hippo"
+	assert.NilError(t, r.reconcilePGBackRestConfig(ctx, cluster, "", "hash", "", "", nil))
+	assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions, ConditionPGBackRestRepoPathsValid) == nil)
+}
+
+func TestReconcilePGBackRestConfigInclude(t *testing.T) {
+	ctx := context.Background()
+	_, tClient := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{Client: tClient, Owner: client.FieldOwner(t.Name()), Recorder: recorder}
+	ns := setupNamespace(t, tClient)
+
+	cluster := fakePostgresCluster("hippocluster", ns.Name, "hippouid", false)
+	cluster.Spec.Backups.PGBackRest.ConfigInclude = &corev1.LocalObjectReference{Name: "extra"}
+
+	config := &corev1.ConfigMap{ObjectMeta: naming.PGBackRestConfig(cluster)}
+	condition := func() *metav1.Condition {
+		return meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionPGBackRestConfigIncludeValid)
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		assert.NilError(t, r.reconcilePGBackRestConfig(ctx, cluster, "", "hash", "", "", nil))
+		assert.Equal(t, <-recorder.Events, "Warning InvalidPGBackRestConfigInclude "+
+			`Invalid pgBackRest configuration include: ConfigMap "extra" does not exist`)
+		assert.Assert(t, condition() != nil)
+		assert.Equal(t, condition().Status, metav1.ConditionFalse)
+
+		// Pods mount the file, so it exists even when empty.
+		assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(config), config))
+		value, ok := config.Data["pgbackrest_user.conf"]
+		assert.Assert(t, ok)
+		assert.Equal(t, value, "")
+	})
+
+	extra := &corev1.ConfigMap{}
+	extra.Namespace, extra.Name = ns.Name, "extra"
+	extra.Data = map[string]string{"extra.conf": "[global]\nlog-path = /elsewhere\n"}
+	assert.NilError(t, tClient.Create(ctx, extra))
+
+	t.Run("Managed", func(t *testing.T) {
+		assert.NilError(t, r.reconcilePGBackRestConfig(ctx, cluster, "", "hash", "", "", nil))
+		assert.Equal(t, <-recorder.Events, "Warning InvalidPGBackRestConfigInclude "+
+			`Invalid pgBackRest configuration include: `+
+			`"extra.conf" sets [global] log-path, which is also set by PGO`)
+		assert.Equal(t, condition().Status, metav1.ConditionFalse)
+
+		assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(config), config))
+		assert.Equal(t, config.Data["pgbackrest_user.conf"], "")
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		extra.Data["extra.conf"] = "[global]\ncompress-level = 3\n"
+		assert.NilError(t, tClient.Update(ctx, extra))
+
+		assert.NilError(t, r.reconcilePGBackRestConfig(ctx, cluster, "", "hash", "", "", nil))
+		assert.Equal(t, len(recorder.Events), 0)
+		assert.Assert(t, condition() == nil)
+
+		assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(config), config))
+		assert.Assert(t, cmp.Contains(config.Data["pgbackrest_user.conf"],
+			"\n# extra.conf\n[global]\ncompress-level = 3\n"))
+	})
+}
+
+func TestObserveRestoreProgress(t *testing.T) {
+	ctx := context.Background()
+
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "restore"}}
+	job.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"controller-uid": "some-uid"},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "ns1", Name: "restore-abcde",
+		Labels: map[string]string{"controller-uid": "some-uid"},
+	}}
+	pod.Status.Phase = corev1.PodRunning
+
+	var calls int
+	var output string
+	r := &Reconciler{
+		Client: fake.NewClientBuilder().WithObjects(pod).Build(),
+		PodExec: func(
+			namespace, name, container string,
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			calls++
+			assert.Equal(t, namespace+"/"+name, "ns1/restore-abcde")
+			assert.Equal(t, container, naming.PGBackRestRestoreContainerName)
+			assert.DeepEqual(t, command,
+				pgbackrest.RestoreProgressCommand(naming.PGBackRestPGDataLogPath))
+
+			_, err := io.WriteString(stdout, output)
+			return err
+		},
+	}
+
+	start := metav1.NewTime(time.Now().Add(-time.Hour))
+	status := &v1beta1.PGBackRestJobStatus{StartTime: &start}
+
+	t.Run("NoLog", func(t *testing.T) {
+		assert.NilError(t, r.observeRestoreProgress(ctx, status, job))
+		assert.Equal(t, calls, 1)
+		assert.Assert(t, status.Progress == nil)
+	})
+
+	t.Run("Restoring", func(t *testing.T) {
+		output = "Restoring 25.50 1048576\n"
+		assert.NilError(t, r.observeRestoreProgress(ctx, status, job))
+		assert.Equal(t, calls, 2)
+
+		assert.Assert(t, status.Progress != nil)
+		assert.Equal(t, status.Progress.Phase, "Restoring")
+		assert.Equal(t, status.Progress.PercentComplete, int32(25))
+		assert.Equal(t, status.Progress.BytesRestored, int64(1048576))
+		assert.Assert(t, status.Progress.LastObservedTime != nil)
+
+		// One hour for 25.5% is about 3.9 hours in total.
+		estimate := status.Progress.EstimatedCompletionTime
+		assert.Assert(t, estimate != nil)
+		assert.Assert(t, estimate.Time.After(start.Add(3*time.Hour)), "got %v", estimate)
+		assert.Assert(t, estimate.Time.Before(start.Add(4*time.Hour)), "got %v", estimate)
+
+		// Nothing happens until the interval passes.
+		output = "Restoring 50.00 2097152\n"
+		assert.NilError(t, r.observeRestoreProgress(ctx, status, job))
+		assert.Equal(t, calls, 2)
+		assert.Equal(t, status.Progress.PercentComplete, int32(25))
+	})
+
+	t.Run("Recovering", func(t *testing.T) {
+		earlier := metav1.NewTime(time.Now().Add(-restoreProgressInterval))
+		status.Progress.LastObservedTime = &earlier
+
+		output = "Recovering 100.00 4194304\n"
+		assert.NilError(t, r.observeRestoreProgress(ctx, status, job))
+		assert.Equal(t, calls, 3)
+
+		assert.Equal(t, status.Progress.Phase, "Recovering")
+		assert.Equal(t, status.Progress.PercentComplete, int32(100))
+		assert.Assert(t, status.Progress.EstimatedCompletionTime == nil)
+	})
+
+	t.Run("NotRunning", func(t *testing.T) {
+		pending := pod.DeepCopy()
+		pending.Status.Phase = corev1.PodPending
+
+		r := *r
+		r.Client = fake.NewClientBuilder().WithObjects(pending).Build()
+
+		status := &v1beta1.PGBackRestJobStatus{}
+		assert.NilError(t, r.observeRestoreProgress(ctx, status, job))
+		assert.Equal(t, calls, 3)
+		assert.Assert(t, status.Progress == nil)
+	})
+}
+
+func TestReconcileExpireDryRun(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+		{Name: "repo1", Volume: &v1beta1.RepoPVC{}},
+		{Name: "repo2", S3: &v1beta1.RepoS3{}},
+		{Name: "repo3", GCS: &v1beta1.RepoGCS{}},
+	}
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{
+			{Name: "repo1", StanzaCreated: true},
+			{Name: "repo2", StanzaCreated: true},
+			{Name: "repo3", StanzaCreated: false},
+		},
+	}
+
+	repoHost := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "ns1", Name: "hippo-repo-host-0",
+		Labels: naming.PGBackRestDedicatedLabels(cluster.Name),
+	}}
+	repoHost.Status.Phase = corev1.PodRunning
+
+	primary := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "ns1", Name: "hippo-instance1-abcd-0",
+		Labels: map[string]string{
+			naming.LabelCluster:     cluster.Name,
+			naming.LabelInstanceSet: "instance1",
+			naming.LabelInstance:    "hippo-instance1-abcd",
+			naming.LabelRole:        naming.RolePatroniLeader,
+		},
+	}}
+	primary.Status.Phase = corev1.PodRunning
+
+	var commands []string
+	var failure error
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{
+		Client:   fake.NewClientBuilder().WithObjects(repoHost, primary).Build(),
+		Recorder: recorder,
+		PodExec: func(
+			namespace, pod, container string,
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			commands = append(commands, pod+"/"+container+": "+strings.Join(command, " "))
+			if failure != nil {
+				return failure
+			}
+			_, err := io.WriteString(stdout,
+				"P00   INFO: [DRY-RUN] repo1: remove expired backup 20230101-000000F\n"+
+					"P00   INFO: [DRY-RUN] repo1: 14-1 remove archive,"+
+					" start = 000000010000000000000001, stop = 000000010000000000000004\n")
+			return err
+		},
+	}
+
+	t.Run("NoAnnotation", func(t *testing.T) {
+		assert.NilError(t, r.reconcileExpireDryRun(ctx, cluster))
+		assert.Equal(t, len(commands), 0)
+		assert.Assert(t, cluster.Status.PGBackRest.ExpireDryRun == nil)
+	})
+
+	t.Run("InvalidOption", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Annotations = map[string]string{naming.PGBackRestExpireDryRun: "one"}
+		cluster.Spec.Backups.PGBackRest.ExpireDryRun = &v1beta1.PGBackRestExpireDryRun{
+			Options: []string{"--repo1-retention-full=2", "--no-dry-run"},
+		}
+
+		assert.NilError(t, r.reconcileExpireDryRun(ctx, cluster))
+		assert.Equal(t, len(commands), 0)
+
+		status := cluster.Status.PGBackRest.ExpireDryRun
+		assert.Assert(t, status != nil)
+		assert.Equal(t, status.ID, "one")
+		assert.Assert(t, status.CompletionTime != nil)
+		assert.Assert(t, cmp.Contains(status.Error, `"--no-dry-run"`))
+		assert.Assert(t, cmp.Contains(<-recorder.Events, "ExpireDryRunFailed"))
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		commands = nil
+		cluster := cluster.DeepCopy()
+		cluster.Annotations = map[string]string{naming.PGBackRestExpireDryRun: "two"}
+		cluster.Spec.Backups.PGBackRest.ExpireDryRun = &v1beta1.PGBackRestExpireDryRun{
+			Options: []string{"--repo1-retention-full=2"},
+		}
+
+		assert.NilError(t, r.reconcileExpireDryRun(ctx, cluster))
+		assert.DeepEqual(t, commands, []string{
+			"hippo-repo-host-0/pgbackrest: pgbackrest expire --dry-run --stanza=db --repo=1" +
+				" --log-level-console=info --repo1-retention-full=2",
+			"hippo-instance1-abcd-0/database: pgbackrest expire --dry-run --stanza=db --repo=2" +
+				" --log-level-console=info --repo1-retention-full=2",
+		})
+
+		status := cluster.Status.PGBackRest.ExpireDryRun
+		assert.Equal(t, status.ID, "two")
+		assert.Equal(t, status.Error, "")
+		assert.Equal(t, len(status.Repos), 2)
+		assert.Equal(t, status.Repos[0].Name, "repo1")
+		assert.DeepEqual(t, status.Repos[0].Backups, []string{"20230101-000000F"})
+		assert.DeepEqual(t, status.Repos[0].Archive, []string{
+			"000000010000000000000001-000000010000000000000004",
+		})
+		assert.Assert(t, cmp.Contains(<-recorder.Events, "ExpireDryRunComplete"))
+
+		// Nothing runs again until the annotation changes.
+		commands = nil
+		assert.NilError(t, r.reconcileExpireDryRun(ctx, cluster))
+		assert.Equal(t, len(commands), 0)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		commands, failure = nil, errors.New("boom")
+		defer func() { failure = nil }()
+
+		cluster := cluster.DeepCopy()
+		cluster.Annotations = map[string]string{naming.PGBackRestExpireDryRun: "three"}
+
+		assert.NilError(t, r.reconcileExpireDryRun(ctx, cluster))
+		assert.Equal(t, len(commands), 1)
+
+		status := cluster.Status.PGBackRest.ExpireDryRun
+		assert.Assert(t, cmp.Contains(status.Error, "repo1: boom"))
+		assert.Assert(t, status.Repos == nil)
+		assert.Assert(t, cmp.Contains(<-recorder.Events, "ExpireDryRunFailed"))
+	})
+
+	t.Run("NoPod", func(t *testing.T) {
+		commands = nil
+		r := *r
+		r.Client = fake.NewClientBuilder().Build()
+
+		cluster := cluster.DeepCopy()
+		cluster.Annotations = map[string]string{naming.PGBackRestExpireDryRun: "four"}
+
+		assert.ErrorContains(t, r.reconcileExpireDryRun(ctx, cluster), "no running Pod")
+		assert.Equal(t, len(commands), 0)
+		assert.Assert(t, cluster.Status.PGBackRest.ExpireDryRun == nil)
+	})
+}
+
+func TestValidateRestore(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := fakePostgresCluster("hippocluster", "validate", "hippouid", false)
+	observed := newObservedInstances(cluster, nil, []corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"status": `{"role":"master"}`},
+			Labels: map[string]string{
+				naming.LabelCluster:  cluster.Name,
+				naming.LabelInstance: "hippocluster-abcd",
+			},
+		},
+	}})
+
+	var calls int
+	var info string
+	r := &Reconciler{
+		PodExec: func(
+			namespace, pod, container string,
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			calls++
+			assert.Equal(t, namespace+"/"+pod, "validate/hippocluster-abcd-0")
+			assert.Equal(t, container, naming.ContainerDatabase)
+			assert.Equal(t, strings.Join(command, " "),
+				"pgbackrest info --stanza=db --repo=1 --output=json")
+
+			_, err := io.WriteString(stdout, info)
+			return err
+		},
+	}
+
+	for _, tt := range []struct {
+		name, info string
+		options    []string
+		databases  []string
+		inPlace    bool
+		calls      int
+		message    string
+	}{
+		{
+			name:    "InvalidTarget",
+			options: []string{"--type=time", "--target=yesterday"},
+			message: `Unable to restore: option --target "yesterday" is not a valid time`,
+		},
+		{
+			name:    "NoBackups",
+			info:    `[{"backup":[]}]`,
+			inPlace: true, calls: 1,
+			message: `Unable to restore: repo "repo1" has no backups to restore`,
+		},
+		{
+			name:    "MissingSet",
+			info:    `[{"backup":[{"label":"20230102-030405F"}]}]`,
+			options: []string{"--set=20230101-030405F"},
+			inPlace: true, calls: 1,
+			message: `Unable to restore: backup set "20230101-030405F" does not exist in repo "repo1"`,
+		},
+		{
+			name:    "Valid",
+			info:    `[{"backup":[{"label":"20230102-030405F"}]}]`,
+			options: []string{"--set=20230102-030405F", "--type=immediate"},
+			inPlace: true, calls: 1,
+		},
+		{
+			name:    "NotInPlace",
+			options: []string{"--set=20230101-030405F"},
+		},
+		{
+			name:      "DatabasesInPlace",
+			databases: []string{"zoo"},
+			inPlace:   true,
+			message:   "Unable to restore: databases can only be selected when restoring into a new cluster",
+		},
+		{
+			name:    "IncludeOptionInPlace",
+			options: []string{"--db-include=zoo"},
+			inPlace: true,
+			message: "Unable to restore: databases can only be selected when restoring into a new cluster",
+		},
+		{
+			name:      "DatabasesNotInPlace",
+			databases: []string{"zoo"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			r.Recorder = recorder
+			calls, info = 0, tt.info
+
+			cluster := cluster.DeepCopy()
+			dataSource := &v1beta1.PostgresClusterDataSource{
+				RepoName: "repo1", Options: tt.options, Databases: tt.databases,
+			}
+
+			valid := r.validateRestore(ctx, cluster, observed, dataSource, nil, tt.inPlace)
+			assert.Equal(t, valid, tt.message == "")
+			assert.Equal(t, calls, tt.calls)
+
+			if tt.message == "" {
+				assert.Equal(t, len(cluster.Status.Conditions), 0)
+				assert.Equal(t, len(recorder.Events), 0)
+				return
+			}
+
+			conditionType := ConditionPostgresDataInitialized
+			if tt.inPlace {
+				conditionType = ConditionPGBackRestRestoreProgressing
+			}
+			condition := meta.FindStatusCondition(cluster.Status.Conditions, conditionType)
+			assert.Assert(t, condition != nil)
+			assert.Equal(t, condition.Status, metav1.ConditionFalse)
+			assert.Equal(t, condition.Reason, ReasonInvalidRestoreOptions)
+			assert.Equal(t, condition.Message, tt.message)
+			assert.Equal(t, <-recorder.Events, "Warning InvalidRestoreOptions "+tt.message)
+		})
+	}
+
+	t.Run("MissingRepo", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(1)
+		r.Recorder = recorder
+
+		cluster := cluster.DeepCopy()
+		dataSource := &v1beta1.PostgresClusterDataSource{RepoName: "repo9"}
+
+		assert.Assert(t, !r.validateRestore(ctx, cluster, observed, dataSource, nil, true))
+		assert.Equal(t, <-recorder.Events, "Warning InvalidRestoreOptions Unable to restore: "+
+			`repo "repo9" is not defined in spec.backups.pgbackrest.repos`)
+	})
+}
+
+// applyRecorder records the objects sent to Patch rather than sending them.
+type applyRecorder struct {
+	client.Client
+	patched []client.Object
+}
+
+func (c *applyRecorder) Patch(
+	_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption,
+) error {
+	c.patched = append(c.patched, obj.DeepCopyObject().(client.Object))
+	return nil
+}
+
+func TestCreateRestoreConfigRepoPath(t *testing.T) {
+	ctx := context.Background()
+
+	scheme, err := pgoruntime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := fakePostgresCluster("hippocluster", "restore", "hippouid", false)
+	cluster.Spec.DataSource = &v1beta1.DataSource{
+		PGBackRest: &v1beta1.PGBackRestDataSource{
+			Global: map[string]string{"repo1-s3-uri-style": "path"},
+			Repo: v1beta1.PGBackRestRepo{
+				Name: "repo1", S3: &v1beta1.RepoS3{Bucket: "bucket"},
+			},
+			Stanza: "elephant",
+		},
+	}
+
+	for _, tt := range []struct{ name, repoPath, expected string }{
+		{name: "Default", expected: "repo1-path = /pgbackrest/repo1"},
+		{name: "Custom", repoPath: "/other/cluster", expected: "repo1-path = /other/cluster"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cc := &applyRecorder{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+			r := &Reconciler{Client: cc, Recorder: record.NewFakeRecorder(1)}
+
+			cluster := cluster.DeepCopy()
+			cluster.Spec.DataSource.PGBackRest.RepoPath = tt.repoPath
+
+			assert.NilError(t, r.createRestoreConfig(ctx, cluster, "hash"))
+			assert.Equal(t, len(cc.patched), 1)
+
+			config := cc.patched[0].(*corev1.ConfigMap).Data[pgbackrest.CMInstanceKey]
+			assert.Assert(t, strings.Contains(config, tt.expected+"\n"), "got:\n%s", config)
+			assert.Assert(t, strings.Contains(config, "repo1-s3-uri-style = path\n"))
+
+			// The spec of the cluster is unchanged.
+			assert.DeepEqual(t, cluster.Spec.DataSource.PGBackRest.Global,
+				map[string]string{"repo1-s3-uri-style": "path"})
+		})
+	}
+}
+
+func TestReconcileReplicaCreateBackupAfterUpgrade(t *testing.T) {
+	ctx := context.Background()
+
+	scheme, err := pgoruntime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := fakePostgresCluster("hippocluster", "upgraded", "hippouid", false)
+	cluster.Status.PostgresVersion = 14
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}},
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		Type: ConditionPostUpgradeBackup, Status: metav1.ConditionFalse, Reason: "Upgraded",
+	})
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		Type: ConditionReplicaRepoReady, Status: metav1.ConditionTrue, Reason: "StanzaCreated",
+	})
+
+	instances := newObservedInstances(cluster, nil, []corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"status": `"role":"master"`},
+			Labels: map[string]string{
+				naming.LabelCluster:  cluster.Name,
+				naming.LabelInstance: "",
+				naming.LabelRole:     naming.RolePatroniLeader,
+			},
+		},
+	}})
+
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "hippo-sa"}}
+	repo := cluster.Spec.Backups.PGBackRest.Repos[0]
+
+	// The info of a repo with a backup of only the database before the upgrade.
+	const info = `[{
+		"backup":[{"database":{"id":1,"repo-key":1},"label":"20230101-000000F"}],
+		"db":[{"id":1},{"id":2}],"name":"db"
+	}]`
+
+	for _, strategy := range []string{"Backup", "Basebackup", "LatestBackup"} {
+		t.Run(strategy, func(t *testing.T) {
+			cc := &applyRecorder{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+			r := &Reconciler{
+				Client: cc,
+				PodExec: func(_, _, _ string, _ io.Reader, stdout, _ io.Writer, command ...string) error {
+					assert.Equal(t, command[1], "info")
+					_, err := io.WriteString(stdout, info)
+					return err
+				},
+			}
+
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Backups.PGBackRest.ReplicaCreate = strategy
+
+			assert.NilError(t, r.reconcileReplicaCreateBackup(ctx, cluster, instances,
+				[]*batchv1.Job{}, sa, "hash", repo))
+
+			// A full backup is taken regardless of how replicas are created.
+			assert.Equal(t, len(cc.patched), 1)
+			job := cc.patched[0].(*batchv1.Job)
+			assert.Assert(t, cmp.Contains(
+				job.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{Name: "COMMAND_OPTS", Value: "--stanza=db --repo=1 --type=full"},
+			))
+
+			condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionPostUpgradeBackup)
+			assert.Assert(t, condition != nil)
+			assert.Equal(t, condition.Status, metav1.ConditionFalse)
+			assert.Equal(t, condition.Reason, "RepoBackupNotComplete")
+			assert.Assert(t, cmp.Contains(condition.Message, "PostgreSQL 14"))
+
+			// The condition is true once the backup completes.
+			job.Status.Conditions = []batchv1.JobCondition{{
+				Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+			}}
+			job.Labels[naming.LabelPGBackRestRepo] = repo.Name
+			assert.NilError(t, r.reconcileReplicaCreateBackup(ctx, cluster, instances,
+				[]*batchv1.Job{job}, sa, "hash", repo))
+
+			condition = meta.FindStatusCondition(cluster.Status.Conditions, ConditionPostUpgradeBackup)
+			assert.Equal(t, condition.Status, metav1.ConditionTrue)
+			assert.Equal(t, condition.Message, "A backup of PostgreSQL 14 exists in repo1")
+		})
+	}
+
+	t.Run("BackupComplete", func(t *testing.T) {
+		cc := &applyRecorder{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+		r := &Reconciler{Client: cc}
+
+		// The status of repos can be reset later, e.g. when the repo changes.
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.ReplicaCreate = "Basebackup"
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type: ConditionPostUpgradeBackup, Status: metav1.ConditionTrue, Reason: "RepoBackupComplete",
+		})
+
+		// Neither the strategy nor the type of backup is overridden any longer.
+		assert.NilError(t, r.reconcileReplicaCreateBackup(ctx, cluster, instances,
+			[]*batchv1.Job{}, sa, "hash", repo))
+		assert.Equal(t, len(cc.patched), 0)
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionPostUpgradeBackup)
+		assert.Equal(t, condition.Reason, "RepoBackupComplete")
+	})
+
+	t.Run("NotUpgraded", func(t *testing.T) {
+		r := &Reconciler{}
+
+		cluster := cluster.DeepCopy()
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionPostUpgradeBackup)
+		cluster.Spec.Backups.PGBackRest.ReplicaCreate = "Basebackup"
+
+		assert.NilError(t, r.reconcileReplicaCreateBackup(ctx, cluster, instances,
+			[]*batchv1.Job{}, sa, "hash", repo))
+		assert.Assert(t,
+			meta.FindStatusCondition(cluster.Status.Conditions, ConditionPostUpgradeBackup) == nil)
+	})
+}
+
+func TestReconcileArchiving(t *testing.T) {
+	ctx := context.Background()
+
+	scheme, err := pgoruntime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := fakePostgresCluster("hippocluster", "archiving", "hippouid", false)
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1", ReplicaCreateBackupComplete: true}},
+	}
+
+	old := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Namespace: cluster.Namespace, Name: "old-backup",
+		CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+	}}
+
+	cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(old.DeepCopy()).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{Client: cc, Recorder: recorder}
+
+	t.Run("NeverPaused", func(t *testing.T) {
+		jobs, err := r.reconcileArchiving(ctx, cluster, []*batchv1.Job{old})
+		assert.NilError(t, err)
+		assert.Equal(t, len(jobs), 1)
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions, ConditionArchiving) == nil)
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Paused", func(t *testing.T) {
+		cluster.Spec.Backups.PGBackRest.PauseArchiving = initialize.Bool(true)
+
+		for i := 0; i < 2; i++ {
+			jobs, err := r.reconcileArchiving(ctx, cluster, []*batchv1.Job{old})
+			assert.NilError(t, err)
+			assert.Equal(t, len(jobs), 1)
+		}
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionArchiving)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "ArchivingPaused")
+
+		assert.Equal(t, len(recorder.Events), 1, "expected only one event")
+		assert.Assert(t, strings.HasPrefix(<-recorder.Events, "Warning ArchivingPaused "))
+	})
+
+	t.Run("Resumed", func(t *testing.T) {
+		cluster.Spec.Backups.PGBackRest.PauseArchiving = initialize.Bool(false)
+
+		jobs, err := r.reconcileArchiving(ctx, cluster, []*batchv1.Job{old})
+		assert.NilError(t, err)
+		assert.Equal(t, len(jobs), 0)
+		assert.Assert(t, apierrors.IsNotFound(
+			cc.Get(ctx, client.ObjectKeyFromObject(old), &batchv1.Job{})), "expected delete")
+		assert.Assert(t, !cluster.Status.PGBackRest.Repos[0].ReplicaCreateBackupComplete)
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionArchiving)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "FullBackupRequired")
+		assert.Assert(t, strings.HasPrefix(<-recorder.Events, "Normal ArchivingResumed "))
+	})
+
+	t.Run("ReplicaCreateBackup", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.ReplicaCreate = "LatestBackup"
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type: ConditionReplicaRepoReady, Status: metav1.ConditionTrue, Reason: "StanzaCreated",
+		})
+
+		instances := newObservedInstances(cluster, nil, []corev1.Pod{{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{"status": `"role":"master"`},
+				Labels: map[string]string{
+					naming.LabelCluster:  cluster.Name,
+					naming.LabelInstance: "",
+					naming.LabelRole:     naming.RolePatroniLeader,
+				},
+			},
+		}})
+
+		applied := &applyRecorder{Client: cc}
+		r := &Reconciler{
+			Client: applied,
+			PodExec: func(string, string, string, io.Reader, io.Writer, io.Writer, ...string) error {
+				t.Fatal("expected no exec; any existing backup is too old")
+				return nil
+			},
+		}
+
+		assert.NilError(t, r.reconcileReplicaCreateBackup(ctx, cluster, instances,
+			nil, &corev1.ServiceAccount{}, "hash", cluster.Spec.Backups.PGBackRest.Repos[0]))
+
+		assert.Equal(t, len(applied.patched), 1)
+		assert.Assert(t, cmp.Contains(
+			applied.patched[0].(*batchv1.Job).Spec.Template.Spec.Containers[0].Env,
+			corev1.EnvVar{Name: "COMMAND_OPTS", Value: "--stanza=db --repo=1 --type=full"},
+		))
+	})
+
+	t.Run("FullBackupRequired", func(t *testing.T) {
+		current := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name: "new-backup", CreationTimestamp: metav1.NewTime(time.Now().Add(time.Minute)),
+		}}
+
+		jobs, err := r.reconcileArchiving(ctx, cluster, []*batchv1.Job{old, current})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, jobs, []*batchv1.Job{current})
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionArchiving)
+		assert.Equal(t, condition.Reason, "FullBackupRequired")
+
+		cluster.Status.PGBackRest.Repos[0].ReplicaCreateBackupComplete = true
+		_, err = r.reconcileArchiving(ctx, cluster, []*batchv1.Job{current})
+		assert.NilError(t, err)
+
+		condition = meta.FindStatusCondition(cluster.Status.Conditions, ConditionArchiving)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.Reason, "FullBackupComplete")
+	})
+}
+
+func TestReconcileRepoInfo(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := fakePostgresCluster("hippocluster", "info", "hippouid", false)
+	observed := newObservedInstances(cluster, nil, []corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"status": `{"role":"master"}`},
+			Labels: map[string]string{
+				naming.LabelCluster:  cluster.Name,
+				naming.LabelInstance: "hippocluster-abcd",
+			},
+		},
+	}})
+
+	var commands []string
+	r := &Reconciler{
+		PodExec: func(
+			namespace, pod, container string,
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Equal(t, namespace+"/"+pod, "info/hippocluster-abcd-0")
+			assert.Equal(t, container, naming.ContainerDatabase)
+			commands = append(commands, strings.Join(command, " "))
+
+			_, err := io.WriteString(stdout, `[{
+				"archive":[{"database":{"id":1},"min":"000000010000000000000001","max":"000000010000000000000009"}],
+				"backup":[
+					{"database":{"id":1},"label":"20230101-000000F","type":"full","timestamp":{"stop":1672531200},"info":{"repository":{"delta":900,"size":900}},
+					 "archive":{"start":"000000010000000000000002","stop":"000000010000000000000002"}},
+					{"database":{"id":1},"label":"20230101-000000F_20230101-010000I","type":"incr","timestamp":{"stop":1672534800},"info":{"repository":{"delta":100,"size":100}}}
+				],
+				"db":[{"id":1}],"name":"db"
+			}]`)
+			return err
+		},
+	}
+
+	t.Run("NoWritableInstance", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}},
+		}
+
+		next, err := r.reconcileRepoInfo(ctx, cluster, newObservedInstances(cluster, nil, nil))
+		assert.NilError(t, err)
+		assert.Equal(t, next, time.Duration(0))
+		assert.Assert(t, cluster.Status.PGBackRest.Repos[0].BackupInfoTime == nil)
+	})
+
+	commands = nil
+	recent := metav1.NewTime(time.Now().Add(-time.Minute))
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{
+			{Name: "repo1", StanzaCreated: true},
+			{Name: "repo2", StanzaCreated: false},
+			{Name: "repo3", StanzaCreated: true, BackupInfoTime: &recent},
+		},
+	}
+
+	next, err := r.reconcileRepoInfo(ctx, cluster, observed)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, commands, []string{
+		"pgbackrest info --stanza=db --repo=1 --output=json",
+	})
+	assert.Assert(t, next > 3*time.Minute && next <= 4*time.Minute, "got %v", next)
+
+	repo1 := cluster.Status.PGBackRest.Repos[0]
+	assert.Assert(t, repo1.BackupInfoTime != nil)
+	assert.Equal(t, repo1.ArchiveMax, "000000010000000000000009")
+	assert.Equal(t, *repo1.LastBackupSize, int64(100))
+	assert.Equal(t, repo1.LastFullBackupTime.UTC().Format(time.RFC3339), "2023-01-01T00:00:00Z")
+	assert.Equal(t, repo1.LastIncrementalBackupTime.UTC().Format(time.RFC3339), "2023-01-01T01:00:00Z")
+	assert.Assert(t, repo1.LastDifferentialBackupTime == nil)
+	assert.Equal(t, repo1.ArchiveMin, "000000010000000000000001")
+	assert.Equal(t, *repo1.BackupsSize, int64(1000))
+	assert.Equal(t, len(repo1.Backups), 2)
+	assert.Equal(t, repo1.Backups[0].Label, "20230101-000000F")
+	assert.Equal(t, repo1.Backups[0].StopTime.UTC().Format(time.RFC3339), "2023-01-01T00:00:00Z")
+	assert.Equal(t, repo1.Backups[1].Type, "incr")
+	assert.Equal(t, repo1.Backups[1].Size, int64(100))
+
+	// Archiving health is not known, so the window ends with the newest backup.
+	assert.Equal(t, len(repo1.RecoveryWindows), 1)
+	assert.Equal(t, repo1.RecoveryWindows[0].Start.UTC().Format(time.RFC3339), "2023-01-01T00:00:00Z")
+	assert.Equal(t, repo1.RecoveryWindows[0].End.UTC().Format(time.RFC3339), "2023-01-01T01:00:00Z")
+
+	assert.Assert(t, cluster.Status.PGBackRest.Repos[1].BackupInfoTime == nil, "no stanza")
+	assert.Assert(t, cluster.Status.PGBackRest.Repos[2].LastBackupSize == nil, "recent")
+
+	// Nothing is read again until the interval has passed.
+	commands = nil
+	_, err = r.reconcileRepoInfo(ctx, cluster, observed)
+	assert.NilError(t, err)
+	assert.Equal(t, len(commands), 0)
+
+	t.Run("ArchivingHealthy", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Status.PGBackRest.Repos[0].BackupInfoTime = nil
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type: ConditionArchivingHealthy, Status: metav1.ConditionTrue, Reason: "Archiving",
+		})
+
+		_, err := r.reconcileRepoInfo(ctx, cluster, observed)
+		assert.NilError(t, err)
+
+		repo1 := cluster.Status.PGBackRest.Repos[0]
+		assert.Equal(t, len(repo1.RecoveryWindows), 1)
+		assert.Equal(t, repo1.RecoveryWindows[0].End, *repo1.BackupInfoTime)
+	})
+}
@@ -62,7 +62,7 @@ func (r *Reconciler) reconcilePGBouncer(
 	if err == nil {
 		err = r.reconcilePGBouncerPodDisruptionBudget(ctx, cluster)
 	}
-	if err == nil {
+	if err == nil && !postgres.ReadOnly(cluster) {
 		err = r.reconcilePGBouncerInPostgreSQL(ctx, cluster, instances, secret)
 	}
 	return err
@@ -315,6 +315,7 @@ func (r *Reconciler) generatePGBouncerService(
 			}
 			servicePort.NodePort = *spec.NodePort
 		}
+		setServiceTrafficPolicies(service, spec)
 	}
 	service.Spec.Ports = []corev1.ServicePort{servicePort}
 
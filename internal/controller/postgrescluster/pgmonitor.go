@@ -30,7 +30,6 @@ import (
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/pgmonitor"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
-	pgpassword "github.com/crunchydata/postgres-operator/internal/postgres/password"
 	"github.com/crunchydata/postgres-operator/internal/util"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -118,11 +117,18 @@ func (r *Reconciler) reconcilePGMonitorExporter(ctx context.Context,
 		}
 	}
 
+	// PostgreSQL does not start when a preloaded library is missing. Look for
+	// the library of the requested statistics extension before using it.
+	if err := r.reconcileStatementStatistics(ctx, cluster, writablePod); err != nil {
+		return err
+	}
+
 	// PostgreSQL is available for writes. Prepare to either add or remove
 	// pgMonitor objects.
 
 	action := func(ctx context.Context, exec postgres.Executor) error {
-		return pgmonitor.EnableExporterInPostgreSQL(ctx, exec, monitoringSecret, exporterDB, setup)
+		return pgmonitor.EnableExporterInPostgreSQL(ctx, exec, monitoringSecret, exporterDB,
+			pgmonitor.StatementStatistics(cluster), setup)
 	}
 
 	if !pgmonitor.ExporterEnabled(cluster) {
@@ -177,6 +183,37 @@ func (r *Reconciler) reconcilePGMonitorExporter(ctx context.Context,
 	return err
 }
 
+// reconcileStatementStatistics records in cluster.Status that pg_stat_monitor
+// can be used when it is requested and its library is in the PostgreSQL image
+// of pod. Until then, the exporter continues to use pg_stat_statements.
+func (r *Reconciler) reconcileStatementStatistics(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, pod *corev1.Pod) error {
+
+	if !pgmonitor.ExporterEnabled(cluster) ||
+		cluster.Spec.Monitoring.PGMonitor.Exporter.StatementStatistics != pgmonitor.PGStatMonitor {
+		cluster.Status.Monitoring.StatementStatistics = ""
+		return nil
+	}
+	if cluster.Status.Monitoring.StatementStatistics == pgmonitor.PGStatMonitor {
+		return nil
+	}
+
+	exec := func(_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+		return r.PodExec(pod.Namespace, pod.Name, naming.ContainerDatabase, stdin, stdout, stderr, command...)
+	}
+	found, err := pgmonitor.Executor(exec).HasPostgreSQLLibrary(ctx, pgmonitor.PGStatMonitor)
+
+	if err == nil && found {
+		cluster.Status.Monitoring.StatementStatistics = pgmonitor.PGStatMonitor
+	}
+	if err == nil && !found {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "MissingLibrary",
+			"The %s library is not in the PostgreSQL image; using %s instead",
+			pgmonitor.PGStatMonitor, pgmonitor.PGStatStatements)
+	}
+	return err
+}
+
 // reconcileMonitoringSecret reconciles the secret containing authentication
 // for monitoring tools
 func (r *Reconciler) reconcileMonitoringSecret(
@@ -223,16 +260,25 @@ func (r *Reconciler) reconcileMonitoringSecret(
 			return nil, err
 		}
 
-		// Generate the SCRAM verifier now and store alongside the plaintext
+		// Generate the verifier now and store alongside the plaintext
 		// password so that later reconciles don't generate it repeatedly.
 		// NOTE(cbandy): We don't have a function to compare a plaintext password
 		// to a SCRAM verifier.
-		verifier, err := pgpassword.NewSCRAMPassword(password).Build()
+		verifier, err := postgres.NewPasswordVerifier(cluster, pgmonitor.MonitoringUser, password)
 		if err != nil {
 			return nil, err
 		}
 		intent.Data["password"] = []byte(password)
 		intent.Data["verifier"] = []byte(verifier)
+	} else if !postgres.PasswordVerifierMatches(cluster, string(existing.Data["verifier"])) {
+		// Keep the password but store it in the current format.
+		verifier, err := postgres.NewPasswordVerifier(cluster,
+			pgmonitor.MonitoringUser, string(existing.Data["password"]))
+		if err != nil {
+			return nil, err
+		}
+		intent.Data["password"] = existing.Data["password"]
+		intent.Data["verifier"] = []byte(verifier)
 	} else {
 		intent.Data["password"] = existing.Data["password"]
 		intent.Data["verifier"] = existing.Data["verifier"]
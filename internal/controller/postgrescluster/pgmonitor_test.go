@@ -35,9 +35,11 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/pgmonitor"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -599,6 +601,19 @@ func TestReconcileMonitoringSecret(t *testing.T) {
 			assert.NilError(t, err)
 			assert.Assert(t, bytes.Equal(actual.Data["password"], existing.Data["password"]))
 		})
+
+		t.Run("PasswordEncryption", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+				PasswordEncryption: "md5",
+			}
+
+			actual, err = reconciler.reconcileMonitoringSecret(ctx, cluster)
+			assert.NilError(t, err)
+			assert.Assert(t, bytes.Equal(actual.Data["password"], existing.Data["password"]))
+			assert.Assert(t, bytes.HasPrefix(actual.Data["verifier"], []byte("md5")),
+				"got %q", actual.Data["verifier"])
+		})
 	})
 }
 
@@ -719,3 +734,56 @@ func TestConfigreExporterTLS(t *testing.T) {
 		`))
 	})
 }
+
+func TestReconcileStatementStatistics(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	var found bool
+	var calls int
+	recorder := events.NewRecorder(t, scheme)
+	reconciler := &Reconciler{
+		Recorder: recorder,
+		PodExec: func(namespace, pod, container string, stdin io.Reader, stdout,
+			stderr io.Writer, command ...string) error {
+			calls++
+			assert.Equal(t, container, naming.ContainerDatabase)
+			if found {
+				_, _ = stdout.Write([]byte("found\n"))
+			}
+			return nil
+		},
+	}
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod"}}
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.Monitoring = &v1beta1.MonitoringSpec{
+		PGMonitor: &v1beta1.PGMonitorSpec{Exporter: &v1beta1.ExporterSpec{
+			StatementStatistics: pgmonitor.PGStatMonitor,
+		}},
+	}
+
+	// Missing library.
+	assert.NilError(t, reconciler.reconcileStatementStatistics(ctx, cluster, pod))
+	assert.Equal(t, calls, 1)
+	assert.Equal(t, cluster.Status.Monitoring.StatementStatistics, "")
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, recorder.Events[0].Reason, "MissingLibrary")
+
+	// Library found.
+	found = true
+	assert.NilError(t, reconciler.reconcileStatementStatistics(ctx, cluster, pod))
+	assert.Equal(t, calls, 2)
+	assert.Equal(t, cluster.Status.Monitoring.StatementStatistics, pgmonitor.PGStatMonitor)
+
+	// Already found; no need to look again.
+	assert.NilError(t, reconciler.reconcileStatementStatistics(ctx, cluster, pod))
+	assert.Equal(t, calls, 2)
+
+	// No longer requested.
+	cluster.Spec.Monitoring.PGMonitor.Exporter.StatementStatistics = pgmonitor.PGStatStatements
+	assert.NilError(t, reconciler.reconcileStatementStatistics(ctx, cluster, pod))
+	assert.Equal(t, calls, 2)
+	assert.Equal(t, cluster.Status.Monitoring.StatementStatistics, "")
+}
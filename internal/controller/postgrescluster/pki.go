@@ -21,10 +21,12 @@ import (
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/pki"
+	"github.com/crunchydata/postgres-operator/internal/util"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
@@ -123,7 +125,11 @@ func (r *Reconciler) reconcileClusterCertificate(
 ) {
 	// if a custom postgrescluster secret is provided, just return it
 	if cluster.Spec.CustomTLSSecret != nil {
-		return cluster.Spec.CustomTLSSecret, nil
+		var err error
+		if util.DefaultMutableFeatureGate.Enabled(util.FIPSValidation) {
+			err = r.checkCustomCertificateFIPS(ctx, cluster, cluster.Spec.CustomTLSSecret)
+		}
+		return cluster.Spec.CustomTLSSecret, err
 	}
 
 	const keyCertificate, keyPrivateKey, rootCA = "tls.crt", "tls.key", "ca.crt"
@@ -188,6 +194,107 @@ func (r *Reconciler) reconcileClusterCertificate(
 	return clusterCertSecretProjection(intent), err
 }
 
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={get}
+
+// checkCustomCertificateFIPS returns an error when the TLS certificate in the
+// custom Secret of projection is not approved by FIPS 186-5. A Warning event
+// explains the problem on cluster. A Secret that does not exist yet passes.
+func (r *Reconciler) checkCustomCertificateFIPS(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	projection *corev1.SecretProjection,
+) error {
+	// Find the key of the custom Secret that is projected as "tls.crt".
+	key := clusterCertFile
+	for _, item := range projection.Items {
+		if item.Path == clusterCertFile {
+			key = item.Key
+		}
+	}
+
+	custom := &corev1.Secret{}
+	custom.Namespace, custom.Name = cluster.Namespace, projection.Name
+	err := errors.WithStack(client.IgnoreNotFound(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(custom), custom)))
+	if err != nil || custom.Data == nil {
+		return err
+	}
+
+	var certificate pki.Certificate
+	err = certificate.UnmarshalText(custom.Data[key])
+	if err == nil {
+		err = certificate.CheckFIPS()
+	}
+	if err != nil {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "CertificateNotFIPSCompliant",
+			"Secret %q key %q: %v", custom.Name, key, err)
+		err = errors.Errorf("custom TLS secret %q is not FIPS compliant: %v",
+			custom.Name, err)
+	}
+	return err
+}
+
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={get}
+// +kubebuilder:rbac:groups="",resources="configmaps",verbs={create,patch}
+
+// reconcileClusterCABundle writes a ConfigMap containing the certificate
+// authority that clients should trust when connecting to cluster. Unlike the
+// Secrets that hold private keys, this ConfigMap can be shared with
+// applications in other namespaces. When a custom certificate is configured,
+// its CA certificate is published instead of the one generated by PGO.
+func (r *Reconciler) reconcileClusterCABundle(
+	ctx context.Context, root *pki.RootCertificateAuthority,
+	cluster *v1beta1.PostgresCluster, certificate *corev1.SecretProjection,
+) error {
+	var bundle []byte
+	var err error
+
+	if cluster.Spec.CustomTLSSecret == nil {
+		bundle, err = root.Certificate.MarshalText()
+		err = errors.WithStack(err)
+	} else {
+		// Find the key of the custom Secret that is projected as "ca.crt".
+		key := rootCertFile
+		for _, item := range certificate.Items {
+			if item.Path == rootCertFile {
+				key = item.Key
+			}
+		}
+
+		custom := &corev1.Secret{}
+		custom.Namespace, custom.Name = cluster.Namespace, certificate.Name
+		err = errors.WithStack(
+			r.Client.Get(ctx, client.ObjectKeyFromObject(custom), custom))
+		bundle = custom.Data[key]
+
+		// The custom Secret may not exist yet. Instances cannot start without
+		// it, so wait for it rather than publish an empty bundle.
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+	}
+
+	intent := &corev1.ConfigMap{ObjectMeta: naming.ClusterCABundle(cluster)}
+	intent.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+
+	intent.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil())
+	intent.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster:            cluster.Name,
+			naming.LabelClusterCertificate: "ca-bundle",
+		})
+	intent.Data = map[string]string{rootCertFile: string(bundle)}
+
+	if err == nil {
+		err = errors.WithStack(r.setControllerReference(cluster, intent))
+	}
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, intent))
+	}
+
+	return err
+}
+
 // +kubebuilder:rbac:groups="",resources="secrets",verbs={get}
 // +kubebuilder:rbac:groups="",resources="secrets",verbs={create,patch}
 
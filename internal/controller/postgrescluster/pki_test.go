@@ -30,6 +30,7 @@ import (
 	"gotest.tools/v3/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -379,6 +380,55 @@ func TestReconcileCerts(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("check cluster CA bundle reconciliation", func(t *testing.T) {
+		root, err := r.reconcileRootCertificate(ctx, cluster1)
+		assert.NilError(t, err)
+
+		t.Run("generated certificate", func(t *testing.T) {
+			projection, err := r.reconcileClusterCertificate(ctx, root, cluster1, primaryService)
+			assert.NilError(t, err)
+			assert.NilError(t, r.reconcileClusterCABundle(ctx, root, cluster1, projection))
+
+			bundle := &corev1.ConfigMap{ObjectMeta: naming.ClusterCABundle(cluster1)}
+			assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(bundle), bundle))
+
+			expected, err := root.Certificate.MarshalText()
+			assert.NilError(t, err)
+			assert.Equal(t, bundle.Data["ca.crt"], string(expected))
+			assert.Equal(t, bundle.Labels[naming.LabelCluster], cluster1.Name)
+			assert.Assert(t, metav1.IsControlledBy(bundle, cluster1))
+		})
+
+		t.Run("custom certificate", func(t *testing.T) {
+			cluster2.Spec.CustomTLSSecret = &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "bundlesecret"},
+				Items: []corev1.KeyToPath{
+					{Key: "server.crt", Path: clusterCertFile},
+					{Key: "server.key", Path: clusterKeyFile},
+					{Key: "authority.crt", Path: rootCertFile},
+				},
+			}
+			projection, err := r.reconcileClusterCertificate(ctx, root, cluster2, primaryService)
+			assert.NilError(t, err)
+
+			// Nothing is published until the custom Secret exists.
+			assert.NilError(t, r.reconcileClusterCABundle(ctx, root, cluster2, projection))
+
+			bundle := &corev1.ConfigMap{ObjectMeta: naming.ClusterCABundle(cluster2)}
+			err = tClient.Get(ctx, client.ObjectKeyFromObject(bundle), bundle)
+			assert.Assert(t, apierrors.IsNotFound(err), "got %#v", err)
+
+			custom := &corev1.Secret{}
+			custom.Namespace, custom.Name = namespace, "bundlesecret"
+			custom.Data = map[string][]byte{"authority.crt": []byte("custom-ca")}
+			assert.NilError(t, tClient.Create(ctx, custom))
+
+			assert.NilError(t, r.reconcileClusterCABundle(ctx, root, cluster2, projection))
+			assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(bundle), bundle))
+			assert.Equal(t, bundle.Data["ca.crt"], "custom-ca")
+		})
+	})
 }
 
 // getCertFromSecret returns a parsed certificate from the named secret
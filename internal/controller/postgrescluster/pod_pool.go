@@ -0,0 +1,179 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+)
+
+const (
+	// podExecPerCluster is how many commands may run at once in the Pods of
+	// one PostgresCluster when PGO_EXEC_PER_CLUSTER is not set.
+	podExecPerCluster = 4
+
+	// podExecTotal is how many commands may run at once across every
+	// PostgresCluster when PGO_EXEC_TOTAL is not set.
+	podExecTotal = 64
+
+	// podExecWait is how long a command waits for its turn when PGO_EXEC_WAIT
+	// is not set.
+	podExecWait = time.Minute
+)
+
+// podExecPool limits how many commands run in Pods at the same time. Each
+// command holds an exec stream to the API server and a connection to a kubelet
+// for as long as it runs, so many clusters reconciling at once can exhaust
+// both. Commands beyond a limit wait for a turn; those that wait too long fail
+// so their reconcile is retried with backoff rather than piling up.
+type podExecPool struct {
+	// cluster returns the PostgresCluster of pod in namespace. Commands in
+	// Pods of the same cluster share its limit.
+	cluster func(namespace, pod string) string
+
+	perCluster int
+	wait       time.Duration
+
+	// total is a semaphore for every command; it is nil when there is no limit.
+	total chan struct{}
+
+	mu       sync.Mutex
+	clusters map[string]*podExecSlots
+}
+
+// podExecSlots is a semaphore for the commands of one cluster. It is removed
+// from its pool when no command holds or waits for it.
+type podExecSlots struct {
+	slots chan struct{}
+	users int
+}
+
+// newPodExecPool returns a pool that allows perCluster commands in each
+// cluster and total commands overall. A limit less than one means no limit. A
+// command fails when it waits longer than wait; zero means wait forever. The
+// cluster of each Pod is read from reader, usually a cache.
+func newPodExecPool(reader client.Reader, perCluster, total int, wait time.Duration) *podExecPool {
+	pool := &podExecPool{
+		perCluster: perCluster,
+		wait:       wait,
+		clusters:   make(map[string]*podExecSlots),
+	}
+	if total > 0 {
+		pool.total = make(chan struct{}, total)
+	}
+
+	pool.cluster = func(namespace, name string) string {
+		pod := &corev1.Pod{}
+		err := reader.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: name}, pod)
+		if cluster := pod.Labels[naming.LabelCluster]; err == nil && cluster != "" {
+			return namespace + "/" + cluster
+		}
+		// Pods that cannot be found are limited on their own.
+		return namespace + "/" + name
+	}
+	return pool
+}
+
+// acquire takes one slot of slots before timeout fires. A nil slots is
+// always available.
+func acquire(slots chan struct{}, timeout <-chan time.Time) bool {
+	if slots == nil {
+		return true
+	}
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timeout:
+		return false
+	}
+}
+
+// release returns one slot to slots.
+func release(slots chan struct{}) {
+	if slots != nil {
+		<-slots
+	}
+}
+
+// slotsFor returns the semaphore of cluster and records one more user of it.
+func (p *podExecPool) slotsFor(cluster string) *podExecSlots {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := p.clusters[cluster]
+	if s == nil {
+		s = &podExecSlots{}
+		if p.perCluster > 0 {
+			s.slots = make(chan struct{}, p.perCluster)
+		}
+		p.clusters[cluster] = s
+	}
+	s.users++
+	return s
+}
+
+// done records one less user of the semaphore of cluster.
+func (p *podExecPool) done(cluster string, s *podExecSlots) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if s.users--; s.users == 0 {
+		delete(p.clusters, cluster)
+	}
+}
+
+// Wrap returns a podExecutor that calls exec when there is room in the pool.
+func (p *podExecPool) Wrap(exec podExecutor) podExecutor {
+	return func(
+		namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		cluster := p.cluster(namespace, pod)
+		slots := p.slotsFor(cluster)
+		defer p.done(cluster, slots)
+
+		var timeout <-chan time.Time
+		if p.wait > 0 {
+			timer := time.NewTimer(p.wait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		// Take a slot of the cluster before one of the pool so that a busy
+		// cluster does not hold slots that other clusters could use.
+		if !acquire(slots.slots, timeout) {
+			return fmt.Errorf("timed out after %v waiting to exec in pod %s/%s: "+
+				"%d commands are running in cluster %s", p.wait, namespace, pod, p.perCluster, cluster)
+		}
+		defer release(slots.slots)
+
+		if !acquire(p.total, timeout) {
+			return fmt.Errorf("timed out after %v waiting to exec in pod %s/%s: "+
+				"%d commands are running", p.wait, namespace, pod, cap(p.total))
+		}
+		defer release(p.total)
+
+		return exec(namespace, pod, container, stdin, stdout, stderr, command...)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+)
+
+func TestPodExecPoolCluster(t *testing.T) {
+	reader := fake.NewClientBuilder().WithObjects(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1", Name: "hippo-00-abcd-0",
+			Labels: map[string]string{naming.LabelCluster: "hippo"},
+		}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1", Name: "unlabeled",
+		}},
+	).Build()
+
+	pool := newPodExecPool(reader, 1, 1, 0)
+	assert.Equal(t, pool.cluster("ns1", "hippo-00-abcd-0"), "ns1/hippo")
+	assert.Equal(t, pool.cluster("ns1", "unlabeled"), "ns1/unlabeled")
+	assert.Equal(t, pool.cluster("ns2", "missing"), "ns2/missing")
+}
+
+func TestPodExecPool(t *testing.T) {
+	// blocking returns an executor that counts the commands running at once
+	// and runs until release is closed.
+	blocking := func(running, most *int32, release chan struct{}) podExecutor {
+		return func(
+			_, _, _ string, _ io.Reader, _, _ io.Writer, _ ...string,
+		) error {
+			n := atomic.AddInt32(running, 1)
+			defer atomic.AddInt32(running, -1)
+
+			for m := atomic.LoadInt32(most); n > m; m = atomic.LoadInt32(most) {
+				if atomic.CompareAndSwapInt32(most, m, n) {
+					break
+				}
+			}
+			<-release
+			return nil
+		}
+	}
+
+	// byPod uses the Pod name as its cluster.
+	byPod := func(_, pod string) string { return pod }
+
+	t.Run("PerCluster", func(t *testing.T) {
+		pool := newPodExecPool(nil, 2, 0, 0)
+		pool.cluster = byPod
+
+		var running, most int32
+		release := make(chan struct{})
+		exec := pool.Wrap(blocking(&running, &most, release))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(2)
+			go func() { defer wg.Done(); assert.Check(t, exec("ns", "a", "c", nil, nil, nil)) }()
+			go func() { defer wg.Done(); assert.Check(t, exec("ns", "b", "c", nil, nil, nil)) }()
+		}
+
+		// Wait for both clusters to reach their limit.
+		for atomic.LoadInt32(&running) < 4 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, atomic.LoadInt32(&running), int32(4))
+
+		close(release)
+		wg.Wait()
+		assert.Equal(t, most, int32(4))
+		assert.Equal(t, len(pool.clusters), 0, "expected idle clusters to be removed")
+	})
+
+	t.Run("Total", func(t *testing.T) {
+		pool := newPodExecPool(nil, 0, 3, 0)
+		pool.cluster = byPod
+
+		var running, most int32
+		release := make(chan struct{})
+		exec := pool.Wrap(blocking(&running, &most, release))
+
+		var wg sync.WaitGroup
+		for _, pod := range []string{"a", "b", "c", "d", "e", "f"} {
+			wg.Add(1)
+			go func(pod string) { defer wg.Done(); assert.Check(t, exec("ns", pod, "c", nil, nil, nil)) }(pod)
+		}
+
+		for atomic.LoadInt32(&running) < 3 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, atomic.LoadInt32(&running), int32(3))
+
+		close(release)
+		wg.Wait()
+		assert.Equal(t, most, int32(3))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		pool := newPodExecPool(nil, 1, 0, 20*time.Millisecond)
+		pool.cluster = byPod
+
+		var running, most int32
+		release := make(chan struct{})
+		exec := pool.Wrap(blocking(&running, &most, release))
+
+		done := make(chan error)
+		go func() { done <- exec("ns", "a", "c", nil, nil, nil) }()
+		for atomic.LoadInt32(&running) < 1 {
+			time.Sleep(time.Millisecond)
+		}
+
+		err := exec("ns", "a", "c", nil, nil, nil)
+		assert.ErrorContains(t, err, "timed out after 20ms waiting to exec in pod ns/a")
+
+		// Other clusters are not delayed.
+		assert.NilError(t, pool.Wrap(func(
+			_, _, _ string, _ io.Reader, _, _ io.Writer, _ ...string,
+		) error {
+			return nil
+		})("ns", "b", "c", nil, nil, nil))
+
+		close(release)
+		assert.NilError(t, <-done)
+	})
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -36,10 +37,10 @@ import (
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/logging"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
 	"github.com/crunchydata/postgres-operator/internal/pgaudit"
 	"github.com/crunchydata/postgres-operator/internal/postgis"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
-	pgpassword "github.com/crunchydata/postgres-operator/internal/postgres/password"
 	"github.com/crunchydata/postgres-operator/internal/util"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -92,12 +93,14 @@ func (r *Reconciler) generatePostgresUserSecret(
 		intent.Data["verifier"] = nil
 	}
 
-	// When a password has been generated or the verifier is empty,
-	// generate a verifier based on the current password.
+	// When a password has been generated or the verifier is empty or in the
+	// wrong format, generate a verifier based on the current password.
 	// NOTE(cbandy): We don't have a function to compare a plaintext
 	// password to a SCRAM verifier.
-	if len(intent.Data["verifier"]) == 0 {
-		verifier, err := pgpassword.NewSCRAMPassword(string(intent.Data["password"])).Build()
+	if len(intent.Data["verifier"]) == 0 ||
+		!postgres.PasswordVerifierMatches(cluster, string(intent.Data["verifier"])) {
+		verifier, err := postgres.NewPasswordVerifier(cluster,
+			username, string(intent.Data["password"]))
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -228,10 +231,22 @@ func (r *Reconciler) reconcilePostgresDatabases(
 			}
 		}
 	}
+	for _, database := range cluster.Spec.Databases {
+		databases.Insert(string(database.Name))
+	}
+
+	// Gather the extensions that should exist in each database. Those that
+	// the operator does not allow are ignored.
+
+	extensions, disallowed := r.requestedExtensions(cluster)
+	if len(disallowed) > 0 {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "ExtensionNotAllowed",
+			"The operator does not allow these extensions: %s", strings.Join(disallowed, ", "))
+	}
 
 	// Calculate a hash of the SQL that should be executed in PostgreSQL.
 
-	var pgAuditOK, postgisInstallOK bool
+	var pgAuditOK, postgisInstallOK, extensionsOK bool
 	create := func(ctx context.Context, exec postgres.Executor) error {
 		if pgAuditOK = pgaudit.EnableInPostgreSQL(ctx, exec) == nil; !pgAuditOK {
 			// pgAudit can only be enabled after its shared library is loaded,
@@ -254,7 +269,23 @@ func (r *Reconciler) reconcilePostgresDatabases(
 				"Unable to install PostGIS")
 		}
 
-		return postgres.CreateDatabasesInPostgreSQL(ctx, exec, databases.List())
+		err := postgres.CreateDatabasesInPostgreSQL(ctx, exec, databases.List())
+
+		// Extensions are created once their databases exist. One that fails,
+		// perhaps because it is not in the image, is reported and tried again
+		// during a later reconcile.
+		extensionsOK = true
+		for _, database := range cluster.Spec.Databases {
+			name := string(database.Name)
+			if err == nil && len(extensions[name]) > 0 &&
+				postgres.CreateExtensionsInPostgreSQL(ctx, exec, name, extensions[name]) != nil {
+				extensionsOK = false
+				r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "ExtensionsFailed",
+					"Unable to create extensions in database %q", name)
+			}
+		}
+
+		return err
 	}
 
 	revision, err := safeHash32(func(hasher io.Writer) error {
@@ -285,20 +316,128 @@ func (r *Reconciler) reconcilePostgresDatabases(
 		log := logging.FromContext(ctx).WithValues("revision", revision)
 		err = errors.WithStack(create(logging.NewContext(ctx, log), podExecutor))
 	}
-	if err == nil && pgAuditOK && postgisInstallOK {
+	if err == nil && pgAuditOK && postgisInstallOK && extensionsOK {
 		cluster.Status.DatabaseRevision = revision
 	}
 
 	return err
 }
 
+// reconcileForeignServers creates foreign-data wrapper extensions, foreign
+// servers, and user mappings inside of PostgreSQL. The databases and users
+// must already exist.
+func (r *Reconciler) reconcileForeignServers(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) error {
+	const container = naming.ContainerDatabase
+	var podExecutor postgres.Executor
+
+	// Find the PostgreSQL instance that can execute SQL that writes system
+	// catalogs. When there is none, return early.
+	pod, _ := instances.writablePod(container)
+	if pod == nil {
+		return nil
+	}
+
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).WithValues("pod", pod.Name))
+	podExecutor = func(
+		_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		return r.PodExec(pod.Namespace, pod.Name, container, stdin, stdout, stderr, command...)
+	}
+
+	// Gather the user mapping options that are stored in Secrets. When one
+	// is missing, wait for it to be created.
+
+	credentials := make(map[string]map[string]string)
+	for _, database := range cluster.Spec.Databases {
+		for _, server := range database.FDW {
+			for _, mapping := range server.UserMappings {
+				if mapping.Secret == nil || credentials[mapping.Secret.Name] != nil {
+					continue
+				}
+
+				secret := &corev1.Secret{}
+				err := r.Client.Get(ctx, client.ObjectKey{
+					Namespace: cluster.Namespace, Name: mapping.Secret.Name,
+				}, secret)
+
+				if apierrors.IsNotFound(err) {
+					r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "InvalidUserMapping",
+						"Secret %q for user %q of foreign server %q in database %q not found",
+						mapping.Secret.Name, mapping.User, server.Name, database.Name)
+					return nil
+				}
+				if err != nil {
+					return errors.WithStack(err)
+				}
+
+				credentials[mapping.Secret.Name] = make(map[string]string, len(secret.Data))
+				for key, value := range secret.Data {
+					credentials[mapping.Secret.Name][key] = string(value)
+				}
+			}
+		}
+	}
+
+	// Calculate a hash of the SQL that should be executed in PostgreSQL.
+
+	write := func(ctx context.Context, exec postgres.Executor) error {
+		var err error
+		for _, database := range cluster.Spec.Databases {
+			if err == nil && len(database.FDW) > 0 {
+				err = postgres.WriteForeignServersInPostgreSQL(ctx, exec, database, credentials)
+			}
+		}
+		return err
+	}
+
+	revision, err := safeHash32(func(hasher io.Writer) error {
+		// Discard log messages about executing SQL.
+		return write(logging.NewContext(ctx, logging.Discard()), func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			_, err := fmt.Fprint(hasher, command)
+			if err == nil && stdin != nil {
+				_, err = io.Copy(hasher, stdin)
+			}
+			return err
+		})
+	})
+
+	if err == nil && revision == cluster.Status.ForeignServersRevision {
+		// The necessary SQL has already been applied; there's nothing more to do.
+		return nil
+	}
+
+	// Apply the necessary SQL and record its hash in cluster.Status. Include
+	// the hash in any log messages. A wrapper that is not installed in the
+	// image is reported without interrupting the rest of reconciliation.
+
+	if err == nil {
+		log := logging.FromContext(ctx).WithValues("revision", revision)
+		if err := write(logging.NewContext(ctx, log), podExecutor); err != nil {
+			log.Error(err, "unable to write foreign servers")
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, "ForeignServersFailed",
+				"Unable to write foreign servers; check the operator log for details")
+			return nil
+		}
+		cluster.Status.ForeignServersRevision = revision
+	}
+
+	return err
+}
+
 // reconcilePostgresUsers writes the objects necessary to manage users and their
 // passwords in PostgreSQL.
 func (r *Reconciler) reconcilePostgresUsers(
 	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
 ) error {
 	users, secrets, err := r.reconcilePostgresUserSecrets(ctx, cluster)
-	if err == nil {
+
+	// PostgreSQL refuses to change users while the cluster is read-only. Their
+	// Secrets are still written; the passwords in them take effect later.
+	if err == nil && !postgres.ReadOnly(cluster) {
 		err = r.reconcilePostgresUsersInPostgreSQL(ctx, cluster, instances, users, secrets)
 	}
 	if err == nil {
@@ -403,6 +542,7 @@ func (r *Reconciler) reconcilePostgresUserSecrets(
 	}
 
 	// Reconcile each PostgreSQL user in the cluster spec.
+	var movedSecrets map[string]*corev1.Secret
 	for userName, user := range userSpecs {
 		secret := userSecrets[userName]
 
@@ -411,6 +551,14 @@ func (r *Reconciler) reconcilePostgresUserSecrets(
 			// default secret, if any.
 			secret = defaultSecret
 		}
+		if secret == nil && err == nil {
+			// The current secret doesn't exist, so read from the cluster this
+			// one replaces, if any.
+			if movedSecrets == nil {
+				movedSecrets, err = r.movedUserSecrets(ctx, cluster)
+			}
+			secret = movedSecrets[userName]
+		}
 
 		if err == nil {
 			userSecrets[userName], err = r.generatePostgresUserSecret(cluster, user, secret)
@@ -423,6 +571,63 @@ func (r *Reconciler) reconcilePostgresUserSecrets(
 	return specUsers, userSecrets, err
 }
 
+// movedUserSecrets returns the user Secrets of the cluster that cluster
+// replaces, indexed by PostgreSQL user name. It returns an empty map when
+// cluster is not moving from another cluster.
+func (r *Reconciler) movedUserSecrets(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) (map[string]*corev1.Secret, error) {
+	moved := make(map[string]*corev1.Secret)
+
+	key, ok := dataSourceCluster(cluster)
+	if !ok || cluster.Spec.DataSource.PostgresCluster.Move == nil ||
+		!*cluster.Spec.DataSource.PostgresCluster.Move {
+		return moved, nil
+	}
+
+	// Passwords are copied only from a source cluster that exists and allows
+	// clusters in this namespace to replace it. Anyone who can create a
+	// PostgresCluster could otherwise read the passwords of another namespace.
+	source := &v1beta1.PostgresCluster{}
+	err := errors.WithStack(r.Client.Get(ctx, key, source))
+	if apierrors.IsNotFound(err) {
+		return moved, nil
+	}
+	if err == nil && !dataSourceAllowed(source, cluster.Namespace) {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "DataSourceNotAllowed",
+			"PostgresCluster %q in namespace %q does not allow moves to namespace %q; "+
+				"add it to the %q annotation of that cluster", key.Name, key.Namespace,
+			cluster.Namespace, naming.AllowDataSourceNamespaces)
+		return moved, nil
+	}
+	if err != nil {
+		return moved, err
+	}
+
+	secrets := &corev1.SecretList{}
+	selector, err := naming.AsSelector(naming.ClusterPostgresUsers(key.Name))
+	if err == nil {
+		err = errors.WithStack(
+			r.Client.List(ctx, secrets,
+				client.InNamespace(key.Namespace),
+				client.MatchingLabelsSelector{Selector: selector},
+			))
+	}
+
+	// Prefer the current secret of each user over the deprecated default one.
+	deprecated := naming.DeprecatedPostgresUserSecret(source).Name
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		userName := secret.Labels[naming.LabelPostgresUser]
+
+		if moved[userName] == nil || secret.Name != deprecated {
+			moved[userName] = secret
+		}
+	}
+
+	return moved, err
+}
+
 // reconcilePostgresUsersInPostgreSQL creates users inside of PostgreSQL and
 // sets their options and database access as specified.
 func (r *Reconciler) reconcilePostgresUsersInPostgreSQL(
@@ -553,6 +758,26 @@ func (r *Reconciler) reconcilePostgresDataVolume(
 
 	pvc.Spec = instanceSpec.DataVolumeClaimSpec
 
+	// The source of a volume cannot change after the volume is created. Keep
+	// the source of an existing volume, and populate the volume of the first
+	// instance of a new cluster from a VolumeSnapshot when one is requested.
+	for i := range clusterVolumes {
+		if clusterVolumes[i].Name == existingPVCName && pvc.Spec.DataSource == nil {
+			pvc.Spec.DataSource = clusterVolumes[i].Spec.DataSource
+		}
+	}
+	if existingPVCName == "" &&
+		instance.Name == cluster.Status.StartupInstance &&
+		cluster.Spec.DataSource != nil &&
+		cluster.Spec.DataSource.VolumeSnapshot != nil &&
+		!patroni.ClusterBootstrapped(cluster) {
+		pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+			APIGroup: initialize.String(volumeSnapshotGVK.Group),
+			Kind:     volumeSnapshotGVK.Kind,
+			Name:     cluster.Spec.DataSource.VolumeSnapshot.Name,
+		}
+	}
+
 	if err == nil {
 		err = r.handlePersistentVolumeClaimError(cluster,
 			errors.WithStack(r.apply(ctx, pvc)))
@@ -736,6 +961,76 @@ func (r *Reconciler) reconcilePostgresWALVolume(
 	return pvc, err
 }
 
+// +kubebuilder:rbac:groups="",resources="persistentvolumeclaims",verbs={get}
+// +kubebuilder:rbac:groups="",resources="persistentvolumeclaims",verbs={create,delete,patch}
+
+// reconcilePGBackRestSpoolVolume writes the PersistentVolumeClaim for instance's
+// pgBackRest spool volume.
+func (r *Reconciler) reconcilePGBackRestSpoolVolume(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	instanceSpec *v1beta1.PostgresInstanceSetSpec, instance *appsv1.StatefulSet,
+	clusterVolumes []corev1.PersistentVolumeClaim,
+) (*corev1.PersistentVolumeClaim, error) {
+
+	labelMap := map[string]string{
+		naming.LabelCluster:     cluster.Name,
+		naming.LabelInstanceSet: instanceSpec.Name,
+		naming.LabelInstance:    instance.Name,
+		naming.LabelRole:        naming.RolePGBackRestSpool,
+		naming.LabelData:        naming.DataPGBackRest,
+	}
+
+	var pvc *corev1.PersistentVolumeClaim
+	existingPVCName, err := getPGPVCName(labelMap, clusterVolumes)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if existingPVCName != "" {
+		pvc = &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.GetNamespace(),
+			Name:      existingPVCName,
+		}}
+	} else {
+		pvc = &corev1.PersistentVolumeClaim{ObjectMeta: naming.InstancePGBackRestSpoolVolume(instance)}
+	}
+
+	pvc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
+
+	if async := cluster.Spec.Backups.PGBackRest.AsyncArchive; async == nil ||
+		async.SpoolVolumeClaimSpec == nil {
+		// No spool volume is specified; delete the PVC if it exists. The spool
+		// holds only the status of WAL files that were already pushed, so it
+		// can be removed at any time. Check the client cache first using Get.
+		key := client.ObjectKeyFromObject(pvc)
+		err := errors.WithStack(r.Client.Get(ctx, key, pvc))
+		if err == nil && pvc.DeletionTimestamp == nil {
+			err = errors.WithStack(r.deleteControlled(ctx, cluster, pvc))
+		}
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	err = errors.WithStack(r.setControllerReference(cluster, pvc))
+
+	pvc.Annotations = naming.Merge(
+		cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		instanceSpec.Metadata.GetAnnotationsOrNil())
+
+	pvc.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		instanceSpec.Metadata.GetLabelsOrNil(),
+		labelMap,
+	)
+
+	pvc.Spec = *cluster.Spec.Backups.PGBackRest.AsyncArchive.SpoolVolumeClaimSpec
+
+	if err == nil {
+		err = r.handlePersistentVolumeClaimError(cluster,
+			errors.WithStack(r.apply(ctx, pvc)))
+	}
+
+	return pvc, err
+}
+
 // reconcileDatabaseInitSQL runs custom SQL files in the database. When
 // DatabaseInitSQL is defined, the function will find the primary pod and run
 // SQL from the defined ConfigMap
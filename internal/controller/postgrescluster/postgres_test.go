@@ -19,8 +19,10 @@
 package postgrescluster
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -30,13 +32,16 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -144,15 +149,36 @@ func TestGeneratePostgresUserSecret(t *testing.T) {
 		secret, err = reconciler.generatePostgresUserSecret(cluster, spec, &corev1.Secret{
 			Data: map[string][]byte{
 				"password": []byte(`asdf`),
-				"verifier": []byte(`some$thing`),
+				"verifier": []byte(`SCRAM-SHA-256$some$thing`),
 			},
 		})
 		assert.NilError(t, err)
 
 		if assert.Check(t, secret != nil) {
 			assert.Equal(t, string(secret.Data["password"]), "asdf")
-			assert.Equal(t, string(secret.Data["verifier"]), "some$thing")
+			assert.Equal(t, string(secret.Data["verifier"]), "SCRAM-SHA-256$some$thing")
 		}
+
+		t.Run("PasswordEncryption", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+				PasswordEncryption: "md5",
+			}
+
+			// Verifier is replaced when it is in another format.
+			secret, err := reconciler.generatePostgresUserSecret(cluster, spec, &corev1.Secret{
+				Data: map[string][]byte{
+					"password": []byte(`asdf`),
+					"verifier": []byte(`SCRAM-SHA-256$some$thing`),
+				},
+			})
+			assert.NilError(t, err)
+
+			if assert.Check(t, secret != nil) {
+				assert.Equal(t, string(secret.Data["password"]), "asdf")
+				assert.Assert(t, cmp.Regexp(`^md5[0-9a-f]{32}$`, string(secret.Data["verifier"])))
+			}
+		})
 	})
 
 	t.Run("Database", func(t *testing.T) {
@@ -237,6 +263,98 @@ func TestGeneratePostgresUserSecret(t *testing.T) {
 	})
 }
 
+func TestMovedUserSecrets(t *testing.T) {
+	ctx := context.Background()
+	_, tClient := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &Reconciler{Client: tClient, Recorder: recorder}
+	ns := setupNamespace(t, tClient)
+
+	source := testCluster()
+	source.Namespace = ns.Name
+	assert.NilError(t, tClient.Create(ctx, source))
+
+	for meta, user := range map[*metav1.ObjectMeta]string{
+		initialize.Pointer(naming.PostgresUserSecret(source, "hippo")):  "hippo",
+		initialize.Pointer(naming.DeprecatedPostgresUserSecret(source)): "hippo",
+		initialize.Pointer(naming.PostgresUserSecret(source, "rhino")):  "rhino",
+	} {
+		secret := &corev1.Secret{ObjectMeta: *meta}
+		secret.Labels = map[string]string{
+			naming.LabelCluster:      source.Name,
+			naming.LabelPostgresUser: user,
+		}
+		assert.NilError(t, tClient.Create(ctx, secret))
+	}
+
+	cluster := testCluster()
+	cluster.Namespace = ns.Name
+	cluster.Name = "moved"
+	cluster.Spec.DataSource = &v1beta1.DataSource{
+		PostgresCluster: &v1beta1.PostgresClusterDataSource{
+			ClusterName: source.Name,
+		},
+	}
+
+	t.Run("NotMoving", func(t *testing.T) {
+		secrets, err := reconciler.movedUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(secrets), 0)
+	})
+
+	t.Run("Moving", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.DataSource.PostgresCluster.Move = initialize.Bool(true)
+
+		secrets, err := reconciler.movedUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(secrets), 2)
+		assert.Equal(t, secrets["hippo"].Name, naming.PostgresUserSecret(source, "hippo").Name)
+		assert.Equal(t, secrets["rhino"].Name, naming.PostgresUserSecret(source, "rhino").Name)
+	})
+
+	t.Run("OtherNamespace", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Namespace = setupNamespace(t, tClient).Name
+		cluster.Spec.DataSource.PostgresCluster.ClusterNamespace = source.Namespace
+		cluster.Spec.DataSource.PostgresCluster.Move = initialize.Bool(true)
+
+		// Nothing is read from a source that does not allow it.
+		secrets, err := reconciler.movedUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(secrets), 0)
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.HasPrefix(<-recorder.Events, "Warning DataSourceNotAllowed "))
+
+		allowing := source.DeepCopy()
+		allowing.Annotations = map[string]string{
+			naming.AllowDataSourceNamespaces: cluster.Namespace,
+		}
+		assert.NilError(t, tClient.Patch(ctx, allowing, client.MergeFrom(source)))
+
+		secrets, err = reconciler.movedUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(secrets), 2)
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("InPlace", func(t *testing.T) {
+		cluster := source.DeepCopy()
+		cluster.Spec.DataSource = &v1beta1.DataSource{
+			PostgresCluster: &v1beta1.PostgresClusterDataSource{
+				ClusterName: source.Name,
+				Move:        initialize.Bool(true),
+			},
+		}
+
+		secrets, err := reconciler.movedUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(secrets), 0)
+	})
+}
+
 func TestReconcilePostgresVolumes(t *testing.T) {
 	ctx := context.Background()
 	_, tClient := setupKubernetes(t)
@@ -423,6 +541,67 @@ volumeMode: Filesystem
 			})
 		})
 	})
+
+	t.Run("SpoolVolume", func(t *testing.T) {
+		t.Run("None", func(t *testing.T) {
+			pvc, err := reconciler.reconcilePGBackRestSpoolVolume(ctx, cluster, spec, instance, nil)
+			assert.NilError(t, err)
+			assert.Assert(t, pvc == nil)
+		})
+
+		t.Run("Specified", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Backups.PGBackRest.AsyncArchive = new(v1beta1.PGBackRestAsyncArchive)
+			assert.NilError(t, yaml.Unmarshal([]byte(`{
+				spoolVolumeClaimSpec: {
+					accessModes: [ReadWriteOnce],
+					resources: { requests: { storage: 3Gi } },
+					storageClassName: "storage-class-for-spool",
+				},
+			}`), cluster.Spec.Backups.PGBackRest.AsyncArchive))
+
+			pvc, err := reconciler.reconcilePGBackRestSpoolVolume(ctx, cluster, spec, instance, nil)
+			assert.NilError(t, err)
+
+			assert.Assert(t, metav1.IsControlledBy(pvc, cluster))
+
+			assert.Equal(t, pvc.Labels[naming.LabelCluster], cluster.Name)
+			assert.Equal(t, pvc.Labels[naming.LabelInstance], instance.Name)
+			assert.Equal(t, pvc.Labels[naming.LabelInstanceSet], spec.Name)
+			assert.Equal(t, pvc.Labels[naming.LabelRole], "pgspool")
+
+			assert.Assert(t, marshalMatches(pvc.Spec, `
+accessModes:
+- ReadWriteOnce
+resources:
+  requests:
+    storage: 3Gi
+storageClassName: storage-class-for-spool
+volumeMode: Filesystem
+			`))
+
+			t.Run("Removed", func(t *testing.T) {
+				cluster := cluster.DeepCopy()
+				cluster.Spec.Backups.PGBackRest.AsyncArchive.SpoolVolumeClaimSpec = nil
+
+				returned, err := reconciler.reconcilePGBackRestSpoolVolume(ctx, cluster, spec, instance, nil)
+				assert.NilError(t, err)
+				assert.Assert(t, returned == nil)
+
+				key, fetched := client.ObjectKeyFromObject(pvc), &corev1.PersistentVolumeClaim{}
+				if err := tClient.Get(ctx, key, fetched); err == nil {
+					assert.Assert(t, fetched.DeletionTimestamp != nil, "expected deleted")
+				} else {
+					assert.Assert(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+				}
+
+				// Nothing changes while the PVC is scheduled for deletion.
+				returned, err = reconciler.reconcilePGBackRestSpoolVolume(ctx, cluster, spec, instance, nil)
+				assert.NilError(t, err)
+				assert.Assert(t, returned == nil)
+			})
+		})
+	})
 }
 
 func TestReconcileDatabaseInitSQL(t *testing.T) {
@@ -661,3 +840,105 @@ func TestReconcileDatabaseInitSQLConfigMap(t *testing.T) {
 		assert.Assert(t, called)
 	})
 }
+
+func TestReconcileForeignServers(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	ns := setupNamespace(t, cc)
+
+	var calls int
+	var execErr error
+	var stdin bytes.Buffer
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	recorder := events.NewRecorder(t, scheme)
+	r := &Reconciler{
+		Client:   cc,
+		Recorder: recorder,
+		PodExec: func(namespace, pod, container string, in io.Reader, stdout,
+			stderr io.Writer, command ...string) error {
+			calls++
+			stdin.Reset()
+			_, _ = stdin.ReadFrom(in)
+			return execErr
+		},
+	}
+
+	observed := &observedInstances{forCluster: []*Instance{{
+		Name: "instance",
+		Pods: []*corev1.Pod{{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "pod",
+				Annotations: map[string]string{
+					"status": `{"role":"master"}`,
+				},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: naming.ContainerDatabase,
+					State: corev1.ContainerState{
+						Running: new(corev1.ContainerStateRunning),
+					},
+				}},
+			},
+		}},
+		Runner: &appsv1.StatefulSet{},
+	}}}
+
+	cluster := testCluster()
+	cluster.Namespace = ns.Name
+	cluster.Spec.Databases = []v1beta1.PostgresDatabaseSpec{{
+		Name: "zoo",
+		FDW: []v1beta1.PostgresForeignServerSpec{{
+			Name:    "oracle",
+			Wrapper: "oracle_fdw",
+			UserMappings: []v1beta1.PostgresUserMappingSpec{{
+				User:   "hippo",
+				Secret: &corev1.LocalObjectReference{Name: "hippo-oracle"},
+			}},
+		}},
+	}}
+
+	// Missing Secret.
+	assert.NilError(t, r.reconcileForeignServers(ctx, cluster, observed))
+	assert.Equal(t, calls, 0)
+	assert.Equal(t, cluster.Status.ForeignServersRevision, "")
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, recorder.Events[0].Reason, "InvalidUserMapping")
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns.Name, Name: "hippo-oracle"},
+		Data:       map[string][]byte{"user": []byte("keeper"), "password": []byte("trunk")},
+	}
+	assert.NilError(t, cc.Create(ctx, secret))
+
+	// SQL fails, such as when the wrapper is not installed.
+	execErr = errors.New("boom")
+	assert.NilError(t, r.reconcileForeignServers(ctx, cluster, observed))
+	assert.Equal(t, calls, 1)
+	assert.Equal(t, cluster.Status.ForeignServersRevision, "")
+	assert.Equal(t, len(recorder.Events), 2)
+	assert.Equal(t, recorder.Events[1].Reason, "ForeignServersFailed")
+
+	// SQL succeeds with credentials from the Secret.
+	execErr = nil
+	assert.NilError(t, r.reconcileForeignServers(ctx, cluster, observed))
+	assert.Equal(t, calls, 2)
+	assert.Assert(t, cmp.Contains(stdin.String(),
+		`{"options":{"password":"trunk","user":"keeper"},"server":"oracle","user":"hippo"}`))
+	assert.Assert(t, cluster.Status.ForeignServersRevision != "")
+
+	// Nothing changed; SQL is not executed again.
+	assert.NilError(t, r.reconcileForeignServers(ctx, cluster, observed))
+	assert.Equal(t, calls, 2)
+
+	// Credentials changed.
+	secret.Data["password"] = []byte("tusk")
+	assert.NilError(t, cc.Update(ctx, secret))
+	assert.NilError(t, r.reconcileForeignServers(ctx, cluster, observed))
+	assert.Equal(t, calls, 3)
+}
@@ -0,0 +1,159 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionDegraded is the type used in a condition to indicate that a
+	// PostgresCluster is running but some part of it is failing
+	ConditionDegraded = "Degraded"
+)
+
+// healthyClusterDelay is how long events of a healthy cluster wait before
+// they are queued while the workqueue is deep.
+const healthyClusterDelay = 10 * time.Second
+
+// clusterDegraded returns true when cluster has instances that are not ready,
+// a pgBackRest repository that is not ready, WAL archiving that is failing,
+// paused, or waiting for a full backup, or a Degraded condition.
+func clusterDegraded(cluster *v1beta1.PostgresCluster) bool {
+	if cluster.Spec.Shutdown == nil || !*cluster.Spec.Shutdown {
+		for _, set := range cluster.Status.InstanceSets {
+			if set.ReadyReplicas < set.Replicas {
+				return true
+			}
+		}
+	}
+
+	for _, conditionType := range []string{
+		ConditionRepoHostReady,
+		ConditionReplicaRepoReady,
+		ConditionArchivingHealthy,
+	} {
+		if meta.IsStatusConditionFalse(cluster.Status.Conditions, conditionType) {
+			return true
+		}
+	}
+	if meta.IsStatusConditionTrue(cluster.Status.Conditions, ConditionDegraded) {
+		return true
+	}
+
+	// The archiving condition only exists while archiving is paused or until
+	// a full backup completes after it resumes.
+	return meta.FindStatusCondition(cluster.Status.Conditions, ConditionArchiving) != nil
+}
+
+// urgentEvent returns true when object is a Pod that is not ready or a Job that
+// failed. The status of a cluster is written after it is reconciled, so it does
+// not yet reflect these.
+func urgentEvent(object client.Object) bool {
+	switch object := object.(type) {
+	case *corev1.Pod:
+		for _, condition := range object.Status.Conditions {
+			if condition.Type == corev1.PodReady {
+				return condition.Status != corev1.ConditionTrue
+			}
+		}
+		return true
+	case *batchv1.Job:
+		return jobFailed(object)
+	}
+	return false
+}
+
+// prioritize returns a handler.EventHandler that queues requests like inner
+// except when the workqueue holds more requests than there are workers. Then
+// requests for healthy clusters wait [healthyClusterDelay] so that workers
+// reach degraded clusters sooner. Requests caused by an urgent event, such as
+// a Pod that is deleted or not ready, never wait.
+func (r *Reconciler) prioritize(inner handler.EventHandler, workers int) handler.EventHandler {
+	return prioritizedHandler{inner: inner, reader: r.Client, workers: workers}
+}
+
+type prioritizedHandler struct {
+	inner   handler.EventHandler
+	reader  client.Reader
+	workers int
+}
+
+func (h prioritizedHandler) queue(
+	q workqueue.RateLimitingInterface, urgent bool,
+) workqueue.RateLimitingInterface {
+	if urgent {
+		return q
+	}
+	return prioritizedQueue{RateLimitingInterface: q, handler: h}
+}
+
+// Create implements [handler.EventHandler].
+func (h prioritizedHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.inner.Create(e, h.queue(q, urgentEvent(e.Object)))
+}
+
+// Update implements [handler.EventHandler].
+func (h prioritizedHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.inner.Update(e, h.queue(q, urgentEvent(e.ObjectNew)))
+}
+
+// Delete implements [handler.EventHandler]. Every deleted Pod is urgent.
+func (h prioritizedHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	_, pod := e.Object.(*corev1.Pod)
+	h.inner.Delete(e, h.queue(q, pod || urgentEvent(e.Object)))
+}
+
+// Generic implements [handler.EventHandler].
+func (h prioritizedHandler) Generic(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.inner.Generic(e, h.queue(q, urgentEvent(e.Object)))
+}
+
+// prioritizedQueue delays adding requests for healthy clusters while the
+// underlying workqueue is deep.
+type prioritizedQueue struct {
+	workqueue.RateLimitingInterface
+	handler prioritizedHandler
+}
+
+// Add implements [workqueue.Interface].
+func (q prioritizedQueue) Add(item interface{}) {
+	if request, ok := item.(reconcile.Request); ok &&
+		q.Len() > q.handler.workers && q.healthy(request.NamespacedName) {
+		q.AddAfter(item, healthyClusterDelay)
+		return
+	}
+	q.RateLimitingInterface.Add(item)
+}
+
+// healthy returns true when the cluster is known to exist and is not degraded.
+func (q prioritizedQueue) healthy(key client.ObjectKey) bool {
+	cluster := &v1beta1.PostgresCluster{}
+	err := q.handler.reader.Get(context.Background(), key, cluster)
+	return err == nil && cluster.DeletionTimestamp == nil && !clusterDegraded(cluster)
+}
@@ -0,0 +1,209 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestClusterDegraded(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	assert.Assert(t, !clusterDegraded(cluster))
+
+	t.Run("Instances", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Status.InstanceSets = []v1beta1.PostgresInstanceSetStatus{
+			{Name: "00", Replicas: 2, ReadyReplicas: 2},
+		}
+		assert.Assert(t, !clusterDegraded(cluster))
+
+		cluster.Status.InstanceSets[0].ReadyReplicas = 1
+		assert.Assert(t, clusterDegraded(cluster))
+
+		cluster.Spec.Shutdown = initialize.Bool(true)
+		assert.Assert(t, !clusterDegraded(cluster), "expected shutdown to be healthy")
+	})
+
+	t.Run("Conditions", func(t *testing.T) {
+		for _, conditionType := range []string{
+			ConditionRepoHostReady,
+			ConditionReplicaRepoReady,
+			ConditionArchivingHealthy,
+		} {
+			cluster := cluster.DeepCopy()
+			meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+				Type: conditionType, Status: metav1.ConditionTrue,
+			})
+			assert.Assert(t, !clusterDegraded(cluster), "%v", conditionType)
+
+			meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+				Type: conditionType, Status: metav1.ConditionFalse,
+			})
+			assert.Assert(t, clusterDegraded(cluster), "%v", conditionType)
+		}
+	})
+
+	t.Run("Failing", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type: ConditionDegraded, Status: metav1.ConditionFalse,
+		})
+		assert.Assert(t, !clusterDegraded(cluster))
+
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type: ConditionDegraded, Status: metav1.ConditionTrue,
+		})
+		assert.Assert(t, clusterDegraded(cluster))
+	})
+
+	t.Run("Archiving", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type: ConditionArchiving, Status: metav1.ConditionFalse,
+			Reason: "ArchivingPaused",
+		})
+		assert.Assert(t, clusterDegraded(cluster))
+	})
+}
+
+func TestPrioritize(t *testing.T) {
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	healthy := &v1beta1.PostgresCluster{}
+	healthy.Namespace, healthy.Name = "ns1", "healthy"
+
+	degraded := &v1beta1.PostgresCluster{}
+	degraded.Namespace, degraded.Name = "ns1", "degraded"
+	degraded.Status.InstanceSets = []v1beta1.PostgresInstanceSetStatus{
+		{Name: "00", Replicas: 1},
+	}
+
+	reconciler := &Reconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(healthy, degraded).Build(),
+	}
+
+	// The inner handler queues the cluster named in a label of the object.
+	inner := handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			q.Add(reconcile.Request{NamespacedName: client.ObjectKey{
+				Namespace: e.Object.GetNamespace(),
+				Name:      e.Object.GetLabels()["cluster"],
+			}})
+		},
+	}
+	readyPod := func(cluster string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Namespace = "ns1"
+		pod.Labels = map[string]string{"cluster": cluster}
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type: corev1.PodReady, Status: corev1.ConditionTrue,
+		}}
+		return pod
+	}
+	create := func(q workqueue.RateLimitingInterface, cluster string) {
+		reconciler.prioritize(inner, 1).Create(event.CreateEvent{Object: readyPod(cluster)}, q)
+	}
+
+	t.Run("ShallowQueue", func(t *testing.T) {
+		queue := controllertest.Queue{Interface: workqueue.New()}
+
+		create(queue, "healthy")
+		assert.Equal(t, queue.Len(), 1)
+	})
+
+	t.Run("DeepQueue", func(t *testing.T) {
+		queue := &delayedQueue{Queue: controllertest.Queue{Interface: workqueue.New()}}
+		queue.Add("first")
+		queue.Add("second")
+
+		create(queue, "healthy")
+		assert.Equal(t, queue.Len(), 2, "expected healthy cluster to wait")
+		assert.DeepEqual(t, queue.delayed, []interface{}{
+			reconcile.Request{NamespacedName: client.ObjectKeyFromObject(healthy)},
+		})
+
+		create(queue, "degraded")
+		assert.Equal(t, queue.Len(), 3, "expected degraded cluster to be queued")
+
+		create(queue, "missing")
+		assert.Equal(t, queue.Len(), 4, "expected unknown cluster to be queued")
+	})
+
+	t.Run("UrgentEvents", func(t *testing.T) {
+		queue := &delayedQueue{Queue: controllertest.Queue{Interface: workqueue.New()}}
+		queue.Add("first")
+		queue.Add("second")
+
+		pod := readyPod("healthy")
+		pod.Status.Conditions[0].Status = corev1.ConditionFalse
+		reconciler.prioritize(inner, 1).Create(event.CreateEvent{Object: pod}, queue)
+		assert.Equal(t, queue.Len(), 3, "expected a Pod that is not ready to be queued")
+		assert.Equal(t, len(queue.delayed), 0)
+	})
+}
+
+func TestUrgentEvent(t *testing.T) {
+	assert.Assert(t, !urgentEvent(&corev1.ConfigMap{}))
+
+	pod := &corev1.Pod{}
+	assert.Assert(t, urgentEvent(pod), "expected unknown readiness to be urgent")
+
+	pod.Status.Conditions = []corev1.PodCondition{{
+		Type: corev1.PodReady, Status: corev1.ConditionTrue,
+	}}
+	assert.Assert(t, !urgentEvent(pod))
+
+	pod.Status.Conditions[0].Status = corev1.ConditionFalse
+	assert.Assert(t, urgentEvent(pod))
+
+	job := &batchv1.Job{}
+	assert.Assert(t, !urgentEvent(job))
+
+	job.Status.Conditions = []batchv1.JobCondition{{
+		Type: batchv1.JobFailed, Status: corev1.ConditionTrue,
+	}}
+	assert.Assert(t, urgentEvent(job))
+}
+
+// delayedQueue records items added with a delay rather than waiting for them.
+type delayedQueue struct {
+	controllertest.Queue
+	delayed []interface{}
+}
+
+func (q *delayedQueue) AddAfter(item interface{}, _ time.Duration) {
+	q.delayed = append(q.delayed, item)
+}
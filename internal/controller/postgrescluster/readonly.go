@@ -0,0 +1,69 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionReadOnly is the type used in a condition to indicate whether or
+	// not PostgreSQL refuses writes because of spec.readOnly or the read-only
+	// annotation. PGO does not write to PostgreSQL while it is true.
+	ConditionReadOnly = "ReadOnly"
+)
+
+// reconcileReadOnly reports in the [ConditionReadOnly] condition whether or
+// not cluster is read-only and records an event when that changes. It returns
+// true when the objects that PGO manages inside PostgreSQL should be left as
+// they are. Those are written again once the cluster is no longer read-only.
+func (r *Reconciler) reconcileReadOnly(cluster *v1beta1.PostgresCluster) bool {
+	previous := meta.FindStatusCondition(cluster.Status.Conditions, ConditionReadOnly)
+
+	if !postgres.ReadOnly(cluster) {
+		if previous != nil {
+			r.Recorder.Event(cluster, corev1.EventTypeNormal, "ReadOnlyDisabled",
+				"PostgreSQL accepts writes")
+		}
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionReadOnly)
+		return false
+	}
+
+	condition := metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionReadOnly,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Spec",
+		Message: "PostgreSQL refuses writes; users, databases, and other objects " +
+			"in PostgreSQL are not changed until it accepts writes. Backups continue.",
+	}
+	if cluster.GetAnnotations()[naming.ReadOnly] == "true" {
+		condition.Reason = "Annotation"
+	}
+
+	if previous == nil {
+		r.Recorder.Event(cluster, corev1.EventTypeNormal, "ReadOnlyEnabled",
+			"PostgreSQL refuses writes")
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+	return true
+}
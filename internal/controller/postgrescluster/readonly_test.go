@@ -0,0 +1,73 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestReconcileReadOnly(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{Recorder: recorder}
+	cluster := &v1beta1.PostgresCluster{}
+
+	t.Run("Writable", func(t *testing.T) {
+		assert.Assert(t, !r.reconcileReadOnly(cluster))
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions, ConditionReadOnly) == nil)
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Spec", func(t *testing.T) {
+		cluster.Spec.ReadOnly = initialize.Bool(true)
+		assert.Assert(t, r.reconcileReadOnly(cluster))
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionReadOnly)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.Reason, "Spec")
+		assert.Equal(t, <-recorder.Events, "Normal ReadOnlyEnabled PostgreSQL refuses writes")
+
+		// No additional event while the cluster stays read-only.
+		assert.Assert(t, r.reconcileReadOnly(cluster))
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Annotation", func(t *testing.T) {
+		cluster.Spec.ReadOnly = nil
+		cluster.Annotations = map[string]string{naming.ReadOnly: "true"}
+		assert.Assert(t, r.reconcileReadOnly(cluster))
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionReadOnly)
+		assert.Equal(t, condition.Reason, "Annotation")
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		cluster.Annotations = nil
+		assert.Assert(t, !r.reconcileReadOnly(cluster))
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions, ConditionReadOnly) == nil)
+		assert.Equal(t, <-recorder.Events, "Normal ReadOnlyDisabled PostgreSQL accepts writes")
+	})
+}
@@ -0,0 +1,400 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pki"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// ConditionRemoteInstanceSetsApplied is the type used in a condition to
+// indicate whether or not the standby PostgresClusters of the remote instance
+// sets of a cluster were written.
+const ConditionRemoteInstanceSetsApplied = "RemoteInstanceSetsApplied"
+
+// validateRemoteInstanceSets returns an error when a remote instance set of
+// cluster cannot work.
+func validateRemoteInstanceSets(cluster *v1beta1.PostgresCluster) error {
+	path := field.NewPath("spec", "remoteInstances")
+	for i := range cluster.Spec.RemoteInstanceSets {
+		set := &cluster.Spec.RemoteInstanceSets[i]
+
+		// The remote PostgresCluster would have the same name as this one.
+		if set.Namespace == cluster.Namespace {
+			return field.Invalid(path.Index(i).Child("namespace"), set.Namespace,
+				"must be different than the namespace of the cluster")
+		}
+
+		if remoteInstanceSetTemplate(cluster, set) == nil {
+			return field.NotFound(path.Index(i).Child("instanceSet"), set.InstanceSet)
+		}
+	}
+	return nil
+}
+
+// remoteInstanceSetTemplate returns the instance set of cluster that set
+// copies, or nil when there is none.
+func remoteInstanceSetTemplate(
+	cluster *v1beta1.PostgresCluster, set *v1beta1.PostgresRemoteInstanceSetSpec,
+) *v1beta1.PostgresInstanceSetSpec {
+	for i := range cluster.Spec.InstanceSets {
+		if set.InstanceSet == "" || cluster.Spec.InstanceSets[i].Name == set.InstanceSet {
+			return &cluster.Spec.InstanceSets[i]
+		}
+	}
+	return nil
+}
+
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={get,list}
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={create,patch}
+
+// reconcileRemoteInstanceSets writes a standby PostgresCluster for each remote
+// instance set of cluster. Each one streams from the primary Service of
+// cluster using certificates issued by the authority of cluster. Standbys of
+// sets that are no longer specified are deleted. The outcome is reported in
+// the [ConditionRemoteInstanceSetsApplied] condition.
+//
+// Kubernetes does not allow owner references across namespaces, so the
+// standbys are found by their labels rather than by owner. A PostgresCluster
+// without those labels is never changed, even when it has the same name.
+func (r *Reconciler) reconcileRemoteInstanceSets(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	root *pki.RootCertificateAuthority,
+	primaryCertificate *corev1.SecretProjection,
+	replicationSecret *corev1.Secret,
+) error {
+	specified := sets.NewString()
+	taken := sets.NewString()
+
+	var err error
+	for i := range cluster.Spec.RemoteInstanceSets {
+		set := &cluster.Spec.RemoteInstanceSets[i]
+		remote := &v1beta1.PostgresCluster{ObjectMeta: naming.RemoteInstanceSet(cluster, set)}
+		specified.Insert(remote.Namespace + "/" + remote.Name)
+
+		if err == nil {
+			var applied bool
+			applied, err = r.reconcileRemoteInstanceSet(ctx, cluster, set, remote,
+				root, primaryCertificate, replicationSecret)
+
+			if err == nil && !applied {
+				taken.Insert(remote.Namespace + "/" + remote.Name)
+			}
+		}
+	}
+
+	if err == nil {
+		err = r.deleteRemoteInstanceSets(ctx, cluster, specified)
+	}
+
+	// Remember the namespaces to look in once sets are removed from the spec.
+	if err == nil {
+		namespaces := sets.NewString()
+		for i := range cluster.Spec.RemoteInstanceSets {
+			namespaces.Insert(cluster.Spec.RemoteInstanceSets[i].Namespace)
+		}
+		cluster.Status.RemoteInstanceSetNamespaces = nil
+		if namespaces.Len() > 0 {
+			cluster.Status.RemoteInstanceSetNamespaces = namespaces.List()
+		}
+	}
+
+	switch {
+	case len(cluster.Spec.RemoteInstanceSets) == 0:
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionRemoteInstanceSetsApplied)
+
+	case taken.Len() > 0:
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			ObservedGeneration: cluster.GetGeneration(),
+			Type:               ConditionRemoteInstanceSetsApplied,
+			Status:             metav1.ConditionFalse,
+			Reason:             "NameInUse",
+			Message: fmt.Sprintf(
+				"PostgresClusters %s already exist and are not remote instance sets of this cluster",
+				strings.Join(taken.List(), ", ")),
+		})
+
+	case err == nil:
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			ObservedGeneration: cluster.GetGeneration(),
+			Type:               ConditionRemoteInstanceSetsApplied,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Applied",
+			Message:            fmt.Sprintf("%d remote instance sets", specified.Len()),
+		})
+	}
+
+	return err
+}
+
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={get}
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={create,patch}
+
+// reconcileRemoteInstanceSet writes remote, the standby PostgresCluster of
+// set, and the Secrets it uses to authenticate to the primary of cluster. It
+// writes nothing and returns false when a PostgresCluster that is not a remote
+// instance set of cluster already has the name of remote.
+func (r *Reconciler) reconcileRemoteInstanceSet(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	set *v1beta1.PostgresRemoteInstanceSetSpec, remote *v1beta1.PostgresCluster,
+	root *pki.RootCertificateAuthority,
+	primaryCertificate *corev1.SecretProjection,
+	replicationSecret *corev1.Secret,
+) (bool, error) {
+	existing := &v1beta1.PostgresCluster{}
+	err := errors.WithStack(r.Client.Get(ctx, client.ObjectKeyFromObject(remote), existing))
+
+	if err == nil {
+		var selector labels.Selector
+		selector, err = naming.AsSelector(naming.RemoteInstanceSets(cluster))
+		if err == nil && !selector.Matches(labels.Set(existing.Labels)) {
+			return false, nil
+		}
+	} else if apierrors.IsNotFound(err) {
+		err = nil
+	}
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "RemoteInstanceSetForbidden",
+				"Unable to manage remote instance set %q in namespace %q: %v",
+				set.Name, set.Namespace, err)
+		}
+		return false, err
+	}
+
+	remote.SetGroupVersionKind(v1beta1.GroupVersion.WithKind("PostgresCluster"))
+
+	remote.Annotations = naming.Merge(
+		cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		set.Metadata.GetAnnotationsOrNil())
+	remote.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		set.Metadata.GetLabelsOrNil(),
+		naming.RemoteInstanceSets(cluster).MatchLabels)
+
+	// The standby runs the same PostgreSQL as the cluster. Its parameters must
+	// be at least those of the primary for hot standby to work, so it gets the
+	// same Patroni dynamic configuration.
+	// - https://www.postgresql.org/docs/current/hot-standby.html#HOT-STANDBY-ADMIN
+	remote.Spec.Image = cluster.Spec.Image
+	remote.Spec.ImagePullPolicy = cluster.Spec.ImagePullPolicy
+	remote.Spec.ImagePullSecrets = cluster.Spec.ImagePullSecrets
+	remote.Spec.Metadata = cluster.Spec.Metadata
+	remote.Spec.Port = cluster.Spec.Port
+	remote.Spec.PostgresVersion = cluster.Spec.PostgresVersion
+	remote.Spec.PostGISVersion = cluster.Spec.PostGISVersion
+
+	if cluster.Spec.Patroni != nil && cluster.Spec.Patroni.DynamicConfiguration != nil {
+		remote.Spec.Patroni = &v1beta1.PatroniSpec{
+			DynamicConfiguration: cluster.Spec.Patroni.DynamicConfiguration,
+		}
+	}
+
+	// The pods copy an instance set of the cluster. Their backups and WAL go to
+	// a repository volume of their own.
+	instances := remoteInstanceSetTemplate(cluster, set).DeepCopy()
+	instances.Replicas = initialize.Int32(1)
+	if set.Replicas != nil {
+		instances.Replicas = initialize.Int32(*set.Replicas)
+	}
+	remote.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{*instances}
+
+	remote.Spec.Backups = v1beta1.Backups{
+		PGBackRest: v1beta1.PGBackRestArchive{
+			Image: cluster.Spec.Backups.PGBackRest.Image,
+			Repos: []v1beta1.PGBackRestRepo{{
+				Name: "repo1",
+				Volume: &v1beta1.RepoPVC{
+					VolumeClaimSpec: *set.RepoVolumeClaimSpec.DeepCopy(),
+				},
+			}},
+		},
+	}
+
+	primaryService := &corev1.Service{ObjectMeta: naming.ClusterPrimaryService(cluster)}
+	remote.Spec.Standby = &v1beta1.PostgresStandbySpec{
+		Enabled: true,
+		Host:    naming.ServiceDNSNames(ctx, primaryService)[0],
+		Port:    cluster.Spec.Port,
+	}
+
+	// The remote instances stream from the primary and from one another using
+	// certificates issued by the authority of cluster.
+	certificate := &corev1.Secret{ObjectMeta: naming.RemoteInstanceSetTLSSecret(remote)}
+	replication := &corev1.Secret{ObjectMeta: naming.RemoteInstanceSetReplicationSecret(remote)}
+
+	remote.Spec.CustomTLSSecret = &corev1.SecretProjection{
+		LocalObjectReference: corev1.LocalObjectReference{Name: certificate.Name},
+		Items:                primaryCertificate.Items,
+	}
+	remote.Spec.CustomReplicationClientTLSSecret = &corev1.SecretProjection{
+		LocalObjectReference: corev1.LocalObjectReference{Name: replication.Name},
+		Items:                replicationCertSecretProjection(replication).Items,
+	}
+
+	err = errors.WithStack(r.apply(ctx, remote))
+
+	// The Secrets belong to the standby so they go away with it.
+	if err == nil {
+		err = r.reconcileRemoteCertificate(ctx, cluster, remote, certificate,
+			root, primaryCertificate)
+	}
+	if err == nil {
+		replication.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+		replication.Labels = naming.Merge(
+			remote.Labels, map[string]string{naming.LabelCluster: remote.Name})
+		replication.Data = replicationSecret.Data
+
+		err = errors.WithStack(r.setControllerReference(remote, replication))
+	}
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, replication))
+	}
+	if apierrors.IsForbidden(err) {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "RemoteInstanceSetForbidden",
+			"Unable to manage remote instance set %q in namespace %q: %v",
+			set.Name, set.Namespace, err)
+	}
+
+	return err == nil, err
+}
+
+// reconcileRemoteCertificate writes certificate, the server certificate of
+// remote. When cluster has a custom certificate, it is copied. Otherwise, a
+// leaf for the primary Service of remote is issued by root.
+func (r *Reconciler) reconcileRemoteCertificate(
+	ctx context.Context, cluster, remote *v1beta1.PostgresCluster,
+	certificate *corev1.Secret, root *pki.RootCertificateAuthority,
+	primaryCertificate *corev1.SecretProjection,
+) error {
+	existing := &corev1.Secret{}
+	certificate.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+	certificate.Labels = naming.Merge(
+		remote.Labels, map[string]string{naming.LabelCluster: remote.Name})
+
+	var err error
+	if cluster.Spec.CustomTLSSecret != nil {
+		existing.Namespace, existing.Name = cluster.Namespace, primaryCertificate.Name
+		err = errors.WithStack(
+			r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing))
+		certificate.Data = existing.Data
+	} else {
+		existing.Namespace, existing.Name = certificate.Namespace, certificate.Name
+		err = errors.WithStack(client.IgnoreNotFound(
+			r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing)))
+
+		leaf := &pki.LeafCertificate{}
+		service := &corev1.Service{ObjectMeta: naming.ClusterPrimaryService(remote)}
+		dnsNames := naming.ServiceDNSNames(ctx, service)
+
+		if err == nil {
+			// These errors can be ignored because they result in an invalid
+			// leaf which is then correctly regenerated.
+			_ = leaf.Certificate.UnmarshalText(existing.Data[clusterCertFile])
+			_ = leaf.PrivateKey.UnmarshalText(existing.Data[clusterKeyFile])
+
+			leaf, err = root.RegenerateLeafWhenNecessary(leaf, dnsNames[0], dnsNames)
+			err = errors.WithStack(err)
+		}
+
+		certificate.Data = make(map[string][]byte)
+		if err == nil {
+			certificate.Data[clusterCertFile], err = leaf.Certificate.MarshalText()
+			err = errors.WithStack(err)
+		}
+		if err == nil {
+			certificate.Data[clusterKeyFile], err = leaf.PrivateKey.MarshalText()
+			err = errors.WithStack(err)
+		}
+		if err == nil {
+			certificate.Data[rootCertFile], err = root.Certificate.MarshalText()
+			err = errors.WithStack(err)
+		}
+	}
+
+	if err == nil {
+		err = errors.WithStack(r.setControllerReference(remote, certificate))
+	}
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, certificate))
+	}
+
+	return err
+}
+
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={list}
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={delete}
+
+// deleteRemoteInstanceSets deletes the standby PostgresClusters of cluster
+// that are not in keep, a set of "namespace/name" strings. It looks only in
+// the namespaces of its remote instance sets, now and when last reconciled,
+// so it works without access to other namespaces.
+func (r *Reconciler) deleteRemoteInstanceSets(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, keep sets.String,
+) error {
+	specified := sets.NewString()
+	for i := range cluster.Spec.RemoteInstanceSets {
+		specified.Insert(cluster.Spec.RemoteInstanceSets[i].Namespace)
+	}
+	namespaces := sets.NewString(cluster.Status.RemoteInstanceSetNamespaces...).Union(specified)
+
+	selector, err := naming.AsSelector(naming.RemoteInstanceSets(cluster))
+
+	for _, namespace := range namespaces.List() {
+		remotes := &v1beta1.PostgresClusterList{}
+		if err == nil {
+			err = errors.WithStack(r.Client.List(ctx, remotes,
+				client.InNamespace(namespace),
+				client.MatchingLabelsSelector{Selector: selector}))
+
+			// The operator may no longer manage a namespace that is not in the
+			// spec anymore. There is nothing it can delete there.
+			if apierrors.IsForbidden(err) && !specified.Has(namespace) {
+				err = nil
+			}
+		}
+
+		for i := range remotes.Items {
+			remote := &remotes.Items[i]
+			if err == nil && !keep.Has(remote.Namespace+"/"+remote.Name) {
+				logging.FromContext(ctx).V(1).Info("deleting remote instance set",
+					"namespace", remote.Namespace, "name", remote.Name)
+
+				err = errors.WithStack(client.IgnoreNotFound(
+					r.Client.Delete(ctx, remote, client.Preconditions{UID: &remote.UID})))
+			}
+		}
+	}
+
+	return err
+}
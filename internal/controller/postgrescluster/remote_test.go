@@ -0,0 +1,212 @@
+//go:build envtest
+// +build envtest
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pki"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
+	"github.com/crunchydata/postgres-operator/internal/testing/require"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestValidateRemoteInstanceSets(t *testing.T) {
+	cluster := testCluster()
+	cluster.Namespace = "ns1"
+	assert.NilError(t, validateRemoteInstanceSets(cluster))
+
+	cluster.Spec.RemoteInstanceSets = []v1beta1.PostgresRemoteInstanceSetSpec{
+		{Namespace: "ns2"},
+	}
+	assert.NilError(t, validateRemoteInstanceSets(cluster))
+
+	cluster.Spec.RemoteInstanceSets[0].InstanceSet = "missing"
+	assert.ErrorContains(t, validateRemoteInstanceSets(cluster),
+		"spec.remoteInstances[0].instanceSet")
+
+	cluster.Spec.RemoteInstanceSets[0].InstanceSet = cluster.Spec.InstanceSets[0].Name
+	assert.NilError(t, validateRemoteInstanceSets(cluster))
+
+	cluster.Spec.RemoteInstanceSets[0].Namespace = "ns1"
+	assert.ErrorContains(t, validateRemoteInstanceSets(cluster),
+		"spec.remoteInstances[0].namespace")
+}
+
+func TestReconcileRemoteInstanceSets(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 1)
+
+	recorder := events.NewRecorder(t, cc.Scheme())
+	reconciler := &Reconciler{
+		Client:   cc,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: recorder,
+	}
+
+	source := setupNamespace(t, cc)
+	reporting := setupNamespace(t, cc)
+
+	cluster := testCluster()
+	cluster.Namespace = source.Name
+	cluster.Spec.Port = initialize.Int32(5432)
+	assert.NilError(t, cc.Create(ctx, cluster))
+
+	root, err := pki.NewRootCertificateAuthority()
+	assert.NilError(t, err)
+
+	replication := &corev1.Secret{ObjectMeta: naming.ReplicationClientCertSecret(cluster)}
+	replication.Data = map[string][]byte{
+		"tls.crt": []byte("certificate"),
+		"tls.key": []byte("key"),
+		"ca.crt":  []byte("authority"),
+	}
+	primaryCertificate := clusterCertSecretProjection(
+		&corev1.Secret{ObjectMeta: naming.PostgresTLSSecret(cluster)})
+
+	cluster.Spec.RemoteInstanceSets = []v1beta1.PostgresRemoteInstanceSetSpec{{
+		Name:                "reports",
+		Namespace:           reporting.Name,
+		Replicas:            initialize.Int32(2),
+		RepoVolumeClaimSpec: testVolumeClaimSpec(),
+	}}
+
+	assert.NilError(t, reconciler.reconcileRemoteInstanceSets(
+		ctx, cluster, root, primaryCertificate, replication))
+
+	remote := &v1beta1.PostgresCluster{}
+	assert.NilError(t, cc.Get(ctx, naming.AsObjectKey(
+		naming.RemoteInstanceSet(cluster, &cluster.Spec.RemoteInstanceSets[0]),
+	), remote))
+
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionRemoteInstanceSetsApplied)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.DeepEqual(t, cluster.Status.RemoteInstanceSetNamespaces, []string{reporting.Name})
+
+	t.Run("Standby", func(t *testing.T) {
+		assert.Equal(t, remote.Labels[naming.LabelRemoteSourceCluster], cluster.Name)
+		assert.Equal(t, remote.Labels[naming.LabelRemoteSourceNamespace], cluster.Namespace)
+		assert.Equal(t, len(remote.OwnerReferences), 0)
+
+		assert.Equal(t, remote.Spec.PostgresVersion, cluster.Spec.PostgresVersion)
+		assert.Equal(t, remote.Spec.Image, cluster.Spec.Image)
+		assert.Equal(t, len(remote.Spec.InstanceSets), 1)
+		assert.Equal(t, remote.Spec.InstanceSets[0].Name, cluster.Spec.InstanceSets[0].Name)
+		assert.DeepEqual(t, remote.Spec.InstanceSets[0].DataVolumeClaimSpec,
+			cluster.Spec.InstanceSets[0].DataVolumeClaimSpec)
+		assert.Equal(t, *remote.Spec.InstanceSets[0].Replicas, int32(2))
+
+		assert.Equal(t, remote.Spec.Backups.PGBackRest.Image, cluster.Spec.Backups.PGBackRest.Image)
+		assert.Equal(t, len(remote.Spec.Backups.PGBackRest.Repos), 1)
+		assert.Assert(t, remote.Spec.Backups.PGBackRest.Repos[0].Volume != nil)
+		assert.DeepEqual(t, remote.Spec.Backups.PGBackRest.Repos[0].Volume.VolumeClaimSpec,
+			cluster.Spec.RemoteInstanceSets[0].RepoVolumeClaimSpec)
+
+		assert.Assert(t, remote.Spec.Standby != nil)
+		assert.Assert(t, remote.Spec.Standby.Enabled)
+		assert.Equal(t, *remote.Spec.Standby.Port, int32(5432))
+		assert.Equal(t, remote.Spec.Standby.Host,
+			"hippo-primary."+source.Name+".svc."+naming.KubernetesClusterDomain(ctx))
+	})
+
+	t.Run("Certificates", func(t *testing.T) {
+		copied := &corev1.Secret{}
+		assert.NilError(t, cc.Get(ctx, client.ObjectKey{
+			Namespace: reporting.Name,
+			Name:      remote.Spec.CustomReplicationClientTLSSecret.Name,
+		}, copied))
+		assert.DeepEqual(t, copied.Data, replication.Data)
+		assert.Assert(t, metav1.IsControlledBy(copied, remote))
+
+		issued := &corev1.Secret{}
+		assert.NilError(t, cc.Get(ctx, client.ObjectKey{
+			Namespace: reporting.Name,
+			Name:      remote.Spec.CustomTLSSecret.Name,
+		}, issued))
+		assert.Assert(t, metav1.IsControlledBy(issued, remote))
+
+		leaf := &pki.LeafCertificate{}
+		assert.NilError(t, leaf.Certificate.UnmarshalText(issued.Data["tls.crt"]))
+		assert.NilError(t, leaf.PrivateKey.UnmarshalText(issued.Data["tls.key"]))
+		assert.Equal(t, leaf.Certificate.CommonName(),
+			remote.Name+"-primary."+reporting.Name+".svc."+naming.KubernetesClusterDomain(ctx))
+
+		// The certificate is kept while it is valid.
+		assert.NilError(t, reconciler.reconcileRemoteInstanceSets(
+			ctx, cluster, root, primaryCertificate, replication))
+
+		again := &corev1.Secret{}
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(issued), again))
+		assert.DeepEqual(t, again.Data, issued.Data)
+	})
+
+	t.Run("NameInUse", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.RemoteInstanceSets = append(cluster.Spec.RemoteInstanceSets,
+			*cluster.Spec.RemoteInstanceSets[0].DeepCopy())
+		cluster.Spec.RemoteInstanceSets[1].Name = "taken"
+
+		// Another PostgresCluster already has the name of the new set.
+		other := testCluster()
+		other.ObjectMeta = naming.RemoteInstanceSet(cluster, &cluster.Spec.RemoteInstanceSets[1])
+		assert.NilError(t, cc.Create(ctx, other))
+		t.Cleanup(func() { assert.Check(t, cc.Delete(ctx, other)) })
+
+		assert.NilError(t, reconciler.reconcileRemoteInstanceSets(
+			ctx, cluster, root, primaryCertificate, replication))
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionRemoteInstanceSetsApplied)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "NameInUse")
+		assert.Assert(t, cmp.Contains(condition.Message, other.Name))
+
+		// The other PostgresCluster is not changed.
+		after := &v1beta1.PostgresCluster{}
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(other), after))
+		assert.Equal(t, after.Generation, other.Generation)
+		assert.Equal(t, after.Labels[naming.LabelRemoteSourceCluster], "")
+		assert.Assert(t, after.Spec.Standby == nil)
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.RemoteInstanceSets = nil
+
+		assert.NilError(t, reconciler.reconcileRemoteInstanceSets(
+			ctx, cluster, root, primaryCertificate, replication))
+
+		err := cc.Get(ctx, client.ObjectKeyFromObject(remote), &v1beta1.PostgresCluster{})
+		assert.Assert(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+		assert.Assert(t, cluster.Status.RemoteInstanceSetNamespaces == nil)
+	})
+}
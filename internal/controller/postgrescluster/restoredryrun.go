@@ -0,0 +1,225 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionPGBackRestRestoreDryRun is the type used in a condition to indicate whether
+	// or not the repository has what a restore needs, according to a restore dry run
+	ConditionPGBackRestRestoreDryRun = "PGBackRestRestoreDryRun"
+)
+
+// restoreDryRun returns true when dataSource only asks to check a restore.
+func restoreDryRun(dataSource *v1beta1.PostgresClusterDataSource) bool {
+	return dataSource != nil && dataSource.DryRun != nil && *dataSource.DryRun
+}
+
+// reconcileInPlaceRestoreDryRun checks an in-place restore while the cluster continues to run.
+// The pgBackRest configuration of the cluster is already in place; the configuration of another
+// source cluster is copied as it would be for the restore.
+func (r *Reconciler) reconcileInPlaceRestoreDryRun(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, dataSource *v1beta1.PostgresClusterDataSource,
+	configHash string) error {
+
+	sourceCluster := cluster
+	if key := (client.ObjectKey{
+		Name: dataSource.ClusterName, Namespace: dataSource.ClusterNamespace,
+	}); (key.Name != "" && key.Name != cluster.Name) ||
+		(key.Namespace != "" && key.Namespace != cluster.Namespace) {
+
+		if key.Name == "" {
+			key.Name = cluster.Name
+		}
+		if key.Namespace == "" {
+			key.Namespace = cluster.Namespace
+		}
+
+		sourceCluster = &v1beta1.PostgresCluster{}
+		err := r.Client.Get(ctx, key, sourceCluster)
+		switch {
+		case apierrors.IsNotFound(err):
+			r.setRestoreDryRunCondition(cluster, metav1.ConditionFalse, "InvalidDataSource",
+				"PostgresCluster "+key.Name+" does not exist")
+			return nil
+		case err != nil:
+			return errors.WithStack(err)
+		case !dataSourceAllowed(sourceCluster, cluster.Namespace):
+			r.setRestoreDryRunCondition(cluster, metav1.ConditionFalse, "DataSourceNotAllowed",
+				"PostgresCluster "+key.Name+" does not allow restores to namespace "+
+					cluster.Namespace)
+			return nil
+		}
+		if err := r.copyRestoreConfiguration(ctx, cluster, sourceCluster); err != nil {
+			return err
+		}
+	}
+
+	return r.reconcileRestoreDryRun(ctx, cluster, sourceCluster, dataSource, configHash,
+		pgbackrest.StanzaName(sourceCluster))
+}
+
+// +kubebuilder:rbac:groups="batch",resources="jobs",verbs={get,create,patch,delete}
+// +kubebuilder:rbac:groups="",resources="pods",verbs={list}
+
+// reconcileRestoreDryRun checks a restore from the repository of dataSource in stanza without
+// restoring anything. A Job like the restore Job, but without any data volumes, looks for the
+// backup set and WAL that the restore needs. The result is reported in the
+// [ConditionPGBackRestRestoreDryRun] condition and an event. The Job is replaced when
+// configHash changes.
+func (r *Reconciler) reconcileRestoreDryRun(ctx context.Context,
+	cluster, sourceCluster *v1beta1.PostgresCluster,
+	dataSource *v1beta1.PostgresClusterDataSource, configHash, stanza string) error {
+
+	if err := pgbackrest.ValidateRestoreOptions(dataSource.Options); err != nil {
+		r.setRestoreDryRunCondition(cluster, metav1.ConditionFalse,
+			ReasonInvalidRestoreOptions, "Unable to restore: "+err.Error())
+		return nil
+	}
+
+	existing := &batchv1.Job{ObjectMeta: naming.PGBackRestRestoreDryRunJob(cluster)}
+	err := errors.WithStack(client.IgnoreNotFound(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing)))
+	if err != nil {
+		return err
+	}
+
+	// Replace a Job that checked some other restore.
+	if existing.GetUID() != "" &&
+		existing.GetAnnotations()[naming.PGBackRestConfigHash] != configHash {
+		r.setRestoreDryRunCondition(cluster, metav1.ConditionUnknown,
+			"RestoreDryRunRunning", "Checking the restore")
+		return errors.WithStack(client.IgnoreNotFound(r.Client.Delete(ctx, existing,
+			client.PropagationPolicy(metav1.DeletePropagationBackground))))
+	}
+
+	switch {
+	case existing.GetUID() != "" && jobCompleted(existing):
+		r.setRestoreDryRunCondition(cluster, metav1.ConditionTrue, "RestoreDryRunPassed",
+			"The repository has the backup set and WAL that the restore needs")
+		return nil
+
+	case existing.GetUID() != "" && jobFailed(existing):
+		message, err := r.restoreDryRunMessage(ctx, existing)
+		if err == nil {
+			r.setRestoreDryRunCondition(cluster, metav1.ConditionFalse, "RestoreDryRunFailed",
+				"Unable to restore: "+message)
+		}
+		return err
+	}
+
+	job := &batchv1.Job{}
+	if err := r.generateRestoreJobIntent(cluster, configHash, "",
+		pgbackrest.RestoreCheckCommand(dataSource.Options,
+			"--stanza="+stanza, "--repo="+regexRepoIndex.FindString(dataSource.RepoName)),
+		nil, nil, dataSource, job); err != nil {
+		return err
+	}
+
+	// The Job restores nothing, so it is neither found nor watched as a restore Job.
+	labels := naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetLabelsOrNil(),
+		naming.PGBackRestRestoreDryRunJobLabels(cluster.Name))
+	job.Name = naming.PGBackRestRestoreDryRunJob(cluster).Name
+	job.Labels, job.Spec.Template.Labels = labels, labels
+
+	// Kubernetes reports the last lines of output when the check fails.
+	// - https://kubernetes.io/docs/tasks/debug/debug-application/determine-reason-pod-failure/
+	job.Spec.Template.Spec.Containers[0].TerminationMessagePolicy =
+		corev1.TerminationMessageFallbackToLogsOnError
+
+	pgbackrest.AddConfigToRestorePod(cluster, sourceCluster, &job.Spec.Template.Spec)
+	addNSSWrapper(
+		config.PGBackRestContainerImage(cluster),
+		cluster.Spec.ImagePullPolicy,
+		&job.Spec.Template)
+	addTMPEmptyDir(&job.Spec.Template, nil)
+
+	if existing.GetUID() == "" {
+		r.setRestoreDryRunCondition(cluster, metav1.ConditionUnknown,
+			"RestoreDryRunRunning", "Checking the restore")
+	}
+	return errors.WithStack(r.applyJob(ctx, job, dataSource.PodFailurePolicy))
+}
+
+// restoreDryRunMessage returns the output of the most recent Pod of job to fail.
+func (r *Reconciler) restoreDryRunMessage(ctx context.Context, job *batchv1.Job) (string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(job.Namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	message := "see the logs of Job " + job.Name
+	var finished metav1.Time
+	for i := range pods.Items {
+		for _, status := range pods.Items[i].Status.ContainerStatuses {
+			if terminated := status.State.Terminated; terminated != nil &&
+				terminated.ExitCode != 0 && terminated.Message != "" &&
+				!terminated.FinishedAt.Before(&finished) {
+				finished = terminated.FinishedAt
+				message = strings.TrimSpace(terminated.Message)
+			}
+		}
+	}
+	return message, nil
+}
+
+// setRestoreDryRunCondition sets the [ConditionPGBackRestRestoreDryRun] condition of cluster
+// and records an event when a dry run finishes.
+func (r *Reconciler) setRestoreDryRunCondition(cluster *v1beta1.PostgresCluster,
+	status metav1.ConditionStatus, reason, message string) {
+
+	previous := meta.FindStatusCondition(cluster.Status.Conditions,
+		ConditionPGBackRestRestoreDryRun)
+	if previous == nil || previous.Status != status || previous.Reason != reason {
+		switch status {
+		case metav1.ConditionTrue:
+			r.Recorder.Event(cluster, corev1.EventTypeNormal, reason, message)
+		case metav1.ConditionFalse:
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, reason, message)
+		}
+	}
+
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionPGBackRestRestoreDryRun,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+	})
+}
@@ -0,0 +1,157 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestRestoreDryRun(t *testing.T) {
+	assert.Assert(t, !restoreDryRun(nil))
+	assert.Assert(t, !restoreDryRun(&v1beta1.PostgresClusterDataSource{}))
+	assert.Assert(t, !restoreDryRun(&v1beta1.PostgresClusterDataSource{
+		DryRun: initialize.Bool(false),
+	}))
+	assert.Assert(t, restoreDryRun(&v1beta1.PostgresClusterDataSource{
+		DryRun: initialize.Bool(true),
+	}))
+}
+
+func TestReconcileRestoreDryRun(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	dataSource := &v1beta1.PostgresClusterDataSource{
+		RepoName: "repo1", DryRun: initialize.Bool(true),
+	}
+
+	job := &batchv1.Job{ObjectMeta: naming.PGBackRestRestoreDryRunJob(cluster)}
+	job.UID = "job-uid" // The fake client does not assign one.
+	job.Annotations = map[string]string{naming.PGBackRestConfigHash: "abc"}
+	job.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"job-name": job.Name},
+	}
+
+	condition := func() *metav1.Condition {
+		return meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionPGBackRestRestoreDryRun)
+	}
+
+	t.Run("InvalidOptions", func(t *testing.T) {
+		recorder := events.NewRecorder(t, scheme)
+		r := &Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).Build(),
+			Recorder: recorder,
+		}
+
+		invalid := dataSource.DeepCopy()
+		invalid.Options = []string{"--set=latest"}
+		assert.NilError(t, r.reconcileRestoreDryRun(ctx, cluster, cluster, invalid, "abc", "db"))
+
+		assert.Equal(t, condition().Status, metav1.ConditionFalse)
+		assert.Equal(t, condition().Reason, ReasonInvalidRestoreOptions)
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Reason, ReasonInvalidRestoreOptions)
+	})
+
+	t.Run("Passed", func(t *testing.T) {
+		passed := job.DeepCopy()
+		passed.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+		}}
+
+		recorder := events.NewRecorder(t, scheme)
+		r := &Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(passed).Build(),
+			Recorder: recorder,
+		}
+		assert.NilError(t, r.reconcileRestoreDryRun(ctx, cluster, cluster, dataSource, "abc", "db"))
+
+		assert.Equal(t, condition().Status, metav1.ConditionTrue)
+		assert.Equal(t, condition().Reason, "RestoreDryRunPassed")
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeNormal)
+
+		// No additional event while the result is the same.
+		assert.NilError(t, r.reconcileRestoreDryRun(ctx, cluster, cluster, dataSource, "abc", "db"))
+		assert.Equal(t, len(recorder.Events), 1)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		failed := job.DeepCopy()
+		failed.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobFailed, Status: corev1.ConditionTrue,
+		}}
+
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = job.Namespace, job.Name+"-xyz"
+		pod.Labels = map[string]string{"job-name": job.Name}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				ExitCode: 1,
+				Message:  "backup set 20220101-000000F does not exist in the repository\n",
+			}},
+		}}
+
+		recorder := events.NewRecorder(t, scheme)
+		r := &Reconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(failed, pod).Build(),
+			Recorder: recorder,
+		}
+		assert.NilError(t, r.reconcileRestoreDryRun(ctx, cluster, cluster, dataSource, "abc", "db"))
+
+		assert.Equal(t, condition().Status, metav1.ConditionFalse)
+		assert.Equal(t, condition().Reason, "RestoreDryRunFailed")
+		assert.Equal(t, condition().Message,
+			"Unable to restore: backup set 20220101-000000F does not exist in the repository")
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		recorder := events.NewRecorder(t, scheme)
+		r := &Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(job.DeepCopy()).Build(),
+			Recorder: recorder,
+		}
+		assert.NilError(t, r.reconcileRestoreDryRun(ctx, cluster, cluster, dataSource, "def", "db"))
+
+		assert.Equal(t, condition().Status, metav1.ConditionUnknown)
+		err := r.Client.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		assert.Assert(t, apierrors.IsNotFound(err), "expected the Job to be deleted, got %v", err)
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+}
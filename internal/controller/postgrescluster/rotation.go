@@ -0,0 +1,484 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/internal/pki"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionCertificatesReady is the type used in a condition to indicate
+	// whether or not the certificates of a PostgresCluster were last rotated
+	// successfully.
+	ConditionCertificatesReady = "CertificatesReady"
+
+	// certificateRotationInterval is how often the certificates of a cluster
+	// are checked for renewal.
+	certificateRotationInterval = time.Hour
+
+	// certificateRotationBackoff is the first delay after a failed rotation.
+	// The delay doubles with every failure up to certificateRotationInterval.
+	certificateRotationBackoff = 5 * time.Second
+
+	// ConditionRepoHostCertificateCurrent is the type used in a condition to
+	// indicate whether or not the TLS server of a pgBackRest dedicated repository
+	// host presents the certificate most recently generated for it.
+	ConditionRepoHostCertificateCurrent = "PGBackRestRepoHostCertificateCurrent"
+
+	// certificateReloadGrace is how long a repository host has to load a new
+	// certificate before the one it presents is stale. The kubelet updates
+	// mounted Secrets periodically, and the repository host looks for changes
+	// to them every few seconds.
+	certificateReloadGrace = 5 * time.Minute
+
+	// certificateReloadInterval is how often a repository host is checked
+	// while it is expected to load a new certificate.
+	certificateReloadInterval = time.Minute
+
+	// certificateReloadTimeout limits how long a check of the certificate
+	// presented by a repository host can take.
+	certificateReloadTimeout = 10 * time.Second
+)
+
+// certificateRotationReconciler renews the certificates of a PostgresCluster
+// on its own interval. Certificates are also reconciled by the PostgresCluster
+// Reconciler, but a cluster that takes a long time to reconcile should not
+// delay their renewal. It shares its client, field owner, and tracer with the
+// PostgresCluster Reconciler.
+type certificateRotationReconciler struct {
+	*Reconciler
+}
+
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={get,list,watch}
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters/status",verbs={patch}
+
+// setupWithManager adds the certificate rotation controller to the provided
+// runtime manager. It has its own workqueue so that rotation is never waiting
+// behind other reconciles of the cluster.
+func (r *certificateRotationReconciler) setupWithManager(mgr manager.Manager) error {
+	return builder.ControllerManagedBy(mgr).
+		Named("postgrescluster-certificates").
+		For(&v1beta1.PostgresCluster{},
+			// Ignore changes to status, including the ones made here.
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+			RateLimiter: workqueue.NewItemExponentialFailureRateLimiter(
+				certificateRotationBackoff, certificateRotationInterval),
+		}).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={get,create,patch}
+// +kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={list}
+
+// Reconcile renews the root certificate authority, the cluster certificate,
+// the replication client certificate, and the pgBackRest certificates of a
+// PostgresCluster when they are missing, invalid, or close to expiring. The
+// outcome is reported in the [ConditionCertificatesReady] condition, and
+// certificates past their renewal time are reported in the
+// [ConditionCertificatesExpiringSoon] condition. Whether the repository host
+// has loaded its certificate is reported in the
+// [ConditionRepoHostCertificateCurrent] condition, and the progress of moving
+// pgBackRest to a new root certificate authority is reported in the
+// [pgbackrest.ConditionAuthorityRotation] condition.
+func (r *certificateRotationReconciler) Reconcile(
+	ctx context.Context, request reconcile.Request) (reconcile.Result, error,
+) {
+	ctx, span := r.Tracer.Start(ctx, "ReconcileCertificates")
+	log := logging.FromContext(ctx)
+	defer span.End()
+
+	cluster := &v1beta1.PostgresCluster{}
+	if err := r.Client.Get(ctx, request.NamespacedName, cluster); err != nil {
+		// NotFound cannot be fixed by requeuing so ignore it.
+		if err = client.IgnoreNotFound(err); err != nil {
+			log.Error(err, "unable to fetch PostgresCluster")
+			span.RecordError(err)
+		} else {
+			r.certificates.forget(request.NamespacedName)
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Leave clusters that are being deleted or whose reconciliation is paused.
+	if cluster.DeletionTimestamp != nil {
+		r.certificates.forget(request.NamespacedName)
+		return reconcile.Result{}, nil
+	}
+	if cluster.Spec.Paused != nil && *cluster.Spec.Paused {
+		return reconcile.Result{}, nil
+	}
+
+	cluster.Default()
+	before := cluster.DeepCopy()
+
+	err := r.rotateCertificates(ctx, cluster)
+
+	condition := metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionCertificatesReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Current",
+		Message:            "Certificates are valid and not due for renewal",
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "RotationFailed"
+		condition.Message = err.Error()
+
+		log.Error(err, "rotating certificates")
+		span.RecordError(err)
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "CertificateRotationFailed",
+			err.Error())
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+
+	// Look at the certificates even when rotation fails; those are the ones
+	// most likely to expire.
+	r.reportCertificateExpiry(ctx, cluster)
+
+	// Write only what this controller changed; see [Reconciler.patchStatus].
+	if patchErr := r.patchStatus(ctx, before, cluster); patchErr != nil {
+		log.Error(patchErr, "patching cluster status")
+		if err == nil {
+			err = patchErr
+		}
+	}
+
+	// Errors are retried with the backoff of this controller's workqueue.
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if condition := meta.FindStatusCondition(cluster.Status.Conditions,
+		ConditionRepoHostCertificateCurrent); condition != nil &&
+		condition.Reason == "ReloadPending" {
+		return reconcile.Result{RequeueAfter: certificateReloadInterval}, nil
+	}
+	if meta.IsStatusConditionTrue(cluster.Status.Conditions,
+		pgbackrest.ConditionAuthorityRotation) {
+		return reconcile.Result{RequeueAfter: certificateReloadInterval}, nil
+	}
+	return reconcile.Result{RequeueAfter: certificateRotationInterval}, nil
+}
+
+// reportCertificateExpiry records when the certificates of cluster expire and
+// sets the [ConditionCertificatesExpiringSoon] condition. A Warning event is
+// emitted when a certificate first becomes due.
+func (r *certificateRotationReconciler) reportCertificateExpiry(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) {
+	certificates, err := r.clusterCertificates(ctx, cluster)
+	if err != nil {
+		logging.FromContext(ctx).Error(err, "reading certificates")
+		return
+	}
+	r.certificates.remember(client.ObjectKeyFromObject(cluster), certificates)
+
+	condition := certificateExpiryCondition(cluster, certificates, time.Now())
+	previous := meta.FindStatusCondition(cluster.Status.Conditions, condition.Type)
+
+	if condition.Status == metav1.ConditionTrue &&
+		(previous == nil || previous.Status != metav1.ConditionTrue) {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "CertificatesExpiringSoon",
+			condition.Message)
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+}
+
+// rotateCertificates renews the certificates of cluster that are signed by the
+// root certificate authority of its namespace.
+func (r *certificateRotationReconciler) rotateCertificates(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	root, err := r.reconcileRootCertificate(ctx, cluster)
+
+	if err == nil {
+		// Only the name and namespace of the Service are used for its DNS names.
+		primaryService := &corev1.Service{ObjectMeta: naming.ClusterPrimaryService(cluster)}
+		_, err = r.reconcileClusterCertificate(ctx, root, cluster, primaryService)
+	}
+	if err == nil {
+		_, err = r.reconcileReplicationSecret(ctx, cluster, root)
+	}
+	if err == nil {
+		err = r.rotatePGBackRestCertificates(ctx, cluster, root)
+	}
+	return err
+}
+
+// rotatePGBackRestCertificates renews the pgBackRest certificates of cluster
+// when it has a dedicated repository host that exists. It then checks that the
+// repository host presents its current certificate.
+func (r *certificateRotationReconciler) rotatePGBackRestCertificates(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, root *pki.RootCertificateAuthority,
+) error {
+	var repoHost *appsv1.StatefulSet
+	var err error
+
+	if pgbackrest.DedicatedRepoHostEnabled(cluster) {
+		hosts := &appsv1.StatefulSetList{}
+		err = errors.WithStack(r.Client.List(ctx, hosts,
+			client.InNamespace(cluster.Namespace),
+			client.MatchingLabelsSelector{
+				Selector: naming.PGBackRestDedicatedSelector(cluster.Name),
+			}))
+
+		// Use the oldest repository host, like [Reconciler.reconcileDedicatedRepoHost].
+		for i := range hosts.Items {
+			if repoHost == nil || hosts.Items[i].CreationTimestamp.Before(&repoHost.CreationTimestamp) {
+				repoHost = &hosts.Items[i]
+			}
+		}
+	}
+
+	// Without a repository host, the pgBackRest Secret is left to the
+	// PostgresCluster Reconciler.
+	if err != nil || repoHost == nil {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionRepoHostCertificateCurrent)
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, pgbackrest.ConditionAuthorityRotation)
+		return err
+	}
+
+	err = r.reconcilePGBackRestSecret(ctx, cluster, repoHost, root)
+	if err == nil {
+		r.checkRepoHostCertificate(ctx, cluster, repoHost)
+		err = r.advanceAuthorityRotation(ctx, cluster, repoHost, root)
+	}
+	return err
+}
+
+// +kubebuilder:rbac:groups="",resources="pods",verbs={list}
+
+// advanceAuthorityRotation moves the pgBackRest certificates of cluster to root
+// when the pgBackRest Secret still trusts authorities that root is replacing.
+// Every pgBackRest server must trust root before certificates signed by it are
+// issued, and the repository host must present its new certificate before the
+// previous authorities are removed. Each step is reported in the
+// [pgbackrest.ConditionAuthorityRotation] condition, which [pgbackrest.Secret]
+// follows on the next reconcile.
+func (r *certificateRotationReconciler) advanceAuthorityRotation(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	repoHost *appsv1.StatefulSet, root *pki.RootCertificateAuthority,
+) error {
+	secret := &corev1.Secret{ObjectMeta: naming.PGBackRestSecret(cluster)}
+	if err := errors.WithStack(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)); err != nil {
+		return err
+	}
+
+	condition := metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               pgbackrest.ConditionAuthorityRotation,
+		Status:             metav1.ConditionTrue,
+	}
+	previous := meta.FindStatusCondition(cluster.Status.Conditions, condition.Type)
+	rotating := previous != nil && previous.Status == metav1.ConditionTrue
+
+	// The rotation is done when only one authority remains. There is nothing
+	// to report for a cluster that has never rotated.
+	if len(pgbackrest.PreviousAuthorities(secret, root)) == 0 {
+		if rotating {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = pgbackrest.AuthorityRotationComplete
+			condition.Message = "pgBackRest trusts only the current certificate authority"
+			meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+
+			r.Recorder.Event(cluster, corev1.EventTypeNormal,
+				"CertificateAuthorityRotated", condition.Message)
+		}
+		return nil
+	}
+
+	condition.Reason = pgbackrest.AuthorityRotationTrusting
+	if rotating {
+		condition.Reason = previous.Reason
+	}
+
+	switch condition.Reason {
+	case pgbackrest.AuthorityRotationReissuing:
+		condition.Message = "Waiting for pgBackRest repository host to present a certificate signed by the current certificate authority"
+
+		if pgbackrest.CertificatesIssuedBy(secret, root) && repoHost.Status.ReadyReplicas > 0 {
+			ctx, cancel := context.WithTimeout(ctx, certificateReloadTimeout)
+			current, err := pgbackrest.ServesCertificate(ctx, cluster, repoHost, secret)
+			cancel()
+
+			if err == nil && current {
+				condition.Reason = pgbackrest.AuthorityRotationRetiring
+				condition.Message = "Removing previous certificate authorities from pgBackRest"
+			}
+		}
+
+	case pgbackrest.AuthorityRotationRetiring:
+		condition.Message = "Removing previous certificate authorities from pgBackRest"
+
+	default:
+		condition.Reason = pgbackrest.AuthorityRotationTrusting
+
+		server, err := r.untrustingServer(ctx, cluster, repoHost, root)
+		if err != nil {
+			return err
+		}
+
+		condition.Message = "Waiting for pgBackRest server " + server +
+			" to trust the current certificate authority"
+
+		if server == "" {
+			condition.Reason = pgbackrest.AuthorityRotationReissuing
+			condition.Message = "Issuing pgBackRest certificates signed by the current certificate authority"
+		}
+	}
+
+	if previous == nil || previous.Reason != condition.Reason {
+		r.Recorder.Event(cluster, corev1.EventTypeNormal, condition.Reason, condition.Message)
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+	return nil
+}
+
+// untrustingServer returns the name of a pgBackRest server of cluster that does
+// not accept client certificates signed by root. It returns an empty string
+// when the repository host and every running instance accept them.
+func (r *certificateRotationReconciler) untrustingServer(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	repoHost *appsv1.StatefulSet, root *pki.RootCertificateAuthority,
+) (string, error) {
+	log := logging.FromContext(ctx)
+
+	pods := &corev1.PodList{}
+	selector, err := naming.AsSelector(naming.ClusterInstances(cluster.Name))
+	if err == nil {
+		err = errors.WithStack(r.Client.List(ctx, pods,
+			client.InNamespace(cluster.Namespace),
+			client.MatchingLabelsSelector{Selector: selector}))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// trusts reports whether or not the server at address accepts root. A
+	// server that cannot be reached is checked again later.
+	trusts := func(address string) bool {
+		ctx, cancel := context.WithTimeout(ctx, certificateReloadTimeout)
+		defer cancel()
+
+		ok, err := pgbackrest.TrustsAuthority(ctx, cluster, root, address)
+		if err != nil {
+			log.V(1).Info("unable to check pgBackRest server", "address", address, "error", err.Error())
+		}
+		return ok
+	}
+
+	if repoHost.Status.ReadyReplicas == 0 ||
+		!trusts(pgbackrest.RepoHostAddress(ctx, cluster, repoHost)) {
+		return repoHost.Name, nil
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+			continue
+		}
+		if !trusts(net.JoinHostPort(pod.Status.PodIP, fmt.Sprint(pgbackrest.IANAPortNumber))) {
+			return pod.Name, nil
+		}
+	}
+
+	return "", nil
+}
+
+// checkRepoHostCertificate compares the certificate presented by the TLS server
+// of repoHost to the one in the pgBackRest Secret of cluster. The result is
+// reported in the [ConditionRepoHostCertificateCurrent] condition.
+func (r *certificateRotationReconciler) checkRepoHostCertificate(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, repoHost *appsv1.StatefulSet,
+) {
+	log := logging.FromContext(ctx)
+
+	// There is nothing to check until the repository host is running.
+	if repoHost.Status.ReadyReplicas == 0 {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionRepoHostCertificateCurrent)
+		return
+	}
+
+	secret := &corev1.Secret{ObjectMeta: naming.PGBackRestSecret(cluster)}
+	err := errors.WithStack(r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret))
+
+	var current bool
+	if err == nil {
+		ctx, cancel := context.WithTimeout(ctx, certificateReloadTimeout)
+		current, err = pgbackrest.ServesCertificate(ctx, cluster, repoHost, secret)
+		cancel()
+	}
+
+	condition := metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionRepoHostCertificateCurrent,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Current",
+		Message:            "pgBackRest repository host presents its current certificate",
+	}
+	previous := meta.FindStatusCondition(cluster.Status.Conditions, condition.Type)
+
+	switch {
+	case err != nil:
+		log.V(1).Info("unable to check repository host certificate", "error", err.Error())
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = "Unreachable"
+		condition.Message = err.Error()
+
+	case !current && previous != nil && previous.Status == metav1.ConditionFalse &&
+		time.Since(previous.LastTransitionTime.Time) > certificateReloadGrace:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "StaleCertificate"
+		condition.Message = "pgBackRest repository host has not loaded its current certificate"
+
+		if previous.Reason != condition.Reason {
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, "StaleCertificate",
+				condition.Message)
+		}
+
+	case !current:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ReloadPending"
+		condition.Message = "Waiting for pgBackRest repository host to load its current certificate"
+	}
+
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+}
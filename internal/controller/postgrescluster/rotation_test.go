@@ -0,0 +1,221 @@
+//go:build envtest
+// +build envtest
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/internal/pki"
+	"github.com/crunchydata/postgres-operator/internal/testing/require"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestCertificateRotationReconcile(t *testing.T) {
+	ctx := context.Background()
+	_, tClient := setupKubernetes(t)
+	require.ParallelCapacity(t, 1)
+
+	reconciler := &certificateRotationReconciler{&Reconciler{
+		Client:   tClient,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: new(record.FakeRecorder),
+		Tracer:   otel.Tracer(t.Name()),
+	}}
+
+	ns := setupNamespace(t, tClient)
+	reconcileCluster := func(t *testing.T, cluster *v1beta1.PostgresCluster) (reconcile.Result, error) {
+		t.Helper()
+		assert.NilError(t, tClient.Create(ctx, cluster))
+		t.Cleanup(func() { assert.Check(t, client.IgnoreNotFound(tClient.Delete(ctx, cluster))) })
+
+		result, err := reconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(cluster),
+		})
+		assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+		return result, err
+	}
+
+	t.Run("Current", func(t *testing.T) {
+		cluster := testCluster()
+		cluster.Namespace, cluster.Name = ns.Name, "current"
+
+		result, err := reconcileCluster(t, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter, certificateRotationInterval)
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionCertificatesReady)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.Reason, "Current")
+
+		for _, object := range []metav1.ObjectMeta{
+			{Namespace: ns.Name, Name: naming.RootCertSecret},
+			naming.PostgresTLSSecret(cluster),
+			naming.ReplicationClientCertSecret(cluster),
+		} {
+			secret := &corev1.Secret{ObjectMeta: object}
+			assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(secret), secret),
+				"expected %q to exist", object.Name)
+		}
+
+		// The new certificates are far from expiring.
+		condition = meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionCertificatesExpiringSoon)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+
+		// There is no repository host yet.
+		assert.Assert(t, meta.FindStatusCondition(
+			cluster.Status.Conditions, ConditionRepoHostCertificateCurrent) == nil)
+	})
+
+	t.Run("RepoHost", func(t *testing.T) {
+		cluster := testCluster()
+		cluster.Namespace, cluster.Name = ns.Name, "repo-host"
+		cluster.Spec.Backups.PGBackRest.RepoHost = &v1beta1.PGBackRestRepoHost{
+			CertificateDurationDays: initialize.Int32(30),
+		}
+
+		labels := naming.PGBackRestDedicatedLabels(cluster.Name)
+		repoHost := &appsv1.StatefulSet{}
+		repoHost.Namespace, repoHost.Name = ns.Name, "repo-host-repo-host"
+		repoHost.Spec.Selector = &metav1.LabelSelector{MatchLabels: labels}
+		repoHost.Spec.Template.Labels = labels
+		repoHost.Spec.Template.Spec.Containers = []corev1.Container{{Name: "pgbackrest", Image: "image"}}
+		assert.NilError(t, tClient.Create(ctx, repoHost))
+		t.Cleanup(func() { assert.Check(t, tClient.Delete(ctx, repoHost)) })
+
+		repoHost.Status.Replicas, repoHost.Status.ReadyReplicas = 1, 1
+		assert.NilError(t, tClient.Status().Update(ctx, repoHost))
+
+		result, err := reconcileCluster(t, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter, certificateRotationInterval)
+
+		// The repository host has a certificate.
+		secret := &corev1.Secret{ObjectMeta: naming.PGBackRestSecret(cluster)}
+		assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(secret), secret))
+		assert.Assert(t, len(secret.Data["pgbackrest-repo-host.crt"]) > 0)
+
+		// The repository host is not running in this environment.
+		condition := meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionRepoHostCertificateCurrent)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionUnknown)
+		assert.Equal(t, condition.Reason, "Unreachable")
+
+		// Nothing is rotating between authorities.
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			pgbackrest.ConditionAuthorityRotation) == nil)
+
+		t.Run("AuthorityRotation", func(t *testing.T) {
+			reconcileAgain := func(t *testing.T) reconcile.Result {
+				t.Helper()
+				result, err := reconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: client.ObjectKeyFromObject(cluster),
+				})
+				assert.NilError(t, err)
+				assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(cluster), cluster))
+				assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(secret), secret))
+				return result
+			}
+
+			// The pgBackRest Secret trusts an authority that is being replaced.
+			previous, err := pki.NewRootCertificateAuthority()
+			assert.NilError(t, err)
+			previousPEM, err := previous.Certificate.MarshalText()
+			assert.NilError(t, err)
+
+			secret.Data["pgbackrest.ca-roots"] = append(secret.Data["pgbackrest.ca-roots"], previousPEM...)
+			assert.NilError(t, tClient.Update(ctx, secret))
+
+			// The rotation waits for the repository host, which is not running
+			// in this environment. The previous authority remains.
+			result := reconcileAgain(t)
+			assert.Equal(t, result.RequeueAfter, certificateReloadInterval)
+			assert.Assert(t, bytes.Contains(secret.Data["pgbackrest.ca-roots"], previousPEM))
+
+			condition := meta.FindStatusCondition(cluster.Status.Conditions,
+				pgbackrest.ConditionAuthorityRotation)
+			assert.Assert(t, condition != nil)
+			assert.Equal(t, condition.Status, metav1.ConditionTrue)
+			assert.Equal(t, condition.Reason, pgbackrest.AuthorityRotationTrusting)
+			assert.Assert(t, strings.Contains(condition.Message, repoHost.Name), "%q", condition.Message)
+
+			// The previous authority is removed in the last step.
+			condition.Reason = pgbackrest.AuthorityRotationRetiring
+			meta.SetStatusCondition(&cluster.Status.Conditions, *condition)
+			assert.NilError(t, tClient.Status().Update(ctx, cluster))
+
+			reconcileAgain(t)
+			assert.Assert(t, !bytes.Contains(secret.Data["pgbackrest.ca-roots"], previousPEM))
+
+			condition = meta.FindStatusCondition(cluster.Status.Conditions,
+				pgbackrest.ConditionAuthorityRotation)
+			assert.Assert(t, condition != nil)
+			assert.Equal(t, condition.Status, metav1.ConditionFalse)
+			assert.Equal(t, condition.Reason, pgbackrest.AuthorityRotationComplete)
+		})
+	})
+
+	t.Run("RotationFailed", func(t *testing.T) {
+		cluster := testCluster()
+		cluster.Namespace, cluster.Name = ns.Name, "failing"
+		cluster.Spec.CustomReplicationClientTLSSecret = &corev1.SecretProjection{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "missing"},
+		}
+
+		result, err := reconcileCluster(t, cluster)
+		assert.ErrorContains(t, err, "missing")
+		assert.Equal(t, result, reconcile.Result{}, "expected workqueue backoff")
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionCertificatesReady)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "RotationFailed")
+	})
+
+	t.Run("Paused", func(t *testing.T) {
+		cluster := testCluster()
+		cluster.Namespace, cluster.Name = ns.Name, "paused"
+		cluster.Spec.Paused = initialize.Bool(true)
+
+		result, err := reconcileCluster(t, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, result, reconcile.Result{})
+		assert.Assert(t, meta.FindStatusCondition(
+			cluster.Status.Conditions, ConditionCertificatesReady) == nil)
+	})
+}
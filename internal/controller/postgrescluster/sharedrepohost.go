@@ -0,0 +1,506 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/internal/pki"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// sharedRepoHostReconciler reconciles the pgBackRest repository host of a
+// SharedRepoHost. It shares its client, field owner, and tracer with the
+// PostgresCluster Reconciler.
+type sharedRepoHostReconciler struct {
+	*Reconciler
+}
+
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="sharedrepohosts",verbs={get,list,watch}
+// +kubebuilder:rbac:groups="",resources="configmaps",verbs={get,list,watch}
+// +kubebuilder:rbac:groups="",resources="persistentvolumeclaims",verbs={get,list,watch}
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={get,list,watch}
+// +kubebuilder:rbac:groups="",resources="services",verbs={get,list,watch}
+// +kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={get,list,watch}
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={list,watch}
+
+// setupWithManager adds the SharedRepoHost controller to the provided runtime manager
+func (r *sharedRepoHostReconciler) setupWithManager(mgr manager.Manager) error {
+	return builder.ControllerManagedBy(mgr).
+		For(&v1beta1.SharedRepoHost{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&corev1.Secret{}).
+		Owns(&corev1.Service{}).
+		Owns(&appsv1.StatefulSet{}).
+		Watches(&source.Kind{Type: &v1beta1.PostgresCluster{}}, r.watchPostgresClusters()).
+		Complete(r)
+}
+
+// watchPostgresClusters returns a handler.EventHandler for PostgresClusters.
+// It queues every SharedRepoHost referenced by a repo of the cluster.
+func (*sharedRepoHostReconciler) watchPostgresClusters() handler.Funcs {
+	handle := func(object client.Object, q workqueue.RateLimitingInterface) {
+		cluster, ok := object.(*v1beta1.PostgresCluster)
+		if !ok {
+			return
+		}
+		for _, name := range sharedRepoHostNames(cluster) {
+			q.Add(reconcile.Request{NamespacedName: client.ObjectKey{
+				Namespace: cluster.Namespace,
+				Name:      name,
+			}})
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			handle(e.Object, q)
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			// A cluster may stop using a repository host, so queue both.
+			handle(e.ObjectOld, q)
+			handle(e.ObjectNew, q)
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			handle(e.Object, q)
+		},
+	}
+}
+
+// sharedRepoHostNames returns the names of the SharedRepoHosts used by the
+// repos of cluster.
+func sharedRepoHostNames(cluster *v1beta1.PostgresCluster) []string {
+	var names []string
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		if repo.SharedHost != nil {
+			names = append(names, repo.SharedHost.Name)
+		}
+	}
+	return names
+}
+
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="sharedrepohosts/status",verbs={patch}
+// +kubebuilder:rbac:groups="",resources="configmaps",verbs={create,patch}
+// +kubebuilder:rbac:groups="",resources="persistentvolumeclaims",verbs={create,patch}
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={create,patch}
+// +kubebuilder:rbac:groups="",resources="services",verbs={create,patch}
+// +kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={create,patch}
+
+// Reconcile reconciles the repository host of a SharedRepoHost and the
+// pgBackRest configuration of every PostgresCluster that stores backups on it.
+func (r *sharedRepoHostReconciler) Reconcile(
+	ctx context.Context, request reconcile.Request) (reconcile.Result, error,
+) {
+	ctx, span := r.Tracer.Start(ctx, "ReconcileSharedRepoHost")
+	log := logging.FromContext(ctx)
+	defer span.End()
+
+	host := &v1beta1.SharedRepoHost{}
+	if err := r.Client.Get(ctx, request.NamespacedName, host); err != nil {
+		// NotFound cannot be fixed by requeuing so ignore it. Kubernetes
+		// garbage collects everything the host owned.
+		if err = client.IgnoreNotFound(err); err != nil {
+			log.Error(err, "unable to fetch SharedRepoHost")
+			span.RecordError(err)
+		}
+		return reconcile.Result{}, err
+	}
+	before := host.DeepCopy()
+
+	clusters, err := r.sharedRepoHostClusters(ctx, host)
+
+	// The server certificate of the repository host is signed by the root
+	// certificate authority of the namespace. That is created by any cluster
+	// in the namespace, so wait for one to exist.
+	var root *pki.RootCertificateAuthority
+	if err == nil {
+		root, err = r.sharedRepoHostRootCertificate(ctx, host)
+	}
+	if err == nil && root == nil {
+		log.V(1).Info("waiting for the root certificate authority")
+		return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+
+	var pgHosts map[string][]string
+	var clusterSecrets map[string]*corev1.Secret
+	if err == nil {
+		pgHosts, clusterSecrets, err = r.observeSharedRepoHostClusters(ctx, clusters)
+	}
+
+	var repoHost *appsv1.StatefulSet
+	if err == nil {
+		err = r.reconcileSharedRepoHostVolume(ctx, host)
+	}
+	if err == nil {
+		err = r.reconcileSharedRepoHostService(ctx, host)
+	}
+	if err == nil {
+		repoHost, err = r.reconcileSharedRepoHostStatefulSet(ctx, host, clusters)
+	}
+	if err == nil {
+		err = r.reconcileSharedRepoHostConfig(ctx, host, clusters, pgHosts)
+	}
+	if err == nil {
+		err = r.reconcileSharedRepoHostSecret(ctx, host, repoHost, root, clusters, clusterSecrets)
+	}
+
+	if err == nil {
+		host.Status.ObservedGeneration = host.GetGeneration()
+		host.Status.Stanzas = nil
+		for _, cluster := range clusters {
+			host.Status.Stanzas = append(host.Status.Stanzas, pgbackrest.StanzaName(cluster))
+		}
+		meta.SetStatusCondition(&host.Status.Conditions,
+			sharedRepoHostReadyCondition(host, repoHost))
+	}
+
+	if !equality.Semantic.DeepEqual(before.Status, host.Status) {
+		if patchErr := errors.WithStack(r.Client.Status().Patch(
+			ctx, host, client.MergeFrom(before), r.Owner)); patchErr != nil {
+			log.Error(patchErr, "patching shared repo host status")
+			if err == nil {
+				err = patchErr
+			}
+		}
+	}
+
+	if err != nil {
+		span.RecordError(err)
+	}
+	return reconcile.Result{}, err
+}
+
+// sharedRepoHostClusters returns the PostgresClusters that have a repo on host,
+// sorted by name. Defaults are set on each cluster.
+func (r *sharedRepoHostReconciler) sharedRepoHostClusters(
+	ctx context.Context, host *v1beta1.SharedRepoHost,
+) ([]*v1beta1.PostgresCluster, error) {
+	list := &v1beta1.PostgresClusterList{}
+	err := errors.WithStack(r.Client.List(ctx, list, client.InNamespace(host.Namespace)))
+
+	var clusters []*v1beta1.PostgresCluster
+	for i := range list.Items {
+		cluster := &list.Items[i]
+		for _, name := range sharedRepoHostNames(cluster) {
+			if name == host.Name {
+				cluster.Default()
+				clusters = append(clusters, cluster)
+				break
+			}
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+	return clusters, err
+}
+
+// sharedRepoHostRootCertificate returns the root certificate authority of the
+// namespace of host. It returns nil when the root does not exist or is invalid.
+func (r *sharedRepoHostReconciler) sharedRepoHostRootCertificate(
+	ctx context.Context, host *v1beta1.SharedRepoHost,
+) (*pki.RootCertificateAuthority, error) {
+	const keyCertificate, keyPrivateKey = "root.crt", "root.key"
+
+	existing := &corev1.Secret{}
+	existing.Namespace, existing.Name = host.Namespace, naming.RootCertSecret
+	err := errors.WithStack(client.IgnoreNotFound(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing)))
+
+	root := &pki.RootCertificateAuthority{}
+	if err == nil {
+		_ = root.Certificate.UnmarshalText(existing.Data[keyCertificate])
+		_ = root.PrivateKey.UnmarshalText(existing.Data[keyPrivateKey])
+	}
+	if err != nil || !pki.RootIsValid(root) {
+		return nil, err
+	}
+	return root, nil
+}
+
+// observeSharedRepoHostClusters returns the sorted instance names and the
+// pgBackRest Secret of each of clusters, keyed by cluster name.
+func (r *sharedRepoHostReconciler) observeSharedRepoHostClusters(
+	ctx context.Context, clusters []*v1beta1.PostgresCluster,
+) (map[string][]string, map[string]*corev1.Secret, error) {
+	pgHosts := make(map[string][]string, len(clusters))
+	secrets := make(map[string]*corev1.Secret, len(clusters))
+
+	for _, cluster := range clusters {
+		selector, err := naming.AsSelector(naming.ClusterInstances(cluster.Name))
+		instances := &appsv1.StatefulSetList{}
+		if err == nil {
+			err = errors.WithStack(r.Client.List(ctx, instances,
+				client.InNamespace(cluster.Namespace),
+				client.MatchingLabelsSelector{Selector: selector},
+			))
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		names := []string{}
+		for i := range instances.Items {
+			names = append(names, instances.Items[i].Name)
+		}
+		// sort to ensure consistent ordering of hosts when creating pgBackRest configs
+		sort.Strings(names)
+		pgHosts[cluster.Name] = names
+
+		secret := &corev1.Secret{ObjectMeta: naming.PGBackRestSecret(cluster)}
+		err = errors.WithStack(client.IgnoreNotFound(
+			r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)))
+		if err != nil {
+			return nil, nil, err
+		}
+		secrets[cluster.Name] = secret
+	}
+
+	return pgHosts, secrets, nil
+}
+
+// reconcileSharedRepoHostVolume writes the PersistentVolumeClaim that stores
+// the stanzas of host.
+func (r *sharedRepoHostReconciler) reconcileSharedRepoHostVolume(
+	ctx context.Context, host *v1beta1.SharedRepoHost,
+) error {
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: naming.SharedRepoHostVolume(host)}
+	pvc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
+
+	pvc.Annotations = host.Spec.Metadata.GetAnnotationsOrNil()
+	pvc.Labels = naming.Merge(host.Spec.Metadata.GetLabelsOrNil(),
+		naming.SharedRepoHostLabels(host.Name))
+	pvc.Spec = host.Spec.VolumeClaimSpec
+
+	err := errors.WithStack(r.setSharedRepoHostReference(host, pvc))
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, pvc))
+	}
+	return err
+}
+
+// reconcileSharedRepoHostService writes the headless Service that gives the
+// Pod of host a stable DNS name.
+func (r *sharedRepoHostReconciler) reconcileSharedRepoHostService(
+	ctx context.Context, host *v1beta1.SharedRepoHost,
+) error {
+	service := &corev1.Service{ObjectMeta: naming.SharedRepoHost(host)}
+	service.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
+
+	service.Annotations = host.Spec.Metadata.GetAnnotationsOrNil()
+	service.Labels = naming.Merge(host.Spec.Metadata.GetLabelsOrNil(),
+		naming.SharedRepoHostLabels(host.Name))
+
+	// Allocate no IP address (headless) and match the Pod of host, regardless
+	// of its readiness, so that it gets a DNS record based on its name.
+	// - https://docs.k8s.io/concepts/services-networking/service/#headless-services
+	// - https://docs.k8s.io/concepts/services-networking/dns-pod-service/#pods
+	service.Spec.ClusterIP = corev1.ClusterIPNone
+	service.Spec.PublishNotReadyAddresses = true
+	service.Spec.Selector = naming.SharedRepoHostLabels(host.Name)
+
+	err := errors.WithStack(r.setSharedRepoHostReference(host, service))
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, service))
+	}
+	return err
+}
+
+// reconcileSharedRepoHostStatefulSet writes the StatefulSet of the repository
+// host and returns it.
+func (r *sharedRepoHostReconciler) reconcileSharedRepoHostStatefulSet(
+	ctx context.Context, host *v1beta1.SharedRepoHost, clusters []*v1beta1.PostgresCluster,
+) (*appsv1.StatefulSet, error) {
+	sts := generateSharedRepoHostIntent(host, clusters)
+
+	err := errors.WithStack(r.setSharedRepoHostReference(host, sts))
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, sts))
+	}
+	return sts, err
+}
+
+// generateSharedRepoHostIntent returns the StatefulSet of the repository host
+// of host that stores the stanzas of clusters.
+func generateSharedRepoHostIntent(
+	host *v1beta1.SharedRepoHost, clusters []*v1beta1.PostgresCluster,
+) *appsv1.StatefulSet {
+	annotations := host.Spec.Metadata.GetAnnotationsOrNil()
+	labels := naming.Merge(host.Spec.Metadata.GetLabelsOrNil(),
+		naming.SharedRepoHostLabels(host.Name))
+
+	sts := &appsv1.StatefulSet{ObjectMeta: naming.SharedRepoHost(host)}
+	sts.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("StatefulSet"))
+	sts.Annotations = annotations
+	sts.Labels = labels
+
+	sts.Spec.Replicas = initialize.Int32(1)
+	sts.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: naming.SharedRepoHostLabels(host.Name),
+	}
+	sts.Spec.ServiceName = naming.SharedRepoHost(host).Name
+
+	template := &sts.Spec.Template
+	template.Annotations = annotations
+	template.Labels = labels
+
+	template.Spec.Affinity = host.Spec.Affinity
+	template.Spec.Tolerations = host.Spec.Tolerations
+	template.Spec.TopologySpreadConstraints = host.Spec.TopologySpreadConstraints
+	if host.Spec.PriorityClassName != nil {
+		template.Spec.PriorityClassName = *host.Spec.PriorityClassName
+	}
+
+	// Set the image pull secrets, if any exist.
+	template.Spec.ImagePullSecrets = host.Spec.ImagePullSecrets
+
+	// Restart containers any time they stop, die, are killed, etc.
+	template.Spec.RestartPolicy = corev1.RestartPolicyAlways
+
+	// The pgBackRest TLS server must be signaled when its configuration or
+	// certificates change. Let containers see each other's processes.
+	// - https://docs.k8s.io/tasks/configure-pod-container/share-process-namespace/
+	template.Spec.ShareProcessNamespace = initialize.Bool(true)
+
+	// pgBackRest does not make any Kubernetes API calls. Use the default
+	// ServiceAccount and do not mount its credentials.
+	template.Spec.AutomountServiceAccountToken = initialize.Bool(false)
+
+	// Do not add environment variables describing services in this namespace.
+	template.Spec.EnableServiceLinks = initialize.Bool(false)
+
+	// Set a filesystem group so pgBackRest can write to the repository volume
+	// regardless of the UID or GID of a container.
+	template.Spec.SecurityContext = initialize.PodSecurityContext()
+	template.Spec.SecurityContext.FSGroup = initialize.Int64(26)
+
+	pgbackrest.AddServerToSharedRepoPod(host, &template.Spec)
+	pgbackrest.AddConfigToSharedRepoPod(host, clusters, &template.Spec)
+
+	// add nss_wrapper init container and add nss_wrapper env vars to the pgbackrest
+	// container
+	addNSSWrapper(
+		config.SharedRepoHostContainerImage(host),
+		host.Spec.ImagePullPolicy,
+		template)
+
+	addTMPEmptyDir(template, nil)
+
+	return sts
+}
+
+// sharedRepoHostReadyCondition returns the ConditionRepoHostReady condition of
+// host based on the status of its StatefulSet.
+func sharedRepoHostReadyCondition(
+	host *v1beta1.SharedRepoHost, repoHost *appsv1.StatefulSet,
+) metav1.Condition {
+	condition := metav1.Condition{
+		ObservedGeneration: host.GetGeneration(),
+		Type:               ConditionRepoHostReady,
+	}
+	if repoHost.Status.ReadyReplicas > 0 &&
+		repoHost.Status.ObservedGeneration == repoHost.GetGeneration() {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "RepoHostReady"
+		condition.Message = "pgBackRest shared repository host is ready"
+	} else {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "RepoHostNotReady"
+		condition.Message = "pgBackRest shared repository host is not ready"
+	}
+	return condition
+}
+
+// reconcileSharedRepoHostConfig writes the pgBackRest ConfigMap of host.
+func (r *sharedRepoHostReconciler) reconcileSharedRepoHostConfig(
+	ctx context.Context, host *v1beta1.SharedRepoHost,
+	clusters []*v1beta1.PostgresCluster, pgHosts map[string][]string,
+) error {
+	configmap := pgbackrest.CreateSharedRepoHostConfigMapIntent(ctx, host, clusters, pgHosts)
+
+	err := errors.WithStack(r.setSharedRepoHostReference(host, configmap))
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, configmap))
+	}
+	return err
+}
+
+// reconcileSharedRepoHostSecret writes the pgBackRest Secret of host.
+func (r *sharedRepoHostReconciler) reconcileSharedRepoHostSecret(
+	ctx context.Context, host *v1beta1.SharedRepoHost, repoHost *appsv1.StatefulSet,
+	root *pki.RootCertificateAuthority, clusters []*v1beta1.PostgresCluster,
+	clusterSecrets map[string]*corev1.Secret,
+) error {
+	intent := &corev1.Secret{ObjectMeta: naming.SharedRepoHostSecret(host)}
+	intent.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+	intent.Type = corev1.SecretTypeOpaque
+
+	intent.Annotations = host.Spec.Metadata.GetAnnotationsOrNil()
+	intent.Labels = naming.Merge(host.Spec.Metadata.GetLabelsOrNil(),
+		naming.SharedRepoHostLabels(host.Name))
+
+	existing := &corev1.Secret{}
+	err := errors.WithStack(client.IgnoreNotFound(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(intent), existing)))
+
+	if err == nil {
+		err = errors.WithStack(r.setSharedRepoHostReference(host, intent))
+	}
+	if err == nil {
+		err = pgbackrest.SharedRepoHostSecret(ctx, repoHost, root,
+			clusters, clusterSecrets, existing, intent)
+	}
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, intent))
+	}
+	return err
+}
+
+// The owner reference created by controllerutil.SetControllerReference blocks
+// deletion, which requires "update" permission on the owner's "finalizers".
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="sharedrepohosts/finalizers",verbs={update}
+
+// setSharedRepoHostReference sets host as a Controller OwnerReference on controlled.
+func (r *sharedRepoHostReconciler) setSharedRepoHostReference(
+	host *v1beta1.SharedRepoHost, controlled client.Object,
+) error {
+	return controllerutil.SetControllerReference(host, controlled, r.Client.Scheme())
+}
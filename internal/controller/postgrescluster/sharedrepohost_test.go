@@ -0,0 +1,146 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestSharedRepoHostWatchPostgresClusters(t *testing.T) {
+	queue := controllertest.Queue{Interface: workqueue.New()}
+	reconciler := &sharedRepoHostReconciler{}
+
+	update := reconciler.watchPostgresClusters().UpdateFunc
+	assert.Assert(t, update != nil)
+
+	// No shared repos; no reconcile.
+	update(event.UpdateEvent{
+		ObjectOld: &v1beta1.PostgresCluster{},
+		ObjectNew: &v1beta1.PostgresCluster{},
+	}, queue)
+	assert.Equal(t, queue.Len(), 0)
+
+	// Moving a repo from one host to another reconciles both.
+	cluster := func(host string) *v1beta1.PostgresCluster {
+		c := &v1beta1.PostgresCluster{}
+		c.Namespace, c.Name = "ns1", "hippo"
+		c.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{
+			Name:       "repo1",
+			SharedHost: &v1beta1.RepoSharedHost{Name: host},
+		}}
+		return c
+	}
+	update(event.UpdateEvent{
+		ObjectOld: cluster("zoo"),
+		ObjectNew: cluster("aquarium"),
+	}, queue)
+	assert.Equal(t, queue.Len(), 2)
+
+	item, _ := queue.Get()
+	assert.Equal(t, item, reconcile.Request{NamespacedName: client.ObjectKey{
+		Namespace: "ns1", Name: "zoo",
+	}})
+	queue.Done(item)
+
+	item, _ = queue.Get()
+	assert.Equal(t, item, reconcile.Request{NamespacedName: client.ObjectKey{
+		Namespace: "ns1", Name: "aquarium",
+	}})
+	queue.Done(item)
+}
+
+func TestGenerateSharedRepoHostIntent(t *testing.T) {
+	host := &v1beta1.SharedRepoHost{}
+	host.Namespace, host.Name = "ns1", "zoo"
+	host.Spec.Image = initialize.String("some-image")
+	host.Spec.PriorityClassName = initialize.String("some-priority")
+	host.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "creds"}}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+
+	sts := generateSharedRepoHostIntent(host, []*v1beta1.PostgresCluster{cluster})
+
+	assert.Equal(t, sts.Name, "zoo-shared-repo")
+	assert.Equal(t, sts.Spec.ServiceName, "zoo-shared-repo")
+	assert.Equal(t, *sts.Spec.Replicas, int32(1))
+	assert.DeepEqual(t, sts.Spec.Selector.MatchLabels, map[string]string{
+		"postgres-operator.crunchydata.com/shared-repo-host": "zoo",
+	})
+	assert.DeepEqual(t, sts.Spec.Template.Labels, map[string]string{
+		"postgres-operator.crunchydata.com/shared-repo-host": "zoo",
+	})
+
+	spec := sts.Spec.Template.Spec
+	assert.Equal(t, spec.PriorityClassName, "some-priority")
+	assert.DeepEqual(t, spec.ImagePullSecrets, host.Spec.ImagePullSecrets)
+	assert.Equal(t, *spec.SecurityContext.FSGroup, int64(26))
+	assert.Equal(t, *spec.ShareProcessNamespace, true)
+	assert.Equal(t, *spec.AutomountServiceAccountToken, false)
+
+	var names []string
+	for _, container := range spec.Containers {
+		names = append(names, container.Name)
+		assert.Equal(t, container.Image, "some-image")
+	}
+	assert.DeepEqual(t, names, []string{"pgbackrest", "pgbackrest-config"})
+
+	var volumes []string
+	for _, volume := range spec.Volumes {
+		volumes = append(volumes, volume.Name)
+	}
+	assert.DeepEqual(t, volumes, []string{
+		"pgbackrest-server", "pgbackrest-repo", "pgbackrest-config", "tmp",
+	})
+}
+
+func TestSharedRepoHostReadyCondition(t *testing.T) {
+	host := &v1beta1.SharedRepoHost{}
+	host.Generation = 3
+
+	sts := &appsv1.StatefulSet{}
+	sts.Generation = 2
+
+	condition := sharedRepoHostReadyCondition(host, sts)
+	assert.Equal(t, condition.Type, "PGBackRestRepoHostReady")
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	assert.Equal(t, condition.Reason, "RepoHostNotReady")
+	assert.Equal(t, condition.ObservedGeneration, int64(3))
+
+	// A ready Pod of an older revision is not enough.
+	sts.Status.ReadyReplicas = 1
+	sts.Status.ObservedGeneration = 1
+	condition = sharedRepoHostReadyCondition(host, sts)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+
+	sts.Status.ObservedGeneration = 2
+	condition = sharedRepoHostReadyCondition(host, sts)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Equal(t, condition.Reason, "RepoHostReady")
+}
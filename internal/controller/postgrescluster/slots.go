@@ -0,0 +1,375 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionReplicationSlotsOrphaned is the type used in a condition to
+	// indicate whether or not the primary has replication slots that have had
+	// no consumer for longer than spec.replicationSlots.inactiveMinutes. The
+	// primary keeps WAL for these slots until they are dropped.
+	ConditionReplicationSlotsOrphaned = "ReplicationSlotsOrphaned"
+
+	// ConditionReplicationSlotsNearLimit is the type used in a condition to
+	// indicate whether or not replication slots of the primary keep nearly as
+	// much WAL as spec.replicationSlots.maxWALKeepSize allows. PostgreSQL
+	// invalidates slots that need more.
+	ConditionReplicationSlotsNearLimit = "ReplicationSlotsNearLimit"
+
+	// replicationSlotCheckInterval is how often the replication slots of the
+	// primary instance are checked.
+	replicationSlotCheckInterval = 5 * time.Minute
+)
+
+// replicationSlotReconciler periodically compares the replication slots of
+// the primary instance with their known consumers. It reports slots that have
+// had no consumer for some time and drops them when asked to do so. It also
+// reports slots that keep nearly as much WAL as they are allowed. It shares
+// its client, field owner, and tracer with the PostgresCluster Reconciler.
+type replicationSlotReconciler struct {
+	*Reconciler
+}
+
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={get,list,watch}
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters/status",verbs={patch}
+
+// setupWithManager adds the replication slot controller to the provided
+// runtime manager.
+func (r *replicationSlotReconciler) setupWithManager(mgr manager.Manager) error {
+	return builder.ControllerManagedBy(mgr).
+		Named("postgrescluster-replication-slots").
+		For(&v1beta1.PostgresCluster{},
+			// Ignore changes to status, including the ones made here.
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups="",resources="pods",verbs={list}
+// +kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={list}
+// +kubebuilder:rbac:groups="",resources="pods/exec",verbs={create}
+
+// Reconcile checks the replication slots of the primary instance of a
+// PostgresCluster and reports orphans in the
+// [ConditionReplicationSlotsOrphaned] condition.
+func (r *replicationSlotReconciler) Reconcile(
+	ctx context.Context, request reconcile.Request) (reconcile.Result, error,
+) {
+	ctx, span := r.Tracer.Start(ctx, "ReconcileReplicationSlots")
+	log := logging.FromContext(ctx)
+	defer span.End()
+
+	cluster := &v1beta1.PostgresCluster{}
+	if err := r.Client.Get(ctx, request.NamespacedName, cluster); err != nil {
+		// NotFound cannot be fixed by requeuing so ignore it.
+		if err = client.IgnoreNotFound(err); err != nil {
+			log.Error(err, "unable to fetch PostgresCluster")
+			span.RecordError(err)
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Leave clusters that are being deleted or whose reconciliation is paused.
+	if cluster.DeletionTimestamp != nil ||
+		(cluster.Spec.Paused != nil && *cluster.Spec.Paused) {
+		return reconcile.Result{}, nil
+	}
+
+	before := cluster.DeepCopy()
+	err := r.reconcileReplicationSlots(ctx, cluster, time.Now())
+	if err != nil {
+		log.Error(err, "checking replication slots")
+		span.RecordError(err)
+	}
+
+	// Write only what this controller changed; see [Reconciler.patchStatus].
+	if patchErr := r.patchStatus(ctx, before, cluster); patchErr != nil {
+		log.Error(patchErr, "patching cluster status")
+		if err == nil {
+			err = patchErr
+		}
+	}
+
+	// Errors are retried with the backoff of this controller's workqueue.
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: replicationSlotCheckInterval}, nil
+}
+
+// reconcileReplicationSlots lists the replication slots of the writable
+// instance of cluster and records when each slot without a consumer was first
+// seen that way. Slots that have been that way for too long are reported and,
+// when the policy says so, dropped.
+func (r *replicationSlotReconciler) reconcileReplicationSlots(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, now time.Time,
+) error {
+	const container = naming.ContainerDatabase
+
+	pods := &corev1.PodList{}
+	runners := &appsv1.StatefulSetList{}
+
+	selector, err := naming.AsSelector(naming.ClusterInstances(cluster.Name))
+	if err == nil {
+		err = errors.WithStack(
+			r.Client.List(ctx, pods,
+				client.InNamespace(cluster.Namespace),
+				client.MatchingLabelsSelector{Selector: selector},
+			))
+	}
+	if err == nil {
+		err = errors.WithStack(
+			r.Client.List(ctx, runners,
+				client.InNamespace(cluster.Namespace),
+				client.MatchingLabelsSelector{Selector: selector},
+			))
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only the primary keeps WAL for its slots. When there is none, such as
+	// in a standby cluster, check again later.
+	pod, _ := newObservedInstances(cluster, runners.Items, pods.Items).writablePod(container)
+	if pod == nil {
+		return nil
+	}
+
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).WithValues("pod", pod.Name))
+	exec := func(
+		_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		return r.PodExec(pod.Namespace, pod.Name, container, stdin, stdout, stderr, command...)
+	}
+
+	slots, err := postgres.ReplicationSlots(ctx, exec)
+	if err != nil {
+		return err
+	}
+
+	spec := cluster.Spec.ReplicationSlots
+	if spec == nil {
+		spec = new(v1beta1.PostgresReplicationSlotsSpec)
+	}
+	threshold := time.Duration(spec.InactiveMinutes) * time.Minute
+	if threshold <= 0 {
+		threshold = time.Hour
+	}
+
+	kept := knownReplicationSlots(cluster, pods.Items)
+	previous := make(map[string]metav1.Time)
+	if cluster.Status.ReplicationSlots != nil {
+		for _, slot := range cluster.Status.ReplicationSlots.Inactive {
+			previous[slot.Name] = slot.Since
+		}
+	}
+
+	var inactive []v1beta1.InactiveReplicationSlot
+	var orphaned []string
+	var retained int64
+	for _, slot := range slots {
+		if slot.Active || kept.Has(slot.Name) {
+			continue
+		}
+
+		since, ok := previous[slot.Name]
+		if !ok {
+			since = metav1.NewTime(now.Truncate(time.Second))
+		}
+		inactive = append(inactive, v1beta1.InactiveReplicationSlot{
+			Name: slot.Name, Since: since,
+		})
+
+		if now.Sub(since.Time) >= threshold {
+			orphaned = append(orphaned, slot.Name)
+			retained += slot.RetainedBytes
+		}
+	}
+
+	// Drop orphaned slots when the policy says so. Slots that gain a consumer
+	// in the meantime are left alone and checked again later.
+	dropped := sets.NewString()
+	if len(orphaned) > 0 && spec.OrphanPolicy == "Drop" {
+		err = errors.WithStack(postgres.DropReplicationSlots(ctx, exec, orphaned))
+
+		if err == nil {
+			r.Recorder.Eventf(cluster, corev1.EventTypeNormal, "DroppedReplicationSlots",
+				"Dropped replication slots that had no consumer for %v: %s",
+				threshold, strings.Join(orphaned, ", "))
+
+			dropped.Insert(orphaned...)
+			remaining := inactive[:0]
+			for _, slot := range inactive {
+				if !dropped.Has(slot.Name) {
+					remaining = append(remaining, slot)
+				}
+			}
+			inactive, orphaned = remaining, nil
+		} else {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "DropReplicationSlotsFailed",
+				"Unable to drop replication slots %s: %v", strings.Join(orphaned, ", "), err)
+		}
+	}
+
+	cluster.Status.ReplicationSlots = nil
+	if len(inactive) > 0 {
+		cluster.Status.ReplicationSlots = &v1beta1.PostgresReplicationSlotsStatus{
+			Inactive: inactive,
+		}
+	}
+
+	condition := metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionReplicationSlotsOrphaned,
+		Status:             metav1.ConditionFalse,
+		Reason:             "NoOrphanedSlots",
+		Message:            "Every replication slot has a consumer or is kept",
+	}
+	if len(orphaned) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "InactiveSlots"
+		condition.Message = fmt.Sprintf(
+			"Replication slots have had no consumer for %v: %s",
+			threshold, strings.Join(orphaned, ", "))
+
+		// Report each new set of orphaned slots once.
+		if last := meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionReplicationSlotsOrphaned); last == nil ||
+			last.Message != condition.Message {
+
+			// Round up to the nearest mebibyte.
+			size := resource.NewQuantity((retained+(1<<20)-1)&^((1<<20)-1), resource.BinarySI)
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "OrphanedReplicationSlots",
+				"Replication slots have had no consumer for %v and keep %v of WAL: %s",
+				threshold, size, strings.Join(orphaned, ", "))
+		}
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+
+	r.reportReplicationSlotLimit(cluster, spec, slots, dropped)
+
+	return err
+}
+
+// reportReplicationSlotLimit reports in a condition the replication slots
+// that keep more than spec.warningPercent of spec.maxWALKeepSize. Slots that
+// were just dropped are ignored.
+func (r *replicationSlotReconciler) reportReplicationSlotLimit(
+	cluster *v1beta1.PostgresCluster, spec *v1beta1.PostgresReplicationSlotsSpec,
+	slots []postgres.ReplicationSlot, dropped sets.String,
+) {
+	// PostgreSQL 12 and earlier have no limit; see [postgres.SetReplicationSlotLimit].
+	if spec.MaxWALKeepSize == nil || cluster.Spec.PostgresVersion < 13 {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionReplicationSlotsNearLimit)
+		return
+	}
+
+	percent := int64(spec.WarningPercent)
+	if percent <= 0 {
+		percent = 80
+	}
+	warning := spec.MaxWALKeepSize.Value() * percent / 100
+
+	var near []string
+	for _, slot := range slots {
+		if slot.RetainedBytes >= warning && !dropped.Has(slot.Name) {
+			near = append(near, slot.Name)
+		}
+	}
+
+	condition := metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionReplicationSlotsNearLimit,
+		Status:             metav1.ConditionFalse,
+		Reason:             "WithinLimit",
+		Message: fmt.Sprintf("Every replication slot keeps less than %d%% of %v of WAL",
+			percent, spec.MaxWALKeepSize),
+	}
+	if len(near) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "SlotsNearLimit"
+		condition.Message = fmt.Sprintf(
+			"Replication slots keep more than %d%% of %v of WAL: %s",
+			percent, spec.MaxWALKeepSize, strings.Join(near, ", "))
+
+		// Report each new set of slots once.
+		if last := meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionReplicationSlotsNearLimit); last == nil ||
+			last.Message != condition.Message {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "ReplicationSlotsNearLimit",
+				"%s. PostgreSQL invalidates slots that need more.", condition.Message)
+		}
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+}
+
+// patroniSlotNameInvalid matches the characters that Patroni replaces with
+// underscores when it names a replication slot after a lowercased member name.
+var patroniSlotNameInvalid = regexp.MustCompile(`[^a-z0-9_]`)
+
+// knownReplicationSlots returns the names of replication slots in cluster
+// whose consumers are expected to come and go: those in the spec, the
+// permanent slots of Patroni, and the slots Patroni creates for the instances
+// in pods.
+func knownReplicationSlots(cluster *v1beta1.PostgresCluster, pods []corev1.Pod) sets.String {
+	known := sets.NewString()
+
+	if spec := cluster.Spec.ReplicationSlots; spec != nil {
+		known.Insert(spec.Keep...)
+	}
+
+	if cluster.Spec.Patroni != nil {
+		if permanent, ok := cluster.Spec.Patroni.DynamicConfiguration["slots"].(map[string]interface{}); ok {
+			for name := range permanent {
+				known.Insert(name)
+			}
+		}
+	}
+
+	for i := range pods {
+		known.Insert(patroniSlotNameInvalid.ReplaceAllString(
+			strings.ToLower(pods[i].Name), "_"))
+	}
+
+	return known
+}
@@ -0,0 +1,206 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestKnownReplicationSlots(t *testing.T) {
+	cluster := testCluster()
+	assert.Equal(t, knownReplicationSlots(cluster, nil).Len(), 0)
+
+	cluster.Spec.ReplicationSlots = &v1beta1.PostgresReplicationSlotsSpec{
+		Keep: []string{"standby_east"},
+	}
+	cluster.Spec.Patroni = &v1beta1.PatroniSpec{
+		DynamicConfiguration: map[string]interface{}{
+			"slots": map[string]interface{}{
+				"debezium": map[string]interface{}{"type": "logical"},
+			},
+		},
+	}
+	pods := []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "Hippo-instance1-abcd-0"}}}
+
+	assert.DeepEqual(t, knownReplicationSlots(cluster, pods).List(), []string{
+		"debezium", "hippo_instance1_abcd_0", "standby_east",
+	})
+}
+
+func TestReconcileReplicationSlots(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := testCluster()
+	cluster.Namespace = "ns1"
+	cluster.Spec.ReplicationSlots = &v1beta1.PostgresReplicationSlotsSpec{
+		Keep: []string{"standby"},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace, Name: "hippo-instance1-abcd-0",
+			Labels: map[string]string{
+				naming.LabelCluster:  cluster.Name,
+				naming.LabelInstance: "hippo-instance1-abcd",
+			},
+			Annotations: map[string]string{"status": `{"role":"master"}`},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: naming.ContainerDatabase,
+				State: corev1.ContainerState{
+					Running: new(corev1.ContainerStateRunning),
+				},
+			}},
+		},
+	}
+
+	var drops []string
+	recorder := events.NewRecorder(t, scheme)
+	reconciler := &replicationSlotReconciler{&Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build(),
+		Recorder: recorder,
+		PodExec: func(namespace, pod, container string, stdin io.Reader, stdout,
+			stderr io.Writer, command ...string) error {
+			b, _ := io.ReadAll(stdin)
+			if strings.Contains(string(b), "pg_drop_replication_slot") {
+				drops = append(drops, command[len(command)-1])
+				return nil
+			}
+			_, _ = stdout.Write([]byte(`
+{"name":"hippo_instance1_abcd_0","type":"physical","active":false,"retainedBytes":0}
+{"name":"replica","type":"physical","active":true,"retainedBytes":0}
+{"name":"standby","type":"physical","active":false,"retainedBytes":0}
+{"name":"zombie","type":"logical","active":false,"retainedBytes":3000000}
+`))
+			return nil
+		},
+	}}
+
+	start := time.Date(2023, time.March, 7, 2, 30, 0, 0, time.UTC)
+
+	// Slots without a consumer are recorded but not yet orphaned.
+	assert.NilError(t, reconciler.reconcileReplicationSlots(ctx, cluster, start))
+	assert.DeepEqual(t, cluster.Status.ReplicationSlots.Inactive,
+		[]v1beta1.InactiveReplicationSlot{{Name: "zombie", Since: metav1.NewTime(start)}})
+
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionReplicationSlotsOrphaned)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	assert.Equal(t, len(recorder.Events), 0)
+
+	// The time it was first seen does not change.
+	assert.NilError(t, reconciler.reconcileReplicationSlots(ctx, cluster, start.Add(time.Minute)))
+	assert.Equal(t, cluster.Status.ReplicationSlots.Inactive[0].Since.Time, start)
+
+	// After the default hour, the slot is reported once.
+	later := start.Add(time.Hour)
+	assert.NilError(t, reconciler.reconcileReplicationSlots(ctx, cluster, later))
+	condition = meta.FindStatusCondition(cluster.Status.Conditions, ConditionReplicationSlotsOrphaned)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Equal(t, condition.Reason, "InactiveSlots")
+	assert.Assert(t, strings.HasSuffix(condition.Message, ": zombie"), "got %q", condition.Message)
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, recorder.Events[0].Reason, "OrphanedReplicationSlots")
+	assert.Assert(t, strings.Contains(recorder.Events[0].Note, "keep 3Mi of WAL"), "got %q", recorder.Events[0].Note)
+
+	assert.NilError(t, reconciler.reconcileReplicationSlots(ctx, cluster, later.Add(time.Minute)))
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, len(drops), 0)
+
+	// When the policy says so, orphaned slots are dropped.
+	cluster.Spec.ReplicationSlots.OrphanPolicy = "Drop"
+	assert.NilError(t, reconciler.reconcileReplicationSlots(ctx, cluster, later.Add(time.Minute)))
+	assert.DeepEqual(t, drops, []string{`--set=slots=["zombie"]`})
+	assert.Assert(t, cluster.Status.ReplicationSlots == nil)
+
+	condition = meta.FindStatusCondition(cluster.Status.Conditions, ConditionReplicationSlotsOrphaned)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	assert.Equal(t, len(recorder.Events), 2)
+	assert.Equal(t, recorder.Events[1].Reason, "DroppedReplicationSlots")
+
+	t.Run("NearLimit", func(t *testing.T) {
+		recorder := events.NewRecorder(t, scheme)
+		reconciler := &replicationSlotReconciler{&Reconciler{
+			Client: reconciler.Client, PodExec: reconciler.PodExec, Recorder: recorder,
+		}}
+
+		cluster := testCluster()
+		cluster.Namespace = "ns1"
+		assert.NilError(t, reconciler.reconcileReplicationSlots(ctx, cluster, start))
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionReplicationSlotsNearLimit) == nil)
+
+		size := resource.MustParse("3Mi")
+		cluster.Spec.ReplicationSlots = &v1beta1.PostgresReplicationSlotsSpec{
+			MaxWALKeepSize: &size,
+		}
+
+		// The default warning is at 80% of the limit.
+		assert.NilError(t, reconciler.reconcileReplicationSlots(ctx, cluster, start))
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionReplicationSlotsNearLimit)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.Message, "Replication slots keep more than 80% of 3Mi of WAL: zombie")
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Reason, "ReplicationSlotsNearLimit")
+
+		assert.NilError(t, reconciler.reconcileReplicationSlots(ctx, cluster, start))
+		assert.Equal(t, len(recorder.Events), 1)
+
+		cluster.Spec.ReplicationSlots.WarningPercent = 100
+		assert.NilError(t, reconciler.reconcileReplicationSlots(ctx, cluster, start))
+		condition = meta.FindStatusCondition(cluster.Status.Conditions, ConditionReplicationSlotsNearLimit)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "WithinLimit")
+
+		// PostgreSQL 12 has no limit.
+		cluster.Spec.PostgresVersion = 12
+		assert.NilError(t, reconciler.reconcileReplicationSlots(ctx, cluster, start))
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionReplicationSlotsNearLimit) == nil)
+	})
+
+	t.Run("NoPrimary", func(t *testing.T) {
+		cluster := testCluster()
+		cluster.Namespace = "ns2"
+
+		assert.NilError(t, reconciler.reconcileReplicationSlots(ctx, cluster, start))
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionReplicationSlotsOrphaned) == nil)
+	})
+}
@@ -0,0 +1,310 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// volumeSnapshotBackupTimeout is how long PostgreSQL stays in backup mode
+	// while it waits for a VolumeSnapshot to be taken.
+	volumeSnapshotBackupTimeout = 10 * time.Minute
+
+	// volumeSnapshotCheckInterval is how often a VolumeSnapshot is checked
+	// while PostgreSQL waits for it to be taken.
+	volumeSnapshotCheckInterval = 10 * time.Second
+)
+
+var (
+	volumeSnapshotGVK = schema.GroupVersionKind{
+		Group: "snapshot.storage.k8s.io", Version: "v1", Kind: "VolumeSnapshot",
+	}
+	volumeSnapshotContentGVK = volumeSnapshotGVK.GroupVersion().WithKind("VolumeSnapshotContent")
+)
+
+// +kubebuilder:rbac:groups="snapshot.storage.k8s.io",resources="volumesnapshots",verbs={create,list,patch}
+// +kubebuilder:rbac:groups="snapshot.storage.k8s.io",resources="volumesnapshotcontents",verbs={get}
+
+// reconcileVolumeSnapshots takes a VolumeSnapshot of the primary's data volume
+// each time the "volume-snapshot" annotation of cluster changes and reports the
+// snapshots that exist in cluster status. PostgreSQL is in backup mode while a
+// snapshot is being taken.
+func (r *Reconciler) reconcileVolumeSnapshots(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) (reconcile.Result, error) {
+	if cluster.Spec.Backups.Snapshots == nil {
+		cluster.Status.VolumeSnapshots = nil
+		return reconcile.Result{}, nil
+	}
+
+	selector, err := naming.AsSelector(naming.ClusterVolumeSnapshots(cluster.Name))
+	if err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
+	snapshots := &unstructured.UnstructuredList{}
+	snapshots.SetGroupVersionKind(volumeSnapshotGVK.GroupVersion().WithKind("VolumeSnapshotList"))
+	err = r.Client.List(ctx, snapshots,
+		client.InNamespace(cluster.Namespace),
+		client.MatchingLabelsSelector{Selector: selector})
+
+	if meta.IsNoMatchError(err) {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "VolumeSnapshotsUnavailable",
+			"The VolumeSnapshot API is not installed in this Kubernetes cluster")
+		return reconcile.Result{}, nil
+	}
+	if err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
+	// Take a new snapshot when the annotation has an identifier that no
+	// snapshot has.
+	id := cluster.GetAnnotations()[naming.VolumeSnapshot]
+	requested := id != ""
+	for i := range snapshots.Items {
+		requested = requested &&
+			snapshots.Items[i].GetAnnotations()[naming.VolumeSnapshot] != id
+	}
+	if requested {
+		var snapshot *unstructured.Unstructured
+		snapshot, err = r.takeVolumeSnapshot(ctx, cluster, instances, id)
+		if snapshot != nil {
+			snapshots.Items = append(snapshots.Items, *snapshot)
+		}
+	}
+
+	var result reconcile.Result
+	cluster.Status.VolumeSnapshots = cluster.Status.VolumeSnapshots[:0]
+
+	for i := range snapshots.Items {
+		snapshot := &snapshots.Items[i]
+		status := v1beta1.VolumeSnapshotStatus{
+			Name:     snapshot.GetName(),
+			ID:       snapshot.GetAnnotations()[naming.VolumeSnapshot],
+			Instance: snapshot.GetLabels()[naming.LabelInstance],
+		}
+
+		status.ReadyToUse, _, _ = unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+		status.VolumeSnapshotContentName, _, _ = unstructured.NestedString(
+			snapshot.Object, "status", "boundVolumeSnapshotContentName")
+
+		created, _, _ := unstructured.NestedString(snapshot.Object, "status", "creationTime")
+		if t, parseErr := time.Parse(time.RFC3339, created); parseErr == nil {
+			status.CreationTime = &metav1.Time{Time: t}
+		}
+
+		failure, _, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message")
+
+		if status.VolumeSnapshotContentName != "" && err == nil {
+			status.SnapshotHandle, err = r.volumeSnapshotHandle(ctx, status.VolumeSnapshotContentName)
+		}
+
+		// PostgreSQL can end its backup once the storage system has taken the
+		// snapshot. The snapshot might still be uploading, but its contents
+		// are set.
+		_, stopped := snapshot.GetAnnotations()[naming.VolumeSnapshotBackupStopped]
+		switch {
+		case stopped:
+		case status.CreationTime != nil || status.ReadyToUse || failure != "":
+			if failure != "" {
+				r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "VolumeSnapshotFailed",
+					"VolumeSnapshot %q failed: %s", snapshot.GetName(), failure)
+			}
+			if err == nil {
+				err = r.stopVolumeSnapshotBackup(ctx, instances, snapshot)
+			}
+		default:
+			result.RequeueAfter = volumeSnapshotCheckInterval
+		}
+
+		cluster.Status.VolumeSnapshots = append(cluster.Status.VolumeSnapshots, status)
+	}
+
+	if len(cluster.Status.VolumeSnapshots) == 0 {
+		cluster.Status.VolumeSnapshots = nil
+	}
+
+	// Report snapshots oldest first; those not yet taken are last.
+	sort.SliceStable(cluster.Status.VolumeSnapshots, func(i, j int) bool {
+		a, b := cluster.Status.VolumeSnapshots[i], cluster.Status.VolumeSnapshots[j]
+		switch {
+		case a.CreationTime == nil:
+			return false
+		case b.CreationTime == nil:
+			return true
+		}
+		return a.CreationTime.Before(b.CreationTime)
+	})
+
+	return result, err
+}
+
+// takeVolumeSnapshot starts a backup in the primary PostgreSQL instance then
+// creates a VolumeSnapshot of its data volume. It returns nil when there is
+// no primary or its data cannot be snapshotted.
+func (r *Reconciler) takeVolumeSnapshot(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	instances *observedInstances, id string,
+) (*unstructured.Unstructured, error) {
+	pod, instance := instances.writablePod(naming.ContainerDatabase)
+	if pod == nil {
+		return nil, nil
+	}
+
+	// A snapshot of one volume is consistent only when all the data, including
+	// WAL, is on that volume.
+	if instance.Spec != nil && instance.Spec.WALVolumeClaimSpec != nil {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "VolumeSnapshotNotAllowed",
+			"Instance set %q has a separate WAL volume; VolumeSnapshots are not supported",
+			instance.Spec.Name)
+		return nil, nil
+	}
+
+	var claim string
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == postgres.DataVolumeMount().Name && volume.PersistentVolumeClaim != nil {
+			claim = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+	if claim == "" {
+		return nil, nil
+	}
+
+	objectMeta := naming.ClusterVolumeSnapshot(cluster, id)
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).WithValues("pod", pod.Name))
+	exec := func(
+		_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		return r.PodExec(pod.Namespace, pod.Name, naming.ContainerDatabase,
+			stdin, stdout, stderr, command...)
+	}
+
+	err := postgres.StartBackupForSnapshot(ctx, exec,
+		cluster.Spec.PostgresVersion, objectMeta.Name, volumeSnapshotBackupTimeout)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	snapshot.SetNamespace(objectMeta.Namespace)
+	snapshot.SetName(objectMeta.Name)
+	snapshot.SetAnnotations(naming.Merge(
+		cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		map[string]string{naming.VolumeSnapshot: id},
+	))
+	snapshot.SetLabels(naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster:     cluster.Name,
+			naming.LabelInstanceSet: pod.Labels[naming.LabelInstanceSet],
+			naming.LabelInstance:    instance.Name,
+			naming.LabelData:        naming.DataPostgres,
+		},
+	))
+
+	// The snapshot is not owned by the cluster so that it outlives the cluster.
+	snapshot.Object["spec"] = map[string]interface{}{
+		"volumeSnapshotClassName": cluster.Spec.Backups.Snapshots.VolumeSnapshotClassName,
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": claim,
+		},
+	}
+
+	if err := errors.WithStack(r.Client.Create(ctx, snapshot)); err != nil {
+		// End the backup now rather than when it times out.
+		_ = postgres.StopBackupForSnapshot(ctx, exec, time.Minute)
+		return nil, err
+	}
+
+	r.Recorder.Eventf(cluster, corev1.EventTypeNormal, "VolumeSnapshotCreated",
+		"Created VolumeSnapshot %q of instance %q", objectMeta.Name, instance.Name)
+
+	return snapshot, nil
+}
+
+// stopVolumeSnapshotBackup ends the backup started for snapshot and marks the
+// snapshot so it is not ended again. There is nothing to end when the instance
+// that was snapshotted is no longer running; its backup ended with it.
+func (r *Reconciler) stopVolumeSnapshotBackup(
+	ctx context.Context, instances *observedInstances, snapshot *unstructured.Unstructured,
+) error {
+	var err error
+
+	instance := instances.byName[snapshot.GetLabels()[naming.LabelInstance]]
+	if instance != nil && len(instance.Pods) > 0 {
+		if running, known := instance.IsRunning(naming.ContainerDatabase); running && known {
+			pod := instance.Pods[0]
+			ctx := logging.NewContext(ctx, logging.FromContext(ctx).WithValues("pod", pod.Name))
+			exec := func(
+				_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+			) error {
+				return r.PodExec(pod.Namespace, pod.Name, naming.ContainerDatabase,
+					stdin, stdout, stderr, command...)
+			}
+
+			err = errors.WithStack(postgres.StopBackupForSnapshot(ctx, exec, time.Minute))
+		}
+	}
+
+	if err == nil {
+		patch := client.RawPatch(client.Merge.Type(), []byte(fmt.Sprintf(
+			`{"metadata":{"annotations":{%q:%q}}}`,
+			naming.VolumeSnapshotBackupStopped, metav1.Now().UTC().Format(time.RFC3339))))
+
+		err = errors.WithStack(r.Client.Patch(ctx, snapshot, patch))
+	}
+
+	return err
+}
+
+// volumeSnapshotHandle returns the identifier of the snapshot in the storage
+// system as reported by the VolumeSnapshotContent called name. It is empty
+// when the operator cannot read cluster-scoped objects, such as when it is
+// installed in a single namespace.
+func (r *Reconciler) volumeSnapshotHandle(ctx context.Context, name string) (string, error) {
+	content := &unstructured.Unstructured{}
+	content.SetGroupVersionKind(volumeSnapshotContentGVK)
+
+	err := r.Client.Get(ctx, client.ObjectKey{Name: name}, content)
+	if apierrors.IsForbidden(err) {
+		err = nil
+	}
+	err = errors.WithStack(client.IgnoreNotFound(err))
+
+	handle, _, _ := unstructured.NestedString(content.Object, "status", "snapshotHandle")
+	return handle, err
+}
@@ -0,0 +1,178 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestReconcileVolumeSnapshots(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := testCluster()
+	cluster.Namespace = "ns1"
+	cluster.Annotations = map[string]string{naming.VolumeSnapshot: "first"}
+	cluster.Spec.Backups.Snapshots = &v1beta1.VolumeSnapshots{
+		VolumeSnapshotClassName: "some-class",
+	}
+
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace, Name: "hippo-instance1-abcd-0",
+			Labels: map[string]string{
+				naming.LabelCluster:     cluster.Name,
+				naming.LabelInstanceSet: "instance1",
+				naming.LabelInstance:    "hippo-instance1-abcd",
+			},
+			Annotations: map[string]string{"status": `{"role":"master"}`},
+		},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{{
+				Name: "postgres-data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: "hippo-instance1-abcd-pgdata",
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: naming.ContainerDatabase,
+				State: corev1.ContainerState{
+					Running: new(corev1.ContainerStateRunning),
+				},
+			}},
+		},
+	}
+	instances := newObservedInstances(cluster, nil, []corev1.Pod{pod})
+
+	var commands []string
+	recorder := events.NewRecorder(t, scheme)
+	reconciler := &Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).Build(),
+		Recorder: recorder,
+		PodExec: func(namespace, pod, container string, stdin io.Reader, stdout,
+			stderr io.Writer, command ...string) error {
+			assert.Equal(t, pod, "hippo-instance1-abcd-0")
+			assert.Equal(t, container, naming.ContainerDatabase)
+			commands = append(commands, command[3])
+			return nil
+		},
+	}
+
+	// A new identifier starts a backup and takes a snapshot of the primary.
+	result, err := reconciler.reconcileVolumeSnapshots(ctx, cluster, instances)
+	assert.NilError(t, err)
+	assert.Equal(t, result.RequeueAfter, volumeSnapshotCheckInterval)
+	assert.Equal(t, len(commands), 1)
+	assert.Assert(t, strings.Contains(commands[0], "nohup psql"))
+
+	assert.Equal(t, len(cluster.Status.VolumeSnapshots), 1)
+	status := cluster.Status.VolumeSnapshots[0]
+	assert.Equal(t, status.ID, "first")
+	assert.Equal(t, status.Instance, "hippo-instance1-abcd")
+	assert.Assert(t, status.CreationTime == nil)
+
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	assert.NilError(t, reconciler.Client.Get(ctx,
+		client.ObjectKey{Namespace: cluster.Namespace, Name: status.Name}, snapshot))
+	assert.Assert(t, len(snapshot.GetOwnerReferences()) == 0)
+
+	source, _, _ := unstructured.NestedString(snapshot.Object,
+		"spec", "source", "persistentVolumeClaimName")
+	class, _, _ := unstructured.NestedString(snapshot.Object, "spec", "volumeSnapshotClassName")
+	assert.Equal(t, source, "hippo-instance1-abcd-pgdata")
+	assert.Equal(t, class, "some-class")
+
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Equal(t, recorder.Events[0].Reason, "VolumeSnapshotCreated")
+
+	// The same identifier does not take another snapshot.
+	_, err = reconciler.reconcileVolumeSnapshots(ctx, cluster, instances)
+	assert.NilError(t, err)
+	assert.Equal(t, len(commands), 1)
+
+	// Once the snapshot is taken, the backup ends and the handle is reported.
+	content := &unstructured.Unstructured{}
+	content.SetGroupVersionKind(volumeSnapshotContentGVK)
+	content.SetName("snapcontent-1234")
+	assert.NilError(t, unstructured.SetNestedField(content.Object, "snap-abc", "status", "snapshotHandle"))
+	assert.NilError(t, reconciler.Client.Create(ctx, content))
+
+	assert.NilError(t, unstructured.SetNestedMap(snapshot.Object, map[string]interface{}{
+		"boundVolumeSnapshotContentName": "snapcontent-1234",
+		"creationTime":                   "2023-03-07T02:00:00Z",
+		"readyToUse":                     true,
+	}, "status"))
+	assert.NilError(t, reconciler.Client.Update(ctx, snapshot))
+
+	result, err = reconciler.reconcileVolumeSnapshots(ctx, cluster, instances)
+	assert.NilError(t, err)
+	assert.Equal(t, result.RequeueAfter, time.Duration(0))
+	assert.Equal(t, len(commands), 2)
+	assert.Assert(t, strings.Contains(commands[1], `touch "${directory}/taken"`))
+
+	status = cluster.Status.VolumeSnapshots[0]
+	assert.Assert(t, status.ReadyToUse)
+	assert.Equal(t, status.CreationTime.UTC(), time.Date(2023, time.March, 7, 2, 0, 0, 0, time.UTC))
+	assert.Equal(t, status.SnapshotHandle, "snap-abc")
+	assert.Equal(t, status.VolumeSnapshotContentName, "snapcontent-1234")
+
+	assert.NilError(t, reconciler.Client.Get(ctx, client.ObjectKeyFromObject(snapshot), snapshot))
+	assert.Assert(t, snapshot.GetAnnotations()[naming.VolumeSnapshotBackupStopped] != "")
+
+	// The backup is ended only once.
+	_, err = reconciler.reconcileVolumeSnapshots(ctx, cluster, instances)
+	assert.NilError(t, err)
+	assert.Equal(t, len(commands), 2)
+
+	// A separate WAL volume cannot be snapshotted with the data volume.
+	cluster.Annotations[naming.VolumeSnapshot] = "second"
+	cluster.Spec.InstanceSets[0].WALVolumeClaimSpec = new(corev1.PersistentVolumeClaimSpec)
+	instances = newObservedInstances(cluster, nil, []corev1.Pod{pod})
+
+	_, err = reconciler.reconcileVolumeSnapshots(ctx, cluster, instances)
+	assert.NilError(t, err)
+	assert.Equal(t, len(commands), 2)
+	assert.Equal(t, len(cluster.Status.VolumeSnapshots), 1)
+	assert.Equal(t, recorder.Events[len(recorder.Events)-1].Reason, "VolumeSnapshotNotAllowed")
+
+	// Snapshots are not reported when they are not enabled.
+	cluster.Spec.Backups.Snapshots = nil
+	_, err = reconciler.reconcileVolumeSnapshots(ctx, cluster, instances)
+	assert.NilError(t, err)
+	assert.Assert(t, cluster.Status.VolumeSnapshots == nil)
+}
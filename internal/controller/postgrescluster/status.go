@@ -0,0 +1,110 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// statusWriteInterval is how long minor changes to the status of a cluster
+// wait after its status was last written.
+const statusWriteInterval = 10 * time.Second
+
+// statusWrites remembers when the status of each cluster was last written so
+// that minor changes can be coalesced into fewer writes. The zero value is
+// ready to use; a nil pointer delays nothing.
+type statusWrites struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	written map[types.UID]time.Time
+}
+
+// delay returns how long to wait before writing the changes from before to
+// after in the status of cluster. It is zero when the changes should be
+// written now: when they are more than minor or when the status of cluster
+// has not been written recently.
+func (w *statusWrites) delay(
+	cluster *v1beta1.PostgresCluster,
+	before, after *v1beta1.PostgresClusterStatus, now time.Time,
+) time.Duration {
+	if w == nil || w.interval <= 0 || !minorStatusChange(before, after) {
+		return 0
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if last, ok := w.written[cluster.UID]; ok {
+		if elapsed := now.Sub(last); elapsed < w.interval {
+			return w.interval - elapsed
+		}
+	}
+	return 0
+}
+
+// wrote records that the status of cluster was written at now. It also
+// forgets clusters that have not been written for a while, including ones
+// that were deleted.
+func (w *statusWrites) wrote(cluster *v1beta1.PostgresCluster, now time.Time) {
+	if w == nil || w.interval <= 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for uid, last := range w.written {
+		if now.Sub(last) >= w.interval {
+			delete(w.written, uid)
+		}
+	}
+	if w.written == nil {
+		w.written = make(map[types.UID]time.Time)
+	}
+	w.written[cluster.UID] = now
+}
+
+// minorStatusChange returns true when before and after differ only in fields
+// that describe a cluster rather than drive its reconciliation: counts of
+// ready Pods, the outcomes of scheduled backups, and the messages of
+// conditions. These change often on a busy cluster.
+func minorStatusChange(before, after *v1beta1.PostgresClusterStatus) bool {
+	normalize := func(status *v1beta1.PostgresClusterStatus) *v1beta1.PostgresClusterStatus {
+		status = status.DeepCopy()
+		for i := range status.InstanceSets {
+			status.InstanceSets[i].ReadyReplicas = 0
+			status.InstanceSets[i].UpdatedReplicas = 0
+		}
+		status.Proxy.PGBouncer.ReadyReplicas = 0
+		status.Proxy.PGBouncer.Replicas = 0
+		if status.PGBackRest != nil {
+			status.PGBackRest.ScheduledBackups = nil
+		}
+		for i := range status.Conditions {
+			status.Conditions[i].Message = ""
+			status.Conditions[i].LastTransitionTime = metav1.Time{}
+		}
+		return status
+	}
+
+	return equality.Semantic.DeepEqual(normalize(before), normalize(after))
+}
@@ -0,0 +1,147 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestMinorStatusChange(t *testing.T) {
+	before := &v1beta1.PostgresClusterStatus{
+		InstanceSets: []v1beta1.PostgresInstanceSetStatus{
+			{Name: "00", Replicas: 2, ReadyReplicas: 1, UpdatedReplicas: 1},
+		},
+		Conditions: []metav1.Condition{{
+			Type: "Thing", Status: metav1.ConditionTrue, Reason: "Yes", Message: "one",
+		}},
+	}
+
+	t.Run("Counts", func(t *testing.T) {
+		after := before.DeepCopy()
+		after.InstanceSets[0].ReadyReplicas = 2
+		after.InstanceSets[0].UpdatedReplicas = 2
+		after.Proxy.PGBouncer.ReadyReplicas = 1
+		assert.Assert(t, minorStatusChange(before, after))
+
+		// The number of Pods decides whether a cluster is stopped.
+		after.InstanceSets[0].Replicas = 0
+		assert.Assert(t, !minorStatusChange(before, after))
+	})
+
+	t.Run("Conditions", func(t *testing.T) {
+		after := before.DeepCopy()
+		after.Conditions[0].Message = "two"
+		assert.Assert(t, minorStatusChange(before, after))
+
+		after.Conditions[0].Reason = "No"
+		assert.Assert(t, !minorStatusChange(before, after))
+
+		after = before.DeepCopy()
+		after.Conditions = append(after.Conditions, metav1.Condition{Type: "Other"})
+		assert.Assert(t, !minorStatusChange(before, after))
+	})
+
+	t.Run("ScheduledBackups", func(t *testing.T) {
+		before := before.DeepCopy()
+		before.PGBackRest = &v1beta1.PGBackRestStatus{}
+
+		after := before.DeepCopy()
+		after.PGBackRest.ScheduledBackups = []v1beta1.PGBackRestScheduledBackupStatus{
+			{CronJobName: "daily", Succeeded: 1},
+		}
+		assert.Assert(t, minorStatusChange(before, after))
+
+		after.PGBackRest.RepoHost = &v1beta1.RepoHostStatus{Ready: true}
+		assert.Assert(t, !minorStatusChange(before, after))
+	})
+
+	t.Run("Other", func(t *testing.T) {
+		after := before.DeepCopy()
+		after.ObservedGeneration = 5
+		assert.Assert(t, !minorStatusChange(before, after))
+	})
+}
+
+func TestStatusWrites(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.UID = "one"
+
+	before := &v1beta1.PostgresClusterStatus{
+		InstanceSets: []v1beta1.PostgresInstanceSetStatus{{Name: "00", Replicas: 1}},
+	}
+	minor := before.DeepCopy()
+	minor.InstanceSets[0].ReadyReplicas = 1
+	major := before.DeepCopy()
+	major.ObservedGeneration = 2
+
+	now := time.Now()
+
+	t.Run("Nil", func(t *testing.T) {
+		var writes *statusWrites
+		writes.wrote(cluster, now)
+		assert.Equal(t, writes.delay(cluster, before, minor, now), time.Duration(0))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		writes := new(statusWrites)
+		writes.wrote(cluster, now)
+		assert.Equal(t, writes.delay(cluster, before, minor, now), time.Duration(0))
+	})
+
+	writes := &statusWrites{interval: 10 * time.Second}
+
+	// Nothing written yet.
+	assert.Equal(t, writes.delay(cluster, before, minor, now), time.Duration(0))
+
+	writes.wrote(cluster, now)
+
+	// Minor changes wait for the rest of the interval.
+	assert.Equal(t, writes.delay(cluster, before, minor, now.Add(4*time.Second)), 6*time.Second)
+	assert.Equal(t, writes.delay(cluster, before, minor, now.Add(10*time.Second)), time.Duration(0))
+
+	// Other changes do not wait.
+	assert.Equal(t, writes.delay(cluster, before, major, now.Add(time.Second)), time.Duration(0))
+
+	// Other clusters do not wait.
+	other := cluster.DeepCopy()
+	other.UID = "two"
+	assert.Equal(t, writes.delay(other, before, minor, now.Add(time.Second)), time.Duration(0))
+
+	// Clusters not written for a while are forgotten.
+	writes.wrote(other, now.Add(time.Minute))
+	assert.Equal(t, len(writes.written), 1)
+}
+
+func TestSpecChanges(t *testing.T) {
+	before := testCluster().Spec
+	assert.Assert(t, specChanges(before, *before.DeepCopy()) == nil)
+
+	after := *before.DeepCopy()
+	after.Port = initialize.Int32(9999)
+	after.InstanceSets[0].Replicas = initialize.Int32(5)
+	assert.DeepEqual(t, specChanges(before, after), []string{"instances", "port"})
+
+	after = *before.DeepCopy()
+	after.Users = append(after.Users, v1beta1.PostgresUserSpec{Name: "added"})
+	assert.DeepEqual(t, specChanges(before, after), []string{"users"})
+}
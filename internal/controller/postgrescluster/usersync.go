@@ -0,0 +1,449 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/scim"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionUsersSynced is the type used in a condition to indicate
+	// whether or not the users in spec.userSync were read from the identity
+	// provider and written to spec.users.
+	ConditionUsersSynced = "UsersSynced"
+
+	// userSyncFieldOwner is the field manager of the spec.users entries written
+	// by the user sync controller. It differs from [Reconciler.Owner] so that
+	// the entries are not confused with the ones written by people or GitOps.
+	userSyncFieldOwner client.FieldOwner = "postgrescluster-usersync"
+
+	// userSyncInterval is how often groups are read by default.
+	userSyncInterval = 15 * time.Minute
+
+	// userSyncRetention is how long a user that has left every group stays
+	// in spec.users without LOGIN before it is removed.
+	userSyncRetention = 7 * 24 * time.Hour
+)
+
+// userGroupReader reads the members of groups in an identity provider.
+type userGroupReader interface {
+	GroupMembers(ctx context.Context, group string) ([]string, error)
+}
+
+// userSyncReconciler periodically reads groups from an identity provider and
+// writes their members to spec.users of a PostgresCluster. It shares its
+// client, recorder, and tracer with the PostgresCluster Reconciler.
+type userSyncReconciler struct {
+	*Reconciler
+
+	// NewReader returns a reader for the identity provider at url. It defaults
+	// to a SCIM client.
+	NewReader func(url, token string, caPEM []byte) (userGroupReader, error)
+}
+
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={get,list,watch,patch}
+// +kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters/status",verbs={patch}
+
+// setupWithManager adds the user sync controller to the provided runtime
+// manager.
+func (r *userSyncReconciler) setupWithManager(mgr manager.Manager) error {
+	if r.NewReader == nil {
+		r.NewReader = func(url, token string, caPEM []byte) (userGroupReader, error) {
+			return scim.NewClient(url, token, caPEM)
+		}
+	}
+
+	return builder.ControllerManagedBy(mgr).
+		Named("postgrescluster-usersync").
+		For(&v1beta1.PostgresCluster{},
+			// Ignore changes to status, including the ones made here.
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={get}
+
+// Reconcile reads the groups in spec.userSync of a PostgresCluster, writes
+// their members to spec.users, and reports the outcome in the
+// [ConditionUsersSynced] condition.
+func (r *userSyncReconciler) Reconcile(
+	ctx context.Context, request reconcile.Request) (reconcile.Result, error,
+) {
+	ctx, span := r.Tracer.Start(ctx, "ReconcileUserSync")
+	log := logging.FromContext(ctx)
+	defer span.End()
+
+	cluster := &v1beta1.PostgresCluster{}
+	if err := r.Client.Get(ctx, request.NamespacedName, cluster); err != nil {
+		// NotFound cannot be fixed by requeuing so ignore it.
+		if err = client.IgnoreNotFound(err); err != nil {
+			log.Error(err, "unable to fetch PostgresCluster")
+			span.RecordError(err)
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Leave clusters that are being deleted or whose reconciliation is paused.
+	if cluster.DeletionTimestamp != nil ||
+		(cluster.Spec.Paused != nil && *cluster.Spec.Paused) {
+		return reconcile.Result{}, nil
+	}
+
+	before := cluster.DeepCopy()
+	err := r.reconcileUserSync(ctx, cluster, time.Now())
+	if err != nil {
+		log.Error(err, "syncing users")
+		span.RecordError(err)
+	}
+
+	// Write only what this controller changed; see [Reconciler.patchStatus].
+	if patchErr := r.patchStatus(ctx, before, cluster); patchErr != nil {
+		log.Error(patchErr, "patching cluster status")
+		if err == nil {
+			err = patchErr
+		}
+	}
+
+	// Errors are retried with the backoff of this controller's workqueue.
+	if err != nil || cluster.Spec.UserSync == nil {
+		return reconcile.Result{}, err
+	}
+
+	interval := userSyncInterval
+	if minutes := cluster.Spec.UserSync.IntervalMinutes; minutes != nil {
+		interval = time.Duration(*minutes) * time.Minute
+	}
+	return reconcile.Result{RequeueAfter: interval}, nil
+}
+
+// reconcileUserSync reads the groups of cluster from the identity provider
+// and server-side applies the resulting users to its spec. Nothing is applied
+// unless every group is read, so an outage of the identity provider does not
+// lock anyone out.
+func (r *userSyncReconciler) reconcileUserSync(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, now time.Time,
+) error {
+	spec := cluster.Spec.UserSync
+	if spec == nil {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionUsersSynced)
+		cluster.Status.UserSync = nil
+		return nil
+	}
+
+	condition := metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionUsersSynced,
+		Status:             metav1.ConditionFalse,
+	}
+
+	secret := &corev1.Secret{}
+	secret.Namespace, secret.Name = cluster.Namespace, spec.Secret.Name
+
+	var reader userGroupReader
+	err := errors.WithStack(r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret))
+	if err == nil {
+		reader, err = r.NewReader(
+			string(secret.Data["url"]), string(secret.Data["token"]), secret.Data["ca.crt"])
+
+		if err != nil {
+			condition.Reason = "SecretInvalid"
+			condition.Message = fmt.Sprintf("Secret %q: %v", secret.Name, err)
+			err = nil
+		}
+	} else if apierrors.IsNotFound(err) {
+		condition.Reason = "SecretInvalid"
+		condition.Message = fmt.Sprintf("Secret %q not found", secret.Name)
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	members := make(map[string][]string, len(spec.Groups))
+	for i := 0; reader != nil && i < len(spec.Groups); i++ {
+		name := spec.Groups[i].Name
+		names, err := reader.GroupMembers(ctx, name)
+		if err != nil {
+			condition.Reason = "SourceFailed"
+			condition.Message = fmt.Sprintf("Reading group %q: %v", name, err)
+			reader = nil
+		}
+		members[name] = names
+	}
+
+	if reader != nil {
+		users, disabled, skipped := userSyncIntent(
+			spec.Groups, members, cluster.Status.UserSync, now)
+
+		// The default user exists only while spec.users is empty, so the first
+		// synced users replace it.
+		replacesDefault := len(cluster.Spec.Users) == 0 && len(users) > 0
+
+		err = r.applyUserSync(ctx, cluster, users)
+		if apierrors.IsConflict(err) {
+			condition.Reason = "Conflict"
+			condition.Message = err.Error()
+			err = nil
+		} else if err == nil {
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = "Synced"
+			condition.Message = fmt.Sprintf("%d users synced", len(users))
+
+			if len(skipped) > 0 {
+				condition.Message += fmt.Sprintf(
+					"; skipped user names that are invalid or shared by other members: %s",
+					strings.Join(skipped, ", "))
+			}
+
+			status := &v1beta1.PostgresUserSyncStatus{
+				LastSyncTime: &metav1.Time{Time: now},
+				Disabled:     disabled,
+			}
+			for i := range users {
+				status.Users = append(status.Users, string(users[i].Name))
+			}
+			cluster.Status.UserSync = status
+
+			if replacesDefault {
+				r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "DefaultUserReplaced",
+					"Synced users replace the default user %q; declare it in spec.users to keep it",
+					cluster.Name)
+			}
+		}
+	}
+
+	if condition.Status == metav1.ConditionFalse {
+		previous := meta.FindStatusCondition(cluster.Status.Conditions, ConditionUsersSynced)
+		if previous == nil || previous.Reason != condition.Reason {
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, "UserSyncFailed",
+				condition.Message)
+		}
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+
+	return err
+}
+
+// applyUserSync server-side applies users to spec.users of cluster as
+// [userSyncFieldOwner]. Entries written by other field managers are left alone.
+// The apply is not forced, so a user that is also written by another field
+// manager with different values returns a Conflict error.
+func (r *userSyncReconciler) applyUserSync(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, users []v1beta1.PostgresUserSpec,
+) error {
+	intent := map[string]any{
+		"apiVersion": v1beta1.GroupVersion.String(),
+		"kind":       "PostgresCluster",
+		"metadata": map[string]any{
+			"name":      cluster.Name,
+			"namespace": cluster.Namespace,
+		},
+	}
+
+	// An empty list would replace the default user of the cluster, so send
+	// users only when there are some.
+	if len(users) > 0 {
+		entries := make([]map[string]any, len(users))
+		for i, user := range users {
+			// Always send memberOf, even when it is empty, so that memberships
+			// outside the groups are revoked.
+			entries[i] = map[string]any{
+				"name":     user.Name,
+				"memberOf": append([]v1beta1.PostgresIdentifier{}, user.MemberOf...),
+				"options":  user.Options,
+			}
+			if len(user.Databases) > 0 {
+				entries[i]["databases"] = user.Databases
+			}
+		}
+		intent["spec"] = map[string]any{"users": entries}
+	}
+
+	data, err := json.Marshal(intent)
+	if err == nil {
+		err = r.Client.Patch(ctx, cluster.DeepCopy(),
+			client.RawPatch(client.Apply.Type(), data), userSyncFieldOwner)
+	}
+	return errors.WithStack(err)
+}
+
+// userSyncIntent returns the users for groups given the members of each group
+// and the status of the previous sync. Members of any group can login and are
+// granted the databases and roles of all their groups. Previous users that are
+// no longer in any group cannot login and are members of no roles; they are
+// returned as disabled until [userSyncRetention] after they left, then dropped.
+// It also returns the member names that could not be turned into PostgreSQL
+// users, including distinct members that would become the same user.
+func userSyncIntent(
+	groups []v1beta1.PostgresUserSyncGroup, members map[string][]string,
+	previous *v1beta1.PostgresUserSyncStatus, now time.Time,
+) (
+	[]v1beta1.PostgresUserSpec, []v1beta1.PostgresUserSyncDisabledUser, []string,
+) {
+	// This matches the validation of [v1beta1.PostgresUserSpec.Name].
+	reUser := regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+	type access struct {
+		databases, memberOf map[v1beta1.PostgresIdentifier]struct{}
+	}
+	current := make(map[string]*access)
+	invalid := make(map[string]struct{})
+
+	// Turn email-style names, such as "Jane.Doe@example.com", into a valid
+	// PostgreSQL user name, "jane-doe". User names are not case-sensitive.
+	// - https://datatracker.ietf.org/doc/html/rfc7643#section-4.1.1
+	userName := make(map[string]string)
+	identities := make(map[string]map[string]struct{})
+	for _, group := range groups {
+		for _, member := range members[group.Name] {
+			identity := strings.ToLower(member)
+			name := identity
+			if i := strings.IndexByte(name, '@'); i >= 0 {
+				name = name[:i]
+			}
+			name = strings.NewReplacer(".", "-", "_", "-").Replace(name)
+
+			// The "postgres" user is managed by the operator.
+			if len(name) > 63 || !reUser.MatchString(name) || name == "postgres" {
+				invalid[member] = struct{}{}
+				continue
+			}
+
+			userName[member] = name
+			if identities[name] == nil {
+				identities[name] = make(map[string]struct{})
+			}
+			identities[name][identity] = struct{}{}
+		}
+	}
+
+	for _, group := range groups {
+		for _, member := range members[group.Name] {
+			name, ok := userName[member]
+
+			// Members that would become the same user, such as "jane.doe@a.com"
+			// and "jane_doe@b.com", could be different people. Skip them all.
+			if ok && len(identities[name]) > 1 {
+				invalid[member] = struct{}{}
+			}
+			if !ok || len(identities[name]) > 1 {
+				continue
+			}
+
+			a := current[name]
+			if a == nil {
+				a = &access{
+					databases: make(map[v1beta1.PostgresIdentifier]struct{}),
+					memberOf:  make(map[v1beta1.PostgresIdentifier]struct{}),
+				}
+				current[name] = a
+			}
+			for _, db := range group.Databases {
+				a.databases[db] = struct{}{}
+			}
+			for _, role := range group.MemberOf {
+				a.memberOf[role] = struct{}{}
+			}
+		}
+	}
+
+	sorted := func(set map[v1beta1.PostgresIdentifier]struct{}) []v1beta1.PostgresIdentifier {
+		out := make([]v1beta1.PostgresIdentifier, 0, len(set))
+		for item := range set {
+			out = append(out, item)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+		return out
+	}
+
+	var previousUsers []string
+	since := make(map[string]metav1.Time)
+	if previous != nil {
+		previousUsers = previous.Users
+		for _, user := range previous.Disabled {
+			since[user.Name] = user.Since
+		}
+	}
+
+	users := make([]v1beta1.PostgresUserSpec, 0, len(current)+len(previousUsers))
+	for name, a := range current {
+		users = append(users, v1beta1.PostgresUserSpec{
+			Name:      v1beta1.PostgresIdentifier(name),
+			Databases: sorted(a.databases),
+			MemberOf:  sorted(a.memberOf),
+			Options:   "LOGIN",
+		})
+	}
+	var disabled []v1beta1.PostgresUserSyncDisabledUser
+	for _, name := range previousUsers {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		current[name] = nil
+
+		// Start the clock for users that just left, including those synced
+		// before departures were recorded.
+		left, ok := since[name]
+		if !ok {
+			left = metav1.NewTime(now)
+		}
+		if now.Sub(left.Time) >= userSyncRetention {
+			continue
+		}
+
+		disabled = append(disabled, v1beta1.PostgresUserSyncDisabledUser{
+			Name: name, Since: left,
+		})
+		users = append(users, v1beta1.PostgresUserSpec{
+			Name:     v1beta1.PostgresIdentifier(name),
+			MemberOf: []v1beta1.PostgresIdentifier{},
+			Options:  "NOLOGIN",
+		})
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	sort.Slice(disabled, func(i, j int) bool { return disabled[i].Name < disabled[j].Name })
+
+	skipped := make([]string, 0, len(invalid))
+	for member := range invalid {
+		skipped = append(skipped, member)
+	}
+	sort.Strings(skipped)
+
+	return users, disabled, skipped
+}
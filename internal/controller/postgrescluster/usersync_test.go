@@ -0,0 +1,293 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+type userGroupReaderFunc func(ctx context.Context, group string) ([]string, error)
+
+func (fn userGroupReaderFunc) GroupMembers(ctx context.Context, group string) ([]string, error) {
+	return fn(ctx, group)
+}
+
+func TestUserSyncIntent(t *testing.T) {
+	groups := []v1beta1.PostgresUserSyncGroup{
+		{Name: "dba", Databases: []v1beta1.PostgresIdentifier{"app"}, MemberOf: []v1beta1.PostgresIdentifier{"admin"}},
+		{Name: "dev", Databases: []v1beta1.PostgresIdentifier{"app", "sandbox"}},
+	}
+
+	now := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Empty", func(t *testing.T) {
+		users, disabled, skipped := userSyncIntent(groups, nil, nil, now)
+		assert.Equal(t, len(users), 0)
+		assert.Equal(t, len(disabled), 0)
+		assert.Equal(t, len(skipped), 0)
+	})
+
+	t.Run("Members", func(t *testing.T) {
+		users, disabled, skipped := userSyncIntent(groups, map[string][]string{
+			"dba": {"Alice@Example.com", "postgres", "bob"},
+			"dev": {"bob", "carol$", "dave"},
+		}, &v1beta1.PostgresUserSyncStatus{Users: []string{"dave", "erin"}}, now)
+
+		assert.DeepEqual(t, skipped, []string{"carol$", "postgres"})
+		assert.DeepEqual(t, disabled, []v1beta1.PostgresUserSyncDisabledUser{
+			{Name: "erin", Since: metav1.NewTime(now)},
+		})
+		assert.DeepEqual(t, users, []v1beta1.PostgresUserSpec{
+			{
+				Name:      "alice",
+				Databases: []v1beta1.PostgresIdentifier{"app"},
+				MemberOf:  []v1beta1.PostgresIdentifier{"admin"},
+				Options:   "LOGIN",
+			},
+			{
+				Name:      "bob",
+				Databases: []v1beta1.PostgresIdentifier{"app", "sandbox"},
+				MemberOf:  []v1beta1.PostgresIdentifier{"admin"},
+				Options:   "LOGIN",
+			},
+			{
+				Name:      "dave",
+				Databases: []v1beta1.PostgresIdentifier{"app", "sandbox"},
+				MemberOf:  []v1beta1.PostgresIdentifier{},
+				Options:   "LOGIN",
+			},
+			{
+				Name:     "erin",
+				MemberOf: []v1beta1.PostgresIdentifier{},
+				Options:  "NOLOGIN",
+			},
+		})
+	})
+
+	t.Run("Punctuation", func(t *testing.T) {
+		users, _, skipped := userSyncIntent(groups, map[string][]string{
+			"dba": {"Jane.Doe@example.com", "john_smith"},
+		}, nil, now)
+
+		assert.Equal(t, len(skipped), 0)
+		assert.Equal(t, len(users), 2)
+		assert.Equal(t, users[0].Name, v1beta1.PostgresIdentifier("jane-doe"))
+		assert.Equal(t, users[1].Name, v1beta1.PostgresIdentifier("john-smith"))
+	})
+
+	t.Run("Collisions", func(t *testing.T) {
+		users, disabled, skipped := userSyncIntent(groups, map[string][]string{
+			"dba": {"jane.doe@a.com", "Bob@Example.com"},
+			"dev": {"jane_doe@b.com", "bob@example.com", "carol"},
+		}, &v1beta1.PostgresUserSyncStatus{Users: []string{"jane-doe"}}, now)
+
+		// The same userName in another case is the same member.
+		assert.DeepEqual(t, skipped, []string{"jane.doe@a.com", "jane_doe@b.com"})
+		assert.DeepEqual(t, disabled, []v1beta1.PostgresUserSyncDisabledUser{
+			{Name: "jane-doe", Since: metav1.NewTime(now)},
+		})
+		assert.Equal(t, len(users), 3)
+		assert.Equal(t, users[0].Name, v1beta1.PostgresIdentifier("bob"))
+		assert.DeepEqual(t, users[0].Databases, []v1beta1.PostgresIdentifier{"app", "sandbox"})
+		assert.Equal(t, users[1].Name, v1beta1.PostgresIdentifier("carol"))
+		assert.Equal(t, users[2].Name, v1beta1.PostgresIdentifier("jane-doe"))
+		assert.Equal(t, users[2].Options, "NOLOGIN")
+	})
+
+	t.Run("Retention", func(t *testing.T) {
+		previous := &v1beta1.PostgresUserSyncStatus{
+			Users: []string{"alice", "bob", "carol", "dave"},
+			Disabled: []v1beta1.PostgresUserSyncDisabledUser{
+				{Name: "alice", Since: metav1.NewTime(now.Add(-time.Hour))},
+				{Name: "bob", Since: metav1.NewTime(now.Add(-time.Hour))},
+				{Name: "carol", Since: metav1.NewTime(now.Add(-userSyncRetention))},
+			},
+		}
+
+		users, disabled, _ := userSyncIntent(groups, map[string][]string{
+			"dev": {"alice"},
+		}, previous, now)
+
+		// Alice came back; Bob keeps the time he left; Carol is dropped after
+		// the retention period; Dave starts it now.
+		assert.DeepEqual(t, disabled, []v1beta1.PostgresUserSyncDisabledUser{
+			{Name: "bob", Since: metav1.NewTime(now.Add(-time.Hour))},
+			{Name: "dave", Since: metav1.NewTime(now)},
+		})
+
+		names := make([]v1beta1.PostgresIdentifier, len(users))
+		for i := range users {
+			names[i] = users[i].Name
+		}
+		assert.DeepEqual(t, names, []v1beta1.PostgresIdentifier{"alice", "bob", "dave"})
+		assert.Equal(t, users[0].Options, "LOGIN")
+		assert.Equal(t, users[1].Options, "NOLOGIN")
+	})
+}
+
+func TestReconcileUserSync(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	now := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "idp"},
+		Data: map[string][]byte{
+			"url":   []byte("https://idp.example.com/scim/v2"),
+			"token": []byte("tok"),
+		},
+	}
+
+	var read []string
+	var readErr error
+	recorder := events.NewRecorder(t, scheme)
+	reconciler := &userSyncReconciler{
+		Reconciler: &Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+			Recorder: recorder,
+		},
+		NewReader: func(url, token string, caPEM []byte) (userGroupReader, error) {
+			assert.Equal(t, url, "https://idp.example.com/scim/v2")
+			assert.Equal(t, token, "tok")
+			assert.Assert(t, caPEM == nil)
+
+			return userGroupReaderFunc(func(_ context.Context, group string) ([]string, error) {
+				read = append(read, group)
+				return nil, readErr
+			}), nil
+		},
+	}
+
+	cluster := testCluster()
+	cluster.Namespace = "ns1"
+
+	t.Run("Disabled", func(t *testing.T) {
+		cluster.Status.UserSync = &v1beta1.PostgresUserSyncStatus{Users: []string{"x"}}
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type: ConditionUsersSynced, Status: metav1.ConditionTrue, Reason: "Synced",
+		})
+
+		assert.NilError(t, reconciler.reconcileUserSync(ctx, cluster, now))
+		assert.Assert(t, cluster.Status.UserSync == nil)
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions, ConditionUsersSynced) == nil)
+	})
+
+	cluster.Spec.UserSync = &v1beta1.PostgresUserSyncSpec{
+		Groups: []v1beta1.PostgresUserSyncGroup{{Name: "dba"}, {Name: "dev"}},
+	}
+
+	t.Run("SecretMissing", func(t *testing.T) {
+		cluster.Spec.UserSync.Secret.Name = "missing"
+
+		assert.NilError(t, reconciler.reconcileUserSync(ctx, cluster, now))
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionUsersSynced)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "SecretInvalid")
+		assert.Equal(t, len(read), 0)
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Reason, "UserSyncFailed")
+	})
+
+	t.Run("SourceFailed", func(t *testing.T) {
+		cluster.Spec.UserSync.Secret.Name = "idp"
+		readErr = errors.New("boom")
+
+		assert.NilError(t, reconciler.reconcileUserSync(ctx, cluster, now))
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionUsersSynced)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "SourceFailed")
+		assert.Assert(t, cluster.Status.UserSync == nil, "expected nothing applied")
+		assert.DeepEqual(t, read, []string{"dba"})
+
+		assert.Equal(t, len(recorder.Events), 2)
+		assert.Equal(t, recorder.Events[1].Reason, "UserSyncFailed")
+
+		// The same failure is not reported again.
+		assert.NilError(t, reconciler.reconcileUserSync(ctx, cluster, now))
+		assert.Equal(t, len(recorder.Events), 2)
+	})
+
+	t.Run("DefaultUserReplaced", func(t *testing.T) {
+		readErr = nil
+		recorder.Events = nil
+
+		var patches int
+		reconciler := *reconciler
+		reconciler.Reconciler = &Reconciler{
+			Client: patchCountingClient{
+				Client: reconciler.Client, count: &patches,
+			},
+			Recorder: recorder,
+		}
+		reconciler.NewReader = func(string, string, []byte) (userGroupReader, error) {
+			return userGroupReaderFunc(func(_ context.Context, group string) ([]string, error) {
+				return []string{group + "-lead"}, nil
+			}), nil
+		}
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Users = nil
+
+		assert.NilError(t, reconciler.reconcileUserSync(ctx, cluster, now))
+		assert.Equal(t, patches, 1)
+		assert.DeepEqual(t, cluster.Status.UserSync.Users, []string{"dba-lead", "dev-lead"})
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+		assert.Equal(t, recorder.Events[0].Reason, "DefaultUserReplaced")
+		assert.Assert(t, cmp.Contains(recorder.Events[0].Note, cluster.Name))
+
+		// Once spec.users has entries, there is no default user to replace.
+		cluster.Spec.Users = []v1beta1.PostgresUserSpec{{Name: "dba-lead"}, {Name: "dev-lead"}}
+
+		assert.NilError(t, reconciler.reconcileUserSync(ctx, cluster, now))
+		assert.Equal(t, patches, 2)
+		assert.Equal(t, len(recorder.Events), 1)
+	})
+}
+
+// patchCountingClient counts patches rather than sending them, because the
+// fake client cannot server-side apply.
+type patchCountingClient struct {
+	client.Client
+	count *int
+}
+
+func (c patchCountingClient) Patch(context.Context, client.Object, client.Patch, ...client.PatchOption) error {
+	*c.count++
+	return nil
+}
@@ -23,11 +23,13 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 var tmpDirSizeLimit = resource.MustParse("16Mi")
@@ -152,13 +154,18 @@ func addDevSHM(template *corev1.PodTemplateSpec) {
 //   - As the pgBackRest lock directory (this is the default lock location for pgBackRest)
 //   - The location where the replication client certificates can be loaded with the proper
 //     permissions set
-func addTMPEmptyDir(template *corev1.PodTemplateSpec) {
+//
+// The size of the volume is limited to sizeLimit or, when that is nil, tmpDirSizeLimit.
+func addTMPEmptyDir(template *corev1.PodTemplateSpec, sizeLimit *resource.Quantity) {
+	if sizeLimit == nil {
+		sizeLimit = &tmpDirSizeLimit
+	}
 
 	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
 		Name: "tmp",
 		VolumeSource: corev1.VolumeSource{
 			EmptyDir: &corev1.EmptyDirVolumeSource{
-				SizeLimit: &tmpDirSizeLimit,
+				SizeLimit: sizeLimit,
 			},
 		},
 	})
@@ -180,6 +187,39 @@ func addTMPEmptyDir(template *corev1.PodTemplateSpec) {
 	}
 }
 
+// requestTMPEphemeralStorage requests sizeLimit of ephemeral storage, the size
+// of the "tmp" volume, in the container named name so that the Pod is scheduled
+// to a node with room for that volume. Nothing changes when sizeLimit is nil or
+// the container already requests or limits ephemeral storage.
+func requestTMPEphemeralStorage(
+	template *corev1.PodTemplateSpec, name string, sizeLimit *resource.Quantity,
+) {
+	if sizeLimit == nil {
+		return
+	}
+
+	for i := range template.Spec.Containers {
+		resources := &template.Spec.Containers[i].Resources
+		if template.Spec.Containers[i].Name != name {
+			continue
+		}
+		if _, ok := resources.Requests[corev1.ResourceEphemeralStorage]; ok {
+			continue
+		}
+		if _, ok := resources.Limits[corev1.ResourceEphemeralStorage]; ok {
+			continue
+		}
+
+		// Copy the requests so that the spec they came from does not change.
+		requests := make(corev1.ResourceList, len(resources.Requests)+1)
+		for k, v := range resources.Requests {
+			requests[k] = v
+		}
+		requests[corev1.ResourceEphemeralStorage] = sizeLimit.DeepCopy()
+		resources.Requests = requests
+	}
+}
+
 // addNSSWrapper adds nss_wrapper environment variables to the database and pgBackRest
 // containers in the Pod template.  Additionally, an init container is added to the Pod template
 // as needed to setup the nss_wrapper. Please note that the nss_wrapper is required for
@@ -263,6 +303,20 @@ func addNSSWrapper(image string, imagePullPolicy corev1.PullPolicy, template *co
 	template.Spec.InitContainers = append(template.Spec.InitContainers, container)
 }
 
+// setServiceTrafficPolicies sets the session affinity and external traffic
+// policy of service from spec. Kubernetes allows an external traffic policy
+// only on Services that are reachable from outside the cluster.
+func setServiceTrafficPolicies(service *corev1.Service, spec *v1beta1.ServiceSpec) {
+	if spec.SessionAffinity != nil {
+		service.Spec.SessionAffinity = *spec.SessionAffinity
+	}
+	if spec.ExternalTrafficPolicy != nil &&
+		(service.Spec.Type == corev1.ServiceTypeNodePort ||
+			service.Spec.Type == corev1.ServiceTypeLoadBalancer) {
+		service.Spec.ExternalTrafficPolicy = *spec.ExternalTrafficPolicy
+	}
+}
+
 // jobFailed returns "true" if the Job provided has failed.  Otherwise it returns "false".
 func jobFailed(job *batchv1.Job) bool {
 	conditions := job.Status.Conditions
@@ -286,6 +340,19 @@ func jobCompleted(job *batchv1.Job) bool {
 	return false
 }
 
+// jobFinishTime returns when the Job provided completed or failed, or nil when it is still
+// running.
+func jobFinishTime(job *batchv1.Job) *metav1.Time {
+	conditions := job.Status.Conditions
+	for i := range conditions {
+		if (conditions[i].Type == batchv1.JobComplete || conditions[i].Type == batchv1.JobFailed) &&
+			conditions[i].Status == corev1.ConditionTrue {
+			return &conditions[i].LastTransitionTime
+		}
+	}
+	return nil
+}
+
 // safeHash32 runs content and returns a short alphanumeric string that
 // represents everything written to w. The string is unlikely to have bad words
 // and is safe to store in the Kubernetes API. This is the same algorithm used
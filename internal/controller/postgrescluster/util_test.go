@@ -30,6 +30,7 @@ import (
 
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestSafeHash32(t *testing.T) {
@@ -257,6 +258,105 @@ func TestAddDevSHM(t *testing.T) {
 	}
 }
 
+func TestAddTMPEmptyDir(t *testing.T) {
+	newTemplate := func() *corev1.PodTemplateSpec {
+		return &corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init"}},
+			Containers:     []corev1.Container{{Name: "database"}, {Name: "pgbackrest"}},
+		}}
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		template := newTemplate()
+		addTMPEmptyDir(template, nil)
+
+		assert.Assert(t, cmp.MarshalMatches(template.Spec, `
+containers:
+- name: database
+  resources: {}
+  volumeMounts:
+  - mountPath: /tmp
+    name: tmp
+- name: pgbackrest
+  resources: {}
+  volumeMounts:
+  - mountPath: /tmp
+    name: tmp
+initContainers:
+- name: init
+  resources: {}
+  volumeMounts:
+  - mountPath: /tmp
+    name: tmp
+volumes:
+- emptyDir:
+    sizeLimit: 16Mi
+  name: tmp
+		`))
+	})
+
+	t.Run("SizeLimit", func(t *testing.T) {
+		template := newTemplate()
+		limit := resource.MustParse("1Gi")
+		addTMPEmptyDir(template, &limit)
+
+		assert.Equal(t, len(template.Spec.Volumes), 1)
+		assert.Assert(t, template.Spec.Volumes[0].EmptyDir != nil)
+		assert.Equal(t, template.Spec.Volumes[0].EmptyDir.SizeLimit.String(), "1Gi")
+
+		// The default is unchanged.
+		assert.Equal(t, tmpDirSizeLimit.String(), "16Mi")
+	})
+}
+
+func TestRequestTMPEphemeralStorage(t *testing.T) {
+	newTemplate := func() *corev1.PodTemplateSpec {
+		return &corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "database"}, {Name: "other"}},
+		}}
+	}
+	limit := resource.MustParse("64Mi")
+
+	t.Run("Default", func(t *testing.T) {
+		template := newTemplate()
+		requestTMPEphemeralStorage(template, "database", nil)
+		assert.DeepEqual(t, template, newTemplate())
+	})
+
+	t.Run("SizeLimit", func(t *testing.T) {
+		requests := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}
+		template := newTemplate()
+		template.Spec.Containers[0].Resources.Requests = requests
+
+		requestTMPEphemeralStorage(template, "database", &limit)
+		assert.Assert(t, marshalMatches(template.Spec.Containers, `
+- name: database
+  resources:
+    requests:
+      cpu: "1"
+      ephemeral-storage: 64Mi
+- name: other
+  resources: {}
+		`))
+
+		// The requests in the spec are unchanged.
+		assert.Equal(t, len(requests), 1)
+	})
+
+	t.Run("Resources", func(t *testing.T) {
+		for _, resources := range []corev1.ResourceRequirements{
+			{Requests: corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("1Gi")}},
+			{Limits: corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("32Mi")}},
+		} {
+			template := newTemplate()
+			template.Spec.Containers[0].Resources = resources
+
+			requestTMPEphemeralStorage(template, "database", &limit)
+			assert.DeepEqual(t, template.Spec.Containers[0].Resources, resources)
+		}
+	})
+}
+
 func TestAddNSSWrapper(t *testing.T) {
 
 	image := "test-image"
@@ -529,3 +629,43 @@ func TestJobFailed(t *testing.T) {
 		})
 	}
 }
+
+func TestSetServiceTrafficPolicies(t *testing.T) {
+	affinity := corev1.ServiceAffinityClientIP
+	policy := corev1.ServiceExternalTrafficPolicyTypeLocal
+	spec := &v1beta1.ServiceSpec{
+		SessionAffinity:       &affinity,
+		ExternalTrafficPolicy: &policy,
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		service := &corev1.Service{}
+		setServiceTrafficPolicies(service, &v1beta1.ServiceSpec{})
+		assert.DeepEqual(t, service.Spec, corev1.ServiceSpec{})
+	})
+
+	t.Run("ClusterIP", func(t *testing.T) {
+		service := &corev1.Service{}
+		service.Spec.Type = corev1.ServiceTypeClusterIP
+		setServiceTrafficPolicies(service, spec)
+
+		assert.Equal(t, service.Spec.SessionAffinity, corev1.ServiceAffinityClientIP)
+		assert.Equal(t, service.Spec.ExternalTrafficPolicy,
+			corev1.ServiceExternalTrafficPolicyType(""),
+			"expected no external policy on an internal Service")
+	})
+
+	for _, serviceType := range []corev1.ServiceType{
+		corev1.ServiceTypeNodePort, corev1.ServiceTypeLoadBalancer,
+	} {
+		t.Run(string(serviceType), func(t *testing.T) {
+			service := &corev1.Service{}
+			service.Spec.Type = serviceType
+			setServiceTrafficPolicies(service, spec)
+
+			assert.Equal(t, service.Spec.SessionAffinity, corev1.ServiceAffinityClientIP)
+			assert.Equal(t, service.Spec.ExternalTrafficPolicy,
+				corev1.ServiceExternalTrafficPolicyTypeLocal)
+		})
+	}
+}
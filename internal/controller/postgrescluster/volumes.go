@@ -34,12 +34,13 @@ import (
 	"github.com/crunchydata/postgres-operator/internal/config"
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
 	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
-// +kubebuilder:rbac:groups="",resources="persistentvolumeclaims",verbs={list}
+// +kubebuilder:rbac:groups="",resources="persistentvolumeclaims",verbs={list,patch}
 
 // observePersistentVolumeClaims reads all PVCs for cluster from the Kubernetes
 // API and sets the PersistentVolumeResizing condition as appropriate.
@@ -57,6 +58,16 @@ func (r *Reconciler) observePersistentVolumeClaims(
 			))
 	}
 
+	// take ownership of volumes left behind by a previous PostgresCluster of the
+	// same name when asked to do so
+	if _, adopt := cluster.GetAnnotations()[naming.AdoptResources]; adopt {
+		for i := range volumes.Items {
+			if err == nil {
+				err = errors.WithStack(r.claimObject(ctx, cluster, &volumes.Items[i]))
+			}
+		}
+	}
+
 	resizing := metav1.Condition{
 		Type:    v1beta1.PersistentVolumeResizing,
 		Message: "One or more volumes are changing size",
@@ -187,6 +198,18 @@ func (r *Reconciler) configureExistingPVCs(
 
 		volumes, err = r.configureExistingRepoVolumes(ctx, cluster, volumes)
 	}
+
+	// A new cluster restored from a VolumeSnapshot starts with one instance
+	// whose data volume is populated from that snapshot. Other instances are
+	// created as replicas once it is running.
+	if cluster.Spec.DataSource != nil &&
+		cluster.Spec.DataSource.VolumeSnapshot != nil &&
+		cluster.Status.StartupInstance == "" &&
+		!patroni.ClusterBootstrapped(cluster) {
+		set := &cluster.Spec.InstanceSets[0]
+		cluster.Status.StartupInstanceSet = set.Name
+		cluster.Status.StartupInstance = naming.GenerateStartupInstance(cluster, set).Name
+	}
 	return volumes, err
 }
 
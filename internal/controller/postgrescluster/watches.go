@@ -16,6 +16,8 @@
 package postgrescluster
 
 import (
+	"context"
+
 	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -24,6 +26,7 @@ import (
 
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // watchPods returns a handler.EventHandler for Pods.
@@ -60,3 +63,73 @@ func (*Reconciler) watchPods() handler.Funcs {
 		},
 	}
 }
+
+// watchDataSourceClusters returns a handler.EventHandler for PostgresClusters.
+// When a cluster stops or changes which namespaces may restore from it, it
+// queues every cluster waiting to restore from it.
+func (r *Reconciler) watchDataSourceClusters() handler.Funcs {
+	return handler.Funcs{
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			oldCluster, okOld := e.ObjectOld.(*v1beta1.PostgresCluster)
+			newCluster, okNew := e.ObjectNew.(*v1beta1.PostgresCluster)
+			if !okOld || !okNew {
+				return
+			}
+
+			stopped := !clusterStopped(oldCluster) && clusterStopped(newCluster)
+			allowed := oldCluster.GetAnnotations()[naming.AllowDataSourceNamespaces] !=
+				newCluster.GetAnnotations()[naming.AllowDataSourceNamespaces]
+			if !stopped && !allowed {
+				return
+			}
+
+			clusters := &v1beta1.PostgresClusterList{}
+			if err := r.Client.List(context.Background(), clusters); err != nil {
+				return
+			}
+
+			source := client.ObjectKeyFromObject(newCluster)
+			for i := range clusters.Items {
+				if key, ok := dataSourceCluster(&clusters.Items[i]); ok && key == source {
+					q.Add(reconcile.Request{
+						NamespacedName: client.ObjectKeyFromObject(&clusters.Items[i]),
+					})
+				}
+			}
+		},
+	}
+}
+
+// watchConfigIncludes returns a handler.EventHandler for ConfigMaps. When a
+// ConfigMap changes, it queues every cluster in its namespace that includes
+// it in spec.backups.pgbackrest.configInclude.
+func (r *Reconciler) watchConfigIncludes() handler.Funcs {
+	enqueue := func(object client.Object, q workqueue.RateLimitingInterface) {
+		clusters := &v1beta1.PostgresClusterList{}
+		if err := r.Client.List(context.Background(), clusters,
+			client.InNamespace(object.GetNamespace())); err != nil {
+			return
+		}
+
+		for i := range clusters.Items {
+			if include := clusters.Items[i].Spec.Backups.PGBackRest.ConfigInclude; include != nil &&
+				include.Name == object.GetName() {
+				q.Add(reconcile.Request{
+					NamespacedName: client.ObjectKeyFromObject(&clusters.Items[i]),
+				})
+			}
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			enqueue(e.Object, q)
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			enqueue(e.ObjectNew, q)
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			enqueue(e.Object, q)
+		},
+	}
+}
@@ -22,9 +22,15 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestWatchPodsUpdate(t *testing.T) {
@@ -141,3 +147,133 @@ func TestWatchPodsUpdate(t *testing.T) {
 		queue.Done(item)
 	})
 }
+
+func TestWatchDataSourceClustersUpdate(t *testing.T) {
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	source := &v1beta1.PostgresCluster{}
+	source.Namespace, source.Name = "ns1", "hippo"
+
+	clone := &v1beta1.PostgresCluster{}
+	clone.Namespace, clone.Name = "ns2", "rhino"
+	clone.Spec.DataSource = &v1beta1.DataSource{
+		PostgresCluster: &v1beta1.PostgresClusterDataSource{
+			ClusterName:      "hippo",
+			ClusterNamespace: "ns1",
+		},
+	}
+
+	unrelated := &v1beta1.PostgresCluster{}
+	unrelated.Namespace, unrelated.Name = "ns1", "zebra"
+	unrelated.Spec.DataSource = &v1beta1.DataSource{
+		PostgresCluster: &v1beta1.PostgresClusterDataSource{ClusterName: "other"},
+	}
+
+	queue := controllertest.Queue{Interface: workqueue.New()}
+	reconciler := &Reconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(source, clone, unrelated).Build(),
+	}
+
+	update := reconciler.watchDataSourceClusters().UpdateFunc
+	assert.Assert(t, update != nil)
+
+	running := source.DeepCopy()
+	stopping := source.DeepCopy()
+	stopping.Spec.Shutdown = initialize.Bool(true)
+	stopping.Status.InstanceSets = []v1beta1.PostgresInstanceSetStatus{{Replicas: 1}}
+	stopped := stopping.DeepCopy()
+	stopped.Status.InstanceSets[0].Replicas = 0
+
+	// Still running; no reconcile.
+	update(event.UpdateEvent{ObjectOld: running, ObjectNew: stopping}, queue)
+	assert.Equal(t, queue.Len(), 0)
+
+	// Already stopped; no reconcile.
+	update(event.UpdateEvent{ObjectOld: stopped, ObjectNew: stopped}, queue)
+	assert.Equal(t, queue.Len(), 0)
+
+	// Now stopped; reconcile the clusters restoring from it.
+	update(event.UpdateEvent{ObjectOld: stopping, ObjectNew: stopped}, queue)
+	assert.Equal(t, queue.Len(), 1)
+
+	item, _ := queue.Get()
+	expected := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(clone)}
+	assert.Equal(t, item, expected)
+	queue.Done(item)
+
+	// Now allows other namespaces; reconcile the clusters restoring from it.
+	allowed := running.DeepCopy()
+	allowed.Annotations = map[string]string{
+		"postgres-operator.crunchydata.com/allow-data-source-namespaces": "ns2",
+	}
+	update(event.UpdateEvent{ObjectOld: running, ObjectNew: allowed}, queue)
+	assert.Equal(t, queue.Len(), 1)
+
+	item, _ = queue.Get()
+	assert.Equal(t, item, expected)
+	queue.Done(item)
+}
+
+func TestWatchConfigIncludes(t *testing.T) {
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	including := &v1beta1.PostgresCluster{}
+	including.Namespace, including.Name = "ns1", "hippo"
+	including.Spec.Backups.PGBackRest.ConfigInclude = &corev1.LocalObjectReference{Name: "extra"}
+
+	elsewhere := including.DeepCopy()
+	elsewhere.Namespace = "ns2"
+
+	unrelated := &v1beta1.PostgresCluster{}
+	unrelated.Namespace, unrelated.Name = "ns1", "zebra"
+
+	queue := controllertest.Queue{Interface: workqueue.New()}
+	reconciler := &Reconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(including, elsewhere, unrelated).Build(),
+	}
+	handler := reconciler.watchConfigIncludes()
+
+	other := &corev1.ConfigMap{}
+	other.Namespace, other.Name = "ns1", "other"
+	handler.UpdateFunc(event.UpdateEvent{ObjectOld: other, ObjectNew: other}, queue)
+	assert.Equal(t, queue.Len(), 0)
+
+	extra := &corev1.ConfigMap{}
+	extra.Namespace, extra.Name = "ns1", "extra"
+	expected := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(including)}
+
+	for _, send := range []func(){
+		func() { handler.CreateFunc(event.CreateEvent{Object: extra}, queue) },
+		func() { handler.UpdateFunc(event.UpdateEvent{ObjectOld: extra, ObjectNew: extra}, queue) },
+		func() { handler.DeleteFunc(event.DeleteEvent{Object: extra}, queue) },
+	} {
+		send()
+		assert.Equal(t, queue.Len(), 1)
+
+		item, _ := queue.Get()
+		assert.Equal(t, item, expected)
+		queue.Done(item)
+	}
+}
+
+func TestDataSourceAllowed(t *testing.T) {
+	source := &v1beta1.PostgresCluster{}
+	source.Namespace, source.Name = "ns1", "hippo"
+
+	assert.Assert(t, dataSourceAllowed(source, "ns1"), "expected same namespace")
+	assert.Assert(t, !dataSourceAllowed(source, "ns2"))
+
+	source.Annotations = map[string]string{
+		"postgres-operator.crunchydata.com/allow-data-source-namespaces": "ns2, ns3",
+	}
+	assert.Assert(t, dataSourceAllowed(source, "ns2"))
+	assert.Assert(t, dataSourceAllowed(source, "ns3"))
+	assert.Assert(t, !dataSourceAllowed(source, "ns4"))
+
+	source.Annotations["postgres-operator.crunchydata.com/allow-data-source-namespaces"] = "*"
+	assert.Assert(t, dataSourceAllowed(source, "ns4"))
+}
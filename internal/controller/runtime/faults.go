@@ -0,0 +1,285 @@
+//go:build faults
+// +build faults
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+)
+
+// Fault is a kind of failure that [Faults] can simulate.
+type Fault string
+
+const (
+	// FaultConflict fails writes to the Kubernetes API with a 409 Conflict.
+	FaultConflict Fault = "conflict"
+
+	// FaultPodExec fails commands executed in Pods without running them.
+	FaultPodExec Fault = "exec"
+
+	// FaultSlowBackup reports pgBackRest backup Jobs as running for some time
+	// after they finish.
+	FaultSlowBackup Fault = "slow-backup"
+)
+
+// Faults simulates failures so that error paths in the controllers can be
+// tested deterministically. It is available only in builds with the "faults"
+// tag; see faults_disabled.go. A nil *Faults never simulates anything.
+type Faults struct {
+	mu        sync.Mutex
+	remaining map[Fault]int
+	delays    map[Fault]time.Duration
+
+	now func() time.Time
+}
+
+// ParseFaults returns the faults described by s, a comma-separated list like
+// the PGO_FAULTS environment variable:
+//
+//	conflict=2,exec,slow-backup=30s
+//
+// A number is how many times to inject the fault before behaving normally; no
+// number means every time. FaultSlowBackup takes a duration. It returns nil
+// when s is empty.
+func ParseFaults(s string) (*Faults, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	f := &Faults{
+		remaining: make(map[Fault]int),
+		delays:    make(map[Fault]time.Duration),
+		now:       time.Now,
+	}
+	for _, item := range strings.Split(s, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+
+		switch fault := Fault(name); fault {
+		case FaultConflict, FaultPodExec:
+			count := -1
+			if value != "" {
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 1 {
+					return nil, fmt.Errorf("fault %q: expected a positive count, got %q", name, value)
+				}
+				count = n
+			}
+			f.remaining[fault] = count
+
+		case FaultSlowBackup:
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("fault %q: expected a positive duration, got %q", name, value)
+			}
+			f.delays[fault] = d
+
+		default:
+			return nil, fmt.Errorf("unknown fault %q", name)
+		}
+	}
+	return f, nil
+}
+
+// Inject reports whether fault should happen now and counts it when it does.
+func (f *Faults) Inject(fault Fault) bool {
+	if f == nil {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, ok := f.remaining[fault]
+	if !ok || n == 0 {
+		return false
+	}
+	if n > 0 {
+		f.remaining[fault] = n - 1
+	}
+	return true
+}
+
+// slowBackup reports whether job is a finished pgBackRest backup that should
+// still look like it is running because of FaultSlowBackup.
+func (f *Faults) slowBackup(job *batchv1.Job) bool {
+	if f == nil || f.delays[FaultSlowBackup] == 0 {
+		return false
+	}
+	if _, ok := job.GetLabels()[naming.LabelPGBackRestBackup]; !ok {
+		return false
+	}
+
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) &&
+			c.Status == corev1.ConditionTrue {
+			return f.now().Before(c.LastTransitionTime.Add(f.delays[FaultSlowBackup]))
+		}
+	}
+	return false
+}
+
+// Client returns a client.Client that fails writes with FaultConflict, hides
+// the end of backup Jobs with FaultSlowBackup, and otherwise calls c. It
+// returns c when f is nil.
+func (f *Faults) Client(c client.Client) client.Client {
+	if f == nil {
+		return c
+	}
+	return faultyClient{Client: c, faults: f}
+}
+
+// PodExec returns a function that fails with FaultPodExec and otherwise calls
+// exec. It returns exec when f is nil.
+func (f *Faults) PodExec(exec func(
+	namespace, pod, container string,
+	stdin io.Reader, stdout, stderr io.Writer, command ...string,
+) error) func(
+	namespace, pod, container string,
+	stdin io.Reader, stdout, stderr io.Writer, command ...string,
+) error {
+	if f == nil {
+		return exec
+	}
+	return func(
+		namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		if f.Inject(FaultPodExec) {
+			return fmt.Errorf("injected fault: exec %q in pod %s/%s", command, namespace, pod)
+		}
+		return exec(namespace, pod, container, stdin, stdout, stderr, command...)
+	}
+}
+
+type faultyClient struct {
+	client.Client
+	faults *Faults
+}
+
+// conflict returns a 409 Conflict for obj when FaultConflict should happen now.
+func (c faultyClient) conflict(obj client.Object) error {
+	if !c.faults.Inject(FaultConflict) {
+		return nil
+	}
+
+	var resource schema.GroupResource
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		resource = schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind)}
+	}
+	return apierrors.NewConflict(resource, obj.GetName(), fmt.Errorf("injected fault"))
+}
+
+// hideFinished removes the conditions that say job is finished when
+// FaultSlowBackup applies to it.
+func (c faultyClient) hideFinished(job *batchv1.Job) {
+	if !c.faults.slowBackup(job) {
+		return
+	}
+	conditions := job.Status.Conditions[:0]
+	for _, cond := range job.Status.Conditions {
+		if cond.Type != batchv1.JobComplete && cond.Type != batchv1.JobFailed {
+			conditions = append(conditions, cond)
+		}
+	}
+	job.Status.Conditions = conditions
+	job.Status.CompletionTime = nil
+}
+
+func (c faultyClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	err := c.Client.Get(ctx, key, obj)
+	if job, ok := obj.(*batchv1.Job); ok && err == nil {
+		c.hideFinished(job)
+	}
+	return err
+}
+
+func (c faultyClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	err := c.Client.List(ctx, list, opts...)
+	if jobs, ok := list.(*batchv1.JobList); ok && err == nil {
+		for i := range jobs.Items {
+			c.hideFinished(&jobs.Items[i])
+		}
+	}
+	return err
+}
+
+func (c faultyClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.conflict(obj); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c faultyClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.conflict(obj); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c faultyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.conflict(obj); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c faultyClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.conflict(obj); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c faultyClient) Status() client.StatusWriter {
+	return faultyStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type faultyStatusWriter struct {
+	client.StatusWriter
+	client faultyClient
+}
+
+func (w faultyStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := w.client.conflict(obj); err != nil {
+		return err
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+func (w faultyStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := w.client.conflict(obj); err != nil {
+		return err
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
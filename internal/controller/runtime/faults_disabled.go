@@ -0,0 +1,54 @@
+//go:build !faults
+// +build !faults
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package runtime
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Faults simulates failures in builds with the "faults" tag. In other builds,
+// like the one that is released, it never simulates anything.
+type Faults struct{}
+
+// ParseFaults returns nil when s is empty and an error otherwise because this
+// build cannot simulate failures.
+func ParseFaults(s string) (*Faults, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	return nil, errors.New(`fault injection requires PGO built with the "faults" tag`)
+}
+
+// Client returns c.
+func (*Faults) Client(c client.Client) client.Client { return c }
+
+// PodExec returns exec.
+func (*Faults) PodExec(exec func(
+	namespace, pod, container string,
+	stdin io.Reader, stdout, stderr io.Writer, command ...string,
+) error) func(
+	namespace, pod, container string,
+	stdin io.Reader, stdout, stderr io.Writer, command ...string,
+) error {
+	return exec
+}
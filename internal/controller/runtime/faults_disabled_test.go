@@ -0,0 +1,38 @@
+//go:build !faults
+// +build !faults
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package runtime
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseFaultsDisabled(t *testing.T) {
+	faults, err := ParseFaults("")
+	assert.NilError(t, err)
+	assert.Assert(t, faults == nil)
+
+	base := fake.NewClientBuilder().Build()
+	assert.Equal(t, faults.Client(base), base)
+
+	_, err = ParseFaults("conflict")
+	assert.ErrorContains(t, err, `built with the "faults" tag`)
+}
@@ -0,0 +1,196 @@
+//go:build faults
+// +build faults
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+)
+
+func TestParseFaults(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		faults, err := ParseFaults(" ")
+		assert.NilError(t, err)
+		assert.Assert(t, faults == nil)
+
+		// A nil *Faults never injects anything.
+		assert.Assert(t, !faults.Inject(FaultConflict))
+		assert.Assert(t, !faults.slowBackup(&batchv1.Job{}))
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		faults, err := ParseFaults("conflict=2, exec,slow-backup=30s")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, faults.remaining, map[Fault]int{
+			FaultConflict: 2, FaultPodExec: -1,
+		})
+		assert.DeepEqual(t, faults.delays, map[Fault]time.Duration{
+			FaultSlowBackup: 30 * time.Second,
+		})
+	})
+
+	for _, tt := range []struct{ value, message string }{
+		{"conflict=0", `fault "conflict": expected a positive count, got "0"`},
+		{"exec=x", `fault "exec": expected a positive count, got "x"`},
+		{"slow-backup", `fault "slow-backup": expected a positive duration, got ""`},
+		{"panic", `unknown fault "panic"`},
+	} {
+		_, err := ParseFaults(tt.value)
+		assert.Error(t, err, tt.message, "value: %q", tt.value)
+	}
+}
+
+func TestFaultsInject(t *testing.T) {
+	faults, err := ParseFaults("conflict=2,exec")
+	assert.NilError(t, err)
+
+	assert.Assert(t, faults.Inject(FaultConflict))
+	assert.Assert(t, faults.Inject(FaultConflict))
+	assert.Assert(t, !faults.Inject(FaultConflict), "expected only two")
+
+	for i := 0; i < 10; i++ {
+		assert.Assert(t, faults.Inject(FaultPodExec), "expected every time")
+	}
+
+	assert.Assert(t, !faults.Inject(FaultSlowBackup), "not counted")
+}
+
+func TestFaultsSlowBackup(t *testing.T) {
+	faults, err := ParseFaults("slow-backup=1m")
+	assert.NilError(t, err)
+
+	finished := time.Date(2023, time.January, 2, 3, 4, 5, 0, time.UTC)
+	faults.now = func() time.Time { return finished.Add(30 * time.Second) }
+
+	job := &batchv1.Job{}
+	job.Labels = map[string]string{naming.LabelPGBackRestBackup: "manual"}
+	assert.Assert(t, !faults.slowBackup(job), "running")
+
+	for _, condition := range []batchv1.JobConditionType{batchv1.JobComplete, batchv1.JobFailed} {
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type: condition, Status: corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(finished),
+		}}
+		assert.Assert(t, faults.slowBackup(job), "%v recently", condition)
+
+		job.Status.Conditions[0].LastTransitionTime = metav1.NewTime(finished.Add(-time.Minute))
+		assert.Assert(t, !faults.slowBackup(job), "%v long ago", condition)
+	}
+
+	t.Run("OtherJobs", func(t *testing.T) {
+		other := job.DeepCopy()
+		other.Labels = nil
+		other.Status.Conditions[0].LastTransitionTime = metav1.NewTime(finished)
+		assert.Assert(t, !faults.slowBackup(other))
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		ctx := context.Background()
+		job := job.DeepCopy()
+		job.Namespace, job.Name = "ns1", "backup"
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(finished),
+		}}
+
+		base := fake.NewClientBuilder().WithObjects(job).Build()
+		cc := faults.Client(base)
+
+		var got batchv1.Job
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(job), &got))
+		assert.Equal(t, len(got.Status.Conditions), 0, "expected running")
+
+		var list batchv1.JobList
+		assert.NilError(t, cc.List(ctx, &list))
+		assert.Equal(t, len(list.Items), 1)
+		assert.Equal(t, len(list.Items[0].Status.Conditions), 0, "expected running")
+
+		assert.NilError(t, base.Get(ctx, client.ObjectKeyFromObject(job), &got))
+		assert.Equal(t, len(got.Status.Conditions), 1, "expected no change to the API")
+	})
+}
+
+func TestFaultsClient(t *testing.T) {
+	ctx := context.Background()
+	base := fake.NewClientBuilder().Build()
+
+	var none *Faults
+	assert.Equal(t, none.Client(base), base)
+
+	faults, err := ParseFaults("conflict=1")
+	assert.NilError(t, err)
+	cc := faults.Client(base)
+
+	cm := &corev1.ConfigMap{}
+	cm.Namespace, cm.Name = "ns1", "cm1"
+
+	err = cc.Create(ctx, cm)
+	assert.Assert(t, apierrors.IsConflict(err), "got %#v", err)
+	assert.ErrorContains(t, err, `configmap "cm1"`)
+	assert.ErrorContains(t, err, "injected fault")
+
+	assert.Assert(t, apierrors.IsNotFound(
+		base.Get(ctx, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{})),
+		"expected no write")
+
+	assert.NilError(t, cc.Create(ctx, cm), "expected only one")
+	assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{}))
+
+	t.Run("Status", func(t *testing.T) {
+		faults, err := ParseFaults("conflict")
+		assert.NilError(t, err)
+
+		err = faults.Client(base).Status().Update(ctx, cm)
+		assert.Assert(t, apierrors.IsConflict(err), "got %#v", err)
+	})
+}
+
+func TestFaultsPodExec(t *testing.T) {
+	var calls int
+	exec := func(
+		namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		calls++
+		return nil
+	}
+
+	faults, err := ParseFaults("exec=1")
+	assert.NilError(t, err)
+	wrapped := faults.PodExec(exec)
+
+	err = wrapped("ns1", "pod1", "database", nil, nil, nil, "pgbackrest", "info")
+	assert.ErrorContains(t, err, `injected fault: exec ["pgbackrest" "info"] in pod ns1/pod1`)
+	assert.Equal(t, calls, 0)
+
+	assert.NilError(t, wrapped("ns1", "pod1", "database", nil, nil, nil, "true"))
+	assert.Equal(t, calls, 1)
+}
@@ -18,9 +18,11 @@ limitations under the License.
 import (
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
@@ -47,6 +49,10 @@ func CreateRuntimeManager(namespace string, config *rest.Config,
 		Namespace:  namespace, // if empty then watching all namespaces
 		SyncPeriod: &refreshInterval,
 		Scheme:     pgoScheme,
+
+		// Nodes are cluster-scoped and read only occasionally. Read them
+		// directly so that PGO can run without permission to watch them.
+		ClientDisableCacheFor: []client.Object{&corev1.Node{}},
 	}
 	if disableMetrics {
 		options.HealthProbeBindAddress = "0"
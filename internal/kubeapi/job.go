@@ -0,0 +1,50 @@
+package kubeapi
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// IgnoreJobDisruptions returns object, a Job or CronJob, as unstructured content
+// with a pod failure policy that does not count pods that fail because they were
+// disrupted toward the backoffLimit of its Jobs. The Job API this module was
+// built against predates the field, so it is added here. It requires Kubernetes
+// 1.26 or later and a pod template with RestartPolicy "Never".
+// - https://docs.k8s.io/concepts/workloads/controllers/job/#pod-failure-policy
+func IgnoreJobDisruptions(object runtime.Object) (*unstructured.Unstructured, error) {
+	path := []string{"spec", "podFailurePolicy"}
+	if _, ok := object.(*batchv1.CronJob); ok {
+		path = []string{"spec", "jobTemplate", "spec", "podFailurePolicy"}
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err == nil {
+		err = unstructured.SetNestedField(content, map[string]interface{}{
+			"rules": []interface{}{
+				map[string]interface{}{
+					"action": "Ignore",
+					"onPodConditions": []interface{}{
+						map[string]interface{}{"type": "DisruptionTarget", "status": "True"},
+					},
+				},
+			},
+		}, path...)
+	}
+	return &unstructured.Unstructured{Object: content}, err
+}
@@ -0,0 +1,70 @@
+package kubeapi
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+func TestIgnoreJobDisruptions(t *testing.T) {
+	const policy = `{"rules":[{"action":"Ignore","onPodConditions":[{"status":"True","type":"DisruptionTarget"}]}]}`
+
+	t.Run("Job", func(t *testing.T) {
+		job := &batchv1.Job{}
+		job.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+		job.Name = "some-job"
+		job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+
+		result, err := IgnoreJobDisruptions(job)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if result.GetKind() != "Job" || result.GetName() != "some-job" {
+			t.Fatalf("expected the same object, got %v", result.Object)
+		}
+
+		restart, _, _ := unstructured.NestedString(result.Object,
+			"spec", "template", "spec", "restartPolicy")
+		if restart != "Never" {
+			t.Fatalf("expected the same spec, got %v", result.Object)
+		}
+
+		actual, _, _ := unstructured.NestedMap(result.Object, "spec", "podFailurePolicy")
+		b, _ := json.Marshal(actual)
+		assertJSON(t, policy, b)
+	})
+
+	t.Run("CronJob", func(t *testing.T) {
+		cronjob := &batchv1.CronJob{}
+		cronjob.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("CronJob"))
+
+		result, err := IgnoreJobDisruptions(cronjob)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		actual, _, _ := unstructured.NestedMap(result.Object,
+			"spec", "jobTemplate", "spec", "podFailurePolicy")
+		b, _ := json.Marshal(actual)
+		assertJSON(t, policy, b)
+	})
+}
@@ -15,12 +15,29 @@
 
 package naming
 
-const (
+// These annotations are variables so that their domain can be changed by
+// PGO_LABEL_DOMAIN; see [LabelDomain].
+var (
 	annotationPrefix = labelPrefix
 
 	// Finalizer marks an object to be garbage collected by this module.
 	Finalizer = annotationPrefix + "finalizer"
 
+	// AdoptResources is the annotation added to a PostgresCluster to have it take ownership
+	// of StatefulSets and PersistentVolumeClaims still owned by a deleted PostgresCluster of
+	// the same name, e.g. after the PostgresCluster is deleted and created again.
+	AdoptResources = annotationPrefix + "adopt-resources"
+
+	// AllowDataSourceNamespaces is the annotation added to a PostgresCluster to allow
+	// PostgresClusters in other namespaces to restore from its backups. The value is a
+	// comma-separated list of namespaces, or "*" to allow every namespace.
+	AllowDataSourceNamespaces = annotationPrefix + "allow-data-source-namespaces"
+
+	// ReadOnly is the annotation added to a PostgresCluster to make PostgreSQL refuse writes
+	// for a while, e.g. during a migration cutover, without changing its spec. The cluster is
+	// read-only while the value is "true".
+	ReadOnly = annotationPrefix + "read-only"
+
 	// PatroniSwitchover is the annotation added to a PostgresCluster to initiate a manual
 	// Patroni Switchover (or Failover).
 	PatroniSwitchover = annotationPrefix + "trigger-switchover"
@@ -32,6 +49,17 @@ const (
 	// ID associated with a specific manual backup Job.
 	PGBackRestBackup = annotationPrefix + "pgbackrest-backup"
 
+	// PGBackRestExpireDryRun is the annotation that is added to a PostgresCluster to run the
+	// pgBackRest "expire" command with "--dry-run" in each repository.  The value of the
+	// annotation will be a unique identifier for the dry run (e.g. a timestamp), which will be
+	// stored in the PostgresCluster status along with what would be removed.
+	PGBackRestExpireDryRun = annotationPrefix + "pgbackrest-expire-dry-run"
+
+	// PGBackRestBackupHooks is the annotation added to a scheduled pgBackRest backup Job to
+	// track the hooks around it. The value is "Started" once its before hook succeeded and the
+	// Job was allowed to run, and "Finished" once its after hook ran.
+	PGBackRestBackupHooks = annotationPrefix + "pgbackrest-backup-hooks"
+
 	// PGBackRestConfigHash is an annotation used to specify the hash value associated with a
 	// repo configuration as needed to detect configuration changes that invalidate running Jobs
 	// (and therefore must be recreated)
@@ -58,4 +86,24 @@ const (
 	// for this annotation is due to an issue in pgBackRest (#1841) where using a wildcard address to
 	// bind all addresses does not work in certain IPv6 environments.
 	PGBackRestIPVersion = annotationPrefix + "pgbackrest-ip-version"
+
+	// LogicalRestore is the annotation that is added to a PostgresCluster to restore the logical
+	// backup in its spec into the running cluster. The value of the annotation is a unique
+	// identifier for the restore (e.g. a timestamp). It is also added to the restore Job.
+	LogicalRestore = annotationPrefix + "logical-restore"
+
+	// Benchmark is the annotation that is added to a PostgresCluster to run the pgbench benchmark
+	// in its spec. The value of the annotation is a unique identifier for the run (e.g. a
+	// timestamp). It is also added to the benchmark Job.
+	Benchmark = annotationPrefix + "benchmark"
+
+	// VolumeSnapshot is the annotation that is added to a PostgresCluster to take a VolumeSnapshot
+	// of the data volume of its primary. The value of the annotation is a unique identifier for
+	// the snapshot (e.g. a timestamp). It is also added to the VolumeSnapshot to identify the
+	// request that created it.
+	VolumeSnapshot = annotationPrefix + "volume-snapshot"
+
+	// VolumeSnapshotBackupStopped is an annotation added to a VolumeSnapshot once PostgreSQL has
+	// ended the backup that was started for it.
+	VolumeSnapshotBackupStopped = annotationPrefix + "volume-snapshot-backup-stopped"
 )
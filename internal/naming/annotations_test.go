@@ -23,6 +23,8 @@ import (
 )
 
 func TestAnnotationsValid(t *testing.T) {
+	assert.Assert(t, nil == validation.IsQualifiedName(AllowDataSourceNamespaces))
+	assert.Assert(t, nil == validation.IsQualifiedName(ReadOnly))
 	assert.Assert(t, nil == validation.IsQualifiedName(Finalizer))
 	assert.Assert(t, nil == validation.IsQualifiedName(PatroniSwitchover))
 	assert.Assert(t, nil == validation.IsQualifiedName(PGBackRestBackup))
@@ -17,11 +17,16 @@ package naming
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"os"
 	"strings"
+	"sync"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // InstancePodDNSNames returns the possible DNS names for instance. The first
@@ -77,23 +82,93 @@ func ServiceDNSNames(ctx context.Context, service *corev1.Service) []string {
 	}
 }
 
-// KubernetesClusterDomain looks up the Kubernetes cluster domain name.
+// KubernetesClusterDomainVariable is the environment variable that sets the
+// Kubernetes cluster domain name rather than looking it up.
+const KubernetesClusterDomainVariable = "PGO_KUBERNETES_CLUSTER_DOMAIN"
+
+// The domain name that was looked up is remembered for some time so that it is
+// not looked up for every object on every reconcile. A failed lookup is tried
+// again sooner.
+var clusterDomain = &domainCache{
+	lookup:     lookupKubernetesClusterDomain,
+	now:        time.Now,
+	ttl:        5 * time.Minute,
+	failureTTL: 10 * time.Second,
+}
+
+// domainCache remembers the result of lookup until it expires.
+type domainCache struct {
+	lookup          func(context.Context) (string, error)
+	now             func() time.Time
+	ttl, failureTTL time.Duration
+
+	mu      sync.Mutex
+	domain  string
+	expires time.Time
+}
+
+// get returns the remembered domain name or looks it up when that has expired.
+// When the lookup fails, it returns "cluster.local.", the kubeadm default, which
+// is adequate when not running in an actual Kubernetes cluster.
+func (c *domainCache) get(ctx context.Context) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now := c.now(); c.domain == "" || !now.Before(c.expires) {
+		domain, err := c.lookup(ctx)
+
+		switch {
+		case err == nil:
+			c.domain, c.expires = domain, now.Add(c.ttl)
+
+		// Remember the default only when the lookup itself failed. A canceled
+		// or expired ctx says nothing about DNS.
+		case ctx.Err() == nil:
+			c.domain, c.expires = "cluster.local.", now.Add(c.failureTTL)
+
+		default:
+			if c.domain != "" {
+				return c.domain
+			}
+			return "cluster.local."
+		}
+	}
+	return c.domain
+}
+
+// KubernetesClusterDomain returns the Kubernetes cluster domain name with a
+// trailing dot. It is the value of PGO_KUBERNETES_CLUSTER_DOMAIN when that is
+// set and is looked up otherwise.
 func KubernetesClusterDomain(ctx context.Context) string {
+	if domain := os.Getenv(KubernetesClusterDomainVariable); domain != "" {
+		return strings.TrimSuffix(domain, ".") + "."
+	}
+	return clusterDomain.get(ctx)
+}
+
+// ValidateKubernetesClusterDomain returns an error when domain cannot be a
+// Kubernetes cluster domain name. A trailing dot is allowed.
+func ValidateKubernetesClusterDomain(domain string) error {
+	if errs := validation.IsDNS1123Subdomain(strings.TrimSuffix(domain, ".")); len(errs) > 0 {
+		return fmt.Errorf("invalid cluster domain %q: %s", domain, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// lookupKubernetesClusterDomain looks up the Kubernetes cluster domain name.
+func lookupKubernetesClusterDomain(ctx context.Context) (string, error) {
 	ctx, span := tracer.Start(ctx, "kubernetes-domain-lookup")
 	defer span.End()
 
 	// Lookup an existing Service to determine its fully qualified domain name.
-	// This is inexpensive because the "net" package uses OS-level DNS caching.
 	// - https://golang.org/issue/24796
 	api := "kubernetes.default.svc"
 	cname, err := net.DefaultResolver.LookupCNAME(ctx, api)
 
 	if err == nil {
-		return strings.TrimPrefix(cname, api+".")
+		return strings.TrimPrefix(cname, api+"."), nil
 	}
 
 	span.RecordError(err)
-	// The kubeadm default is "cluster.local" and is adequate when not running
-	// in an actual Kubernetes cluster.
-	return "cluster.local."
+	return "", err
 }
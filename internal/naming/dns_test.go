@@ -17,6 +17,7 @@ package naming
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -70,3 +71,85 @@ func TestServiceDNSNames(t *testing.T) {
 	assert.Assert(t, strings.HasPrefix(names[0], names[1]+"."), "wrong FQDN: %q", names[0])
 	assert.Assert(t, strings.HasSuffix(names[0], "."), "expected root, got %q", names[0])
 }
+
+func TestKubernetesClusterDomain(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Variable", func(t *testing.T) {
+		t.Setenv(KubernetesClusterDomainVariable, "example.com")
+		assert.Equal(t, KubernetesClusterDomain(ctx), "example.com.")
+
+		t.Setenv(KubernetesClusterDomainVariable, "example.com.")
+		assert.Equal(t, KubernetesClusterDomain(ctx), "example.com.")
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		assert.NilError(t, ValidateKubernetesClusterDomain("cluster.local"))
+		assert.NilError(t, ValidateKubernetesClusterDomain("cluster.local."))
+		assert.ErrorContains(t, ValidateKubernetesClusterDomain("Cluster_Local"), "invalid cluster domain")
+	})
+}
+
+func TestDomainCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var calls int
+	var result string
+	var failure error
+
+	cache := &domainCache{
+		lookup: func(context.Context) (string, error) {
+			calls++
+			return result, failure
+		},
+		now:        func() time.Time { return now },
+		ttl:        time.Minute,
+		failureTTL: time.Second,
+	}
+
+	result = "first.example."
+	assert.Equal(t, cache.get(ctx), "first.example.")
+	assert.Equal(t, calls, 1)
+
+	// Remembered until it expires.
+	result = "second.example."
+	now = now.Add(59 * time.Second)
+	assert.Equal(t, cache.get(ctx), "first.example.")
+	assert.Equal(t, calls, 1)
+
+	now = now.Add(time.Second)
+	assert.Equal(t, cache.get(ctx), "second.example.")
+	assert.Equal(t, calls, 2)
+
+	t.Run("Failure", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		failure = errors.New("no such host")
+
+		assert.Equal(t, cache.get(ctx), "cluster.local.")
+		assert.Equal(t, cache.get(ctx), "cluster.local.")
+		assert.Equal(t, calls, 3, "expected failures to be remembered")
+
+		now = now.Add(time.Second)
+		failure = nil
+		assert.Equal(t, cache.get(ctx), "second.example.")
+		assert.Equal(t, calls, 4)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		now = now.Add(time.Minute)
+		failure = canceled.Err()
+
+		assert.Equal(t, cache.get(canceled), "second.example.",
+			"expected the previous domain")
+		assert.Equal(t, cache.get(canceled), "second.example.")
+		assert.Equal(t, calls, 6, "expected nothing to be remembered")
+
+		empty := &domainCache{lookup: cache.lookup, now: cache.now}
+		assert.Equal(t, empty.get(canceled), "cluster.local.")
+		assert.Equal(t, empty.domain, "")
+	})
+}
@@ -0,0 +1,72 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package naming
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// DefaultLabelDomain is the domain of every label and annotation that PGO
+	// reads and writes when PGO_LABEL_DOMAIN is not set.
+	DefaultLabelDomain = "postgres-operator.crunchydata.com"
+
+	// LabelDomainVariable is the environment variable that changes the domain
+	// of every label and annotation that PGO reads and writes.
+	LabelDomainVariable = "PGO_LABEL_DOMAIN"
+)
+
+// labelPrefix is read from the environment when the package is initialized so
+// that every label and annotation below is consistent from the start.
+var labelPrefix = labelDomainFromEnvironment() + "/"
+
+// labelDomainFromEnvironment returns the value of PGO_LABEL_DOMAIN when it is
+// valid and DefaultLabelDomain otherwise. Use [ValidateLabelDomain] to report
+// an invalid value.
+func labelDomainFromEnvironment() string {
+	if domain := os.Getenv(LabelDomainVariable); domain != "" &&
+		ValidateLabelDomain(domain) == nil {
+		return domain
+	}
+	return DefaultLabelDomain
+}
+
+// LabelDomain returns the domain of every label and annotation that PGO reads
+// and writes, e.g. the "postgres-operator.crunchydata.com" in
+// "postgres-operator.crunchydata.com/cluster".
+func LabelDomain() string { return strings.TrimSuffix(labelPrefix, "/") }
+
+// LabelInDomain returns the key of label, a label or annotation of this
+// package, as it would be in domain. Other keys are returned unchanged.
+func LabelInDomain(domain, label string) string {
+	if name := strings.TrimPrefix(label, labelPrefix); name != label {
+		return domain + "/" + name
+	}
+	return label
+}
+
+// ValidateLabelDomain returns an error when domain cannot be the prefix of a
+// label or annotation key.
+func ValidateLabelDomain(domain string) error {
+	if errs := validation.IsDNS1123Subdomain(domain); len(errs) > 0 {
+		return fmt.Errorf("invalid label domain %q: %s", domain, strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package naming
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLabelDomain(t *testing.T) {
+	assert.Equal(t, LabelDomain(), DefaultLabelDomain)
+	assert.Equal(t, LabelCluster, "postgres-operator.crunchydata.com/cluster")
+	assert.Equal(t, LabelInDomain("pg.example.com", LabelCluster), "pg.example.com/cluster")
+	assert.Equal(t, LabelInDomain("pg.example.com", "app.kubernetes.io/name"), "app.kubernetes.io/name")
+
+	t.Run("Environment", func(t *testing.T) {
+		t.Setenv(LabelDomainVariable, "pg.example.com")
+		assert.Equal(t, labelDomainFromEnvironment(), "pg.example.com")
+
+		t.Setenv(LabelDomainVariable, "Not A Domain")
+		assert.Equal(t, labelDomainFromEnvironment(), DefaultLabelDomain)
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		before := labelPrefix
+		t.Cleanup(func() { labelPrefix = before })
+		labelPrefix = "pg.example.com/"
+
+		assert.Equal(t, LabelDomain(), "pg.example.com")
+		assert.Equal(t, LabelInDomain(DefaultLabelDomain, "pg.example.com/cluster"),
+			"postgres-operator.crunchydata.com/cluster")
+	})
+}
+
+func TestValidateLabelDomain(t *testing.T) {
+	assert.NilError(t, ValidateLabelDomain(DefaultLabelDomain))
+	assert.NilError(t, ValidateLabelDomain("pg.example.com"))
+
+	assert.ErrorContains(t, ValidateLabelDomain(""), `invalid label domain ""`)
+	assert.ErrorContains(t, ValidateLabelDomain("pg.example.com/"), "invalid label domain")
+	assert.ErrorContains(t, ValidateLabelDomain("PG.Example.com"), "invalid label domain")
+}
@@ -16,12 +16,13 @@
 package naming
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/labels"
 )
 
-const (
-	labelPrefix = "postgres-operator.crunchydata.com/"
-
+// These labels are variables so that their domain can be changed by
+// PGO_LABEL_DOMAIN; see [LabelDomain].
+var (
 	// LabelCluster et al. provides the fundamental labels for Postgres instances
 	LabelCluster     = labelPrefix + "cluster"
 	LabelInstance    = labelPrefix + "instance"
@@ -57,6 +58,10 @@ const (
 	// LabelPGBackRestBackup is used to indicate that a resource is for a pgBackRest backup
 	LabelPGBackRestBackup = labelPrefix + "pgbackrest-backup"
 
+	// LabelPGBackRestBackupType is used to indicate the type of backup (full, diff, or incr)
+	// requested of a pgBackRest backup Job
+	LabelPGBackRestBackupType = labelPrefix + "pgbackrest-backup-type"
+
 	// LabelPGBackRestConfig is used to indicate that a ConfigMap or Secret is for pgBackRest
 	LabelPGBackRestConfig = labelPrefix + "pgbackrest-config"
 
@@ -81,6 +86,19 @@ const (
 	// resource (e.g. a ConfigMap or Secret) is for a pgBackRest restore
 	LabelPGBackRestRestoreConfig = labelPrefix + "pgbackrest-restore-config"
 
+	// LabelPGBackRestRestoreDryRun is used to indicate that a Job or Pod checks a pgBackRest
+	// restore without restoring anything
+	LabelPGBackRestRestoreDryRun = labelPrefix + "pgbackrest-restore-dry-run"
+
+	// LabelRemoteSourceCluster and LabelRemoteSourceNamespace identify the
+	// PostgresCluster that a remote instance set streams from.
+	LabelRemoteSourceCluster   = labelPrefix + "remote-source-cluster"
+	LabelRemoteSourceNamespace = labelPrefix + "remote-source-namespace"
+
+	// LabelSharedRepoHost is used to indicate that a resource is for the SharedRepoHost
+	// named by its value
+	LabelSharedRepoHost = labelPrefix + "shared-repo-host"
+
 	// LabelPGMonitorDiscovery is the label added to Pods running the "exporter" container to
 	// support discovery by Prometheus according to pgMonitor configuration
 	LabelPGMonitorDiscovery = labelPrefix + "crunchy-postgres-exporter"
@@ -90,7 +108,9 @@ const (
 
 	// LabelStartupInstance is used to indicate the startup instance associated with a resource
 	LabelStartupInstance = labelPrefix + "startup-instance"
+)
 
+const (
 	RolePrimary = "primary"
 	RoleReplica = "replica"
 
@@ -117,8 +137,18 @@ const (
 	// RolePostgresWAL is the LabelRole applied to PostgreSQL WAL volumes.
 	RolePostgresWAL = "pgwal"
 
+	// RolePGBackRestSpool is the LabelRole applied to pgBackRest spool volumes.
+	RolePGBackRestSpool = "pgspool"
+
 	// RoleMonitoring is the LabelRole applied to Monitoring resources
 	RoleMonitoring = "monitoring"
+
+	// RoleLogicalBackup is the LabelRole applied to the objects of logical
+	// backups and restores.
+	RoleLogicalBackup = "logical-backup"
+
+	// RoleBenchmark is the LabelRole applied to the objects of pgbench benchmarks.
+	RoleBenchmark = "benchmark"
 )
 
 const (
@@ -224,6 +254,16 @@ func PGBackRestRestoreJobSelector(clusterName string) labels.Selector {
 	return PGBackRestRestoreJobLabels(clusterName).AsSelector()
 }
 
+// PGBackRestRestoreDryRunJobLabels provides labels for Jobs that check a pgBackRest restore
+// without restoring anything.
+func PGBackRestRestoreDryRunJobLabels(clusterName string) labels.Set {
+	commonLabels := PGBackRestLabels(clusterName)
+	jobLabels := map[string]string{
+		LabelPGBackRestRestoreDryRun: "",
+	}
+	return labels.Merge(jobLabels, commonLabels)
+}
+
 // PGBackRestRepoLabels provides common labels for pgBackRest repository
 // resources.
 func PGBackRestRepoLabels(clusterName, repoName string) labels.Set {
@@ -275,6 +315,27 @@ func PGBackRestDedicatedSelector(clusterName string) labels.Selector {
 	return PGBackRestDedicatedLabels(clusterName).AsSelector()
 }
 
+// PGBackRestActiveRepoHostLabels provides labels for selecting podName, the active
+// pod of a pgBackRest dedicated repository host
+func PGBackRestActiveRepoHostLabels(clusterName, podName string) labels.Set {
+	return labels.Merge(PGBackRestDedicatedLabels(clusterName), map[string]string{
+		appsv1.StatefulSetPodNameLabel: podName,
+	})
+}
+
+// SharedRepoHostLabels provides labels for the resources of a SharedRepoHost
+func SharedRepoHostLabels(hostName string) labels.Set {
+	return map[string]string{
+		LabelSharedRepoHost: hostName,
+	}
+}
+
+// SharedRepoHostSelector provides a selector for querying the resources of a
+// SharedRepoHost
+func SharedRepoHostSelector(hostName string) labels.Selector {
+	return SharedRepoHostLabels(hostName).AsSelector()
+}
+
 // PGBackRestRepoVolumeLabels the labels for a pgBackRest repository volume.
 func PGBackRestRepoVolumeLabels(clusterName, repoName string) labels.Set {
 	repoLabels := PGBackRestRepoLabels(clusterName, repoName)
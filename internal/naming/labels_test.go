@@ -36,13 +36,16 @@ func TestLabelsValid(t *testing.T) {
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelRole))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRest))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestBackup))
+	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestBackupType))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestConfig))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestDedicated))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestRepo))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestRepoVolume))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestRestore))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestRestoreConfig))
+	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestRestoreDryRun))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGMonitorDiscovery))
+	assert.Assert(t, nil == validation.IsQualifiedName(LabelSharedRepoHost))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPostgresUser))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelStartupInstance))
 }
@@ -55,6 +58,7 @@ func TestLabelValuesValid(t *testing.T) {
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePatroniReplica))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePGAdmin))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePGBouncer))
+	assert.Assert(t, nil == validation.IsValidLabelValue(RolePGBackRestSpool))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePostgresData))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePostgresUser))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePostgresWAL))
@@ -201,6 +205,12 @@ func TestPGBackRestLabelFuncs(t *testing.T) {
 	pgBackRestDedicatedSelector := PGBackRestDedicatedSelector(clusterName)
 	assert.Check(t, pgBackRestDedicatedSelector.Matches(pgBackRestDedicatedLabels))
 
+	// verify the labels that identify shared repository host resources
+	sharedRepoHostLabels := SharedRepoHostLabels("shared")
+	assert.Equal(t, sharedRepoHostLabels.Get(LabelSharedRepoHost), "shared")
+	assert.Check(t, SharedRepoHostSelector("shared").Matches(sharedRepoHostLabels))
+	assert.Check(t, !SharedRepoHostSelector("other").Matches(sharedRepoHostLabels))
+
 	// verify the labels that identify pgBackRest repository volume resources
 	pgBackRestRepoVolumeLabels := PGBackRestRepoVolumeLabels(clusterName, repoName)
 	assert.Equal(t, pgBackRestRepoVolumeLabels.Get(LabelCluster), clusterName)
@@ -214,6 +224,12 @@ func TestPGBackRestLabelFuncs(t *testing.T) {
 	assert.Check(t, pgBackRestRestoreJobLabels.Has(LabelPGBackRest))
 	assert.Check(t, pgBackRestRestoreJobLabels.Has(LabelPGBackRestRestore))
 
+	// verify the labels that identify pgBackRest restore dry run Jobs
+	pgBackRestRestoreDryRunJobLabels := PGBackRestRestoreDryRunJobLabels(clusterName)
+	assert.Equal(t, pgBackRestRestoreDryRunJobLabels.Get(LabelCluster), clusterName)
+	assert.Check(t, pgBackRestRestoreDryRunJobLabels.Has(LabelPGBackRestRestoreDryRun))
+	assert.Check(t, !pgBackRestRestoreDryRunJobLabels.Has(LabelPGBackRestRestore))
+
 	// verify the labels that identify pgBackRest restore configuration resources
 	pgBackRestRestoreConfigLabels := PGBackRestRestoreConfigLabels(clusterName)
 	assert.Equal(t, pgBackRestRestoreConfigLabels.Get(LabelCluster), clusterName)
@@ -56,6 +56,9 @@ const (
 	// setting proper permissions on the client certificate and key after initialization whenever
 	// there is a change in the certificates or key
 	ContainerClientCertCopy = "replication-cert-copy"
+	// ContainerLogCleanup is the name of the container that deletes old PostgreSQL and
+	// pgBackRest log files on the data volume of an instance.
+	ContainerLogCleanup = "log-cleanup"
 	// ContainerNSSWrapperInit is the name of the init container utilized to configure support
 	// for the nss_wrapper
 	ContainerNSSWrapperInit = "nss-wrapper-init"
@@ -67,6 +70,10 @@ const (
 	// ContainerPGMonitorExporter is the name of a container running postgres_exporter
 	ContainerPGMonitorExporter = "exporter"
 
+	// ContainerRepoSync is the name of the container that copies a pgBackRest repository
+	// to its sync bucket
+	ContainerRepoSync = "repo-sync"
+
 	// ContainerJobMovePGDataDir is the name of the job container utilized to copy v4 Operator
 	// pgData directories to the v5 default location
 	ContainerJobMovePGDataDir = "pgdata-move-job"
@@ -156,10 +163,18 @@ const (
 	// PostgreSQL instance.
 	PGBackRestPGDataLogPath = "/pgdata/pgbackrest/log"
 
+	// PGBackRestPGDataSpoolPath is the pgBackRest spool path used by the PostgreSQL
+	// instance when asynchronous archiving does not have its own volume.
+	PGBackRestPGDataSpoolPath = "/pgdata/pgbackrest/spool"
+
 	// PGBackRestRepoLogPath is the pgBackRest default log path configuration used by the
 	// dedicated repo host, if configured.
 	PGBackRestRepoLogPath = "/pgbackrest/%s/log"
 
+	// PGBackRestRepoLockPath is the pgBackRest lock path used by the dedicated repo
+	// host when it has more than one pod, so that the pods share their locks.
+	PGBackRestRepoLockPath = "/pgbackrest/%s/lock"
+
 	// suffix used with postgrescluster name for associated configmap.
 	// for instance, if the cluster is named 'mycluster', the
 	// configmap will be named 'mycluster-pgbackrest-config'
@@ -192,6 +207,15 @@ func AsObjectKey(m metav1.ObjectMeta) client.ObjectKey {
 	return client.ObjectKey{Namespace: m.Namespace, Name: m.Name}
 }
 
+// ClusterCABundle returns the ObjectMeta necessary to lookup the ConfigMap
+// that publishes the certificate authority of cluster to its clients.
+func ClusterCABundle(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Name + "-ca-bundle",
+	}
+}
+
 // ClusterConfigMap returns the ObjectMeta necessary to lookup
 // cluster's shared ConfigMap.
 func ClusterConfigMap(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
@@ -288,6 +312,60 @@ func GenerateStartupInstance(
 	}
 }
 
+// ClusterVolumeSnapshot returns a stable name for the VolumeSnapshot requested
+// with id. Its suffix is a hash of id.
+func ClusterVolumeSnapshot(cluster *v1beta1.PostgresCluster, id string) metav1.ObjectMeta {
+	// hash.Hash.Write never returns an error: https://pkg.go.dev/hash#Hash.
+	hash := fnv.New32()
+	_, _ = hash.Write([]byte(id))
+	suffix := rand.SafeEncodeString(fmt.Sprint(hash.Sum32()))
+
+	return metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Name + "-pgdata-" + suffix,
+	}
+}
+
+// RemoteInstanceSet returns the ObjectMeta of the standby PostgresCluster that
+// runs the remote instance set of cluster. Its name is that of cluster and set
+// followed by a hash of the namespace of cluster, so clusters with the same
+// name in different namespaces can have remote sets in the same namespace.
+func RemoteInstanceSet(
+	cluster *v1beta1.PostgresCluster, set *v1beta1.PostgresRemoteInstanceSetSpec,
+) metav1.ObjectMeta {
+	name := cluster.Name
+	if set.Name != "" {
+		name += "-" + set.Name
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(cluster.Namespace))
+	name += fmt.Sprintf("-%04x", hash.Sum32()&0xffff)
+
+	return metav1.ObjectMeta{
+		Namespace: set.Namespace,
+		Name:      name,
+	}
+}
+
+// RemoteInstanceSetTLSSecret returns the ObjectMeta of the Secret that holds
+// the server certificate of remote, signed by the authority of its source.
+func RemoteInstanceSetTLSSecret(remote *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: remote.Namespace,
+		Name:      remote.Name + "-source-cert",
+	}
+}
+
+// RemoteInstanceSetReplicationSecret returns the ObjectMeta of the Secret that
+// holds the replication client certificate remote uses to stream from its source.
+func RemoteInstanceSetReplicationSecret(remote *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: remote.Namespace,
+		Name:      remote.Name + "-source-replication-cert",
+	}
+}
+
 // InstanceConfigMap returns the ObjectMeta necessary to lookup
 // instance's shared ConfigMap.
 func InstanceConfigMap(instance metav1.Object) metav1.ObjectMeta {
@@ -345,6 +423,15 @@ func InstancePostgresWALVolume(instance *appsv1.StatefulSet) metav1.ObjectMeta {
 	}
 }
 
+// InstancePGBackRestSpoolVolume returns the ObjectMeta for the pgBackRest
+// spool volume for instance.
+func InstancePGBackRestSpoolVolume(instance *appsv1.StatefulSet) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: instance.GetNamespace(),
+		Name:      instance.GetName() + "-pgspool",
+	}
+}
+
 // MonitoringUserSecret returns ObjectMeta necessary to lookup the Secret
 // containing authentication credentials for monitoring tools.
 func MonitoringUserSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
@@ -354,6 +441,33 @@ func MonitoringUserSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	}
 }
 
+// LogicalBackup returns the ObjectMeta of the CronJob, PersistentVolumeClaim,
+// and Secret of the logical backups of cluster.
+func LogicalBackup(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Name + "-logical-backup",
+	}
+}
+
+// LogicalRestoreJob returns the ObjectMeta for the Job that restores a logical
+// backup into cluster.
+func LogicalRestoreJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Name + "-logical-restore",
+	}
+}
+
+// BenchmarkJob returns the ObjectMeta for the Job that runs pgbench against
+// cluster.
+func BenchmarkJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Name + "-benchmark",
+	}
+}
+
 // ExporterWebConfigMap returns ObjectMeta necessary to lookup and create the
 // exporter web configmap. This configmap is used to configure the exporter
 // web server.
@@ -460,6 +574,15 @@ func PGBackRestRestoreJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	}
 }
 
+// PGBackRestRestoreDryRunJob returns the ObjectMeta for a Job that checks a pgBackRest restore
+// without restoring anything
+func PGBackRestRestoreDryRunJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.GetNamespace(),
+		Name:      cluster.Name + "-pgbackrest-restore-dry-run",
+	}
+}
+
 // PGBackRestRBAC returns the ObjectMeta necessary to lookup the ServiceAccount, Role, and
 // RoleBinding for pgBackRest Jobs
 func PGBackRestRBAC(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
@@ -469,6 +592,15 @@ func PGBackRestRBAC(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	}
 }
 
+// PGBackRestRepoHostService returns the ObjectMeta necessary to lookup the Service
+// that exposes the active pod of a dedicated repository host with more than one pod.
+func PGBackRestRepoHostService(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Name + "-repo-host",
+	}
+}
+
 // PGBackRestRepoVolume returns the ObjectMeta for a pgBackRest repository volume
 func PGBackRestRepoVolume(cluster *v1beta1.PostgresCluster,
 	repoName string) metav1.ObjectMeta {
@@ -506,6 +638,42 @@ func PGBackRestSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	}
 }
 
+// SharedRepoHost returns the ObjectMeta for the StatefulSet and headless Service
+// of a SharedRepoHost.
+func SharedRepoHost(host *v1beta1.SharedRepoHost) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: host.Namespace,
+		Name:      host.Name + "-shared-repo",
+	}
+}
+
+// SharedRepoHostConfig returns the ObjectMeta for the pgBackRest ConfigMap of
+// a SharedRepoHost.
+func SharedRepoHostConfig(host *v1beta1.SharedRepoHost) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: host.Namespace,
+		Name:      host.Name + "-shared-repo-config",
+	}
+}
+
+// SharedRepoHostSecret returns the ObjectMeta for the pgBackRest Secret of a
+// SharedRepoHost.
+func SharedRepoHostSecret(host *v1beta1.SharedRepoHost) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: host.Namespace,
+		Name:      host.Name + "-shared-repo-tls",
+	}
+}
+
+// SharedRepoHostVolume returns the ObjectMeta for the repository volume of a
+// SharedRepoHost.
+func SharedRepoHostVolume(host *v1beta1.SharedRepoHost) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: host.Namespace,
+		Name:      host.Name + "-shared-repo",
+	}
+}
+
 // DeprecatedPostgresUserSecret returns the ObjectMeta necessary to lookup the
 // old Secret containing the default Postgres user and connection information.
 // Use PostgresUserSecret instead.
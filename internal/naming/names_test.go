@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -53,6 +54,7 @@ func TestContainerNamesUniqueAndValid(t *testing.T) {
 		ContainerPGBouncerConfig,
 		ContainerPostgresStartup,
 		ContainerPGMonitorExporter,
+		ContainerRepoSync,
 	} {
 		assert.Assert(t, !names.Has(name), "%q defined already", name)
 		assert.Assert(t, nil == validation.IsDNS1123Label(name))
@@ -92,6 +94,7 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 
 	t.Run("ConfigMaps", func(t *testing.T) {
 		testUniqueAndValid(t, []test{
+			{"ClusterCABundle", ClusterCABundle(cluster)},
 			{"ClusterConfigMap", ClusterConfigMap(cluster)},
 			{"ClusterPGAdmin", ClusterPGAdmin(cluster)},
 			{"ClusterPGBouncer", ClusterPGBouncer(cluster)},
@@ -109,6 +112,7 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 			{"PGBackRestCronJon", PGBackRestCronJob(cluster, "incr", "repo2")},
 			{"PGBackRestCronJon", PGBackRestCronJob(cluster, "diff", "repo3")},
 			{"PGBackRestCronJon", PGBackRestCronJob(cluster, "full", "repo4")},
+			{"LogicalBackup", LogicalBackup(cluster)},
 		})
 	})
 
@@ -122,6 +126,9 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 		testUniqueAndValid(t, []test{
 			{"PGBackRestBackupJob", PGBackRestBackupJob(cluster)},
 			{"PGBackRestRestoreJob", PGBackRestRestoreJob(cluster)},
+			{"PGBackRestRestoreDryRunJob", PGBackRestRestoreDryRunJob(cluster)},
+			{"LogicalRestoreJob", LogicalRestoreJob(cluster)},
+			{"BenchmarkJob", BenchmarkJob(cluster)},
 		})
 	})
 
@@ -154,6 +161,7 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 			{"ReplicationClientCertSecret", ReplicationClientCertSecret(cluster)},
 			{"PGBackRestSSHSecret", PGBackRestSSHSecret(cluster)},
 			{"MonitoringUserSecret", MonitoringUserSecret(cluster)},
+			{"LogicalBackup", LogicalBackup(cluster)},
 		})
 
 		// NOTE: This does not fail when a conflict is introduced. When adding a
@@ -190,6 +198,7 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 			{"ClusterPodService", ClusterPodService(cluster)},
 			{"ClusterPrimaryService", ClusterPrimaryService(cluster)},
 			{"ClusterReplicaService", ClusterReplicaService(cluster)},
+			{"PGBackRestRepoHostService", PGBackRestRepoHostService(cluster)},
 			// Patroni can use Endpoints which relate directly to a Service.
 			{"PatroniDistributedConfiguration", PatroniDistributedConfiguration(cluster)},
 			{"PatroniLeaderEndpoints", PatroniLeaderEndpoints(cluster)},
@@ -243,6 +252,7 @@ func TestInstanceNamesUniqueAndValid(t *testing.T) {
 		for _, tt := range []test{
 			{"InstancePostgresDataVolume", InstancePostgresDataVolume(instance)},
 			{"InstancePostgresWALVolume", InstancePostgresWALVolume(instance)},
+			{"InstancePGBackRestSpoolVolume", InstancePGBackRestSpoolVolume(instance)},
 		} {
 			t.Run(tt.name, func(t *testing.T) {
 				assert.Equal(t, tt.value.Namespace, instance.Namespace)
@@ -270,6 +280,40 @@ func TestInstanceNamesUniqueAndValid(t *testing.T) {
 	})
 }
 
+func TestSharedRepoHostNamesUniqueAndValid(t *testing.T) {
+	host := &v1beta1.SharedRepoHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns", Name: "some-such",
+		},
+	}
+
+	// A cluster may have the same name as the host.
+	cluster := &v1beta1.PostgresCluster{ObjectMeta: host.ObjectMeta}
+	clusterNames := sets.NewString(
+		ClusterPodService(cluster).Name,
+		PGBackRestConfig(cluster).Name,
+		PGBackRestRepoVolume(cluster, "repo1").Name,
+		PGBackRestSecret(cluster).Name,
+	)
+
+	for _, tt := range []struct {
+		name  string
+		value metav1.ObjectMeta
+	}{
+		{"SharedRepoHost", SharedRepoHost(host)},
+		{"SharedRepoHostConfig", SharedRepoHostConfig(host)},
+		{"SharedRepoHostSecret", SharedRepoHostSecret(host)},
+		{"SharedRepoHostVolume", SharedRepoHostVolume(host)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.value.Namespace, host.Namespace)
+			assert.Assert(t, tt.value.Name != host.Name, "may collide")
+			assert.Assert(t, !clusterNames.Has(tt.value.Name), "%q collides with a cluster", tt.value.Name)
+			assert.Assert(t, nil == validation.IsDNS1123Label(tt.value.Name))
+		})
+	}
+}
+
 func TestGenerateInstance(t *testing.T) {
 	cluster := &v1beta1.PostgresCluster{
 		ObjectMeta: metav1.ObjectMeta{
@@ -305,6 +349,57 @@ func TestGenerateStartupInstance(t *testing.T) {
 
 }
 
+func TestClusterVolumeSnapshot(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1", Name: "pg0",
+		},
+	}
+
+	one := ClusterVolumeSnapshot(cluster, "2023-03-07T02:00:00Z")
+	assert.Equal(t, one.Namespace, cluster.Namespace)
+	assert.Assert(t, strings.HasPrefix(one.Name, "pg0-pgdata-"))
+	assert.Assert(t, nil == validation.IsDNS1123Subdomain(one.Name))
+
+	// The same identifier has the same name; another has a different one.
+	assert.DeepEqual(t, one, ClusterVolumeSnapshot(cluster, "2023-03-07T02:00:00Z"))
+	assert.Assert(t, one.Name != ClusterVolumeSnapshot(cluster, "2023-03-08T02:00:00Z").Name)
+}
+
+func TestRemoteInstanceSet(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1", Name: "pg0",
+		},
+	}
+
+	set := &v1beta1.PostgresRemoteInstanceSetSpec{Namespace: "reporting"}
+	remote := RemoteInstanceSet(cluster, set)
+	assert.Equal(t, remote.Namespace, "reporting")
+	assert.Assert(t, cmp.Regexp(`^pg0-[0-9a-f]{4}$`, remote.Name))
+
+	set.Name = "nightly"
+	remote = RemoteInstanceSet(cluster, set)
+	assert.Equal(t, remote.Namespace, "reporting")
+	assert.Assert(t, cmp.Regexp(`^pg0-nightly-[0-9a-f]{4}$`, remote.Name))
+
+	// Clusters of the same name in other namespaces have other remote sets.
+	other := cluster.DeepCopy()
+	other.Namespace = "ns2"
+	assert.Assert(t, RemoteInstanceSet(other, set).Name != remote.Name)
+	assert.Equal(t, RemoteInstanceSet(cluster, set).Name, remote.Name)
+
+	standby := &v1beta1.PostgresCluster{ObjectMeta: remote}
+	for _, meta := range []metav1.ObjectMeta{
+		RemoteInstanceSetTLSSecret(standby),
+		RemoteInstanceSetReplicationSecret(standby),
+	} {
+		assert.Equal(t, meta.Namespace, "reporting")
+		assert.Assert(t, strings.HasPrefix(meta.Name, "pg0-nightly-"))
+		assert.Assert(t, nil == validation.IsDNS1123Subdomain(meta.Name))
+	}
+}
+
 func TestOperatorConfigurationSecret(t *testing.T) {
 	t.Setenv("PGO_NAMESPACE", "cheese")
 
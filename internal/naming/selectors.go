@@ -145,3 +145,24 @@ func ClusterPrimary(cluster string) metav1.LabelSelector {
 	s.MatchLabels[LabelRole] = RolePatroniLeader
 	return s
 }
+
+// ClusterVolumeSnapshots selects VolumeSnapshots of PostgreSQL data in cluster.
+func ClusterVolumeSnapshots(cluster string) metav1.LabelSelector {
+	return metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			LabelCluster: cluster,
+			LabelData:    DataPostgres,
+		},
+	}
+}
+
+// RemoteInstanceSets selects the PostgresClusters that are remote instance
+// sets of cluster, in any namespace.
+func RemoteInstanceSets(cluster *v1beta1.PostgresCluster) metav1.LabelSelector {
+	return metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			LabelRemoteSourceCluster:   cluster.Name,
+			LabelRemoteSourceNamespace: cluster.Namespace,
+		},
+	}
+}
@@ -156,3 +156,28 @@ func TestClusterPrimary(t *testing.T) {
 		"postgres-operator.crunchydata.com/role=master",
 	}, ","))
 }
+
+func TestClusterVolumeSnapshots(t *testing.T) {
+	s, err := AsSelector(ClusterVolumeSnapshots("something"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, s.String(), strings.Join([]string{
+		"postgres-operator.crunchydata.com/cluster=something",
+		"postgres-operator.crunchydata.com/data=postgres",
+	}, ","))
+
+	_, err = AsSelector(ClusterVolumeSnapshots("--whoa/yikes"))
+	assert.ErrorContains(t, err, "Invalid")
+}
+
+func TestRemoteInstanceSets(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace = "ns1"
+	cluster.Name = "something"
+
+	s, err := AsSelector(RemoteInstanceSets(cluster))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, s.String(), strings.Join([]string{
+		"postgres-operator.crunchydata.com/remote-source-cluster=something",
+		"postgres-operator.crunchydata.com/remote-source-namespace=ns1",
+	}, ","))
+}
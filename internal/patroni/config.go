@@ -524,11 +524,28 @@ func instanceYAML(
 	// The "basebackup" replica method is configured differently from others.
 	// Patroni prepends "--" before it calls `pg_basebackup`.
 	// - https://github.com/zalando/patroni/blob/v2.0.2/patroni/postgresql/bootstrap.py#L45
-	postgresql["basebackup"] = []string{
+	basebackup := []string{
 		// NOTE(cbandy): The "--waldir" option was introduced in PostgreSQL v10.
 		"waldir=" + postgres.WALDirectory(cluster, instance),
 	}
-	methods := []string{"basebackup"}
+	methods := []string{basebackupCreateReplicaMethod}
+
+	// Replicas stream a copy of the primary until a pgBackRest backup is
+	// available to restore from. This is always the case when a cluster is
+	// new, so begin the copy with an immediate checkpoint rather than waiting
+	// up to "checkpoint_timeout" for a spread one. Once a backup exists, the
+	// stream should not burden the primary with a burst of I/O.
+	// - https://www.postgresql.org/docs/current/app-pgbasebackup.html
+	var backupExists bool
+	if cluster.Status.PGBackRest != nil {
+		for _, repo := range cluster.Status.PGBackRest.Repos {
+			backupExists = backupExists || repo.ReplicaCreateBackupComplete
+		}
+	}
+	if !backupExists {
+		basebackup = append([]string{"checkpoint=fast"}, basebackup...)
+	}
+	postgresql["basebackup"] = basebackup
 
 	// Prefer a pgBackRest method when it is available, and fallback to other
 	// methods when it fails.
@@ -851,6 +851,7 @@ bootstrap:
 kubernetes: {}
 postgresql:
   basebackup:
+  - checkpoint=fast
   - waldir=/pgdata/pg12_wal
   create_replica_methods:
   - basebackup
@@ -874,6 +875,7 @@ bootstrap:
 kubernetes: {}
 postgresql:
   basebackup:
+  - checkpoint=fast
   - waldir=/pgdata/pg12_wal
   create_replica_methods:
   - pgbackrest
@@ -889,6 +891,23 @@ postgresql:
 restapi: {}
 tags: {}
 	`, "\t\n")+"\n")
+
+	t.Run("BackupExists", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			Repos: []v1beta1.RepoStatus{
+				{Name: "repo1"},
+				{Name: "repo2", ReplicaCreateBackupComplete: true},
+			},
+		}
+
+		data, err := instanceYAML(cluster, instance, nil)
+		assert.NilError(t, err)
+		assert.Assert(t, strings.Contains(data, `
+  basebackup:
+  - waldir=/pgdata/pg12_wal
+`), "expected no fast checkpoint, got:\n%s", data)
+	})
 }
 
 func TestPGBackRestCreateReplicaCommand(t *testing.T) {
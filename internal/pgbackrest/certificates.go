@@ -16,12 +16,24 @@
 package pgbackrest
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"time"
 
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pki"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 const (
@@ -48,6 +60,36 @@ const (
 	certRepoSecretKey           = "pgbackrest-repo-host.crt" // #nosec G101 this is a name, not a credential
 )
 
+const (
+	// ConditionAuthorityRotation is the type used in a condition to indicate
+	// whether or not the pgBackRest certificates of a cluster are moving from
+	// one root certificate authority to another. Its reason is the step of
+	// that rotation, and it determines what [Secret] does with the authorities
+	// being replaced.
+	ConditionAuthorityRotation = "PGBackRestCertificateAuthorityRotation"
+
+	// AuthorityRotationTrusting means every pgBackRest server is being given
+	// the new authority while the existing certificates stay in use.
+	AuthorityRotationTrusting = "TrustingAuthority"
+
+	// AuthorityRotationReissuing means every pgBackRest server trusts the new
+	// authority, and certificates signed by it are replacing the existing ones.
+	AuthorityRotationReissuing = "ReissuingCertificates"
+
+	// AuthorityRotationRetiring means the repository host presents a
+	// certificate signed by the new authority, and the authorities it replaces
+	// are removed.
+	AuthorityRotationRetiring = "RetiringAuthority"
+
+	// AuthorityRotationComplete means only one authority is trusted.
+	AuthorityRotationComplete = "RotationComplete"
+
+	// authorityProbeWait is how long [TrustsAuthority] waits for a server to
+	// reject a client certificate after the TLS handshake. TLS 1.3 servers
+	// report that rejection after the client considers the handshake done.
+	authorityProbeWait = time.Second
+)
+
 // certFile concatenates the results of multiple PEM-encoding marshalers.
 func certFile(texts ...encoding.TextMarshaler) ([]byte, error) {
 	var out []byte
@@ -63,6 +105,218 @@ func certFile(texts ...encoding.TextMarshaler) ([]byte, error) {
 	return out, nil
 }
 
+// certificateDuration returns how long the certificates that PGO generates for
+// the pgBackRest processes of cluster are valid. Zero means the default.
+func certificateDuration(cluster *v1beta1.PostgresCluster) time.Duration {
+	if host := cluster.Spec.Backups.PGBackRest.RepoHost; host != nil &&
+		host.CertificateDurationDays != nil {
+		return time.Duration(*host.CertificateDurationDays) * 24 * time.Hour
+	}
+	return 0
+}
+
+// authorityFile returns a PEM-encoded bundle of inRoot followed by previous.
+func authorityFile(inRoot *pki.RootCertificateAuthority, previous []pki.Certificate) ([]byte, error) {
+	texts := []encoding.TextMarshaler{inRoot.Certificate}
+	for i := range previous {
+		texts = append(texts, previous[i])
+	}
+	return certFile(texts...)
+}
+
+// authorityRotationStep returns the reason of the [ConditionAuthorityRotation]
+// condition of cluster while a rotation is in progress, or an empty string.
+func authorityRotationStep(cluster *v1beta1.PostgresCluster) string {
+	for _, condition := range cluster.Status.Conditions {
+		if condition.Type == ConditionAuthorityRotation &&
+			condition.Status == metav1.ConditionTrue {
+			return condition.Reason
+		}
+	}
+	return ""
+}
+
+// issuedByAny checks if leaf is valid, has commonName and dnsNames in its
+// subject, and is signed by one of authorities.
+func issuedByAny(authorities []pki.Certificate,
+	leaf *pki.LeafCertificate, commonName string, dnsNames []string,
+) bool {
+	for i := range authorities {
+		root := &pki.RootCertificateAuthority{Certificate: authorities[i]}
+		if root.LeafIsValid(leaf, commonName, dnsNames) {
+			return true
+		}
+	}
+	return false
+}
+
+// CertificatesIssuedBy checks if the client certificate and the repository
+// host certificate in inSecret are valid and signed by inRoot.
+func CertificatesIssuedBy(inSecret *corev1.Secret, inRoot *pki.RootCertificateAuthority) bool {
+	ok := true
+	for _, keys := range [][2]string{
+		{certClientSecretKey, certClientPrivateKeySecretKey},
+		{certRepoSecretKey, certRepoPrivateKeySecretKey},
+	} {
+		leaf := &pki.LeafCertificate{}
+		_ = leaf.Certificate.UnmarshalText(inSecret.Data[keys[0]])
+		_ = leaf.PrivateKey.UnmarshalText(inSecret.Data[keys[1]])
+
+		ok = ok && inRoot.LeafIsValid(leaf,
+			leaf.Certificate.CommonName(), leaf.Certificate.DNSNames())
+	}
+	return ok
+}
+
+// Certificates returns the client certificate and the repository host
+// certificate in inSecret by the name of the pgBackRest process that presents
+// them. Certificates that are missing or malformed are omitted.
+func Certificates(inSecret *corev1.Secret) map[string]pki.Certificate {
+	out := make(map[string]pki.Certificate)
+	for name, key := range map[string]string{
+		"pgbackrest-client":    certClientSecretKey,
+		"pgbackrest-repo-host": certRepoSecretKey,
+	} {
+		var certificate pki.Certificate
+		if certificate.UnmarshalText(inSecret.Data[key]) == nil {
+			out[name] = certificate
+		}
+	}
+	return out
+}
+
+// PreviousAuthorities returns the certificate authorities in the bundle of
+// inSecret other than inRoot. They are authorities that inRoot is replacing.
+func PreviousAuthorities(inSecret *corev1.Secret, inRoot *pki.RootCertificateAuthority) []pki.Certificate {
+	var previous []pki.Certificate
+
+	for rest := inSecret.Data[certAuthoritySecretKey]; len(rest) > 0; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+
+		var certificate pki.Certificate
+		if certificate.UnmarshalText(pem.EncodeToMemory(block)) == nil &&
+			!certificate.Equal(inRoot.Certificate) {
+			previous = append(previous, certificate)
+		}
+	}
+
+	return previous
+}
+
+// RepoHostAddress returns the host and port of the TLS server of inRepoHost.
+// When inCluster has more than one repository host pod, it is the address of
+// the active one.
+func RepoHostAddress(ctx context.Context,
+	inCluster *v1beta1.PostgresCluster, inRepoHost *appsv1.StatefulSet,
+) string {
+	fqdn := naming.RepoHostPodDNSNames(ctx, inRepoHost)[0]
+	if RepoHostReplicas(inCluster) > 1 {
+		fqdn = naming.ServiceDNSNames(ctx, &corev1.Service{
+			ObjectMeta: naming.PGBackRestRepoHostService(inCluster),
+		})[0]
+	}
+	return net.JoinHostPort(fqdn, fmt.Sprint(IANAPortNumber))
+}
+
+// ServesCertificate connects to the TLS server of inRepoHost using the client
+// certificate in inSecret. It returns true when the server presents the repo
+// host certificate in inSecret; false means it has not yet reloaded that file.
+// When inCluster has more than one repository host pod, it connects to the
+// active one.
+func ServesCertificate(ctx context.Context, inCluster *v1beta1.PostgresCluster,
+	inRepoHost *appsv1.StatefulSet, inSecret *corev1.Secret,
+) (bool, error) {
+	return servesCertificate(ctx, RepoHostAddress(ctx, inCluster, inRepoHost), inSecret)
+}
+
+// TrustsAuthority connects to the pgBackRest TLS server at address using a new
+// client certificate for inCluster signed by inRoot. It returns true when the
+// server accepts that certificate; false means it does not yet trust inRoot.
+func TrustsAuthority(ctx context.Context, inCluster metav1.Object,
+	inRoot *pki.RootCertificateAuthority, address string,
+) (bool, error) {
+	commonName := clientCommonName(inCluster)
+	leaf, err := inRoot.GenerateLeafCertificate(commonName, []string{commonName})
+
+	var certificate, privateKey []byte
+	if err == nil {
+		certificate, err = certFile(leaf.Certificate)
+	}
+	if err == nil {
+		privateKey, err = certFile(leaf.PrivateKey)
+	}
+
+	var client tls.Certificate
+	if err == nil {
+		client, err = tls.X509KeyPair(certificate, privateKey)
+	}
+
+	var conn net.Conn
+	if err == nil {
+		conn, err = new(net.Dialer).DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	defer conn.Close()
+
+	// The server certificate may be signed by either authority, so it is not
+	// verified. Only the response of the server to this client matters.
+	session := tls.Client(conn, &tls.Config{
+		Certificates:       []tls.Certificate{client},
+		InsecureSkipVerify: true, // #nosec G402 -- only the client is being checked
+		MinVersion:         tls.VersionTLS12,
+	})
+	if session.HandshakeContext(ctx) != nil {
+		return false, nil
+	}
+
+	// A pgBackRest server sends a greeting to the clients it accepts. One that
+	// rejects the certificate closes the connection, sometimes with an alert.
+	_ = session.SetReadDeadline(time.Now().Add(authorityProbeWait))
+	_, err = session.Read(make([]byte, 1))
+
+	// One that is slow to send its greeting has not rejected it either.
+	var timeout net.Error
+	return err == nil || (errors.As(err, &timeout) && timeout.Timeout()), nil
+}
+
+// servesCertificate connects to the TLS server at address using the client
+// certificate in inSecret and compares the certificate it presents to the
+// repo host certificate in inSecret.
+func servesCertificate(ctx context.Context, address string, inSecret *corev1.Secret) (bool, error) {
+	expected, _ := pem.Decode(inSecret.Data[certRepoSecretKey])
+	if expected == nil {
+		return false, errors.New("missing repo host certificate")
+	}
+
+	client, err := tls.X509KeyPair(
+		inSecret.Data[certClientSecretKey], inSecret.Data[certClientPrivateKeySecretKey])
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	// A stale certificate may no longer be trusted, so compare the presented
+	// certificate to the expected one rather than verifying it.
+	dialer := tls.Dialer{Config: &tls.Config{
+		Certificates:       []tls.Certificate{client},
+		InsecureSkipVerify: true, // #nosec G402 -- the certificate is compared below
+		MinVersion:         tls.VersionTLS12,
+	}}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	defer conn.Close()
+
+	presented := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	return len(presented) > 0 && bytes.Equal(presented[0].Raw, expected.Bytes), nil
+}
+
 // clientCertificates returns projections of CAs, keys, and certificates to
 // include in a configuration volume from the pgBackRest Secret.
 func clientCertificates() []corev1.KeyToPath {
@@ -99,6 +353,57 @@ func clientCommonName(cluster metav1.Object) string {
 	return "pgbackrest@" + string(cluster.GetUID())
 }
 
+// sharedClientCertificates returns projections of the CA and of the client key
+// and certificate of each of clusters to include in a configuration volume from
+// the Secret of a SharedRepoHost.
+func sharedClientCertificates(clusters []*v1beta1.PostgresCluster) []corev1.KeyToPath {
+	items := []corev1.KeyToPath{{
+		Key:  certAuthoritySecretKey,
+		Path: certAuthorityProjectionPath,
+	}}
+
+	for _, cluster := range clusters {
+		items = append(items, corev1.KeyToPath{
+			Key:  sharedClientSecretKey(cluster),
+			Path: sharedClientProjectionPath(cluster),
+		}, corev1.KeyToPath{
+			Key:  sharedClientPrivateKeySecretKey(cluster),
+			Path: sharedClientPrivateKeyProjectionPath(cluster),
+
+			// pgBackRest requires that certificate keys not be readable by any
+			// other user.
+			// - https://github.com/pgbackrest/pgbackrest/blob/release/2.38/src/common/io/tls/common.c#L128
+			Mode: initialize.Int32(0o600),
+		})
+	}
+
+	return items
+}
+
+// sharedClientPrivateKeyProjectionPath returns where the client key of cluster
+// is projected in the configuration volume of a SharedRepoHost.
+func sharedClientPrivateKeyProjectionPath(cluster *v1beta1.PostgresCluster) string {
+	return "~postgres-operator/client-" + cluster.Name + ".key"
+}
+
+// sharedClientProjectionPath returns where the client certificate of cluster
+// is projected in the configuration volume of a SharedRepoHost.
+func sharedClientProjectionPath(cluster *v1beta1.PostgresCluster) string {
+	return "~postgres-operator/client-" + cluster.Name + ".crt"
+}
+
+// sharedClientPrivateKeySecretKey returns the key of the client key of cluster
+// in the Secret of a SharedRepoHost.
+func sharedClientPrivateKeySecretKey(cluster *v1beta1.PostgresCluster) string {
+	return "pgbackrest-client-" + cluster.Name + ".key"
+}
+
+// sharedClientSecretKey returns the key of the client certificate of cluster
+// in the Secret of a SharedRepoHost.
+func sharedClientSecretKey(cluster *v1beta1.PostgresCluster) string {
+	return "pgbackrest-client-" + cluster.Name + ".crt"
+}
+
 // instanceServerCertificates returns projections of keys and certificates to
 // include in a server volume from an instance Secret.
 func instanceServerCertificates() []corev1.KeyToPath {
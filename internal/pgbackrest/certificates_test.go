@@ -16,15 +16,23 @@
 package pgbackrest
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"net"
 	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/uuid"
 
+	"github.com/crunchydata/postgres-operator/internal/pki"
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 type funcMarshaler func() ([]byte, error)
@@ -60,3 +68,154 @@ func TestClientCommonName(t *testing.T) {
 	assert.Assert(t, strings.HasPrefix(cn, "pgbackrest@"),
 		`expected %q to begin with "pgbackrest@" for %q`, cn, cluster)
 }
+
+func TestServesCertificate(t *testing.T) {
+	ctx := context.Background()
+	root, err := pki.NewRootCertificateAuthority()
+	assert.NilError(t, err)
+
+	host := new(appsv1.StatefulSet)
+	host.Namespace, host.Name, host.Spec.ServiceName = "ns1", "some-repo", "some-domain"
+
+	secret := new(corev1.Secret)
+	assert.NilError(t, Secret(ctx,
+		new(v1beta1.PostgresCluster), host, root, new(corev1.Secret), secret))
+
+	// Start a TLS server that requires client certificates like pgBackRest.
+	served, err := tls.X509KeyPair(
+		secret.Data[certRepoSecretKey], secret.Data[certRepoPrivateKeySecretKey])
+	assert.NilError(t, err)
+
+	authority := x509.NewCertPool()
+	assert.Assert(t, authority.AppendCertsFromPEM(secret.Data[certAuthoritySecretKey]))
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{served},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    authority,
+		MinVersion:   tls.VersionTLS12,
+	})
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+
+	address := listener.Addr().String()
+
+	t.Run("Current", func(t *testing.T) {
+		ok, err := servesCertificate(ctx, address, secret)
+		assert.NilError(t, err)
+		assert.Assert(t, ok)
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		// The Secret has a newer certificate than the one being served.
+		newer := secret.DeepCopy()
+		assert.NilError(t, Secret(ctx,
+			new(v1beta1.PostgresCluster), &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "other"},
+			}, root, secret, newer))
+
+		ok, err := servesCertificate(ctx, address, newer)
+		assert.NilError(t, err)
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		closed, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NilError(t, err)
+		assert.NilError(t, closed.Close())
+
+		_, err = servesCertificate(ctx, closed.Addr().String(), secret)
+		assert.Assert(t, err != nil)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := servesCertificate(ctx, address, new(corev1.Secret))
+		assert.ErrorContains(t, err, "missing")
+	})
+}
+
+func TestTrustsAuthority(t *testing.T) {
+	ctx := context.Background()
+	cluster := &metav1.ObjectMeta{UID: uuid.NewUUID()}
+
+	previous, err := pki.NewRootCertificateAuthority()
+	assert.NilError(t, err)
+	root, err := pki.NewRootCertificateAuthority()
+	assert.NilError(t, err)
+
+	served, err := previous.GenerateLeafCertificate("server", []string{"server"})
+	assert.NilError(t, err)
+	servedCertificate, err := certFile(served.Certificate)
+	assert.NilError(t, err)
+	servedPrivateKey, err := certFile(served.PrivateKey)
+	assert.NilError(t, err)
+	pair, err := tls.X509KeyPair(servedCertificate, servedPrivateKey)
+	assert.NilError(t, err)
+
+	// listen starts a TLS server that trusts authorities and sends a greeting
+	// to the clients it accepts, like pgBackRest.
+	listen := func(t *testing.T, authorities ...*pki.RootCertificateAuthority) string {
+		pool := x509.NewCertPool()
+		for _, authority := range authorities {
+			bundle, err := certFile(authority.Certificate)
+			assert.NilError(t, err)
+			assert.Assert(t, pool.AppendCertsFromPEM(bundle))
+		}
+
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+			Certificates: []tls.Certificate{pair},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    pool,
+			MinVersion:   tls.VersionTLS12,
+		})
+		assert.NilError(t, err)
+		t.Cleanup(func() { _ = listener.Close() })
+
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				if conn.(*tls.Conn).Handshake() == nil {
+					_, _ = conn.Write([]byte(`{"name":"pgBackRest"}`))
+				}
+				_ = conn.Close()
+			}
+		}()
+
+		return listener.Addr().String()
+	}
+
+	t.Run("Trusted", func(t *testing.T) {
+		ok, err := TrustsAuthority(ctx, cluster, root, listen(t, root, previous))
+		assert.NilError(t, err)
+		assert.Assert(t, ok)
+	})
+
+	t.Run("Untrusted", func(t *testing.T) {
+		ok, err := TrustsAuthority(ctx, cluster, root, listen(t, previous))
+		assert.NilError(t, err)
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		closed, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NilError(t, err)
+		assert.NilError(t, closed.Close())
+
+		_, err = TrustsAuthority(ctx, cluster, root, closed.Addr().String())
+		assert.Assert(t, err != nil)
+	})
+}
@@ -18,10 +18,15 @@ package pgbackrest
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/crunchydata/postgres-operator/internal/config"
 	"github.com/crunchydata/postgres-operator/internal/initialize"
@@ -37,6 +42,10 @@ const (
 	// DefaultStanzaName is the name of the default pgBackRest stanza
 	DefaultStanzaName = "db"
 
+	// sharedRepoPath is where a SharedRepoHost mounts its volume and stores
+	// the stanzas of every cluster that uses it.
+	sharedRepoPath = repoMountPath + "/shared"
+
 	// CMInstanceKey is the name of the pgBackRest configuration file for a PostgreSQL instance
 	CMInstanceKey = "pgbackrest_instance.conf"
 
@@ -58,10 +67,28 @@ const (
 
 	serverConfigMapKey = "pgbackrest-server.conf"
 
+	// spoolMountPath is where to mount the spool volume of a PostgreSQL instance.
+	spoolMountPath = "/pgspool"
+
 	// serverMountPath is the directory containing the TLS server certificate
 	// and key. This is outside of configDirectory so the hash calculated by
 	// backup jobs does not change when the primary changes.
 	serverMountPath = "/etc/pgbackrest/server"
+
+	// webIdentityMountPath is the directory containing the service account
+	// tokens exchanged for temporary cloud credentials. This is outside of
+	// configDirectory so the hash calculated by backup jobs does not change
+	// when the tokens are refreshed.
+	webIdentityMountPath = "/etc/pgbackrest/web-identity"
+
+	// webIdentityAWSAudience is the audience AWS STS expects in a projected token.
+	webIdentityAWSAudience  = "sts.amazonaws.com"
+	webIdentityAWSTokenPath = "aws-token"
+
+	// webIdentityAzureAudience is the audience Microsoft Entra ID expects in a
+	// projected token.
+	webIdentityAzureAudience  = "api://AzureADTokenExchange"
+	webIdentityAzureTokenPath = "azure-token"
 )
 
 const (
@@ -70,12 +97,22 @@ const (
 		"# Your changes will not be saved.\n"
 )
 
+// StanzaName returns the name of the pgBackRest stanza of cluster. A SharedRepoHost
+// stores the stanzas of many clusters, so the stanza of a cluster with any repository
+// on a SharedRepoHost is the name of that cluster. Otherwise, it is [DefaultStanzaName].
+func StanzaName(cluster *v1beta1.PostgresCluster) string {
+	if SharedRepoHostEnabled(cluster) {
+		return cluster.Name
+	}
+	return DefaultStanzaName
+}
+
 // CreatePGBackRestConfigMapIntent creates a configmap struct with pgBackRest pgbackrest.conf settings in the data field.
 // The keys within the data field correspond to the use of that configuration.
 // pgbackrest_job.conf is used by certain jobs, such as stanza create and backup
 // pgbackrest_primary.conf is used by the primary database pod
 // pgbackrest_repo.conf is used by the pgBackRest repository pod
-func CreatePGBackRestConfigMapIntent(postgresCluster *v1beta1.PostgresCluster,
+func CreatePGBackRestConfigMapIntent(ctx context.Context, postgresCluster *v1beta1.PostgresCluster,
 	repoHostName, configHash, serviceName, serviceNamespace string,
 	instanceNames []string) *corev1.ConfigMap {
 
@@ -104,10 +141,22 @@ func CreatePGBackRestConfigMapIntent(postgresCluster *v1beta1.PostgresCluster,
 	pgdataDir := postgres.DataDirectory(postgresCluster)
 	// Port will always be populated, since the API will set a default of 5432 if not provided
 	pgPort := *postgresCluster.Spec.Port
+
+	// A repository host with more than one pod is reached through the Service
+	// of its active pod.
+	repoHostReplicas := RepoHostReplicas(postgresCluster)
+	var repoHostService string
+	if repoHostReplicas > 1 {
+		repoHostService = naming.PGBackRestRepoHostService(postgresCluster).Name
+	}
+
 	cm.Data[CMInstanceKey] = iniGeneratedWarning +
-		populatePGInstanceConfigurationMap(
-			serviceName, serviceNamespace, repoHostName,
+		populatePGInstanceConfigurationMap(ctx,
+			serviceName, serviceNamespace, repoHostName, repoHostService, StanzaName(postgresCluster),
 			pgdataDir, pgPort, postgresCluster.Spec.Backups.PGBackRest.Repos,
+			postgresCluster.Spec.Backups.PGBackRest.ArchivePush,
+			postgresCluster.Spec.Backups.PGBackRest.AsyncArchive,
+			postgresCluster.Spec.Backups.PGBackRest.IO,
 			postgresCluster.Spec.Backups.PGBackRest.Global,
 		).String()
 
@@ -117,15 +166,20 @@ func CreatePGBackRestConfigMapIntent(postgresCluster *v1beta1.PostgresCluster,
 	// Kubernetes propagates their contents to those pods.
 	cm.Data[serverConfigMapKey] = ""
 
-	if addDedicatedHost && repoHostName != "" {
+	// PostgreSQL instances run a TLS server whenever a repository host, dedicated
+	// or shared, needs to reach them.
+	if (addDedicatedHost && repoHostName != "") || SharedRepoHostEnabled(postgresCluster) {
 		cm.Data[serverConfigMapKey] = iniGeneratedWarning +
 			serverConfig(postgresCluster).String()
+	}
 
+	if addDedicatedHost && repoHostName != "" {
 		cm.Data[CMRepoKey] = iniGeneratedWarning +
-			populateRepoHostConfigurationMap(
-				serviceName, serviceNamespace,
-				pgdataDir, pgPort, instanceNames,
+			populateRepoHostConfigurationMap(ctx,
+				serviceName, serviceNamespace, StanzaName(postgresCluster),
+				pgdataDir, pgPort, instanceNames, repoHostReplicas,
 				postgresCluster.Spec.Backups.PGBackRest.Repos,
+				postgresCluster.Spec.Backups.PGBackRest.IO,
 				postgresCluster.Spec.Backups.PGBackRest.Global,
 			).String()
 	}
@@ -135,6 +189,39 @@ func CreatePGBackRestConfigMapIntent(postgresCluster *v1beta1.PostgresCluster,
 	return cm
 }
 
+// CreateSharedRepoHostConfigMapIntent creates a configmap struct with the pgBackRest
+// configuration of host in the data field. Each of clusters has a stanza on host and
+// pgHosts contains the names of its instances, keyed by cluster name.
+func CreateSharedRepoHostConfigMapIntent(ctx context.Context, host *v1beta1.SharedRepoHost,
+	clusters []*v1beta1.PostgresCluster, pgHosts map[string][]string) *corev1.ConfigMap {
+
+	meta := naming.SharedRepoHostConfig(host)
+	meta.Annotations = host.Spec.Metadata.GetAnnotationsOrNil()
+	meta.Labels = naming.Merge(
+		host.Spec.Metadata.GetLabelsOrNil(),
+		naming.SharedRepoHostLabels(host.Name),
+	)
+
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: "v1",
+		},
+		ObjectMeta: meta,
+	}
+
+	// create an empty map for the config data
+	initialize.StringMap(&cm.Data)
+
+	cm.Data[CMRepoKey] = iniGeneratedWarning +
+		populateSharedRepoHostConfigurationMap(ctx, host.Name, clusters, pgHosts).String()
+
+	cm.Data[serverConfigMapKey] = iniGeneratedWarning +
+		sharedServerConfig(host, clusters).String()
+
+	return cm
+}
+
 // MakePGBackrestLogDir creates the pgBackRest default log path directory used when a
 // dedicated repo host is configured.
 func MakePGBackrestLogDir(template *corev1.PodTemplateSpec,
@@ -169,6 +256,8 @@ func MakePGBackrestLogDir(template *corev1.PodTemplateSpec,
 // RestoreCommand returns the command for performing a pgBackRest restore.  In addition to calling
 // the pgBackRest restore command with any pgBackRest options provided, the script also does the
 // following:
+//   - Empties the data, WAL, and tablespace directories when delta is false. pgBackRest
+//     restores into existing files only with its "--delta" option.
 //   - Removes the patroni.dynamic.json file if present.  This ensures the configuration from the
 //     cluster being restored from is not utilized when bootstrapping a new cluster, and the
 //     configuration for the new cluster is utilized instead.
@@ -177,7 +266,7 @@ func MakePGBackrestLogDir(template *corev1.PodTemplateSpec,
 //   - Renames the data directory as needed to bootstrap the cluster using the restored database.
 //     This ensures compatibility with the "existing" bootstrap method that is included in the
 //     Patroni config when bootstrapping a cluster using an existing data directory.
-func RestoreCommand(pgdata string, tablespaceVolumes []*corev1.PersistentVolumeClaim, args ...string) []string {
+func RestoreCommand(pgdata string, delta bool, tablespaceVolumes []*corev1.PersistentVolumeClaim, args ...string) []string {
 
 	// After pgBackRest restores files, PostgreSQL starts in recovery to finish
 	// replaying WAL files. "hot_standby" is "on" (by default) so we can detect
@@ -205,14 +294,28 @@ func RestoreCommand(pgdata string, tablespaceVolumes []*corev1.PersistentVolumeC
 	// The 'pg_ctl' timeout is set to a very large value (1 year) to ensure there
 	// are no timeouts when starting or stopping Postgres.
 
-	tablespaceCmd := ""
+	// Without "--delta", pgBackRest refuses to restore into directories that
+	// contain files. Remove the WAL directory that "pg_wal" links to, the data
+	// directory, and any tablespace directories so they are recreated empty.
+
+	cleanCmd, tablespaceCmd := "", ""
+	if !delta {
+		cleanCmd = `
+[ ! -L "${pgdata}/pg_wal" ] || rm -rf "$(readlink "${pgdata}/pg_wal")"
+rm -rf "${pgdata}"`
+	}
 	for _, tablespaceVolume := range tablespaceVolumes {
+		if !delta {
+			cleanCmd = cleanCmd + fmt.Sprintf(
+				"\nrm -rf '/tablespaces/%s/data'",
+				tablespaceVolume.Labels[naming.LabelData])
+		}
 		tablespaceCmd = tablespaceCmd + fmt.Sprintf(
 			"\ninstall --directory --mode=0700 '/tablespaces/%s/data'",
 			tablespaceVolume.Labels[naming.LabelData])
 	}
 
-	restoreScript := `declare -r pgdata="$1" opts="$2"
+	restoreScript := `declare -r pgdata="$1" opts="$2"` + cleanCmd + `
 install --directory --mode=0700 "${pgdata}"` + tablespaceCmd + `
 rm -f "${pgdata}/postmaster.pid"
 bash -xc "pgbackrest restore ${opts}"
@@ -258,27 +361,287 @@ mv "${pgdata}" "${pgdata}_bootstrap"`
 	return append([]string{"bash", "-ceu", "--", restoreScript, "-", pgdata}, args...)
 }
 
+// restoreTargetPatterns are the formats of recovery targets by recovery type.
+// Times are "YYYY-MM-DD HH:MM:SS" with optional fractional seconds and an
+// optional offset or time zone name, the format shown by pgBackRest. PostgreSQL
+// also accepts ISO 8601, which separates the date and time with "T".
+// - https://pgbackrest.org/command.html#command-restore/category-command/option-target
+var restoreTargetPatterns = map[string]*regexp.Regexp{
+	"lsn":  regexp.MustCompile(`^[0-9A-Fa-f]{1,8}/[0-9A-Fa-f]{1,8}$`),
+	"name": regexp.MustCompile(`.`),
+	"time": regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})[ T]\d{2}:\d{2}:\d{2}(\.\d+)?` +
+		`( ?(Z|[+-]\d{2}(:?\d{2})?|[A-Za-z][A-Za-z0-9/_+-]*))?$`),
+	"xid": regexp.MustCompile(`^\d+$`),
+}
+
+// restoreSetPattern is the format of a backup label.
+var restoreSetPattern = regexp.MustCompile(`^\d{8}-\d{6}F(_\d{8}-\d{6}[DI])?$`)
+
+// optionFields splits the command line options of a pgBackRest command into
+// arguments the way a shell would. The options are joined by spaces, so one
+// item can hold many options and an option can span items. Quotes around all
+// or part of an argument are removed.
+func optionFields(options []string) []string {
+	var fields []string
+	var field strings.Builder
+	var quote rune
+	var inField bool
+
+	for _, c := range strings.Join(options, " ") {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			field.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote, inField = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+			inField = false
+		default:
+			field.WriteRune(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// OptionNames returns the names, e.g. "--type", of the command line options in
+// options of a pgBackRest command. Options can be given as "--name=value",
+// "--name value", or "--name", and one item can hold many options.
+func OptionNames(options []string) sets.String {
+	names := sets.NewString()
+	for _, field := range optionFields(options) {
+		if strings.HasPrefix(field, "-") {
+			name, _, _ := strings.Cut(field, "=")
+			names.Insert(name)
+		}
+	}
+	return names
+}
+
+// OptionValue returns the value of the command line option named name, e.g.
+// "--set", in options of a pgBackRest command. Quotes around the value are removed. When the
+// option appears more than once, the last value is returned.
+func OptionValue(options []string, name string) (string, bool) {
+	var value string
+	var found bool
+
+	fields := optionFields(options)
+	for i, field := range fields {
+		rest := strings.TrimPrefix(field, name)
+		switch {
+		case rest == field:
+		case strings.HasPrefix(rest, "="):
+			value, found = rest[1:], true
+		case rest == "" && i+1 < len(fields) && !strings.HasPrefix(fields[i+1], "-"):
+			value, found = fields[i+1], true
+		case rest == "":
+			value, found = "", true
+		}
+	}
+	return value, found
+}
+
+// ValidateRestoreOptions returns an error when options, the command line options
+// of a restore, contain a recovery type, target, or backup set that pgBackRest
+// would reject.
+func ValidateRestoreOptions(options []string) error {
+	recoveryType, _ := OptionValue(options, "--type")
+	target, hasTarget := OptionValue(options, "--target")
+
+	switch recoveryType {
+	case "", "default", "immediate", "preserve", "standby":
+		if hasTarget {
+			return errors.Errorf(
+				"option --target requires --type to be one of lsn, name, time, or xid; got %q",
+				recoveryType)
+		}
+	case "lsn", "name", "time", "xid":
+		if !hasTarget {
+			return errors.Errorf("option --type=%s requires --target", recoveryType)
+		}
+		if !restoreTargetPatterns[recoveryType].MatchString(target) {
+			return errors.Errorf("option --target %q is not a valid %s", target, recoveryType)
+		}
+		if recoveryType == "time" {
+			date := restoreTargetPatterns["time"].FindStringSubmatch(target)[1]
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				return errors.Errorf("option --target %q is not a valid time", target)
+			}
+		}
+	default:
+		return errors.Errorf("option --type %q is not a pgBackRest recovery type", recoveryType)
+	}
+
+	if set, ok := OptionValue(options, "--set"); ok && !restoreSetPattern.MatchString(set) {
+		return errors.Errorf("option --set %q is not a pgBackRest backup label", set)
+	}
+
+	return nil
+}
+
+// RestoreDatabaseOptions returns the command line options of a restore that
+// restores only databases. pgBackRest always restores the template and
+// "postgres" databases.
+// - https://pgbackrest.org/command.html#command-restore/category-command/option-db-include
+func RestoreDatabaseOptions(databases []string) []string {
+	options := make([]string, 0, len(databases))
+	for _, database := range databases {
+		options = append(options, "--db-include="+quoteShellWord(database))
+	}
+	return options
+}
+
+// RestoreProgressCommand returns a command that prints the progress of the most
+// recent restore in the pgBackRest log files of directory: its phase, percent
+// complete, and bytes restored. pgBackRest logs each file it restores only when
+// "log-level-file" is "detail" or more, so the command prints nothing when the
+// restore did not log at that level or there is no restore log.
+func RestoreProgressCommand(directory string) []string {
+
+	// Each run of pgBackRest begins with a PROCESS START line followed by the
+	// options of the command. A file line looks like one of the following; sizes
+	// are rounded and end with a unit. Files that already match the backup
+	// during a delta restore and files that are zeroed are not copied, so they
+	// count toward the percent but not the bytes restored.
+	//
+	//   P01 DETAIL: restore file /pgdata/pg14/base/1/1249 (440KB, 12.34%) checksum …
+	//   P01 DETAIL: restore file /pgdata/pg14/base/1/1255 (bundle 1/0, 8KB, 12.40%) checksum …
+	//   P01 DETAIL: restore file /pgdata/pg14/base/1/1259 - exists and matches backup (8KB, 12.50%) checksum …
+	//   P01 DETAIL: restore zeroed file /pgdata/pg14/base/16384/1259 (8KB, 12.60%)
+	//
+	// - https://pgbackrest.org/user-guide.html#restore
+	// - https://pgbackrest.org/configuration.html#section-log/option-log-level-file
+	const script = `declare -r directory="$1"
+log=''
+for file in "${directory}"/*-restore.log; do
+if [[ -f "${file}" && ( -z "${log}" || "${file}" -nt "${log}" ) ]]; then log="${file}"; fi
+done
+[[ -n "${log}" ]] || exit 0
+
+awk '
+/-------------------PROCESS START-------------------/ { bytes = 0; percent = 0; done = 0; detail = 0 }
+/ restore command begin / { detail = ($0 ~ / --log-level-file=(detail|debug|trace)( |$)/) }
+/ restore (zeroed )?file / && match($0, /\([^()]*%\)/) {
+  n = split(substr($0, RSTART + 1, RLENGTH - 2), fields, ", ")
+  percent = fields[n]; sub(/%$/, "", percent)
+  if ($0 ~ / restore zeroed file / || $0 ~ / - exists and /) next
+  size = fields[n - 1]; unit = size
+  sub(/[A-Z]+$/, "", size); sub(/^[0-9.]+/, "", unit)
+  if (unit == "KB") size *= 1024
+  else if (unit == "MB") size *= 1048576
+  else if (unit == "GB") size *= 1073741824
+  else if (unit == "TB") size *= 1099511627776
+  bytes += size
+}
+/ restore command end: completed successfully/ { done = 1 }
+END { if (detail) printf "%s %s %.0f\n", (done ? "Recovering" : "Restoring"), percent, bytes }
+' "${log}"`
+
+	return []string{"bash", "-ceu", "--", script, "-", directory}
+}
+
+// RestoreCheckCommand returns a command that checks, without restoring anything, that the
+// repository in args has what a restore with options needs: the backup set in "--set" or,
+// for a "--type=time" recovery, a backup that finished before "--target", and the WAL that
+// backup needs to become consistent. It prints the backup set that would be restored and
+// exits non-zero with a message when the restore would fail. Args are passed to
+// "pgbackrest info" and should identify the stanza and repository.
+// - https://pgbackrest.org/command.html#command-info
+func RestoreCheckCommand(options []string, args ...string) []string {
+	set, _ := OptionValue(options, "--set")
+
+	var target string
+	if recoveryType, _ := OptionValue(options, "--type"); recoveryType == "time" {
+		target, _ = OptionValue(options, "--target")
+	}
+
+	// The JSON output of "pgbackrest info" lists backups from oldest to newest.
+	// Each backup is an object that begins with the range of WAL it archived,
+	// so the WAL it needs is present when that range begins at or after the
+	// oldest WAL in the repository.
+	//
+	//   {"archive":{"start":"000000010000000000000002","stop":"…"},…,"label":"20220101-000000F",…,"timestamp":{"start":1640995200,"stop":1640995260},"type":"full"}
+	//
+	// - https://pgbackrest.org/command.html#command-info/category-command/option-output
+	const script = `declare -r set="$1" target="$2"
+shift 2
+info="$(pgbackrest info --output=json "$@")"
+
+epoch=''
+if [[ -n "${target}" ]]; then epoch="$(date --date="${target}" +%s)"; fi
+
+oldest="$(grep --only-matching '"min":"[0-9A-F]*"' <<< "${info}" | cut -d'"' -f4 | sort | sed -n 1p)"
+label='' wal=''
+while read -r backup start stop; do
+  if [[ -n "${set}" && "${backup}" != "${set}" ]]; then continue; fi
+  if [[ -n "${epoch}" && "${stop}" -gt "${epoch}" ]]; then continue; fi
+  label="${backup}" wal="${start}"
+done < <(sed 's/{"archive":{"start"/\n&/g' <<< "${info}" |
+  sed -n 's/^{"archive":{"start":"\([^"]*\)".*"label":"\([^"]*\)".*"timestamp":{"start":[0-9]*,"stop":\([0-9]*\)}.*/\2 \1 \3/p')
+
+if [[ -z "${label}" && -n "${set}" ]]; then
+  echo >&2 "backup set ${set} does not exist in the repository"; exit 1
+elif [[ -z "${label}" && -n "${target}" ]]; then
+  echo >&2 "no backup in the repository finished before ${target}"; exit 1
+elif [[ -z "${label}" ]]; then
+  echo >&2 "the repository has no backups"; exit 1
+elif [[ -z "${oldest}" || "${wal}" < "${oldest}" ]]; then
+  echo >&2 "the WAL needed by backup set ${label} is no longer in the repository"; exit 1
+fi
+echo "backup set ${label} and the WAL it needs are in the repository"`
+
+	return append([]string{"bash", "-ceu", "--", script, "-", set, target}, args...)
+}
+
 // populatePGInstanceConfigurationMap returns options representing the pgBackRest configuration for
-// a PostgreSQL instance
+// a PostgreSQL instance. When repoHostService is not empty, the instance reaches the repository
+// host through that Service rather than the first pod of repoHostName.
 func populatePGInstanceConfigurationMap(
-	serviceName, serviceNamespace, repoHostName, pgdataDir string,
+	ctx context.Context,
+	serviceName, serviceNamespace, repoHostName, repoHostService, stanzaName, pgdataDir string,
 	pgPort int32, repos []v1beta1.PGBackRestRepo,
+	push *v1beta1.PGBackRestArchivePush,
+	asyncArchive *v1beta1.PGBackRestAsyncArchive,
+	io *v1beta1.PGBackRestIO,
 	globalConfig map[string]string,
 ) iniSectionSet {
 
 	// TODO(cbandy): pass a FQDN in already.
 	repoHostFQDN := repoHostName + "-0." +
 		serviceName + "." + serviceNamespace + ".svc." +
-		naming.KubernetesClusterDomain(context.Background())
+		naming.KubernetesClusterDomain(ctx)
+	if repoHostService != "" {
+		repoHostFQDN = repoHostService + "." + serviceNamespace + ".svc." +
+			naming.KubernetesClusterDomain(ctx)
+	}
 
 	global := iniMultiSet{}
+	archivePush := iniMultiSet{}
 	stanza := iniMultiSet{}
 
+	// remote configures repo to be accessed through the TLS server at host.
+	remote := func(repo v1beta1.PGBackRestRepo, host string) {
+		global.Set(repo.Name+"-host", host)
+		global.Set(repo.Name+"-host-type", "tls")
+		global.Set(repo.Name+"-host-ca-file", certAuthorityAbsolutePath)
+		global.Set(repo.Name+"-host-cert-file", certClientAbsolutePath)
+		global.Set(repo.Name+"-host-key-file", certClientPrivateKeyAbsolutePath)
+		global.Set(repo.Name+"-host-user", "postgres")
+	}
+
 	// pgBackRest will log to the pgData volume for commands run on the PostgreSQL instance
 	global.Set("log-path", naming.PGBackRestPGDataLogPath)
 
 	for _, repo := range repos {
-		global.Set(repo.Name+"-path", defaultRepo1Path+repo.Name)
+		global.Set(repo.Name+"-path", repoPath(repo))
 
 		// repo volumes do not contain configuration (unlike other repo types which has actual
 		// pgBackRest settings such as "bucket", "region", etc.), so only grab the name from the
@@ -289,16 +652,58 @@ func populatePGInstanceConfigurationMap(
 			}
 		}
 
+		for option, val := range getRepoRetentionConfigs(repo) {
+			global.Set(option, val)
+		}
+
 		// Only "volume" (i.e. PVC-based) repos should ever have a repo host configured.  This
 		// means cloud-based repos (S3, GCS or Azure) should not have a repo host configured.
 		if repoHostName != "" && repo.Volume != nil {
-			global.Set(repo.Name+"-host", repoHostFQDN)
-			global.Set(repo.Name+"-host-type", "tls")
-			global.Set(repo.Name+"-host-ca-file", certAuthorityAbsolutePath)
-			global.Set(repo.Name+"-host-cert-file", certClientAbsolutePath)
-			global.Set(repo.Name+"-host-key-file", certClientPrivateKeyAbsolutePath)
-			global.Set(repo.Name+"-host-user", "postgres")
+			remote(repo, repoHostFQDN)
+		}
+
+		// Every cluster using a shared repo host stores its stanza in the same path.
+		if repo.SharedHost != nil {
+			global.Set(repo.Name+"-path", sharedRepoPath)
+			remote(repo, sharedRepoHostFQDN(ctx, repo.SharedHost.Name, serviceNamespace))
+		}
+	}
+
+	// Limit how long and how often each WAL file is pushed, and how much WAL
+	// can wait. These are limited to the archive-push command, like those below.
+	// - https://pgbackrest.org/configuration.html#section-archive
+	if push != nil {
+		if push.TimeoutSeconds != nil {
+			archivePush.Set("archive-timeout", fmt.Sprint(*push.TimeoutSeconds))
+		}
+		if push.Retries != nil {
+			archivePush.Set("job-retry", fmt.Sprint(*push.Retries))
 		}
+		if push.QueueMax != nil {
+			archivePush.Set("archive-push-queue-max", fmt.Sprint(push.QueueMax.Value()))
+		}
+	}
+
+	// Push WAL asynchronously through a spool directory on its own volume or,
+	// when there is none, the pgData volume. These options are limited to the
+	// archive-push command so that restores, which do not mount the spool volume,
+	// keep fetching WAL synchronously.
+	// - https://pgbackrest.org/user-guide.html#async-archiving
+	if asyncArchive != nil {
+		archivePush.Set("archive-async", "y")
+		archivePush.Set("spool-path", naming.PGBackRestPGDataSpoolPath)
+
+		if asyncArchive.SpoolVolumeClaimSpec != nil {
+			archivePush.Set("spool-path", spoolMountPath)
+		}
+		if asyncArchive.PushQueueMax != nil {
+			archivePush.Set("archive-push-queue-max",
+				fmt.Sprint(asyncArchive.PushQueueMax.Value()))
+		}
+	}
+
+	for option, val := range getIOConfigs(io) {
+		global.Set(option, val)
 	}
 
 	for option, val := range globalConfig {
@@ -311,17 +716,23 @@ func populatePGInstanceConfigurationMap(
 	stanza.Set("pg1-port", fmt.Sprint(pgPort))
 	stanza.Set("pg1-socket-path", postgres.SocketDirectory)
 
-	return iniSectionSet{
-		"global":          global,
-		DefaultStanzaName: stanza,
+	sections := iniSectionSet{
+		"global":   global,
+		stanzaName: stanza,
+	}
+	if len(archivePush) > 0 {
+		sections["global:archive-push"] = archivePush
 	}
+	return sections
 }
 
 // populateRepoHostConfigurationMap returns options representing the pgBackRest configuration for
-// a pgBackRest dedicated repository host
+// a pgBackRest dedicated repository host with replicas pods
 func populateRepoHostConfigurationMap(
-	serviceName, serviceNamespace, pgdataDir string,
-	pgPort int32, pgHosts []string, repos []v1beta1.PGBackRestRepo,
+	ctx context.Context,
+	serviceName, serviceNamespace, stanzaName, pgdataDir string,
+	pgPort int32, pgHosts []string, replicas int32, repos []v1beta1.PGBackRestRepo,
+	io *v1beta1.PGBackRestIO,
 	globalConfig map[string]string,
 ) iniSectionSet {
 
@@ -330,7 +741,12 @@ func populateRepoHostConfigurationMap(
 
 	var pgBackRestLogPathSet bool
 	for _, repo := range repos {
-		global.Set(repo.Name+"-path", defaultRepo1Path+repo.Name)
+		// commands for shared repos run on the shared repo host
+		if repo.SharedHost != nil {
+			continue
+		}
+
+		global.Set(repo.Name+"-path", repoPath(repo))
 
 		// repo volumes do not contain configuration (unlike other repo types which has actual
 		// pgBackRest settings such as "bucket", "region", etc.), so only grab the name from the
@@ -341,6 +757,10 @@ func populateRepoHostConfigurationMap(
 			}
 		}
 
+		for option, val := range getRepoRetentionConfigs(repo) {
+			global.Set(option, val)
+		}
+
 		if !pgBackRestLogPathSet && repo.Volume != nil {
 			// pgBackRest will log to the first configured repo volume when commands
 			// are run on the pgBackRest repo host. With our previous check in
@@ -348,35 +768,127 @@ func populateRepoHostConfigurationMap(
 			// defined repo has a volume.
 			global.Set("log-path", fmt.Sprintf(naming.PGBackRestRepoLogPath, repo.Name))
 			pgBackRestLogPathSet = true
+
+			// Pods of the repository host can run commands at the same time, e.g.
+			// while one is becoming active. Keep their locks on the shared volume
+			// so that they do not change the repository at the same time.
+			if replicas > 1 {
+				global.Set("lock-path", fmt.Sprintf(naming.PGBackRestRepoLockPath, repo.Name))
+			}
 		}
 	}
 
+	for option, val := range getIOConfigs(io) {
+		global.Set(option, val)
+	}
+
 	for option, val := range globalConfig {
 		global.Set(option, val)
 	}
 
 	// set the configs for all PG hosts
+	setPGHosts(ctx, stanza, serviceName, serviceNamespace, pgdataDir, pgPort, pgHosts,
+		certClientAbsolutePath, certClientPrivateKeyAbsolutePath)
+
+	return iniSectionSet{
+		"global":   global,
+		stanzaName: stanza,
+	}
+}
+
+// populateSharedRepoHostConfigurationMap returns options representing the pgBackRest
+// configuration for a SharedRepoHost. Each of clusters has its own stanza section that
+// contains its repositories on hostName and the instances named in pgHosts.
+func populateSharedRepoHostConfigurationMap(
+	ctx context.Context, hostName string, clusters []*v1beta1.PostgresCluster, pgHosts map[string][]string,
+) iniSectionSet {
+
+	global := iniMultiSet{}
+	sections := iniSectionSet{"global": global}
+
+	// pgBackRest will log to the shared volume for commands run on the repo host.
+	global.Set("log-path", sharedRepoPath+"/log")
+
+	for _, cluster := range clusters {
+		stanza := iniMultiSet{}
+		sections[StanzaName(cluster)] = stanza
+
+		var repoNames []string
+		for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+			if repo.SharedHost == nil || repo.SharedHost.Name != hostName {
+				continue
+			}
+			repoNames = append(repoNames, repo.Name)
+
+			stanza.Set(repo.Name+"-path", sharedRepoPath)
+			for option, val := range getRepoRetentionConfigs(repo) {
+				stanza.Set(option, val)
+			}
+		}
+
+		// Commands for these repos run on the shared repo host, so they need
+		// the same I/O options as the rest of cluster.
+		for option, val := range getIOConfigs(cluster.Spec.Backups.PGBackRest.IO) {
+			stanza.Set(option, val)
+		}
+
+		// Only the global options of cluster that are about its shared repos
+		// apply here; the rest are for commands run on the PostgreSQL instances.
+		for option, val := range cluster.Spec.Backups.PGBackRest.Global {
+			for _, name := range repoNames {
+				if strings.HasPrefix(option, name+"-") {
+					stanza.Set(option, val)
+				}
+			}
+		}
+
+		// The repo host presents the client certificate of cluster to its
+		// instances; see [sharedClientCertificates].
+		setPGHosts(ctx, stanza,
+			naming.ClusterPodService(cluster).Name, cluster.Namespace,
+			postgres.DataDirectory(cluster), *cluster.Spec.Port, pgHosts[cluster.Name],
+			configDirectory+"/"+sharedClientProjectionPath(cluster),
+			configDirectory+"/"+sharedClientPrivateKeyProjectionPath(cluster))
+	}
+
+	return sections
+}
+
+// setPGHosts adds options to stanza for connecting to the TLS servers of the
+// PostgreSQL instances named in pgHosts using the client certificate in certFile
+// and keyFile.
+func setPGHosts(
+	ctx context.Context, stanza iniMultiSet, serviceName, serviceNamespace, pgdataDir string,
+	pgPort int32, pgHosts []string, certFile, keyFile string,
+) {
 	for i, pgHost := range pgHosts {
 		// TODO(cbandy): pass a FQDN in already.
 		pgHostFQDN := pgHost + "-0." +
 			serviceName + "." + serviceNamespace + ".svc." +
-			naming.KubernetesClusterDomain(context.Background())
+			naming.KubernetesClusterDomain(ctx)
 
 		stanza.Set(fmt.Sprintf("pg%d-host", i+1), pgHostFQDN)
 		stanza.Set(fmt.Sprintf("pg%d-host-type", i+1), "tls")
 		stanza.Set(fmt.Sprintf("pg%d-host-ca-file", i+1), certAuthorityAbsolutePath)
-		stanza.Set(fmt.Sprintf("pg%d-host-cert-file", i+1), certClientAbsolutePath)
-		stanza.Set(fmt.Sprintf("pg%d-host-key-file", i+1), certClientPrivateKeyAbsolutePath)
+		stanza.Set(fmt.Sprintf("pg%d-host-cert-file", i+1), certFile)
+		stanza.Set(fmt.Sprintf("pg%d-host-key-file", i+1), keyFile)
 
 		stanza.Set(fmt.Sprintf("pg%d-path", i+1), pgdataDir)
 		stanza.Set(fmt.Sprintf("pg%d-port", i+1), fmt.Sprint(pgPort))
 		stanza.Set(fmt.Sprintf("pg%d-socket-path", i+1), postgres.SocketDirectory)
 	}
+}
 
-	return iniSectionSet{
-		"global":          global,
-		DefaultStanzaName: stanza,
-	}
+// sharedRepoHostFQDN returns the fully qualified domain name of the Pod of the
+// SharedRepoHost named hostName in namespace.
+func sharedRepoHostFQDN(ctx context.Context, hostName, namespace string) string {
+	meta := naming.SharedRepoHost(&v1beta1.SharedRepoHost{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: hostName},
+	})
+
+	// TODO(cbandy): pass a FQDN in already.
+	return meta.Name + "-0." + meta.Name + "." + meta.Namespace + ".svc." +
+		naming.KubernetesClusterDomain(ctx)
 }
 
 // getExternalRepoConfigs returns a map containing the configuration settings for an external
@@ -388,6 +900,9 @@ func getExternalRepoConfigs(repo v1beta1.PGBackRestRepo) map[string]string {
 	if repo.Azure != nil {
 		repoConfigs[repo.Name+"-type"] = "azure"
 		repoConfigs[repo.Name+"-azure-container"] = repo.Azure.Container
+		if repo.Azure.KeyType != "" {
+			repoConfigs[repo.Name+"-azure-key-type"] = repo.Azure.KeyType
+		}
 	} else if repo.GCS != nil {
 		repoConfigs[repo.Name+"-type"] = "gcs"
 		repoConfigs[repo.Name+"-gcs-bucket"] = repo.GCS.Bucket
@@ -396,11 +911,296 @@ func getExternalRepoConfigs(repo v1beta1.PGBackRestRepo) map[string]string {
 		repoConfigs[repo.Name+"-s3-bucket"] = repo.S3.Bucket
 		repoConfigs[repo.Name+"-s3-endpoint"] = repo.S3.Endpoint
 		repoConfigs[repo.Name+"-s3-region"] = repo.S3.Region
+		if repo.S3.KeyType != "" {
+			repoConfigs[repo.Name+"-s3-key-type"] = repo.S3.KeyType
+		}
+	}
+
+	if repo.UploadChunkSize != nil && len(repoConfigs) > 0 {
+		repoConfigs[repo.Name+"-storage-upload-chunk-size"] =
+			fmt.Sprint(repo.UploadChunkSize.Value())
+	}
+
+	return repoConfigs
+}
+
+// getIOConfigs returns a map containing the network and storage I/O settings of
+// pgBackRest as defined in the PostgresCluster spec. pgBackRest does not allow these
+// to differ between repositories, so they apply to all of them.
+func getIOConfigs(io *v1beta1.PGBackRestIO) map[string]string {
+
+	ioConfigs := make(map[string]string)
+
+	if io == nil {
+		return ioConfigs
+	}
+	if io.TimeoutSeconds != nil {
+		ioConfigs["io-timeout"] = fmt.Sprint(*io.TimeoutSeconds)
+	}
+	if io.BufferSize != nil {
+		ioConfigs["buffer-size"] = fmt.Sprint(io.BufferSize.Value())
+	}
+
+	return ioConfigs
+}
+
+// getRepoRetentionConfigs returns a map containing the retention settings for a pgBackRest
+// repository as defined in the PostgresCluster spec
+func getRepoRetentionConfigs(repo v1beta1.PGBackRestRepo) map[string]string {
+
+	repoConfigs := make(map[string]string)
+
+	if repo.RetentionFull != nil {
+		repoConfigs[repo.Name+"-retention-full"] = fmt.Sprint(*repo.RetentionFull)
+	}
+	if repo.RetentionFullType != "" {
+		repoConfigs[repo.Name+"-retention-full-type"] = repo.RetentionFullType
+	}
+	if repo.RetentionDiff != nil {
+		repoConfigs[repo.Name+"-retention-diff"] = fmt.Sprint(*repo.RetentionDiff)
+	}
+	if repo.RetentionArchive != nil {
+		repoConfigs[repo.Name+"-retention-archive"] = fmt.Sprint(*repo.RetentionArchive)
+	}
+	if repo.RetentionArchiveType != "" {
+		repoConfigs[repo.Name+"-retention-archive-type"] = repo.RetentionArchiveType
+	}
+
+	// With time-based retention, pgBackRest keeps the full backups needed to
+	// restore to any time in the last N days and the WAL those backups need.
+	// - https://pgbackrest.org/user-guide.html#retention/full
+	if repo.RetentionDays != nil {
+		repoConfigs[repo.Name+"-retention-full"] = fmt.Sprint(*repo.RetentionDays)
+		repoConfigs[repo.Name+"-retention-full-type"] = "time"
+	}
+
+	// Objects in a bucket with S3 Object Lock cannot be deleted until the lock
+	// expires. Keep full backups at least that long so pgBackRest does not expire
+	// backups that are still locked.
+	if repo.S3 != nil && repo.S3.ObjectLockDays != nil {
+		days := *repo.S3.ObjectLockDays
+		if repoConfigs[repo.Name+"-retention-full-type"] == "time" {
+			if repo.RetentionDays != nil && *repo.RetentionDays > days {
+				days = *repo.RetentionDays
+			}
+			if repo.RetentionDays == nil && repo.RetentionFull != nil && *repo.RetentionFull > days {
+				days = *repo.RetentionFull
+			}
+		}
+		repoConfigs[repo.Name+"-retention-full"] = fmt.Sprint(days)
+		repoConfigs[repo.Name+"-retention-full-type"] = "time"
 	}
 
 	return repoConfigs
 }
 
+// BackupCommandOptions returns the compression, parallelism and other options for a backup
+// of backupType ("full", "diff" or "incr") to repo as defined in the PostgresCluster spec.
+// pgBackRest does not allow these options to differ between repositories in its
+// configuration file, so they are passed to the backup command instead. An error is returned
+// when the compression level is not valid for the compression type or an option is not
+// allowed.
+func BackupCommandOptions(repo v1beta1.PGBackRestRepo, backupType string) ([]string, error) {
+	if repo.BackupOptions == nil {
+		return nil, nil
+	}
+
+	options := repo.BackupOptions.PGBackRestBackupOptions
+	var typed *v1beta1.PGBackRestBackupOptions
+	switch backupType {
+	case "full":
+		typed = repo.BackupOptions.Full
+	case "diff":
+		typed = repo.BackupOptions.Differential
+	case "incr":
+		typed = repo.BackupOptions.Incremental
+	}
+	if typed != nil {
+		if typed.CompressType != "" {
+			options.CompressType = typed.CompressType
+		}
+		if typed.CompressLevel != nil {
+			options.CompressLevel = typed.CompressLevel
+		}
+		if typed.ProcessMax != nil {
+			options.ProcessMax = typed.ProcessMax
+		}
+	}
+
+	var result []string
+	if options.CompressType != "" {
+		result = append(result, "--compress-type="+options.CompressType)
+	}
+	if options.CompressLevel != nil {
+		// The allowed levels for each compression type; gz is the default type.
+		// - https://pgbackrest.org/configuration.html#section-general/option-compress-level
+		levels := map[string][2]int32{
+			"": {0, 9}, "gz": {0, 9}, "bz2": {1, 9}, "lz4": {0, 12}, "zst": {0, 22},
+		}
+		level := *options.CompressLevel
+		if allowed, ok := levels[options.CompressType]; !ok ||
+			level < allowed[0] || level > allowed[1] {
+			return nil, errors.Errorf("compressLevel %d is not valid for compressType %q of %s",
+				level, options.CompressType, repo.Name)
+		}
+		result = append(result, fmt.Sprintf("--compress-level=%d", level))
+	}
+	if options.ProcessMax != nil {
+		result = append(result, fmt.Sprintf("--process-max=%d", *options.ProcessMax))
+	}
+
+	// Options of the backup type replace those of the same name for all backups,
+	// and both replace the options above.
+	overrides := [][]string{repo.BackupOptions.Options}
+	if typed != nil {
+		overrides = append(overrides, typed.Options)
+	}
+	for _, override := range overrides {
+		names := OptionNames(override)
+		for _, name := range names.List() {
+			if name == "--repo" || name == "--stanza" || name == "--type" {
+				return nil, errors.Errorf("option %q is not allowed in the backupOptions of %s",
+					name, repo.Name)
+			}
+		}
+
+		kept := result[:0]
+		for _, opt := range result {
+			if !names.HasAny(OptionNames([]string{opt}).UnsortedList()...) {
+				kept = append(kept, opt)
+			}
+		}
+		result = append(kept, override...)
+	}
+
+	return result, nil
+}
+
+// ownedGlobalOptions are prefixes of options that PGO generates. Changing them
+// breaks the configuration of PostgreSQL instances, repository hosts, or the
+// pgBackRest TLS server.
+var ownedGlobalOptions = []string{
+	"config", "log-path", "pg-host", "pg-path", "pg-port", "pg-socket-path",
+	"repo-host", "repo-type", "tls-server-",
+}
+
+// OwnedGlobalOptions returns the sorted keys of options that cannot be set in
+// the "global" section because PGO generates them. Any other option is passed
+// to pgBackRest as-is, so options of every pgBackRest version are allowed.
+func OwnedGlobalOptions(options map[string]string) []string {
+	var owned []string
+	for key := range options {
+		// Remove the index from repository and instance options.
+		option := key
+		for _, prefix := range []string{"repo", "pg"} {
+			if rest := strings.TrimPrefix(key, prefix); rest != key {
+				if i := strings.IndexByte(rest, '-'); i > 0 &&
+					strings.Trim(rest[:i], "0123456789") == "" {
+					option = prefix + rest[i:]
+				}
+			}
+		}
+
+		for _, prefix := range ownedGlobalOptions {
+			if strings.HasPrefix(option, prefix) {
+				owned = append(owned, key)
+				break
+			}
+		}
+	}
+
+	sort.Strings(owned)
+	return owned
+}
+
+// ValidateGlobalOptions returns an error that lists the keys of options that
+// cannot be set in the "global" section because PGO generates them.
+func ValidateGlobalOptions(options map[string]string) error {
+	if owned := OwnedGlobalOptions(options); len(owned) > 0 {
+		return fmt.Errorf("options managed by PGO cannot be set: %s",
+			strings.Join(owned, ", "))
+	}
+	return nil
+}
+
+// ValidateWebIdentity returns an error when repos cannot share the environment
+// that exchanges service account tokens for cloud credentials. Every S3 repository
+// using "web-id" must name the same role, and every Azure repository using a
+// workload identity must name the same client and tenant.
+func ValidateWebIdentity(repos []v1beta1.PGBackRestRepo) error {
+	var roleARN, clientID, tenantID string
+	var problems []string
+
+	for _, repo := range repos {
+		if s3 := repo.S3; s3 != nil && s3.KeyType == "web-id" {
+			switch {
+			case s3.RoleARN == "":
+				problems = append(problems, fmt.Sprintf(
+					"%s uses web-id without a roleARN", repo.Name))
+			case roleARN == "":
+				roleARN = s3.RoleARN
+			case roleARN != s3.RoleARN:
+				problems = append(problems, fmt.Sprintf(
+					"%s uses roleARN %q, not %q", repo.Name, s3.RoleARN, roleARN))
+			}
+		}
+		if az := repo.Azure; az != nil && (az.ClientID != "" || az.TenantID != "") {
+			switch {
+			case az.ClientID == "" || az.TenantID == "":
+				problems = append(problems, fmt.Sprintf(
+					"%s requires both clientID and tenantID", repo.Name))
+			case clientID == "":
+				clientID, tenantID = az.ClientID, az.TenantID
+			case clientID != az.ClientID || tenantID != az.TenantID:
+				problems = append(problems, fmt.Sprintf(
+					"%s uses a workload identity other than clientID %q in tenantID %q",
+					repo.Name, clientID, tenantID))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("repositories cannot share a web identity: %s",
+			strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ValidateRepoPaths returns an error when the path of a repository in repos
+// cannot be used: it is set on a repository that is always at its default path,
+// or another repository is already at the same path in the same storage. Paths
+// in global take precedence over those in repos. It also returns the names of
+// repos that conflict with an earlier repository; their paths should not be used.
+func ValidateRepoPaths(repos []v1beta1.PGBackRestRepo, global map[string]string) ([]string, error) {
+	var conflicts, problems []string
+	seen := make(map[string]string)
+
+	for _, repo := range repos {
+		if repo.Path != "" && (repo.Volume != nil || repo.SharedHost != nil) {
+			problems = append(problems, fmt.Sprintf(
+				"%s is always at %s", repo.Name, repoPath(repo)))
+		}
+
+		location := repoLocation(global, repo)
+		if location == "" {
+			continue
+		}
+		if earlier, ok := seen[location]; ok {
+			conflicts = append(conflicts, repo.Name)
+			problems = append(problems, fmt.Sprintf(
+				"%s is at the same path as %s", repo.Name, earlier))
+		} else {
+			seen[location] = repo.Name
+		}
+	}
+
+	if len(problems) > 0 {
+		return conflicts, fmt.Errorf("invalid repository paths: %s",
+			strings.Join(problems, "; "))
+	}
+	return nil, nil
+}
+
 // reloadCommand returns an entrypoint that convinces the pgBackRest TLS server
 // to reload its options and certificate files when they change. The process
 // will appear as name in `ps` and `top`.
@@ -453,6 +1253,34 @@ done
 
 // serverConfig returns the options needed to run the TLS server for cluster.
 func serverConfig(cluster *v1beta1.PostgresCluster) iniSectionSet {
+	config := tlsServerConfig(cluster.Annotations)
+
+	// The client certificate for this cluster is allowed to connect for any stanza.
+	// Without the wildcard "*", the "pgbackrest info" and "pgbackrest repo-ls"
+	// commands fail with "access denied" when invoked without a "--stanza" flag.
+	config["global"].Add("tls-server-auth", clientCommonName(cluster)+"=*")
+
+	return config
+}
+
+// sharedServerConfig returns the options needed to run the TLS server of host
+// for clusters.
+func sharedServerConfig(host *v1beta1.SharedRepoHost,
+	clusters []*v1beta1.PostgresCluster) iniSectionSet {
+	config := tlsServerConfig(host.Annotations)
+
+	// The client certificate of each cluster is allowed to connect only for
+	// the stanza of that cluster.
+	for _, cluster := range clusters {
+		config["global"].Add("tls-server-auth",
+			clientCommonName(cluster)+"="+StanzaName(cluster))
+	}
+
+	return config
+}
+
+// tlsServerConfig returns the options needed to run a TLS server that has annotations.
+func tlsServerConfig(annotations map[string]string) iniSectionSet {
 	global := iniMultiSet{}
 	server := iniMultiSet{}
 
@@ -479,15 +1307,10 @@ func serverConfig(cluster *v1beta1.PostgresCluster) iniSectionSet {
 	// going to workaround the issue by allowing the user to add an annotation to
 	// enable IPv6. We will check for that annotation here and override the
 	// "tls-server-address" setting accordingly.
-	if strings.EqualFold(cluster.Annotations[naming.PGBackRestIPVersion], "ipv6") {
+	if strings.EqualFold(annotations[naming.PGBackRestIPVersion], "ipv6") {
 		global.Set("tls-server-address", "::")
 	}
 
-	// The client certificate for this cluster is allowed to connect for any stanza.
-	// Without the wildcard "*", the "pgbackrest info" and "pgbackrest repo-ls"
-	// commands fail with "access denied" when invoked without a "--stanza" flag.
-	global.Add("tls-server-auth", clientCommonName(cluster)+"=*")
-
 	global.Set("tls-server-ca-file", certAuthorityAbsolutePath)
 	global.Set("tls-server-cert-file", certServerAbsolutePath)
 	global.Set("tls-server-key-file", certServerPrivateKeyAbsolutePath)
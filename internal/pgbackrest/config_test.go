@@ -17,6 +17,7 @@ package pgbackrest
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -27,6 +28,7 @@ import (
 	"gotest.tools/v3/assert/cmp"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
@@ -49,7 +51,7 @@ func TestCreatePGBackRestConfigMapIntent(t *testing.T) {
 		cluster := cluster.DeepCopy()
 		cluster.Spec.Backups.PGBackRest.Repos = nil
 
-		configmap := CreatePGBackRestConfigMapIntent(cluster,
+		configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
 			"", "number", "pod-service-name", "test-ns",
 			[]string{"some-instance"})
 
@@ -83,7 +85,7 @@ func TestCreatePGBackRestConfigMapIntent(t *testing.T) {
 			},
 		}
 
-		configmap := CreatePGBackRestConfigMapIntent(cluster,
+		configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
 			"repo-hostname", "abcde12345", "pod-service-name", "test-ns",
 			[]string{"some-instance"})
 
@@ -159,6 +161,132 @@ pg1-socket-path = /tmp/postgres
 		`, "\t\n")+"\n")
 	})
 
+	t.Run("RepoPath", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+			{
+				Name:   "repo1",
+				Path:   "/ignored",
+				Volume: &v1beta1.RepoPVC{},
+			},
+			{
+				Name: "repo2",
+				Path: "/clusters/hippo",
+				GCS:  &v1beta1.RepoGCS{Bucket: "g-bucket"},
+			},
+		}
+
+		configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
+			"repo-hostname", "abcde12345", "pod-service-name", "test-ns",
+			[]string{"some-instance"})
+
+		for _, key := range []string{"pgbackrest_instance.conf", "pgbackrest_repo.conf"} {
+			assert.Assert(t, cmp.Contains(configmap.Data[key],
+				"\nrepo1-path = /pgbackrest/repo1\n"), "key %q", key)
+			assert.Assert(t, cmp.Contains(configmap.Data[key],
+				"\nrepo2-path = /clusters/hippo\n"), "key %q", key)
+		}
+	})
+
+	t.Run("RepoRetention", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Global = map[string]string{
+			"repo2-retention-diff": "overridden",
+		}
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+			{
+				Name:              "repo1",
+				Volume:            &v1beta1.RepoPVC{},
+				RetentionFull:     initialize.Int32(14),
+				RetentionFullType: "time",
+			},
+			{
+				Name:             "repo2",
+				GCS:              &v1beta1.RepoGCS{Bucket: "g-bucket"},
+				RetentionFull:    initialize.Int32(2),
+				RetentionDiff:    initialize.Int32(3),
+				RetentionArchive: initialize.Int32(1),
+			},
+			{
+				Name:                 "repo3",
+				S3:                   &v1beta1.RepoS3{Bucket: "s-bucket"},
+				RetentionFull:        initialize.Int32(2),
+				RetentionArchive:     initialize.Int32(3),
+				RetentionArchiveType: "diff",
+				RetentionDays:        initialize.Int32(30),
+			},
+			{
+				Name: "repo4",
+				S3: &v1beta1.RepoS3{
+					Bucket:         "locked",
+					ObjectLockDays: initialize.Int32(7),
+				},
+				RetentionFull: initialize.Int32(2),
+			},
+		}
+
+		configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
+			"repo-hostname", "abcde12345", "pod-service-name", "test-ns",
+			[]string{"some-instance"})
+
+		for _, key := range []string{"pgbackrest_instance.conf", "pgbackrest_repo.conf"} {
+			assert.Assert(t, cmp.Contains(configmap.Data[key], "\n"+strings.Trim(`
+repo1-retention-full = 14
+repo1-retention-full-type = time
+			`, "\t\n")+"\n"), "key %q", key)
+			assert.Assert(t, cmp.Contains(configmap.Data[key], "\n"+strings.Trim(`
+repo2-path = /pgbackrest/repo2
+repo2-retention-archive = 1
+repo2-retention-diff = overridden
+repo2-retention-full = 2
+repo2-type = gcs
+			`, "\t\n")+"\n"), "key %q", key)
+			assert.Assert(t, cmp.Contains(configmap.Data[key], "\n"+strings.Trim(`
+repo3-retention-archive = 3
+repo3-retention-archive-type = diff
+repo3-retention-full = 30
+repo3-retention-full-type = time
+			`, "\t\n")+"\n"), "key %q", key)
+			assert.Assert(t, cmp.Contains(configmap.Data[key], "\n"+strings.Trim(`
+repo4-retention-full = 7
+repo4-retention-full-type = time
+			`, "\t\n")+"\n"), "key %q", key)
+		}
+	})
+
+	t.Run("KeyType", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+			{
+				Name: "repo1",
+				S3: &v1beta1.RepoS3{
+					Bucket: "s-bucket", Endpoint: "endpoint-s", Region: "earth",
+					KeyType: "web-id", RoleARN: "arn:aws:iam::123:role/hippo",
+				},
+			},
+			{
+				Name: "repo2",
+				Azure: &v1beta1.RepoAzure{
+					Container: "a-container", KeyType: "auto",
+				},
+			},
+		}
+
+		configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
+			"", "abcde12345", "pod-service-name", "test-ns",
+			[]string{"some-instance"})
+
+		assert.Assert(t, cmp.Contains(configmap.Data["pgbackrest_instance.conf"], "\n"+strings.Trim(`
+repo1-s3-bucket = s-bucket
+repo1-s3-endpoint = endpoint-s
+repo1-s3-key-type = web-id
+repo1-s3-region = earth
+repo1-type = s3
+repo2-azure-container = a-container
+repo2-azure-key-type = auto
+		`, "\t\n")+"\n"))
+	})
+
 	t.Run("CustomMetadata", func(t *testing.T) {
 		cluster := cluster.DeepCopy()
 		cluster.Spec.Metadata = &v1beta1.Metadata{
@@ -186,7 +314,7 @@ pg1-socket-path = /tmp/postgres
 			},
 		}
 
-		configmap := CreatePGBackRestConfigMapIntent(cluster,
+		configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
 			"any", "any", "any", "any", nil)
 
 		assert.DeepEqual(t, configmap.Annotations, map[string]string{
@@ -204,6 +332,543 @@ pg1-socket-path = /tmp/postgres
 			"postgres-operator.crunchydata.com/pgbackrest-config": "",
 		})
 	})
+
+	t.Run("SharedRepoHost", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+			{
+				Name:       "repo2",
+				SharedHost: &v1beta1.RepoSharedHost{Name: "shared"},
+			},
+		}
+
+		configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
+			"", "abcde12345", "pod-service-name", "test-ns",
+			[]string{"some-instance"})
+
+		// The instances run a TLS server without a dedicated repository host.
+		assert.Assert(t, strings.Contains(configmap.Data["pgbackrest-server.conf"],
+			"tls-server-auth = pgbackrest@"))
+		assert.Assert(t, configmap.Data["pgbackrest_repo.conf"] == "")
+
+		// The stanza is the name of the cluster.
+		assert.Equal(t, configmap.Data["pgbackrest_instance.conf"], strings.Trim(`
+# Generated by postgres-operator. DO NOT EDIT.
+# Your changes will not be saved.
+
+[global]
+log-path = /pgdata/pgbackrest/log
+repo2-host = shared-shared-repo-0.shared-shared-repo.test-ns.svc.`+domain+`
+repo2-host-ca-file = /etc/pgbackrest/conf.d/~postgres-operator/tls-ca.crt
+repo2-host-cert-file = /etc/pgbackrest/conf.d/~postgres-operator/client-tls.crt
+repo2-host-key-file = /etc/pgbackrest/conf.d/~postgres-operator/client-tls.key
+repo2-host-type = tls
+repo2-host-user = postgres
+repo2-path = /pgbackrest/shared
+
+[hippo-dance]
+pg1-path = /pgdata/pg12
+pg1-port = 2345
+pg1-socket-path = /tmp/postgres
+		`, "\t\n")+"\n")
+	})
+
+	t.Run("AsyncArchive", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+			{Name: "repo1", GCS: &v1beta1.RepoGCS{Bucket: "g-bucket"}},
+		}
+		cluster.Spec.Backups.PGBackRest.AsyncArchive = &v1beta1.PGBackRestAsyncArchive{}
+
+		configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
+			"", "abcde12345", "pod-service-name", "test-ns",
+			[]string{"some-instance"})
+
+		// Only archive-push is asynchronous, and it spools to the pgData volume.
+		assert.Assert(t, cmp.Contains(configmap.Data["pgbackrest_instance.conf"], "\n"+strings.Trim(`
+[global:archive-push]
+archive-async = y
+spool-path = /pgdata/pgbackrest/spool
+
+[db]
+		`, "\t\n")+"\n"))
+
+		t.Run("SpoolVolumeAndQueueMax", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Backups.PGBackRest.AsyncArchive.PushQueueMax = resource.NewQuantity(
+				5<<30, resource.BinarySI)
+			cluster.Spec.Backups.PGBackRest.AsyncArchive.SpoolVolumeClaimSpec =
+				&corev1.PersistentVolumeClaimSpec{}
+
+			configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
+				"", "abcde12345", "pod-service-name", "test-ns",
+				[]string{"some-instance"})
+
+			assert.Assert(t, cmp.Contains(configmap.Data["pgbackrest_instance.conf"], "\n"+strings.Trim(`
+[global:archive-push]
+archive-async = y
+archive-push-queue-max = 5368709120
+spool-path = /pgspool
+			`, "\t\n")+"\n"))
+		})
+	})
+
+	t.Run("ArchivePush", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+			{Name: "repo1", GCS: &v1beta1.RepoGCS{Bucket: "g-bucket"}},
+		}
+		cluster.Spec.Backups.PGBackRest.ArchivePush = &v1beta1.PGBackRestArchivePush{
+			AlertAfterMinutes: initialize.Int32(30),
+			QueueMax:          resource.NewQuantity(1<<30, resource.BinarySI),
+			Retries:           initialize.Int32(4),
+			TimeoutSeconds:    initialize.Int32(120),
+		}
+
+		configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
+			"", "abcde12345", "pod-service-name", "test-ns",
+			[]string{"some-instance"})
+
+		// The options apply to synchronous archive-push. The alert is not an option.
+		assert.Assert(t, cmp.Contains(configmap.Data["pgbackrest_instance.conf"], "\n"+strings.Trim(`
+[global:archive-push]
+archive-push-queue-max = 1073741824
+archive-timeout = 120
+job-retry = 4
+
+[db]
+		`, "\t\n")+"\n"))
+
+		t.Run("AsyncQueueMax", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Backups.PGBackRest.AsyncArchive = &v1beta1.PGBackRestAsyncArchive{
+				PushQueueMax: resource.NewQuantity(5<<30, resource.BinarySI),
+			}
+
+			configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
+				"", "abcde12345", "pod-service-name", "test-ns",
+				[]string{"some-instance"})
+
+			assert.Assert(t, cmp.Contains(configmap.Data["pgbackrest_instance.conf"], "\n"+strings.Trim(`
+[global:archive-push]
+archive-async = y
+archive-push-queue-max = 5368709120
+archive-timeout = 120
+job-retry = 4
+spool-path = /pgdata/pgbackrest/spool
+			`, "\t\n")+"\n"))
+		})
+	})
+
+	t.Run("IO", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Global = map[string]string{
+			"io-timeout": "overridden",
+		}
+		cluster.Spec.Backups.PGBackRest.IO = &v1beta1.PGBackRestIO{
+			TimeoutSeconds: initialize.Int32(120),
+			BufferSize:     resource.NewQuantity(4<<20, resource.BinarySI),
+		}
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+			{
+				Name:            "repo1",
+				Volume:          &v1beta1.RepoPVC{},
+				UploadChunkSize: resource.NewQuantity(8<<20, resource.BinarySI),
+			},
+			{
+				Name:            "repo2",
+				S3:              &v1beta1.RepoS3{Bucket: "s-bucket", Endpoint: "endpoint-s", Region: "earth"},
+				UploadChunkSize: resource.NewQuantity(8<<20, resource.BinarySI),
+			},
+		}
+
+		configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
+			"repo-hostname", "abcde12345", "pod-service-name", "test-ns",
+			[]string{"some-instance"})
+
+		for _, key := range []string{"pgbackrest_instance.conf", "pgbackrest_repo.conf"} {
+			assert.Assert(t, cmp.Contains(configmap.Data[key], "\n"+strings.Trim(`
+[global]
+buffer-size = 4194304
+io-timeout = overridden
+			`, "\t\n")+"\n"), "key %q", key)
+
+			// The upload chunk size is only for object stores.
+			assert.Assert(t, cmp.Contains(configmap.Data[key],
+				"\nrepo2-storage-upload-chunk-size = 8388608\n"), "key %q", key)
+			assert.Assert(t, !strings.Contains(configmap.Data[key],
+				"repo1-storage-upload-chunk-size"), "key %q", key)
+		}
+	})
+
+	t.Run("RepoHostReplicas", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.RepoHost = &v1beta1.PGBackRestRepoHost{
+			Replicas: initialize.Int32(2),
+		}
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{
+			Name: "repo1",
+			Volume: &v1beta1.RepoPVC{VolumeClaimSpec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			}},
+		}}
+
+		configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
+			"repo-hostname", "abcde12345", "pod-service-name", "test-ns",
+			[]string{"some-instance"})
+
+		// Instances connect to the Service of the active pod.
+		assert.Assert(t, cmp.Contains(configmap.Data["pgbackrest_instance.conf"],
+			"\nrepo1-host = hippo-dance-repo-host.test-ns.svc."+domain+"\n"))
+
+		// The pods keep their locks on the shared volume.
+		assert.Assert(t, cmp.Contains(configmap.Data["pgbackrest_repo.conf"],
+			"\nlock-path = /pgbackrest/repo1/lock\n"))
+
+		t.Run("ReadWriteOnce", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Backups.PGBackRest.Repos[0].Volume.VolumeClaimSpec.AccessModes =
+				[]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+
+			configmap := CreatePGBackRestConfigMapIntent(context.Background(), cluster,
+				"repo-hostname", "abcde12345", "pod-service-name", "test-ns",
+				[]string{"some-instance"})
+
+			assert.Assert(t, cmp.Contains(configmap.Data["pgbackrest_instance.conf"],
+				"\nrepo1-host = repo-hostname-0.pod-service-name.test-ns.svc."+domain+"\n"))
+			assert.Assert(t, !strings.Contains(configmap.Data["pgbackrest_repo.conf"], "lock-path"))
+		})
+	})
+}
+
+func TestCreateSharedRepoHostConfigMapIntent(t *testing.T) {
+	domain := naming.KubernetesClusterDomain(context.Background())
+
+	host := &v1beta1.SharedRepoHost{}
+	host.Namespace, host.Name = "ns1", "shared"
+
+	hippo := &v1beta1.PostgresCluster{}
+	hippo.Namespace, hippo.Name, hippo.UID = "ns1", "hippo", "hippo-uid"
+	hippo.Spec.Port = initialize.Int32(5432)
+	hippo.Spec.PostgresVersion = 14
+	hippo.Spec.Backups.PGBackRest.Global = map[string]string{
+		"repo1-path":           "/ignored",
+		"repo2-retention-diff": "4",
+		"compress-level":       "3",
+	}
+	hippo.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+		{Name: "repo1", Volume: &v1beta1.RepoPVC{}},
+		{
+			Name:          "repo2",
+			SharedHost:    &v1beta1.RepoSharedHost{Name: "shared"},
+			RetentionFull: initialize.Int32(2),
+		},
+	}
+
+	rhino := &v1beta1.PostgresCluster{}
+	rhino.Namespace, rhino.Name, rhino.UID = "ns1", "rhino", "rhino-uid"
+	rhino.Spec.Port = initialize.Int32(2345)
+	rhino.Spec.PostgresVersion = 15
+	rhino.Spec.Backups.PGBackRest.IO = &v1beta1.PGBackRestIO{
+		TimeoutSeconds: initialize.Int32(90),
+	}
+	rhino.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+		{Name: "repo1", SharedHost: &v1beta1.RepoSharedHost{Name: "shared"}},
+	}
+
+	configmap := CreateSharedRepoHostConfigMapIntent(context.Background(), host,
+		[]*v1beta1.PostgresCluster{hippo, rhino},
+		map[string][]string{
+			"hippo": {"hippo-00-abcd", "hippo-00-efgh"},
+			"rhino": {"rhino-00-wxyz"},
+		})
+
+	assert.Equal(t, configmap.Name, "shared-shared-repo-config")
+	assert.DeepEqual(t, configmap.Labels, map[string]string{
+		"postgres-operator.crunchydata.com/shared-repo-host": "shared",
+	})
+
+	assert.Equal(t, configmap.Data["pgbackrest-server.conf"], strings.Trim(`
+# Generated by postgres-operator. DO NOT EDIT.
+# Your changes will not be saved.
+
+[global]
+tls-server-address = 0.0.0.0
+tls-server-auth = pgbackrest@hippo-uid=hippo
+tls-server-auth = pgbackrest@rhino-uid=rhino
+tls-server-ca-file = /etc/pgbackrest/conf.d/~postgres-operator/tls-ca.crt
+tls-server-cert-file = /etc/pgbackrest/server/server-tls.crt
+tls-server-key-file = /etc/pgbackrest/server/server-tls.key
+
+[global:server]
+log-level-console = detail
+log-level-file = off
+log-level-stderr = error
+log-timestamp = n
+	`, "\t\n")+"\n")
+
+	assert.Equal(t, configmap.Data["pgbackrest_repo.conf"], strings.Trim(`
+# Generated by postgres-operator. DO NOT EDIT.
+# Your changes will not be saved.
+
+[global]
+log-path = /pgbackrest/shared/log
+
+[hippo]
+pg1-host = hippo-00-abcd-0.hippo-pods.ns1.svc.`+domain+`
+pg1-host-ca-file = /etc/pgbackrest/conf.d/~postgres-operator/tls-ca.crt
+pg1-host-cert-file = /etc/pgbackrest/conf.d/~postgres-operator/client-hippo.crt
+pg1-host-key-file = /etc/pgbackrest/conf.d/~postgres-operator/client-hippo.key
+pg1-host-type = tls
+pg1-path = /pgdata/pg14
+pg1-port = 5432
+pg1-socket-path = /tmp/postgres
+pg2-host = hippo-00-efgh-0.hippo-pods.ns1.svc.`+domain+`
+pg2-host-ca-file = /etc/pgbackrest/conf.d/~postgres-operator/tls-ca.crt
+pg2-host-cert-file = /etc/pgbackrest/conf.d/~postgres-operator/client-hippo.crt
+pg2-host-key-file = /etc/pgbackrest/conf.d/~postgres-operator/client-hippo.key
+pg2-host-type = tls
+pg2-path = /pgdata/pg14
+pg2-port = 5432
+pg2-socket-path = /tmp/postgres
+repo2-path = /pgbackrest/shared
+repo2-retention-diff = 4
+repo2-retention-full = 2
+
+[rhino]
+io-timeout = 90
+pg1-host = rhino-00-wxyz-0.rhino-pods.ns1.svc.`+domain+`
+pg1-host-ca-file = /etc/pgbackrest/conf.d/~postgres-operator/tls-ca.crt
+pg1-host-cert-file = /etc/pgbackrest/conf.d/~postgres-operator/client-rhino.crt
+pg1-host-key-file = /etc/pgbackrest/conf.d/~postgres-operator/client-rhino.key
+pg1-host-type = tls
+pg1-path = /pgdata/pg15
+pg1-port = 2345
+pg1-socket-path = /tmp/postgres
+repo1-path = /pgbackrest/shared
+	`, "\t\n")+"\n")
+}
+
+func TestStanzaName(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Name = "hippo"
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+		{Name: "repo1", Volume: &v1beta1.RepoPVC{}},
+	}
+	assert.Equal(t, StanzaName(cluster), "db")
+
+	cluster.Spec.Backups.PGBackRest.Repos = append(cluster.Spec.Backups.PGBackRest.Repos,
+		v1beta1.PGBackRestRepo{Name: "repo2", SharedHost: &v1beta1.RepoSharedHost{Name: "x"}})
+	assert.Equal(t, StanzaName(cluster), "hippo")
+}
+
+func TestBackupCommandOptions(t *testing.T) {
+	repo := v1beta1.PGBackRestRepo{Name: "repo1"}
+
+	t.Run("Unset", func(t *testing.T) {
+		options, err := BackupCommandOptions(repo, "full")
+		assert.NilError(t, err)
+		assert.Assert(t, options == nil)
+	})
+
+	repo.BackupOptions = &v1beta1.PGBackRestRepoBackupOptions{
+		PGBackRestBackupOptions: v1beta1.PGBackRestBackupOptions{
+			CompressType:  "zst",
+			CompressLevel: initialize.Int32(3),
+		},
+		Full: &v1beta1.PGBackRestBackupOptions{
+			CompressLevel: initialize.Int32(12),
+			ProcessMax:    initialize.Int32(4),
+		},
+	}
+
+	t.Run("Repo", func(t *testing.T) {
+		for _, backupType := range []string{"", "diff", "incr"} {
+			options, err := BackupCommandOptions(repo, backupType)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, options, []string{
+				"--compress-type=zst", "--compress-level=3",
+			})
+		}
+	})
+
+	t.Run("BackupType", func(t *testing.T) {
+		options, err := BackupCommandOptions(repo, "full")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, options, []string{
+			"--compress-type=zst", "--compress-level=12", "--process-max=4",
+		})
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		repo := *repo.DeepCopy()
+		repo.BackupOptions.Options = []string{
+			"--process-max=2", "--exclude=a", "--exclude=b", "--start-fast",
+		}
+		repo.BackupOptions.Full.Options = []string{
+			"--compress-level 5", "--exclude=c", "--start-fast=n",
+		}
+
+		options, err := BackupCommandOptions(repo, "diff")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, options, []string{
+			"--compress-type=zst", "--compress-level=3",
+			"--process-max=2", "--exclude=a", "--exclude=b", "--start-fast",
+		})
+
+		options, err = BackupCommandOptions(repo, "full")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, options, []string{
+			"--compress-type=zst",
+			"--process-max=2",
+			"--compress-level 5", "--exclude=c", "--start-fast=n",
+		})
+
+		for _, opt := range []string{
+			"--repo=2", "--stanza=other", "--type full", "--start-fast --type=diff",
+		} {
+			repo.BackupOptions.Full.Options = []string{opt}
+			_, err = BackupCommandOptions(repo, "full")
+			assert.ErrorContains(t, err, "is not allowed", "%q", opt)
+		}
+	})
+
+	t.Run("InvalidLevel", func(t *testing.T) {
+		repo := *repo.DeepCopy()
+		repo.BackupOptions.Full.CompressType = "gz"
+
+		_, err := BackupCommandOptions(repo, "full")
+		assert.ErrorContains(t, err, `compressLevel 12 is not valid for compressType "gz"`)
+
+		repo.BackupOptions.Full.CompressType = "none"
+		_, err = BackupCommandOptions(repo, "full")
+		assert.ErrorContains(t, err, `compressType "none"`)
+
+		// The default compression type is gz.
+		repo.BackupOptions.CompressType = ""
+		repo.BackupOptions.Full = nil
+		repo.BackupOptions.CompressLevel = initialize.Int32(10)
+		_, err = BackupCommandOptions(repo, "full")
+		assert.ErrorContains(t, err, `compressType ""`)
+	})
+}
+
+func TestValidateGlobalOptions(t *testing.T) {
+	assert.NilError(t, ValidateGlobalOptions(nil))
+	assert.NilError(t, ValidateGlobalOptions(map[string]string{
+		"repo1-path":                "/pgbackrest/repo1",
+		"repo2-retention-full":      "2",
+		"repo3-s3-uri-style":        "path",
+		"repo3-s3-kms-key-id":       "arn:aws:kms:us-east-1:123456789012:key/abc",
+		"repo3-s3-sse-customer-key": "secret",
+		"repo4-gcs-user-project":    "project",
+		"repo4-cipher-type":         "aes-256-cbc",
+		"pg1-database":              "postgres",
+		"process-max":               "4",
+		"log-level-console":         "info",
+		"archive-push-queue-max":    "1GiB",
+		"repo1-retention-full-type": "time",
+		"some-future-option":        "value",
+	}))
+
+	options := map[string]string{
+		"log-path":             "/tmp",
+		"tls-server-port":      "9000",
+		"repo1-host":           "elsewhere",
+		"pg1-path":             "/pgdata",
+		"config-include-path":  "/tmp",
+		"repo1-retention-full": "1",
+	}
+	assert.DeepEqual(t, OwnedGlobalOptions(options), []string{
+		"config-include-path", "log-path", "pg1-path", "repo1-host", "tls-server-port",
+	})
+	assert.Error(t, ValidateGlobalOptions(options), ""+
+		"options managed by PGO cannot be set: config-include-path, log-path, pg1-path, repo1-host, tls-server-port")
+}
+
+func TestValidateWebIdentity(t *testing.T) {
+	assert.NilError(t, ValidateWebIdentity(nil))
+
+	s3 := func(name, keyType, role string) v1beta1.PGBackRestRepo {
+		return v1beta1.PGBackRestRepo{Name: name, S3: &v1beta1.RepoS3{
+			Bucket: "b", KeyType: keyType, RoleARN: role,
+		}}
+	}
+	azure := func(name, client, tenant string) v1beta1.PGBackRestRepo {
+		return v1beta1.PGBackRestRepo{Name: name, Azure: &v1beta1.RepoAzure{
+			Container: "c", KeyType: "auto", ClientID: client, TenantID: tenant,
+		}}
+	}
+
+	assert.NilError(t, ValidateWebIdentity([]v1beta1.PGBackRestRepo{
+		{Name: "repo1", Volume: &v1beta1.RepoPVC{}},
+		s3("repo2", "shared", ""),
+		s3("repo3", "web-id", "arn:aws:iam::123:role/hippo"),
+		s3("repo4", "web-id", "arn:aws:iam::123:role/hippo"),
+		azure("repo5", "", ""),
+		azure("repo6", "client", "tenant"),
+		azure("repo7", "client", "tenant"),
+	}))
+
+	t.Run("MissingRole", func(t *testing.T) {
+		assert.Error(t, ValidateWebIdentity([]v1beta1.PGBackRestRepo{
+			s3("repo1", "web-id", ""),
+		}), "repositories cannot share a web identity: repo1 uses web-id without a roleARN")
+	})
+
+	t.Run("DifferentRoles", func(t *testing.T) {
+		assert.Error(t, ValidateWebIdentity([]v1beta1.PGBackRestRepo{
+			s3("repo1", "web-id", "arn:aws:iam::123:role/hippo"),
+			s3("repo2", "web-id", "arn:aws:iam::123:role/rhino"),
+		}), `repositories cannot share a web identity: `+
+			`repo2 uses roleARN "arn:aws:iam::123:role/rhino", not "arn:aws:iam::123:role/hippo"`)
+	})
+
+	t.Run("Azure", func(t *testing.T) {
+		assert.Error(t, ValidateWebIdentity([]v1beta1.PGBackRestRepo{
+			azure("repo1", "client", ""),
+			azure("repo2", "client", "tenant"),
+			azure("repo3", "other", "tenant"),
+		}), `repositories cannot share a web identity: `+
+			`repo1 requires both clientID and tenantID; `+
+			`repo3 uses a workload identity other than clientID "client" in tenantID "tenant"`)
+	})
+}
+
+func TestValidateRepoPaths(t *testing.T) {
+	s3 := func(name, bucket, path string) v1beta1.PGBackRestRepo {
+		return v1beta1.PGBackRestRepo{Name: name, Path: path, S3: &v1beta1.RepoS3{
+			Bucket: bucket, Endpoint: "e",
+		}}
+	}
+
+	conflicts, err := ValidateRepoPaths([]v1beta1.PGBackRestRepo{
+		{Name: "repo1", Volume: &v1beta1.RepoPVC{}},
+		s3("repo2", "b", ""),
+		s3("repo3", "b", "/hippo"),
+		s3("repo4", "other", "/hippo"),
+	}, nil)
+	assert.NilError(t, err)
+	assert.Assert(t, conflicts == nil)
+
+	t.Run("Volume", func(t *testing.T) {
+		conflicts, err := ValidateRepoPaths([]v1beta1.PGBackRestRepo{
+			{Name: "repo1", Path: "/hippo", Volume: &v1beta1.RepoPVC{}},
+		}, nil)
+		assert.Error(t, err, "invalid repository paths: repo1 is always at /pgbackrest/repo1")
+		assert.Assert(t, conflicts == nil)
+	})
+
+	t.Run("SamePath", func(t *testing.T) {
+		conflicts, err := ValidateRepoPaths([]v1beta1.PGBackRestRepo{
+			s3("repo1", "b", ""),
+			s3("repo2", "b", "/pgbackrest/repo1"),
+			s3("repo3", "b", "/hippo"),
+		}, map[string]string{"repo3-path": "/pgbackrest/repo1"})
+		assert.Error(t, err, "invalid repository paths: "+
+			"repo2 is at the same path as repo1; repo3 is at the same path as repo1")
+		assert.DeepEqual(t, conflicts, []string{"repo2", "repo3"})
+	})
 }
 
 func TestMakePGBackrestLogDir(t *testing.T) {
@@ -297,7 +962,7 @@ func TestRestoreCommand(t *testing.T) {
 	opts := []string{
 		"--stanza=" + DefaultStanzaName, "--pg1-path=" + pgdata,
 		"--repo=1"}
-	command := RestoreCommand(pgdata, nil, strings.Join(opts, " "))
+	command := RestoreCommand(pgdata, true, nil, strings.Join(opts, " "))
 
 	assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
 	assert.Assert(t, len(command) > 3)
@@ -311,8 +976,244 @@ func TestRestoreCommand(t *testing.T) {
 	assert.NilError(t, err, "%q\n%s", cmd.Args, output)
 }
 
+func TestOptionNames(t *testing.T) {
+	assert.DeepEqual(t, OptionNames(nil).List(), []string{})
+	assert.DeepEqual(t, OptionNames([]string{
+		"--type full", "--start-fast --compress-level=3",
+		"--annotation", "key=value", `--exclude="--not-an-option"`,
+	}).List(), []string{
+		"--annotation", "--compress-level", "--exclude", "--start-fast", "--type",
+	})
+}
+
+func TestOptionValue(t *testing.T) {
+	for _, tt := range []struct {
+		options []string
+		value   string
+		found   bool
+	}{
+		{options: nil},
+		{options: []string{"--setting=x"}},
+		{options: []string{"--set=20230101-000000F"}, value: "20230101-000000F", found: true},
+		{options: []string{"--set 20230101-000000F"}, value: "20230101-000000F", found: true},
+		{options: []string{`--set="20230101-000000F"`}, value: "20230101-000000F", found: true},
+		{options: []string{"--set=a", "--set='b'"}, value: "b", found: true},
+		{options: []string{"--type=full --set=20230101-000000F"}, value: "20230101-000000F", found: true},
+		{options: []string{"--set", "20230101-000000F"}, value: "20230101-000000F", found: true},
+		{options: []string{"--set", "--delta"}, value: "", found: true},
+	} {
+		value, found := OptionValue(tt.options, "--set")
+		assert.Equal(t, value, tt.value, "options: %q", tt.options)
+		assert.Equal(t, found, tt.found, "options: %q", tt.options)
+	}
+}
+
+func TestValidateRestoreOptions(t *testing.T) {
+	for _, options := range [][]string{
+		nil,
+		{"--type=immediate"},
+		{"--type=time", `--target="2021-06-09 14:15:11-04"`},
+		{"--type=time", "--target='2021-06-09 14:15:11.123456+00:00'"},
+		{"--type=time", `--target="2021-06-09 14:15:11 America/New_York"`},
+		{"--type=xid", "--target=12345"},
+		{"--type=lsn", "--target=0/3000000"},
+		{"--type=name", "--target=before-upgrade"},
+		{"--set=20230101-000000F_20230102-000000D"},
+		{`--type=time --target="2021-06-09 14:15:11-04"`},
+		{"--type=time", "--target=2021-06-09T14:15:11Z"},
+		{"--type=time", `--target "2021-06-09T14:15:11.5+02:00"`},
+	} {
+		assert.NilError(t, ValidateRestoreOptions(options), "options: %q", options)
+	}
+
+	for _, tt := range []struct {
+		options []string
+		message string
+	}{
+		{[]string{"--type=later"}, `option --type "later" is not a pgBackRest recovery type`},
+		{[]string{"--type=time"}, "option --type=time requires --target"},
+		{[]string{"--target=12345"}, `option --target requires --type to be one of lsn, name, time, or xid; got ""`},
+		{[]string{"--type=time", `--target="June 9th"`}, `option --target "June 9th" is not a valid time`},
+		{[]string{"--type=time", `--target="2021-13-09 14:15:11"`}, `option --target "2021-13-09 14:15:11" is not a valid time`},
+		{[]string{"--type=xid", "--target=abc"}, `option --target "abc" is not a valid xid`},
+		{[]string{"--set=latest"}, `option --set "latest" is not a pgBackRest backup label`},
+		{[]string{"--type=time --target='2021-06-09'"}, `option --target "2021-06-09" is not a valid time`},
+	} {
+		assert.Error(t, ValidateRestoreOptions(tt.options), tt.message, "options: %q", tt.options)
+	}
+}
+
+func TestRestoreDatabaseOptions(t *testing.T) {
+	assert.Equal(t, len(RestoreDatabaseOptions(nil)), 0)
+	assert.DeepEqual(t, RestoreDatabaseOptions([]string{"zoo", "it's"}),
+		[]string{`--db-include='zoo'`, `--db-include='it'"'"'s'`})
+}
+
+func TestRestoreProgressCommand(t *testing.T) {
+	dir := t.TempDir()
+	command := RestoreProgressCommand(dir)
+
+	assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+	assert.Assert(t, len(command) > 3)
+
+	t.Run("ShellCheck", func(t *testing.T) {
+		shellcheck := require.ShellCheck(t)
+
+		file := filepath.Join(t.TempDir(), "script.bash")
+		assert.NilError(t, os.WriteFile(file, []byte(command[3]), 0o600))
+
+		cmd := exec.Command(shellcheck, "--enable=all", file)
+		output, err := cmd.CombinedOutput()
+		assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+	})
+
+	if _, err := exec.LookPath(command[0]); err != nil {
+		t.Skipf("requires %q executable", command[0])
+	}
+	run := func(t *testing.T) string {
+		t.Helper()
+		output, err := exec.Command(command[0], command[1:]...).CombinedOutput()
+		assert.NilError(t, err, "%s", output)
+		return string(output)
+	}
+
+	t.Run("NoLog", func(t *testing.T) {
+		assert.Equal(t, run(t), "")
+	})
+
+	log := filepath.Join(dir, "db-restore.log")
+	assert.NilError(t, os.WriteFile(log, []byte(`
+-------------------PROCESS START-------------------
+P00   INFO: restore command begin 2.41: --delta --log-level-file=detail --stanza=db
+P01 DETAIL: restore file /pgdata/pg14/base/1/1249 (1MB, 99.00%) checksum aaa
+P00   INFO: restore command end: completed successfully (1000ms)
+
+-------------------PROCESS START-------------------
+P00   INFO: restore command begin 2.41: --delta --log-level-file=detail --stanza=db
+P01 DETAIL: restore file /pgdata/pg14/base/1/1249 (440KB, 12.34%) checksum bbb
+P01 DETAIL: restore file /pgdata/pg14/base/1/1255 (bundle 1/0, 1.5KB, 12.40%) checksum ccc
+P02 DETAIL: restore zeroed file /pgdata/pg14/base/16384/1259 (8KB, 12.50%)
+P02 DETAIL: restore file /pgdata/pg14/base/1/1259 - exists and matches backup (2MB, 50.10%) checksum ddd
+`), 0o600))
+
+	t.Run("Restoring", func(t *testing.T) {
+		// Files that match the backup or are zeroed are not counted as restored.
+		assert.Equal(t, run(t), fmt.Sprintf("Restoring 50.10 %d\n", 440*1024+1536))
+	})
+
+	f, err := os.OpenFile(log, os.O_APPEND|os.O_WRONLY, 0)
+	assert.NilError(t, err)
+	_, err = f.WriteString("P00   INFO: restore command end: completed successfully (5000ms)\n")
+	assert.NilError(t, err)
+	assert.NilError(t, f.Close())
+
+	t.Run("Recovering", func(t *testing.T) {
+		assert.Equal(t, run(t), fmt.Sprintf("Recovering 50.10 %d\n", 440*1024+1536))
+	})
+
+	t.Run("NotDetailed", func(t *testing.T) {
+		assert.NilError(t, os.WriteFile(log, []byte(`
+-------------------PROCESS START-------------------
+P00   INFO: restore command begin 2.41: --delta --log-level-file=detail --stanza=db
+P01 DETAIL: restore file /pgdata/pg14/base/1/1249 (1MB, 99.00%) checksum aaa
+P00   INFO: restore command end: completed successfully (1000ms)
+
+-------------------PROCESS START-------------------
+P00   INFO: restore command begin 2.41: --delta --stanza=db
+`), 0o600))
+
+		assert.Equal(t, run(t), "")
+	})
+}
+
+func TestRestoreCheckCommand(t *testing.T) {
+	command := RestoreCheckCommand([]string{"--type=time", `--target="2022-01-03 00:00:00+00"`},
+		"--stanza=db", "--repo=1")
+
+	assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+	assert.DeepEqual(t, command[4:], []string{
+		"-", "", "2022-01-03 00:00:00+00", "--stanza=db", "--repo=1",
+	})
+
+	t.Run("ShellCheck", func(t *testing.T) {
+		shellcheck := require.ShellCheck(t)
+
+		file := filepath.Join(t.TempDir(), "script.bash")
+		assert.NilError(t, os.WriteFile(file, []byte(command[3]), 0o600))
+
+		cmd := exec.Command(shellcheck, "--enable=all", file)
+		output, err := cmd.CombinedOutput()
+		assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+	})
+
+	if _, err := exec.LookPath(command[0]); err != nil {
+		t.Skipf("requires %q executable", command[0])
+	}
+
+	// Replace pgbackrest with a script that prints the JSON of two full backups.
+	// The WAL of the first one has expired.
+	bin := t.TempDir()
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	assert.NilError(t, os.WriteFile(filepath.Join(bin, "pgbackrest"), []byte(`#!/bin/sh
+echo '[{"archive":[{"database":{"id":1,"repo-key":1},"id":"14-1","max":"000000010000000000000009","min":"000000010000000000000003"}],"backup":[`+
+		`{"archive":{"start":"000000010000000000000002","stop":"000000010000000000000002"},"database":{"id":1,"repo-key":1},"label":"20220101-000000F","prior":null,"timestamp":{"start":1640995200,"stop":1640995260},"type":"full"},`+
+		`{"archive":{"start":"000000010000000000000005","stop":"000000010000000000000005"},"database":{"id":1,"repo-key":1},"label":"20220102-000000F","prior":null,"timestamp":{"start":1641081600,"stop":1641081660},"type":"full"}`+
+		`],"cipher":"none","name":"db","status":{"code":0,"message":"ok"}}]'
+`), 0o700))
+
+	run := func(t *testing.T, options ...string) (string, error) {
+		t.Helper()
+		command := RestoreCheckCommand(options, "--stanza=db")
+		output, err := exec.Command(command[0], command[1:]...).CombinedOutput()
+		return strings.TrimSpace(string(output)), err
+	}
+
+	for _, tt := range []struct {
+		options []string
+		output  string
+		passed  bool
+	}{
+		{nil, "backup set 20220102-000000F and the WAL it needs are in the repository", true},
+		{[]string{"--set=20220102-000000F"}, "backup set 20220102-000000F and the WAL it needs are in the repository", true},
+		{[]string{"--set=20220101-000000F"}, "the WAL needed by backup set 20220101-000000F is no longer in the repository", false},
+		{[]string{"--set=20211231-000000F"}, "backup set 20211231-000000F does not exist in the repository", false},
+		{[]string{"--type=time", `--target="2022-01-03 00:00:00+00"`}, "backup set 20220102-000000F and the WAL it needs are in the repository", true},
+		{[]string{"--type=time", `--target="2022-01-02 00:00:00+00"`}, "the WAL needed by backup set 20220101-000000F is no longer in the repository", false},
+		{[]string{"--type=time", `--target="2021-12-01 00:00:00+00"`}, "no backup in the repository finished before 2021-12-01 00:00:00+00", false},
+	} {
+		output, err := run(t, tt.options...)
+		assert.Equal(t, output, tt.output, "options: %q", tt.options)
+		assert.Equal(t, err == nil, tt.passed, "options: %q", tt.options)
+	}
+}
+
+func TestRestoreCommandDelta(t *testing.T) {
+	tablespaces := []*corev1.PersistentVolumeClaim{{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{naming.LabelData: "trial"},
+		},
+	}}
+
+	t.Run("Delta", func(t *testing.T) {
+		command := RestoreCommand("/pgdata/pg13", true, tablespaces, "--delta")
+		assert.Assert(t, !strings.Contains(command[3], "rm -rf"))
+		assert.Assert(t, strings.Contains(command[3], "install --directory --mode=0700 '/tablespaces/trial/data'"))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		command := RestoreCommand("/pgdata/pg13", false, tablespaces, "--repo=1")
+		assert.Assert(t, strings.Contains(command[3], `
+[ ! -L "${pgdata}/pg_wal" ] || rm -rf "$(readlink "${pgdata}/pg_wal")"
+rm -rf "${pgdata}"
+rm -rf '/tablespaces/trial/data'
+install --directory --mode=0700 "${pgdata}"
+install --directory --mode=0700 '/tablespaces/trial/data'
+`), "got:\n%s", command[3])
+	})
+}
+
 func TestRestoreCommandPrettyYAML(t *testing.T) {
-	b, err := yaml.Marshal(RestoreCommand("/dir", nil, "--options"))
+	b, err := yaml.Marshal(RestoreCommand("/dir", true, nil, "--options"))
 	assert.NilError(t, err)
 	assert.Assert(t, strings.Contains(string(b), "\n- |"),
 		"expected literal block scalar, got:\n%s", b)
@@ -0,0 +1,103 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pgbackrest
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CMIncludeKey is the name of the pgBackRest configuration file that holds the files
+// of spec.backups.pgbackrest.configInclude. pgBackRest reads it after the files PGO
+// generates because it sorts after them.
+const CMIncludeKey = "pgbackrest_user.conf"
+
+// ConfigInclude returns the pgBackRest configuration files in include, the data of a
+// ConfigMap, as one file in the order of their names. The names must end in ".conf",
+// and no option may be set by more than one of them or by any of the generated files.
+// pgBackRest reads every file in its configuration directory, so an option in two files
+// is ambiguous at best.
+// - https://pgbackrest.org/configuration.html#section-general/option-config-include-path
+func ConfigInclude(generated, include map[string]string) (string, error) {
+	owner := make(map[string]string)
+	for _, name := range []string{CMInstanceKey, CMRepoKey, serverConfigMapKey} {
+		options, err := iniOptions(generated[name])
+		if err != nil {
+			return "", errors.Wrapf(err, "PGO generated %q", name)
+		}
+		for _, option := range options {
+			owner[option] = "PGO"
+		}
+	}
+
+	names := make([]string, 0, len(include))
+	for name := range include {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".conf") {
+			return "", errors.Errorf("%q does not end in \".conf\"", name)
+		}
+
+		options, err := iniOptions(include[name])
+		if err != nil {
+			return "", errors.Wrapf(err, "%q", name)
+		}
+		for _, option := range options {
+			if other, ok := owner[option]; ok && other != name {
+				return "", errors.Errorf("%q sets %s, which is also set by %s", name, option, other)
+			}
+			owner[option] = name
+		}
+
+		_, _ = fmt.Fprintf(&b, "\n# %s\n%s\n", name, strings.TrimSpace(include[name]))
+	}
+
+	if b.Len() == 0 {
+		return "", nil
+	}
+	return iniGeneratedWarning + b.String(), nil
+}
+
+// iniOptions returns the options set in text, a pgBackRest configuration file, as
+// "[section] option". It returns an error when an option is not in a section.
+func iniOptions(text string) ([]string, error) {
+	var options []string
+	var section string
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+		case section == "":
+			return nil, errors.Errorf("option %q is not in a section", line)
+		default:
+			option, _, _ := strings.Cut(line, "=")
+			options = append(options, "["+section+"] "+strings.TrimSpace(option))
+		}
+	}
+	return options, errors.WithStack(scanner.Err())
+}
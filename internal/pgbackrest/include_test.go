@@ -0,0 +1,100 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pgbackrest
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestConfigInclude(t *testing.T) {
+	generated := map[string]string{
+		CMInstanceKey: iniGeneratedWarning + `
+[global]
+log-path = /pgdata/pgbackrest/log
+repo1-path = /pgbackrest/repo1
+
+[db]
+pg1-path = /pgdata/pg14
+`,
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		text, err := ConfigInclude(generated, nil)
+		assert.NilError(t, err)
+		assert.Equal(t, text, "")
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		text, err := ConfigInclude(generated, map[string]string{
+			"b.conf": "[global]\narchive-get-queue-max = 1GiB\n",
+			"a.conf": "# compression\n[global]\ncompress-level=3\n\n[db]\n; comment\npg1-user = postgres\n",
+		})
+		assert.NilError(t, err)
+		assert.Assert(t, strings.HasPrefix(text, iniGeneratedWarning))
+		assert.Equal(t, strings.TrimPrefix(text, iniGeneratedWarning), `
+# a.conf
+# compression
+[global]
+compress-level=3
+
+[db]
+; comment
+pg1-user = postgres
+
+# b.conf
+[global]
+archive-get-queue-max = 1GiB
+`)
+	})
+
+	t.Run("Name", func(t *testing.T) {
+		_, err := ConfigInclude(generated, map[string]string{
+			"extra.ini": "[global]\ncompress-level = 3\n",
+		})
+		assert.ErrorContains(t, err, `"extra.ini" does not end in ".conf"`)
+	})
+
+	t.Run("NoSection", func(t *testing.T) {
+		_, err := ConfigInclude(generated, map[string]string{
+			"a.conf": "compress-level = 3\n",
+		})
+		assert.ErrorContains(t, err, `"a.conf": option "compress-level = 3" is not in a section`)
+	})
+
+	t.Run("Generated", func(t *testing.T) {
+		_, err := ConfigInclude(generated, map[string]string{
+			"a.conf": "[global]\nrepo1-path=/elsewhere\n",
+		})
+		assert.ErrorContains(t, err, `"a.conf" sets [global] repo1-path, which is also set by PGO`)
+
+		// The same option in another section is fine.
+		_, err = ConfigInclude(generated, map[string]string{
+			"a.conf": "[db]\nrepo1-path=/elsewhere\n",
+		})
+		assert.NilError(t, err)
+	})
+
+	t.Run("Collision", func(t *testing.T) {
+		_, err := ConfigInclude(generated, map[string]string{
+			"a.conf": "[global]\ncompress-level = 3\n",
+			"b.conf": "[global]\ncompress-level = 6\n",
+		})
+		assert.ErrorContains(t, err, `"b.conf" sets [global] compress-level, which is also set by a.conf`)
+	})
+}
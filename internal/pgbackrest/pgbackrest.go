@@ -18,9 +18,13 @@ package pgbackrest
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -50,7 +54,7 @@ type Executor func(
 // mismatch was identified that prevented the pgBackRest stanza-create or stanza-upgrade command
 // from running (with a config mismatch indicating that the pgBackRest configuration as stored in
 // the cluster's pgBackRest ConfigMap has not yet propagated to the Pod).
-func (exec Executor) StanzaCreateOrUpgrade(ctx context.Context, configHash string,
+func (exec Executor) StanzaCreateOrUpgrade(ctx context.Context, stanza, configHash string,
 	upgrade bool) (bool, error) {
 
 	var stdout, stderr bytes.Buffer
@@ -74,7 +78,7 @@ else
 fi
 `
 	if err := exec(ctx, nil, &stdout, &stderr, "bash", "-ceu", "--",
-		script, "-", configHash, DefaultStanzaName, errMsgConfigHashMismatch,
+		script, "-", configHash, stanza, errMsgConfigHashMismatch,
 		fmt.Sprintf("stanza-%s", stanzaCmd)); err != nil {
 
 		errReturn := stderr.String()
@@ -89,7 +93,7 @@ fi
 		// if the err returned from pgbackrest command is about a version mismatch
 		// then we should run upgrade rather than create
 		if strings.Contains(errReturn, errMsgBackupDbMismatch) {
-			return exec.StanzaCreateOrUpgrade(ctx, configHash, true)
+			return exec.StanzaCreateOrUpgrade(ctx, stanza, configHash, true)
 		}
 
 		// if none of the above errors, return the err
@@ -98,3 +102,345 @@ fi
 
 	return false, nil
 }
+
+// CurrentBackupExists runs the pgBackRest "info" command and reports whether stanza in the
+// repository named repoName contains a backup of the current PostgreSQL database. Backups taken
+// prior to a major upgrade belong to a previous database and are not considered.
+func (exec Executor) CurrentBackupExists(ctx context.Context, stanza, repoName string) (bool, error) {
+	var stdout, stderr bytes.Buffer
+
+	if err := exec(ctx, nil, &stdout, &stderr, "pgbackrest", "info",
+		"--stanza="+stanza, "--repo="+strings.TrimPrefix(repoName, "repo"),
+		"--output=json"); err != nil {
+		return false, errors.WithStack(fmt.Errorf("%w: %v", err, stderr.String()))
+	}
+
+	// The "db" section lists every database the stanza has ever backed up. The
+	// current one has the largest ID.
+	// - https://pgbackrest.org/command.html#command-info
+	var stanzas []struct {
+		Backup []struct {
+			Database struct {
+				ID int `json:"id"`
+			} `json:"database"`
+		} `json:"backup"`
+		DB []struct {
+			ID int `json:"id"`
+		} `json:"db"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &stanzas); err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	for _, stanza := range stanzas {
+		current := 0
+		for _, db := range stanza.DB {
+			if db.ID > current {
+				current = db.ID
+			}
+		}
+		for _, backup := range stanza.Backup {
+			if backup.Database.ID == current {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// StanzaSystemIdentifier runs the pgBackRest "info" command and returns the system identifier
+// of the current PostgreSQL database of stanza in the repository named repoName. It returns
+// an empty string when the stanza does not exist in the repository.
+func (exec Executor) StanzaSystemIdentifier(ctx context.Context, stanza, repoName string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	if err := exec(ctx, nil, &stdout, &stderr, "pgbackrest", "info",
+		"--stanza="+stanza, "--repo="+strings.TrimPrefix(repoName, "repo"),
+		"--output=json"); err != nil {
+		return "", errors.WithStack(fmt.Errorf("%w: %v", err, stderr.String()))
+	}
+
+	// The "db" section is empty when the stanza does not exist. Otherwise, the
+	// current database has the largest ID.
+	// - https://pgbackrest.org/command.html#command-info
+	var stanzas []struct {
+		DB []struct {
+			ID       int    `json:"id"`
+			SystemID uint64 `json:"system-id"`
+		} `json:"db"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &stanzas); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var current struct {
+		ID       int
+		SystemID uint64
+	}
+	for _, stanza := range stanzas {
+		for _, db := range stanza.DB {
+			if db.ID > current.ID {
+				current.ID, current.SystemID = db.ID, db.SystemID
+			}
+		}
+	}
+	if current.ID == 0 {
+		return "", nil
+	}
+	return strconv.FormatUint(current.SystemID, 10), nil
+}
+
+// BackupLabels runs the pgBackRest "info" command and returns the labels of the backups of
+// stanza in the repository named repoName, oldest first.
+func (exec Executor) BackupLabels(ctx context.Context, stanza, repoName string) ([]string, error) {
+	var stdout, stderr bytes.Buffer
+
+	if err := exec(ctx, nil, &stdout, &stderr, "pgbackrest", "info",
+		"--stanza="+stanza, "--repo="+strings.TrimPrefix(repoName, "repo"),
+		"--output=json"); err != nil {
+		return nil, errors.WithStack(fmt.Errorf("%w: %v", err, stderr.String()))
+	}
+
+	// - https://pgbackrest.org/command.html#command-info
+	var stanzas []struct {
+		Backup []struct {
+			Label string `json:"label"`
+		} `json:"backup"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &stanzas); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var labels []string
+	for _, stanza := range stanzas {
+		for _, backup := range stanza.Backup {
+			labels = append(labels, backup.Label)
+		}
+	}
+	return labels, nil
+}
+
+// RepoInfo is what the pgBackRest "info" command reports about the backups of
+// the current PostgreSQL database in one repository.
+type RepoInfo struct {
+	// LastBackupTime is when the newest backup of each type finished, keyed by
+	// pgBackRest backup type: "full", "diff", or "incr".
+	LastBackupTime map[string]time.Time
+
+	// LastBackupSize is the size in bytes of the newest backup in the
+	// repository, after compression. It is nil when there are no backups.
+	LastBackupSize *int64
+
+	// ArchiveMin and ArchiveMax are the oldest and newest WAL files in the
+	// repository.
+	ArchiveMin, ArchiveMax string
+
+	// BackupsSize is the total size in bytes of the backups in the repository,
+	// after compression. It does not include WAL.
+	BackupsSize int64
+
+	// Backups are the backups in the repository, oldest first.
+	Backups []BackupInfo
+}
+
+// BackupInfo is what the pgBackRest "info" command reports about one backup.
+type BackupInfo struct {
+	Label string
+
+	// Type is the pgBackRest backup type: "full", "diff", or "incr".
+	Type string
+
+	StartTime, StopTime time.Time
+
+	// Size is the size in bytes of the backup in the repository, after
+	// compression. This includes any backups it depends on.
+	Size int64
+
+	// ArchiveStart and ArchiveStop are the first and last WAL files needed to
+	// make the backup consistent.
+	ArchiveStart, ArchiveStop string
+}
+
+// RecoveryWindow is a range of time, inclusive, to which a repository can restore.
+type RecoveryWindow struct {
+	Start, End time.Time
+}
+
+// RecoveryWindows returns the ranges of time to which the backups and WAL in the repository
+// can restore, oldest first. Every backup can restore to when it finished. When the WAL it
+// needs is still in the repository, it can also restore to any time after that up to
+// archivedUntil. When archivedUntil is before the newest backup finished, e.g. because it is
+// not known, that time is used instead.
+//
+// NOTE: pgBackRest reports only the oldest and newest WAL files, so gaps in WAL, such as
+// while archiving is paused, are not seen here.
+func (info RepoInfo) RecoveryWindows(archivedUntil time.Time) []RecoveryWindow {
+	end := archivedUntil
+	for _, backup := range info.Backups {
+		if backup.StopTime.After(end) {
+			end = backup.StopTime
+		}
+	}
+
+	var windows []RecoveryWindow
+	for _, backup := range info.Backups {
+		// Backups are listed oldest first, so the WAL of every backup after
+		// this one is in the repository, too.
+		if info.ArchiveMin != "" && backup.ArchiveStart >= info.ArchiveMin {
+			return append(windows, RecoveryWindow{Start: backup.StopTime, End: end})
+		}
+		windows = append(windows, RecoveryWindow{Start: backup.StopTime, End: backup.StopTime})
+	}
+	return windows
+}
+
+// RepoInfo runs the pgBackRest "info" command and returns what it reports about stanza in
+// the repository named repoName. Backups taken prior to a major upgrade belong to a previous
+// database and are not considered.
+func (exec Executor) RepoInfo(ctx context.Context, stanza, repoName string) (*RepoInfo, error) {
+	var stdout, stderr bytes.Buffer
+
+	if err := exec(ctx, nil, &stdout, &stderr, "pgbackrest", "info",
+		"--stanza="+stanza, "--repo="+strings.TrimPrefix(repoName, "repo"),
+		"--output=json"); err != nil {
+		return nil, errors.WithStack(fmt.Errorf("%w: %v", err, stderr.String()))
+	}
+
+	// - https://pgbackrest.org/command.html#command-info
+	var stanzas []struct {
+		Archive []struct {
+			Database struct {
+				ID int `json:"id"`
+			} `json:"database"`
+			Min string `json:"min"`
+			Max string `json:"max"`
+		} `json:"archive"`
+		Backup []struct {
+			Archive struct {
+				Start string `json:"start"`
+				Stop  string `json:"stop"`
+			} `json:"archive"`
+			Database struct {
+				ID int `json:"id"`
+			} `json:"database"`
+			Info struct {
+				Repository struct {
+					Delta int64 `json:"delta"`
+					Size  int64 `json:"size"`
+				} `json:"repository"`
+			} `json:"info"`
+			Label     string `json:"label"`
+			Timestamp struct {
+				Start int64 `json:"start"`
+				Stop  int64 `json:"stop"`
+			} `json:"timestamp"`
+			Type string `json:"type"`
+		} `json:"backup"`
+		DB []struct {
+			ID int `json:"id"`
+		} `json:"db"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &stanzas); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	info := &RepoInfo{LastBackupTime: make(map[string]time.Time)}
+	for _, stanza := range stanzas {
+		current := 0
+		for _, db := range stanza.DB {
+			if db.ID > current {
+				current = db.ID
+			}
+		}
+		for _, archive := range stanza.Archive {
+			if archive.Database.ID == current {
+				info.ArchiveMin = archive.Min
+				info.ArchiveMax = archive.Max
+			}
+		}
+
+		// Backups are listed oldest first. The delta of each is what it adds
+		// to the backups it depends on.
+		for _, backup := range stanza.Backup {
+			if backup.Database.ID == current {
+				size := backup.Info.Repository.Size
+				info.LastBackupSize = &size
+				info.LastBackupTime[backup.Type] = time.Unix(backup.Timestamp.Stop, 0).UTC()
+				info.BackupsSize += backup.Info.Repository.Delta
+				info.Backups = append(info.Backups, BackupInfo{
+					Label:        backup.Label,
+					Type:         backup.Type,
+					StartTime:    time.Unix(backup.Timestamp.Start, 0).UTC(),
+					StopTime:     time.Unix(backup.Timestamp.Stop, 0).UTC(),
+					Size:         size,
+					ArchiveStart: backup.Archive.Start,
+					ArchiveStop:  backup.Archive.Stop,
+				})
+			}
+		}
+	}
+	return info, nil
+}
+
+// Check runs the pgBackRest "check" command for stanza. It switches to a new WAL file and
+// waits for that file to reach every repository, so it fails when WAL is not being archived.
+// - https://pgbackrest.org/command.html#command-check
+func (exec Executor) Check(ctx context.Context, stanza string) error {
+	var stdout, stderr bytes.Buffer
+
+	if err := exec(ctx, nil, &stdout, &stderr, "pgbackrest", "check",
+		"--stanza="+stanza); err != nil {
+		return errors.WithStack(fmt.Errorf("%w: %v", err, strings.TrimSpace(stderr.String())))
+	}
+	return nil
+}
+
+// ExpireResult is what the pgBackRest "expire" command removes from one repository.
+type ExpireResult struct {
+	// Backups are the labels of the backups that are removed.
+	Backups []string
+
+	// Archive are the ranges of WAL files that are removed, each the first and
+	// last file separated by a hyphen.
+	Archive []string
+}
+
+var (
+	// expireBackup and expireArchive match what the "expire" command logs at
+	// the "info" level as it removes backups and WAL files, e.g.
+	//
+	//	P00   INFO: [DRY-RUN] repo1: remove expired backup 20230101-000000F
+	//	P00   INFO: [DRY-RUN] repo1: 14-1 remove archive, start = 000000010000000000000001, stop = 000000010000000000000004
+	expireBackup  = regexp.MustCompile(`remove expired backup (\S+)`)
+	expireArchive = regexp.MustCompile(`remove archive, start = ([^,\s]+), stop = (\S+)`)
+)
+
+// ExpireDryRun runs the pgBackRest "expire" command with "--dry-run" for stanza in the
+// repository named repoName and returns what it would remove. Nothing is removed. Options,
+// e.g. retention settings, are appended to the command.
+// - https://pgbackrest.org/command.html#command-expire
+func (exec Executor) ExpireDryRun(ctx context.Context, stanza, repoName string,
+	options ...string) (*ExpireResult, error) {
+	var stdout, stderr bytes.Buffer
+
+	command := []string{"pgbackrest", "expire", "--dry-run",
+		"--stanza=" + stanza, "--repo=" + strings.TrimPrefix(repoName, "repo"),
+		"--log-level-console=info"}
+	command = append(command, options...)
+
+	if err := exec(ctx, nil, &stdout, &stderr, command...); err != nil {
+		return nil, errors.WithStack(fmt.Errorf("%w: %v", err, strings.TrimSpace(stderr.String())))
+	}
+
+	result := &ExpireResult{}
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if match := expireBackup.FindStringSubmatch(line); match != nil {
+			result.Backups = append(result.Backups, match[1])
+		}
+		if match := expireArchive.FindStringSubmatch(line); match != nil {
+			result.Archive = append(result.Archive, match[1]+"-"+match[2])
+		}
+	}
+	return result, nil
+}
@@ -17,13 +17,16 @@ package pgbackrest
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 )
@@ -57,7 +60,7 @@ fi
 		return nil
 	}
 
-	configHashMismatch, err := Executor(stanzaExec).StanzaCreateOrUpgrade(ctx, configHash, false)
+	configHashMismatch, err := Executor(stanzaExec).StanzaCreateOrUpgrade(ctx, "db", configHash, false)
 	assert.NilError(t, err)
 	assert.Assert(t, !configHashMismatch)
 
@@ -72,3 +75,342 @@ fi
 	output, err := cmd.CombinedOutput()
 	assert.NilError(t, err, "%q\n%s", cmd.Args, output)
 }
+
+func TestCurrentBackupExists(t *testing.T) {
+	ctx := context.Background()
+
+	output := func(stdout string) Executor {
+		return func(ctx context.Context, stdin io.Reader, out, _ io.Writer,
+			command ...string) error {
+			assert.DeepEqual(t, command, []string{
+				"pgbackrest", "info", "--stanza=db", "--repo=2", "--output=json",
+			})
+			_, err := io.WriteString(out, stdout)
+			return err
+		}
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		exec := func(ctx context.Context, _ io.Reader, _, stderr io.Writer, _ ...string) error {
+			_, _ = io.WriteString(stderr, "some message")
+			return errors.New("boom")
+		}
+
+		_, err := Executor(exec).CurrentBackupExists(ctx, "db", "repo2")
+		assert.ErrorContains(t, err, "boom")
+		assert.Assert(t, cmp.ErrorContains(err, "some message"))
+	})
+
+	t.Run("NoBackups", func(t *testing.T) {
+		exists, err := output(`[{"backup":[],"db":[{"id":1}],"name":"db"}]`).
+			CurrentBackupExists(ctx, "db", "repo2")
+		assert.NilError(t, err)
+		assert.Assert(t, !exists)
+	})
+
+	t.Run("PreviousDatabase", func(t *testing.T) {
+		exists, err := output(`[{
+			"backup":[{"database":{"id":1,"repo-key":2},"label":"20230101-000000F"}],
+			"db":[{"id":1},{"id":2}],"name":"db"
+		}]`).CurrentBackupExists(ctx, "db", "repo2")
+		assert.NilError(t, err)
+		assert.Assert(t, !exists)
+	})
+
+	t.Run("CurrentDatabase", func(t *testing.T) {
+		exists, err := output(`[{
+			"backup":[
+				{"database":{"id":1,"repo-key":2},"label":"20230101-000000F"},
+				{"database":{"id":2,"repo-key":2},"label":"20230202-000000F"}
+			],
+			"db":[{"id":1},{"id":2}],"name":"db"
+		}]`).CurrentBackupExists(ctx, "db", "repo2")
+		assert.NilError(t, err)
+		assert.Assert(t, exists)
+	})
+}
+
+func TestStanzaSystemIdentifier(t *testing.T) {
+	ctx := context.Background()
+
+	output := func(stdout string) Executor {
+		return func(ctx context.Context, stdin io.Reader, out, _ io.Writer,
+			command ...string) error {
+			assert.DeepEqual(t, command, []string{
+				"pgbackrest", "info", "--stanza=db", "--repo=2", "--output=json",
+			})
+			_, err := io.WriteString(out, stdout)
+			return err
+		}
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		exec := func(ctx context.Context, _ io.Reader, _, stderr io.Writer, _ ...string) error {
+			_, _ = io.WriteString(stderr, "some message")
+			return errors.New("boom")
+		}
+
+		_, err := Executor(exec).StanzaSystemIdentifier(ctx, "db", "repo2")
+		assert.ErrorContains(t, err, "boom")
+		assert.Assert(t, cmp.ErrorContains(err, "some message"))
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		id, err := output(`[{
+			"backup":[],"db":[],"name":"db",
+			"status":{"code":1,"message":"missing stanza path"}
+		}]`).StanzaSystemIdentifier(ctx, "db", "repo2")
+		assert.NilError(t, err)
+		assert.Equal(t, id, "")
+	})
+
+	t.Run("CurrentDatabase", func(t *testing.T) {
+		id, err := output(`[{
+			"backup":[],"name":"db",
+			"db":[
+				{"id":2,"repo-key":2,"system-id":7208476291367340075,"version":"15"},
+				{"id":1,"repo-key":2,"system-id":7106528346893520946,"version":"14"}
+			]
+		}]`).StanzaSystemIdentifier(ctx, "db", "repo2")
+		assert.NilError(t, err)
+		assert.Equal(t, id, "7208476291367340075")
+	})
+}
+
+func TestBackupLabels(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Error", func(t *testing.T) {
+		exec := func(ctx context.Context, _ io.Reader, _, stderr io.Writer, _ ...string) error {
+			_, _ = io.WriteString(stderr, "some message")
+			return errors.New("boom")
+		}
+
+		_, err := Executor(exec).BackupLabels(ctx, "db", "repo2")
+		assert.ErrorContains(t, err, "boom")
+		assert.Assert(t, cmp.ErrorContains(err, "some message"))
+	})
+
+	t.Run("Labels", func(t *testing.T) {
+		exec := func(ctx context.Context, _ io.Reader, stdout, _ io.Writer, command ...string) error {
+			assert.DeepEqual(t, command, []string{
+				"pgbackrest", "info", "--stanza=hippo", "--repo=2", "--output=json",
+			})
+			_, err := io.WriteString(stdout, `[{
+				"backup":[
+					{"label":"20230101-000000F"},
+					{"label":"20230101-000000F_20230102-000000I"}
+				],
+				"db":[{"id":1}],"name":"hippo"
+			}]`)
+			return err
+		}
+
+		labels, err := Executor(exec).BackupLabels(ctx, "hippo", "repo2")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, labels, []string{
+			"20230101-000000F", "20230101-000000F_20230102-000000I",
+		})
+	})
+}
+
+func TestRepoInfo(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Error", func(t *testing.T) {
+		exec := func(ctx context.Context, _ io.Reader, _, stderr io.Writer, _ ...string) error {
+			_, _ = io.WriteString(stderr, "some message")
+			return errors.New("boom")
+		}
+
+		_, err := Executor(exec).RepoInfo(ctx, "db", "repo2")
+		assert.ErrorContains(t, err, "boom")
+		assert.Assert(t, cmp.ErrorContains(err, "some message"))
+	})
+
+	output := func(stdout string) Executor {
+		return func(ctx context.Context, stdin io.Reader, out, _ io.Writer,
+			command ...string) error {
+			assert.DeepEqual(t, command, []string{
+				"pgbackrest", "info", "--stanza=db", "--repo=2", "--output=json",
+			})
+			_, err := io.WriteString(out, stdout)
+			return err
+		}
+	}
+
+	t.Run("NoBackups", func(t *testing.T) {
+		info, err := output(`[{"archive":[],"backup":[],"db":[{"id":1}],"name":"db"}]`).
+			RepoInfo(ctx, "db", "repo2")
+		assert.NilError(t, err)
+		assert.Assert(t, info.LastBackupSize == nil)
+		assert.Equal(t, len(info.LastBackupTime), 0)
+		assert.Equal(t, info.ArchiveMax, "")
+		assert.Equal(t, len(info.Backups), 0)
+		assert.Equal(t, info.BackupsSize, int64(0))
+	})
+
+	t.Run("Backups", func(t *testing.T) {
+		info, err := output(`[{
+			"archive":[
+				{"database":{"id":1},"min":"000000010000000000000001","max":"000000010000000000000009"},
+				{"database":{"id":2},"min":"000000010000000000000002","max":"000000010000000000000005"}
+			],
+			"backup":[
+				{"database":{"id":1},"label":"20230101-000000F","type":"full","timestamp":{"start":1672531100,"stop":1672531200},"info":{"repository":{"delta":900,"size":900}}},
+				{"database":{"id":2},"label":"20230102-000000F","type":"full","timestamp":{"start":1672617500,"stop":1672617600},"info":{"repository":{"delta":1000,"size":1000}},
+				 "archive":{"start":"000000010000000000000002","stop":"000000010000000000000002"}},
+				{"database":{"id":2},"label":"20230102-000000F_20230102-010000D","type":"diff","timestamp":{"stop":1672621200},"info":{"repository":{"delta":300,"size":400}}},
+				{"database":{"id":2},"label":"20230102-000000F_20230102-020000I","type":"incr","timestamp":{"stop":1672624800},"info":{"repository":{"delta":100,"size":200}}}
+			],
+			"db":[{"id":1},{"id":2}],"name":"db"
+		}]`).RepoInfo(ctx, "db", "repo2")
+		assert.NilError(t, err)
+		assert.Equal(t, info.ArchiveMax, "000000010000000000000005")
+		assert.Assert(t, info.LastBackupSize != nil)
+		assert.Equal(t, *info.LastBackupSize, int64(200))
+		assert.Equal(t, info.LastBackupTime["full"].Format(time.RFC3339), "2023-01-02T00:00:00Z")
+		assert.Equal(t, info.LastBackupTime["diff"].Format(time.RFC3339), "2023-01-02T01:00:00Z")
+		assert.Equal(t, info.LastBackupTime["incr"].Format(time.RFC3339), "2023-01-02T02:00:00Z")
+
+		assert.Equal(t, info.ArchiveMin, "000000010000000000000002")
+		assert.Equal(t, info.BackupsSize, int64(1400))
+		assert.Equal(t, len(info.Backups), 3)
+		assert.DeepEqual(t, info.Backups[0], BackupInfo{
+			Label:        "20230102-000000F",
+			Type:         "full",
+			StartTime:    time.Date(2023, time.January, 1, 23, 58, 20, 0, time.UTC),
+			StopTime:     time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC),
+			Size:         1000,
+			ArchiveStart: "000000010000000000000002",
+			ArchiveStop:  "000000010000000000000002",
+		})
+		assert.Equal(t, info.Backups[2].Label, "20230102-000000F_20230102-020000I")
+	})
+}
+
+func TestRecoveryWindows(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2023, time.January, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	t.Run("NoBackups", func(t *testing.T) {
+		info := RepoInfo{ArchiveMin: "000000010000000000000001"}
+		assert.Assert(t, info.RecoveryWindows(at(9)) == nil)
+	})
+
+	t.Run("ExpiredWAL", func(t *testing.T) {
+		info := RepoInfo{
+			ArchiveMin: "000000010000000000000005",
+			Backups: []BackupInfo{
+				{StopTime: at(1), ArchiveStart: "000000010000000000000002"},
+				{StopTime: at(3), ArchiveStart: "000000010000000000000005"},
+				{StopTime: at(5), ArchiveStart: "000000010000000000000008"},
+			},
+		}
+
+		assert.DeepEqual(t, info.RecoveryWindows(at(9)), []RecoveryWindow{
+			{Start: at(1), End: at(1)},
+			{Start: at(3), End: at(9)},
+		})
+
+		// Without a later time, the window ends with the newest backup.
+		assert.DeepEqual(t, info.RecoveryWindows(time.Time{}), []RecoveryWindow{
+			{Start: at(1), End: at(1)},
+			{Start: at(3), End: at(5)},
+		})
+	})
+
+	t.Run("NoWAL", func(t *testing.T) {
+		info := RepoInfo{
+			Backups: []BackupInfo{
+				{StopTime: at(1), ArchiveStart: "000000010000000000000002"},
+				{StopTime: at(3), ArchiveStart: "000000010000000000000005"},
+			},
+		}
+
+		assert.DeepEqual(t, info.RecoveryWindows(at(9)), []RecoveryWindow{
+			{Start: at(1), End: at(1)},
+			{Start: at(3), End: at(3)},
+		})
+	})
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Error", func(t *testing.T) {
+		exec := func(ctx context.Context, _ io.Reader, _, stderr io.Writer, command ...string) error {
+			assert.DeepEqual(t, command, []string{"pgbackrest", "check", "--stanza=db"})
+			_, _ = io.WriteString(stderr, "ERROR: [082]: WAL segment was not archived before the 60000ms timeout\n")
+			return errors.New("boom")
+		}
+
+		err := Executor(exec).Check(ctx, "db")
+		assert.ErrorContains(t, err, "boom")
+		assert.Assert(t, cmp.ErrorContains(err, "WAL segment was not archived"))
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		exec := func(ctx context.Context, _ io.Reader, _, _ io.Writer, _ ...string) error {
+			return nil
+		}
+
+		assert.NilError(t, Executor(exec).Check(ctx, "db"))
+	})
+}
+
+func TestExpireDryRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Error", func(t *testing.T) {
+		exec := func(ctx context.Context, _ io.Reader, _, stderr io.Writer, _ ...string) error {
+			_, _ = io.WriteString(stderr, "ERROR: [031]: invalid option '--repo1-retention-nope'\n")
+			return errors.New("boom")
+		}
+
+		_, err := Executor(exec).ExpireDryRun(ctx, "db", "repo1", "--repo1-retention-nope=1")
+		assert.ErrorContains(t, err, "boom")
+		assert.Assert(t, cmp.ErrorContains(err, "invalid option"))
+	})
+
+	t.Run("Nothing", func(t *testing.T) {
+		exec := func(ctx context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string) error {
+			_, err := io.WriteString(stdout, "P00   INFO: expire command begin 2.41\n"+
+				"P00   INFO: expire command end: completed successfully (10ms)\n")
+			return err
+		}
+
+		result, err := Executor(exec).ExpireDryRun(ctx, "db", "repo1")
+		assert.NilError(t, err)
+		assert.Assert(t, result.Backups == nil)
+		assert.Assert(t, result.Archive == nil)
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		exec := func(ctx context.Context, _ io.Reader, stdout, _ io.Writer, command ...string) error {
+			assert.DeepEqual(t, command, []string{
+				"pgbackrest", "expire", "--dry-run", "--stanza=hippo", "--repo=2",
+				"--log-level-console=info", "--repo2-retention-full=1",
+			})
+			_, err := io.WriteString(stdout, `
+P00   INFO: expire command begin 2.41: --dry-run --repo=2 --repo2-retention-full=1 --stanza=hippo
+P00   INFO: [DRY-RUN] repo2: expire full backup set 20230101-000000F, 20230101-000000F_20230101-010000I
+P00   INFO: [DRY-RUN] repo2: remove expired backup 20230101-000000F_20230101-010000I
+P00   INFO: [DRY-RUN] repo2: remove expired backup 20230101-000000F
+P00   INFO: [DRY-RUN] repo2: 14-1 remove archive, start = 000000010000000000000001, stop = 000000010000000000000004
+P00   INFO: expire command end: completed successfully (25ms)
+`)
+			return err
+		}
+
+		result, err := Executor(exec).ExpireDryRun(ctx, "hippo", "repo2", "--repo2-retention-full=1")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, result.Backups, []string{
+			"20230101-000000F_20230101-010000I", "20230101-000000F",
+		})
+		assert.DeepEqual(t, result.Archive, []string{
+			"000000010000000000000001-000000010000000000000004",
+		})
+	})
+}
@@ -38,10 +38,16 @@ func PostgreSQL(
 	// - https://pgbackrest.org/user-guide.html#quickstart/configure-archiving
 	// - https://pgbackrest.org/command.html#command-archive-push
 	// - https://www.postgresql.org/docs/current/runtime-config-wal.html
-	archive := `pgbackrest --stanza=` + DefaultStanzaName + ` archive-push "%p"`
+	archive := `pgbackrest --stanza=` + StanzaName(inCluster) + ` archive-push "%p"`
 	outParameters.Mandatory.Add("archive_mode", "on")
 	outParameters.Mandatory.Add("archive_command", archive)
 
+	// Discard WAL files while archiving is paused. Changing "archive_mode" requires
+	// a restart, but "archive_command" takes effect on reload.
+	if pause := inCluster.Spec.Backups.PGBackRest.PauseArchiving; pause != nil && *pause {
+		outParameters.Mandatory.Add("archive_command", "true")
+	}
+
 	// archive_timeout is used to determine at what point a WAL file is switched,
 	// if the WAL archive has not reached its full size in # of transactions
 	// (16MB). This has ramifications for log shipping, i.e. it ensures a WAL file
@@ -60,7 +66,7 @@ func PostgreSQL(
 	// Fetch WAL files from any configured repository during recovery.
 	// - https://pgbackrest.org/command.html#command-archive-get
 	// - https://www.postgresql.org/docs/current/runtime-config-wal.html
-	restore := `pgbackrest --stanza=` + DefaultStanzaName + ` archive-get %f "%p"`
+	restore := `pgbackrest --stanza=` + StanzaName(inCluster) + ` archive-get %f "%p"`
 	outParameters.Mandatory.Add("restore_command", restore)
 
 	if inCluster.Spec.Standby != nil && inCluster.Spec.Standby.Enabled && inCluster.Spec.Standby.RepoName != "" {
@@ -20,6 +20,7 @@ import (
 
 	"gotest.tools/v3/assert"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -50,4 +51,10 @@ func TestPostgreSQLParameters(t *testing.T) {
 		"archive_command": `pgbackrest --stanza=db archive-push "%p"`,
 		"restore_command": `pgbackrest --stanza=db archive-get %f "%p" --repo=99`,
 	})
+
+	cluster.Spec.Backups.PGBackRest.PauseArchiving = initialize.Bool(true)
+
+	PostgreSQL(cluster, parameters)
+	assert.Equal(t, parameters.Mandatory.Value("archive_command"), "true")
+	assert.Equal(t, parameters.Mandatory.Value("archive_mode"), "on")
 }
@@ -18,6 +18,7 @@ package pgbackrest
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
@@ -115,6 +116,7 @@ func AddConfigToInstancePod(
 		{Key: CMInstanceKey, Path: CMInstanceKey},
 		{Key: ConfigHashKey, Path: ConfigHashKey},
 	}
+	addConfigInclude(cluster, configmap.ConfigMap)
 
 	// As the cluster transitions from having a repository host to having none,
 	// PostgreSQL instances that have not rolled out expect to mount client
@@ -124,7 +126,7 @@ func AddConfigToInstancePod(
 	secret.Secret.Name = naming.PGBackRestSecret(cluster).Name
 	secret.Secret.Optional = initialize.Bool(true)
 
-	if DedicatedRepoHostEnabled(cluster) {
+	if DedicatedRepoHostEnabled(cluster) || SharedRepoHostEnabled(cluster) {
 		configmap.ConfigMap.Items = append(
 			configmap.ConfigMap.Items, corev1.KeyToPath{
 				Key:  serverConfigMapKey,
@@ -146,6 +148,7 @@ func AddConfigToInstancePod(
 	}
 
 	addConfigVolumeAndMounts(pod, sources)
+	addWebIdentityVolumeAndEnvironment(pod, cluster.Spec.Backups.PGBackRest.Repos)
 }
 
 // AddConfigToRepoPod adds and mounts the pgBackRest configuration volume for
@@ -161,6 +164,7 @@ func AddConfigToRepoPod(
 		{Key: ConfigHashKey, Path: ConfigHashKey},
 		{Key: serverConfigMapKey, Path: serverConfigProjectionPath},
 	}
+	addConfigInclude(cluster, configmap.ConfigMap)
 
 	secret := corev1.VolumeProjection{Secret: &corev1.SecretProjection{}}
 	secret.Secret.Name = naming.PGBackRestSecret(cluster).Name
@@ -173,6 +177,27 @@ func AddConfigToRepoPod(
 		cluster.Spec.Backups.PGBackRest.Configuration...)
 
 	addConfigVolumeAndMounts(pod, append(sources, configmap, secret))
+	addWebIdentityVolumeAndEnvironment(pod, cluster.Spec.Backups.PGBackRest.Repos)
+}
+
+// AddConfigToSharedRepoPod adds and mounts the pgBackRest configuration volume
+// of host to pod. Each of clusters has a stanza on host. The pgBackRest
+// containers must already be in pod.
+func AddConfigToSharedRepoPod(
+	host *v1beta1.SharedRepoHost, clusters []*v1beta1.PostgresCluster, pod *corev1.PodSpec,
+) {
+	configmap := corev1.VolumeProjection{ConfigMap: &corev1.ConfigMapProjection{}}
+	configmap.ConfigMap.Name = naming.SharedRepoHostConfig(host).Name
+	configmap.ConfigMap.Items = []corev1.KeyToPath{
+		{Key: CMRepoKey, Path: CMRepoKey},
+		{Key: serverConfigMapKey, Path: serverConfigProjectionPath},
+	}
+
+	secret := corev1.VolumeProjection{Secret: &corev1.SecretProjection{}}
+	secret.Secret.Name = naming.SharedRepoHostSecret(host).Name
+	secret.Secret.Items = sharedClientCertificates(clusters)
+
+	addConfigVolumeAndMounts(pod, []corev1.VolumeProjection{configmap, secret})
 }
 
 // AddConfigToRestorePod adds and mounts the pgBackRest configuration volume
@@ -193,6 +218,7 @@ func AddConfigToRestorePod(
 		// See also [RestoreConfig].
 		{Key: CMInstanceKey, Path: CMInstanceKey},
 	}
+	addConfigInclude(cluster, configmap.ConfigMap)
 
 	// Mount client certificates of the source cluster if they exist.
 	secret := corev1.VolumeProjection{Secret: &corev1.SecretProjection{}}
@@ -219,6 +245,27 @@ func AddConfigToRestorePod(
 	}
 
 	addConfigVolumeAndMounts(pod, append(sources, configmap, secret))
+
+	// The restore may read from the repositories of this cluster, the cloud
+	// repository in its data source, or the repositories of another cluster.
+	repos := append([]v1beta1.PGBackRestRepo{}, cluster.Spec.Backups.PGBackRest.Repos...)
+	if cluster.Spec.DataSource != nil && cluster.Spec.DataSource.PGBackRest != nil {
+		repos = append(repos, cluster.Spec.DataSource.PGBackRest.Repo)
+	}
+	if sourceCluster != nil {
+		repos = append(repos, sourceCluster.Spec.Backups.PGBackRest.Repos...)
+	}
+	addWebIdentityVolumeAndEnvironment(pod, repos)
+}
+
+// addConfigInclude adds the files of spec.backups.pgbackrest.configInclude to the
+// projection of the pgBackRest ConfigMap of cluster. PGO copies them there once they
+// are valid; see [ConfigInclude].
+func addConfigInclude(cluster *v1beta1.PostgresCluster, projection *corev1.ConfigMapProjection) {
+	if cluster.Spec.Backups.PGBackRest.ConfigInclude != nil {
+		projection.Items = append(projection.Items,
+			corev1.KeyToPath{Key: CMIncludeKey, Path: CMIncludeKey})
+	}
 }
 
 // addConfigVolumeAndMounts adds the config projections to pod as the
@@ -257,12 +304,137 @@ func addConfigVolumeAndMounts(
 	pod.Volumes = append(pod.Volumes, configVolume)
 }
 
+// addWebIdentityVolumeAndEnvironment projects service account tokens into pod
+// when any of repos authenticates to S3 using "web-id" or to Azure using a
+// workload identity. It mounts that volume to every container in pod that runs
+// pgBackRest commands and sets the environment that exchanges the tokens for
+// cloud credentials. Only the first identity of each kind is used; see
+// [ValidateWebIdentity] for the repos it reports.
+// - https://docs.aws.amazon.com/eks/latest/userguide/iam-roles-for-service-accounts.html
+// - https://azure.github.io/azure-workload-identity/docs/
+func addWebIdentityVolumeAndEnvironment(
+	pod *corev1.PodSpec, repos []v1beta1.PGBackRestRepo,
+) {
+	var aws *v1beta1.RepoS3
+	var azure *v1beta1.RepoAzure
+	for i := range repos {
+		if s3 := repos[i].S3; aws == nil && s3 != nil &&
+			s3.KeyType == "web-id" && s3.RoleARN != "" {
+			aws = s3
+		}
+		if az := repos[i].Azure; azure == nil && az != nil &&
+			az.ClientID != "" && az.TenantID != "" {
+			azure = az
+		}
+	}
+
+	var env []corev1.EnvVar
+	var sources []corev1.VolumeProjection
+	token := func(audience, path string) corev1.VolumeProjection {
+		return corev1.VolumeProjection{
+			ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+				Audience:          audience,
+				ExpirationSeconds: initialize.Int64(86400),
+				Path:              path,
+			},
+		}
+	}
+
+	if aws != nil {
+		sources = append(sources, token(webIdentityAWSAudience, webIdentityAWSTokenPath))
+		env = append(env,
+			corev1.EnvVar{Name: "AWS_ROLE_ARN", Value: aws.RoleARN},
+			corev1.EnvVar{Name: "AWS_WEB_IDENTITY_TOKEN_FILE",
+				Value: webIdentityMountPath + "/" + webIdentityAWSTokenPath})
+	}
+	if azure != nil {
+		sources = append(sources, token(webIdentityAzureAudience, webIdentityAzureTokenPath))
+		env = append(env,
+			corev1.EnvVar{Name: "AZURE_CLIENT_ID", Value: azure.ClientID},
+			corev1.EnvVar{Name: "AZURE_TENANT_ID", Value: azure.TenantID},
+			corev1.EnvVar{Name: "AZURE_FEDERATED_TOKEN_FILE",
+				Value: webIdentityMountPath + "/" + webIdentityAzureTokenPath})
+	}
+	if len(sources) == 0 {
+		return
+	}
+
+	tokenVolumeMount := corev1.VolumeMount{
+		Name:      "pgbackrest-web-identity",
+		MountPath: webIdentityMountPath,
+		ReadOnly:  true,
+	}
+
+	tokenVolume := corev1.Volume{
+		Name: tokenVolumeMount.Name,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{Sources: sources},
+		},
+	}
+
+	for i := range pod.Containers {
+		container := &pod.Containers[i]
+
+		switch container.Name {
+		case
+			naming.ContainerDatabase,
+			naming.PGBackRestRepoContainerName,
+			naming.PGBackRestRestoreContainerName:
+
+			container.Env = append(container.Env, env...)
+			container.VolumeMounts = append(container.VolumeMounts, tokenVolumeMount)
+		}
+	}
+
+	pod.Volumes = append(pod.Volumes, tokenVolume)
+}
+
 // addServerContainerAndVolume adds the TLS server container and certificate
 // projections to pod. Any PostgreSQL data and WAL volumes in pod are also mounted.
 func addServerContainerAndVolume(
 	cluster *v1beta1.PostgresCluster, pod *corev1.PodSpec,
 	certificates []corev1.VolumeProjection, resources *corev1.ResourceRequirements,
 ) {
+	var reloaderResources *corev1.ResourceRequirements
+	if sidecars := cluster.Spec.Backups.PGBackRest.Sidecars; sidecars != nil &&
+		sidecars.PGBackRestConfig != nil {
+		reloaderResources = sidecars.PGBackRestConfig.Resources
+	}
+
+	container, reloader, volume := serverContainersAndVolume(
+		config.PGBackRestContainerImage(cluster), cluster.Spec.ImagePullPolicy,
+		certificates, resources, reloaderResources)
+
+	// Mount PostgreSQL volumes that are present in pod.
+	postgresMounts := map[string]corev1.VolumeMount{
+		postgres.DataVolumeMount().Name: postgres.DataVolumeMount(),
+		postgres.WALVolumeMount().Name:  postgres.WALVolumeMount(),
+	}
+	if util.DefaultMutableFeatureGate.Enabled(util.TablespaceVolumes) {
+		for _, instance := range cluster.Spec.InstanceSets {
+			for _, vol := range instance.TablespaceVolumes {
+				tablespaceVolumeMount := postgres.TablespaceVolumeMount(vol.Name)
+				postgresMounts[tablespaceVolumeMount.Name] = tablespaceVolumeMount
+			}
+		}
+	}
+	for i := range pod.Volumes {
+		if mount, ok := postgresMounts[pod.Volumes[i].Name]; ok {
+			container.VolumeMounts = append(container.VolumeMounts, mount)
+		}
+	}
+
+	pod.Containers = append(pod.Containers, container, reloader)
+	pod.Volumes = append(pod.Volumes, volume)
+}
+
+// serverContainersAndVolume returns the TLS server container, its configuration
+// reloader, and the volume of certificate projections they mount.
+func serverContainersAndVolume(
+	image string, imagePullPolicy corev1.PullPolicy,
+	certificates []corev1.VolumeProjection,
+	resources, reloaderResources *corev1.ResourceRequirements,
+) (corev1.Container, corev1.Container, corev1.Volume) {
 	serverVolumeMount := corev1.VolumeMount{
 		Name:      "pgbackrest-server",
 		MountPath: serverMountPath,
@@ -279,8 +451,8 @@ func addServerContainerAndVolume(
 	container := corev1.Container{
 		Name:            naming.PGBackRestRepoContainerName,
 		Command:         []string{"pgbackrest", "server"},
-		Image:           config.PGBackRestContainerImage(cluster),
-		ImagePullPolicy: cluster.Spec.ImagePullPolicy,
+		Image:           image,
+		ImagePullPolicy: imagePullPolicy,
 		SecurityContext: initialize.RestrictedSecurityContext(),
 
 		LivenessProbe: &corev1.Probe{
@@ -298,25 +470,6 @@ func addServerContainerAndVolume(
 		container.Resources = *resources
 	}
 
-	// Mount PostgreSQL volumes that are present in pod.
-	postgresMounts := map[string]corev1.VolumeMount{
-		postgres.DataVolumeMount().Name: postgres.DataVolumeMount(),
-		postgres.WALVolumeMount().Name:  postgres.WALVolumeMount(),
-	}
-	if util.DefaultMutableFeatureGate.Enabled(util.TablespaceVolumes) {
-		for _, instance := range cluster.Spec.InstanceSets {
-			for _, vol := range instance.TablespaceVolumes {
-				tablespaceVolumeMount := postgres.TablespaceVolumeMount(vol.Name)
-				postgresMounts[tablespaceVolumeMount.Name] = tablespaceVolumeMount
-			}
-		}
-	}
-	for i := range pod.Volumes {
-		if mount, ok := postgresMounts[pod.Volumes[i].Name]; ok {
-			container.VolumeMounts = append(container.VolumeMounts, mount)
-		}
-	}
-
 	reloader := corev1.Container{
 		Name:            naming.ContainerPGBackRestConfig,
 		Command:         reloadCommand(naming.ContainerPGBackRestConfig),
@@ -328,14 +481,11 @@ func addServerContainerAndVolume(
 		VolumeMounts: []corev1.VolumeMount{serverVolumeMount},
 	}
 
-	if sidecars := cluster.Spec.Backups.PGBackRest.Sidecars; sidecars != nil &&
-		sidecars.PGBackRestConfig != nil &&
-		sidecars.PGBackRestConfig.Resources != nil {
-		reloader.Resources = *sidecars.PGBackRestConfig.Resources
+	if reloaderResources != nil {
+		reloader.Resources = *reloaderResources
 	}
 
-	pod.Containers = append(pod.Containers, container, reloader)
-	pod.Volumes = append(pod.Volumes, serverVolume)
+	return container, reloader, serverVolume
 }
 
 // AddServerToInstancePod adds the TLS server container and volume to pod for
@@ -383,6 +533,39 @@ func AddServerToRepoPod(
 	addServerContainerAndVolume(cluster, pod, certificates, resources)
 }
 
+// AddServerToSharedRepoPod adds the TLS server container and volume to pod for
+// host. It also mounts the volume of host that stores its stanzas.
+func AddServerToSharedRepoPod(host *v1beta1.SharedRepoHost, pod *corev1.PodSpec) {
+	certificates := []corev1.VolumeProjection{{
+		Secret: &corev1.SecretProjection{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: naming.SharedRepoHostSecret(host).Name,
+			},
+			Items: repositoryServerCertificates(),
+		},
+	}}
+
+	container, reloader, volume := serverContainersAndVolume(
+		config.SharedRepoHostContainerImage(host), host.Spec.ImagePullPolicy,
+		certificates, &host.Spec.Resources, nil)
+
+	repoVolume := corev1.Volume{
+		Name: "pgbackrest-repo",
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: naming.SharedRepoHostVolume(host).Name,
+			},
+		},
+	}
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      repoVolume.Name,
+		MountPath: sharedRepoPath,
+	})
+
+	pod.Containers = append(pod.Containers, container, reloader)
+	pod.Volumes = append(pod.Volumes, volume, repoVolume)
+}
+
 // InstanceCertificates populates the shared Secret with certificates needed to run pgBackRest.
 func InstanceCertificates(ctx context.Context,
 	inCluster *v1beta1.PostgresCluster,
@@ -392,7 +575,7 @@ func InstanceCertificates(ctx context.Context,
 ) error {
 	var err error
 
-	if DedicatedRepoHostEnabled(inCluster) {
+	if DedicatedRepoHostEnabled(inCluster) || SharedRepoHostEnabled(inCluster) {
 		initialize.ByteMap(&outInstanceCertificates.Data)
 
 		if err == nil {
@@ -415,7 +598,7 @@ func ReplicaCreateCommand(
 	command := func(repoName string) []string {
 		return []string{
 			"pgbackrest", "restore", "--delta",
-			"--stanza=" + DefaultStanzaName,
+			"--stanza=" + StanzaName(cluster),
 			"--repo=" + strings.TrimPrefix(repoName, "repo"),
 			"--link-map=pg_wal=" + postgres.WALDirectory(cluster, instance),
 
@@ -439,6 +622,12 @@ func ReplicaCreateCommand(
 		return command(cluster.Spec.Standby.RepoName)
 	}
 
+	// Replicas stream a copy of the primary when the cluster is configured to
+	// never use pgBackRest for replica creation.
+	if cluster.Spec.Backups.PGBackRest.ReplicaCreate == v1beta1.PGBackRestReplicaCreateBasebackup {
+		return nil
+	}
+
 	if cluster.Status.PGBackRest != nil {
 		for _, repo := range cluster.Status.PGBackRest.Repos {
 			if repo.ReplicaCreateBackupComplete {
@@ -455,6 +644,33 @@ func RepoVolumeMount() corev1.VolumeMount {
 	return corev1.VolumeMount{Name: "pgbackrest-repo", MountPath: repoMountPath}
 }
 
+// SpoolVolumeMount returns the name and mount path of the pgBackRest spool volume.
+func SpoolVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{Name: "pgbackrest-spool", MountPath: spoolMountPath}
+}
+
+// AddSpoolVolumeToInstancePod adds and mounts the spool volume of an instance
+// to pod. The database container must already be in pod.
+func AddSpoolVolumeToInstancePod(
+	pod *corev1.PodSpec, inSpoolVolume *corev1.PersistentVolumeClaim,
+) {
+	mount := SpoolVolumeMount()
+	pod.Volumes = append(pod.Volumes, corev1.Volume{
+		Name: mount.Name,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: inSpoolVolume.Name,
+			},
+		},
+	})
+
+	for i := range pod.Containers {
+		if pod.Containers[i].Name == naming.ContainerDatabase {
+			pod.Containers[i].VolumeMounts = append(pod.Containers[i].VolumeMounts, mount)
+		}
+	}
+}
+
 // RestoreConfig populates targetConfigMap and targetSecret with values needed
 // to restore a cluster from repositories defined in sourceConfigMap and sourceSecret.
 func RestoreConfig(
@@ -495,8 +711,28 @@ func Secret(ctx context.Context,
 ) error {
 	var err error
 
-	// Save the CA and generate a TLS client certificate for the entire cluster.
+	// While the root certificate authority is being replaced, the authorities
+	// it replaces stay in the bundle and existing certificates signed by them
+	// stay in use until every server trusts the new one. Those authorities are
+	// removed once the repository host presents a certificate signed by the
+	// new one. See [ConditionAuthorityRotation]. A SharedRepoHost has its own
+	// Secret, so only a dedicated repository host rotates this way.
+	var previous []pki.Certificate
 	if inRepoHost != nil {
+		previous = PreviousAuthorities(inSecret, inRoot)
+	}
+	retained := previous
+
+	switch authorityRotationStep(inCluster) {
+	case AuthorityRotationReissuing:
+		retained = nil
+	case AuthorityRotationRetiring:
+		previous, retained = nil, nil
+	}
+
+	// Save the CA and generate a TLS client certificate for the entire cluster.
+	// A SharedRepoHost presents a copy of this certificate to the instances.
+	if inRepoHost != nil || SharedRepoHostEnabled(inCluster) {
 		initialize.ByteMap(&outSecret.Data)
 
 		// The server verifies its "tls-server-auth" option contains the common
@@ -515,12 +751,15 @@ func Secret(ctx context.Context,
 			_ = leaf.Certificate.UnmarshalText(inSecret.Data[certClientSecretKey])
 			_ = leaf.PrivateKey.UnmarshalText(inSecret.Data[certClientPrivateKeySecretKey])
 
-			leaf, err = inRoot.RegenerateLeafWhenNecessary(leaf, commonName, dnsNames)
-			err = errors.WithStack(err)
+			if !issuedByAny(retained, leaf, commonName, dnsNames) {
+				leaf, err = inRoot.RegenerateLeafWithDurationWhenNecessary(
+					leaf, commonName, dnsNames, certificateDuration(inCluster))
+				err = errors.WithStack(err)
+			}
 		}
 
 		if err == nil {
-			outSecret.Data[certAuthoritySecretKey], err = certFile(inRoot.Certificate)
+			outSecret.Data[certAuthoritySecretKey], err = authorityFile(inRoot, previous)
 		}
 		if err == nil {
 			outSecret.Data[certClientPrivateKeySecretKey], err = certFile(leaf.PrivateKey)
@@ -530,32 +769,98 @@ func Secret(ctx context.Context,
 		}
 	}
 
-	// Generate a TLS server certificate for each repository host.
-	if inRepoHost != nil {
-		// The client verifies the "pg-host" or "repo-host" option it used is
-		// present in the DNS names of the server certificate.
-		leaf := &pki.LeafCertificate{}
+	// Generate a TLS server certificate for each repository host. When there
+	// is more than one pod, instances connect through the Service of the
+	// active pod, so the certificate is valid for its names too.
+	if inRepoHost != nil && err == nil {
 		dnsNames := naming.RepoHostPodDNSNames(ctx, inRepoHost)
-		commonName := dnsNames[0] // FQDN
+		if RepoHostReplicas(inCluster) > 1 {
+			dnsNames = append(dnsNames, naming.ServiceDNSNames(ctx, &corev1.Service{
+				ObjectMeta: naming.PGBackRestRepoHostService(inCluster),
+			})...)
+		}
 
-		if err == nil {
-			// Unmarshal and validate the stored leaf. These first errors can
-			// be ignored because they result in an invalid leaf which is then
-			// correctly regenerated.
-			_ = leaf.Certificate.UnmarshalText(inSecret.Data[certRepoSecretKey])
-			_ = leaf.PrivateKey.UnmarshalText(inSecret.Data[certRepoPrivateKeySecretKey])
+		err = repoHostServerCertificate(ctx, inRoot, retained, dnsNames,
+			certificateDuration(inCluster), inSecret, outSecret)
+	}
 
-			leaf, err = inRoot.RegenerateLeafWhenNecessary(leaf, commonName, dnsNames)
-			err = errors.WithStack(err)
-		}
+	return err
+}
 
-		if err == nil {
-			outSecret.Data[certRepoPrivateKeySecretKey], err = certFile(leaf.PrivateKey)
-		}
-		if err == nil {
-			outSecret.Data[certRepoSecretKey], err = certFile(leaf.Certificate)
+// SharedRepoHostSecret populates the Secret of a SharedRepoHost. Each of
+// inClusters has a stanza on the repository host and its pgBackRest Secret
+// in inClusterSecrets, keyed by cluster name. The repository host presents
+// the client certificate of each cluster to the instances of that cluster.
+func SharedRepoHostSecret(ctx context.Context,
+	inRepoHost *appsv1.StatefulSet,
+	inRoot *pki.RootCertificateAuthority,
+	inClusters []*v1beta1.PostgresCluster,
+	inClusterSecrets map[string]*corev1.Secret,
+	inSecret *corev1.Secret,
+	outSecret *corev1.Secret,
+) error {
+	initialize.ByteMap(&outSecret.Data)
+
+	// - https://golang.org/issue/45038
+	bytesClone := func(b []byte) []byte { return append([]byte(nil), b...) }
+
+	err := repoHostServerCertificate(ctx, inRoot, nil,
+		naming.RepoHostPodDNSNames(ctx, inRepoHost), 0, inSecret, outSecret)
+
+	if err == nil {
+		outSecret.Data[certAuthoritySecretKey], err = certFile(inRoot.Certificate)
+	}
+
+	for _, cluster := range inClusters {
+		if source := inClusterSecrets[cluster.Name]; source != nil {
+			outSecret.Data[sharedClientSecretKey(cluster)] =
+				bytesClone(source.Data[certClientSecretKey])
+			outSecret.Data[sharedClientPrivateKeySecretKey(cluster)] =
+				bytesClone(source.Data[certClientPrivateKeySecretKey])
 		}
 	}
 
 	return err
 }
+
+// repoHostServerCertificate generates a TLS server certificate for a repository
+// host that is valid for dnsNames and duration. The first of dnsNames is its
+// FQDN. Zero means the default duration. An existing certificate signed by one
+// of inRetained is kept.
+func repoHostServerCertificate(ctx context.Context,
+	inRoot *pki.RootCertificateAuthority,
+	inRetained []pki.Certificate,
+	dnsNames []string,
+	duration time.Duration,
+	inSecret *corev1.Secret,
+	outSecret *corev1.Secret,
+) error {
+	initialize.ByteMap(&outSecret.Data)
+
+	// The client verifies the "pg-host" or "repo-host" option it used is
+	// present in the DNS names of the server certificate.
+	leaf := &pki.LeafCertificate{}
+	commonName := dnsNames[0] // FQDN
+
+	// Unmarshal and validate the stored leaf. These first errors can
+	// be ignored because they result in an invalid leaf which is then
+	// correctly regenerated.
+	_ = leaf.Certificate.UnmarshalText(inSecret.Data[certRepoSecretKey])
+	_ = leaf.PrivateKey.UnmarshalText(inSecret.Data[certRepoPrivateKeySecretKey])
+
+	var err error
+	if !issuedByAny(inRetained, leaf, commonName, dnsNames) {
+		leaf, err = inRoot.RegenerateLeafWithDurationWhenNecessary(
+			leaf, commonName, dnsNames, duration)
+		err = errors.WithStack(err)
+	}
+
+	if err == nil {
+		outSecret.Data[certRepoPrivateKeySecretKey], err = certFile(leaf.PrivateKey)
+	}
+	if err == nil {
+		outSecret.Data[certRepoSecretKey], err = certFile(leaf.Certificate)
+	}
+
+	return err
+}
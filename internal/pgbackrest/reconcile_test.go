@@ -17,9 +17,13 @@ package pgbackrest
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"gotest.tools/v3/assert"
@@ -28,6 +32,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/pki"
 	"github.com/crunchydata/postgres-operator/internal/util"
@@ -245,6 +250,32 @@ func TestAddConfigToInstancePod(t *testing.T) {
 		`))
 	})
 
+	t.Run("ConfigInclude", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Repos = nil
+		cluster.Spec.Backups.PGBackRest.ConfigInclude = &corev1.LocalObjectReference{Name: "extra"}
+
+		out := pod.DeepCopy()
+		AddConfigToInstancePod(cluster, out)
+		alwaysExpect(t, out)
+
+		// Included files after the generated ones.
+		assert.Assert(t, marshalMatches(out.Volumes, `
+- name: pgbackrest-config
+  projected:
+    sources:
+    - configMap:
+        items:
+        - key: pgbackrest_instance.conf
+          path: pgbackrest_instance.conf
+        - key: config-hash
+          path: config-hash
+        - key: pgbackrest_user.conf
+          path: pgbackrest_user.conf
+        name: hippo-pgbackrest-config
+		`))
+	})
+
 	t.Run("NoVolumeRepo", func(t *testing.T) {
 		cluster := cluster.DeepCopy()
 		cluster.Spec.Backups.PGBackRest.Repos = nil
@@ -310,6 +341,106 @@ func TestAddConfigToInstancePod(t *testing.T) {
 	})
 }
 
+func TestAddWebIdentityVolumeAndEnvironment(t *testing.T) {
+	pod := corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "database"},
+			{Name: "other"},
+			{Name: "pgbackrest"},
+		},
+	}
+
+	t.Run("SharedKeys", func(t *testing.T) {
+		out := pod.DeepCopy()
+		addWebIdentityVolumeAndEnvironment(out, []v1beta1.PGBackRestRepo{
+			{Name: "repo1", S3: &v1beta1.RepoS3{Bucket: "b"}},
+		})
+		assert.DeepEqual(t, pod, *out)
+	})
+
+	t.Run("WebIdentity", func(t *testing.T) {
+		out := pod.DeepCopy()
+		addWebIdentityVolumeAndEnvironment(out, []v1beta1.PGBackRestRepo{
+			{Name: "repo1", Volume: new(v1beta1.RepoPVC)},
+			{Name: "repo2", S3: &v1beta1.RepoS3{
+				Bucket: "b", KeyType: "web-id", RoleARN: "arn:aws:iam::123:role/hippo",
+			}},
+		})
+
+		assert.Assert(t, marshalMatches(out.Containers, `
+- env:
+  - name: AWS_ROLE_ARN
+    value: arn:aws:iam::123:role/hippo
+  - name: AWS_WEB_IDENTITY_TOKEN_FILE
+    value: /etc/pgbackrest/web-identity/aws-token
+  name: database
+  resources: {}
+  volumeMounts:
+  - mountPath: /etc/pgbackrest/web-identity
+    name: pgbackrest-web-identity
+    readOnly: true
+- name: other
+  resources: {}
+- env:
+  - name: AWS_ROLE_ARN
+    value: arn:aws:iam::123:role/hippo
+  - name: AWS_WEB_IDENTITY_TOKEN_FILE
+    value: /etc/pgbackrest/web-identity/aws-token
+  name: pgbackrest
+  resources: {}
+  volumeMounts:
+  - mountPath: /etc/pgbackrest/web-identity
+    name: pgbackrest-web-identity
+    readOnly: true
+		`))
+
+		assert.Assert(t, marshalMatches(out.Volumes, `
+- name: pgbackrest-web-identity
+  projected:
+    sources:
+    - serviceAccountToken:
+        audience: sts.amazonaws.com
+        expirationSeconds: 86400
+        path: aws-token
+		`))
+	})
+
+	t.Run("WorkloadIdentity", func(t *testing.T) {
+		out := pod.DeepCopy()
+		addWebIdentityVolumeAndEnvironment(out, []v1beta1.PGBackRestRepo{
+			{Name: "repo1", Azure: &v1beta1.RepoAzure{
+				Container: "c", KeyType: "auto", ClientID: "some-client", TenantID: "some-tenant",
+			}},
+		})
+
+		assert.Assert(t, marshalMatches(out.Containers[0], `
+env:
+- name: AZURE_CLIENT_ID
+  value: some-client
+- name: AZURE_TENANT_ID
+  value: some-tenant
+- name: AZURE_FEDERATED_TOKEN_FILE
+  value: /etc/pgbackrest/web-identity/azure-token
+name: database
+resources: {}
+volumeMounts:
+- mountPath: /etc/pgbackrest/web-identity
+  name: pgbackrest-web-identity
+  readOnly: true
+		`))
+
+		assert.Assert(t, marshalMatches(out.Volumes, `
+- name: pgbackrest-web-identity
+  projected:
+    sources:
+    - serviceAccountToken:
+        audience: api://AzureADTokenExchange
+        expirationSeconds: 86400
+        path: azure-token
+		`))
+	})
+}
+
 func TestAddConfigToRepoPod(t *testing.T) {
 	cluster := v1beta1.PostgresCluster{}
 	cluster.Name = "hippo"
@@ -878,6 +1009,127 @@ func TestAddServerToRepoPod(t *testing.T) {
 	})
 }
 
+func TestAddServerToSharedRepoPod(t *testing.T) {
+	host := &v1beta1.SharedRepoHost{}
+	host.Name = "shared"
+	host.Spec.Image = initialize.String("some-image")
+	host.Spec.Resources.Requests = corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("5m"),
+	}
+
+	pod := corev1.PodSpec{}
+	AddServerToSharedRepoPod(host, &pod)
+
+	assert.DeepEqual(t, getContainerNames(pod.Containers),
+		[]string{"pgbackrest", "pgbackrest-config"})
+	assert.Equal(t, pod.Containers[0].Image, "some-image")
+	assert.Equal(t, pod.Containers[1].Image, "some-image")
+
+	// The server mounts the volume that stores every stanza.
+	assert.Assert(t, marshalMatches(pod.Containers[0].VolumeMounts, `
+- mountPath: /etc/pgbackrest/server
+  name: pgbackrest-server
+  readOnly: true
+- mountPath: /pgbackrest/shared
+  name: pgbackrest-repo
+	`))
+	assert.Assert(t, marshalMatches(pod.Containers[0].Resources, `
+requests:
+  cpu: 5m
+	`))
+
+	// The server certificate comes from the Secret of the host.
+	assert.Assert(t, marshalMatches(pod.Volumes, `
+- name: pgbackrest-server
+  projected:
+    sources:
+    - secret:
+        items:
+        - key: pgbackrest-repo-host.crt
+          path: server-tls.crt
+        - key: pgbackrest-repo-host.key
+          mode: 384
+          path: server-tls.key
+        name: shared-shared-repo-tls
+- name: pgbackrest-repo
+  persistentVolumeClaim:
+    claimName: shared-shared-repo
+	`))
+}
+
+func TestAddConfigToSharedRepoPod(t *testing.T) {
+	host := &v1beta1.SharedRepoHost{}
+	host.Name = "shared"
+
+	hippo := &v1beta1.PostgresCluster{}
+	hippo.Name = "hippo"
+
+	pod := corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "other"},
+			{Name: "pgbackrest"},
+		},
+	}
+	AddConfigToSharedRepoPod(host, []*v1beta1.PostgresCluster{hippo}, &pod)
+
+	assert.Assert(t, pod.Containers[0].VolumeMounts == nil)
+	assert.Assert(t, marshalMatches(pod.Containers[1].VolumeMounts, `
+- mountPath: /etc/pgbackrest/conf.d
+  name: pgbackrest-config
+  readOnly: true
+	`))
+
+	// The client certificate of each cluster comes from the Secret of the host.
+	assert.Assert(t, marshalMatches(pod.Volumes, `
+- name: pgbackrest-config
+  projected:
+    sources:
+    - configMap:
+        items:
+        - key: pgbackrest_repo.conf
+          path: pgbackrest_repo.conf
+        - key: pgbackrest-server.conf
+          path: ~postgres-operator_server.conf
+        name: shared-shared-repo-config
+    - secret:
+        items:
+        - key: pgbackrest.ca-roots
+          path: ~postgres-operator/tls-ca.crt
+        - key: pgbackrest-client-hippo.crt
+          path: ~postgres-operator/client-hippo.crt
+        - key: pgbackrest-client-hippo.key
+          mode: 384
+          path: ~postgres-operator/client-hippo.key
+        name: shared-shared-repo-tls
+	`))
+}
+
+func TestAddSpoolVolumeToInstancePod(t *testing.T) {
+	pod := corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "database"},
+			{Name: "pgbackrest"},
+		},
+	}
+	volume := &corev1.PersistentVolumeClaim{}
+	volume.Name = "some-spool"
+
+	AddSpoolVolumeToInstancePod(&pod, volume)
+
+	// Only the database container runs archive-push.
+	assert.Assert(t, marshalMatches(pod.Containers[0].VolumeMounts, `
+- mountPath: /pgspool
+  name: pgbackrest-spool
+	`))
+	assert.Assert(t, pod.Containers[1].VolumeMounts == nil)
+
+	assert.Assert(t, marshalMatches(pod.Volumes, `
+- name: pgbackrest-spool
+  persistentVolumeClaim:
+    claimName: some-spool
+	`))
+}
+
 func getContainerNames(containers []corev1.Container) []string {
 	names := make([]string, len(containers))
 	for i, c := range containers {
@@ -930,6 +1182,21 @@ func TestReplicaCreateCommand(t *testing.T) {
 			"pgbackrest", "restore", "--delta", "--stanza=db", "--repo=7",
 			"--link-map=pg_wal=/pgdata/pg0_wal", "--type=standby",
 		})
+
+		t.Run("Basebackup", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Backups.PGBackRest.ReplicaCreate = "Basebackup"
+
+			assert.Assert(t, len(ReplicaCreateCommand(cluster, instance)) > 0,
+				"expected standby to follow its repository")
+		})
+	})
+
+	t.Run("Basebackup", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.ReplicaCreate = "Basebackup"
+
+		assert.Equal(t, 0, len(ReplicaCreateCommand(cluster, instance)))
 	})
 }
 
@@ -973,6 +1240,13 @@ func TestSecret(t *testing.T) {
 		"some-repo-0.some-domain",
 	})
 
+	// Both certificates are reported with their expirations.
+	certificates := Certificates(intent)
+	assert.Equal(t, len(certificates), 2)
+	assert.Assert(t, certificates["pgbackrest-repo-host"].Equal(leaf.Certificate))
+	assert.Assert(t, !certificates["pgbackrest-client"].NotAfter().IsZero())
+	assert.Equal(t, len(Certificates(new(corev1.Secret))), 0)
+
 	// Assuming the intent is written, no change when called again.
 	existing.Data = intent.Data
 	before := intent.DeepCopy()
@@ -980,16 +1254,145 @@ func TestSecret(t *testing.T) {
 	assert.DeepEqual(t, before, intent)
 
 	t.Run("Rotation", func(t *testing.T) {
-		// The leaf certificate is regenerated when the root authority changes.
 		root2, err := pki.NewRootCertificateAuthority()
 		assert.NilError(t, err)
-		assert.NilError(t, Secret(ctx, cluster, host, root2, existing, intent))
+
+		authorities := func(t *testing.T, secret *corev1.Secret) []pki.Certificate {
+			var out []pki.Certificate
+			for rest := secret.Data["pgbackrest.ca-roots"]; len(rest) > 0; {
+				var block *pem.Block
+				if block, rest = pem.Decode(rest); block == nil {
+					break
+				}
+
+				var certificate pki.Certificate
+				assert.NilError(t, certificate.UnmarshalText(pem.EncodeToMemory(block)))
+				out = append(out, certificate)
+			}
+			return out
+		}
+		step := func(reason string) *v1beta1.PostgresCluster {
+			cluster := cluster.DeepCopy()
+			cluster.Status.Conditions = []metav1.Condition{{
+				Type: ConditionAuthorityRotation, Status: metav1.ConditionTrue, Reason: reason,
+			}}
+			return cluster
+		}
+
+		// The existing certificates stay when the root authority changes. Both
+		// authorities are trusted, the new one first.
+		trusting := new(corev1.Secret)
+		assert.NilError(t, Secret(ctx, cluster, host, root2, existing, trusting))
+		assert.DeepEqual(t, trusting.Data["pgbackrest-repo-host.crt"], existing.Data["pgbackrest-repo-host.crt"])
+		assert.DeepEqual(t, trusting.Data["pgbackrest-client.crt"], existing.Data["pgbackrest-client.crt"])
+
+		trusted := authorities(t, trusting)
+		assert.Equal(t, len(trusted), 2)
+		assert.Assert(t, trusted[0].Equal(root2.Certificate))
+		assert.Assert(t, trusted[1].Equal(root.Certificate))
+		assert.Assert(t, !CertificatesIssuedBy(trusting, root2))
+
+		// The leaf certificates are regenerated once every server trusts the
+		// new authority. Both authorities are still trusted.
+		reissuing := new(corev1.Secret)
+		assert.NilError(t, Secret(ctx, step(AuthorityRotationReissuing), host, root2, trusting, reissuing))
+		assert.Equal(t, len(authorities(t, reissuing)), 2)
+		assert.Assert(t, CertificatesIssuedBy(reissuing, root2))
 
 		leaf2 := &pki.LeafCertificate{}
-		assert.NilError(t, leaf2.Certificate.UnmarshalText(intent.Data["pgbackrest-repo-host.crt"]))
-		assert.NilError(t, leaf2.PrivateKey.UnmarshalText(intent.Data["pgbackrest-repo-host.key"]))
+		assert.NilError(t, leaf2.Certificate.UnmarshalText(reissuing.Data["pgbackrest-repo-host.crt"]))
+		assert.NilError(t, leaf2.PrivateKey.UnmarshalText(reissuing.Data["pgbackrest-repo-host.key"]))
 
 		assert.Assert(t, !reflect.DeepEqual(leaf.Certificate, leaf2.Certificate))
 		assert.Assert(t, !reflect.DeepEqual(leaf.PrivateKey, leaf2.PrivateKey))
+
+		// The previous authority is removed last.
+		retiring := new(corev1.Secret)
+		assert.NilError(t, Secret(ctx, step(AuthorityRotationRetiring), host, root2, reissuing, retiring))
+		assert.DeepEqual(t, retiring.Data["pgbackrest-repo-host.crt"], reissuing.Data["pgbackrest-repo-host.crt"])
+
+		trusted = authorities(t, retiring)
+		assert.Equal(t, len(trusted), 1)
+		assert.Assert(t, trusted[0].Equal(root2.Certificate))
+		assert.Equal(t, len(PreviousAuthorities(retiring, root2)), 0)
 	})
+
+	t.Run("Duration", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.RepoHost = &v1beta1.PGBackRestRepoHost{
+			CertificateDurationDays: initialize.Int32(7),
+		}
+
+		// Both certificates are regenerated to be valid for the configured duration.
+		intent := new(corev1.Secret)
+		assert.NilError(t, Secret(ctx, cluster, host, root, existing, intent))
+
+		for _, key := range []string{"pgbackrest-client.crt", "pgbackrest-repo-host.crt"} {
+			block, _ := pem.Decode(intent.Data[key])
+			assert.Assert(t, block != nil, "%v", key)
+
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			assert.NilError(t, err)
+			assert.Equal(t, certificate.NotAfter.Sub(certificate.NotBefore),
+				7*24*time.Hour+time.Hour, "%v", key)
+		}
+	})
+}
+
+func TestSharedRepoHostSecret(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root, err := pki.NewRootCertificateAuthority()
+	assert.NilError(t, err)
+
+	hippo := new(v1beta1.PostgresCluster)
+	hippo.Name = "hippo"
+	hippo.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+		{Name: "repo1", SharedHost: &v1beta1.RepoSharedHost{Name: "shared"}},
+	}
+
+	// A cluster with a shared repository has a client certificate without a
+	// dedicated repository host.
+	hippoSecret := new(corev1.Secret)
+	assert.NilError(t, Secret(ctx, hippo, nil, root, new(corev1.Secret), hippoSecret))
+	assert.Assert(t, len(hippoSecret.Data["pgbackrest-client.crt"]) > 0)
+	assert.Assert(t, len(hippoSecret.Data["pgbackrest-client.key"]) > 0)
+	assert.Assert(t, len(hippoSecret.Data["pgbackrest-repo-host.crt"]) == 0)
+
+	host := new(appsv1.StatefulSet)
+	host.Namespace = "ns1"
+	host.Name = "shared-shared-repo"
+	host.Spec.ServiceName = "shared-shared-repo"
+
+	existing := new(corev1.Secret)
+	intent := new(corev1.Secret)
+	assert.NilError(t, SharedRepoHostSecret(ctx, host, root,
+		[]*v1beta1.PostgresCluster{hippo},
+		map[string]*corev1.Secret{"hippo": hippoSecret},
+		existing, intent))
+
+	// The client certificate of each cluster is copied.
+	assert.DeepEqual(t, intent.Data["pgbackrest-client-hippo.crt"],
+		hippoSecret.Data["pgbackrest-client.crt"])
+	assert.DeepEqual(t, intent.Data["pgbackrest-client-hippo.key"],
+		hippoSecret.Data["pgbackrest-client.key"])
+	assert.DeepEqual(t, intent.Data["pgbackrest.ca-roots"],
+		hippoSecret.Data["pgbackrest.ca-roots"])
+
+	// There is a leaf certificate and private key for the repository host.
+	leaf := &pki.LeafCertificate{}
+	assert.NilError(t, leaf.Certificate.UnmarshalText(intent.Data["pgbackrest-repo-host.crt"]))
+	assert.NilError(t, leaf.PrivateKey.UnmarshalText(intent.Data["pgbackrest-repo-host.key"]))
+	assert.Assert(t, strings.HasPrefix(leaf.Certificate.CommonName(),
+		"shared-shared-repo-0.shared-shared-repo.ns1.svc."))
+
+	// Assuming the intent is written, no change when called again.
+	existing.Data = intent.Data
+	before := intent.DeepCopy()
+	assert.NilError(t, SharedRepoHostSecret(ctx, host, root,
+		[]*v1beta1.PostgresCluster{hippo},
+		map[string]*corev1.Secret{"hippo": hippoSecret},
+		existing, intent))
+	assert.DeepEqual(t, before, intent)
 }
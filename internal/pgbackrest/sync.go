@@ -0,0 +1,82 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pgbackrest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// SyncCommand returns the command that copies a repository from the rclone
+// remote in the SYNC_SOURCE environment variable to the one in SYNC_DESTINATION.
+// The files that list backups and WAL are copied after the backups and WAL
+// themselves so that the copy never lists a backup it does not have. Logs and
+// locks are not copied.
+// - https://rclone.org/commands/rclone_sync/
+// - https://rclone.org/filtering/
+func SyncCommand() []string {
+	const script = `
+rclone sync --exclude '*.info' --exclude '*.info.copy' \
+  --exclude '/log/**' --exclude '/lock/**' "${SYNC_SOURCE}" "${SYNC_DESTINATION}"
+rclone sync --include '*.info' --include '*.info.copy' "${SYNC_SOURCE}" "${SYNC_DESTINATION}"
+`
+	return []string{"sh", "-ceu", "--", script, "sync"}
+}
+
+// SyncRemotes returns the rclone remotes that repo is copied from and to
+// according to its sync field. A repository on a volume is read from where
+// it is mounted. The copy has the same path as the repository so that it can
+// be restored like the original. It returns an error when repo cannot be copied.
+func SyncRemotes(cluster *v1beta1.PostgresCluster, repo v1beta1.PGBackRestRepo) (
+	source, destination string, err error,
+) {
+	if repo.Sync == nil {
+		return "", "", fmt.Errorf("%q has no sync bucket", repo.Name)
+	}
+
+	// A path in the global options takes precedence, as it does for pgBackRest.
+	path, ok := cluster.Spec.Backups.PGBackRest.Global[repo.Name+"-path"]
+	if !ok || repo.Volume != nil {
+		path = repoPath(repo)
+	}
+
+	switch {
+	case repo.Volume != nil:
+		source = path
+	case repo.S3 != nil:
+		source = s3Remote(repo.S3.Bucket, repo.S3.Endpoint, repo.S3.Region, path)
+	default:
+		return "", "", fmt.Errorf("%q is not on a volume or in S3", repo.Name)
+	}
+
+	sync := repo.Sync.S3
+	destination = s3Remote(sync.Bucket, sync.Endpoint, sync.Region, path)
+	return source, destination, nil
+}
+
+// s3Remote returns an rclone connection string for path in an S3 bucket.
+// Credentials come from the environment, e.g. AWS_ACCESS_KEY_ID.
+// - https://rclone.org/docs/#connection-strings
+// - https://rclone.org/s3/#authentication
+func s3Remote(bucket, endpoint, region, path string) string {
+	quote := func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `""`) + `"` }
+
+	return ":s3,provider=Other,env_auth=true" +
+		",endpoint=" + quote(endpoint) + ",region=" + quote(region) +
+		":" + bucket + path
+}
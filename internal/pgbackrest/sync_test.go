@@ -0,0 +1,97 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pgbackrest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/crunchydata/postgres-operator/internal/testing/require"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestSyncCommand(t *testing.T) {
+	shellcheck := require.ShellCheck(t)
+
+	command := SyncCommand()
+
+	// Expect a POSIX shell command with an inline script.
+	assert.DeepEqual(t, command[:3], []string{"sh", "-ceu", "--"})
+	assert.Assert(t, len(command) > 3)
+
+	// Write out that inline script.
+	dir := t.TempDir()
+	file := filepath.Join(dir, "script.sh")
+	assert.NilError(t, os.WriteFile(file, []byte(command[3]), 0o600))
+
+	// Expect shellcheck to be happy.
+	cmd := exec.Command(shellcheck, "--enable=all", "--shell=sh", file)
+	output, err := cmd.CombinedOutput()
+	assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+}
+
+func TestSyncRemotes(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	sync := &v1beta1.PGBackRestRepoSync{
+		Schedule: "0 * * * *",
+		S3: v1beta1.RepoSyncS3{
+			Bucket: "west", Endpoint: "s3.us-west-2.amazonaws.com", Region: "us-west-2",
+		},
+	}
+
+	t.Run("NoSync", func(t *testing.T) {
+		_, _, err := SyncRemotes(cluster, v1beta1.PGBackRestRepo{
+			Name: "repo1", Volume: &v1beta1.RepoPVC{},
+		})
+		assert.ErrorContains(t, err, "no sync")
+	})
+
+	t.Run("Volume", func(t *testing.T) {
+		source, destination, err := SyncRemotes(cluster, v1beta1.PGBackRestRepo{
+			Name: "repo1", Volume: &v1beta1.RepoPVC{}, Sync: sync,
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, source, "/pgbackrest/repo1")
+		assert.Equal(t, destination, `:s3,provider=Other,env_auth=true,`+
+			`endpoint="s3.us-west-2.amazonaws.com",region="us-west-2":west/pgbackrest/repo1`)
+	})
+
+	t.Run("S3", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Global = map[string]string{"repo2-path": "/global"}
+
+		source, destination, err := SyncRemotes(cluster, v1beta1.PGBackRestRepo{
+			Name: "repo2", Path: "/ignored", Sync: sync,
+			S3: &v1beta1.RepoS3{Bucket: "east", Endpoint: `some"where`, Region: "us-east-1"},
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, source, `:s3,provider=Other,env_auth=true,`+
+			`endpoint="some""where",region="us-east-1":east/global`)
+		assert.Equal(t, destination, `:s3,provider=Other,env_auth=true,`+
+			`endpoint="s3.us-west-2.amazonaws.com",region="us-west-2":west/global`)
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		_, _, err := SyncRemotes(cluster, v1beta1.PGBackRestRepo{
+			Name: "repo3", GCS: &v1beta1.RepoGCS{Bucket: "g"}, Sync: sync,
+		})
+		assert.ErrorContains(t, err, "not on a volume or in S3")
+	})
+}
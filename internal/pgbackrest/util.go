@@ -19,8 +19,10 @@ import (
 	"fmt"
 	"hash/fnv"
 	"io"
+	"strings"
 
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/rand"
 
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
@@ -41,8 +43,94 @@ func DedicatedRepoHostEnabled(postgresCluster *v1beta1.PostgresCluster) bool {
 	return false
 }
 
+// RepoHostReplicas returns the number of pods of the pgBackRest dedicated repository
+// host of the provided PostgresCluster. Every pod mounts every repository volume, so
+// there is more than one only when all those volumes are ReadWriteMany.
+func RepoHostReplicas(postgresCluster *v1beta1.PostgresCluster) int32 {
+	host := postgresCluster.Spec.Backups.PGBackRest.RepoHost
+	if host == nil || host.Replicas == nil || *host.Replicas < 2 {
+		return 1
+	}
+	for _, repo := range postgresCluster.Spec.Backups.PGBackRest.Repos {
+		if repo.Volume != nil && !readWriteMany(repo.Volume.VolumeClaimSpec.AccessModes) {
+			return 1
+		}
+	}
+	return *host.Replicas
+}
+
+// readWriteMany returns whether or not modes allows a volume to be mounted by
+// pods on many nodes at once.
+func readWriteMany(modes []corev1.PersistentVolumeAccessMode) bool {
+	for _, mode := range modes {
+		if mode == corev1.ReadWriteMany {
+			return true
+		}
+	}
+	return false
+}
+
+// SharedRepoHostEnabled determines whether or not any pgBackRest repository of the provided
+// PostgresCluster is on a SharedRepoHost
+func SharedRepoHostEnabled(postgresCluster *v1beta1.PostgresCluster) bool {
+	for _, repo := range postgresCluster.Spec.Backups.PGBackRest.Repos {
+		if repo.SharedHost != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// repoPath returns the path of repo in its storage. Only cloud-based repositories can
+// be somewhere other than "/pgbackrest/" followed by the name of the repo.
+func repoPath(repo v1beta1.PGBackRestRepo) string {
+	if repo.Path != "" && repo.Volume == nil && repo.SharedHost == nil {
+		return repo.Path
+	}
+	return defaultRepo1Path + repo.Name
+}
+
+// repoLocation identifies the storage of a cloud-based repository and the path of the
+// repository in that storage. A path in global takes precedence over the path of repo.
+// It returns an empty string for other repositories.
+func repoLocation(global map[string]string, repo v1beta1.PGBackRestRepo) string {
+	path, ok := global[repo.Name+"-path"]
+	if !ok {
+		path = repoPath(repo)
+	}
+	switch {
+	case repo.Azure != nil:
+		return "azure:" + repo.Azure.Container + ":" + path
+	case repo.GCS != nil:
+		return "gcs:" + repo.GCS.Bucket + ":" + path
+	case repo.S3 != nil:
+		return "s3:" + repo.S3.Endpoint + "/" + repo.S3.Bucket + ":" + path
+	}
+	return ""
+}
+
+// SharesStanza determines whether or not the provided PostgresClusters store backups in the
+// same stanza of the same cloud-based repository. Only one of them should be running when so,
+// otherwise both write WAL and backups into the same stanza.
+func SharesStanza(postgresCluster, other *v1beta1.PostgresCluster) bool {
+	if StanzaName(postgresCluster) != StanzaName(other) {
+		return false
+	}
+
+	for _, repo := range postgresCluster.Spec.Backups.PGBackRest.Repos {
+		for _, otherRepo := range other.Spec.Backups.PGBackRest.Repos {
+			if here := repoLocation(postgresCluster.Spec.Backups.PGBackRest.Global, repo); here != "" &&
+				here == repoLocation(other.Spec.Backups.PGBackRest.Global, otherRepo) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // CalculateConfigHashes calculates hashes for any external pgBackRest repository configuration
-// present in the PostgresCluster spec (e.g. configuration for Azure, GCR and/or S3 repositories).
+// present in the PostgresCluster spec (e.g. configuration for Azure, GCR, S3 and/or shared
+// repositories).
 // Additionally it returns a hash of the hashes for each external repository.
 func CalculateConfigHashes(
 	postgresCluster *v1beta1.PostgresCluster) (map[string]string, string, error) {
@@ -56,7 +144,6 @@ func CalculateConfigHashes(
 		})
 	}
 
-	var err error
 	repoConfigHashes := make(map[string]string)
 	for _, repo := range postgresCluster.Spec.Backups.PGBackRest.Repos {
 		// hashes are only calculated for external repo configs
@@ -64,24 +151,32 @@ func CalculateConfigHashes(
 			continue
 		}
 
-		var hash, name string
+		var opts []string
 		switch {
 		case repo.Azure != nil:
-			hash, err = hashFunc([]string{repo.Azure.Container})
-			name = repo.Name
+			opts = []string{repo.Azure.Container}
 		case repo.GCS != nil:
-			hash, err = hashFunc([]string{repo.GCS.Bucket})
-			name = repo.Name
+			opts = []string{repo.GCS.Bucket}
 		case repo.S3 != nil:
-			hash, err = hashFunc([]string{repo.S3.Bucket, repo.S3.Endpoint, repo.S3.Region})
-			name = repo.Name
+			opts = []string{repo.S3.Bucket, repo.S3.Endpoint, repo.S3.Region}
+		case repo.SharedHost != nil:
+			opts = []string{repo.SharedHost.Name}
 		default:
 			return map[string]string{}, "", errors.New("found unexpected repo type")
 		}
+
+		// A stanza must be created when the repository moves within its storage.
+		// The hash of a repository at the default path is the same as before
+		// paths could be set.
+		if path := repoPath(repo); path != defaultRepo1Path+repo.Name {
+			opts = append(opts, path)
+		}
+
+		hash, err := hashFunc(opts)
 		if err != nil {
 			return map[string]string{}, "", errors.WithStack(err)
 		}
-		repoConfigHashes[name] = hash
+		repoConfigHashes[repo.Name] = hash
 	}
 
 	configHashes := []string{}
@@ -111,3 +206,9 @@ func safeHash32(content func(w io.Writer) error) (string, error) {
 	}
 	return rand.SafeEncodeString(fmt.Sprint(hash.Sum32())), nil
 }
+
+// quoteShellWord ensures that s is interpreted by a shell as single word.
+func quoteShellWord(s string) string {
+	// https://www.gnu.org/software/bash/manual/html_node/Quoting.html
+	return `'` + strings.ReplaceAll(s, `'`, `'"'"'`) + `'`
+}
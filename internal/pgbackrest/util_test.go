@@ -22,8 +22,10 @@ import (
 	"testing"
 
 	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
@@ -131,4 +133,122 @@ func TestCalculateConfigHashes(t *testing.T) {
 		repo := "repo" + strconv.Itoa(i+1)
 		assert.Assert(t, hashMap[repo] != configHashMap[repo])
 	}
+
+	// a repo path changes the hash, but the default path does not
+	pathCluster := postgresCluster.DeepCopy()
+	pathCluster.Spec.Backups.PGBackRest.Repos[2].Path = "/pgbackrest/repo3"
+	hashMap, _, err := CalculateConfigHashes(pathCluster)
+	assert.NilError(t, err)
+	assert.Equal(t, hashMap["repo3"], configHashMap["repo3"])
+
+	pathCluster.Spec.Backups.PGBackRest.Repos[2].Path = "/hippo"
+	hashMap, _, err = CalculateConfigHashes(pathCluster)
+	assert.NilError(t, err)
+	assert.Assert(t, hashMap["repo3"] != configHashMap["repo3"])
+}
+
+func TestSharedRepoHostEnabled(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+		{Name: "repo1", Volume: &v1beta1.RepoPVC{}},
+	}
+	assert.Assert(t, !SharedRepoHostEnabled(cluster))
+
+	cluster.Spec.Backups.PGBackRest.Repos = append(cluster.Spec.Backups.PGBackRest.Repos,
+		v1beta1.PGBackRestRepo{Name: "repo2", SharedHost: &v1beta1.RepoSharedHost{Name: "shared"}})
+	assert.Assert(t, SharedRepoHostEnabled(cluster))
+
+	// Shared repositories are hashed like other external repositories.
+	hashes, _, err := CalculateConfigHashes(cluster)
+	assert.NilError(t, err)
+	assert.Equal(t, len(hashes), 1)
+	assert.Assert(t, hashes["repo2"] != "")
+
+	cluster.Spec.Backups.PGBackRest.Repos[1].SharedHost.Name = "other"
+	other, _, err := CalculateConfigHashes(cluster)
+	assert.NilError(t, err)
+	assert.Assert(t, other["repo2"] != hashes["repo2"])
+}
+
+func TestRepoHostReplicas(t *testing.T) {
+	rwx := corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+	}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+		{Name: "repo1", Volume: &v1beta1.RepoPVC{VolumeClaimSpec: rwx}},
+		{Name: "repo2", S3: &v1beta1.RepoS3{}},
+	}
+	assert.Equal(t, RepoHostReplicas(cluster), int32(1))
+
+	cluster.Spec.Backups.PGBackRest.RepoHost = &v1beta1.PGBackRestRepoHost{
+		Replicas: initialize.Int32(3),
+	}
+	assert.Equal(t, RepoHostReplicas(cluster), int32(3))
+
+	// Every volume must be mounted by every pod.
+	cluster.Spec.Backups.PGBackRest.Repos = append(cluster.Spec.Backups.PGBackRest.Repos,
+		v1beta1.PGBackRestRepo{Name: "repo3", Volume: &v1beta1.RepoPVC{
+			VolumeClaimSpec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			},
+		}})
+	assert.Equal(t, RepoHostReplicas(cluster), int32(1))
+}
+
+func TestSharesStanza(t *testing.T) {
+	hippo := &v1beta1.PostgresCluster{}
+	hippo.Namespace, hippo.Name = "ns1", "hippo"
+	hippo.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+		{Name: "repo1", Volume: &v1beta1.RepoPVC{}},
+		{Name: "repo2", S3: &v1beta1.RepoS3{Bucket: "b", Endpoint: "e", Region: "r"}},
+	}
+
+	rhino := hippo.DeepCopy()
+	rhino.Namespace, rhino.Name = "ns2", "rhino"
+
+	// Volumes are never shared, but the same bucket and path is.
+	assert.Assert(t, SharesStanza(hippo, rhino))
+	assert.Assert(t, SharesStanza(rhino, hippo))
+
+	t.Run("VolumesOnly", func(t *testing.T) {
+		rhino := rhino.DeepCopy()
+		rhino.Spec.Backups.PGBackRest.Repos = rhino.Spec.Backups.PGBackRest.Repos[:1]
+		assert.Assert(t, !SharesStanza(hippo, rhino))
+	})
+
+	t.Run("DifferentBucket", func(t *testing.T) {
+		rhino := rhino.DeepCopy()
+		rhino.Spec.Backups.PGBackRest.Repos[1].S3.Bucket = "other"
+		assert.Assert(t, !SharesStanza(hippo, rhino))
+	})
+
+	t.Run("DifferentPath", func(t *testing.T) {
+		rhino := rhino.DeepCopy()
+		rhino.Spec.Backups.PGBackRest.Global = map[string]string{"repo2-path": "/rhino"}
+		assert.Assert(t, !SharesStanza(hippo, rhino))
+
+		// The same path through a different repository name.
+		rhino.Spec.Backups.PGBackRest.Repos[1].Name = "repo3"
+		rhino.Spec.Backups.PGBackRest.Global = map[string]string{"repo3-path": "/pgbackrest/repo2"}
+		assert.Assert(t, SharesStanza(hippo, rhino))
+	})
+
+	t.Run("RepoPath", func(t *testing.T) {
+		rhino := rhino.DeepCopy()
+		rhino.Spec.Backups.PGBackRest.Repos[1].Path = "/rhino"
+		assert.Assert(t, !SharesStanza(hippo, rhino))
+
+		// The path in global options takes precedence.
+		rhino.Spec.Backups.PGBackRest.Global = map[string]string{"repo2-path": "/pgbackrest/repo2"}
+		assert.Assert(t, SharesStanza(hippo, rhino))
+	})
+
+	t.Run("DifferentStanza", func(t *testing.T) {
+		rhino := rhino.DeepCopy()
+		rhino.Spec.Backups.PGBackRest.Repos = append(rhino.Spec.Backups.PGBackRest.Repos,
+			v1beta1.PGBackRestRepo{Name: "repo4", SharedHost: &v1beta1.RepoSharedHost{Name: "shared"}})
+		assert.Assert(t, !SharesStanza(hippo, rhino))
+	})
 }
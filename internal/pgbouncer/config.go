@@ -161,10 +161,50 @@ func clusterINI(cluster *v1beta1.PostgresCluster) string {
 
 	// Replace the above with any specified databases.
 	if len(cluster.Spec.Proxy.PGBouncer.Config.Databases) > 0 {
-		databases = iniValueSet(cluster.Spec.Proxy.PGBouncer.Config.Databases)
+		databases = iniValueSet{}
+		for k, v := range cluster.Spec.Proxy.PGBouncer.Config.Databases {
+			databases[k] = v
+		}
+	}
+
+	users := iniValueSet{}
+	for k, v := range cluster.Spec.Proxy.PGBouncer.Config.Users {
+		users[k] = v
 	}
 
-	users := iniValueSet(cluster.Spec.Proxy.PGBouncer.Config.Users)
+	// Add pool settings to the definitions of databases and users. Databases
+	// that are not defined connect the same as the wildcard. PgBouncer reloads
+	// these settings without dropping connections.
+	// - https://www.pgbouncer.org/config.html#section-databases
+	// - https://www.pgbouncer.org/config.html#section-users
+	for _, pool := range cluster.Spec.Proxy.PGBouncer.Config.DatabasePools {
+		definition, ok := databases[pool.Name]
+		if !ok {
+			definition = databases["*"]
+		}
+
+		settings := []string{definition}
+		if pool.PoolMode != "" {
+			settings = append(settings, "pool_mode="+pool.PoolMode)
+		}
+		if pool.PoolSize != nil {
+			settings = append(settings, fmt.Sprintf("pool_size=%d", *pool.PoolSize))
+		}
+		if pool.MaxConnections != nil {
+			settings = append(settings, fmt.Sprintf("max_db_connections=%d", *pool.MaxConnections))
+		}
+		databases[pool.Name] = strings.TrimSpace(strings.Join(settings, " "))
+	}
+	for _, pool := range cluster.Spec.Proxy.PGBouncer.Config.UserPools {
+		settings := []string{users[pool.Name]}
+		if pool.PoolMode != "" {
+			settings = append(settings, "pool_mode="+pool.PoolMode)
+		}
+		if pool.MaxConnections != nil {
+			settings = append(settings, fmt.Sprintf("max_user_connections=%d", *pool.MaxConnections))
+		}
+		users[pool.Name] = strings.TrimSpace(strings.Join(settings, " "))
+	}
 
 	// Include any custom configuration file, then apply global settings, then
 	// pool definitions.
@@ -27,6 +27,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -139,6 +140,48 @@ app = mode=rad
 		cluster.Spec.Proxy.PGBouncer.Config.Global["conffile"] = "too-far"
 		assert.Assert(t, !strings.Contains(clusterINI(cluster), "too-far"))
 	})
+
+	t.Run("PoolSettings", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{
+			Users: map[string]string{"app": "max_user_client_connections=5"},
+			DatabasePools: []v1beta1.PGBouncerDatabasePool{
+				{Name: "reports", PoolMode: "session", PoolSize: initialize.Int32(2)},
+				{Name: "*", MaxConnections: initialize.Int32(50)},
+			},
+			UserPools: []v1beta1.PGBouncerUserPool{
+				{Name: "app", PoolMode: "transaction"},
+				{Name: "orm", PoolMode: "session", MaxConnections: initialize.Int32(10)},
+			},
+		}
+
+		ini := clusterINI(cluster)
+		assert.Assert(t, strings.HasSuffix(ini, `
+[databases]
+* = host=foo-baz-primary port=9999 max_db_connections=50
+reports = host=foo-baz-primary port=9999 pool_mode=session pool_size=2
+
+[users]
+app = max_user_client_connections=5 pool_mode=transaction
+orm = pool_mode=session max_user_connections=10
+`), "got:\n%s", ini)
+
+		// Databases are added to those specified.
+		cluster.Spec.Proxy.PGBouncer.Config.Databases = map[string]string{
+			"*":       "host=elsewhere",
+			"reports": "host=replica",
+		}
+		ini = clusterINI(cluster)
+		assert.Assert(t, strings.Contains(ini, `
+[databases]
+* = host=elsewhere max_db_connections=50
+reports = host=replica pool_mode=session pool_size=2
+`), "got:\n%s", ini)
+
+		// The specification is not changed.
+		assert.Equal(t, cluster.Spec.Proxy.PGBouncer.Config.Databases["*"], "host=elsewhere")
+		assert.Equal(t, cluster.Spec.Proxy.PGBouncer.Config.Users["app"], "max_user_client_connections=5")
+	})
 }
 
 func TestPodConfigFiles(t *testing.T) {
@@ -56,3 +56,20 @@ func (exec Executor) GetExporterSetupSQL(ctx context.Context, version int) (stri
 
 	return sql, stderr.String(), err
 }
+
+// HasPostgreSQLLibrary returns true when the shared library named library is
+// in the package library directory of PostgreSQL. It must be executed in the
+// container that runs PostgreSQL.
+func (exec Executor) HasPostgreSQLLibrary(ctx context.Context, library string) (bool, error) {
+	log := logging.FromContext(ctx)
+
+	var stdout, stderr bytes.Buffer
+	err := exec(ctx, nil, &stdout, &stderr, "bash", "-ceu", "--",
+		`if [ -f "$(pg_config --pkglibdir)/$1.so" ]; then echo found; fi`,
+		"-", library)
+
+	log.V(1).Info("searched for library", "library", library,
+		"stdout", stdout.String(), "stderr", stderr.String())
+
+	return strings.TrimSpace(stdout.String()) == "found", err
+}
@@ -90,3 +90,47 @@ func TestExecutorGetExporterSetupSQL(t *testing.T) {
 
 	})
 }
+
+func TestExecutorHasPostgreSQLLibrary(t *testing.T) {
+	t.Run("Arguments", func(t *testing.T) {
+		called := false
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			called = true
+			assert.Assert(t, stdin == nil, "expected no stdin, got %T", stdin)
+			assert.Equal(t, command[0], "bash")
+			assert.Equal(t, command[len(command)-1], "pg_stat_monitor")
+			return nil
+		}
+
+		_, _ = Executor(exec).HasPostgreSQLLibrary(context.Background(), "pg_stat_monitor")
+		assert.Assert(t, called)
+	})
+
+	t.Run("Result", func(t *testing.T) {
+		found, err := Executor(func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string) error {
+			_, _ = stdout.Write([]byte("found\n"))
+			return nil
+		}).HasPostgreSQLLibrary(context.Background(), "pg_stat_monitor")
+		assert.NilError(t, err)
+		assert.Assert(t, found)
+
+		found, err = Executor(func(
+			context.Context, io.Reader, io.Writer, io.Writer, ...string) error {
+			return nil
+		}).HasPostgreSQLLibrary(context.Background(), "pg_stat_monitor")
+		assert.NilError(t, err)
+		assert.Assert(t, !found)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		expected := errors.New("boom")
+		_, actual := Executor(func(
+			context.Context, io.Reader, io.Writer, io.Writer, ...string) error {
+			return expected
+		}).HasPostgreSQLLibrary(context.Background(), "pg_stat_monitor")
+		assert.Equal(t, expected, actual)
+	})
+}
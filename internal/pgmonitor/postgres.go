@@ -17,6 +17,7 @@ package pgmonitor
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -35,10 +36,11 @@ const (
 // exporter to be accessible
 func PostgreSQLHBAs(inCluster *v1beta1.PostgresCluster, outHBAs *postgres.HBAs) {
 	if ExporterEnabled(inCluster) {
-		// Limit the monitoring user to local connections using SCRAM.
+		// Limit the monitoring user to local connections using its password.
+		method := postgres.PasswordMethod(inCluster)
 		outHBAs.Mandatory = append(outHBAs.Mandatory,
-			*postgres.NewHBA().TCP().User(MonitoringUser).Method("scram-sha-256").Network("127.0.0.0/8"),
-			*postgres.NewHBA().TCP().User(MonitoringUser).Method("scram-sha-256").Network("::1/128"),
+			*postgres.NewHBA().TCP().User(MonitoringUser).Method(method).Network("127.0.0.0/8"),
+			*postgres.NewHBA().TCP().User(MonitoringUser).Method(method).Network("::1/128"),
 			*postgres.NewHBA().TCP().User(MonitoringUser).Method("reject"))
 	}
 }
@@ -50,7 +52,7 @@ func PostgreSQLParameters(inCluster *v1beta1.PostgresCluster, outParameters *pos
 		// Exporter expects that shared_preload_libraries are installed
 		// pg_stat_statements: https://access.crunchydata.com/documentation/pgmonitor/latest/exporter/
 		// pgnodemx: https://github.com/CrunchyData/pgnodemx
-		libraries := []string{"pg_stat_statements", "pgnodemx"}
+		libraries := []string{StatementStatistics(inCluster), "pgnodemx"}
 
 		defined, found := outParameters.Mandatory.Get("shared_preload_libraries")
 		if found {
@@ -63,6 +65,58 @@ func PostgreSQLParameters(inCluster *v1beta1.PostgresCluster, outParameters *pos
 	}
 }
 
+// disableExporterSQL removes login permissions from the monitoring user, when
+// it exists. It expects the psql variable "username".
+const disableExporterSQL = `
+		SELECT pg_catalog.format('ALTER ROLE %I NOLOGIN', :'username')
+		 WHERE EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = :'username')
+		\gexec`
+
+// enableExporterSQL returns the SQL that EnableExporterInPostgreSQL executes in
+// all databases and then in the database of the exporter. The former installs
+// the statistics extension. The latter includes setup, the SQL from the
+// exporter image, and expects the psql variables "username" and "verifier".
+func enableExporterSQL(statistics, setup string) (allDatabases, setupDatabase string) {
+	allDatabases = strings.Join([]string{
+		// Quiet NOTICE messages from IF EXISTS statements.
+		// - https://www.postgresql.org/docs/current/runtime-config-client.html
+		`SET client_min_messages = WARNING;`,
+
+		// Exporter expects that extension(s) to be installed in all databases
+		// pg_stat_statements: https://access.crunchydata.com/documentation/pgmonitor/latest/exporter/
+		fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s;", statistics),
+
+		// Run idempotent update
+		fmt.Sprintf("ALTER EXTENSION %s UPDATE;", statistics),
+	}, "\n")
+
+	setupDatabase = strings.Join([]string{
+		// Quiet NOTICE messages from IF EXISTS statements.
+		// - https://www.postgresql.org/docs/current/runtime-config-client.html
+		`SET client_min_messages = WARNING;`,
+
+		// Setup.sql file from the exporter image. sql is specific
+		// to the PostgreSQL version
+		setup,
+
+		// pgnodemx: https://github.com/CrunchyData/pgnodemx
+		// The `monitor` schema is hard-coded in the setup SQL files
+		// from pgMonitor configuration
+		// https://github.com/CrunchyData/pgmonitor/blob/master/postgres_exporter/common/queries_nodemx.yml
+		"CREATE EXTENSION IF NOT EXISTS pgnodemx WITH SCHEMA monitor;",
+
+		// Run idempotent update
+		"ALTER EXTENSION pgnodemx UPDATE;",
+
+		// ccp_monitoring user is created in Setup.sql without a
+		// password; update the password and ensure that the ROLE
+		// can login to the database
+		`ALTER ROLE :"username" LOGIN PASSWORD :'verifier';`,
+	}, "\n")
+
+	return
+}
+
 // DisableExporterInPostgreSQL disables the exporter configuration in PostgreSQL.
 // Currently the exporter is disabled by removing login permissions for the
 // monitoring user.
@@ -70,10 +124,7 @@ func PostgreSQLParameters(inCluster *v1beta1.PostgresCluster, outParameters *pos
 func DisableExporterInPostgreSQL(ctx context.Context, exec postgres.Executor) error {
 	log := logging.FromContext(ctx)
 
-	stdout, stderr, err := exec.Exec(ctx, strings.NewReader(`
-		SELECT pg_catalog.format('ALTER ROLE %I NOLOGIN', :'username')
-		 WHERE EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = :'username')
-		\gexec`),
+	stdout, stderr, err := exec.Exec(ctx, strings.NewReader(disableExporterSQL),
 		map[string]string{
 			"username": MonitoringUser,
 		})
@@ -85,25 +136,15 @@ func DisableExporterInPostgreSQL(ctx context.Context, exec postgres.Executor) er
 
 // EnableExporterInPostgreSQL runs SQL setup commands in `database` to enable
 // the exporter to retrieve metrics. pgMonitor objects are created and expected
-// extensions are installed. We also ensure that the monitoring user has the
-// current password and can login.
+// extensions, including the `statistics` extension, are installed. We also
+// ensure that the monitoring user has the current password and can login.
 func EnableExporterInPostgreSQL(ctx context.Context, exec postgres.Executor,
-	monitoringSecret *corev1.Secret, database, setup string) error {
+	monitoringSecret *corev1.Secret, database, statistics, setup string) error {
 	log := logging.FromContext(ctx)
 
-	stdout, stderr, err := exec.ExecInAllDatabases(ctx,
-		strings.Join([]string{
-			// Quiet NOTICE messages from IF EXISTS statements.
-			// - https://www.postgresql.org/docs/current/runtime-config-client.html
-			`SET client_min_messages = WARNING;`,
-
-			// Exporter expects that extension(s) to be installed in all databases
-			// pg_stat_statements: https://access.crunchydata.com/documentation/pgmonitor/latest/exporter/
-			"CREATE EXTENSION IF NOT EXISTS pg_stat_statements;",
+	allDatabases, setupDatabase := enableExporterSQL(statistics, setup)
 
-			// Run idempotent update
-			"ALTER EXTENSION pg_stat_statements UPDATE;",
-		}, "\n"),
+	stdout, stderr, err := exec.ExecInAllDatabases(ctx, allDatabases,
 		map[string]string{
 			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
 			"QUIET":         "on", // Do not print successful commands to stdout.
@@ -115,30 +156,7 @@ func EnableExporterInPostgreSQL(ctx context.Context, exec postgres.Executor,
 	// NOTE: Setup is run last to ensure that the setup sql is used in the hash
 	if err == nil {
 		stdout, stderr, err = exec.ExecInDatabasesFromQuery(ctx,
-			`SELECT :'database'`,
-			strings.Join([]string{
-				// Quiet NOTICE messages from IF EXISTS statements.
-				// - https://www.postgresql.org/docs/current/runtime-config-client.html
-				`SET client_min_messages = WARNING;`,
-
-				// Setup.sql file from the exporter image. sql is specific
-				// to the PostgreSQL version
-				setup,
-
-				// pgnodemx: https://github.com/CrunchyData/pgnodemx
-				// The `monitor` schema is hard-coded in the setup SQL files
-				// from pgMonitor configuration
-				// https://github.com/CrunchyData/pgmonitor/blob/master/postgres_exporter/common/queries_nodemx.yml
-				"CREATE EXTENSION IF NOT EXISTS pgnodemx WITH SCHEMA monitor;",
-
-				// Run idempotent update
-				"ALTER EXTENSION pgnodemx UPDATE;",
-
-				// ccp_monitoring user is created in Setup.sql without a
-				// password; update the password and ensure that the ROLE
-				// can login to the database
-				`ALTER ROLE :"username" LOGIN PASSWORD :'verifier';`,
-			}, "\n"),
+			`SELECT :'database'`, setupDatabase,
 			map[string]string{
 				"database": database,
 				"username": MonitoringUser,
@@ -16,12 +16,17 @@
 package pgmonitor
 
 import (
+	"context"
+	"errors"
 	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/internal/testing/replay"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
@@ -51,6 +56,27 @@ func TestPostgreSQLHBA(t *testing.T) {
 		assert.Equal(t, outHBAs.Mandatory[1].String(), `host all "ccp_monitoring" "::1/128" scram-sha-256`)
 		assert.Equal(t, outHBAs.Mandatory[2].String(), `host all "ccp_monitoring" all reject`)
 	})
+
+	t.Run("PasswordEncryption", func(t *testing.T) {
+		inCluster := &v1beta1.PostgresCluster{}
+		inCluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+			PasswordEncryption: "md5",
+		}
+		inCluster.Spec.Monitoring = &v1beta1.MonitoringSpec{
+			PGMonitor: &v1beta1.PGMonitorSpec{
+				Exporter: &v1beta1.ExporterSpec{
+					Image: "image",
+				},
+			},
+		}
+
+		outHBAs := postgres.HBAs{}
+		PostgreSQLHBAs(inCluster, &outHBAs)
+
+		assert.Equal(t, len(outHBAs.Mandatory), 3)
+		assert.Equal(t, outHBAs.Mandatory[0].String(), `host all "ccp_monitoring" "127.0.0.0/8" md5`)
+		assert.Equal(t, outHBAs.Mandatory[1].String(), `host all "ccp_monitoring" "::1/128" md5`)
+	})
 }
 
 func TestPostgreSQLParameters(t *testing.T) {
@@ -98,4 +124,67 @@ func TestPostgreSQLParameters(t *testing.T) {
 		assert.Assert(t, strings.Contains(libs, "pgnodemx"))
 		assert.Assert(t, strings.Contains(libs, "daisy"))
 	})
+
+	t.Run("StatementStatistics", func(t *testing.T) {
+		inCluster := &v1beta1.PostgresCluster{}
+		inCluster.Spec.Monitoring = &v1beta1.MonitoringSpec{
+			PGMonitor: &v1beta1.PGMonitorSpec{
+				Exporter: &v1beta1.ExporterSpec{
+					Image:               "image",
+					StatementStatistics: PGStatMonitor,
+				},
+			},
+		}
+		inCluster.Status.Monitoring.StatementStatistics = PGStatMonitor
+		outParameters := postgres.NewParameters()
+
+		PostgreSQLParameters(inCluster, &outParameters)
+		libs, found := outParameters.Mandatory.Get("shared_preload_libraries")
+		assert.Assert(t, found)
+		assert.Equal(t, libs, "pg_stat_monitor,pgnodemx")
+	})
+}
+
+// Changes to the SQL below show up as changes to files in testdata. Update them
+// by running "go test" with the -test.update-golden flag.
+
+func TestDisableExporterInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+	exec := new(replay.Executor)
+
+	assert.NilError(t, DisableExporterInPostgreSQL(ctx, exec.Exec))
+	golden.Assert(t, exec.String(), "DisableExporterInPostgreSQL.golden")
+}
+
+func TestEnableExporterInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+	secret := &corev1.Secret{Data: map[string][]byte{
+		"verifier": []byte("SCRAM-SHA-256$4096:salt$stored:server"),
+	}}
+	setup := "CREATE SCHEMA IF NOT EXISTS monitor;"
+
+	t.Run("Golden", func(t *testing.T) {
+		exec := new(replay.Executor)
+
+		assert.NilError(t, EnableExporterInPostgreSQL(ctx, exec.Exec, secret, "postgres", PGStatStatements, setup))
+		golden.Assert(t, exec.String(), "EnableExporterInPostgreSQL.golden")
+	})
+
+	t.Run("StatementStatistics", func(t *testing.T) {
+		exec := new(replay.Executor)
+
+		assert.NilError(t, EnableExporterInPostgreSQL(ctx, exec.Exec, secret, "postgres", PGStatMonitor, setup))
+		assert.Assert(t, strings.Contains(exec.String(), "CREATE EXTENSION IF NOT EXISTS pg_stat_monitor;"))
+		assert.Assert(t, !strings.Contains(exec.String(), "pg_stat_statements"))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		exec := &replay.Executor{Results: []replay.Result{
+			{Stderr: "boom", Err: errors.New("exit code 1")},
+		}}
+
+		err := EnableExporterInPostgreSQL(ctx, exec.Exec, secret, "postgres", PGStatStatements, setup)
+		assert.ErrorContains(t, err, "exit code 1")
+		assert.Equal(t, len(exec.Calls), 1, "expected setup to be skipped")
+	})
 }
@@ -32,3 +32,26 @@ func ExporterEnabled(cluster *v1beta1.PostgresCluster) bool {
 	}
 	return true
 }
+
+const (
+	// PGStatStatements is the default extension for statement statistics.
+	// - https://www.postgresql.org/docs/current/pgstatstatements.html
+	PGStatStatements = "pg_stat_statements"
+
+	// PGStatMonitor is an alternative to pg_stat_statements that aggregates
+	// statistics into time buckets.
+	// - https://github.com/percona/pg_stat_monitor
+	PGStatMonitor = "pg_stat_monitor"
+)
+
+// StatementStatistics returns the extension that collects statement statistics
+// for the exporter. It is pg_stat_monitor only when the spec asks for it and
+// its library has been found in the PostgreSQL image.
+func StatementStatistics(cluster *v1beta1.PostgresCluster) string {
+	if ExporterEnabled(cluster) &&
+		cluster.Spec.Monitoring.PGMonitor.Exporter.StatementStatistics == PGStatMonitor &&
+		cluster.Status.Monitoring.StatementStatistics == PGStatMonitor {
+		return PGStatMonitor
+	}
+	return PGStatStatements
+}
@@ -37,3 +37,20 @@ func TestExporterEnabled(t *testing.T) {
 	assert.Assert(t, ExporterEnabled(cluster))
 
 }
+
+func TestStatementStatistics(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	assert.Equal(t, StatementStatistics(cluster), PGStatStatements)
+
+	cluster.Spec.Monitoring = &v1beta1.MonitoringSpec{
+		PGMonitor: &v1beta1.PGMonitorSpec{Exporter: &v1beta1.ExporterSpec{}},
+	}
+	assert.Equal(t, StatementStatistics(cluster), PGStatStatements)
+
+	cluster.Spec.Monitoring.PGMonitor.Exporter.StatementStatistics = PGStatMonitor
+	assert.Equal(t, StatementStatistics(cluster), PGStatStatements,
+		"expected pg_stat_statements until the library is found")
+
+	cluster.Status.Monitoring.StatementStatistics = PGStatMonitor
+	assert.Equal(t, StatementStatistics(cluster), PGStatMonitor)
+}
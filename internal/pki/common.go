@@ -29,6 +29,15 @@ import (
 // signature algorithm with the P-256 curve.
 const certificateSignatureAlgorithm = x509.ECDSAWithSHA384
 
+const (
+	// leafExpiration is how long a leaf certificate is valid by default.
+	leafExpiration = time.Hour * 24 * 365
+
+	// leafStartValid is when a leaf certificate becomes valid, relative to
+	// when it is generated.
+	leafStartValid = time.Hour * -1
+)
+
 // currentTime returns the current local time. It is a variable so it can be
 // replaced during testing.
 var currentTime = time.Now
@@ -49,18 +58,15 @@ func generateSerialNumber() (*big.Int, error) {
 func generateLeafCertificate(
 	signer *x509.Certificate, signerPrivate *ecdsa.PrivateKey,
 	signeePublic *ecdsa.PublicKey, serialNumber *big.Int,
-	commonName string, dnsNames []string,
+	commonName string, dnsNames []string, expiration time.Duration,
 ) (*x509.Certificate, error) {
-	const leafExpiration = time.Hour * 24 * 365
-	const leafStartValid = time.Hour * -1
-
 	now := currentTime()
 	template := &x509.Certificate{
 		BasicConstraintsValid: true,
 		DNSNames:              dnsNames,
 		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
 		NotBefore:             now.Add(leafStartValid),
-		NotAfter:              now.Add(leafExpiration),
+		NotAfter:              now.Add(expiration),
 		SerialNumber:          serialNumber,
 		SignatureAlgorithm:    certificateSignatureAlgorithm,
 		Subject: pkix.Name{
@@ -0,0 +1,90 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+)
+
+// fipsMinimumRSABits is the smallest RSA modulus approved for digital
+// signatures by FIPS 186-5.
+const fipsMinimumRSABits = 2048
+
+// CheckFIPS returns an error when c has a public key or signature algorithm
+// that is not approved for digital signatures by FIPS 186-5: RSA keys of at
+// least 2048 bits, ECDSA keys on a NIST prime curve, or Ed25519 keys, signed
+// using SHA-2.
+// - https://csrc.nist.gov/pubs/fips/186-5/final
+func (c Certificate) CheckFIPS() error {
+	if c.x509 == nil {
+		return fmt.Errorf("certificate is missing")
+	}
+
+	switch key := c.x509.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if bits := key.N.BitLen(); bits < fipsMinimumRSABits {
+			return fmt.Errorf("RSA key of %d bits is smaller than %d bits",
+				bits, fipsMinimumRSABits)
+		}
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		default:
+			return fmt.Errorf("ECDSA curve %s is not approved", key.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+	default:
+		return fmt.Errorf("%s keys are not approved", c.x509.PublicKeyAlgorithm)
+	}
+
+	switch c.x509.SignatureAlgorithm {
+	case x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+		x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS,
+		x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512,
+		x509.PureEd25519:
+	default:
+		return fmt.Errorf("signature algorithm %s is not approved", c.x509.SignatureAlgorithm)
+	}
+
+	return nil
+}
+
+// CheckGeneratedFIPS generates a root certificate authority and a leaf
+// certificate the same way they are generated for clusters and returns an
+// error when either is not approved by FIPS 186-5.
+func CheckGeneratedFIPS() error {
+	root, err := NewRootCertificateAuthority()
+	if err != nil {
+		return err
+	}
+	if err := root.Certificate.CheckFIPS(); err != nil {
+		return fmt.Errorf("generated root certificate: %w", err)
+	}
+
+	leaf, err := root.GenerateLeafCertificate("fips", []string{"fips"})
+	if err != nil {
+		return err
+	}
+	if err := leaf.Certificate.CheckFIPS(); err != nil {
+		return fmt.Errorf("generated leaf certificate: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"math/big"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCertificateCheckFIPS(t *testing.T) {
+	selfSigned := func(t *testing.T, key crypto.Signer, algorithm x509.SignatureAlgorithm) Certificate {
+		t.Helper()
+		template := &x509.Certificate{
+			SerialNumber:       big.NewInt(1),
+			SignatureAlgorithm: algorithm,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+		assert.NilError(t, err)
+
+		parsed, err := x509.ParseCertificate(der)
+		assert.NilError(t, err)
+		return Certificate{x509: parsed}
+	}
+
+	t.Run("Zero", func(t *testing.T) {
+		assert.ErrorContains(t, Certificate{}.CheckFIPS(), "missing")
+	})
+
+	t.Run("Generated", func(t *testing.T) {
+		assert.NilError(t, CheckGeneratedFIPS())
+	})
+
+	t.Run("Approved", func(t *testing.T) {
+		p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		assert.NilError(t, err)
+		assert.NilError(t, selfSigned(t, p384, x509.ECDSAWithSHA512).CheckFIPS())
+
+		_, ed, err := ed25519.GenerateKey(rand.Reader)
+		assert.NilError(t, err)
+		assert.NilError(t, selfSigned(t, ed, x509.PureEd25519).CheckFIPS())
+	})
+
+	t.Run("SmallRSA", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		assert.NilError(t, err)
+		assert.Error(t, selfSigned(t, key, x509.SHA256WithRSA).CheckFIPS(),
+			"RSA key of 1024 bits is smaller than 2048 bits")
+	})
+
+	t.Run("SHA1", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NilError(t, err)
+		assert.Error(t, selfSigned(t, key, x509.ECDSAWithSHA1).CheckFIPS(),
+			"signature algorithm ECDSA-SHA1 is not approved")
+	})
+
+	t.Run("Curve", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+		assert.NilError(t, err)
+		assert.Error(t, selfSigned(t, key, x509.ECDSAWithSHA256).CheckFIPS(),
+			"ECDSA curve P-224 is not approved")
+	})
+}
@@ -53,6 +53,25 @@ func (c Certificate) DNSNames() []string {
 	return append([]string{}, c.x509.DNSNames...)
 }
 
+// NotAfter returns when the certificate expires. It is the zero time when c
+// is empty.
+func (c Certificate) NotAfter() time.Time {
+	if c.x509 == nil {
+		return time.Time{}
+	}
+	return c.x509.NotAfter
+}
+
+// RenewalTime returns when the certificate should be replaced according to
+// this package's policies. It is the zero time when c is empty.
+func (c Certificate) RenewalTime() time.Time {
+	if c.x509 == nil {
+		return time.Time{}
+	}
+	after := c.x509.NotAfter
+	return after.Add(-1 * after.Sub(c.x509.NotBefore) / renewalRatio)
+}
+
 // hasSubject checks that c has these values in its subject.
 func (c Certificate) hasSubject(commonName string, dnsNames []string) bool {
 	ok := c.x509 != nil &&
@@ -145,6 +164,14 @@ func RootIsValid(root *RootCertificateAuthority) bool {
 // GenerateLeafCertificate generates a new key and certificate signed by root.
 func (root *RootCertificateAuthority) GenerateLeafCertificate(
 	commonName string, dnsNames []string,
+) (*LeafCertificate, error) {
+	return root.generateLeafCertificate(commonName, dnsNames, leafExpiration)
+}
+
+// generateLeafCertificate generates a new key and certificate signed by root
+// that expires after expiration.
+func (root *RootCertificateAuthority) generateLeafCertificate(
+	commonName string, dnsNames []string, expiration time.Duration,
 ) (*LeafCertificate, error) {
 	var leaf LeafCertificate
 	var serial *big.Int
@@ -157,7 +184,7 @@ func (root *RootCertificateAuthority) GenerateLeafCertificate(
 		leaf.PrivateKey.ecdsa = key
 		leaf.Certificate.x509, err = generateLeafCertificate(
 			root.Certificate.x509, root.PrivateKey.ecdsa, &key.PublicKey, serial,
-			commonName, dnsNames)
+			commonName, dnsNames, expiration)
 	}
 
 	return &leaf, err
@@ -206,6 +233,16 @@ func (root *RootCertificateAuthority) leafIsValid(leaf *LeafCertificate) bool {
 	return ok
 }
 
+// LeafIsValid checks if leaf is valid according to this package's policies,
+// signed by root, and has commonName and dnsNames in its subject. Only the
+// certificate of root is used, so root can be an authority that is being
+// replaced and whose private key is no longer known.
+func (root *RootCertificateAuthority) LeafIsValid(
+	leaf *LeafCertificate, commonName string, dnsNames []string,
+) bool {
+	return root.leafIsValid(leaf) && leaf.Certificate.hasSubject(commonName, dnsNames)
+}
+
 // isBeforeRenewalTime checks if the result of `currentTime`
 // is after the default renewal time of
 // 1/3rds before the certificate's expiry
@@ -229,3 +266,24 @@ func (root *RootCertificateAuthority) RegenerateLeafWhenNecessary(
 	}
 	return root.GenerateLeafCertificate(commonName, dnsNames)
 }
+
+// RegenerateLeafWithDurationWhenNecessary is like [RegenerateLeafWhenNecessary]
+// but also replaces a leaf that is not valid for duration. A new certificate is
+// valid for duration. A zero duration is the default of [GenerateLeafCertificate].
+func (root *RootCertificateAuthority) RegenerateLeafWithDurationWhenNecessary(
+	leaf *LeafCertificate, commonName string, dnsNames []string, duration time.Duration,
+) (*LeafCertificate, error) {
+	if duration == 0 {
+		return root.RegenerateLeafWhenNecessary(leaf, commonName, dnsNames)
+	}
+
+	ok := root.leafIsValid(leaf) &&
+		leaf.Certificate.hasSubject(commonName, dnsNames) &&
+		leaf.Certificate.x509.NotAfter.Sub(leaf.Certificate.x509.NotBefore) ==
+			duration-leafStartValid
+
+	if ok {
+		return leaf, nil
+	}
+	return root.generateLeafCertificate(commonName, dnsNames, duration)
+}
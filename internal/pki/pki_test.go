@@ -45,6 +45,24 @@ func TestCertificateDNSNames(t *testing.T) {
 	assert.Assert(t, zero.DNSNames() == nil)
 }
 
+func TestCertificateNotAfter(t *testing.T) {
+	zero := Certificate{}
+	assert.Assert(t, zero.NotAfter().IsZero())
+	assert.Assert(t, zero.RenewalTime().IsZero())
+
+	root, err := NewRootCertificateAuthority()
+	assert.NilError(t, err)
+
+	cert := root.Certificate.x509
+	assert.Equal(t, root.Certificate.NotAfter(), cert.NotAfter)
+
+	// Renewal is one third of the lifetime before expiration.
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	assert.Equal(t, root.Certificate.RenewalTime(), cert.NotAfter.Add(-lifetime/3))
+	assert.Assert(t, isBeforeRenewalTime(cert.NotBefore, cert.NotAfter))
+	assert.Assert(t, currentTime().Before(root.Certificate.RenewalTime()))
+}
+
 func TestCertificateHasSubject(t *testing.T) {
 	zero := Certificate{}
 
@@ -446,6 +464,53 @@ func TestRegenerateLeaf(t *testing.T) {
 	assert.Assert(t, !after.Certificate.Equal(before.Certificate))
 }
 
+func TestRegenerateLeafWithDuration(t *testing.T) {
+	root, err := NewRootCertificateAuthority()
+	assert.NilError(t, err)
+
+	before, err := root.GenerateLeafCertificate("before", nil)
+	assert.NilError(t, err)
+
+	// Leaf is the same when the duration is the default.
+	same, err := root.RegenerateLeafWithDurationWhenNecessary(before, "before", nil, 0)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, same, before)
+
+	// Leaf is replaced when its duration is different.
+	week := time.Hour * 24 * 7
+	after, err := root.RegenerateLeafWithDurationWhenNecessary(before, "before", nil, week)
+	assert.NilError(t, err)
+	assert.Assert(t, !after.Certificate.Equal(before.Certificate))
+	assert.Equal(t, after.Certificate.x509.NotAfter.Sub(after.Certificate.x509.NotBefore), week+time.Hour)
+
+	// Leaf is the same when its duration is the same.
+	same, err = root.RegenerateLeafWithDurationWhenNecessary(after, "before", nil, week)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, same, after)
+
+	// Leaf is replaced when its subject is different.
+	other, err := root.RegenerateLeafWithDurationWhenNecessary(after, "other", nil, week)
+	assert.NilError(t, err)
+	assert.Assert(t, other.Certificate.hasSubject("other", nil))
+}
+
+func TestLeafIsValidFromCertificate(t *testing.T) {
+	root, err := NewRootCertificateAuthority()
+	assert.NilError(t, err)
+
+	leaf, err := root.GenerateLeafCertificate("some-cn", []string{"some-dns"})
+	assert.NilError(t, err)
+
+	// Only the certificate of the authority is necessary.
+	retired := &RootCertificateAuthority{Certificate: root.Certificate}
+	assert.Assert(t, retired.LeafIsValid(leaf, "some-cn", []string{"some-dns"}))
+	assert.Assert(t, !retired.LeafIsValid(leaf, "other-cn", []string{"some-dns"}))
+
+	other, err := NewRootCertificateAuthority()
+	assert.NilError(t, err)
+	assert.Assert(t, !other.LeafIsValid(leaf, "some-cn", []string{"some-dns"}))
+}
+
 func basicOpenSSLVerify(t *testing.T, openssl string, root, leaf Certificate) {
 	verify := func(t testing.TB, args ...string) {
 		t.Helper()
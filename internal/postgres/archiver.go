@@ -0,0 +1,93 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+)
+
+// ArchiverStatus is what a PostgreSQL server reports about archiving WAL files.
+// - https://www.postgresql.org/docs/current/monitoring-stats.html#MONITORING-PG-STAT-ARCHIVER-VIEW
+type ArchiverStatus struct {
+	// When the last WAL file was archived successfully.
+	LastArchivedTime *time.Time `json:"lastArchivedTime"`
+
+	// The name of the last WAL file that failed to archive and when.
+	LastFailedWAL  string     `json:"lastFailedWAL"`
+	LastFailedTime *time.Time `json:"lastFailedTime"`
+
+	// The number of WAL files waiting to be archived and when the oldest of
+	// them was written.
+	ReadyFiles      int        `json:"readyFiles"`
+	OldestReadyTime *time.Time `json:"oldestReadyTime"`
+}
+
+// Failing returns whether or not the last attempt to archive a WAL file failed.
+func (s ArchiverStatus) Failing() bool {
+	return s.LastFailedTime != nil &&
+		(s.LastArchivedTime == nil || s.LastFailedTime.After(*s.LastArchivedTime))
+}
+
+// ReadArchiverStatus calls exec to read the statistics of the WAL archiver and
+// to look at the WAL files that are waiting to be archived.
+// - https://www.postgresql.org/docs/current/wal-internals.html
+func ReadArchiverStatus(ctx context.Context, exec Executor) (ArchiverStatus, error) {
+	log := logging.FromContext(ctx)
+
+	// PostgreSQL marks each WAL file that is ready to be archived with a file
+	// in "archive_status". Files can be archived while this runs, so ignore
+	// any that go missing.
+	// - https://www.postgresql.org/docs/current/functions-admin.html#FUNCTIONS-ADMIN-GENFILE
+	const sql = `
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+
+SELECT pg_catalog.json_build_object(
+       'lastArchivedTime', s.last_archived_time,
+       'lastFailedWAL', s.last_failed_wal,
+       'lastFailedTime', s.last_failed_time,
+       'readyFiles', r.files,
+       'oldestReadyTime', r.oldest)
+  FROM pg_catalog.pg_stat_archiver AS s,
+       (SELECT pg_catalog.count(f.name) AS files,
+               pg_catalog.min((pg_catalog.pg_stat_file(
+                 'pg_wal/archive_status/' || f.name, true)).modification) AS oldest
+          FROM pg_catalog.pg_ls_dir('pg_wal/archive_status', true, false) AS f (name)
+         WHERE f.name LIKE '%.ready') AS r;
+`
+
+	stdout, stderr, err := exec.Exec(ctx, strings.NewReader(sql),
+		map[string]string{
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("read archiver status", "stdout", stdout, "stderr", stderr)
+
+	var status ArchiverStatus
+	if err == nil {
+		err = errors.WithStack(json.Unmarshal([]byte(strings.TrimSpace(stdout)), &status))
+	}
+	return status, err
+}
@@ -0,0 +1,95 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+)
+
+func TestArchiverStatusFailing(t *testing.T) {
+	earlier := time.Date(2023, time.March, 7, 2, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Minute)
+
+	assert.Assert(t, !ArchiverStatus{}.Failing())
+	assert.Assert(t, !ArchiverStatus{LastArchivedTime: &later}.Failing())
+	assert.Assert(t, ArchiverStatus{LastFailedTime: &later}.Failing())
+	assert.Assert(t, ArchiverStatus{LastArchivedTime: &earlier, LastFailedTime: &later}.Failing())
+	assert.Assert(t, !ArchiverStatus{LastArchivedTime: &later, LastFailedTime: &earlier}.Failing())
+}
+
+func TestReadArchiverStatus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Assert(t, cmp.Contains(string(b), `pg_stat_archiver`))
+			assert.Assert(t, cmp.Contains(string(b), `pg_ls_dir('pg_wal/archive_status'`))
+			assert.Assert(t, cmp.Contains(command, "--set=ON_ERROR_STOP=on"))
+			return expected
+		}
+
+		_, err := ReadArchiverStatus(ctx, exec)
+		assert.Equal(t, expected, err)
+	})
+
+	t.Run("Output", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte(`{"lastArchivedTime" : "2023-03-07T02:00:00.123456+00:00", ` +
+				`"lastFailedWAL" : "000000010000000000000009", ` +
+				`"lastFailedTime" : "2023-03-07T02:01:00+00:00", ` +
+				`"readyFiles" : 3, "oldestReadyTime" : "2023-03-07T01:59:00+00:00"}` + "\n"))
+			return nil
+		}
+
+		status, err := ReadArchiverStatus(ctx, exec)
+		assert.NilError(t, err)
+		assert.Assert(t, status.Failing())
+		assert.Equal(t, status.LastFailedWAL, "000000010000000000000009")
+		assert.Equal(t, status.ReadyFiles, 3)
+		assert.Equal(t, status.OldestReadyTime.UTC(),
+			time.Date(2023, time.March, 7, 1, 59, 0, 0, time.UTC))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte(`{"lastArchivedTime" : null, "lastFailedWAL" : null, ` +
+				`"lastFailedTime" : null, "readyFiles" : 0, "oldestReadyTime" : null}`))
+			return nil
+		}
+
+		status, err := ReadArchiverStatus(ctx, exec)
+		assert.NilError(t, err)
+		assert.Assert(t, !status.Failing())
+		assert.Assert(t, status.OldestReadyTime == nil)
+	})
+}
@@ -0,0 +1,146 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	pgpassword "github.com/crunchydata/postgres-operator/internal/postgres/password"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// PasswordEncryption returns the format of passwords that PGO stores in
+// PostgreSQL for cluster: "md5" or "scram-sha-256".
+// - https://www.postgresql.org/docs/current/runtime-config-connection.html#GUC-PASSWORD-ENCRYPTION
+func PasswordEncryption(cluster *v1beta1.PostgresCluster) string {
+	if spec := cluster.Spec.Authentication; spec != nil && spec.PasswordEncryption != "" {
+		return spec.PasswordEncryption
+	}
+	return "scram-sha-256"
+}
+
+// PasswordMethod returns the authentication method that accepts passwords in
+// the format of [PasswordEncryption].
+// - https://www.postgresql.org/docs/current/auth-password.html
+func PasswordMethod(cluster *v1beta1.PostgresCluster) string {
+	// The "md5" method accepts passwords in either format.
+	if PasswordEncryption(cluster) == "md5" {
+		return "md5"
+	}
+	return "scram-sha-256"
+}
+
+// RequireChannelBinding returns whether or not password connections to
+// cluster over TLS must use SCRAM-SHA-256 so that clients can require
+// channel binding.
+func RequireChannelBinding(cluster *v1beta1.PostgresCluster) bool {
+	spec := cluster.Spec.Authentication
+	return spec != nil && spec.ChannelBinding == "require"
+}
+
+// RejectUndeclaredDatabases returns whether or not password connections to
+// databases that are not declared in the spec of cluster are rejected.
+func RejectUndeclaredDatabases(cluster *v1beta1.PostgresCluster) bool {
+	spec := cluster.Spec.Authentication
+	return spec != nil && spec.UndeclaredDatabases == "reject"
+}
+
+// DeclaredDatabases returns the sorted names of databases in spec.databases
+// and spec.users of cluster. When spec.users is unspecified, PGO creates one
+// database named after cluster, so that is declared too.
+func DeclaredDatabases(cluster *v1beta1.PostgresCluster) []string {
+	databases := sets.NewString()
+	if cluster.Spec.Users == nil {
+		// Database names cannot be too long. PostgresCluster.Name is a DNS
+		// subdomain, so use len() to count characters.
+		if len(cluster.Name) <= 63 {
+			databases.Insert(cluster.Name)
+		}
+	}
+	for _, user := range cluster.Spec.Users {
+		for _, database := range user.Databases {
+			databases.Insert(string(database))
+		}
+	}
+	for _, database := range cluster.Spec.Databases {
+		databases.Insert(string(database.Name))
+	}
+	return databases.List()
+}
+
+// NewPasswordVerifier returns the verifier of password for username in the
+// format of [PasswordEncryption]. SCRAM verifiers use the number of iterations
+// in spec.authentication.scramIterations, when set.
+func NewPasswordVerifier(cluster *v1beta1.PostgresCluster, username, password string) (string, error) {
+	if PasswordEncryption(cluster) == "md5" {
+		return pgpassword.NewMD5Password(username, password).Build()
+	}
+
+	scram := pgpassword.NewSCRAMPassword(password)
+	if spec := cluster.Spec.Authentication; spec != nil && spec.SCRAMIterations != nil {
+		scram.Iterations = int(*spec.SCRAMIterations)
+	}
+	return scram.Build()
+}
+
+// PasswordVerifierMatches returns whether or not verifier is in the format of
+// [PasswordEncryption].
+func PasswordVerifierMatches(cluster *v1beta1.PostgresCluster, verifier string) bool {
+	if PasswordEncryption(cluster) == "md5" {
+		return strings.HasPrefix(verifier, "md5")
+	}
+	return strings.HasPrefix(verifier, "SCRAM-SHA-256$")
+}
+
+// SetAuthentication modifies outHBAs and outParameters according to the
+// authentication settings of inCluster.
+func SetAuthentication(inCluster *v1beta1.PostgresCluster, outHBAs *HBAs, outParameters *Parameters) {
+	if spec := inCluster.Spec.Authentication; spec != nil && spec.PasswordEncryption != "" {
+		outParameters.Mandatory.Add("password_encryption", spec.PasswordEncryption)
+	}
+
+	// The "scram-sha-256" method rejects MD5 passwords and offers channel
+	// binding to TLS connections.
+	// - https://www.postgresql.org/docs/current/sasl-authentication.html#SASL-SCRAM-SHA-256
+	if RequireChannelBinding(inCluster) {
+		for i := range outHBAs.Default {
+			if outHBAs.Default[i].method == "md5" {
+				outHBAs.Default[i].method = "scram-sha-256"
+			}
+		}
+	}
+
+	// PostgreSQL uses the first record that matches a connection. Narrow the
+	// records that match every database to those that are declared, and reject
+	// whatever connections remain.
+	// - https://www.postgresql.org/docs/current/auth-pg-hba-conf.html
+	if RejectUndeclaredDatabases(inCluster) {
+		declared := DeclaredDatabases(inCluster)
+		records := make([]HostBasedAuthentication, 0, len(outHBAs.Default)+1)
+		for _, record := range outHBAs.Default {
+			if record.database == "all" {
+				if len(declared) == 0 {
+					continue
+				}
+				record.Databases(declared...)
+			}
+			records = append(records, record)
+		}
+		outHBAs.Default = append(records, *NewHBA().TCP().Method("reject"))
+	}
+}
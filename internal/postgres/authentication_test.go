@@ -0,0 +1,152 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestPasswordVerifier(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+
+	t.Run("Default", func(t *testing.T) {
+		assert.Equal(t, PasswordEncryption(cluster), "scram-sha-256")
+		assert.Equal(t, PasswordMethod(cluster), "scram-sha-256")
+
+		verifier, err := NewPasswordVerifier(cluster, "someone", "secret")
+		assert.NilError(t, err)
+		assert.Assert(t, strings.HasPrefix(verifier, "SCRAM-SHA-256$"), "got %q", verifier)
+		assert.Assert(t, PasswordVerifierMatches(cluster, verifier))
+		assert.Assert(t, !PasswordVerifierMatches(cluster, "md5abc"))
+	})
+
+	t.Run("MD5", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+			PasswordEncryption: "md5",
+		}
+
+		assert.Equal(t, PasswordEncryption(cluster), "md5")
+		assert.Equal(t, PasswordMethod(cluster), "md5")
+
+		verifier, err := NewPasswordVerifier(cluster, "someone", "secret")
+		assert.NilError(t, err)
+		assert.Assert(t, strings.HasPrefix(verifier, "md5"), "got %q", verifier)
+		assert.Assert(t, PasswordVerifierMatches(cluster, verifier))
+		assert.Assert(t, !PasswordVerifierMatches(cluster, "SCRAM-SHA-256$4096:abc"))
+	})
+
+	t.Run("SCRAMIterations", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+			SCRAMIterations: initialize.Int32(10000),
+		}
+
+		verifier, err := NewPasswordVerifier(cluster, "someone", "secret")
+		assert.NilError(t, err)
+		assert.Assert(t, strings.HasPrefix(verifier, "SCRAM-SHA-256$10000:"), "got %q", verifier)
+		assert.Assert(t, PasswordVerifierMatches(cluster, verifier))
+	})
+}
+
+func TestSetAuthentication(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		hbas, parameters := NewHBAs(), NewParameters()
+		SetAuthentication(cluster, &hbas, &parameters)
+
+		assert.Assert(t, !parameters.Mandatory.Has("password_encryption"))
+		assert.Equal(t, parameters.Default.Value("password_encryption"), "scram-sha-256")
+		assert.Equal(t, len(hbas.Default), 1)
+		assert.Equal(t, hbas.Default[0].String(), `hostssl all all all md5`)
+	})
+
+	t.Run("PasswordEncryption", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+			PasswordEncryption: "md5",
+		}
+
+		hbas, parameters := NewHBAs(), NewParameters()
+		SetAuthentication(cluster, &hbas, &parameters)
+
+		assert.Equal(t, parameters.Mandatory.Value("password_encryption"), "md5")
+	})
+
+	t.Run("ChannelBinding", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+			ChannelBinding: "require",
+		}
+
+		hbas, parameters := NewHBAs(), NewParameters()
+		SetAuthentication(cluster, &hbas, &parameters)
+
+		assert.Equal(t, len(hbas.Default), 1)
+		assert.Equal(t, hbas.Default[0].String(), `hostssl all all all scram-sha-256`)
+	})
+
+	t.Run("UndeclaredDatabases", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Name = "hippo"
+		cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+			UndeclaredDatabases: "reject",
+		}
+		cluster.Spec.Databases = []v1beta1.PostgresDatabaseSpec{{Name: "zoo"}}
+
+		hbas, parameters := NewHBAs(), NewParameters()
+		SetAuthentication(cluster, &hbas, &parameters)
+
+		assert.Equal(t, len(hbas.Default), 2)
+		assert.Equal(t, hbas.Default[0].String(), `hostssl "hippo","zoo" all all md5`)
+		assert.Equal(t, hbas.Default[1].String(), `host all all all reject`)
+
+		t.Run("NoneDeclared", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Databases = nil
+			cluster.Spec.Users = []v1beta1.PostgresUserSpec{{Name: "hippo"}}
+
+			hbas, parameters := NewHBAs(), NewParameters()
+			SetAuthentication(cluster, &hbas, &parameters)
+
+			assert.Equal(t, len(hbas.Default), 1)
+			assert.Equal(t, hbas.Default[0].String(), `host all all all reject`)
+		})
+	})
+}
+
+func TestDeclaredDatabases(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Name = "hippo"
+	assert.DeepEqual(t, DeclaredDatabases(cluster), []string{"hippo"})
+
+	cluster.Spec.Users = []v1beta1.PostgresUserSpec{
+		{Name: "one", Databases: []v1beta1.PostgresIdentifier{"zoo", "aquarium"}},
+		{Name: "two", Databases: []v1beta1.PostgresIdentifier{"zoo"}},
+	}
+	cluster.Spec.Databases = []v1beta1.PostgresDatabaseSpec{{Name: "barn"}}
+	assert.DeepEqual(t, DeclaredDatabases(cluster), []string{"aquarium", "barn", "zoo"})
+
+	cluster.Name = strings.Repeat("h", 64)
+	cluster.Spec.Users = nil
+	assert.DeepEqual(t, DeclaredDatabases(cluster), []string{"barn"})
+}
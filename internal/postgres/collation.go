@@ -0,0 +1,127 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+)
+
+// collationVersionsSQL prints the name of the current database when the
+// version of any of its collations, or of its default collation, differs from
+// the version provided by the operating system or ICU library. Indexes that
+// depend on those collations may be corrupt and should be rebuilt.
+// - https://www.postgresql.org/docs/current/sql-altercollation.html#SQL-ALTERCOLLATION-NOTES
+//
+// PostgreSQL 15 records the version of the default collation of each database
+// in "pg_database.datcollversion". Earlier versions do not have that column.
+const collationVersionsSQL = `
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+
+SELECT pg_catalog.current_database()
+ WHERE EXISTS (
+       SELECT 1 FROM pg_catalog.pg_collation
+        WHERE collversion IS NOT NULL
+          AND collversion IS DISTINCT FROM pg_catalog.pg_collation_actual_version(oid));
+
+SELECT pg_catalog.current_setting('server_version_num')::integer >= 150000 AS datcollversion
+\gset
+\if :datcollversion
+SELECT datname FROM pg_catalog.pg_database
+ WHERE datname = pg_catalog.current_database()
+   AND datcollversion IS NOT NULL
+   AND datcollversion IS DISTINCT FROM pg_catalog.pg_database_collation_actual_version(oid);
+\endif
+`
+
+// DatabasesWithCollationMismatch calls exec to find the databases that allow
+// connections and use a collation whose version has changed since it was
+// recorded, such as after an update to the operating system of the image.
+// The returned names are sorted.
+func DatabasesWithCollationMismatch(ctx context.Context, exec Executor) ([]string, error) {
+	log := logging.FromContext(ctx)
+
+	stdout, stderr, err := exec.ExecInAllDatabases(ctx, collationVersionsSQL,
+		map[string]string{
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("checked collation versions", "stdout", stdout, "stderr", stderr)
+
+	databases := sets.NewString()
+	for _, line := range strings.Split(stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			databases.Insert(line)
+		}
+	}
+
+	return databases.List(), err
+}
+
+// ReindexForCollationsInPostgreSQL calls exec to rebuild every index in
+// database then record the current versions of its collations. Rebuilding
+// blocks writes to each table while its indexes are rebuilt.
+// - https://www.postgresql.org/docs/current/sql-reindex.html
+func ReindexForCollationsInPostgreSQL(
+	ctx context.Context, exec Executor, database string,
+) error {
+	log := logging.FromContext(ctx)
+
+	// REINDEX DATABASE cannot run inside a transaction block and only applies
+	// to the current database. Collation versions are refreshed afterward so
+	// that an interrupted rebuild is attempted again.
+	// - https://www.postgresql.org/docs/current/sql-alterdatabase.html
+	const sql = `
+SET search_path TO '';
+
+SELECT pg_catalog.format('REINDEX DATABASE %I', pg_catalog.current_database())
+\gexec
+
+SELECT pg_catalog.format('ALTER COLLATION %I.%I REFRESH VERSION', n.nspname, c.collname)
+  FROM pg_catalog.pg_collation AS c
+  JOIN pg_catalog.pg_namespace AS n ON n.oid = c.collnamespace
+ WHERE c.collversion IS NOT NULL
+   AND c.collversion IS DISTINCT FROM pg_catalog.pg_collation_actual_version(c.oid)
+\gexec
+
+SELECT pg_catalog.current_setting('server_version_num')::integer >= 150000 AS datcollversion
+\gset
+\if :datcollversion
+SELECT pg_catalog.format('ALTER DATABASE %I REFRESH COLLATION VERSION', pg_catalog.current_database())
+\gexec
+\endif
+`
+
+	stdout, stderr, err := exec.ExecInDatabasesFromQuery(ctx,
+		`SELECT :'database'`, sql,
+		map[string]string{
+			"database": database,
+
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("rebuilt indexes", "database", database, "stdout", stdout, "stderr", stderr)
+
+	return err
+}
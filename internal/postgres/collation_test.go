@@ -0,0 +1,97 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+)
+
+func TestDatabasesWithCollationMismatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Assert(t, cmp.Contains(string(b), `pg_collation_actual_version`))
+			assert.Assert(t, cmp.Contains(string(b), `pg_database_collation_actual_version`))
+
+			// The query runs in every database that allows connections.
+			assert.Assert(t, cmp.Contains(command[len(command)-3], `datallowconn`))
+			return expected
+		}
+
+		_, err := DatabasesWithCollationMismatch(ctx, exec)
+		assert.Equal(t, expected, err)
+	})
+
+	t.Run("Output", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte("zoo\n\nzoo\naquarium\n"))
+			return nil
+		}
+
+		databases, err := DatabasesWithCollationMismatch(ctx, exec)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, databases, []string{"aquarium", "zoo"})
+	})
+
+	t.Run("None", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, _, _ io.Writer, _ ...string,
+		) error {
+			return nil
+		}
+
+		databases, err := DatabasesWithCollationMismatch(ctx, exec)
+		assert.NilError(t, err)
+		assert.Equal(t, len(databases), 0)
+	})
+}
+
+func TestReindexForCollationsInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	expected := errors.New("pass-through")
+	exec := func(
+		_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		assert.Assert(t, stdout != nil, "should capture stdout")
+		assert.Assert(t, stderr != nil, "should capture stderr")
+		assert.Assert(t, cmp.Contains(command, "--set=database=zoo"))
+
+		b, err := io.ReadAll(stdin)
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Contains(string(b), `REINDEX DATABASE %I`))
+		assert.Assert(t, cmp.Contains(string(b), `REFRESH VERSION`))
+		assert.Assert(t, cmp.Contains(string(b), `REFRESH COLLATION VERSION`))
+		return expected
+	}
+
+	assert.Equal(t, expected, ReindexForCollationsInPostgreSQL(ctx, exec, "zoo"))
+}
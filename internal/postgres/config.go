@@ -197,6 +197,60 @@ done
 	return []string{"bash", "-ceu", "--", wrapper, name}
 }
 
+// logCleanupCommand returns an entrypoint that periodically deletes old log
+// files from the PostgreSQL and pgBackRest log directories on the data volume
+// according to retention. The process will appear as name in `ps` and `top`.
+func logCleanupCommand(
+	cluster *v1beta1.PostgresCluster, retention *v1beta1.PostgresLogRetentionSpec, name string,
+) []string {
+	// An empty value disables that part of the policy.
+	var maxAge, maxSize string
+	if retention.MaxAgeHours != nil {
+		maxAge = fmt.Sprint(*retention.MaxAgeHours * 60)
+	}
+	if retention.MaxSize != nil {
+		maxSize = fmt.Sprint(retention.MaxSize.Value())
+	}
+
+	// Use a Bash loop to check each directory every five minutes. Files are
+	// listed newest first so that the most recent file, which may still be
+	// open for writing, is never deleted. Older files are deleted when they
+	// are older than the maximum age or once the files before them fill the
+	// maximum size.
+	//
+	// Like reloadCommand, this waits using the timeout of the builtin `read`.
+	script := `
+exec {fd}<> <(:)
+while true; do
+  for directory in "${server_directory}" "${pgbackrest_directory}"; do
+    [ -d "${directory}" ] || continue
+    declare -i total=0 newest=1
+    printf -v now '%(%s)T' -1
+    while IFS=' ' read -r mtime size file; do
+      total+="${size}"
+      if (( newest )); then newest=0; continue; fi
+      if { [ -n "${max_age}" ] && (( mtime < now - max_age * 60 )); } ||
+        { [ -n "${max_size}" ] && (( total > max_size )); }
+      then
+        rm -f -- "${file}" && echo "Deleted ${file}"
+      fi
+    done < <(find "${directory}" -maxdepth 1 -type f -printf '%T@ %s %p\n' |
+      sed 's/^\([0-9]*\)[.][0-9]* /\1 /' | sort -rn)
+  done
+  read -r -t 300 -u "${fd}" || true
+done
+`
+
+	// Elide the above script from `ps` and `top` by wrapping it in a function
+	// and calling that.
+	wrapper := `monitor() {` + script + `};` +
+		` export max_age="$1" max_size="$2" server_directory="$3" pgbackrest_directory="$4";` +
+		` export -f monitor; exec -a "$0" bash -ceu monitor`
+
+	return []string{"bash", "-ceu", "--", wrapper, name,
+		maxAge, maxSize, DataDirectory(cluster) + "/log", naming.PGBackRestPGDataLogPath}
+}
+
 // startupCommand returns an entrypoint that prepares the filesystem for
 // PostgreSQL.
 func startupCommand(
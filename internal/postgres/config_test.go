@@ -17,6 +17,7 @@ package postgres
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -24,11 +25,14 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"sigs.k8s.io/yaml"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/internal/util"
@@ -464,6 +468,110 @@ func TestBashSafeLink(t *testing.T) {
 	})
 }
 
+func TestLogCleanupCommand(t *testing.T) {
+	// macOS `find` does not have the -printf action.
+	if output, err := exec.Command("find", "--help").CombinedOutput(); err != nil {
+		t.Skip(`requires "find" executable`)
+	} else if !strings.Contains(string(output), "-printf") {
+		t.Skip(`requires "find" with -printf action`)
+	}
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.PostgresVersion = 13
+
+	t.Run("ShellCheck", func(t *testing.T) {
+		shellcheck := require.ShellCheck(t)
+		command := logCleanupCommand(cluster, new(v1beta1.PostgresLogRetentionSpec), "x")
+
+		// Expect a bash command with an inline script.
+		assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+		assert.Assert(t, len(command) > 3)
+
+		// Write out that inline script.
+		dir := t.TempDir()
+		file := filepath.Join(dir, "script.bash")
+		assert.NilError(t, os.WriteFile(file, []byte(command[3]), 0o600))
+
+		// Expect shellcheck to be happy.
+		cmd := exec.Command(shellcheck, "--enable=all", file)
+		output, err := cmd.CombinedOutput()
+		assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+	})
+
+	t.Run("Arguments", func(t *testing.T) {
+		command := logCleanupCommand(cluster,
+			&v1beta1.PostgresLogRetentionSpec{
+				MaxAgeHours: initialize.Int32(24),
+				MaxSize:     resource.NewQuantity(1<<20, resource.BinarySI),
+			}, "log-cleanup")
+
+		assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+		assert.DeepEqual(t, command[4:], []string{
+			"log-cleanup", "1440", "1048576", "/pgdata/pg13/log", "/pgdata/pgbackrest/log",
+		})
+
+		command = logCleanupCommand(cluster, new(v1beta1.PostgresLogRetentionSpec), "x")
+		assert.DeepEqual(t, command[5:7], []string{"", ""})
+	})
+
+	t.Run("Deletes", func(t *testing.T) {
+		dir := t.TempDir()
+		server, backrest := filepath.Join(dir, "log"), filepath.Join(dir, "pgbackrest")
+		assert.NilError(t, os.Mkdir(server, 0o700))
+		assert.NilError(t, os.Mkdir(backrest, 0o700))
+
+		write := func(name string, size int, age time.Duration) {
+			assert.NilError(t, os.WriteFile(name, make([]byte, size), 0o600))
+			mtime := time.Now().Add(-age)
+			assert.NilError(t, os.Chtimes(name, mtime, mtime))
+		}
+		write(filepath.Join(server, "a.log"), 100, 1*time.Hour)
+		write(filepath.Join(server, "b.log"), 100, 2*time.Hour)
+		write(filepath.Join(server, "c.log"), 100, 3*time.Hour)
+		write(filepath.Join(server, "d.log"), 100, 6*time.Hour)
+		write(filepath.Join(backrest, "old.log"), 100, 8*time.Hour)
+
+		command := logCleanupCommand(cluster,
+			&v1beta1.PostgresLogRetentionSpec{
+				MaxAgeHours: initialize.Int32(4),
+				MaxSize:     resource.NewQuantity(250, resource.DecimalSI),
+			}, "log-cleanup")
+		command[len(command)-2] = server
+		command[len(command)-1] = backrest
+
+		// The script loops forever; stop it once it has printed its first pass.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		t.Cleanup(cancel)
+
+		stdout, err := os.Create(filepath.Join(dir, "stdout"))
+		assert.NilError(t, err)
+		t.Cleanup(func() { stdout.Close() })
+
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Stdout = stdout
+		assert.NilError(t, cmd.Start())
+		for ctx.Err() == nil {
+			if b, _ := os.ReadFile(stdout.Name()); bytes.Count(b, []byte("Deleted")) >= 2 {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		cancel()
+		_ = cmd.Wait()
+
+		// The most recent file in each directory is kept regardless of age.
+		// The oldest file is too old, and the third file exceeds the size.
+		entries, err := os.ReadDir(server)
+		assert.NilError(t, err)
+		assert.Equal(t, len(entries), 2)
+		assert.Equal(t, entries[0].Name(), "a.log")
+		assert.Equal(t, entries[1].Name(), "b.log")
+
+		_, err = os.Stat(filepath.Join(backrest, "old.log"))
+		assert.NilError(t, err)
+	})
+}
+
 func TestStartupCommand(t *testing.T) {
 	shellcheck := require.ShellCheck(t)
 
@@ -0,0 +1,214 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+)
+
+// CreateExtensionsInPostgreSQL calls exec to create extensions that do not
+// exist in database. Their objects go into the "public" schema. The database
+// must already exist.
+func CreateExtensionsInPostgreSQL(
+	ctx context.Context, exec Executor, database string, extensions []string,
+) error {
+	log := logging.FromContext(ctx)
+
+	var err error
+	var line bytes.Buffer
+	var sql bytes.Buffer
+
+	// Prevent unexpected dereferences by emptying "search_path". The "pg_catalog"
+	// schema is still searched, and only temporary objects can be created.
+	// - https://www.postgresql.org/docs/current/runtime-config-client.html#GUC-SEARCH-PATH
+	_, _ = sql.WriteString(`SET search_path TO '';`)
+
+	// Fill a temporary table with the JSON of the extension names. "\copy"
+	// reads from subsequent lines until the special line "\.". Its text format
+	// interprets backslashes, so those in the JSON are doubled.
+	// - https://www.postgresql.org/docs/current/app-psql.html#APP-PSQL-META-COMMANDS-COPY
+	_, _ = sql.WriteString(`
+CREATE TEMPORARY TABLE input (id serial, data json);
+\copy input (data) from stdin with (format text)
+`)
+	encoder := json.NewEncoder(&line)
+	encoder.SetEscapeHTML(false)
+
+	for i := range extensions {
+		if err == nil {
+			line.Reset()
+			err = encoder.Encode(map[string]interface{}{
+				"extension": extensions[i],
+			})
+			_, _ = sql.Write(bytes.ReplaceAll(line.Bytes(), []byte(`\`), []byte(`\\`)))
+		}
+	}
+	_, _ = sql.WriteString(`\.` + "\n")
+
+	// Create extensions that do not already exist.
+	// - https://www.postgresql.org/docs/current/sql-createextension.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.format('CREATE EXTENSION IF NOT EXISTS %I WITH SCHEMA public',
+       pg_catalog.json_extract_path_text(input.data, 'extension'))
+  FROM input
+ ORDER BY input.id
+\gexec
+`)
+
+	if err != nil {
+		return err
+	}
+
+	stdout, stderr, err := exec.ExecInDatabasesFromQuery(ctx,
+		`SELECT :'database'`, sql.String(),
+		map[string]string{
+			"database": database,
+
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("created PostgreSQL extensions",
+		"database", database, "stdout", stdout, "stderr", stderr)
+
+	return err
+}
+
+// outdatedExtensionsSQL selects the names of installed extensions whose version
+// differs from the default version of the installation. When the "extensions"
+// variable is a non-empty JSON array, only those extensions are selected.
+// - https://www.postgresql.org/docs/current/view-pg-available-extensions.html
+const outdatedExtensionsSQL = `
+SELECT e.extname
+  FROM pg_catalog.pg_extension AS e
+  JOIN pg_catalog.pg_available_extensions AS a ON a.name = e.extname
+ WHERE a.default_version IS NOT NULL
+   AND e.extversion IS DISTINCT FROM a.default_version
+   AND (pg_catalog.json_array_length(:'extensions') = 0
+     OR e.extname IN (SELECT pg_catalog.json_array_elements_text(:'extensions')))`
+
+// extensionsVariable returns the JSON array of extensions expected by
+// outdatedExtensionsSQL.
+func extensionsVariable(extensions []string) (string, error) {
+	if extensions == nil {
+		extensions = []string{}
+	}
+	b, err := json.Marshal(extensions)
+	return string(b), err
+}
+
+// DatabasesWithOutdatedExtensions calls exec to find the databases that allow
+// connections and have an installed extension that can be updated. When
+// extensions is empty, every installed extension is considered. The returned
+// names are sorted.
+func DatabasesWithOutdatedExtensions(
+	ctx context.Context, exec Executor, extensions []string,
+) ([]string, error) {
+	log := logging.FromContext(ctx)
+
+	value, err := extensionsVariable(extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, stderr, err := exec.ExecInAllDatabases(ctx, `
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+
+SELECT pg_catalog.current_database()
+ WHERE EXISTS (`+outdatedExtensionsSQL+`);
+`,
+		map[string]string{
+			"extensions": value,
+
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("checked extension versions", "stdout", stdout, "stderr", stderr)
+
+	databases := sets.NewString()
+	for _, line := range strings.Split(stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			databases.Insert(line)
+		}
+	}
+
+	return databases.List(), err
+}
+
+// UpdateExtensionsInPostgreSQL calls exec to update installed extensions in
+// database to the default versions of the installation. pg_upgrade does not
+// change the versions of extensions. When extensions is empty, every installed
+// extension is updated. It returns the sorted names of extensions it updated.
+// - https://www.postgresql.org/docs/current/sql-alterextension.html
+func UpdateExtensionsInPostgreSQL(
+	ctx context.Context, exec Executor, database string, extensions []string,
+) ([]string, error) {
+	log := logging.FromContext(ctx)
+
+	value, err := extensionsVariable(extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Print the name of each extension after it is updated so that those
+	// updated before any failure are reported.
+	stdout, stderr, err := exec.ExecInDatabasesFromQuery(ctx,
+		`SELECT :'database'`, `
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+
+SELECT pg_catalog.format('ALTER EXTENSION %I UPDATE; SELECT %L', extname, extname)
+  FROM (`+outdatedExtensionsSQL+`) AS outdated
+ ORDER BY extname
+\gexec
+`,
+		map[string]string{
+			"database":   database,
+			"extensions": value,
+
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("updated PostgreSQL extensions",
+		"database", database, "stdout", stdout, "stderr", stderr)
+
+	// Report the message of the statement that failed.
+	if err != nil {
+		err = fmt.Errorf("%w: %v", err, strings.TrimSpace(stderr))
+	}
+
+	updated := sets.NewString()
+	for _, line := range strings.Split(stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			updated.Insert(line)
+		}
+	}
+
+	return updated.List(), err
+}
@@ -0,0 +1,155 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+)
+
+func TestCreateExtensionsInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			assert.Assert(t, cmp.Contains(command, "--set=database=zoo"))
+			return expected
+		}
+
+		assert.Equal(t, expected, CreateExtensionsInPostgreSQL(ctx, exec, "zoo", nil))
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), strings.TrimLeft(`
+SET search_path TO '';
+CREATE TEMPORARY TABLE input (id serial, data json);
+\copy input (data) from stdin with (format text)
+{"extension":"vector"}
+{"extension":"back\\\\slash"}
+\.
+
+SELECT pg_catalog.format('CREATE EXTENSION IF NOT EXISTS %I WITH SCHEMA public',
+       pg_catalog.json_extract_path_text(input.data, 'extension'))
+  FROM input
+ ORDER BY input.id
+\gexec
+`, "\n"))
+			return nil
+		}
+
+		assert.NilError(t, CreateExtensionsInPostgreSQL(ctx, exec, "zoo",
+			[]string{"vector", `back\slash`}))
+		assert.Equal(t, calls, 1)
+	})
+}
+
+func TestDatabasesWithOutdatedExtensions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Assert(t, cmp.Contains(string(b), `pg_available_extensions`))
+			assert.Assert(t, cmp.Contains(command, `--set=extensions=[]`))
+
+			// The query runs in every database that allows connections.
+			assert.Assert(t, cmp.Contains(command[len(command)-4], `datallowconn`))
+			return expected
+		}
+
+		_, err := DatabasesWithOutdatedExtensions(ctx, exec, nil)
+		assert.Equal(t, expected, err)
+	})
+
+	t.Run("Output", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, command ...string,
+		) error {
+			assert.Assert(t, cmp.Contains(command, `--set=extensions=["postgis","pg_cron"]`))
+			_, _ = stdout.Write([]byte("zoo\n\nzoo\naquarium\n"))
+			return nil
+		}
+
+		databases, err := DatabasesWithOutdatedExtensions(ctx, exec,
+			[]string{"postgis", "pg_cron"})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, databases, []string{"aquarium", "zoo"})
+	})
+}
+
+func TestUpdateExtensionsInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			assert.Assert(t, cmp.Contains(command, "--set=database=zoo"))
+			assert.Assert(t, cmp.Contains(command, `--set=extensions=["postgis"]`))
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Assert(t, cmp.Contains(string(b), `ALTER EXTENSION %I UPDATE`))
+			return nil
+		}
+
+		updated, err := UpdateExtensionsInPostgreSQL(ctx, exec, "zoo", []string{"postgis"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(updated), 0)
+	})
+
+	t.Run("Partial", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, _ io.Reader, stdout, stderr io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte("pg_stat_statements\naddress_standardizer\n"))
+			_, _ = stderr.Write([]byte("ERROR:  no update path\n"))
+			return expected
+		}
+
+		updated, err := UpdateExtensionsInPostgreSQL(ctx, exec, "zoo", nil)
+		assert.ErrorIs(t, err, expected)
+		assert.ErrorContains(t, err, "no update path")
+		assert.DeepEqual(t, updated, []string{"address_standardizer", "pg_stat_statements"})
+	})
+}
@@ -0,0 +1,232 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// WriteForeignServersInPostgreSQL calls exec to create the foreign-data wrapper
+// extensions, foreign servers, and user mappings of database. Once they exist,
+// it adds or sets their options. Options of each user mapping come from its
+// Secret in credentials, keyed by Secret name. The database and the users of
+// each mapping must already exist.
+func WriteForeignServersInPostgreSQL(
+	ctx context.Context, exec Executor,
+	database v1beta1.PostgresDatabaseSpec, credentials map[string]map[string]string,
+) error {
+	log := logging.FromContext(ctx)
+
+	sql, err := writeForeignServersSQL(database, credentials)
+	if err != nil {
+		return err
+	}
+
+	stdout, stderr, err := exec.ExecInDatabasesFromQuery(ctx,
+		`SELECT :'database'`, sql.String(),
+		map[string]string{
+			"database": string(database.Name),
+
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("wrote PostgreSQL foreign servers",
+		"database", database.Name, "stdout", stdout, "stderr", stderr)
+
+	return err
+}
+
+// writeForeignServersSQL returns the psql script that
+// WriteForeignServersInPostgreSQL executes for database and credentials.
+func writeForeignServersSQL(
+	database v1beta1.PostgresDatabaseSpec, credentials map[string]map[string]string,
+) (*bytes.Buffer, error) {
+	var err error
+	var line bytes.Buffer
+	var sql bytes.Buffer
+
+	// Prevent unexpected dereferences by emptying "search_path". The "pg_catalog"
+	// schema is still searched, and only temporary objects can be created.
+	// - https://www.postgresql.org/docs/current/runtime-config-client.html#GUC-SEARCH-PATH
+	_, _ = sql.WriteString(`SET search_path TO '';`)
+
+	// Fill a temporary table with the JSON of the server and user mapping
+	// specifications. "\copy" reads from subsequent lines until the special
+	// line "\.". Its text format interprets backslashes, so those in the JSON
+	// are doubled.
+	// - https://www.postgresql.org/docs/current/app-psql.html#APP-PSQL-META-COMMANDS-COPY
+	_, _ = sql.WriteString(`
+CREATE TEMPORARY TABLE input (id serial, data json);
+\copy input (data) from stdin with (format text)
+`)
+	encoder := json.NewEncoder(&line)
+	encoder.SetEscapeHTML(false)
+
+	encode := func(value map[string]interface{}) {
+		if err == nil {
+			line.Reset()
+			err = encoder.Encode(value)
+			_, _ = sql.Write(bytes.ReplaceAll(line.Bytes(), []byte(`\`), []byte(`\\`)))
+		}
+	}
+
+	for _, server := range database.FDW {
+		options := server.Options
+		if options == nil {
+			options = map[string]string{}
+		}
+		encode(map[string]interface{}{
+			"options": options,
+			"server":  server.Name,
+			"wrapper": server.Wrapper,
+		})
+
+		for _, mapping := range server.UserMappings {
+			options := make(map[string]string, len(mapping.Options))
+			for k, v := range mapping.Options {
+				options[k] = v
+			}
+			if mapping.Secret != nil {
+				for k, v := range credentials[mapping.Secret.Name] {
+					options[k] = v
+				}
+			}
+			encode(map[string]interface{}{
+				"options": options,
+				"server":  server.Name,
+				"user":    mapping.User,
+			})
+		}
+	}
+	_, _ = sql.WriteString(`\.` + "\n")
+
+	// Create the following objects in a transaction so that permissions are
+	// correct before any other session sees them.
+	// - https://www.postgresql.org/docs/current/ddl-priv.html
+	_, _ = sql.WriteString(`BEGIN;`)
+
+	// Create the extension of each foreign-data wrapper that does not already
+	// exist. Its objects go into the "public" schema.
+	// - https://www.postgresql.org/docs/current/sql-createextension.html
+	_, _ = sql.WriteString(`
+SELECT DISTINCT pg_catalog.format('CREATE EXTENSION IF NOT EXISTS %I WITH SCHEMA public',
+       pg_catalog.json_extract_path_text(input.data, 'wrapper'))
+  FROM input
+ WHERE pg_catalog.json_extract_path_text(input.data, 'wrapper') IS NOT NULL
+\gexec
+`)
+
+	// Create servers that do not already exist. Options are set later.
+	// - https://www.postgresql.org/docs/current/sql-createserver.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.format('CREATE SERVER %I FOREIGN DATA WRAPPER %I',
+       pg_catalog.json_extract_path_text(input.data, 'server'),
+       pg_catalog.json_extract_path_text(input.data, 'wrapper'))
+  FROM input
+ WHERE pg_catalog.json_extract_path_text(input.data, 'wrapper') IS NOT NULL
+   AND NOT EXISTS (
+       SELECT 1 FROM pg_catalog.pg_foreign_server
+       WHERE srvname = pg_catalog.json_extract_path_text(input.data, 'server'))
+ ORDER BY input.id
+\gexec
+`)
+
+	// Add or set any server options from the specification. Options that
+	// are not in the specification are left alone.
+	// - https://www.postgresql.org/docs/current/sql-alterserver.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.format('ALTER SERVER %I OPTIONS (%s)', srv.srvname,
+       pg_catalog.string_agg(pg_catalog.format('%s %I %L',
+         CASE WHEN opt.option_name IS NULL THEN 'ADD' ELSE 'SET' END,
+         spec.key, spec.value), ', ' ORDER BY spec.key))
+  FROM input
+  JOIN pg_catalog.pg_foreign_server AS srv
+    ON srv.srvname = pg_catalog.json_extract_path_text(input.data, 'server')
+ CROSS JOIN LATERAL pg_catalog.json_each_text(
+       pg_catalog.json_extract_path(input.data, 'options')) AS spec
+  LEFT JOIN LATERAL pg_catalog.pg_options_to_table(srv.srvoptions) AS opt
+    ON opt.option_name = spec.key
+ WHERE pg_catalog.json_extract_path_text(input.data, 'wrapper') IS NOT NULL
+ GROUP BY input.id, srv.srvname
+ ORDER BY input.id
+\gexec
+`)
+
+	// Create user mappings that do not already exist and allow their users
+	// to create foreign tables using the server. PUBLIC applies to every user.
+	// - https://www.postgresql.org/docs/current/sql-createusermapping.html
+	// - https://www.postgresql.org/docs/current/sql-grant.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.format('CREATE USER MAPPING IF NOT EXISTS FOR %s SERVER %I',
+       CASE WHEN pg_catalog.json_extract_path_text(input.data, 'user') = 'PUBLIC'
+            THEN 'PUBLIC'
+            ELSE pg_catalog.quote_ident(pg_catalog.json_extract_path_text(input.data, 'user'))
+        END,
+       pg_catalog.json_extract_path_text(input.data, 'server'))
+  FROM input
+ WHERE pg_catalog.json_extract_path_text(input.data, 'user') IS NOT NULL
+ ORDER BY input.id
+\gexec
+
+SELECT pg_catalog.format('GRANT USAGE ON FOREIGN SERVER %I TO %s',
+       pg_catalog.json_extract_path_text(input.data, 'server'),
+       CASE WHEN pg_catalog.json_extract_path_text(input.data, 'user') = 'PUBLIC'
+            THEN 'PUBLIC'
+            ELSE pg_catalog.quote_ident(pg_catalog.json_extract_path_text(input.data, 'user'))
+        END)
+  FROM input
+ WHERE pg_catalog.json_extract_path_text(input.data, 'user') IS NOT NULL
+ ORDER BY input.id
+\gexec
+`)
+
+	// Add or set any user mapping options from the specification and Secret.
+	// Options that are not in either are left alone.
+	// - https://www.postgresql.org/docs/current/sql-alterusermapping.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.format('ALTER USER MAPPING FOR %s SERVER %I OPTIONS (%s)',
+       CASE WHEN um.umuser = 0 THEN 'PUBLIC' ELSE pg_catalog.quote_ident(um.usename) END,
+       um.srvname,
+       pg_catalog.string_agg(pg_catalog.format('%s %I %L',
+         CASE WHEN opt.option_name IS NULL THEN 'ADD' ELSE 'SET' END,
+         spec.key, spec.value), ', ' ORDER BY spec.key))
+  FROM input
+  JOIN pg_catalog.pg_user_mappings AS um
+    ON um.srvname = pg_catalog.json_extract_path_text(input.data, 'server')
+   AND um.usename = CASE
+       WHEN pg_catalog.json_extract_path_text(input.data, 'user') = 'PUBLIC' THEN 'public'
+       ELSE pg_catalog.json_extract_path_text(input.data, 'user') END
+ CROSS JOIN LATERAL pg_catalog.json_each_text(
+       pg_catalog.json_extract_path(input.data, 'options')) AS spec
+  LEFT JOIN LATERAL pg_catalog.pg_options_to_table(um.umoptions) AS opt
+    ON opt.option_name = spec.key
+ WHERE pg_catalog.json_extract_path_text(input.data, 'user') IS NOT NULL
+ GROUP BY input.id, um.umuser, um.usename, um.srvname
+ ORDER BY input.id
+\gexec
+`)
+
+	// Commit (finish) the transaction.
+	_, _ = sql.WriteString(`COMMIT;`)
+
+	return &sql, err
+}
@@ -0,0 +1,125 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/internal/testing/replay"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestWriteForeignServersInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			assert.Assert(t, cmp.Contains(command, "--set=database=zoo"))
+			return expected
+		}
+
+		assert.Equal(t, expected, WriteForeignServersInPostgreSQL(ctx, exec,
+			v1beta1.PostgresDatabaseSpec{Name: "zoo"}, nil))
+	})
+
+	t.Run("Credentials", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+
+			// Options from the Secret take precedence, and backslashes are
+			// escaped for "\copy".
+			assert.Assert(t, cmp.Contains(string(b), `
+\copy input (data) from stdin with (format text)
+{"options":{"dbserver":"//oracle:1521/ORCL"},"server":"oracle","wrapper":"oracle_fdw"}
+{"options":{"password":"back\\\\slash \\"quote\\"","user":"secret"},"server":"oracle","user":"hippo"}
+{"options":{},"server":"oracle","user":"PUBLIC"}
+\.
+`))
+			return nil
+		}
+
+		assert.NilError(t, WriteForeignServersInPostgreSQL(ctx, exec,
+			v1beta1.PostgresDatabaseSpec{
+				Name: "zoo",
+				FDW: []v1beta1.PostgresForeignServerSpec{{
+					Name:    "oracle",
+					Wrapper: "oracle_fdw",
+					Options: map[string]string{"dbserver": "//oracle:1521/ORCL"},
+					UserMappings: []v1beta1.PostgresUserMappingSpec{
+						{
+							User:    "hippo",
+							Secret:  &corev1.LocalObjectReference{Name: "hippo-oracle"},
+							Options: map[string]string{"user": "spec"},
+						},
+						{User: "PUBLIC"},
+					},
+				}},
+			},
+			map[string]map[string]string{
+				"hippo-oracle": {"user": "secret", "password": `back\slash "quote"`},
+			},
+		))
+		assert.Equal(t, calls, 1)
+	})
+
+	t.Run("Golden", func(t *testing.T) {
+		exec := new(replay.Executor)
+
+		assert.NilError(t, WriteForeignServersInPostgreSQL(ctx, exec.Exec,
+			v1beta1.PostgresDatabaseSpec{
+				Name: "zoo",
+				FDW: []v1beta1.PostgresForeignServerSpec{
+					{
+						Name:    "aquarium",
+						Wrapper: "postgres_fdw",
+						Options: map[string]string{"host": "aquarium-primary", "dbname": "fish"},
+						UserMappings: []v1beta1.PostgresUserMappingSpec{{
+							User:   "hippo",
+							Secret: &corev1.LocalObjectReference{Name: "hippo-aquarium"},
+						}},
+					},
+					{Name: "legacy", Wrapper: "oracle_fdw"},
+				},
+			},
+			map[string]map[string]string{
+				"hippo-aquarium": {"user": "keeper", "password": "fins"},
+			},
+		))
+
+		// Changes to the SQL show up as changes to this file. Update it by
+		// running "go test" with the -test.update-golden flag.
+		golden.Assert(t, exec.String(), "WriteForeignServersInPostgreSQL.golden")
+	})
+}
@@ -88,6 +88,16 @@ func (hba *HostBasedAuthentication) Database(name string) *HostBasedAuthenticati
 	return hba
 }
 
+// Databases makes hba match connections made to any of these databases.
+func (hba *HostBasedAuthentication) Databases(names ...string) *HostBasedAuthentication {
+	quoted := make([]string, len(names))
+	for i := range names {
+		quoted[i] = hba.quote(names[i])
+	}
+	hba.database = strings.Join(quoted, ",")
+	return hba
+}
+
 // Local makes hba match connection attempts using Unix-domain sockets.
 func (hba *HostBasedAuthentication) Local() *HostBasedAuthentication {
 	hba.origin = "local"
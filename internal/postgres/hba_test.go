@@ -68,6 +68,9 @@ func TestHostBasedAuthentication(t *testing.T) {
 			Method("md5").Options(map[string]string{"clientcert": "verify-ca"}).
 			String())
 
+	assert.Equal(t, `hostssl "one","t""wo" all all md5`,
+		NewHBA().TLS().Databases("one", `t"wo`).Method("md5").String())
+
 	assert.Equal(t, `hostnossl all all all reject`,
 		NewHBA().NoSSL().Method("reject").String())
 }
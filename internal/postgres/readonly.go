@@ -0,0 +1,38 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// ReadOnly returns true when cluster should refuse writes, either because of
+// its spec or because of its read-only annotation.
+func ReadOnly(cluster *v1beta1.PostgresCluster) bool {
+	return (cluster.Spec.ReadOnly != nil && *cluster.Spec.ReadOnly) ||
+		cluster.GetAnnotations()[naming.ReadOnly] == "true"
+}
+
+// SetReadOnly sets default_transaction_read_only when cluster should refuse
+// writes. Sessions can turn it off, so this guards against mistakes rather
+// than privileged users. Changing this value does not restart PostgreSQL.
+// - https://www.postgresql.org/docs/current/runtime-config-client.html#GUC-DEFAULT-TRANSACTION-READ-ONLY
+func SetReadOnly(cluster *v1beta1.PostgresCluster, parameters *Parameters) {
+	if ReadOnly(cluster) {
+		parameters.Mandatory.Add("default_transaction_read_only", "on")
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestSetReadOnly(t *testing.T) {
+	t.Run("Unset", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		parameters := NewParameters()
+		SetReadOnly(cluster, &parameters)
+
+		assert.Assert(t, !ReadOnly(cluster))
+		assert.Assert(t, !parameters.Mandatory.Has("default_transaction_read_only"))
+	})
+
+	t.Run("Spec", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Spec.ReadOnly = initialize.Bool(true)
+		parameters := NewParameters()
+		SetReadOnly(cluster, &parameters)
+
+		assert.Assert(t, ReadOnly(cluster))
+		assert.Equal(t, parameters.Mandatory.Value("default_transaction_read_only"), "on")
+
+		cluster.Spec.ReadOnly = initialize.Bool(false)
+		assert.Assert(t, !ReadOnly(cluster))
+	})
+
+	t.Run("Annotation", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Annotations = map[string]string{naming.ReadOnly: "true"}
+		parameters := NewParameters()
+		SetReadOnly(cluster, &parameters)
+
+		assert.Assert(t, ReadOnly(cluster))
+		assert.Equal(t, parameters.Mandatory.Value("default_transaction_read_only"), "on")
+
+		cluster.Annotations[naming.ReadOnly] = "false"
+		assert.Assert(t, !ReadOnly(cluster))
+	})
+}
@@ -274,6 +274,31 @@ func InstancePod(ctx context.Context,
 
 	outInstancePod.Containers = []corev1.Container{container, reloader}
 
+	// When a log retention policy is defined, add a container that deletes old
+	// log files from the data volume.
+	if inCluster.Spec.LogRetention != nil {
+		cleanup := corev1.Container{
+			Name: naming.ContainerLogCleanup,
+
+			Command: logCleanupCommand(inCluster, inCluster.Spec.LogRetention,
+				naming.ContainerLogCleanup),
+
+			Image:           container.Image,
+			ImagePullPolicy: container.ImagePullPolicy,
+			SecurityContext: initialize.RestrictedSecurityContext(),
+
+			VolumeMounts: []corev1.VolumeMount{dataVolumeMount},
+		}
+
+		if inInstanceSpec.Sidecars != nil &&
+			inInstanceSpec.Sidecars.LogCleanup != nil &&
+			inInstanceSpec.Sidecars.LogCleanup.Resources != nil {
+			cleanup.Resources = *inInstanceSpec.Sidecars.LogCleanup.Resources
+		}
+
+		outInstancePod.Containers = append(outInstancePod.Containers, cleanup)
+	}
+
 	// If the InstanceSidecars feature gate is enabled and instance sidecars are
 	// defined, add the defined container to the Pod.
 	if util.DefaultMutableFeatureGate.Enabled(util.InstanceSidecars) &&
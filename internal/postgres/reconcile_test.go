@@ -493,6 +493,45 @@ volumes:
   name: postgres-data`), "expected WAL mount, no downwardAPI mount in %q container", pod.InitContainers[0].Name)
 	})
 
+	t.Run("WithLogRetention", func(t *testing.T) {
+		clusterWithRetention := cluster.DeepCopy()
+		clusterWithRetention.Spec.LogRetention = &v1beta1.PostgresLogRetentionSpec{
+			MaxAgeHours: initialize.Int32(48),
+		}
+
+		pod := new(corev1.PodSpec)
+		InstancePod(ctx, clusterWithRetention, instance,
+			serverSecretProjection, clientSecretProjection, dataVolume, nil, nil, pod)
+
+		assert.Equal(t, len(pod.Containers), 3)
+		assert.Equal(t, pod.Containers[2].Name, "log-cleanup")
+		assert.DeepEqual(t, pod.Containers[2].Command[4:], []string{
+			"log-cleanup", "2880", "", "/pgdata/pg11/log", "/pgdata/pgbackrest/log",
+		})
+		assert.Assert(t, marshalMatches(pod.Containers[2].VolumeMounts, `
+- mountPath: /pgdata
+  name: postgres-data`))
+		assert.DeepEqual(t, pod.Containers[2].Resources, corev1.ResourceRequirements{})
+
+		t.Run("Resources", func(t *testing.T) {
+			instance := instance.DeepCopy()
+			instance.Sidecars.LogCleanup = &v1beta1.Sidecar{
+				Resources: &corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{"cpu": resource.MustParse("5m")},
+				},
+			}
+
+			pod := new(corev1.PodSpec)
+			InstancePod(ctx, clusterWithRetention, instance,
+				serverSecretProjection, clientSecretProjection, dataVolume, nil, nil, pod)
+
+			assert.Equal(t, pod.Containers[2].Name, "log-cleanup")
+			assert.Assert(t, marshalMatches(pod.Containers[2].Resources, `
+requests:
+  cpu: 5m`))
+		})
+	})
+
 	t.Run("WithCustomSidecarContainer", func(t *testing.T) {
 		sidecarInstance := new(v1beta1.PostgresInstanceSetSpec)
 		sidecarInstance.Containers = []corev1.Container{
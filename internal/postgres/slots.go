@@ -0,0 +1,140 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// ReplicationSlot is a replication slot of a PostgreSQL server.
+// - https://www.postgresql.org/docs/current/view-pg-replication-slots.html
+type ReplicationSlot struct {
+	Name string `json:"name"`
+
+	// Either "physical" or "logical".
+	Type string `json:"type"`
+
+	// Whether or not a consumer is connected to the slot.
+	Active bool `json:"active"`
+
+	// The number of bytes of WAL that the server keeps for the slot.
+	RetainedBytes int64 `json:"retainedBytes"`
+}
+
+// ReplicationSlots calls exec to list the persistent replication slots of the
+// PostgreSQL server. Temporary slots go away with their session, so they are
+// not listed.
+func ReplicationSlots(ctx context.Context, exec Executor) ([]ReplicationSlot, error) {
+	log := logging.FromContext(ctx)
+
+	// A standby has no current WAL location, so measure from the location it
+	// last received or replayed.
+	// - https://www.postgresql.org/docs/current/functions-admin.html#FUNCTIONS-RECOVERY-INFO-TABLE
+	const sql = `
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+
+SELECT pg_catalog.json_build_object(
+       'name', slot_name,
+       'type', slot_type,
+       'active', active,
+       'retainedBytes', COALESCE(pg_catalog.pg_wal_lsn_diff(
+         CASE WHEN pg_catalog.pg_is_in_recovery()
+              THEN pg_catalog.pg_last_wal_receive_lsn()
+              ELSE pg_catalog.pg_current_wal_lsn() END,
+         restart_lsn), 0)::bigint)
+  FROM pg_catalog.pg_replication_slots
+ WHERE NOT temporary
+ ORDER BY slot_name;
+`
+
+	stdout, stderr, err := exec.Exec(ctx, strings.NewReader(sql),
+		map[string]string{
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("listed replication slots", "stdout", stdout, "stderr", stderr)
+
+	var slots []ReplicationSlot
+	for _, line := range strings.Split(stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" && err == nil {
+			var slot ReplicationSlot
+			err = errors.WithStack(json.Unmarshal([]byte(line), &slot))
+			slots = append(slots, slot)
+		}
+	}
+
+	return slots, err
+}
+
+// DropReplicationSlots calls exec to drop the replication slots named in
+// slots that have no consumer. Slots that are in use are left alone.
+// - https://www.postgresql.org/docs/current/functions-admin.html#FUNCTIONS-REPLICATION
+func DropReplicationSlots(ctx context.Context, exec Executor, slots []string) error {
+	log := logging.FromContext(ctx)
+
+	names, err := json.Marshal(slots)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	const sql = `
+SET search_path TO '';
+
+SELECT pg_catalog.pg_drop_replication_slot(slot_name)
+  FROM pg_catalog.pg_replication_slots
+ WHERE NOT active AND NOT temporary
+   AND slot_name IN (SELECT pg_catalog.json_array_elements_text(:'slots'));
+`
+
+	stdout, stderr, err := exec.Exec(ctx, strings.NewReader(sql),
+		map[string]string{
+			"slots": string(names),
+
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("dropped replication slots", "stdout", stdout, "stderr", stderr)
+
+	return err
+}
+
+// SetReplicationSlotLimit sets max_slot_wal_keep_size to the size in
+// spec.replicationSlots.maxWALKeepSize. PostgreSQL 12 and earlier have no such
+// limit.
+// - https://www.postgresql.org/docs/current/runtime-config-replication.html#GUC-MAX-SLOT-WAL-KEEP-SIZE
+func SetReplicationSlotLimit(cluster *v1beta1.PostgresCluster, parameters *Parameters) {
+	spec := cluster.Spec.ReplicationSlots
+	if spec == nil || spec.MaxWALKeepSize == nil || cluster.Spec.PostgresVersion < 13 {
+		return
+	}
+
+	// The parameter is in megabytes. Round up so that slots can keep at least
+	// as much as was asked.
+	megabytes := (spec.MaxWALKeepSize.Value() + (1 << 20) - 1) >> 20
+	parameters.Mandatory.Add("max_slot_wal_keep_size", fmt.Sprintf("%dMB", megabytes))
+}
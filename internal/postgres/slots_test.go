@@ -0,0 +1,130 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestReplicationSlots(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Assert(t, cmp.Contains(string(b), `pg_replication_slots`))
+			assert.Assert(t, cmp.Contains(string(b), `NOT temporary`))
+			assert.Assert(t, cmp.Contains(command, "--set=ON_ERROR_STOP=on"))
+			return expected
+		}
+
+		_, err := ReplicationSlots(ctx, exec)
+		assert.Equal(t, expected, err)
+	})
+
+	t.Run("Output", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte(`
+{"name" : "one", "type" : "physical", "active" : true, "retainedBytes" : 0}
+{"name" : "two", "type" : "logical", "active" : false, "retainedBytes" : 1048576}
+`))
+			return nil
+		}
+
+		slots, err := ReplicationSlots(ctx, exec)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, slots, []ReplicationSlot{
+			{Name: "one", Type: "physical", Active: true},
+			{Name: "two", Type: "logical", RetainedBytes: 1 << 20},
+		})
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte("not json\n"))
+			return nil
+		}
+
+		_, err := ReplicationSlots(ctx, exec)
+		assert.Assert(t, err != nil)
+	})
+}
+
+func TestDropReplicationSlots(t *testing.T) {
+	ctx := context.Background()
+
+	expected := errors.New("pass-through")
+	exec := func(
+		_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		assert.Assert(t, stdout != nil, "should capture stdout")
+		assert.Assert(t, stderr != nil, "should capture stderr")
+		assert.Assert(t, cmp.Contains(command, `--set=slots=["one","two"]`))
+
+		b, err := io.ReadAll(stdin)
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Contains(string(b), `pg_drop_replication_slot(slot_name)`))
+		assert.Assert(t, cmp.Contains(string(b), `NOT active`))
+		return expected
+	}
+
+	assert.Equal(t, expected, DropReplicationSlots(ctx, exec, []string{"one", "two"}))
+}
+
+func TestSetReplicationSlotLimit(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.PostgresVersion = 15
+
+	parameters := NewParameters()
+	SetReplicationSlotLimit(cluster, &parameters)
+	assert.Assert(t, !parameters.Mandatory.Has("max_slot_wal_keep_size"))
+
+	size := resource.MustParse("1500M")
+	cluster.Spec.ReplicationSlots = &v1beta1.PostgresReplicationSlotsSpec{
+		MaxWALKeepSize: &size,
+	}
+
+	parameters = NewParameters()
+	SetReplicationSlotLimit(cluster, &parameters)
+	assert.Equal(t, parameters.Mandatory.Value("max_slot_wal_keep_size"), "1431MB",
+		"expected to round up to the next megabyte")
+
+	t.Run("PostgreSQL12", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.PostgresVersion = 12
+
+		parameters := NewParameters()
+		SetReplicationSlotLimit(cluster, &parameters)
+		assert.Assert(t, !parameters.Mandatory.Has("max_slot_wal_keep_size"))
+	})
+}
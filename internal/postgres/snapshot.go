@@ -0,0 +1,116 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+)
+
+// snapshotDirectory is where the session that holds a backup open for a
+// VolumeSnapshot keeps its state. It is outside the data volume so that none
+// of it is in the snapshot.
+const snapshotDirectory = "/tmp/volume-snapshot"
+
+// StartBackupForSnapshot calls exec to start a backup of PostgreSQL so that a
+// snapshot of its data volume can be taken. A backup ends with the session that
+// started it, so a detached "psql" session starts it and keeps it open until
+// [StopBackupForSnapshot] is called or timeout passes. This returns once the
+// backup has started.
+// - https://www.postgresql.org/docs/current/continuous-archiving.html#BACKUP-LOWLEVEL-BASE-BACKUP
+func StartBackupForSnapshot(
+	ctx context.Context, exec Executor, version int, label string, timeout time.Duration,
+) error {
+	log := logging.FromContext(ctx)
+
+	// PostgreSQL 15 renamed the functions and removed exclusive backups.
+	start, stop := `pg_catalog.pg_backup_start(:'label', true)`, `pg_catalog.pg_backup_stop(false)`
+	if version < 15 {
+		start, stop = `pg_catalog.pg_start_backup(:'label', true, false)`, `pg_catalog.pg_stop_backup(false, false)`
+	}
+
+	// Start the backup with a fast checkpoint, then wait for the snapshot. The
+	// snapshot is of the data volume, so it does not need WAL to be archived.
+	sql := strings.Join([]string{
+		`SELECT ` + start + `;`,
+		fmt.Sprintf(`\! touch %[1]s/started && for _ in $(seq %[2]d); do [ -e %[1]s/taken ] && break; sleep 1; done`,
+			snapshotDirectory, int(timeout.Seconds())),
+		`SELECT ` + stop + `;`,
+		fmt.Sprintf(`\! touch %s/stopped`, snapshotDirectory),
+	}, "\n")
+
+	const script = `
+sql=$(< /dev/stdin)
+directory="$1" label="$2"
+mkdir -p "${directory}"
+rm -f "${directory}/started" "${directory}/taken" "${directory}/stopped"
+
+nohup psql -Xw --set=ON_ERROR_STOP=1 --set=label="${label}" --file=- \
+	> "${directory}/session.log" 2>&1 <<< "${sql}" &
+echo $! > "${directory}/session.pid"
+
+until [[ -e "${directory}/started" ]]; do
+	if ! kill -0 "$(< "${directory}/session.pid")" 2> /dev/null; then
+		cat "${directory}/session.log" >&2
+		exit 1
+	fi
+	sleep 1
+done
+`
+
+	var stdout, stderr bytes.Buffer
+	err := exec(ctx, strings.NewReader(sql), &stdout, &stderr,
+		"bash", "-ceu", "--", script, "-", snapshotDirectory, label)
+
+	log.V(1).Info("started backup for snapshot", "stdout", stdout.String(), "stderr", stderr.String())
+
+	return err
+}
+
+// StopBackupForSnapshot calls exec to end the backup started by
+// [StartBackupForSnapshot] once the snapshot has been taken. It waits up to
+// timeout for PostgreSQL to end the backup. There is nothing to do when there
+// is no backup, e.g. because PostgreSQL restarted.
+func StopBackupForSnapshot(ctx context.Context, exec Executor, timeout time.Duration) error {
+	log := logging.FromContext(ctx)
+
+	const script = `
+directory="$1" seconds="$2"
+[[ -e "${directory}/started" ]] || exit 0
+touch "${directory}/taken"
+
+for _ in $(seq "${seconds}"); do
+	[[ -e "${directory}/stopped" ]] && exit 0
+	sleep 1
+done
+
+cat "${directory}/session.log" >&2
+exit 1
+`
+
+	var stdout, stderr bytes.Buffer
+	err := exec(ctx, nil, &stdout, &stderr,
+		"bash", "-ceu", "--", script, "-", snapshotDirectory, fmt.Sprint(int(timeout.Seconds())))
+
+	log.V(1).Info("stopped backup for snapshot", "stdout", stdout.String(), "stderr", stderr.String())
+
+	return err
+}
@@ -0,0 +1,83 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+)
+
+func TestStartBackupForSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Assert(t, cmp.Contains(string(b), `pg_catalog.pg_backup_start(:'label', true)`))
+			assert.Assert(t, cmp.Contains(string(b), `pg_catalog.pg_backup_stop(false)`))
+			assert.Assert(t, cmp.Contains(string(b), `$(seq 600)`))
+
+			assert.Equal(t, command[0], "bash")
+			assert.Assert(t, cmp.Contains(command[3], `nohup psql`))
+			assert.DeepEqual(t, command[4:], []string{"-", "/tmp/volume-snapshot", "some-label"})
+			return expected
+		}
+
+		err := StartBackupForSnapshot(ctx, exec, 15, "some-label", 10*time.Minute)
+		assert.Equal(t, expected, err)
+	})
+
+	t.Run("Version14", func(t *testing.T) {
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, _ ...string,
+		) error {
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Assert(t, cmp.Contains(string(b), `pg_catalog.pg_start_backup(:'label', true, false)`))
+			assert.Assert(t, cmp.Contains(string(b), `pg_catalog.pg_stop_backup(false, false)`))
+			return nil
+		}
+
+		assert.NilError(t, StartBackupForSnapshot(ctx, exec, 14, "some-label", time.Minute))
+	})
+}
+
+func TestStopBackupForSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	expected := errors.New("pass-through")
+	exec := func(
+		_ context.Context, _ io.Reader, _, _ io.Writer, command ...string,
+	) error {
+		assert.Equal(t, command[0], "bash")
+		assert.Assert(t, cmp.Contains(command[3], `touch "${directory}/taken"`))
+		assert.DeepEqual(t, command[4:], []string{"-", "/tmp/volume-snapshot", "30"})
+		return expected
+	}
+
+	assert.Equal(t, expected, StopBackupForSnapshot(ctx, exec, 30*time.Second))
+}
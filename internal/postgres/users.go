@@ -25,15 +25,36 @@ import (
 )
 
 // WriteUsersInPostgreSQL calls exec to create users that do not exist in
-// PostgreSQL. Once they exist, it updates their options and passwords and
-// grants them access to their specified databases. The databases must already
-// exist.
+// PostgreSQL. Once they exist, it updates their options, passwords, and group
+// memberships and grants them access to their specified databases. The
+// databases must already exist.
 func WriteUsersInPostgreSQL(
 	ctx context.Context, exec Executor,
 	users []v1beta1.PostgresUserSpec, verifiers map[string]string,
 ) error {
 	log := logging.FromContext(ctx)
 
+	sql, err := writeUsersSQL(users, verifiers)
+	if err != nil {
+		return err
+	}
+
+	stdout, stderr, err := exec.Exec(ctx, sql,
+		map[string]string{
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("wrote PostgreSQL users", "stdout", stdout, "stderr", stderr)
+
+	return err
+}
+
+// writeUsersSQL returns the psql script that WriteUsersInPostgreSQL executes
+// for users and their password verifiers.
+func writeUsersSQL(
+	users []v1beta1.PostgresUserSpec, verifiers map[string]string,
+) (*bytes.Buffer, error) {
 	var err error
 	var sql bytes.Buffer
 
@@ -56,18 +77,21 @@ CREATE TEMPORARY TABLE input (id serial, data json);
 		spec := users[i]
 
 		databases := spec.Databases
+		memberOf := spec.MemberOf
 		options := spec.Options
 
 		// The "postgres" user must always be a superuser that can login to
 		// the "postgres" database.
 		if spec.Name == "postgres" {
 			databases = append(databases[:0:0], "postgres")
+			memberOf = nil
 			options = `LOGIN SUPERUSER`
 		}
 
 		if err == nil {
 			err = encoder.Encode(map[string]interface{}{
 				"databases": databases,
+				"memberOf":  memberOf,
 				"options":   options,
 				"username":  spec.Name,
 				"verifier":  verifiers[string(spec.Name)],
@@ -105,6 +129,59 @@ SELECT pg_catalog.format('ALTER ROLE %I WITH %s PASSWORD %L',
        pg_catalog.json_extract_path_text(input.data, 'verifier'))
   FROM input ORDER BY input.id
 \gexec
+`)
+
+	// Create group roles that do not already exist. They cannot login.
+	// - https://www.postgresql.org/docs/current/sql-createrole.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.format('CREATE ROLE %I NOLOGIN', groups.name)
+  FROM (SELECT DISTINCT pg_catalog.json_array_elements_text(
+               pg_catalog.json_extract_path(
+               pg_catalog.json_strip_nulls(input.data), 'memberOf')) AS name
+          FROM input) AS groups
+ WHERE NOT EXISTS (
+       SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = groups.name)
+ ORDER BY groups.name
+\gexec
+`)
+
+	// Revoke membership in roles that are not specified, but only for users
+	// that specify their memberships. A null "memberOf" leaves them alone.
+	// - https://www.postgresql.org/docs/current/sql-revoke.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.format('REVOKE %I FROM %I', groups.rolname, users.rolname)
+  FROM input
+  JOIN pg_catalog.pg_roles AS users
+    ON users.rolname = pg_catalog.json_extract_path_text(input.data, 'username')
+  JOIN pg_catalog.pg_roles AS groups
+    ON EXISTS (
+       SELECT 1 FROM pg_catalog.pg_auth_members
+        WHERE member = users.oid AND roleid = groups.oid)
+ WHERE pg_catalog.json_typeof(
+       pg_catalog.json_extract_path(input.data, 'memberOf')) = 'array'
+   AND groups.rolname NOT IN (
+       SELECT pg_catalog.json_array_elements_text(
+              pg_catalog.json_extract_path(input.data, 'memberOf')))
+ ORDER BY input.id, groups.rolname
+\gexec
+`)
+
+	// Grant membership in any specified roles that are not already granted.
+	// - https://www.postgresql.org/docs/current/sql-grant.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.format('GRANT %I TO %I', groups.name,
+       pg_catalog.json_extract_path_text(input.data, 'username'))
+  FROM input, pg_catalog.json_array_elements_text(
+       pg_catalog.json_extract_path(
+       pg_catalog.json_strip_nulls(input.data), 'memberOf')) AS groups (name)
+ WHERE NOT EXISTS (
+       SELECT 1 FROM pg_catalog.pg_auth_members
+         JOIN pg_catalog.pg_roles AS g ON g.oid = roleid
+         JOIN pg_catalog.pg_roles AS u ON u.oid = member
+        WHERE g.rolname = groups.name
+          AND u.rolname = pg_catalog.json_extract_path_text(input.data, 'username'))
+ ORDER BY input.id
+\gexec
 `)
 
 	// Grant access to any specified databases.
@@ -122,13 +199,5 @@ SELECT pg_catalog.format('GRANT ALL PRIVILEGES ON DATABASE %I TO %I',
 	// Commit (finish) the transaction.
 	_, _ = sql.WriteString(`COMMIT;`)
 
-	stdout, stderr, err := exec.Exec(ctx, &sql,
-		map[string]string{
-			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
-			"QUIET":         "on", // Do not print successful statements to stdout.
-		})
-
-	log.V(1).Info("wrote PostgreSQL users", "stdout", stdout, "stderr", stderr)
-
-	return err
+	return &sql, err
 }
@@ -23,8 +23,10 @@ import (
 	"testing"
 
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
 
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/internal/testing/replay"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
@@ -75,6 +77,46 @@ SELECT pg_catalog.format('ALTER ROLE %I WITH %s PASSWORD %L',
   FROM input ORDER BY input.id
 \gexec
 
+SELECT pg_catalog.format('CREATE ROLE %I NOLOGIN', groups.name)
+  FROM (SELECT DISTINCT pg_catalog.json_array_elements_text(
+               pg_catalog.json_extract_path(
+               pg_catalog.json_strip_nulls(input.data), 'memberOf')) AS name
+          FROM input) AS groups
+ WHERE NOT EXISTS (
+       SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = groups.name)
+ ORDER BY groups.name
+\gexec
+
+SELECT pg_catalog.format('REVOKE %I FROM %I', groups.rolname, users.rolname)
+  FROM input
+  JOIN pg_catalog.pg_roles AS users
+    ON users.rolname = pg_catalog.json_extract_path_text(input.data, 'username')
+  JOIN pg_catalog.pg_roles AS groups
+    ON EXISTS (
+       SELECT 1 FROM pg_catalog.pg_auth_members
+        WHERE member = users.oid AND roleid = groups.oid)
+ WHERE pg_catalog.json_typeof(
+       pg_catalog.json_extract_path(input.data, 'memberOf')) = 'array'
+   AND groups.rolname NOT IN (
+       SELECT pg_catalog.json_array_elements_text(
+              pg_catalog.json_extract_path(input.data, 'memberOf')))
+ ORDER BY input.id, groups.rolname
+\gexec
+
+SELECT pg_catalog.format('GRANT %I TO %I', groups.name,
+       pg_catalog.json_extract_path_text(input.data, 'username'))
+  FROM input, pg_catalog.json_array_elements_text(
+       pg_catalog.json_extract_path(
+       pg_catalog.json_strip_nulls(input.data), 'memberOf')) AS groups (name)
+ WHERE NOT EXISTS (
+       SELECT 1 FROM pg_catalog.pg_auth_members
+         JOIN pg_catalog.pg_roles AS g ON g.oid = roleid
+         JOIN pg_catalog.pg_roles AS u ON u.oid = member
+        WHERE g.rolname = groups.name
+          AND u.rolname = pg_catalog.json_extract_path_text(input.data, 'username'))
+ ORDER BY input.id
+\gexec
+
 SELECT pg_catalog.format('GRANT ALL PRIVILEGES ON DATABASE %I TO %I',
        pg_catalog.json_array_elements_text(
        pg_catalog.json_extract_path(
@@ -107,9 +149,11 @@ COMMIT;`))
 			assert.NilError(t, err)
 			assert.Assert(t, cmp.Contains(string(b), `
 \copy input (data) from stdin with (format text)
-{"databases":["db1"],"options":"","username":"user-no-options","verifier":""}
-{"databases":null,"options":"some options here","username":"user-no-databases","verifier":""}
-{"databases":null,"options":"","username":"user-with-verifier","verifier":"some$verifier"}
+{"databases":["db1"],"memberOf":null,"options":"","username":"user-no-options","verifier":""}
+{"databases":null,"memberOf":null,"options":"some options here","username":"user-no-databases","verifier":""}
+{"databases":null,"memberOf":null,"options":"","username":"user-with-verifier","verifier":"some$verifier"}
+{"databases":null,"memberOf":["group1"],"options":"","username":"user-with-groups","verifier":""}
+{"databases":null,"memberOf":[],"options":"","username":"user-without-groups","verifier":""}
 \.
 `))
 			return nil
@@ -128,6 +172,14 @@ COMMIT;`))
 				{
 					Name: "user-with-verifier",
 				},
+				{
+					Name:     "user-with-groups",
+					MemberOf: []v1beta1.PostgresIdentifier{"group1"},
+				},
+				{
+					Name:     "user-without-groups",
+					MemberOf: []v1beta1.PostgresIdentifier{},
+				},
 			},
 			map[string]string{
 				"no-user":            "ignored",
@@ -148,7 +200,7 @@ COMMIT;`))
 			assert.NilError(t, err)
 			assert.Assert(t, cmp.Contains(string(b), `
 \copy input (data) from stdin with (format text)
-{"databases":["postgres"],"options":"LOGIN SUPERUSER","username":"postgres","verifier":"allowed"}
+{"databases":["postgres"],"memberOf":null,"options":"LOGIN SUPERUSER","username":"postgres","verifier":"allowed"}
 \.
 `))
 			return nil
@@ -159,6 +211,7 @@ COMMIT;`))
 				{
 					Name:      "postgres",
 					Databases: []v1beta1.PostgresIdentifier{"all", "ignored"},
+					MemberOf:  []v1beta1.PostgresIdentifier{"ignored"},
 					Options:   "NOLOGIN CONNECTION LIMIT 0",
 				},
 			},
@@ -168,4 +221,29 @@ COMMIT;`))
 		))
 		assert.Equal(t, calls, 1)
 	})
+
+	t.Run("Golden", func(t *testing.T) {
+		exec := new(replay.Executor)
+
+		assert.NilError(t, WriteUsersInPostgreSQL(ctx, exec.Exec,
+			[]v1beta1.PostgresUserSpec{
+				{Name: "postgres"},
+				{
+					Name:      "hippo",
+					Databases: []v1beta1.PostgresIdentifier{"zoo", "aquarium"},
+					MemberOf:  []v1beta1.PostgresIdentifier{"app_readers", "app_writers"},
+					Options:   "CREATEDB CONNECTION LIMIT 5",
+				},
+				{Name: "rhino", MemberOf: []v1beta1.PostgresIdentifier{}},
+			},
+			map[string]string{
+				"hippo":    "SCRAM-SHA-256$4096:salt$stored:server",
+				"postgres": "SCRAM-SHA-256$4096:other$stored:server",
+			},
+		))
+
+		// Changes to the SQL show up as changes to this file. Update it by
+		// running "go test" with the -test.update-golden flag.
+		golden.Assert(t, exec.String(), "WriteUsersInPostgreSQL.golden")
+	})
 }
@@ -0,0 +1,263 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package scim reads groups and users from a System for Cross-domain Identity
+// Management (SCIM) 2.0 service provider.
+// - https://datatracker.ietf.org/doc/html/rfc7643
+// - https://datatracker.ietf.org/doc/html/rfc7644
+package scim
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrGroupNotFound is returned when the service provider has no group with a
+// requested displayName.
+var ErrGroupNotFound = errors.New("group not found")
+
+// maxResponseBytes is the largest response body that Client reads.
+const maxResponseBytes = 10 << 20
+
+// usersPerRequest is the number of users that Client looks up in one request.
+const usersPerRequest = 50
+
+// checkAddress returns an error when address is a loopback, link-local, or
+// unspecified IP. These reach the operator itself or the metadata service of
+// its node rather than a service provider.
+func checkAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+
+	ip := net.ParseIP(host)
+	if ip != nil && (ip.IsLoopback() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()) {
+		//nolint:goerr113 // This is intentionally dynamic.
+		return fmt.Errorf("refusing to connect to %q", host)
+	}
+	return nil
+}
+
+type Client struct {
+	http.Client
+
+	BaseURL url.URL
+	Token   string
+}
+
+// NewClient creates a Client that sends token to the service provider at
+// baseURL. When caPEM is not empty, it replaces the system certificate
+// authorities that verify the service provider.
+func NewClient(baseURL, token string, caPEM []byte) (*Client, error) {
+	base, err := url.Parse(baseURL)
+	if err == nil && ((base.Scheme != "http" && base.Scheme != "https") || base.Hostname() == "") {
+		//nolint:goerr113 // This is intentionally dynamic.
+		err = fmt.Errorf("expected an HTTP or HTTPS URL, got %q", baseURL)
+	}
+	if err == nil {
+		err = checkAddress(base.Hostname())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{BaseURL: *base, Token: token}
+	client.Client.Timeout = 30 * time.Second
+
+	// Redirects could send the token elsewhere.
+	client.Client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return errors.New("refusing to follow a redirect")
+	}
+
+	// Check addresses after names resolve, too.
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			return checkAddress(address)
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	client.Client.Transport = transport
+
+	if len(caPEM) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("expected PEM-encoded certificates")
+		}
+
+		transport.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    pool,
+		}
+	}
+
+	return client, nil
+}
+
+// get performs a GET request for path and decodes a successful response into
+// result. Errors do not include the response body; it may echo the token or
+// other details that should not reach events and status.
+func (c *Client) get(ctx context.Context, path string, query url.Values, result any) error {
+	location := c.BaseURL.JoinPath(path)
+	location.RawQuery = query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, location.String(), nil)
+	if err == nil {
+		request.Header.Set("Accept", "application/scim+json")
+		request.Header.Set("Authorization", "Bearer "+c.Token)
+
+		var response *http.Response
+		response, err = c.Client.Do(request)
+
+		if err == nil {
+			defer response.Body.Close()
+			body, _ := io.ReadAll(io.LimitReader(response.Body, maxResponseBytes+1))
+
+			switch {
+			// 2xx, Successful
+			case response.StatusCode >= 200 && response.StatusCode < 300:
+				if len(body) > maxResponseBytes {
+					//nolint:goerr113 // This is intentionally dynamic.
+					err = fmt.Errorf("response from %q is larger than %d bytes",
+						location.Path, maxResponseBytes)
+				} else if err = json.Unmarshal(body, result); err != nil {
+					err = fmt.Errorf("response from %q: %w", location.Path, err)
+				}
+
+			default:
+				//nolint:goerr113 // This is intentionally dynamic.
+				err = fmt.Errorf("response from %q: %v", location.Path, response.Status)
+			}
+		}
+	}
+
+	return err
+}
+
+// GroupMembers returns the sorted userNames of the users that are direct
+// members of the group named displayName. Members that are themselves groups
+// are not expanded.
+func (c *Client) GroupMembers(ctx context.Context, displayName string) ([]string, error) {
+	// Filter values are JSON strings.
+	// - https://datatracker.ietf.org/doc/html/rfc7644#section-3.4.2.2
+	quoted, _ := json.Marshal(displayName)
+
+	var groups struct {
+		Resources []struct {
+			DisplayName string `json:"displayName"`
+			Members     []struct {
+				Type  string `json:"type"`
+				Value string `json:"value"`
+			} `json:"members"`
+		} `json:"Resources"`
+	}
+	err := c.get(ctx, "/Groups", url.Values{
+		"attributes": []string{"displayName,members"},
+		"filter":     []string{"displayName eq " + string(quoted)},
+	}, &groups)
+
+	if err == nil && len(groups.Resources) != 1 {
+		err = fmt.Errorf("%w: %q matched %d groups",
+			ErrGroupNotFound, displayName, len(groups.Resources))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, member := range groups.Resources[0].Members {
+		if member.Type != "Group" && member.Value != "" {
+			ids = append(ids, member.Value)
+		}
+	}
+
+	names, err := c.userNames(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("members of group %q: %w", displayName, err)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// userNames returns the userNames of the users with ids. It looks them up a
+// few at a time, rather than one request per user.
+// - https://datatracker.ietf.org/doc/html/rfc7644#section-3.4.2
+func (c *Client) userNames(ctx context.Context, ids []string) ([]string, error) {
+	var names []string
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > usersPerRequest {
+			batch = batch[:usersPerRequest]
+		}
+		ids = ids[len(batch):]
+
+		filters := make([]string, len(batch))
+		for i, id := range batch {
+			quoted, _ := json.Marshal(id)
+			filters[i] = "id eq " + string(quoted)
+		}
+
+		// The service provider may return fewer users than requested per page.
+		// - https://datatracker.ietf.org/doc/html/rfc7644#section-3.4.2.4
+		for start, found := 1, 0; found < len(batch); {
+			var users struct {
+				TotalResults int `json:"totalResults"`
+				Resources    []struct {
+					UserName string `json:"userName"`
+				} `json:"Resources"`
+			}
+			if err := c.get(ctx, "/Users", url.Values{
+				"attributes": []string{"userName"},
+				"count":      []string{strconv.Itoa(len(batch))},
+				"filter":     []string{strings.Join(filters, " or ")},
+				"startIndex": []string{strconv.Itoa(start)},
+			}, &users); err != nil {
+				return nil, err
+			}
+
+			for _, user := range users.Resources {
+				if user.UserName != "" {
+					names = append(names, user.UserName)
+				}
+			}
+
+			found += len(users.Resources)
+			start += len(users.Resources)
+			if len(users.Resources) == 0 || found >= users.TotalResults {
+				break
+			}
+		}
+	}
+
+	return names, nil
+}
@@ -0,0 +1,275 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// newTestClient returns a Client of server. Test servers listen on loopback,
+// so it uses their transport rather than one that refuses to dial it.
+func newTestClient(t *testing.T, server *httptest.Server, token string) *Client {
+	client, err := NewClient("https://idp.example.com", token, nil)
+	assert.NilError(t, err)
+
+	base, err := url.Parse(server.URL + "/scim")
+	assert.NilError(t, err)
+
+	client.BaseURL = *base
+	client.Client.Transport = server.Client().Transport
+	return client
+}
+
+// filterIDs returns the ids in the filter of a request for users.
+func filterIDs(r *http.Request) []string {
+	var ids []string
+	for _, match := range regexp.MustCompile(`id eq "([^"]*)"`).FindAllStringSubmatch(
+		r.URL.Query().Get("filter"), -1) {
+		ids = append(ids, match[1])
+	}
+	return ids
+}
+
+func TestCheckAddress(t *testing.T) {
+	for _, bad := range []string{
+		"127.0.0.1:443", "[::1]:80", "0.0.0.0:80",
+		"169.254.169.254:80", "[fe80::1]:443", "127.0.0.53",
+	} {
+		assert.ErrorContains(t, checkAddress(bad), "refusing", "for %q", bad)
+	}
+
+	for _, good := range []string{
+		"10.0.0.1:443", "203.0.113.9:443", "[2001:db8::1]:443", "idp.example.com",
+	} {
+		assert.NilError(t, checkAddress(good), "for %q", good)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	for _, bad := range []string{"", "/path", "http://:9999", "ldap://localhost"} {
+		_, err := NewClient(bad, "", nil)
+		assert.ErrorContains(t, err, "URL", "for %q", bad)
+	}
+
+	for _, bad := range []string{"http://127.0.0.1:8080", "https://[::1]", "http://169.254.169.254"} {
+		_, err := NewClient(bad, "", nil)
+		assert.ErrorContains(t, err, "refusing", "for %q", bad)
+	}
+
+	client, err := NewClient("https://idp.example.com/scim/v2", "tok", nil)
+	assert.NilError(t, err)
+	assert.Equal(t, client.BaseURL.String(), "https://idp.example.com/scim/v2")
+	assert.Assert(t, client.Client.Timeout > 0)
+	assert.Assert(t, client.Client.CheckRedirect != nil)
+	assert.Assert(t, client.Client.Transport != nil)
+
+	_, err = NewClient("https://idp.example.com", "", []byte("not pem"))
+	assert.ErrorContains(t, err, "PEM")
+}
+
+func TestClientGroupMembers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Members", func(t *testing.T) {
+		var requests []*http.Request
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+
+			switch {
+			case r.URL.Path == "/scim/Groups":
+				_, _ = w.Write([]byte(`{"Resources":[{"displayName":"dba team","members":[
+					{"value":"u2"},
+					{"value":"g1","type":"Group"},
+					{"value":"u1","type":"User"}
+				]}]}`))
+			case r.URL.Path == "/scim/Users":
+				name := map[string]string{"u1": "Zed@example.com", "u2": "alice"}
+				var users []map[string]string
+				for _, id := range filterIDs(r) {
+					users = append(users, map[string]string{"userName": name[id]})
+				}
+				body, _ := json.Marshal(map[string]any{
+					"totalResults": len(users), "Resources": users,
+				})
+				_, _ = w.Write(body)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		client := newTestClient(t, server, "secret-token")
+
+		names, err := client.GroupMembers(ctx, `dba team`)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, names, []string{"Zed@example.com", "alice"})
+
+		assert.Equal(t, len(requests), 2, "expected one request for all users")
+		assert.Equal(t, requests[0].URL.Query().Get("filter"), `displayName eq "dba team"`)
+		assert.Equal(t, requests[1].URL.Query().Get("filter"), `id eq "u2" or id eq "u1"`,
+			"expected no user for the nested group")
+		for _, r := range requests {
+			assert.Equal(t, r.Method, http.MethodGet)
+			assert.Equal(t, r.Header.Get("Authorization"), "Bearer secret-token")
+			assert.Equal(t, r.Header.Get("Accept"), "application/scim+json")
+		}
+	})
+
+	t.Run("ManyMembers", func(t *testing.T) {
+		var requests int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+
+			switch r.URL.Path {
+			case "/scim/Groups":
+				var members []map[string]string
+				for i := 0; i < 120; i++ {
+					members = append(members, map[string]string{"value": fmt.Sprintf("u%03d", i)})
+				}
+				body, _ := json.Marshal(map[string]any{
+					"Resources": []any{map[string]any{"members": members}},
+				})
+				_, _ = w.Write(body)
+
+			case "/scim/Users":
+				// Return at most 20 users per page.
+				ids := filterIDs(r)
+				start, _ := strconv.Atoi(r.URL.Query().Get("startIndex"))
+				var users []map[string]string
+				for i := start - 1; i < len(ids) && i < start+19; i++ {
+					users = append(users, map[string]string{"userName": "user-" + ids[i]})
+				}
+				body, _ := json.Marshal(map[string]any{
+					"totalResults": len(ids), "Resources": users,
+				})
+				_, _ = w.Write(body)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		client := newTestClient(t, server, "")
+
+		names, err := client.GroupMembers(ctx, "everyone")
+		assert.NilError(t, err)
+		assert.Equal(t, len(names), 120)
+		assert.Equal(t, names[0], "user-u000")
+		assert.Equal(t, names[119], "user-u119")
+
+		// One for the group, three pages for each batch of 50, and one for the rest.
+		assert.Equal(t, requests, 1+3+3+1)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"totalResults":0,"Resources":[]}`))
+		}))
+		t.Cleanup(server.Close)
+
+		client := newTestClient(t, server, "")
+
+		_, err := client.GroupMembers(ctx, "nobody")
+		assert.Assert(t, errors.Is(err, ErrGroupNotFound))
+	})
+
+	t.Run("ServerError", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`invalid token`))
+		}))
+		t.Cleanup(server.Close)
+
+		client := newTestClient(t, server, "")
+
+		_, err := client.GroupMembers(ctx, "any")
+		assert.ErrorContains(t, err, "401")
+		assert.Assert(t, !strings.Contains(err.Error(), "invalid token"),
+			"expected no response body, got %v", err)
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`not json, secret details`))
+		}))
+		t.Cleanup(server.Close)
+
+		client := newTestClient(t, server, "")
+
+		_, err := client.GroupMembers(ctx, "any")
+		assert.ErrorContains(t, err, "/scim/Groups")
+		assert.Assert(t, !strings.Contains(err.Error(), "secret details"),
+			"expected no response body, got %v", err)
+	})
+
+	t.Run("LargeResponse", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"Resources":[{"displayName":"`))
+			_, _ = w.Write([]byte(strings.Repeat("x", maxResponseBytes)))
+			_, _ = w.Write([]byte(`"}]}`))
+		}))
+		t.Cleanup(server.Close)
+
+		client := newTestClient(t, server, "")
+
+		_, err := client.GroupMembers(ctx, "any")
+		assert.ErrorContains(t, err, "larger than")
+	})
+
+	t.Run("Redirect", func(t *testing.T) {
+		var redirected bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/elsewhere" {
+				redirected = true
+				return
+			}
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		}))
+		t.Cleanup(server.Close)
+
+		client := newTestClient(t, server, "secret-token")
+
+		_, err := client.GroupMembers(ctx, "any")
+		assert.ErrorContains(t, err, "redirect")
+		assert.Assert(t, !redirected, "expected no request after the redirect")
+	})
+
+	t.Run("Loopback", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("expected no request")
+		}))
+		t.Cleanup(server.Close)
+
+		// A name that resolves to loopback is refused when dialing.
+		_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+		client, err := NewClient("http://localhost:"+port, "", nil)
+		assert.NilError(t, err)
+
+		_, err = client.GroupMembers(ctx, "any")
+		assert.ErrorContains(t, err, "refusing")
+	})
+}
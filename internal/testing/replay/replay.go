@@ -0,0 +1,117 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package replay records the commands that code under test would run in a Pod
+// and replays results for them. A transcript of the commands can be compared
+// to a golden file so that changes to them are reviewed as diffs:
+//
+//	exec := new(replay.Executor)
+//	assert.NilError(t, postgres.WriteUsersInPostgreSQL(ctx, exec.Exec, users, nil))
+//	golden.Assert(t, exec.String(), t.Name()+".golden")
+//
+// Run "go test" with the -test.update-golden flag to write the golden files.
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Call is one command that was sent to an Executor.
+type Call struct {
+	Command []string
+	Stdin   string
+}
+
+// Result is what an Executor writes and returns for one Call.
+type Result struct {
+	Stdout, Stderr string
+	Err            error
+}
+
+// Executor records every Call and replays Results in order. Calls after the
+// last Result succeed without output.
+type Executor struct {
+	Calls   []Call
+	Results []Result
+
+	mu sync.Mutex
+}
+
+// Exec has the signature of postgres.Executor, pgbackrest.Executor, and
+// patroni.Executor.
+func (e *Executor) Exec(
+	_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	call := Call{Command: append([]string(nil), command...)}
+	if stdin != nil {
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		call.Stdin = string(b)
+	}
+
+	var result Result
+	if n := len(e.Calls); n < len(e.Results) {
+		result = e.Results[n]
+	}
+	e.Calls = append(e.Calls, call)
+
+	if stdout != nil {
+		_, _ = io.WriteString(stdout, result.Stdout)
+	}
+	if stderr != nil {
+		_, _ = io.WriteString(stderr, result.Stderr)
+	}
+	return result.Err
+}
+
+// String returns a transcript of the calls. Each begins with a line of its
+// command, quoting arguments that are not plain words, followed by its stdin.
+func (e *Executor) String() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var b strings.Builder
+	for i, call := range e.Calls {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		args := make([]string, len(call.Command))
+		for j, arg := range call.Command {
+			args[j] = arg
+			if arg == "" || strings.ContainsAny(arg, " \t\n\"'$\\") {
+				args[j] = strconv.Quote(arg)
+			}
+		}
+		fmt.Fprintf(&b, "$ %s\n", strings.Join(args, " "))
+
+		if call.Stdin != "" {
+			b.WriteString(call.Stdin)
+			if !strings.HasSuffix(call.Stdin, "\n") {
+				b.WriteString("\n")
+			}
+		}
+	}
+	return b.String()
+}
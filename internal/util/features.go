@@ -34,6 +34,9 @@ const (
 	//
 	BridgeIdentifiers featuregate.Feature = "BridgeIdentifiers"
 	//
+	// Enables validation that cryptographic material meets FIPS 140 requirements
+	FIPSValidation featuregate.Feature = "FIPSValidation"
+	//
 	// Enables support of custom sidecars for PostgreSQL instance Pods
 	InstanceSidecars featuregate.Feature = "InstanceSidecars"
 	//
@@ -53,6 +56,7 @@ const (
 // - https://releases.k8s.io/v1.20.0/pkg/features/kube_features.go#L729-732
 var pgoFeatures = map[featuregate.Feature]featuregate.FeatureSpec{
 	BridgeIdentifiers: {Default: false, PreRelease: featuregate.Alpha},
+	FIPSValidation:    {Default: false, PreRelease: featuregate.Alpha},
 	InstanceSidecars:  {Default: false, PreRelease: featuregate.Alpha},
 	PGBouncerSidecars: {Default: false, PreRelease: featuregate.Alpha},
 	TablespaceVolumes: {Default: false, PreRelease: featuregate.Alpha},
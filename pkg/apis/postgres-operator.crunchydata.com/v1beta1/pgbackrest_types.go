@@ -17,6 +17,7 @@ package v1beta1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -55,6 +56,75 @@ type PGBackRestJobStatus struct {
 	// The number of Pods for the manual backup Job that reached the "Failed" phase.
 	// +optional
 	Failed int32 `json:"failed,omitempty"`
+
+	// The type of backup requested of the manual backup Job, when one was requested.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// The progress of a restore, as observed by PGO while the restore Job is running.
+	// It is reported only when the restore options set "--log-level-file=detail".
+	// +optional
+	Progress *PGBackRestRestoreProgress `json:"progress,omitempty"`
+}
+
+// PGBackRestLastBackupStatus describes the most recent pgBackRest backup Job to finish.
+type PGBackRestLastBackupStatus struct {
+
+	// The name of the backup Job.
+	// +kubebuilder:validation:Required
+	JobName string `json:"jobName"`
+
+	// The name of the pgBackRest repository that the backup was written to.
+	// +optional
+	RepoName string `json:"repoName,omitempty"`
+
+	// Why the backup was taken: "manual", "replica-create", or the type of a
+	// scheduled backup ("full", "diff", or "incr").
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Whether or not the backup Job completed successfully.
+	// +kubebuilder:validation:Required
+	Succeeded bool `json:"succeeded"`
+
+	// Represents the time the backup Job was acknowledged by the Job controller.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Represents the time the backup Job completed or failed.
+	// +optional
+	FinishTime *metav1.Time `json:"finishTime,omitempty"`
+}
+
+// PGBackRestRestoreProgress describes how far along a pgBackRest restore Job is.
+type PGBackRestRestoreProgress struct {
+
+	// The step of the restore that is running: "Restoring" while pgBackRest restores
+	// files and "Recovering" while PostgreSQL replays WAL.
+	// +kubebuilder:validation:Enum={Restoring,Recovering}
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// The percentage of files, by size, that pgBackRest has restored.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	PercentComplete int32 `json:"percentComplete,omitempty"`
+
+	// The number of bytes that pgBackRest has copied. Files that already match the
+	// backup are not counted. This is an estimate based on the rounded file sizes
+	// that pgBackRest logs.
+	// +optional
+	BytesRestored int64 `json:"bytesRestored,omitempty"`
+
+	// When pgBackRest is expected to finish restoring files, based on its rate so far.
+	// Replaying WAL takes additional time.
+	// +optional
+	EstimatedCompletionTime *metav1.Time `json:"estimatedCompletionTime,omitempty"`
+
+	// The last time PGO observed the progress of the restore.
+	// +optional
+	LastObservedTime *metav1.Time `json:"lastObservedTime,omitempty"`
 }
 
 type PGBackRestScheduledBackupStatus struct {
@@ -107,20 +177,46 @@ type PGBackRestArchive struct {
 	// +optional
 	Configuration []corev1.VolumeProjection `json:"configuration,omitempty"`
 
+	// A ConfigMap in the namespace of the PostgresCluster with pgBackRest configuration
+	// files to include after the ones PGO generates, e.g. for options that are not in
+	// this spec. Every key must end in ".conf" and may not set an option that PGO or
+	// another key sets. PGO reports a ConfigMap that does not follow these rules in the
+	// PGBackRestConfigIncludeValid condition and leaves its files out.
+	// https://pgbackrest.org/configuration.html
+	// +optional
+	ConfigInclude *corev1.LocalObjectReference `json:"configInclude,omitempty"`
+
 	// Global pgBackRest configuration settings.  These settings are included in the "global"
 	// section of the pgBackRest configuration generated by the PostgreSQL Operator, and then
 	// mounted under "/etc/pgbackrest/conf.d":
 	// https://pgbackrest.org/configuration.html
+	// Options that PGO manages, such as log-path and tls-server-*, cannot be
+	// set. When they are, PGO leaves the pgBackRest configuration unchanged and
+	// reports them in an InvalidPGBackRestGlobal event and the
+	// PGBackRestGlobalValid condition.
 	// +optional
 	Global map[string]string `json:"global,omitempty"`
 
+	// Defines how pgBackRest reads from and writes to the network and its
+	// repositories. These options apply to every repository, because pgBackRest
+	// does not allow them to differ between repositories.
+	// +optional
+	IO *PGBackRestIO `json:"io,omitempty"`
+
 	// The image name to use for pgBackRest containers.  Utilized to run
 	// pgBackRest repository hosts and backups. The image may also be set using
 	// the RELATED_IMAGE_PGBACKREST environment variable
 	// +optional
 	Image string `json:"image,omitempty"`
 
-	// Jobs field allows configuration for all backup jobs
+	// How many finished backup Jobs to keep for the cluster. Older Jobs are deleted.
+	// The Job of the backup for replica creation and the Job of the current manual
+	// backup are always kept.
+	// +optional
+	JobHistoryLimit *PGBackRestJobHistoryLimit `json:"jobHistoryLimit,omitempty"`
+
+	// Jobs field allows configuration for all backup jobs. Its resources and scheduling
+	// constraints also apply to restore Jobs when the data source does not set its own.
 	// +optional
 	Jobs *BackupJobs `json:"jobs,omitempty"`
 
@@ -140,6 +236,42 @@ type PGBackRestArchive struct {
 	// +optional
 	Manual *PGBackRestManualBackup `json:"manual,omitempty"`
 
+	// Defines details for expire dry runs requested with the
+	// "postgres-operator.crunchydata.com/pgbackrest-expire-dry-run" annotation
+	// +optional
+	ExpireDryRun *PGBackRestExpireDryRun `json:"expireDryRun,omitempty"`
+
+	// Defines how PostgreSQL instances push WAL files to the repositories and
+	// when PGO alerts that archiving has been failing for too long.
+	// +optional
+	ArchivePush *PGBackRestArchivePush `json:"archivePush,omitempty"`
+
+	// Send WAL files to the repositories asynchronously and in parallel. This
+	// helps PostgreSQL instances that generate WAL faster than it can be pushed
+	// one file at a time. Changing this value does not restart PostgreSQL, but
+	// adding or removing the spool volume does.
+	// More info: https://pgbackrest.org/user-guide.html#async-archiving
+	// +optional
+	AsyncArchive *PGBackRestAsyncArchive `json:"asyncArchive,omitempty"`
+
+	// Whether to stop sending WAL files to the repositories, e.g. during a bulk load.
+	// WAL files are discarded while archiving is paused, so backups cannot restore to
+	// any point after it was paused, and new backups fail. A full backup is taken when
+	// archiving resumes. Changing this value does not restart PostgreSQL.
+	// +optional
+	PauseArchiving *bool `json:"pauseArchiving,omitempty"`
+
+	// How replicas are created once the cluster is running. Defaults to Backup.
+	// "Backup" takes a new full backup into the first repository and creates replicas from it.
+	// "LatestBackup" creates replicas from the most recent backup (plus WAL) already in the
+	// first repository, taking a new backup only when there is none. This avoids a full
+	// backup of very large clusters before replicas can be rebuilt.
+	// "Basebackup" never takes a backup for replicas. They stream a copy of the primary
+	// using pg_basebackup instead.
+	// +kubebuilder:validation:Enum={Backup,Basebackup,LatestBackup}
+	// +optional
+	ReplicaCreate string `json:"replicaCreate,omitempty"`
+
 	// Defines details for performing an in-place restore using pgBackRest
 	// +optional
 	Restore *PGBackRestRestore `json:"restore,omitempty"`
@@ -147,8 +279,104 @@ type PGBackRestArchive struct {
 	// Configuration for pgBackRest sidecar containers
 	// +optional
 	Sidecars *PGBackRestSidecars `json:"sidecars,omitempty"`
+
+	// Whether to stop creating the scheduled full, differential and incremental
+	// backups of every repository. The schedules are kept, and backups that have
+	// already started continue. Defaults to false.
+	// +optional
+	SuspendSchedules *bool `json:"suspendSchedules,omitempty"`
+
+	// Actions taken before and after each scheduled full backup, e.g. so that
+	// applications can reach a consistency point. When set, scheduled full
+	// backups start only after their before hook succeeds.
+	// +optional
+	FullBackupHooks *PGBackRestBackupHooks `json:"fullBackupHooks,omitempty"`
 }
 
+// PGBackRestArchivePush defines how PostgreSQL instances push WAL files to the
+// repositories.
+type PGBackRestArchivePush struct {
+
+	// How long archiving can be unhealthy before PGO alerts with a
+	// WALArchivingStalled event. The event repeats on every check until
+	// archiving recovers. When unset, failures are reported only in the
+	// ArchivingHealthy condition and a WALArchivingFailed event.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	AlertAfterMinutes *int32 `json:"alertAfterMinutes,omitempty"`
+
+	// The maximum size of WAL waiting to be sent to the repositories. When the
+	// queue exceeds this size, WAL files are discarded so that PostgreSQL does not
+	// run out of space. Backups cannot restore to any point in discarded WAL.
+	// This applies whether or not archiving is asynchronous; the pushQueueMax
+	// of asyncArchive takes precedence. Defaults to no limit.
+	// More info: https://pgbackrest.org/configuration.html#section-archive/option-archive-push-queue-max
+	// +optional
+	QueueMax *resource.Quantity `json:"queueMax,omitempty"`
+
+	// The number of times pgBackRest tries again to push a WAL file that failed
+	// before it reports the failure to PostgreSQL. PostgreSQL keeps trying to
+	// archive that file afterward.
+	// More info: https://pgbackrest.org/configuration.html#section-general/option-job-retry
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=99
+	// +optional
+	Retries *int32 `json:"retries,omitempty"`
+
+	// The number of seconds pgBackRest waits for each WAL file to reach the
+	// repositories before it fails. Defaults to 60 seconds.
+	// More info: https://pgbackrest.org/configuration.html#section-archive/option-archive-timeout
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// PGBackRestAsyncArchive defines asynchronous archiving of WAL by PostgreSQL instances.
+type PGBackRestAsyncArchive struct {
+
+	// The maximum size of WAL waiting to be sent to the repositories. When the
+	// queue exceeds this size, WAL files are discarded so that PostgreSQL does not
+	// run out of space. Backups cannot restore to any point in discarded WAL.
+	// Defaults to no limit.
+	// More info: https://pgbackrest.org/configuration.html#section-archive/option-archive-push-queue-max
+	// +optional
+	PushQueueMax *resource.Quantity `json:"pushQueueMax,omitempty"`
+
+	// Defines a PersistentVolumeClaim for the spool directory of each PostgreSQL
+	// instance. When unset, the spool directory is on the pgData volume.
+	// More info: https://pgbackrest.org/configuration.html#section-general/option-spool-path
+	// +optional
+	SpoolVolumeClaimSpec *corev1.PersistentVolumeClaimSpec `json:"spoolVolumeClaimSpec,omitempty"`
+}
+
+// PGBackRestIO defines the network and storage I/O options of pgBackRest.
+type PGBackRestIO struct {
+
+	// The number of seconds pgBackRest waits for a read or write to a repository
+	// or remote host to make progress before it fails. Raise this when the network
+	// to a cloud repository is slow or congested. Defaults to 60 seconds.
+	// More info: https://pgbackrest.org/configuration.html#section-general/option-io-timeout
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=3600
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// The size of the buffers pgBackRest uses to copy and compress files. Larger
+	// buffers can make transfers faster at the cost of memory. pgBackRest rounds
+	// this up to a power of two between 16KiB and 16MiB. Defaults to 1MiB.
+	// More info: https://pgbackrest.org/configuration.html#section-general/option-buffer-size
+	// +optional
+	BufferSize *resource.Quantity `json:"bufferSize,omitempty"`
+}
+
+// PGBackRestArchive replica creation strategies.
+const (
+	PGBackRestReplicaCreateBackup       = "Backup"
+	PGBackRestReplicaCreateBasebackup   = "Basebackup"
+	PGBackRestReplicaCreateLatestBackup = "LatestBackup"
+)
+
 // PGBackRestSidecars defines the configuration for pgBackRest sidecar containers
 type PGBackRestSidecars struct {
 	// Defines the configuration for the pgBackRest sidecar container
@@ -181,11 +409,51 @@ type BackupJobs struct {
 	// +optional
 	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
 
+	// Node labels that pgBackRest backup Job pods must match to be scheduled.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/assign-pod-node/#nodeselector
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
 	// Limit the lifetime of a Job that has finished.
 	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/job
 	// +optional
 	// +kubebuilder:validation:Minimum=60
 	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+
+	// The number of times to retry a failed backup Job pod before marking the
+	// Job failed. Defaults to 6.
+	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/job/#pod-backoff-failure-policy
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// The number of seconds a backup Job may run, including retries, before it
+	// is stopped and marked failed.
+	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/job/#job-termination-and-cleanup
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// How pods of the a backup Job that fail count toward its backoffLimit.
+	// +optional
+	PodFailurePolicy *JobPodFailurePolicy `json:"podFailurePolicy,omitempty"`
+}
+
+// PGBackRestJobHistoryLimit defines how many finished pgBackRest backup Jobs to keep.
+type PGBackRestJobHistoryLimit struct {
+	// The number of successful backup Jobs to keep. When omitted, each backup schedule
+	// keeps the Kubernetes default of 3.
+	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/cron-jobs/#jobs-history-limits
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Successful *int32 `json:"successful,omitempty"`
+
+	// The number of failed backup Jobs to keep. When omitted, each backup schedule
+	// keeps the Kubernetes default of 1.
+	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/cron-jobs/#jobs-history-limits
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Failed *int32 `json:"failed,omitempty"`
 }
 
 // PGBackRestManualBackup contains information that is used for creating a
@@ -196,12 +464,30 @@ type PGBackRestManualBackup struct {
 	// +kubebuilder:validation:Pattern=^repo[1-4]
 	RepoName string `json:"repoName"`
 
+	// The type of backup to take: full, diff (differential), or incr (incremental).
+	// When omitted, pgBackRest takes the type in options or an incremental backup.
+	// https://pgbackrest.org/command.html#command-backup/category-command/option-type
+	// +kubebuilder:validation:Enum={full,diff,incr}
+	// +optional
+	Type string `json:"type,omitempty"`
+
 	// Command line options to include when running the pgBackRest backup command.
 	// https://pgbackrest.org/command.html#command-backup
 	// +optional
 	Options []string `json:"options,omitempty"`
 }
 
+// PGBackRestExpireDryRun contains information that is used for running the pgBackRest
+// "expire" command with "--dry-run". It reports what would be removed under the retention
+// settings without removing anything.
+type PGBackRestExpireDryRun struct {
+	// Retention options to try in place of those in the pgBackRest configuration,
+	// e.g. "--repo1-retention-full=2". Only "--repoN-retention-*" options are allowed.
+	// https://pgbackrest.org/command.html#command-expire
+	// +optional
+	Options []string `json:"options,omitempty"`
+}
+
 // PGBackRestRepoHost represents a pgBackRest dedicated repository host
 type PGBackRestRepoHost struct {
 
@@ -211,12 +497,33 @@ type PGBackRestRepoHost struct {
 	// +optional
 	Affinity *corev1.Affinity `json:"affinity,omitempty"`
 
+	// Number of days that the TLS certificates PGO generates for pgBackRest are
+	// valid. Each certificate is replaced after two-thirds of this time, and the
+	// repo host reloads it without restarting. Defaults to 365.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	CertificateDurationDays *int32 `json:"certificateDurationDays,omitempty"`
+
+	// Node labels that the Dedicated repo host pod must match to be scheduled.
+	// Changing this value causes the repo host to restart.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/assign-pod-node/#nodeselector
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
 	// Priority class name for the pgBackRest repo host pod. Changing this value
 	// causes PostgreSQL to restart.
 	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/pod-priority-preemption/
 	// +optional
 	PriorityClassName *string `json:"priorityClassName,omitempty"`
 
+	// Number of repository host pods. One pod at a time is active: PostgreSQL
+	// sends it WAL and backups run in it. When the active pod is not ready, another
+	// ready pod becomes active. More than one pod requires that every repository
+	// volume be ReadWriteMany so that all the pods mount it. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
 	// Resource requirements for a pgBackRest repository host
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
@@ -250,6 +557,14 @@ type PGBackRestRestore struct {
 	// +kubebuilder:default=false
 	Enabled *bool `json:"enabled"`
 
+	// Whether or not pgBackRest reuses the files of an in-place restore that
+	// already match the backup, copying only the ones that differ. This is much
+	// faster for large clusters. When false, the data directory is emptied
+	// before restoring.
+	// +kubebuilder:default=true
+	// +optional
+	Delta *bool `json:"delta,omitempty"`
+
 	*PostgresClusterDataSource `json:",inline"`
 }
 
@@ -277,6 +592,98 @@ type PGBackRestBackupSchedules struct {
 	// +optional
 	// +kubebuilder:validation:MinLength=6
 	Incremental *string `json:"incremental,omitempty"`
+
+	// Whether to stop creating the full, differential and incremental backups of
+	// this repository, e.g. during maintenance. The schedules are kept, and backups
+	// that have already started continue. Defaults to false.
+	// +optional
+	Suspend *bool `json:"suspend,omitempty"`
+}
+
+// PGBackRestBackupHooks defines actions taken around scheduled pgBackRest backups
+type PGBackRestBackupHooks struct {
+	// Runs before each backup. The backup waits until it succeeds, and it is
+	// retried every minute until then.
+	// +optional
+	Before *PGBackRestBackupHook `json:"before,omitempty"`
+
+	// Runs once after each backup that waited for the before hook, whether or
+	// not the backup succeeded.
+	// +optional
+	After *PGBackRestBackupHook `json:"after,omitempty"`
+}
+
+// PGBackRestBackupHook is an HTTP request or SQL statements. Exactly one of
+// url or sql must be set.
+// +kubebuilder:validation:MinProperties=1
+type PGBackRestBackupHook struct {
+	// An HTTP or HTTPS URL to which PGO sends a POST request describing the
+	// backup. Any 2xx response is success.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL *string `json:"url,omitempty"`
+
+	// SQL statements executed by psql as the postgres superuser in the
+	// "postgres" database of the primary. Each hook has its own session.
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	SQL *string `json:"sql,omitempty"`
+
+	// How long the hook may take. Defaults to 30 seconds.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// PGBackRestBackupOptions defines the compression, parallelism and other options of
+// pgBackRest backups
+type PGBackRestBackupOptions struct {
+	// The type of compression used for backup files.
+	// https://pgbackrest.org/configuration.html#section-general/option-compress-type
+	// +optional
+	// +kubebuilder:validation:Enum={none,bz2,gz,lz4,zst}
+	CompressType string `json:"compressType,omitempty"`
+
+	// The level of compression used for backup files. The allowed range depends on
+	// compressType: 0-9 for gz (the default), 1-9 for bz2, 0-12 for lz4 and 0-22 for zst.
+	// https://pgbackrest.org/configuration.html#section-general/option-compress-level
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=22
+	CompressLevel *int32 `json:"compressLevel,omitempty"`
+
+	// The maximum number of processes used for compression and transfer.
+	// https://pgbackrest.org/configuration.html#section-general/option-process-max
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=999
+	ProcessMax *int32 `json:"processMax,omitempty"`
+
+	// Command line options to include when running the pgBackRest backup command.
+	// These replace options of the same name set by other fields, and options for
+	// a backup type replace those of the same name for all backups. The --repo,
+	// --stanza and --type options are not allowed.
+	// https://pgbackrest.org/command.html#command-backup
+	// +optional
+	Options []string `json:"options,omitempty"`
+}
+
+// PGBackRestRepoBackupOptions defines the compression, parallelism and other options of
+// the pgBackRest backups to a repository, optionally for each backup type
+type PGBackRestRepoBackupOptions struct {
+	PGBackRestBackupOptions `json:",inline"`
+
+	// Options for full backups.
+	// +optional
+	Full *PGBackRestBackupOptions `json:"full,omitempty"`
+
+	// Options for differential backups.
+	// +optional
+	Differential *PGBackRestBackupOptions `json:"differential,omitempty"`
+
+	// Options for incremental backups.
+	// +optional
+	Incremental *PGBackRestBackupOptions `json:"incremental,omitempty"`
 }
 
 // PGBackRestStatus defines the status of pgBackRest within a PostgresCluster
@@ -290,6 +697,16 @@ type PGBackRestStatus struct {
 	// +optional
 	ScheduledBackups []PGBackRestScheduledBackupStatus `json:"scheduledBackups,omitempty"`
 
+	// Names of the backup Jobs that are running, whether manual, scheduled, or
+	// for replica creation
+	// +listType=set
+	// +optional
+	RunningBackups []string `json:"runningBackups,omitempty"`
+
+	// Status information for the most recent backup Job to finish
+	// +optional
+	LastBackup *PGBackRestLastBackupStatus `json:"lastBackup,omitempty"`
+
 	// Status information for the pgBackRest dedicated repository host
 	// +optional
 	RepoHost *RepoHostStatus `json:"repoHost,omitempty"`
@@ -303,6 +720,55 @@ type PGBackRestStatus struct {
 	// Status information for in-place restores
 	// +optional
 	Restore *PGBackRestJobStatus `json:"restore,omitempty"`
+
+	// What the pgBackRest "expire" command would remove, as of the most recent
+	// dry run
+	// +optional
+	ExpireDryRun *PGBackRestExpireDryRunStatus `json:"expireDryRun,omitempty"`
+}
+
+// PGBackRestExpireDryRunStatus contains what the pgBackRest "expire" command would remove
+// from each repository.
+type PGBackRestExpireDryRunStatus struct {
+
+	// A unique identifier for the dry run as provided using the "pgbackrest-expire-dry-run"
+	// annotation when requesting it.
+	// +kubebuilder:validation:Required
+	ID string `json:"id"`
+
+	// Represents the time the dry run finished. It is represented in RFC3339 form
+	// and is in UTC.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Why the dry run failed. It is empty when the dry run succeeded.
+	// +optional
+	Error string `json:"error,omitempty"`
+
+	// What would be removed from each repository with a stanza
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Repos []PGBackRestExpireDryRunRepoStatus `json:"repos,omitempty"`
+}
+
+// PGBackRestExpireDryRunRepoStatus contains what the pgBackRest "expire" command would
+// remove from one repository.
+type PGBackRestExpireDryRunRepoStatus struct {
+	// The name of the pgBackRest repository
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// The labels of the backups that would be removed
+	// +listType=atomic
+	// +optional
+	Backups []string `json:"backups,omitempty"`
+
+	// The ranges of WAL files that would be removed, each the first and last
+	// file separated by a hyphen
+	// +listType=atomic
+	// +optional
+	Archive []string `json:"archive,omitempty"`
 }
 
 // PGBackRestRepo represents a pgBackRest repository.  Only one of its members may be specified.
@@ -316,12 +782,107 @@ type PGBackRestRepo struct {
 	// +kubebuilder:validation:Pattern=^repo[1-4]
 	Name string `json:"name"`
 
+	// The path of the repository in its Azure container, GCS bucket, or S3
+	// bucket. Defaults to "/pgbackrest/" followed by the name of the repo. Set
+	// this so that clusters can share a bucket or to use an existing repository.
+	// Repositories on a volume or a SharedRepoHost are always at their default path.
+	// https://pgbackrest.org/configuration.html#section-repository/option-repo-path
+	// +optional
+	// +kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path,omitempty"`
+
+	// Whether to use a stanza that already exists in this repository rather than
+	// create one. When the stanza belongs to the PostgreSQL database of this cluster,
+	// it is adopted without running stanza-create. Defaults to false.
+	// +optional
+	AdoptExisting *bool `json:"adoptExisting,omitempty"`
+
 	// Defines the schedules for the pgBackRest backups
 	// Full, Differential and Incremental backup types are supported:
 	// https://pgbackrest.org/user-guide.html#concept/backup
 	// +optional
 	BackupSchedules *PGBackRestBackupSchedules `json:"schedules,omitempty"`
 
+	// Defines the Cron schedule for checking the integrity of the repository
+	// with pgBackRest verify. The result is reported in the status of the repository.
+	// Follows the standard Cron schedule syntax:
+	// https://k8s.io/docs/concepts/workloads/controllers/cron-jobs/#cron-schedule-syntax
+	// +optional
+	// +kubebuilder:validation:MinLength=6
+	VerifySchedule *string `json:"verifySchedule,omitempty"`
+
+	// Defines the Cron schedule for removing backups and WAL from the repository
+	// with pgBackRest expire, according to its retention options. Use this when
+	// backups are taken by another system and PGO does not schedule them.
+	// Follows the standard Cron schedule syntax:
+	// https://k8s.io/docs/concepts/workloads/controllers/cron-jobs/#cron-schedule-syntax
+	// +optional
+	// +kubebuilder:validation:MinLength=6
+	ExpireSchedule *string `json:"expireSchedule,omitempty"`
+
+	// Copies the backups and WAL in this repository to an S3 bucket, e.g. in another
+	// region, on a schedule. The copy can restore a cluster when this repository is
+	// lost. Only repositories on a volume or in S3 can be copied.
+	// +optional
+	Sync *PGBackRestRepoSync `json:"sync,omitempty"`
+
+	// Defines the compression, parallelism and other options of backups to this
+	// repository. Options for a backup type take precedence over those for all backups.
+	// +optional
+	BackupOptions *PGBackRestRepoBackupOptions `json:"backupOptions,omitempty"`
+
+	// The number of full backups to retain in the repository. When retentionFullType
+	// is "time", the number of days full backups are retained.
+	// https://pgbackrest.org/configuration.html#section-repository/option-repo-retention-full
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9999999
+	RetentionFull *int32 `json:"retentionFull,omitempty"`
+
+	// Whether retentionFull is a number of backups ("count") or a number of days ("time").
+	// Defaults to "count" when not provided.
+	// https://pgbackrest.org/configuration.html#section-repository/option-repo-retention-full-type
+	// +optional
+	// +kubebuilder:validation:Enum={count,time}
+	RetentionFullType string `json:"retentionFullType,omitempty"`
+
+	// The number of differential backups to retain in the repository.
+	// https://pgbackrest.org/configuration.html#section-repository/option-repo-retention-diff
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9999999
+	RetentionDiff *int32 `json:"retentionDiff,omitempty"`
+
+	// The number of backups worth of continuous WAL to retain in the repository.
+	// https://pgbackrest.org/configuration.html#section-repository/option-repo-retention-archive
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9999999
+	RetentionArchive *int32 `json:"retentionArchive,omitempty"`
+
+	// Whether retentionArchive counts full ("full"), differential ("diff") or
+	// incremental ("incr") backups. Defaults to "full" when not provided.
+	// https://pgbackrest.org/configuration.html#section-repository/option-repo-retention-archive-type
+	// +optional
+	// +kubebuilder:validation:Enum={full,diff,incr}
+	RetentionArchiveType string `json:"retentionArchiveType,omitempty"`
+
+	// The number of days of backups and WAL to retain in the repository, so the
+	// cluster can be restored to any time in that window. This takes precedence
+	// over retentionFull and retentionFullType. Expired backups and WAL are removed
+	// after each backup and, when expireSchedule is not set, every day at midnight.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9999999
+	RetentionDays *int32 `json:"retentionDays,omitempty"`
+
+	// The size of each part pgBackRest uploads to an Azure, GCS or S3 repository.
+	// Smaller parts send less at once and retry faster on a congested network; larger
+	// parts make fewer requests. Ignored for repositories on a volume or SharedRepoHost.
+	// More info: https://pgbackrest.org/configuration.html#section-repository/option-repo-storage-upload-chunk-size
+	// +optional
+	UploadChunkSize *resource.Quantity `json:"uploadChunkSize,omitempty"`
+
 	// Represents a pgBackRest repository that is created using Azure storage
 	// +optional
 	Azure *RepoAzure `json:"azure,omitempty"`
@@ -338,6 +899,12 @@ type PGBackRestRepo struct {
 	// Represents a pgBackRest repository that is created using a PersistentVolumeClaim
 	// +optional
 	Volume *RepoPVC `json:"volume,omitempty"`
+
+	// Represents a pgBackRest repository on a SharedRepoHost in the same namespace.
+	// The stanza of every repository in this cluster is the cluster name when
+	// any repository is on a SharedRepoHost.
+	// +optional
+	SharedHost *RepoSharedHost `json:"sharedHost,omitempty"`
 }
 
 // RepoHostStatus defines the status of a pgBackRest repository host
@@ -347,6 +914,10 @@ type RepoHostStatus struct {
 	// Whether or not the pgBackRest repository host is ready for use
 	// +optional
 	Ready bool `json:"ready"`
+
+	// The name of the repository host pod that is active when there is more than one
+	// +optional
+	ActivePod string `json:"activePod,omitempty"`
 }
 
 // RepoPVC represents a pgBackRest repository that is created using a PersistentVolumeClaim
@@ -357,12 +928,42 @@ type RepoPVC struct {
 	VolumeClaimSpec corev1.PersistentVolumeClaimSpec `json:"volumeClaimSpec"`
 }
 
+// RepoSharedHost represents a pgBackRest repository on a SharedRepoHost
+type RepoSharedHost struct {
+
+	// The name of the SharedRepoHost in the namespace of the cluster
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
 // RepoAzure represents a pgBackRest repository that is created using Azure storage
 type RepoAzure struct {
 
 	// The Azure container utilized for the repository
 	// +kubebuilder:validation:Required
 	Container string `json:"container"`
+
+	// The type of credentials pgBackRest uses to access the container. Defaults to "shared",
+	// which reads a shared account key from the pgBackRest configuration. "sas" reads a shared
+	// access signature from the pgBackRest configuration. "auto" uses the Azure managed
+	// identity of the pods or, when clientID and tenantID are set, a workload identity.
+	// More info: https://pgbackrest.org/configuration.html#section-repository/option-repo-azure-key-type
+	// +kubebuilder:validation:Enum={shared,sas,auto}
+	// +optional
+	KeyType string `json:"keyType,omitempty"`
+
+	// The client ID of the Microsoft Entra application or user-assigned managed identity
+	// federated with the service account of the pods. When this and tenantID are set, a
+	// service account token is projected into pods and exchanged for Azure credentials.
+	// Every Azure repository using a workload identity must specify the same identity.
+	// More info: https://azure.github.io/azure-workload-identity/docs/
+	// +optional
+	ClientID string `json:"clientID,omitempty"`
+
+	// The Microsoft Entra tenant ID of the workload identity in clientID.
+	// +optional
+	TenantID string `json:"tenantID,omitempty"`
 }
 
 // RepoGCS represents a pgBackRest repository that is created using Google Cloud Storage
@@ -388,6 +989,78 @@ type RepoS3 struct {
 	// The region corresponding to the S3 bucket
 	// +kubebuilder:validation:Required
 	Region string `json:"region"`
+
+	// The type of credentials pgBackRest uses to access the bucket. Defaults to "shared",
+	// which reads static keys from the pgBackRest configuration. "auto" retrieves temporary
+	// credentials from the instance metadata service. "web-id" assumes the IAM role in
+	// roleARN using a service account token projected into pods, e.g. IAM roles for
+	// service accounts (IRSA) on Amazon EKS.
+	// More info: https://pgbackrest.org/configuration.html#section-repository/option-repo-s3-key-type
+	// +kubebuilder:validation:Enum={shared,auto,web-id}
+	// +optional
+	KeyType string `json:"keyType,omitempty"`
+
+	// The ARN of the IAM role to assume when keyType is "web-id". Every S3 repository
+	// using "web-id" must specify the same role.
+	// +optional
+	RoleARN string `json:"roleARN,omitempty"`
+
+	// The default retention period, in days, of S3 Object Lock on the bucket.
+	// Objects cannot be deleted until they are this old, so full backups are
+	// retained for at least this many days using time-based retention.
+	// More info: https://docs.aws.amazon.com/AmazonS3/latest/userguide/object-lock.html
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=36500
+	ObjectLockDays *int32 `json:"objectLockDays,omitempty"`
+}
+
+// PGBackRestRepoSync defines how a pgBackRest repository is copied to another bucket.
+type PGBackRestRepoSync struct {
+
+	// Defines the Cron schedule for copying the repository. Follows the standard Cron
+	// schedule syntax:
+	// https://k8s.io/docs/concepts/workloads/controllers/cron-jobs/#cron-schedule-syntax
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=6
+	Schedule string `json:"schedule"`
+
+	// The S3 bucket to copy the repository to. The copy is at the path of the
+	// repository in the bucket.
+	// +kubebuilder:validation:Required
+	S3 RepoSyncS3 `json:"s3"`
+
+	// A Secret in the namespace of the cluster with the AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY that access both buckets. Its keys become environment
+	// variables of the copy Job. When omitted, credentials come from the environment,
+	// e.g. IAM roles for service accounts.
+	// +optional
+	CredentialsSecret *corev1.LocalObjectReference `json:"credentialsSecret,omitempty"`
+
+	// The image name to use for the copy Job. It must contain rclone. The image
+	// may also be set using the RELATED_IMAGE_RCLONE environment variable.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Resource requirements for the copy Job.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// RepoSyncS3 is an S3 (or S3-compatible) bucket that a pgBackRest repository is copied to.
+type RepoSyncS3 struct {
+
+	// The S3 bucket
+	// +kubebuilder:validation:Required
+	Bucket string `json:"bucket"`
+
+	// A valid endpoint corresponding to the specified region
+	// +kubebuilder:validation:Required
+	Endpoint string `json:"endpoint"`
+
+	// The region corresponding to the S3 bucket
+	// +kubebuilder:validation:Required
+	Region string `json:"region"`
 }
 
 // RepoStatus the status of a pgBackRest repository
@@ -418,6 +1091,122 @@ type RepoStatus struct {
 	// commands accordingly.
 	// +optional
 	RepoOptionsHash string `json:"repoOptionsHash,omitempty"`
+
+	// Whether or not the most recent scheduled pgBackRest verify of the repository
+	// found it to be free of errors. Unset until a verify has finished.
+	// +optional
+	Verified *bool `json:"verified,omitempty"`
+
+	// The most recent copy of the repository to its sync bucket. Unset until a
+	// copy has finished.
+	// +optional
+	Sync *RepoSyncStatus `json:"sync,omitempty"`
+
+	// The time at which the newest full backup in the repository finished
+	// +optional
+	LastFullBackupTime *metav1.Time `json:"lastFullBackupTime,omitempty"`
+
+	// The time at which the newest differential backup in the repository finished
+	// +optional
+	LastDifferentialBackupTime *metav1.Time `json:"lastDifferentialBackupTime,omitempty"`
+
+	// The time at which the newest incremental backup in the repository finished
+	// +optional
+	LastIncrementalBackupTime *metav1.Time `json:"lastIncrementalBackupTime,omitempty"`
+
+	// The size in bytes of the newest backup in the repository, after compression
+	// +optional
+	LastBackupSize *int64 `json:"lastBackupSize,omitempty"`
+
+	// The oldest WAL file archived to the repository
+	// +optional
+	ArchiveMin string `json:"archiveMin,omitempty"`
+
+	// The newest WAL file archived to the repository
+	// +optional
+	ArchiveMax string `json:"archiveMax,omitempty"`
+
+	// The total size in bytes of the backups in the repository, after compression.
+	// This does not include WAL.
+	// +optional
+	BackupsSize *int64 `json:"backupsSize,omitempty"`
+
+	// The newest backups in the repository, oldest first. At most 20 are listed.
+	// +listType=atomic
+	// +optional
+	Backups []RepoBackupStatus `json:"backups,omitempty"`
+
+	// The ranges of time to which the repository can restore the cluster, oldest
+	// first. A backup whose WAL has expired can restore only to the time it finished.
+	// The newest range ends when the backup information was read while WAL archiving
+	// is healthy, or when the newest backup finished otherwise. At most 20 are listed.
+	// +listType=atomic
+	// +optional
+	RecoveryWindows []RepoRecoveryWindow `json:"recoveryWindows,omitempty"`
+
+	// The time at which the backup information above was last read from the repository
+	// +optional
+	BackupInfoTime *metav1.Time `json:"backupInfoTime,omitempty"`
+}
+
+// RepoRecoveryWindow is a range of time to which a pgBackRest repository can restore.
+type RepoRecoveryWindow struct {
+
+	// The earliest time in the range
+	Start metav1.Time `json:"start"`
+
+	// The latest time in the range
+	End metav1.Time `json:"end"`
+}
+
+// RepoSyncStatus describes the copy of a pgBackRest repository in its sync bucket.
+type RepoSyncStatus struct {
+
+	// When the most recent successful copy started. Backups and WAL in the
+	// repository at that time are in the copy.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// The replication lag in seconds when the most recent successful copy
+	// finished, i.e. how long the copy took. The lag grows from there until
+	// the next copy finishes.
+	// +optional
+	LagSeconds *int64 `json:"lagSeconds,omitempty"`
+
+	// Whether or not the most recent copy failed
+	// +optional
+	Failed bool `json:"failed,omitempty"`
+}
+
+// RepoBackupStatus describes one backup in a pgBackRest repository.
+type RepoBackupStatus struct {
+
+	// The label pgBackRest assigned to the backup
+	Label string `json:"label"`
+
+	// The type of the backup: full, diff (differential), or incr (incremental)
+	Type string `json:"type"`
+
+	// The time at which the backup started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// The time at which the backup finished
+	// +optional
+	StopTime *metav1.Time `json:"stopTime,omitempty"`
+
+	// The size in bytes of the backup in the repository, after compression. This
+	// includes any backups it depends on.
+	// +optional
+	Size int64 `json:"size,omitempty"`
+
+	// The first WAL file needed to make the backup consistent
+	// +optional
+	ArchiveStart string `json:"archiveStart,omitempty"`
+
+	// The last WAL file needed to make the backup consistent
+	// +optional
+	ArchiveStop string `json:"archiveStop,omitempty"`
 }
 
 // PGBackRestDataSource defines a pgBackRest configuration specifically for restoring from cloud-based data source
@@ -433,6 +1222,9 @@ type PGBackRestDataSource struct {
 	// section of the pgBackRest configuration generated by the PostgreSQL Operator, and then
 	// mounted under "/etc/pgbackrest/conf.d":
 	// https://pgbackrest.org/configuration.html
+	// Options that PGO manages, such as log-path and tls-server-*, cannot be
+	// set. When they are, PGO does not start the restore and reports them in an
+	// InvalidPGBackRestGlobal event and the PGBackRestGlobalValid condition.
 	// +optional
 	Global map[string]string `json:"global,omitempty"`
 
@@ -445,6 +1237,22 @@ type PGBackRestDataSource struct {
 	// +kubebuilder:default="db"
 	Stanza string `json:"stanza"`
 
+	// The path of the repository in which to find the stanza. Defaults to "/pgbackrest/"
+	// followed by the name of the repo, which is where PGO keeps the backups of a
+	// PostgresCluster. Set this to restore from a repository written by a differently
+	// named cluster or by pgBackRest outside of Kubernetes.
+	// https://pgbackrest.org/configuration.html#section-repository/option-repo-path
+	// +optional
+	// +kubebuilder:validation:Pattern=`^/`
+	RepoPath string `json:"repoPath,omitempty"`
+
+	// Whether to only check the restore. A Job confirms that the repository has
+	// the backup set and WAL that the restore needs and reports the result in the
+	// PGBackRestRestoreDryRun condition. The data directory is left untouched and
+	// the PostgresCluster waits to bootstrap until this is false. Defaults to false.
+	// +optional
+	DryRun *bool `json:"dryRun,omitempty"`
+
 	// Command line options to include when running the pgBackRest restore command.
 	// https://pgbackrest.org/command.html#command-restore
 	// +optional
@@ -469,4 +1277,29 @@ type PGBackRestDataSource struct {
 	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/taint-and-toleration
 	// +optional
 	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// The number of times to retry a failed pgBackRest restore Job pod before
+	// marking the Job failed. Defaults to 6.
+	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/job/#pod-backoff-failure-policy
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// The number of seconds the pgBackRest restore Job may run, including
+	// retries, before it is stopped and marked failed.
+	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/job/#job-termination-and-cleanup
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// How pods of the pgBackRest restore Job that fail count toward its backoffLimit.
+	// +optional
+	PodFailurePolicy *JobPodFailurePolicy `json:"podFailurePolicy,omitempty"`
+
+	// Limit the lifetime of the pgBackRest restore Job after it has finished.
+	// A restore Job that failed and is removed this way is created again.
+	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/job
+	// +kubebuilder:validation:Minimum=60
+	// +optional
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
 }
@@ -53,6 +53,68 @@ type PGBouncerConfiguration struct {
 	// More info: https://www.pgbouncer.org/config.html#section-users
 	// +optional
 	Users map[string]string `json:"users,omitempty"`
+
+	// Pooling settings for particular databases. These are added to the
+	// definition of each database in "databases". A database that is not
+	// defined there is defined like the "*" entry.
+	// More info: https://www.pgbouncer.org/config.html#section-databases
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	DatabasePools []PGBouncerDatabasePool `json:"databasePools,omitempty"`
+
+	// Pooling settings for particular users. These are added to the settings
+	// of each user in "users".
+	// More info: https://www.pgbouncer.org/config.html#section-users
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	UserPools []PGBouncerUserPool `json:"userPools,omitempty"`
+}
+
+// PGBouncerDatabasePool defines how PgBouncer pools connections to a database.
+type PGBouncerDatabasePool struct {
+	// The name of the database requested by clients.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// When a server connection can be reused by other clients.
+	// More info: https://www.pgbouncer.org/config.html#pool_mode
+	// +kubebuilder:validation:Enum={session,transaction,statement}
+	// +optional
+	PoolMode string `json:"poolMode,omitempty"`
+
+	// The number of server connections in each pool of this database.
+	// More info: https://www.pgbouncer.org/config.html#pool_size
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PoolSize *int32 `json:"poolSize,omitempty"`
+
+	// The most server connections to this database in all of its pools.
+	// More info: https://www.pgbouncer.org/config.html#max_db_connections
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConnections *int32 `json:"maxConnections,omitempty"`
+}
+
+// PGBouncerUserPool defines how PgBouncer pools connections for a user.
+type PGBouncerUserPool struct {
+	// The name of the user.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// When a server connection can be reused by other clients. This takes
+	// precedence over the pool mode of the database.
+	// More info: https://www.pgbouncer.org/config.html#pool_mode-1
+	// +kubebuilder:validation:Enum={session,transaction,statement}
+	// +optional
+	PoolMode string `json:"poolMode,omitempty"`
+
+	// The most server connections for this user in all of its pools.
+	// More info: https://www.pgbouncer.org/config.html#max_user_connections
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConnections *int32 `json:"maxConnections,omitempty"`
 }
 
 // PGBouncerPodSpec defines the desired state of a PgBouncer connection pooler.
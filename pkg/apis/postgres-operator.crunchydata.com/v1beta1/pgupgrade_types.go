@@ -30,6 +30,12 @@ type PGUpgradeSpec struct {
 	// +kubebuilder:validation:MinLength=1
 	PostgresClusterName string `json:"postgresClusterName"`
 
+	// Whether or not to add the allow-upgrade annotation to the cluster rather
+	// than wait for it. The annotation is added only when this PGUpgrade has
+	// an owner reference to the cluster or an Approved condition that is true.
+	// +optional
+	AutoAnnotateCluster bool `json:"autoAnnotateCluster,omitempty"`
+
 	// The image name to use for major PostgreSQL upgrades.
 	// +optional
 	Image *string `json:"image,omitempty"`
@@ -73,6 +79,17 @@ type PGUpgradeSpec struct {
 	// +kubebuilder:validation:Maximum=15
 	ToPostgresVersion int `json:"toPostgresVersion"`
 
+	// How pg_upgrade transfers data files to the new cluster. "Link" uses hard
+	// links and is fastest, but the old data directory cannot be started after
+	// the new one. "Copy" leaves the old data directory intact and needs room
+	// for both. "Clone" uses file cloning and requires PostgreSQL 12 or later
+	// and a filesystem that supports it.
+	// More info: https://www.postgresql.org/docs/current/pgupgrade.html
+	// +kubebuilder:default=Link
+	// +kubebuilder:validation:Enum={Copy,Link,Clone}
+	// +optional
+	TransferMode string `json:"transferMode,omitempty"`
+
 	// The image name to use for PostgreSQL containers after upgrade.
 	// When omitted, the value comes from an operator environment variable.
 	// +optional
@@ -100,6 +117,108 @@ type PGUpgradeSpec struct {
 	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/taint-and-toleration
 	// +optional
 	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// The number of times to retry a failed pg_upgrade or remove data Job pod
+	// before marking the Job failed. Defaults to 0; the upgrade is attempted once.
+	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/job/#pod-backoff-failure-policy
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// The number of seconds a pg_upgrade or remove data Job may run, including
+	// retries, before it is stopped and marked failed.
+	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/job/#job-termination-and-cleanup
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// How pods of the pg_upgrade and remove data Jobs that fail count toward
+	// their backoffLimit.
+	// +optional
+	PodFailurePolicy *JobPodFailurePolicy `json:"podFailurePolicy,omitempty"`
+
+	// Whether or not to run `pg_upgrade --check` against a copy of the primary
+	// before the cluster is shut down. When enabled, the upgrade does not begin
+	// until the check succeeds. The copy is written to an emptyDir volume, so
+	// nodes need enough space for the PostgreSQL data directory.
+	// More info: https://www.postgresql.org/docs/current/pgupgrade.html
+	// +optional
+	Preflight bool `json:"preflight,omitempty"`
+
+	// Whether or not to look for the extensions and libraries that the cluster
+	// uses in the image of the new version before the cluster is shut down.
+	// This includes installed extensions, shared_preload_libraries, and the
+	// libraries of C functions such as those of custom data types. When
+	// enabled, the upgrade does not begin until every one is found.
+	// +optional
+	ScanModules bool `json:"scanModules,omitempty"`
+
+	// Whether or not to run `ALTER EXTENSION ... UPDATE` in every database once
+	// the cluster is running the new version. pg_upgrade does not change the
+	// versions of extensions. When set, the upgrade does not succeed until the
+	// extensions are updated.
+	// More info: https://www.postgresql.org/docs/current/sql-alterextension.html
+	// +optional
+	UpdateExtensions *PGUpgradeUpdateExtensionsSpec `json:"updateExtensions,omitempty"`
+
+	// Take a full pgBackRest backup of the cluster before it is shut down for
+	// the upgrade. The upgrade does not begin until the backup is in the
+	// repository. This replaces the manual backup settings of the cluster.
+	// +optional
+	PreUpgradeBackup *PGUpgradePreUpgradeBackupSpec `json:"preUpgradeBackup,omitempty"`
+
+	// Whether or not to restore the pre-upgrade backup when the upgrade jobs
+	// fail. The cluster is set back to fromPostgresVersion and restored in
+	// place. Requires preUpgradeBackup.
+	// +optional
+	Rollback bool `json:"rollback,omitempty"`
+
+	// How the cluster is upgraded. "PGUpgrade" shuts the cluster down and runs
+	// pg_upgrade on its volumes. "LogicalReplication" creates a new cluster at
+	// toPostgresVersion, copies data into it using logical replication while
+	// the cluster keeps running, and cuts over once the two are in sync.
+	// More info: https://www.postgresql.org/docs/current/logical-replication.html
+	// +kubebuilder:default=PGUpgrade
+	// +kubebuilder:validation:Enum={PGUpgrade,LogicalReplication}
+	// +optional
+	Method string `json:"method,omitempty"`
+
+	// Settings for the LogicalReplication method.
+	// +optional
+	LogicalReplication *PGUpgradeLogicalReplicationSpec `json:"logicalReplication,omitempty"`
+}
+
+// PGUpgradeLogicalReplicationSpec defines an upgrade through logical replication.
+type PGUpgradeLogicalReplicationSpec struct {
+	// The name of the PostgresCluster to create at toPostgresVersion. Defaults
+	// to postgresClusterName followed by a hyphen and toPostgresVersion.
+	// +kubebuilder:validation:MaxLength=40
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+
+	// Whether to cut over to the new cluster once it is in sync. The old
+	// cluster refuses writes until the new cluster has applied every change
+	// and the values of sequences are copied. Defaults to false.
+	// +optional
+	Cutover bool `json:"cutover,omitempty"`
+}
+
+// PGUpgradePreUpgradeBackupSpec defines the backup taken before the upgrade.
+type PGUpgradePreUpgradeBackupSpec struct {
+	// The name of the pgBackRest repository in which to take the backup.
+	// +kubebuilder:validation:Pattern=^repo[1-4]
+	// +required
+	RepoName string `json:"repoName"`
+}
+
+// PGUpgradeUpdateExtensionsSpec defines the extensions to update after upgrade.
+type PGUpgradeUpdateExtensionsSpec struct {
+	// The names of extensions to update. When empty, every installed extension
+	// is updated.
+	// +listType=set
+	// +optional
+	Names []string `json:"names,omitempty"`
 }
 
 // PGUpgradeStatus defines the observed state of PGUpgrade
@@ -114,6 +233,126 @@ type PGUpgradeStatus struct {
 	// +optional
 	// +kubebuilder:validation:Minimum=0
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// The checks that failed during the most recent pre-flight check. These
+	// must be resolved before the upgrade can begin.
+	// +optional
+	Incompatibilities []string `json:"incompatibilities,omitempty"`
+
+	// The extensions and libraries that the most recent module scan did not
+	// find in the image of the new version. These must be installed in that
+	// image or removed from the cluster before the upgrade can begin.
+	// +optional
+	MissingModules []string `json:"missingModules,omitempty"`
+
+	// The results of updating extensions in each database that had extensions
+	// to update after the upgrade.
+	// +listType=map
+	// +listMapKey=database
+	// +optional
+	ExtensionUpdates []PGUpgradeExtensionUpdateStatus `json:"extensionUpdates,omitempty"`
+
+	// The backup taken before the upgrade.
+	// +optional
+	PreUpgradeBackup *PGUpgradeBackupStatus `json:"preUpgradeBackup,omitempty"`
+
+	// The progress of an upgrade through logical replication.
+	// +optional
+	LogicalReplication *PGUpgradeLogicalReplicationStatus `json:"logicalReplication,omitempty"`
+
+	// The step of the upgrade that is happening or, when the upgrade cannot
+	// continue, Blocked. The conditions explain why.
+	// +kubebuilder:validation:Enum={Pending,Checking,BackingUp,Upgrading,RemovingReplicaData,Finalizing,Replicating,CuttingOver,Blocked,Failed,RollingBack,RolledBack,Succeeded}
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// The progress of each step of the upgrade.
+	// +optional
+	Progress *PGUpgradeProgress `json:"progress,omitempty"`
+}
+
+// PGUpgradeProgress is the progress of each step of a PGUpgrade.
+type PGUpgradeProgress struct {
+	// Whether or not every check before the upgrade Job passed. The cluster
+	// must be shut down and annotated, and any pre-flight check and backup
+	// must succeed.
+	// +optional
+	ChecksPassed bool `json:"checksPassed,omitempty"`
+
+	// The state of the Job that runs pg_upgrade.
+	// +kubebuilder:validation:Enum={Pending,Running,Succeeded,Failed}
+	// +optional
+	UpgradeJob string `json:"upgradeJob,omitempty"`
+
+	// The number of replicas whose data must be removed after pg_upgrade.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// The number of replicas whose data has been removed.
+	// +optional
+	ReplicasRemoved int32 `json:"replicasRemoved,omitempty"`
+
+	// Whether or not a full backup of the new version has been requested.
+	// +optional
+	BackupRequested bool `json:"backupRequested,omitempty"`
+
+	// Whether or not the full backup of the new version has completed.
+	// +optional
+	BackupCompleted bool `json:"backupCompleted,omitempty"`
+}
+
+// PGUpgradeLogicalReplicationStatus is the progress of an upgrade through
+// logical replication.
+type PGUpgradeLogicalReplicationStatus struct {
+	// The name of the PostgresCluster created at toPostgresVersion.
+	// +required
+	ClusterName string `json:"clusterName"`
+
+	// The databases whose schema was copied to the new cluster and that
+	// subscribe to changes in the old cluster.
+	// +listType=set
+	// +optional
+	Databases []string `json:"databases,omitempty"`
+
+	// The number of tables whose initial data is still being copied.
+	// +optional
+	TablesSyncing *int32 `json:"tablesSyncing,omitempty"`
+
+	// The number of bytes of WAL in the old cluster that the new cluster has
+	// yet to confirm.
+	// +optional
+	LagBytes *int64 `json:"lagBytes,omitempty"`
+}
+
+// PGUpgradeBackupStatus identifies the backup taken before the upgrade.
+type PGUpgradeBackupStatus struct {
+	// The value of the manual backup annotation that requested the backup.
+	// +required
+	ID string `json:"id"`
+
+	// The name of the pgBackRest repository that holds the backup.
+	// +required
+	RepoName string `json:"repoName"`
+
+	// The label pgBackRest assigned to the backup. Empty until the backup is
+	// in the repository.
+	// +optional
+	Label string `json:"label,omitempty"`
+}
+
+// PGUpgradeExtensionUpdateStatus is the result of updating extensions in one database.
+type PGUpgradeExtensionUpdateStatus struct {
+	// The name of the database.
+	// +required
+	Database string `json:"database"`
+
+	// The extensions that were updated.
+	// +optional
+	Updated []string `json:"updated,omitempty"`
+
+	// The reason extensions could not be updated, if any.
+	// +optional
+	Error string `json:"error,omitempty"`
 }
 
 //+kubebuilder:object:root=true
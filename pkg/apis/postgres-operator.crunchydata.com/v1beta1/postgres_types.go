@@ -15,6 +15,12 @@
 
 package v1beta1
 
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 // PostgreSQL identifiers are limited in length but may contain any character.
 // More info: https://www.postgresql.org/docs/current/sql-syntax-lexical.html#SQL-SYNTAX-IDENTIFIERS
 //
@@ -57,6 +63,16 @@ type PostgresUserSpec struct {
 	// +optional
 	Databases []PostgresIdentifier `json:"databases,omitempty"`
 
+	// Group roles of which this user is a member. Roles that do not exist are
+	// created without LOGIN. When this field is set, membership in any role that
+	// is not listed is revoked; set it to an empty list to revoke every
+	// membership. When it is not set, memberships are not changed. This field is
+	// ignored for the "postgres" user.
+	// More info: https://www.postgresql.org/docs/current/role-membership.html
+	// +listType=set
+	// +optional
+	MemberOf []PostgresIdentifier `json:"memberOf,omitempty"`
+
 	// ALTER ROLE options except for PASSWORD. This field is ignored for the
 	// "postgres" user.
 	// More info: https://www.postgresql.org/docs/current/role-attributes.html
@@ -68,3 +84,330 @@ type PostgresUserSpec struct {
 	// +optional
 	Password *PostgresPasswordSpec `json:"password,omitempty"`
 }
+
+// PostgresUserSyncSpec defines how users are read from a SCIM 2.0 service
+// provider, such as an identity provider or a gateway to an LDAP directory.
+// More info: https://datatracker.ietf.org/doc/html/rfc7644
+type PostgresUserSyncSpec struct {
+
+	// A Secret in the namespace of the PostgresCluster with the connection
+	// details of the service provider. The "url" key is its base URL, the "token"
+	// key is a bearer token, and the optional "ca.crt" key holds the certificate
+	// authorities that verify its certificate.
+	// +required
+	Secret corev1.LocalObjectReference `json:"secret"`
+
+	// Groups whose direct members become PostgreSQL users. Each userName is
+	// lowercased, anything after "@" is removed, and "." and "_" become "-";
+	// names that are still not valid PostgreSQL user names in this spec are
+	// skipped. Distinct members that would become the same user are skipped,
+	// too.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=name
+	Groups []PostgresUserSyncGroup `json:"groups"`
+
+	// How often to read the groups, in minutes. Defaults to 15.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	IntervalMinutes *int32 `json:"intervalMinutes,omitempty"`
+}
+
+// PostgresUserSyncGroup maps a group in an identity provider to PostgreSQL access.
+type PostgresUserSyncGroup struct {
+
+	// The displayName of the group in the service provider.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Databases to which members of this group can connect and create objects.
+	// +listType=set
+	// +optional
+	Databases []PostgresIdentifier `json:"databases,omitempty"`
+
+	// Group roles of which members of this group are members. Memberships that
+	// are not listed in any group of a user are revoked.
+	// +listType=set
+	// +optional
+	MemberOf []PostgresIdentifier `json:"memberOf,omitempty"`
+}
+
+// PostgresUserSyncStatus reports the users added from an identity provider.
+type PostgresUserSyncStatus struct {
+
+	// When the groups were last read from the service provider.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// Users added to the spec, including those that have since left every group.
+	// +listType=set
+	// +optional
+	Users []string `json:"users,omitempty"`
+
+	// Synced users that have left every group, and when each one left. They
+	// are removed from the spec seven days later.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Disabled []PostgresUserSyncDisabledUser `json:"disabled,omitempty"`
+}
+
+// PostgresUserSyncDisabledUser is a synced user that is in no group.
+type PostgresUserSyncDisabledUser struct {
+
+	// The name of the PostgreSQL user.
+	// +required
+	Name string `json:"name"`
+
+	// When the user was first found in no group.
+	// +required
+	Since metav1.Time `json:"since"`
+}
+
+type PostgresDatabaseSpec struct {
+	// The name of this PostgreSQL database.
+	Name PostgresIdentifier `json:"name"`
+
+	// Extensions to create in this database. Extensions that are not allowed
+	// by the operator are ignored. Removing an extension from this list does
+	// NOT drop the extension.
+	// More info: https://www.postgresql.org/docs/current/sql-createextension.html
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Extensions []PostgresExtensionSpec `json:"extensions,omitempty"`
+
+	// Foreign servers to define in this database. Removing a server from this
+	// list does NOT drop the server nor its user mappings.
+	// More info: https://www.postgresql.org/docs/current/ddl-foreign-data.html
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	FDW []PostgresForeignServerSpec `json:"fdw,omitempty"`
+}
+
+type PostgresExtensionSpec struct {
+	// The name of this extension, such as "vector" for pgvector.
+	Name PostgresIdentifier `json:"name"`
+
+	// The shared library this extension needs loaded when PostgreSQL starts,
+	// if any. The library is loaded only after it is found in the PostgreSQL
+	// image. Changing this value causes PostgreSQL to restart.
+	// More info: https://www.postgresql.org/docs/current/runtime-config-client.html#GUC-SHARED-PRELOAD-LIBRARIES
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_-]+$`
+	// +optional
+	Preload string `json:"preload,omitempty"`
+}
+
+type PostgresForeignServerSpec struct {
+	// The name of this foreign server.
+	Name PostgresIdentifier `json:"name"`
+
+	// The foreign-data wrapper that connects to this server. Its extension is
+	// created in the database when it does not already exist.
+	// +kubebuilder:validation:Enum={postgres_fdw,oracle_fdw}
+	Wrapper string `json:"wrapper"`
+
+	// Options of this server, such as "host" and "dbname" for postgres_fdw or
+	// "dbserver" for oracle_fdw. Removing an option does NOT remove it from
+	// the server.
+	// More info: https://www.postgresql.org/docs/current/sql-createserver.html
+	// +optional
+	Options map[string]string `json:"options,omitempty"`
+
+	// Local users that connect to this server and the credentials they use.
+	// +listType=map
+	// +listMapKey=user
+	// +optional
+	UserMappings []PostgresUserMappingSpec `json:"userMappings,omitempty"`
+}
+
+type PostgresUserMappingSpec struct {
+	// The local PostgreSQL user of this mapping, or PUBLIC for every user.
+	// The user is granted USAGE on the foreign server.
+	User PostgresIdentifier `json:"user"`
+
+	// A Secret in the namespace of the PostgresCluster. Every key and value
+	// of this Secret is an option of the user mapping, such as "user" and
+	// "password". Values from the Secret take precedence over options.
+	// +optional
+	Secret *corev1.LocalObjectReference `json:"secret,omitempty"`
+
+	// Options of this user mapping that are not sensitive.
+	// More info: https://www.postgresql.org/docs/current/sql-createusermapping.html
+	// +optional
+	Options map[string]string `json:"options,omitempty"`
+}
+
+type PostgresReindexSpec struct {
+	// Times when PGO may rebuild the indexes of databases that use collations
+	// whose versions have changed. Indexes are rebuilt one database at a time
+	// and block writes to each table while they are rebuilt. When empty,
+	// indexes are not rebuilt automatically.
+	// +listType=atomic
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+}
+
+// MaintenanceWindow is a recurring period of time, in UTC, during which
+// disruptive maintenance can start.
+type MaintenanceWindow struct {
+	// Days of the week on which this window starts. Defaults to every day.
+	// +listType=set
+	// +optional
+	Days []Weekday `json:"days,omitempty"`
+
+	// The time of day, in UTC, at which this window starts. The format is
+	// 24-hour HH:MM, e.g. "02:30".
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+
+	// How long this window lasts, in minutes. Defaults to 60.
+	// +kubebuilder:default=60
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1440
+	// +optional
+	DurationMinutes int32 `json:"durationMinutes,omitempty"`
+}
+
+// +kubebuilder:validation:Enum={Sunday,Monday,Tuesday,Wednesday,Thursday,Friday,Saturday}
+type Weekday string
+
+type PostgresCollationStatus struct {
+	// The image, by digest when known, in which collation versions were last
+	// compared.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Databases that use collations whose versions have changed. Their
+	// indexes should be rebuilt.
+	// +listType=set
+	// +optional
+	Databases []string `json:"databases,omitempty"`
+}
+
+type PostgresReplicationSlotsSpec struct {
+	// Names of replication slots whose consumers are outside this cluster,
+	// such as standby clusters and logical replication subscribers. These
+	// slots are never considered orphaned.
+	// +listType=set
+	// +optional
+	Keep []string `json:"keep,omitempty"`
+
+	// How long, in minutes, a replication slot can have no consumer before it
+	// is considered orphaned. Defaults to 60.
+	// +kubebuilder:default=60
+	// +kubebuilder:validation:Minimum=5
+	// +optional
+	InactiveMinutes int32 `json:"inactiveMinutes,omitempty"`
+
+	// What to do with orphaned replication slots. "Report" only reports them.
+	// "Drop" drops them from the primary so that it stops keeping WAL for
+	// them. Defaults to "Report".
+	// +kubebuilder:default=Report
+	// +kubebuilder:validation:Enum={Report,Drop}
+	// +optional
+	OrphanPolicy string `json:"orphanPolicy,omitempty"`
+
+	// The most WAL that replication slots can keep on the primary. A slot that
+	// needs more is invalidated, and its consumer must be synchronized again.
+	// Sets max_slot_wal_keep_size in PostgreSQL 13 and later. Defaults to no
+	// limit.
+	// More info: https://www.postgresql.org/docs/current/runtime-config-replication.html#GUC-MAX-SLOT-WAL-KEEP-SIZE
+	// +optional
+	MaxWALKeepSize *resource.Quantity `json:"maxWALKeepSize,omitempty"`
+
+	// The percentage of maxWALKeepSize that a replication slot can keep before
+	// it is reported in the ReplicationSlotsNearLimit condition. Defaults to 80.
+	// +kubebuilder:default=80
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	WarningPercent int32 `json:"warningPercent,omitempty"`
+}
+
+type PostgresReplicationSlotsStatus struct {
+	// Replication slots that had no consumer when last checked, excluding
+	// the ones in spec.replicationSlots.keep.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Inactive []InactiveReplicationSlot `json:"inactive,omitempty"`
+}
+
+type InactiveReplicationSlot struct {
+	// The name of the replication slot.
+	Name string `json:"name"`
+
+	// When the replication slot was first seen without a consumer.
+	Since metav1.Time `json:"since"`
+}
+
+// PostgresBenchmarkSpec defines a pgbench run against the primary of a cluster.
+// More info: https://www.postgresql.org/docs/current/pgbench.html
+type PostgresBenchmarkSpec struct {
+	// The user that pgbench connects as. It must be defined in spec.users and
+	// be able to create tables in the database.
+	// +kubebuilder:validation:Required
+	User PostgresIdentifier `json:"user"`
+
+	// The database in which pgbench creates its tables. These are dropped
+	// after the benchmark. Defaults to the first database of the user.
+	// +optional
+	Database PostgresIdentifier `json:"database,omitempty"`
+
+	// The scale factor of the pgbench tables; each unit is about 15MiB.
+	// Defaults to 10.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Scale *int32 `json:"scale,omitempty"`
+
+	// The number of concurrent database sessions. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Clients *int32 `json:"clients,omitempty"`
+
+	// The number of pgbench threads. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Jobs *int32 `json:"jobs,omitempty"`
+
+	// How long to run transactions, in seconds. Defaults to 60.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	DurationSeconds *int32 `json:"durationSeconds,omitempty"`
+
+	// Resource requirements of the container that runs pgbench.
+	// More info: https://kubernetes.io/docs/concepts/configuration/manage-resources-containers/
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// PostgresBenchmarkStatus reports the results of a pgbench run.
+type PostgresBenchmarkStatus struct {
+
+	// The value of the "benchmark" annotation that started the run.
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// When the run started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// When the run finished successfully.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// The number of transactions processed.
+	// +optional
+	Transactions int64 `json:"transactions,omitempty"`
+
+	// Transactions processed per second, not counting the time to connect,
+	// e.g. "1234.567890".
+	// +optional
+	TransactionsPerSecond string `json:"transactionsPerSecond,omitempty"`
+
+	// The average time of each transaction, e.g. "3.141 ms".
+	// +optional
+	AverageLatency string `json:"averageLatency,omitempty"`
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -32,10 +33,19 @@ type PostgresClusterSpec struct {
 	// +optional
 	DataSource *DataSource `json:"dataSource,omitempty"`
 
+	// How PostgreSQL stores passwords and authenticates password connections.
+	// +optional
+	Authentication *PostgresAuthenticationSpec `json:"authentication,omitempty"`
+
 	// PostgreSQL backup configuration
 	// +kubebuilder:validation:Required
 	Backups Backups `json:"backups"`
 
+	// A pgbench benchmark to run against the primary. The benchmark starts
+	// when the "benchmark" annotation of the cluster changes.
+	// +optional
+	Benchmark *PostgresBenchmarkSpec `json:"benchmark,omitempty"`
+
 	// The secret containing the Certificates and Keys to encrypt PostgreSQL
 	// traffic will need to contain the server TLS certificate, TLS key and the
 	// Certificate Authority certificate with the data keys set to tls.crt,
@@ -62,6 +72,15 @@ type PostgresClusterSpec struct {
 	// namespace as the cluster.
 	// +optional
 	DatabaseInitSQL *DatabaseInitSQL `json:"databaseInitSQL,omitempty"`
+
+	// Databases to create inside PostgreSQL and objects to define in them.
+	// Removing a database from this list does NOT drop the database nor the
+	// objects in it.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Databases []PostgresDatabaseSpec `json:"databases,omitempty"`
+
 	// Whether or not the PostgreSQL cluster should use the defined default
 	// scheduling constraints. If the field is unset or false, the default
 	// scheduling constraints will be used in addition to any custom constraints
@@ -100,6 +119,21 @@ type PostgresClusterSpec struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,order=2
 	InstanceSets []PostgresInstanceSetSpec `json:"instances"`
 
+	// Specifies sets of PostgreSQL pods in other namespaces that replicate data
+	// from this cluster. Each set runs as a standby PostgresCluster in its own
+	// namespace that streams from the primary of this cluster. PGO must manage
+	// those namespaces.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	RemoteInstanceSets []PostgresRemoteInstanceSetSpec `json:"remoteInstances,omitempty"`
+
+	// Defines how long rotated PostgreSQL and pgBackRest log files are kept on
+	// the data volume of each instance. Log files are kept indefinitely when
+	// this is not set.
+	// +optional
+	LogRetention *PostgresLogRetentionSpec `json:"logRetention,omitempty"`
+
 	// Whether or not the PostgreSQL cluster is being deployed to an OpenShift
 	// environment. If the field is unset, the operator will automatically
 	// detect the environment.
@@ -136,6 +170,15 @@ type PostgresClusterSpec struct {
 	// +optional
 	Proxy *PostgresProxySpec `json:"proxy,omitempty"`
 
+	// Whether or not PostgreSQL should refuse writes, e.g. during maintenance
+	// or a migration cutover. This sets default_transaction_read_only, so
+	// sessions that turn it off can still write. PGO does not change objects
+	// inside PostgreSQL, such as users and databases, while this is true.
+	// Backups continue. The cluster is also read-only while it has the
+	// read-only annotation set to "true".
+	// +optional
+	ReadOnly *bool `json:"readOnly,omitempty"`
+
 	// The specification of a user interface that connects to PostgreSQL.
 	// +optional
 	UserInterface *UserInterfaceSpec `json:"userInterface,omitempty"`
@@ -148,6 +191,23 @@ type PostgresClusterSpec struct {
 	// +optional
 	Service *ServiceSpec `json:"service,omitempty"`
 
+	// Specification of the service that exposes PostgreSQL replica instances.
+	// +optional
+	ReplicaService *ServiceSpec `json:"replicaService,omitempty"`
+
+	// Whether and when to rebuild indexes after the versions of collations
+	// change, such as after an update to the operating system of the image.
+	// Changes are always reported in the ReindexRequired condition.
+	// +optional
+	Reindex *PostgresReindexSpec `json:"reindex,omitempty"`
+
+	// Which replication slots have consumers outside this cluster and what to
+	// do with slots that have had no consumer for some time. Such slots keep
+	// WAL on the primary and are always reported in the
+	// ReplicationSlotsOrphaned condition.
+	// +optional
+	ReplicationSlots *PostgresReplicationSlotsSpec `json:"replicationSlots,omitempty"`
+
 	// Whether or not the PostgreSQL cluster should be stopped.
 	// When this is true, workloads are scaled to zero and CronJobs
 	// are suspended.
@@ -174,6 +234,12 @@ type PostgresClusterSpec struct {
 	// +optional
 	Users []PostgresUserSpec `json:"users,omitempty"`
 
+	// Add users to the list above from the members of groups in an identity
+	// provider. The groups are read periodically, and members that leave every
+	// group can no longer log in.
+	// +optional
+	UserSync *PostgresUserSyncSpec `json:"userSync,omitempty"`
+
 	Config PostgresAdditionalConfig `json:"config,omitempty"`
 }
 
@@ -193,11 +259,26 @@ type DataSource struct {
 	// +optional
 	PostgresCluster *PostgresClusterDataSource `json:"postgresCluster,omitempty"`
 
+	// Defines a VolumeSnapshot of the PostgreSQL data volume of another cluster
+	// to restore into the first instance of this PostgresCluster. The snapshot
+	// must be in the namespace of this PostgresCluster and of the same major
+	// PostgreSQL version.
+	// +optional
+	VolumeSnapshot *DataSourceVolumeSnapshot `json:"volumeSnapshot,omitempty"`
+
 	// Defines any existing volumes to reuse for this PostgresCluster.
 	// +optional
 	Volumes *DataSourceVolumes `json:"volumes,omitempty"`
 }
 
+// DataSourceVolumeSnapshot defines an existing VolumeSnapshot of a PostgreSQL data volume.
+type DataSourceVolumeSnapshot struct {
+	// The name of an existing VolumeSnapshot.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
 // DataSourceVolumes defines any existing volumes to reuse for this PostgresCluster.
 type DataSourceVolumes struct {
 	// Defines the existing pgData volume and directory to use in the current
@@ -252,6 +333,7 @@ type PostgresClusterDataSource struct {
 
 	// The namespace of the cluster specified as the data source using the clusterName field.
 	// Defaults to the namespace of the PostgresCluster being created if not provided.
+	// A cluster in another namespace must list this namespace in its allow-data-source-namespaces annotation.
 	// +optional
 	ClusterNamespace string `json:"clusterNamespace,omitempty"`
 
@@ -267,6 +349,29 @@ type PostgresClusterDataSource struct {
 	// +optional
 	Options []string `json:"options,omitempty"`
 
+	// The names of the databases to restore. Other databases are restored as
+	// empty files that cannot be connected to and should be dropped. Only valid
+	// when initializing a new PostgresCluster; in-place restores must restore
+	// every database. Defaults to all databases.
+	// https://pgbackrest.org/command.html#command-restore/category-command/option-db-include
+	// +listType=set
+	// +optional
+	Databases []string `json:"databases,omitempty"`
+
+	// Whether the new PostgresCluster replaces the source cluster, e.g. to give it
+	// a different name or namespace. PostgreSQL users keep the passwords stored in
+	// the Secrets of the source cluster. Has no effect when restoring in-place.
+	// Defaults to false.
+	// +optional
+	Move *bool `json:"move,omitempty"`
+
+	// Whether to only check the restore. A Job confirms that the repository has
+	// the backup set and WAL that the restore needs and reports the result in the
+	// PGBackRestRestoreDryRun condition. The data directory is left untouched and
+	// a new PostgresCluster waits to bootstrap until this is false. Defaults to false.
+	// +optional
+	DryRun *bool `json:"dryRun,omitempty"`
+
 	// Resource requirements for the pgBackRest restore Job.
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
@@ -286,6 +391,31 @@ type PostgresClusterDataSource struct {
 	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/taint-and-toleration
 	// +optional
 	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// The number of times to retry a failed pgBackRest restore Job pod before
+	// marking the Job failed. Defaults to 6.
+	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/job/#pod-backoff-failure-policy
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// The number of seconds the pgBackRest restore Job may run, including
+	// retries, before it is stopped and marked failed.
+	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/job/#job-termination-and-cleanup
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// How pods of the pgBackRest restore Job that fail count toward its backoffLimit.
+	// +optional
+	PodFailurePolicy *JobPodFailurePolicy `json:"podFailurePolicy,omitempty"`
+
+	// Limit the lifetime of the pgBackRest restore Job after it has finished.
+	// A restore Job that failed and is removed this way is created again.
+	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/job
+	// +kubebuilder:validation:Minimum=60
+	// +optional
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
 }
 
 // Default defines several key default values for a Postgres cluster.
@@ -319,14 +449,104 @@ type Backups struct {
 	// pgBackRest archive configuration
 	// +kubebuilder:validation:Required
 	PGBackRest PGBackRestArchive `json:"pgbackrest"`
+
+	// CSI VolumeSnapshots of the PostgreSQL data volume. When set, a snapshot
+	// of the primary is taken every time the "volume-snapshot" annotation of
+	// the cluster changes.
+	// +optional
+	Snapshots *VolumeSnapshots `json:"snapshots,omitempty"`
+
+	// Scheduled logical backups of each database using pg_dump. These are
+	// in addition to the physical backups of pgBackRest and can restore
+	// individual tables into the running cluster.
+	// +optional
+	Logical *LogicalBackups `json:"logical,omitempty"`
+}
+
+// LogicalBackups defines scheduled pg_dump backups of the databases of a cluster.
+type LogicalBackups struct {
+	// The schedule of the dumps, in cron format.
+	// More info: https://k8s.io/docs/concepts/workloads/controllers/cron-jobs/#cron-schedule-syntax
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=6
+	Schedule string `json:"schedule"`
+
+	// The databases to dump. Defaults to every database that allows
+	// connections, except templates.
+	// +listType=set
+	// +optional
+	Databases []PostgresIdentifier `json:"databases,omitempty"`
+
+	// Defines the PersistentVolumeClaim where dumps are written. Dumps can be
+	// kept in object storage using a StorageClass whose CSI driver mounts a
+	// bucket.
+	// +kubebuilder:validation:Required
+	VolumeClaimSpec corev1.PersistentVolumeClaimSpec `json:"volumeClaimSpec"`
+
+	// The number of dumps to keep of each database. Older dumps are removed
+	// after each new dump succeeds. Defaults to 7.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Retention *int32 `json:"retention,omitempty"`
+
+	// Resource requirements of the containers that dump and restore.
+	// More info: https://kubernetes.io/docs/concepts/configuration/manage-resources-containers/
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// A dump to restore into the running cluster. The restore starts when
+	// the "logical-restore" annotation of the cluster changes.
+	// +optional
+	Restore *LogicalRestore `json:"restore,omitempty"`
+}
+
+// LogicalRestore defines a pg_restore of one dump into an existing database.
+type LogicalRestore struct {
+	// The database to restore into.
+	// +kubebuilder:validation:Required
+	Database PostgresIdentifier `json:"database"`
+
+	// The path of the dump on the volume of logical backups, e.g.
+	// "app/20230102T030405Z.dump". It cannot contain ".." directories.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^(\.?[^./][^/]*/|\.\.[^/]+/|\./)*[^/]*\.dump$`
+	File string `json:"file"`
+
+	// The tables to restore. Defaults to everything in the dump.
+	// +listType=set
+	// +optional
+	Tables []string `json:"tables,omitempty"`
+
+	// Additional command line options of pg_restore, e.g. "--clean".
+	// More info: https://www.postgresql.org/docs/current/app-pgrestore.html
+	// +optional
+	Options []string `json:"options,omitempty"`
+}
+
+// VolumeSnapshots defines how PostgreSQL data volumes are snapshotted.
+type VolumeSnapshots struct {
+	// The VolumeSnapshotClass of the snapshots. Its driver must be the CSI
+	// driver of the PostgreSQL data volumes.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName"`
 }
 
 // PostgresClusterStatus defines the observed state of PostgresCluster
 type PostgresClusterStatus struct {
 
+	// Databases that use collations whose versions have changed.
+	// +optional
+	Collation *PostgresCollationStatus `json:"collation,omitempty"`
+
 	// Identifies the databases that have been installed into PostgreSQL.
 	DatabaseRevision string `json:"databaseRevision,omitempty"`
 
+	// Identifies the foreign servers and user mappings that have been
+	// installed into PostgreSQL.
+	// +optional
+	ForeignServersRevision string `json:"foreignServersRevision,omitempty"`
+
 	// Current state of PostgreSQL instances.
 	// +listType=map
 	// +listMapKey=name
@@ -345,10 +565,26 @@ type PostgresClusterStatus struct {
 	// +optional
 	PostgresVersion int `json:"postgresVersion"`
 
+	// Shared libraries of requested extensions that were found in the
+	// PostgreSQL image and are loaded when PostgreSQL starts.
+	// +listType=set
+	// +optional
+	PreloadLibraries []string `json:"preloadLibraries,omitempty"`
+
 	// Current state of the PostgreSQL proxy.
 	// +optional
 	Proxy PostgresProxyStatus `json:"proxy,omitempty"`
 
+	// Namespaces in which standby PostgresClusters of remote instance sets
+	// have been written.
+	// +listType=set
+	// +optional
+	RemoteInstanceSetNamespaces []string `json:"remoteInstanceSetNamespaces,omitempty"`
+
+	// Replication slots that had no consumer when last checked.
+	// +optional
+	ReplicationSlots *PostgresReplicationSlotsStatus `json:"replicationSlots,omitempty"`
+
 	// The instance that should be started first when bootstrapping and/or starting a
 	// PostgresCluster.
 	// +optional
@@ -362,9 +598,26 @@ type PostgresClusterStatus struct {
 	// +optional
 	UserInterface *PostgresUserInterfaceStatus `json:"userInterface,omitempty"`
 
+	// Users added to the spec from an identity provider.
+	// +optional
+	UserSync *PostgresUserSyncStatus `json:"userSync,omitempty"`
+
 	// Identifies the users that have been installed into PostgreSQL.
 	UsersRevision string `json:"usersRevision,omitempty"`
 
+	// Identifies the user that logical backups connect as, once installed
+	// into PostgreSQL.
+	LogicalBackupRevision string `json:"logicalBackupRevision,omitempty"`
+
+	// The results of the most recent pgbench benchmark.
+	// +optional
+	Benchmark *PostgresBenchmarkStatus `json:"benchmark,omitempty"`
+
+	// VolumeSnapshots of the PostgreSQL data volume taken for this cluster
+	// that still exist, oldest first.
+	// +optional
+	VolumeSnapshots []VolumeSnapshotStatus `json:"volumeSnapshots,omitempty"`
+
 	// Current state of PostgreSQL cluster monitoring tool configuration
 	// +optional
 	Monitoring MonitoringStatus `json:"monitoring,omitempty"`
@@ -455,6 +708,16 @@ type PostgresInstanceSetSpec struct {
 	// +optional
 	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
 
+	// Whether or not PGO adds a temporary pod to this set while one of its
+	// pods is on a node that is cordoned, e.g. during a drain. Until then, the
+	// PodDisruptionBudget of the set blocks every eviction. Afterward, it allows
+	// one only when the temporary pod has caught up to the primary. PGO removes
+	// the temporary pod once the other pods are running on schedulable nodes.
+	// Applies when replicas is 2 and minAvailable is unset. PGO must be allowed
+	// to get nodes.
+	// +optional
+	DisruptionSurge *bool `json:"disruptionSurge,omitempty"`
+
 	// Compute resources of a PostgreSQL container.
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
@@ -468,6 +731,15 @@ type PostgresInstanceSetSpec struct {
 	// +optional
 	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
 
+	// The size limit of the "tmp" volume of a PostgreSQL pod. This volume holds
+	// temporary files, such as those of nss_wrapper, and counts toward the
+	// ephemeral storage of the pod. When set, the database container requests
+	// this much ephemeral storage unless resources set ephemeral-storage.
+	// Defaults to 16Mi. Changing this value causes PostgreSQL to restart.
+	// More info: https://kubernetes.io/docs/concepts/storage/volumes/#emptydir
+	// +optional
+	TmpVolumeSizeLimit *resource.Quantity `json:"tmpVolumeSizeLimit,omitempty"`
+
 	// Topology spread constraints of a PostgreSQL pod. Changing this value causes
 	// PostgreSQL to restart.
 	// More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-topology-spread-constraints/
@@ -511,6 +783,10 @@ type TablespaceVolume struct {
 
 // InstanceSidecars defines the configuration for instance sidecar containers
 type InstanceSidecars struct {
+	// Defines the configuration for the log cleanup sidecar container
+	// +optional
+	LogCleanup *Sidecar `json:"logCleanup,omitempty"`
+
 	// Defines the configuration for the replica cert copy sidecar container
 	// +optional
 	ReplicaCertCopy *Sidecar `json:"replicaCertCopy,omitempty"`
@@ -544,6 +820,36 @@ type PostgresInstanceSetStatus struct {
 	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
 }
 
+// VolumeSnapshotStatus describes a VolumeSnapshot of a PostgreSQL data volume.
+type VolumeSnapshotStatus struct {
+	// The name of the VolumeSnapshot.
+	Name string `json:"name"`
+
+	// The value of the "volume-snapshot" annotation that requested the snapshot.
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// The instance whose data volume was snapshotted.
+	// +optional
+	Instance string `json:"instance,omitempty"`
+
+	// When the storage system took the snapshot.
+	// +optional
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+
+	// Whether or not the snapshot can be used to restore a new cluster.
+	// +optional
+	ReadyToUse bool `json:"readyToUse,omitempty"`
+
+	// The identifier of the snapshot in the storage system.
+	// +optional
+	SnapshotHandle string `json:"snapshotHandle,omitempty"`
+
+	// The name of the VolumeSnapshotContent bound to the VolumeSnapshot.
+	// +optional
+	VolumeSnapshotContentName string `json:"volumeSnapshotContentName,omitempty"`
+}
+
 // PostgresProxySpec is a union of the supported PostgreSQL proxies.
 type PostgresProxySpec struct {
 
@@ -562,6 +868,45 @@ type PostgresProxyStatus struct {
 	PGBouncer PGBouncerPodStatus `json:"pgBouncer,omitempty"`
 }
 
+// PostgresRemoteInstanceSetSpec defines a set of PostgreSQL pods in another
+// namespace that stream from the primary of a cluster. The pods copy the
+// settings of an instance set of the cluster.
+type PostgresRemoteInstanceSetSpec struct {
+	// +optional
+	Metadata *Metadata `json:"metadata,omitempty"`
+
+	// Name that associates this set of PostgreSQL pods. It goes into the name
+	// of the standby PostgresCluster along with the name of the cluster. Each
+	// remote instance set must have a unique name.
+	// +optional
+	// +kubebuilder:default=""
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?)?$`
+	Name string `json:"name"`
+
+	// The namespace in which to run these PostgreSQL pods. It must be different
+	// than the namespace of the cluster.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+
+	// The name of the instance set in spec.instances whose pod settings and
+	// volume claims these pods copy. Defaults to the first instance set.
+	// +optional
+	InstanceSet string `json:"instanceSet,omitempty"`
+
+	// Number of desired PostgreSQL pods.
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Defines a PersistentVolumeClaim for the pgBackRest repository of these
+	// pods. Their backups and WAL archive are separate from those of the cluster.
+	// More info: https://kubernetes.io/docs/concepts/storage/persistent-volumes/#persistentvolumeclaims
+	// +kubebuilder:validation:Required
+	RepoVolumeClaimSpec corev1.PersistentVolumeClaimSpec `json:"repoVolumeClaimSpec"`
+}
+
 // PostgresStandbySpec defines if/how the cluster should be a hot standby.
 type PostgresStandbySpec struct {
 	// Whether or not the PostgreSQL cluster should be read-only. When this is
@@ -612,6 +957,61 @@ type PostgresAdditionalConfig struct {
 	Files []corev1.VolumeProjection `json:"files,omitempty"`
 }
 
+// PostgresLogRetentionSpec defines when log files in the PostgreSQL and
+// pgBackRest log directories of an instance are deleted. The most recent log
+// file in each directory is never deleted.
+type PostgresLogRetentionSpec struct {
+	// Log files that have not been written for this many hours are deleted.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxAgeHours *int32 `json:"maxAgeHours,omitempty"`
+
+	// The oldest log files are deleted while the total size of a log directory
+	// is larger than this.
+	// +optional
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+}
+
+// PostgresAuthenticationSpec defines how PostgreSQL stores passwords and
+// authenticates connections that use them.
+type PostgresAuthenticationSpec struct {
+	// The format of passwords stored by PostgreSQL and of the verifiers that
+	// PGO generates for users and monitoring. Passwords that PGO has already
+	// stored are replaced when they are in a different format.
+	// Defaults to "scram-sha-256".
+	// More info: https://www.postgresql.org/docs/current/auth-password.html
+	// +kubebuilder:validation:Enum={md5,scram-sha-256}
+	// +optional
+	PasswordEncryption string `json:"passwordEncryption,omitempty"`
+
+	// When "require", password connections over TLS must authenticate using
+	// SCRAM-SHA-256 so that clients can require channel binding with the libpq
+	// setting "channel_binding=require". Passwords stored as MD5 cannot be
+	// used. Rules in spec.patroni.dynamicConfiguration take precedence.
+	// Requires passwordEncryption to be "scram-sha-256". Defaults to "prefer".
+	// More info: https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNECT-CHANNEL-BINDING
+	// +kubebuilder:validation:Enum={prefer,require}
+	// +optional
+	ChannelBinding string `json:"channelBinding,omitempty"`
+
+	// The number of PBKDF2 iterations in the SCRAM-SHA-256 verifiers that PGO
+	// generates. Verifiers that PGO has already stored are kept until their
+	// password changes. Defaults to 4096, the PostgreSQL default.
+	// More info: https://www.postgresql.org/docs/current/sasl-authentication.html#SASL-SCRAM-SHA-256
+	// +kubebuilder:validation:Minimum=4096
+	// +optional
+	SCRAMIterations *int32 `json:"scramIterations,omitempty"`
+
+	// When "reject", password connections to databases that are not named in
+	// spec.databases or spec.users are rejected. Connections that PGO makes
+	// are not affected. Rules in spec.patroni.dynamicConfiguration take
+	// precedence. Defaults to "allow".
+	// More info: https://www.postgresql.org/docs/current/auth-pg-hba-conf.html
+	// +kubebuilder:validation:Enum={allow,reject}
+	// +optional
+	UndeclaredDatabases string `json:"undeclaredDatabases,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +operator-sdk:csv:customresourcedefinitions:resources={{ConfigMap,v1},{Secret,v1},{Service,v1},{CronJob,v1beta1},{Deployment,v1},{Job,v1},{StatefulSet,v1},{PersistentVolumeClaim,v1}}
@@ -669,6 +1069,11 @@ type MonitoringSpec struct {
 type MonitoringStatus struct {
 	// +optional
 	ExporterConfiguration string `json:"exporterConfiguration,omitempty"`
+
+	// The extension that collects statement statistics for the exporter. This is
+	// "pg_stat_monitor" only after its library is found in the PostgreSQL image.
+	// +optional
+	StatementStatistics string `json:"statementStatistics,omitempty"`
 }
 
 // PGMonitorSpec defines the desired state of the pgMonitor tool suite
@@ -701,6 +1106,15 @@ type ExporterSpec struct {
 	// More info: https://kubernetes.io/docs/concepts/configuration/manage-resources-containers
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// The extension that collects statement statistics. PostgreSQL continues to use
+	// pg_stat_statements until the pg_stat_monitor library is found in its image.
+	// Changing this value requires PostgreSQL to restart.
+	// More info: https://github.com/percona/pg_stat_monitor
+	// +kubebuilder:validation:Enum={pg_stat_statements,pg_stat_monitor}
+	// +kubebuilder:default=pg_stat_statements
+	// +optional
+	StatementStatistics string `json:"statementStatistics,omitempty"`
 }
 
 func NewPostgresCluster() *PostgresCluster {
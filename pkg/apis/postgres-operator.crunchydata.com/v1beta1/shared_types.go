@@ -55,6 +55,26 @@ type ServiceSpec struct {
 	// +kubebuilder:default=ClusterIP
 	// +kubebuilder:validation:Enum={ClusterIP,NodePort,LoadBalancer}
 	Type string `json:"type"`
+
+	// Whether connections from the same client IP are sent to the same Pod.
+	// Defaults to None.
+	// More info: https://kubernetes.io/docs/concepts/services-networking/service/#virtual-ips-and-service-proxies
+	// +optional
+	// +kubebuilder:validation:Enum={None,ClientIP}
+	SessionAffinity *corev1.ServiceAffinity `json:"sessionAffinity,omitempty"`
+
+	// NOTE: There is no field for the PROXY protocol. PostgreSQL and
+	// PgBouncer cannot read its header, so a load balancer that sends one breaks
+	// every connection. Use ExternalTrafficPolicy to preserve client addresses.
+
+	// Whether traffic from outside the Kubernetes cluster is routed only to
+	// Pods on the node that received it. "Local" preserves the client IP
+	// address seen by PostgreSQL, e.g. for pg_hba rules and logging. Applies
+	// only when type is NodePort or LoadBalancer. Defaults to Cluster.
+	// More info: https://kubernetes.io/docs/tasks/access-application-cluster/create-external-load-balancer/#preserving-the-client-source-ip
+	// +optional
+	// +kubebuilder:validation:Enum={Cluster,Local}
+	ExternalTrafficPolicy *corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
 }
 
 // Sidecar defines the configuration of a sidecar container
@@ -64,6 +84,17 @@ type Sidecar struct {
 	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
 }
 
+// JobPodFailurePolicy describes how the pods of a Job that fail count toward
+// its backoffLimit. It requires Kubernetes 1.26 or later.
+// More info: https://kubernetes.io/docs/concepts/workloads/controllers/job/#pod-failure-policy
+type JobPodFailurePolicy struct {
+	// Whether or not pods that fail because they were disrupted, e.g. evicted
+	// from a node being drained or preempted, count toward the backoffLimit of
+	// the Job. When true, those pods are replaced without counting.
+	// +optional
+	IgnoreDisruptions bool `json:"ignoreDisruptions,omitempty"`
+}
+
 // Metadata contains metadata for custom resources
 type Metadata struct {
 	// +optional
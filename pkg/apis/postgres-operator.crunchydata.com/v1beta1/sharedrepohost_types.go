@@ -0,0 +1,124 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SharedRepoHostSpec defines the desired state of SharedRepoHost
+type SharedRepoHostSpec struct {
+
+	// +optional
+	Metadata *Metadata `json:"metadata,omitempty"`
+
+	// The image name to use for the pgBackRest repository host. When omitted,
+	// the value comes from an operator environment variable.
+	// +optional
+	Image *string `json:"image,omitempty"`
+
+	// ImagePullPolicy is used to determine when Kubernetes will attempt to
+	// pull (download) container images.
+	// More info: https://kubernetes.io/docs/concepts/containers/images/#image-pull-policy
+	// +kubebuilder:validation:Enum={Always,Never,IfNotPresent}
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// The image pull secrets used to pull from a private registry.
+	// Changing this value causes the repository host to restart.
+	// https://k8s.io/docs/tasks/configure-pod-container/pull-image-private-registry/
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Defines a PersistentVolumeClaim spec used to create and/or bind the volume
+	// that stores the stanzas of every cluster using this repository host
+	// +kubebuilder:validation:Required
+	VolumeClaimSpec corev1.PersistentVolumeClaimSpec `json:"volumeClaimSpec"`
+
+	// Resource requirements for the pgBackRest repository host
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Scheduling constraints of the repository host pod.
+	// Changing this value causes the repository host to restart.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/assign-pod-node
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// Priority class name for the repository host pod. Changing this value
+	// causes the repository host to restart.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/pod-priority-preemption/
+	// +optional
+	PriorityClassName *string `json:"priorityClassName,omitempty"`
+
+	// Tolerations of the repository host pod. Changing this value causes a restart.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/taint-and-toleration
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Topology spread constraints of the repository host pod. Changing this
+	// value causes the repository host to restart.
+	// More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-topology-spread-constraints/
+	// +optional
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+}
+
+// SharedRepoHostStatus defines the observed state of SharedRepoHost
+type SharedRepoHostStatus struct {
+	// conditions represent the observations of SharedRepoHost's current state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// observedGeneration represents the .metadata.generation on which the status was based.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// The pgBackRest stanzas configured on the repository host, one for each
+	// PostgresCluster that has a repository on it.
+	// +optional
+	Stanzas []string `json:"stanzas,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// SharedRepoHost is the Schema for the sharedrepohosts API. It is a pgBackRest
+// repository host that stores the backups and WAL of multiple PostgresClusters
+// in the same namespace, each in its own stanza.
+type SharedRepoHost struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SharedRepoHostSpec   `json:"spec,omitempty"`
+	Status SharedRepoHostStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SharedRepoHostList contains a list of SharedRepoHost
+type SharedRepoHostList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SharedRepoHost `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SharedRepoHost{}, &SharedRepoHostList{})
+}
@@ -48,11 +48,33 @@ func (in *BackupJobs) DeepCopyInto(out *BackupJobs) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.TTLSecondsAfterFinished != nil {
 		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
 		*out = new(int32)
 		**out = **in
 	}
+	if in.BackoffLimit != nil {
+		in, out := &in.BackoffLimit, &out.BackoffLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.PodFailurePolicy != nil {
+		in, out := &in.PodFailurePolicy, &out.PodFailurePolicy
+		*out = new(JobPodFailurePolicy)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupJobs.
@@ -69,6 +91,16 @@ func (in *BackupJobs) DeepCopy() *BackupJobs {
 func (in *Backups) DeepCopyInto(out *Backups) {
 	*out = *in
 	in.PGBackRest.DeepCopyInto(&out.PGBackRest)
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = new(VolumeSnapshots)
+		**out = **in
+	}
+	if in.Logical != nil {
+		in, out := &in.Logical, &out.Logical
+		*out = new(LogicalBackups)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Backups.
@@ -94,6 +126,11 @@ func (in *DataSource) DeepCopyInto(out *DataSource) {
 		*out = new(PostgresClusterDataSource)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.VolumeSnapshot != nil {
+		in, out := &in.VolumeSnapshot, &out.VolumeSnapshot
+		*out = new(DataSourceVolumeSnapshot)
+		**out = **in
+	}
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
 		*out = new(DataSourceVolumes)
@@ -126,6 +163,21 @@ func (in *DataSourceVolume) DeepCopy() *DataSourceVolume {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataSourceVolumeSnapshot) DeepCopyInto(out *DataSourceVolumeSnapshot) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataSourceVolumeSnapshot.
+func (in *DataSourceVolumeSnapshot) DeepCopy() *DataSourceVolumeSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(DataSourceVolumeSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DataSourceVolumes) DeepCopyInto(out *DataSourceVolumes) {
 	*out = *in
@@ -199,9 +251,30 @@ func (in *ExporterSpec) DeepCopy() *ExporterSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InactiveReplicationSlot) DeepCopyInto(out *InactiveReplicationSlot) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InactiveReplicationSlot.
+func (in *InactiveReplicationSlot) DeepCopy() *InactiveReplicationSlot {
+	if in == nil {
+		return nil
+	}
+	out := new(InactiveReplicationSlot)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InstanceSidecars) DeepCopyInto(out *InstanceSidecars) {
 	*out = *in
+	if in.LogCleanup != nil {
+		in, out := &in.LogCleanup, &out.LogCleanup
+		*out = new(Sidecar)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ReplicaCertCopy != nil {
 		in, out := &in.ReplicaCertCopy, &out.ReplicaCertCopy
 		*out = new(Sidecar)
@@ -219,6 +292,98 @@ func (in *InstanceSidecars) DeepCopy() *InstanceSidecars {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]Weekday, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobPodFailurePolicy) DeepCopyInto(out *JobPodFailurePolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobPodFailurePolicy.
+func (in *JobPodFailurePolicy) DeepCopy() *JobPodFailurePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(JobPodFailurePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LogicalBackups) DeepCopyInto(out *LogicalBackups) {
+	*out = *in
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]PostgresIdentifier, len(*in))
+		copy(*out, *in)
+	}
+	in.VolumeClaimSpec.DeepCopyInto(&out.VolumeClaimSpec)
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(int32)
+		**out = **in
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Restore != nil {
+		in, out := &in.Restore, &out.Restore
+		*out = new(LogicalRestore)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LogicalBackups.
+func (in *LogicalBackups) DeepCopy() *LogicalBackups {
+	if in == nil {
+		return nil
+	}
+	out := new(LogicalBackups)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LogicalRestore) DeepCopyInto(out *LogicalRestore) {
+	*out = *in
+	if in.Tables != nil {
+		in, out := &in.Tables, &out.Tables
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LogicalRestore.
+func (in *LogicalRestore) DeepCopy() *LogicalRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(LogicalRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Metadata) DeepCopyInto(out *Metadata) {
 	*out = *in
@@ -398,6 +563,11 @@ func (in *PGBackRestArchive) DeepCopyInto(out *PGBackRestArchive) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ConfigInclude != nil {
+		in, out := &in.ConfigInclude, &out.ConfigInclude
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
 	if in.Global != nil {
 		in, out := &in.Global, &out.Global
 		*out = make(map[string]string, len(*in))
@@ -405,6 +575,16 @@ func (in *PGBackRestArchive) DeepCopyInto(out *PGBackRestArchive) {
 			(*out)[key] = val
 		}
 	}
+	if in.IO != nil {
+		in, out := &in.IO, &out.IO
+		*out = new(PGBackRestIO)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobHistoryLimit != nil {
+		in, out := &in.JobHistoryLimit, &out.JobHistoryLimit
+		*out = new(PGBackRestJobHistoryLimit)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Jobs != nil {
 		in, out := &in.Jobs, &out.Jobs
 		*out = new(BackupJobs)
@@ -427,6 +607,26 @@ func (in *PGBackRestArchive) DeepCopyInto(out *PGBackRestArchive) {
 		*out = new(PGBackRestManualBackup)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ExpireDryRun != nil {
+		in, out := &in.ExpireDryRun, &out.ExpireDryRun
+		*out = new(PGBackRestExpireDryRun)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ArchivePush != nil {
+		in, out := &in.ArchivePush, &out.ArchivePush
+		*out = new(PGBackRestArchivePush)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AsyncArchive != nil {
+		in, out := &in.AsyncArchive, &out.AsyncArchive
+		*out = new(PGBackRestAsyncArchive)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PauseArchiving != nil {
+		in, out := &in.PauseArchiving, &out.PauseArchiving
+		*out = new(bool)
+		**out = **in
+	}
 	if in.Restore != nil {
 		in, out := &in.Restore, &out.Restore
 		*out = new(PGBackRestRestore)
@@ -437,6 +637,16 @@ func (in *PGBackRestArchive) DeepCopyInto(out *PGBackRestArchive) {
 		*out = new(PGBackRestSidecars)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.SuspendSchedules != nil {
+		in, out := &in.SuspendSchedules, &out.SuspendSchedules
+		*out = new(bool)
+		**out = **in
+	}
+	if in.FullBackupHooks != nil {
+		in, out := &in.FullBackupHooks, &out.FullBackupHooks
+		*out = new(PGBackRestBackupHooks)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestArchive.
@@ -449,6 +659,151 @@ func (in *PGBackRestArchive) DeepCopy() *PGBackRestArchive {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestArchivePush) DeepCopyInto(out *PGBackRestArchivePush) {
+	*out = *in
+	if in.AlertAfterMinutes != nil {
+		in, out := &in.AlertAfterMinutes, &out.AlertAfterMinutes
+		*out = new(int32)
+		**out = **in
+	}
+	if in.QueueMax != nil {
+		in, out := &in.QueueMax, &out.QueueMax
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Retries != nil {
+		in, out := &in.Retries, &out.Retries
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestArchivePush.
+func (in *PGBackRestArchivePush) DeepCopy() *PGBackRestArchivePush {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestArchivePush)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestAsyncArchive) DeepCopyInto(out *PGBackRestAsyncArchive) {
+	*out = *in
+	if in.PushQueueMax != nil {
+		in, out := &in.PushQueueMax, &out.PushQueueMax
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.SpoolVolumeClaimSpec != nil {
+		in, out := &in.SpoolVolumeClaimSpec, &out.SpoolVolumeClaimSpec
+		*out = new(v1.PersistentVolumeClaimSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestAsyncArchive.
+func (in *PGBackRestAsyncArchive) DeepCopy() *PGBackRestAsyncArchive {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestAsyncArchive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestBackupHook) DeepCopyInto(out *PGBackRestBackupHook) {
+	*out = *in
+	if in.URL != nil {
+		in, out := &in.URL, &out.URL
+		*out = new(string)
+		**out = **in
+	}
+	if in.SQL != nil {
+		in, out := &in.SQL, &out.SQL
+		*out = new(string)
+		**out = **in
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestBackupHook.
+func (in *PGBackRestBackupHook) DeepCopy() *PGBackRestBackupHook {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestBackupHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestBackupHooks) DeepCopyInto(out *PGBackRestBackupHooks) {
+	*out = *in
+	if in.Before != nil {
+		in, out := &in.Before, &out.Before
+		*out = new(PGBackRestBackupHook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.After != nil {
+		in, out := &in.After, &out.After
+		*out = new(PGBackRestBackupHook)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestBackupHooks.
+func (in *PGBackRestBackupHooks) DeepCopy() *PGBackRestBackupHooks {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestBackupHooks)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestBackupOptions) DeepCopyInto(out *PGBackRestBackupOptions) {
+	*out = *in
+	if in.CompressLevel != nil {
+		in, out := &in.CompressLevel, &out.CompressLevel
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ProcessMax != nil {
+		in, out := &in.ProcessMax, &out.ProcessMax
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestBackupOptions.
+func (in *PGBackRestBackupOptions) DeepCopy() *PGBackRestBackupOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestBackupOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestBackupSchedules) DeepCopyInto(out *PGBackRestBackupSchedules) {
 	*out = *in
@@ -467,6 +822,11 @@ func (in *PGBackRestBackupSchedules) DeepCopyInto(out *PGBackRestBackupSchedules
 		*out = new(string)
 		**out = **in
 	}
+	if in.Suspend != nil {
+		in, out := &in.Suspend, &out.Suspend
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestBackupSchedules.
@@ -497,6 +857,11 @@ func (in *PGBackRestDataSource) DeepCopyInto(out *PGBackRestDataSource) {
 		}
 	}
 	in.Repo.DeepCopyInto(&out.Repo)
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(bool)
+		**out = **in
+	}
 	if in.Options != nil {
 		in, out := &in.Options, &out.Options
 		*out = make([]string, len(*in))
@@ -520,6 +885,26 @@ func (in *PGBackRestDataSource) DeepCopyInto(out *PGBackRestDataSource) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.BackoffLimit != nil {
+		in, out := &in.BackoffLimit, &out.BackoffLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.PodFailurePolicy != nil {
+		in, out := &in.PodFailurePolicy, &out.PodFailurePolicy
+		*out = new(JobPodFailurePolicy)
+		**out = **in
+	}
+	if in.TTLSecondsAfterFinished != nil {
+		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestDataSource.
@@ -533,15 +918,141 @@ func (in *PGBackRestDataSource) DeepCopy() *PGBackRestDataSource {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PGBackRestJobStatus) DeepCopyInto(out *PGBackRestJobStatus) {
+func (in *PGBackRestExpireDryRun) DeepCopyInto(out *PGBackRestExpireDryRun) {
 	*out = *in
-	if in.StartTime != nil {
-		in, out := &in.StartTime, &out.StartTime
-		*out = (*in).DeepCopy()
-	}
-	if in.CompletionTime != nil {
-		in, out := &in.CompletionTime, &out.CompletionTime
-		*out = (*in).DeepCopy()
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestExpireDryRun.
+func (in *PGBackRestExpireDryRun) DeepCopy() *PGBackRestExpireDryRun {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestExpireDryRun)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestExpireDryRunRepoStatus) DeepCopyInto(out *PGBackRestExpireDryRunRepoStatus) {
+	*out = *in
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Archive != nil {
+		in, out := &in.Archive, &out.Archive
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestExpireDryRunRepoStatus.
+func (in *PGBackRestExpireDryRunRepoStatus) DeepCopy() *PGBackRestExpireDryRunRepoStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestExpireDryRunRepoStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestExpireDryRunStatus) DeepCopyInto(out *PGBackRestExpireDryRunStatus) {
+	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Repos != nil {
+		in, out := &in.Repos, &out.Repos
+		*out = make([]PGBackRestExpireDryRunRepoStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestExpireDryRunStatus.
+func (in *PGBackRestExpireDryRunStatus) DeepCopy() *PGBackRestExpireDryRunStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestExpireDryRunStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestIO) DeepCopyInto(out *PGBackRestIO) {
+	*out = *in
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.BufferSize != nil {
+		in, out := &in.BufferSize, &out.BufferSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestIO.
+func (in *PGBackRestIO) DeepCopy() *PGBackRestIO {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestIO)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestJobHistoryLimit) DeepCopyInto(out *PGBackRestJobHistoryLimit) {
+	*out = *in
+	if in.Successful != nil {
+		in, out := &in.Successful, &out.Successful
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Failed != nil {
+		in, out := &in.Failed, &out.Failed
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestJobHistoryLimit.
+func (in *PGBackRestJobHistoryLimit) DeepCopy() *PGBackRestJobHistoryLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestJobHistoryLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestJobStatus) DeepCopyInto(out *PGBackRestJobStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Progress != nil {
+		in, out := &in.Progress, &out.Progress
+		*out = new(PGBackRestRestoreProgress)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
@@ -555,6 +1066,29 @@ func (in *PGBackRestJobStatus) DeepCopy() *PGBackRestJobStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestLastBackupStatus) DeepCopyInto(out *PGBackRestLastBackupStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.FinishTime != nil {
+		in, out := &in.FinishTime, &out.FinishTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestLastBackupStatus.
+func (in *PGBackRestLastBackupStatus) DeepCopy() *PGBackRestLastBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestLastBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestManualBackup) DeepCopyInto(out *PGBackRestManualBackup) {
 	*out = *in
@@ -578,11 +1112,61 @@ func (in *PGBackRestManualBackup) DeepCopy() *PGBackRestManualBackup {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestRepo) DeepCopyInto(out *PGBackRestRepo) {
 	*out = *in
+	if in.AdoptExisting != nil {
+		in, out := &in.AdoptExisting, &out.AdoptExisting
+		*out = new(bool)
+		**out = **in
+	}
 	if in.BackupSchedules != nil {
 		in, out := &in.BackupSchedules, &out.BackupSchedules
 		*out = new(PGBackRestBackupSchedules)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.VerifySchedule != nil {
+		in, out := &in.VerifySchedule, &out.VerifySchedule
+		*out = new(string)
+		**out = **in
+	}
+	if in.ExpireSchedule != nil {
+		in, out := &in.ExpireSchedule, &out.ExpireSchedule
+		*out = new(string)
+		**out = **in
+	}
+	if in.Sync != nil {
+		in, out := &in.Sync, &out.Sync
+		*out = new(PGBackRestRepoSync)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BackupOptions != nil {
+		in, out := &in.BackupOptions, &out.BackupOptions
+		*out = new(PGBackRestRepoBackupOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RetentionFull != nil {
+		in, out := &in.RetentionFull, &out.RetentionFull
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RetentionDiff != nil {
+		in, out := &in.RetentionDiff, &out.RetentionDiff
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RetentionArchive != nil {
+		in, out := &in.RetentionArchive, &out.RetentionArchive
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RetentionDays != nil {
+		in, out := &in.RetentionDays, &out.RetentionDays
+		*out = new(int32)
+		**out = **in
+	}
+	if in.UploadChunkSize != nil {
+		in, out := &in.UploadChunkSize, &out.UploadChunkSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
 	if in.Azure != nil {
 		in, out := &in.Azure, &out.Azure
 		*out = new(RepoAzure)
@@ -596,13 +1180,18 @@ func (in *PGBackRestRepo) DeepCopyInto(out *PGBackRestRepo) {
 	if in.S3 != nil {
 		in, out := &in.S3, &out.S3
 		*out = new(RepoS3)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Volume != nil {
 		in, out := &in.Volume, &out.Volume
 		*out = new(RepoPVC)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.SharedHost != nil {
+		in, out := &in.SharedHost, &out.SharedHost
+		*out = new(RepoSharedHost)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestRepo.
@@ -615,6 +1204,37 @@ func (in *PGBackRestRepo) DeepCopy() *PGBackRestRepo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestRepoBackupOptions) DeepCopyInto(out *PGBackRestRepoBackupOptions) {
+	*out = *in
+	in.PGBackRestBackupOptions.DeepCopyInto(&out.PGBackRestBackupOptions)
+	if in.Full != nil {
+		in, out := &in.Full, &out.Full
+		*out = new(PGBackRestBackupOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Differential != nil {
+		in, out := &in.Differential, &out.Differential
+		*out = new(PGBackRestBackupOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Incremental != nil {
+		in, out := &in.Incremental, &out.Incremental
+		*out = new(PGBackRestBackupOptions)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestRepoBackupOptions.
+func (in *PGBackRestRepoBackupOptions) DeepCopy() *PGBackRestRepoBackupOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestRepoBackupOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestRepoHost) DeepCopyInto(out *PGBackRestRepoHost) {
 	*out = *in
@@ -623,11 +1243,28 @@ func (in *PGBackRestRepoHost) DeepCopyInto(out *PGBackRestRepoHost) {
 		*out = new(v1.Affinity)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.CertificateDurationDays != nil {
+		in, out := &in.CertificateDurationDays, &out.CertificateDurationDays
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.PriorityClassName != nil {
 		in, out := &in.PriorityClassName, &out.PriorityClassName
 		*out = new(string)
 		**out = **in
 	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
 	in.Resources.DeepCopyInto(&out.Resources)
 	if in.Tolerations != nil {
 		in, out := &in.Tolerations, &out.Tolerations
@@ -665,6 +1302,28 @@ func (in *PGBackRestRepoHost) DeepCopy() *PGBackRestRepoHost {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestRepoSync) DeepCopyInto(out *PGBackRestRepoSync) {
+	*out = *in
+	out.S3 = in.S3
+	if in.CredentialsSecret != nil {
+		in, out := &in.CredentialsSecret, &out.CredentialsSecret
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestRepoSync.
+func (in *PGBackRestRepoSync) DeepCopy() *PGBackRestRepoSync {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestRepoSync)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestRestore) DeepCopyInto(out *PGBackRestRestore) {
 	*out = *in
@@ -673,6 +1332,11 @@ func (in *PGBackRestRestore) DeepCopyInto(out *PGBackRestRestore) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.Delta != nil {
+		in, out := &in.Delta, &out.Delta
+		*out = new(bool)
+		**out = **in
+	}
 	if in.PostgresClusterDataSource != nil {
 		in, out := &in.PostgresClusterDataSource, &out.PostgresClusterDataSource
 		*out = new(PostgresClusterDataSource)
@@ -690,6 +1354,29 @@ func (in *PGBackRestRestore) DeepCopy() *PGBackRestRestore {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestRestoreProgress) DeepCopyInto(out *PGBackRestRestoreProgress) {
+	*out = *in
+	if in.EstimatedCompletionTime != nil {
+		in, out := &in.EstimatedCompletionTime, &out.EstimatedCompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastObservedTime != nil {
+		in, out := &in.LastObservedTime, &out.LastObservedTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestRestoreProgress.
+func (in *PGBackRestRestoreProgress) DeepCopy() *PGBackRestRestoreProgress {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestRestoreProgress)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestScheduledBackupStatus) DeepCopyInto(out *PGBackRestScheduledBackupStatus) {
 	*out = *in
@@ -753,6 +1440,16 @@ func (in *PGBackRestStatus) DeepCopyInto(out *PGBackRestStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RunningBackups != nil {
+		in, out := &in.RunningBackups, &out.RunningBackups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastBackup != nil {
+		in, out := &in.LastBackup, &out.LastBackup
+		*out = new(PGBackRestLastBackupStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.RepoHost != nil {
 		in, out := &in.RepoHost, &out.RepoHost
 		*out = new(RepoHostStatus)
@@ -761,13 +1458,20 @@ func (in *PGBackRestStatus) DeepCopyInto(out *PGBackRestStatus) {
 	if in.Repos != nil {
 		in, out := &in.Repos, &out.Repos
 		*out = make([]RepoStatus, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Restore != nil {
 		in, out := &in.Restore, &out.Restore
 		*out = new(PGBackRestJobStatus)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ExpireDryRun != nil {
+		in, out := &in.ExpireDryRun, &out.ExpireDryRun
+		*out = new(PGBackRestExpireDryRunStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestStatus.
@@ -811,6 +1515,20 @@ func (in *PGBouncerConfiguration) DeepCopyInto(out *PGBouncerConfiguration) {
 			(*out)[key] = val
 		}
 	}
+	if in.DatabasePools != nil {
+		in, out := &in.DatabasePools, &out.DatabasePools
+		*out = make([]PGBouncerDatabasePool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UserPools != nil {
+		in, out := &in.UserPools, &out.UserPools
+		*out = make([]PGBouncerUserPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBouncerConfiguration.
@@ -823,6 +1541,31 @@ func (in *PGBouncerConfiguration) DeepCopy() *PGBouncerConfiguration {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBouncerDatabasePool) DeepCopyInto(out *PGBouncerDatabasePool) {
+	*out = *in
+	if in.PoolSize != nil {
+		in, out := &in.PoolSize, &out.PoolSize
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxConnections != nil {
+		in, out := &in.MaxConnections, &out.MaxConnections
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBouncerDatabasePool.
+func (in *PGBouncerDatabasePool) DeepCopy() *PGBouncerDatabasePool {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBouncerDatabasePool)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBouncerPodSpec) DeepCopyInto(out *PGBouncerPodSpec) {
 	*out = *in
@@ -942,29 +1685,49 @@ func (in *PGBouncerSidecars) DeepCopy() *PGBouncerSidecars {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PGMonitorSpec) DeepCopyInto(out *PGMonitorSpec) {
+func (in *PGBouncerUserPool) DeepCopyInto(out *PGBouncerUserPool) {
 	*out = *in
-	if in.Exporter != nil {
-		in, out := &in.Exporter, &out.Exporter
-		*out = new(ExporterSpec)
-		(*in).DeepCopyInto(*out)
+	if in.MaxConnections != nil {
+		in, out := &in.MaxConnections, &out.MaxConnections
+		*out = new(int32)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGMonitorSpec.
-func (in *PGMonitorSpec) DeepCopy() *PGMonitorSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBouncerUserPool.
+func (in *PGBouncerUserPool) DeepCopy() *PGBouncerUserPool {
 	if in == nil {
 		return nil
 	}
-	out := new(PGMonitorSpec)
+	out := new(PGBouncerUserPool)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PGUpgrade) DeepCopyInto(out *PGUpgrade) {
+func (in *PGMonitorSpec) DeepCopyInto(out *PGMonitorSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
+	if in.Exporter != nil {
+		in, out := &in.Exporter, &out.Exporter
+		*out = new(ExporterSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGMonitorSpec.
+func (in *PGMonitorSpec) DeepCopy() *PGMonitorSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PGMonitorSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGUpgrade) DeepCopyInto(out *PGUpgrade) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
@@ -988,6 +1751,41 @@ func (in *PGUpgrade) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGUpgradeBackupStatus) DeepCopyInto(out *PGUpgradeBackupStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGUpgradeBackupStatus.
+func (in *PGUpgradeBackupStatus) DeepCopy() *PGUpgradeBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PGUpgradeBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGUpgradeExtensionUpdateStatus) DeepCopyInto(out *PGUpgradeExtensionUpdateStatus) {
+	*out = *in
+	if in.Updated != nil {
+		in, out := &in.Updated, &out.Updated
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGUpgradeExtensionUpdateStatus.
+func (in *PGUpgradeExtensionUpdateStatus) DeepCopy() *PGUpgradeExtensionUpdateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PGUpgradeExtensionUpdateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGUpgradeList) DeepCopyInto(out *PGUpgradeList) {
 	*out = *in
@@ -1020,6 +1818,81 @@ func (in *PGUpgradeList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGUpgradeLogicalReplicationSpec) DeepCopyInto(out *PGUpgradeLogicalReplicationSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGUpgradeLogicalReplicationSpec.
+func (in *PGUpgradeLogicalReplicationSpec) DeepCopy() *PGUpgradeLogicalReplicationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PGUpgradeLogicalReplicationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGUpgradeLogicalReplicationStatus) DeepCopyInto(out *PGUpgradeLogicalReplicationStatus) {
+	*out = *in
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TablesSyncing != nil {
+		in, out := &in.TablesSyncing, &out.TablesSyncing
+		*out = new(int32)
+		**out = **in
+	}
+	if in.LagBytes != nil {
+		in, out := &in.LagBytes, &out.LagBytes
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGUpgradeLogicalReplicationStatus.
+func (in *PGUpgradeLogicalReplicationStatus) DeepCopy() *PGUpgradeLogicalReplicationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PGUpgradeLogicalReplicationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGUpgradePreUpgradeBackupSpec) DeepCopyInto(out *PGUpgradePreUpgradeBackupSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGUpgradePreUpgradeBackupSpec.
+func (in *PGUpgradePreUpgradeBackupSpec) DeepCopy() *PGUpgradePreUpgradeBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PGUpgradePreUpgradeBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGUpgradeProgress) DeepCopyInto(out *PGUpgradeProgress) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGUpgradeProgress.
+func (in *PGUpgradeProgress) DeepCopy() *PGUpgradeProgress {
+	if in == nil {
+		return nil
+	}
+	out := new(PGUpgradeProgress)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGUpgradeSpec) DeepCopyInto(out *PGUpgradeSpec) {
 	*out = *in
@@ -1056,6 +1929,36 @@ func (in *PGUpgradeSpec) DeepCopyInto(out *PGUpgradeSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.BackoffLimit != nil {
+		in, out := &in.BackoffLimit, &out.BackoffLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.PodFailurePolicy != nil {
+		in, out := &in.PodFailurePolicy, &out.PodFailurePolicy
+		*out = new(JobPodFailurePolicy)
+		**out = **in
+	}
+	if in.UpdateExtensions != nil {
+		in, out := &in.UpdateExtensions, &out.UpdateExtensions
+		*out = new(PGUpgradeUpdateExtensionsSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PreUpgradeBackup != nil {
+		in, out := &in.PreUpgradeBackup, &out.PreUpgradeBackup
+		*out = new(PGUpgradePreUpgradeBackupSpec)
+		**out = **in
+	}
+	if in.LogicalReplication != nil {
+		in, out := &in.LogicalReplication, &out.LogicalReplication
+		*out = new(PGUpgradeLogicalReplicationSpec)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGUpgradeSpec.
@@ -1078,6 +1981,38 @@ func (in *PGUpgradeStatus) DeepCopyInto(out *PGUpgradeStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Incompatibilities != nil {
+		in, out := &in.Incompatibilities, &out.Incompatibilities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MissingModules != nil {
+		in, out := &in.MissingModules, &out.MissingModules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExtensionUpdates != nil {
+		in, out := &in.ExtensionUpdates, &out.ExtensionUpdates
+		*out = make([]PGUpgradeExtensionUpdateStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PreUpgradeBackup != nil {
+		in, out := &in.PreUpgradeBackup, &out.PreUpgradeBackup
+		*out = new(PGUpgradeBackupStatus)
+		**out = **in
+	}
+	if in.LogicalReplication != nil {
+		in, out := &in.LogicalReplication, &out.LogicalReplication
+		*out = new(PGUpgradeLogicalReplicationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Progress != nil {
+		in, out := &in.Progress, &out.Progress
+		*out = new(PGUpgradeProgress)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGUpgradeStatus.
@@ -1090,6 +2025,26 @@ func (in *PGUpgradeStatus) DeepCopy() *PGUpgradeStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGUpgradeUpdateExtensionsSpec) DeepCopyInto(out *PGUpgradeUpdateExtensionsSpec) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGUpgradeUpdateExtensionsSpec.
+func (in *PGUpgradeUpdateExtensionsSpec) DeepCopy() *PGUpgradeUpdateExtensionsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PGUpgradeUpdateExtensionsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PatroniSpec) DeepCopyInto(out *PatroniSpec) {
 	*out = *in
@@ -1193,6 +2148,85 @@ func (in *PostgresAdditionalConfig) DeepCopy() *PostgresAdditionalConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresAuthenticationSpec) DeepCopyInto(out *PostgresAuthenticationSpec) {
+	*out = *in
+	if in.SCRAMIterations != nil {
+		in, out := &in.SCRAMIterations, &out.SCRAMIterations
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresAuthenticationSpec.
+func (in *PostgresAuthenticationSpec) DeepCopy() *PostgresAuthenticationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresAuthenticationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresBenchmarkSpec) DeepCopyInto(out *PostgresBenchmarkSpec) {
+	*out = *in
+	if in.Scale != nil {
+		in, out := &in.Scale, &out.Scale
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Clients != nil {
+		in, out := &in.Clients, &out.Clients
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Jobs != nil {
+		in, out := &in.Jobs, &out.Jobs
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DurationSeconds != nil {
+		in, out := &in.DurationSeconds, &out.DurationSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresBenchmarkSpec.
+func (in *PostgresBenchmarkSpec) DeepCopy() *PostgresBenchmarkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresBenchmarkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresBenchmarkStatus) DeepCopyInto(out *PostgresBenchmarkStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresBenchmarkStatus.
+func (in *PostgresBenchmarkStatus) DeepCopy() *PostgresBenchmarkStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresBenchmarkStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresCluster) DeepCopyInto(out *PostgresCluster) {
 	*out = *in
@@ -1228,6 +2262,21 @@ func (in *PostgresClusterDataSource) DeepCopyInto(out *PostgresClusterDataSource
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Move != nil {
+		in, out := &in.Move, &out.Move
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(bool)
+		**out = **in
+	}
 	in.Resources.DeepCopyInto(&out.Resources)
 	if in.Affinity != nil {
 		in, out := &in.Affinity, &out.Affinity
@@ -1246,6 +2295,26 @@ func (in *PostgresClusterDataSource) DeepCopyInto(out *PostgresClusterDataSource
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.BackoffLimit != nil {
+		in, out := &in.BackoffLimit, &out.BackoffLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.PodFailurePolicy != nil {
+		in, out := &in.PodFailurePolicy, &out.PodFailurePolicy
+		*out = new(JobPodFailurePolicy)
+		**out = **in
+	}
+	if in.TTLSecondsAfterFinished != nil {
+		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresClusterDataSource.
@@ -1303,7 +2372,17 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 		*out = new(DataSource)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Authentication != nil {
+		in, out := &in.Authentication, &out.Authentication
+		*out = new(PostgresAuthenticationSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Backups.DeepCopyInto(&out.Backups)
+	if in.Benchmark != nil {
+		in, out := &in.Benchmark, &out.Benchmark
+		*out = new(PostgresBenchmarkSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.CustomTLSSecret != nil {
 		in, out := &in.CustomTLSSecret, &out.CustomTLSSecret
 		*out = new(v1.SecretProjection)
@@ -1319,6 +2398,13 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 		*out = new(DatabaseInitSQL)
 		**out = **in
 	}
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]PostgresDatabaseSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.DisableDefaultPodScheduling != nil {
 		in, out := &in.DisableDefaultPodScheduling, &out.DisableDefaultPodScheduling
 		*out = new(bool)
@@ -1336,6 +2422,18 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RemoteInstanceSets != nil {
+		in, out := &in.RemoteInstanceSets, &out.RemoteInstanceSets
+		*out = make([]PostgresRemoteInstanceSetSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LogRetention != nil {
+		in, out := &in.LogRetention, &out.LogRetention
+		*out = new(PostgresLogRetentionSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.OpenShift != nil {
 		in, out := &in.OpenShift, &out.OpenShift
 		*out = new(bool)
@@ -1361,6 +2459,11 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 		*out = new(PostgresProxySpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ReadOnly != nil {
+		in, out := &in.ReadOnly, &out.ReadOnly
+		*out = new(bool)
+		**out = **in
+	}
 	if in.UserInterface != nil {
 		in, out := &in.UserInterface, &out.UserInterface
 		*out = new(UserInterfaceSpec)
@@ -1376,6 +2479,21 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 		*out = new(ServiceSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ReplicaService != nil {
+		in, out := &in.ReplicaService, &out.ReplicaService
+		*out = new(ServiceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Reindex != nil {
+		in, out := &in.Reindex, &out.Reindex
+		*out = new(PostgresReindexSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReplicationSlots != nil {
+		in, out := &in.ReplicationSlots, &out.ReplicationSlots
+		*out = new(PostgresReplicationSlotsSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Shutdown != nil {
 		in, out := &in.Shutdown, &out.Shutdown
 		*out = new(bool)
@@ -1398,6 +2516,11 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.UserSync != nil {
+		in, out := &in.UserSync, &out.UserSync
+		*out = new(PostgresUserSyncSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Config.DeepCopyInto(&out.Config)
 }
 
@@ -1414,6 +2537,11 @@ func (in *PostgresClusterSpec) DeepCopy() *PostgresClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresClusterStatus) DeepCopyInto(out *PostgresClusterStatus) {
 	*out = *in
+	if in.Collation != nil {
+		in, out := &in.Collation, &out.Collation
+		*out = new(PostgresCollationStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.InstanceSets != nil {
 		in, out := &in.InstanceSets, &out.InstanceSets
 		*out = make([]PostgresInstanceSetStatus, len(*in))
@@ -1425,12 +2553,44 @@ func (in *PostgresClusterStatus) DeepCopyInto(out *PostgresClusterStatus) {
 		*out = new(PGBackRestStatus)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PreloadLibraries != nil {
+		in, out := &in.PreloadLibraries, &out.PreloadLibraries
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	out.Proxy = in.Proxy
+	if in.RemoteInstanceSetNamespaces != nil {
+		in, out := &in.RemoteInstanceSetNamespaces, &out.RemoteInstanceSetNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReplicationSlots != nil {
+		in, out := &in.ReplicationSlots, &out.ReplicationSlots
+		*out = new(PostgresReplicationSlotsStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.UserInterface != nil {
 		in, out := &in.UserInterface, &out.UserInterface
 		*out = new(PostgresUserInterfaceStatus)
 		**out = **in
 	}
+	if in.UserSync != nil {
+		in, out := &in.UserSync, &out.UserSync
+		*out = new(PostgresUserSyncStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Benchmark != nil {
+		in, out := &in.Benchmark, &out.Benchmark
+		*out = new(PostgresBenchmarkStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VolumeSnapshots != nil {
+		in, out := &in.VolumeSnapshots, &out.VolumeSnapshots
+		*out = make([]VolumeSnapshotStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	out.Monitoring = in.Monitoring
 	if in.DatabaseInitSQL != nil {
 		in, out := &in.DatabaseInitSQL, &out.DatabaseInitSQL
@@ -1456,6 +2616,97 @@ func (in *PostgresClusterStatus) DeepCopy() *PostgresClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresCollationStatus) DeepCopyInto(out *PostgresCollationStatus) {
+	*out = *in
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresCollationStatus.
+func (in *PostgresCollationStatus) DeepCopy() *PostgresCollationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresCollationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresDatabaseSpec) DeepCopyInto(out *PostgresDatabaseSpec) {
+	*out = *in
+	if in.Extensions != nil {
+		in, out := &in.Extensions, &out.Extensions
+		*out = make([]PostgresExtensionSpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.FDW != nil {
+		in, out := &in.FDW, &out.FDW
+		*out = make([]PostgresForeignServerSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresDatabaseSpec.
+func (in *PostgresDatabaseSpec) DeepCopy() *PostgresDatabaseSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresDatabaseSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresExtensionSpec) DeepCopyInto(out *PostgresExtensionSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresExtensionSpec.
+func (in *PostgresExtensionSpec) DeepCopy() *PostgresExtensionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresExtensionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresForeignServerSpec) DeepCopyInto(out *PostgresForeignServerSpec) {
+	*out = *in
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.UserMappings != nil {
+		in, out := &in.UserMappings, &out.UserMappings
+		*out = make([]PostgresUserMappingSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresForeignServerSpec.
+func (in *PostgresForeignServerSpec) DeepCopy() *PostgresForeignServerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresForeignServerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresInstanceSetSpec) DeepCopyInto(out *PostgresInstanceSetSpec) {
 	*out = *in
@@ -1492,6 +2743,11 @@ func (in *PostgresInstanceSetSpec) DeepCopyInto(out *PostgresInstanceSetSpec) {
 		*out = new(intstr.IntOrString)
 		**out = **in
 	}
+	if in.DisruptionSurge != nil {
+		in, out := &in.DisruptionSurge, &out.DisruptionSurge
+		*out = new(bool)
+		**out = **in
+	}
 	in.Resources.DeepCopyInto(&out.Resources)
 	if in.Sidecars != nil {
 		in, out := &in.Sidecars, &out.Sidecars
@@ -1505,6 +2761,11 @@ func (in *PostgresInstanceSetSpec) DeepCopyInto(out *PostgresInstanceSetSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.TmpVolumeSizeLimit != nil {
+		in, out := &in.TmpVolumeSizeLimit, &out.TmpVolumeSizeLimit
+		x := (*in).DeepCopy()
+		*out = &x
+	}
 	if in.TopologySpreadConstraints != nil {
 		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
 		*out = make([]v1.TopologySpreadConstraint, len(*in))
@@ -1551,6 +2812,31 @@ func (in *PostgresInstanceSetStatus) DeepCopy() *PostgresInstanceSetStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresLogRetentionSpec) DeepCopyInto(out *PostgresLogRetentionSpec) {
+	*out = *in
+	if in.MaxAgeHours != nil {
+		in, out := &in.MaxAgeHours, &out.MaxAgeHours
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxSize != nil {
+		in, out := &in.MaxSize, &out.MaxSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresLogRetentionSpec.
+func (in *PostgresLogRetentionSpec) DeepCopy() *PostgresLogRetentionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresLogRetentionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresPasswordSpec) DeepCopyInto(out *PostgresPasswordSpec) {
 	*out = *in
@@ -1602,6 +2888,101 @@ func (in *PostgresProxyStatus) DeepCopy() *PostgresProxyStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresReindexSpec) DeepCopyInto(out *PostgresReindexSpec) {
+	*out = *in
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresReindexSpec.
+func (in *PostgresReindexSpec) DeepCopy() *PostgresReindexSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresReindexSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRemoteInstanceSetSpec) DeepCopyInto(out *PostgresRemoteInstanceSetSpec) {
+	*out = *in
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = new(Metadata)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	in.RepoVolumeClaimSpec.DeepCopyInto(&out.RepoVolumeClaimSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRemoteInstanceSetSpec.
+func (in *PostgresRemoteInstanceSetSpec) DeepCopy() *PostgresRemoteInstanceSetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRemoteInstanceSetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresReplicationSlotsSpec) DeepCopyInto(out *PostgresReplicationSlotsSpec) {
+	*out = *in
+	if in.Keep != nil {
+		in, out := &in.Keep, &out.Keep
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxWALKeepSize != nil {
+		in, out := &in.MaxWALKeepSize, &out.MaxWALKeepSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresReplicationSlotsSpec.
+func (in *PostgresReplicationSlotsSpec) DeepCopy() *PostgresReplicationSlotsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresReplicationSlotsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresReplicationSlotsStatus) DeepCopyInto(out *PostgresReplicationSlotsStatus) {
+	*out = *in
+	if in.Inactive != nil {
+		in, out := &in.Inactive, &out.Inactive
+		*out = make([]InactiveReplicationSlot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresReplicationSlotsStatus.
+func (in *PostgresReplicationSlotsStatus) DeepCopy() *PostgresReplicationSlotsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresReplicationSlotsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresStandbySpec) DeepCopyInto(out *PostgresStandbySpec) {
 	*out = *in
@@ -1638,6 +3019,33 @@ func (in *PostgresUserInterfaceStatus) DeepCopy() *PostgresUserInterfaceStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresUserMappingSpec) DeepCopyInto(out *PostgresUserMappingSpec) {
+	*out = *in
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUserMappingSpec.
+func (in *PostgresUserMappingSpec) DeepCopy() *PostgresUserMappingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresUserMappingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresUserSpec) DeepCopyInto(out *PostgresUserSpec) {
 	*out = *in
@@ -1646,6 +3054,11 @@ func (in *PostgresUserSpec) DeepCopyInto(out *PostgresUserSpec) {
 		*out = make([]PostgresIdentifier, len(*in))
 		copy(*out, *in)
 	}
+	if in.MemberOf != nil {
+		in, out := &in.MemberOf, &out.MemberOf
+		*out = make([]PostgresIdentifier, len(*in))
+		copy(*out, *in)
+	}
 	if in.Password != nil {
 		in, out := &in.Password, &out.Password
 		*out = new(PostgresPasswordSpec)
@@ -1663,6 +3076,106 @@ func (in *PostgresUserSpec) DeepCopy() *PostgresUserSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresUserSyncDisabledUser) DeepCopyInto(out *PostgresUserSyncDisabledUser) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUserSyncDisabledUser.
+func (in *PostgresUserSyncDisabledUser) DeepCopy() *PostgresUserSyncDisabledUser {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresUserSyncDisabledUser)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresUserSyncGroup) DeepCopyInto(out *PostgresUserSyncGroup) {
+	*out = *in
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]PostgresIdentifier, len(*in))
+		copy(*out, *in)
+	}
+	if in.MemberOf != nil {
+		in, out := &in.MemberOf, &out.MemberOf
+		*out = make([]PostgresIdentifier, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUserSyncGroup.
+func (in *PostgresUserSyncGroup) DeepCopy() *PostgresUserSyncGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresUserSyncGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresUserSyncSpec) DeepCopyInto(out *PostgresUserSyncSpec) {
+	*out = *in
+	out.Secret = in.Secret
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]PostgresUserSyncGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.IntervalMinutes != nil {
+		in, out := &in.IntervalMinutes, &out.IntervalMinutes
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUserSyncSpec.
+func (in *PostgresUserSyncSpec) DeepCopy() *PostgresUserSyncSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresUserSyncSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresUserSyncStatus) DeepCopyInto(out *PostgresUserSyncStatus) {
+	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Disabled != nil {
+		in, out := &in.Disabled, &out.Disabled
+		*out = make([]PostgresUserSyncDisabledUser, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUserSyncStatus.
+func (in *PostgresUserSyncStatus) DeepCopy() *PostgresUserSyncStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresUserSyncStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RepoAzure) DeepCopyInto(out *RepoAzure) {
 	*out = *in
@@ -1678,6 +3191,29 @@ func (in *RepoAzure) DeepCopy() *RepoAzure {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepoBackupStatus) DeepCopyInto(out *RepoBackupStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.StopTime != nil {
+		in, out := &in.StopTime, &out.StopTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepoBackupStatus.
+func (in *RepoBackupStatus) DeepCopy() *RepoBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RepoBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RepoGCS) DeepCopyInto(out *RepoGCS) {
 	*out = *in
@@ -1728,6 +3264,11 @@ func (in *RepoPVC) DeepCopy() *RepoPVC {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RepoS3) DeepCopyInto(out *RepoS3) {
 	*out = *in
+	if in.ObjectLockDays != nil {
+		in, out := &in.ObjectLockDays, &out.ObjectLockDays
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepoS3.
@@ -1740,9 +3281,91 @@ func (in *RepoS3) DeepCopy() *RepoS3 {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepoRecoveryWindow) DeepCopyInto(out *RepoRecoveryWindow) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepoRecoveryWindow.
+func (in *RepoRecoveryWindow) DeepCopy() *RepoRecoveryWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(RepoRecoveryWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepoSharedHost) DeepCopyInto(out *RepoSharedHost) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepoSharedHost.
+func (in *RepoSharedHost) DeepCopy() *RepoSharedHost {
+	if in == nil {
+		return nil
+	}
+	out := new(RepoSharedHost)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RepoStatus) DeepCopyInto(out *RepoStatus) {
 	*out = *in
+	if in.Verified != nil {
+		in, out := &in.Verified, &out.Verified
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Sync != nil {
+		in, out := &in.Sync, &out.Sync
+		*out = new(RepoSyncStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastFullBackupTime != nil {
+		in, out := &in.LastFullBackupTime, &out.LastFullBackupTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastDifferentialBackupTime != nil {
+		in, out := &in.LastDifferentialBackupTime, &out.LastDifferentialBackupTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastIncrementalBackupTime != nil {
+		in, out := &in.LastIncrementalBackupTime, &out.LastIncrementalBackupTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastBackupSize != nil {
+		in, out := &in.LastBackupSize, &out.LastBackupSize
+		*out = new(int64)
+		**out = **in
+	}
+	if in.BackupsSize != nil {
+		in, out := &in.BackupsSize, &out.BackupsSize
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]RepoBackupStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RecoveryWindows != nil {
+		in, out := &in.RecoveryWindows, &out.RecoveryWindows
+		*out = make([]RepoRecoveryWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BackupInfoTime != nil {
+		in, out := &in.BackupInfoTime, &out.BackupInfoTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepoStatus.
@@ -1755,6 +3378,45 @@ func (in *RepoStatus) DeepCopy() *RepoStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepoSyncS3) DeepCopyInto(out *RepoSyncS3) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepoSyncS3.
+func (in *RepoSyncS3) DeepCopy() *RepoSyncS3 {
+	if in == nil {
+		return nil
+	}
+	out := new(RepoSyncS3)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepoSyncStatus) DeepCopyInto(out *RepoSyncStatus) {
+	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LagSeconds != nil {
+		in, out := &in.LagSeconds, &out.LagSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepoSyncStatus.
+func (in *RepoSyncStatus) DeepCopy() *RepoSyncStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RepoSyncStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in SchemalessObject) DeepCopyInto(out *SchemalessObject) {
 	{
@@ -1777,6 +3439,16 @@ func (in *ServiceSpec) DeepCopyInto(out *ServiceSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.SessionAffinity != nil {
+		in, out := &in.SessionAffinity, &out.SessionAffinity
+		*out = new(v1.ServiceAffinity)
+		**out = **in
+	}
+	if in.ExternalTrafficPolicy != nil {
+		in, out := &in.ExternalTrafficPolicy, &out.ExternalTrafficPolicy
+		*out = new(v1.ServiceExternalTrafficPolicyType)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceSpec.
@@ -1789,6 +3461,148 @@ func (in *ServiceSpec) DeepCopy() *ServiceSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SharedRepoHost) DeepCopyInto(out *SharedRepoHost) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SharedRepoHost.
+func (in *SharedRepoHost) DeepCopy() *SharedRepoHost {
+	if in == nil {
+		return nil
+	}
+	out := new(SharedRepoHost)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SharedRepoHost) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SharedRepoHostList) DeepCopyInto(out *SharedRepoHostList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SharedRepoHost, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SharedRepoHostList.
+func (in *SharedRepoHostList) DeepCopy() *SharedRepoHostList {
+	if in == nil {
+		return nil
+	}
+	out := new(SharedRepoHostList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SharedRepoHostList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SharedRepoHostSpec) DeepCopyInto(out *SharedRepoHostSpec) {
+	*out = *in
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = new(Metadata)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(string)
+		**out = **in
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	in.VolumeClaimSpec.DeepCopyInto(&out.VolumeClaimSpec)
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PriorityClassName != nil {
+		in, out := &in.PriorityClassName, &out.PriorityClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]v1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SharedRepoHostSpec.
+func (in *SharedRepoHostSpec) DeepCopy() *SharedRepoHostSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SharedRepoHostSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SharedRepoHostStatus) DeepCopyInto(out *SharedRepoHostStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Stanzas != nil {
+		in, out := &in.Stanzas, &out.Stanzas
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SharedRepoHostStatus.
+func (in *SharedRepoHostStatus) DeepCopy() *SharedRepoHostStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SharedRepoHostStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Sidecar) DeepCopyInto(out *Sidecar) {
 	*out = *in
@@ -1844,3 +3658,37 @@ func (in *UserInterfaceSpec) DeepCopy() *UserInterfaceSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotStatus) DeepCopyInto(out *VolumeSnapshotStatus) {
+	*out = *in
+	if in.CreationTime != nil {
+		in, out := &in.CreationTime, &out.CreationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotStatus.
+func (in *VolumeSnapshotStatus) DeepCopy() *VolumeSnapshotStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshots) DeepCopyInto(out *VolumeSnapshots) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshots.
+func (in *VolumeSnapshots) DeepCopy() *VolumeSnapshots {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshots)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,246 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package clustertest helps integration tests create PostgresClusters in a
+// Kubernetes API. It starts a local API with envtest or, when the
+// USE_EXISTING_CLUSTER environment variable is "true", connects to the cluster
+// of the current kubeconfig, e.g. one created by kind.
+//
+//	func TestSomething(t *testing.T) {
+//		cc := clustertest.Kubernetes(t)
+//		cluster := clustertest.Create(t, cc, clustertest.Cluster("hippo"))
+//		...
+//	}
+package clustertest
+
+import (
+	"context"
+	"path/filepath"
+	goruntime "runtime"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// DefaultPostgresVersion is the major version of PostgreSQL in clusters
+// returned by [Cluster].
+const DefaultPostgresVersion = 14
+
+var kubernetes struct {
+	sync.Mutex
+
+	env   *envtest.Environment
+	count int
+}
+
+// crdDirectory returns the directory of CRDs in the source of this module. It
+// works in this repository and in the module cache of projects that import it.
+func crdDirectory() string {
+	_, file, _, _ := goruntime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "config", "crd", "bases")
+}
+
+// Kubernetes starts or connects to a Kubernetes API and returns a client that
+// uses it. When starting a local API, the client is a member of the
+// "system:masters" group and the API has the CRDs of this operator. When any
+// of these fail, it calls t.Fatal. Tests in the same process share the API;
+// the last to finish stops it using t.Cleanup.
+func Kubernetes(t testing.TB) client.Client {
+	t.Helper()
+
+	kubernetes.Lock()
+	defer kubernetes.Unlock()
+
+	if kubernetes.env == nil {
+		env := &envtest.Environment{
+			CRDDirectoryPaths:     []string{crdDirectory()},
+			ErrorIfCRDPathMissing: true,
+		}
+
+		if _, err := env.Start(); err != nil {
+			t.Fatalf("unable to start Kubernetes: %v", err)
+		}
+
+		kubernetes.env = env
+	}
+
+	kubernetes.count++
+
+	t.Cleanup(func() {
+		kubernetes.Lock()
+		defer kubernetes.Unlock()
+
+		kubernetes.count--
+
+		if kubernetes.count == 0 {
+			if err := kubernetes.env.Stop(); err != nil {
+				t.Errorf("unable to stop Kubernetes: %v", err)
+			}
+			kubernetes.env = nil
+		}
+	})
+
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc, err := client.New(kubernetes.env.Config, client.Options{Scheme: scheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cc
+}
+
+// Namespace creates a random namespace that will be deleted by t.Cleanup.
+// When creation fails, it calls t.Fatal. The caller may delete the namespace
+// at any time.
+func Namespace(t testing.TB, cc client.Client) *corev1.Namespace {
+	t.Helper()
+
+	ns := &corev1.Namespace{}
+	ns.GenerateName = "postgres-operator-test-"
+	ns.Labels = map[string]string{"postgres-operator-test": t.Name()}
+
+	ctx := context.Background()
+	if err := cc.Create(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := client.IgnoreNotFound(cc.Delete(ctx, ns)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return ns
+}
+
+// Cluster returns a PostgresCluster named name with one instance and one
+// backup repository, each on a small volume. Images are left empty so that
+// the operator uses its defaults. Change any field before calling [Create].
+func Cluster(name string) *v1beta1.PostgresCluster {
+	volume := corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceStorage: resource.MustParse("1Gi"),
+			},
+		},
+	}
+
+	cluster := &v1beta1.PostgresCluster{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1beta1.GroupVersion.String(),
+			Kind:       "PostgresCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1beta1.PostgresClusterSpec{
+			PostgresVersion: DefaultPostgresVersion,
+			InstanceSets: []v1beta1.PostgresInstanceSetSpec{{
+				Name:                "instance1",
+				Replicas:            initialize.Int32(1),
+				DataVolumeClaimSpec: volume,
+			}},
+			Backups: v1beta1.Backups{
+				PGBackRest: v1beta1.PGBackRestArchive{
+					Repos: []v1beta1.PGBackRestRepo{{
+						Name: "repo1",
+						Volume: &v1beta1.RepoPVC{
+							VolumeClaimSpec: *volume.DeepCopy(),
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	return cluster
+}
+
+// Create creates cluster and deletes it using t.Cleanup. When cluster has no
+// namespace, it is created in a new one from [Namespace]. When creation fails,
+// it calls t.Fatal.
+func Create(t testing.TB, cc client.Client, cluster *v1beta1.PostgresCluster) *v1beta1.PostgresCluster {
+	t.Helper()
+
+	if cluster.Namespace == "" {
+		cluster.Namespace = Namespace(t, cc).Name
+	}
+
+	ctx := context.Background()
+	if err := cc.Create(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := client.IgnoreNotFound(cc.Delete(ctx, cluster)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return cluster
+}
+
+// WaitFor reads cluster until condition returns true or timeout passes. It
+// calls t.Fatal when reading fails or time runs out. Use it when an operator
+// is running against the API, e.g. in a kind cluster.
+func WaitFor(
+	t testing.TB, cc client.Client, cluster *v1beta1.PostgresCluster,
+	timeout time.Duration, condition func(*v1beta1.PostgresCluster) bool,
+) {
+	t.Helper()
+
+	ctx := context.Background()
+	err := wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+		err := cc.Get(ctx, client.ObjectKeyFromObject(cluster), cluster)
+		return err == nil && condition(cluster), err
+	})
+	if err != nil {
+		t.Fatalf("waiting for PostgresCluster %q: %v", cluster.Name, err)
+	}
+}
+
+// InstancesReady returns true when every instance set in cluster has as many
+// ready instances as it specifies. Use it with [WaitFor].
+func InstancesReady(cluster *v1beta1.PostgresCluster) bool {
+	ready := map[string]int32{}
+	for _, status := range cluster.Status.InstanceSets {
+		ready[status.Name] = status.ReadyReplicas
+	}
+
+	for _, set := range cluster.Spec.InstanceSets {
+		replicas := int32(1)
+		if set.Replicas != nil {
+			replicas = *set.Replicas
+		}
+		if ready[set.Name] < replicas {
+			return false
+		}
+	}
+	return true
+}
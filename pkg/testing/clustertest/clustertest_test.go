@@ -0,0 +1,67 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package clustertest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestCRDDirectory(t *testing.T) {
+	_, err := os.Stat(filepath.Join(crdDirectory(),
+		"postgres-operator.crunchydata.com_postgresclusters.yaml"))
+	assert.NilError(t, err)
+}
+
+func TestCluster(t *testing.T) {
+	cluster := Cluster("hippo")
+
+	assert.Equal(t, cluster.Name, "hippo")
+	assert.Equal(t, cluster.Namespace, "")
+	assert.Equal(t, cluster.Spec.PostgresVersion, DefaultPostgresVersion)
+	assert.Equal(t, len(cluster.Spec.InstanceSets), 1)
+	assert.Equal(t, len(cluster.Spec.Backups.PGBackRest.Repos), 1)
+
+	// Changing one volume does not change the other.
+	cluster.Spec.InstanceSets[0].DataVolumeClaimSpec.AccessModes[0] = "changed"
+	assert.Equal(t,
+		cluster.Spec.Backups.PGBackRest.Repos[0].Volume.VolumeClaimSpec.AccessModes[0],
+		corev1.ReadWriteOnce)
+}
+
+func TestInstancesReady(t *testing.T) {
+	cluster := Cluster("hippo")
+	cluster.Spec.InstanceSets = append(cluster.Spec.InstanceSets,
+		v1beta1.PostgresInstanceSetSpec{Name: "other", Replicas: initialize.Int32(2)})
+
+	assert.Assert(t, !InstancesReady(cluster), "expected no status to be not ready")
+
+	cluster.Status.InstanceSets = []v1beta1.PostgresInstanceSetStatus{
+		{Name: "instance1", Replicas: 1, ReadyReplicas: 1},
+		{Name: "other", Replicas: 2, ReadyReplicas: 1},
+	}
+	assert.Assert(t, !InstancesReady(cluster), "expected one missing replica to be not ready")
+
+	cluster.Status.InstanceSets[1].ReadyReplicas = 2
+	assert.Assert(t, InstancesReady(cluster))
+}
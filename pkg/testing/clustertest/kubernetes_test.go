@@ -0,0 +1,42 @@
+//go:build envtest
+// +build envtest
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package clustertest
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestCreate(t *testing.T) {
+	cc := Kubernetes(t)
+	cluster := Create(t, cc, Cluster("hippo"))
+
+	// The cluster is in a new namespace and passes validation by the API.
+	assert.Assert(t, cluster.Namespace != "")
+	assert.Assert(t, cluster.UID != "")
+
+	stored := &v1beta1.PostgresCluster{}
+	assert.NilError(t, cc.Get(context.Background(), client.ObjectKeyFromObject(cluster), stored))
+	assert.Equal(t, stored.Spec.PostgresVersion, DefaultPostgresVersion)
+}